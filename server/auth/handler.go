@@ -2,36 +2,63 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"strings"
+	"net/url"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/ksankeerth/open-image-registry/auth/keys"
+	"github.com/ksankeerth/open-image-registry/auth/revocation"
+	"github.com/ksankeerth/open-image-registry/client/ldap"
+	"github.com/ksankeerth/open-image-registry/client/oidc"
 	"github.com/ksankeerth/open-image-registry/config"
 	"github.com/ksankeerth/open-image-registry/constants"
 	"github.com/ksankeerth/open-image-registry/errors/httperrors"
 	"github.com/ksankeerth/open-image-registry/lib"
 	"github.com/ksankeerth/open-image-registry/log"
 	"github.com/ksankeerth/open-image-registry/middleware"
+	"github.com/ksankeerth/open-image-registry/resource/access"
 	"github.com/ksankeerth/open-image-registry/store"
 	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
 	"github.com/ksankeerth/open-image-registry/user"
+	"github.com/ksankeerth/open-image-registry/utils"
 )
 
 type AuthAPIHandler struct {
 	svc           *authService
 	userAdapter   *user.UserAdapter
 	authenticator *middleware.Authenticator
+	keyManager    *keys.Manager
 }
 
 // NewAuthAPIHandler creates a new auth API handler
-func NewAuthAPIHandler(store store.Store, jwtProvider lib.JWTProvider,
-	authenticator *middleware.Authenticator) *AuthAPIHandler {
+func NewAuthAPIHandler(store store.Store, jwtProvider lib.JWTProvider, authenticator *middleware.Authenticator,
+	accessManager *access.Manager, keyManager *keys.Manager, revocations *revocation.Cache) *AuthAPIHandler {
+	svc := &authService{
+		store:            store,
+		jwtAuthenticator: jwtProvider,
+		accessManager:    accessManager,
+		revocations:      revocations,
+	}
+
+	if oidcConfig := config.GetOIDCConfig(); oidcConfig.Enabled {
+		svc.oidcClient = oidc.NewClient(oidcConfig)
+	}
+	if ldapConfig := config.GetLDAPConfig(); ldapConfig.Enabled {
+		ldapClient, err := ldap.NewClient(ldapConfig)
+		if err != nil {
+			// LDAP users can't log in until the configuration is fixed. Local accounts keep working.
+			log.Logger().Error().Err(err).Msg("LDAP login is disabled due to configuration errors")
+		} else {
+			svc.ldapClient = ldapClient
+		}
+	}
+
 	return &AuthAPIHandler{
-		svc: &authService{
-			store:            store,
-			jwtAuthenticator: jwtProvider,
-		},
+		svc:           svc,
 		authenticator: authenticator,
+		keyManager:    keyManager,
 	}
 }
 
@@ -47,16 +74,7 @@ func (h *AuthAPIHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	userAgent := r.Header.Get("User-Agent")
-	xForwardedFor := r.Header.Get("X-Forwarded-For") // client_ip, lb1_ip, ........
-	var clientIp string
-	ips := strings.Split(xForwardedFor, ",")
-	if len(ips) > 0 {
-		clientIp = ips[0]
-	} else {
-		clientIp = xForwardedFor
-	}
-
-	loginResult, err := h.svc.authenticateUser(r.Context(), &loginRequest, userAgent, clientIp)
+	loginResult, err := h.svc.authenticateUser(r.Context(), &loginRequest, userAgent, utils.ClientIP(r))
 	authLoginResponse := mgmt.AuthLoginResponse{}
 
 	if err != nil {
@@ -69,7 +87,25 @@ func (h *AuthAPIHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// cookies are set after the second factor is verified
+	if loginResult.twoFactorToken != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(mgmt.AuthLoginResponse{
+			TwoFactor: &mgmt.TwoFactorChallengeDTO{
+				Token:              loginResult.twoFactorToken,
+				EnrollmentRequired: loginResult.twoFactorEnrollmentRequired,
+				ExpiresIn:          constants.TwoFactorChallengeExpiry,
+			},
+		})
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Error occurred when writing login response to client")
+		}
+		return
+	}
+
 	h.setAuthCookie(w, loginResult.jwtToken, loginResult.expiryInSeconds)
+	h.setRefreshCookie(w, loginResult.refreshToken, loginResult.refreshExpiryInSeconds)
 	w.WriteHeader(http.StatusOK)
 
 	authLoginResponse.User = mgmt.UserProfileInfo{
@@ -84,7 +120,198 @@ func (h *AuthAPIHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// LoginTwoFactor handles POST /api/v1/auth/login/2fa. It completes a login which needs a second factor.
+func (h *AuthAPIHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req mgmt.TwoFactorLoginRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when parsing two-factor login request")
+		httperrors.BadRequest(w, 400, "Invalid request body")
+		return
+	}
+	if req.Token == "" || (req.Code == "" && req.RecoveryCode == "") {
+		httperrors.BadRequest(w, 400, "Login token and verification code are required")
+		return
+	}
+
+	res, err := h.svc.completeTwoFactorLogin(r.Context(), &req, r.Header.Get("User-Agent"), utils.ClientIP(r))
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Two-factor login failed due to errors")
+		httperrors.SendError(w, res.statusCode, res.errorMessage)
+		return
+	}
+	if !res.success {
+		httperrors.SendError(w, res.statusCode, res.errorMessage)
+		return
+	}
+
+	h.setAuthCookie(w, res.jwtToken, res.expiryInSeconds)
+	h.setRefreshCookie(w, res.refreshToken, res.refreshExpiryInSeconds)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(mgmt.AuthLoginResponse{
+		User: mgmt.UserProfileInfo{
+			UserId:   res.userID,
+			Username: res.username,
+			Role:     res.userRole,
+		},
+		RecoveryCodes: res.recoveryCodes,
+	})
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when writing login response to client")
+	}
+}
+
+// LoginTwoFactorEnroll handles POST /api/v1/auth/login/2fa/enroll. Users whose role requires two-factor
+// authentication enroll here before completing their first login.
+func (h *AuthAPIHandler) LoginTwoFactorEnroll(w http.ResponseWriter, r *http.Request) {
+	var req mgmt.TwoFactorLoginEnrollRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Token == "" {
+		httperrors.BadRequest(w, 400, "Invalid request body")
+		return
+	}
+
+	res, enrollment, err := h.svc.enrollTwoFactorLogin(r.Context(), req.Token)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Two-factor enrollment failed due to errors")
+		httperrors.SendError(w, res.statusCode, res.errorMessage)
+		return
+	}
+	if !res.success {
+		httperrors.SendError(w, res.statusCode, res.errorMessage)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(enrollment)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when writing enrollment response to client")
+	}
+}
+
+// OIDCLogin handles GET /api/v1/auth/oidc/login. The browser is redirected to the identity provider.
+func (h *AuthAPIHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if h.svc.oidcClient == nil {
+		httperrors.NotFound(w, 404, "Single sign-on is not enabled")
+		return
+	}
+
+	authURL, state, statusCode, err := h.svc.startOIDCLogin(r.Context())
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Starting single sign-on login failed due to errors")
+		httperrors.SendError(w, statusCode, "Unable to start single sign-on login. Please try again!")
+		return
+	}
+
+	h.setOIDCStateCookie(w, state, constants.DefaultOIDCAuthRequestExpiry)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback handles GET /api/v1/auth/oidc/callback. The identity provider redirects the browser here after
+// login. On success, session cookies are set and the browser is redirected to the web app.
+func (h *AuthAPIHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if h.svc.oidcClient == nil {
+		httperrors.NotFound(w, 404, "Single sign-on is not enabled")
+		return
+	}
+
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		log.Logger().Warn().Msgf("Identity provider returned error: %s %s", errCode, query.Get("error_description"))
+		h.setOIDCStateCookie(w, "", -1)
+		httperrors.Unauthorized(w, 401, "Login was rejected by identity provider")
+		return
+	}
+
+	// state must be the one of the login started from this browser
+	state := query.Get("state")
+	c, err := r.Cookie(constants.OIDCStateCookie)
+	if err != nil || state == "" || c.Value != state {
+		httperrors.Unauthorized(w, 401, "Invalid login request. Please try again!")
+		return
+	}
+	h.setOIDCStateCookie(w, "", -1)
+
+	code := query.Get("code")
+	if code == "" {
+		httperrors.BadRequest(w, 400, "Authorization code is missing")
+		return
+	}
+
+	res, err := h.svc.completeOIDCLogin(r.Context(), state, code, r.Header.Get("User-Agent"), utils.ClientIP(r))
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Single sign-on login failed due to errors")
+		httperrors.SendError(w, res.statusCode, res.errorMessage)
+		return
+	}
+	if !res.success {
+		httperrors.SendError(w, res.statusCode, res.errorMessage)
+		return
+	}
+
+	// the web app completes the login from POST /api/v1/auth/login/2fa with the token in the URL fragment. Fragments
+	// are not sent to servers, so the token doesn't reach access logs.
+	if res.twoFactorToken != "" {
+		fragment := url.Values{}
+		fragment.Set("two_factor_token", res.twoFactorToken)
+		fragment.Set("enrollment_required", strconv.FormatBool(res.twoFactorEnrollmentRequired))
+		http.Redirect(w, r, config.GetOIDCConfig().PostLoginRedirect+"#"+fragment.Encode(), http.StatusFound)
+		return
+	}
+
+	h.setAuthCookie(w, res.jwtToken, res.expiryInSeconds)
+	h.setRefreshCookie(w, res.refreshToken, res.refreshExpiryInSeconds)
+	http.Redirect(w, r, config.GetOIDCConfig().PostLoginRedirect, http.StatusFound)
+}
+
+// Refresh handles POST /api/v1/auth/refresh
+func (h *AuthAPIHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie(constants.RefreshTokenCookie)
+	if errors.Is(err, http.ErrNoCookie) || c == nil || c.Value == "" {
+		httperrors.Unauthorized(w, 401, "refresh token not found")
+		return
+	}
+
+	res, err := h.svc.refreshSession(r.Context(), c.Value)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Session refresh failed due to errors")
+		httperrors.SendError(w, res.statusCode, res.errorMessage)
+		return
+	}
+	if !res.success {
+		h.clearCookies(w)
+		httperrors.SendError(w, res.statusCode, res.errorMessage)
+		return
+	}
+
+	h.setAuthCookie(w, res.jwtToken, res.expiryInSeconds)
+	h.setRefreshCookie(w, res.refreshToken, res.refreshExpiryInSeconds)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(mgmt.AuthLoginResponse{
+		User: mgmt.UserProfileInfo{
+			UserId:   res.userID,
+			Username: res.username,
+			Role:     res.userRole,
+		},
+	})
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when writing refresh response to client")
+	}
+}
+
 func (h *AuthAPIHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Context().Value(constants.ContextAuthMethod) == constants.AuthMethodAccessToken {
+		httperrors.BadRequest(w, 400, "Personal access tokens are revoked from /api/v1/users/me/tokens")
+		return
+	}
+
 	signatureHash := r.Context().Value(constants.ContextSignatureHash)
 	if signatureHash == nil {
 		log.Logger().Error().Msg("signature hash is not found in request context")
@@ -143,22 +370,115 @@ func (h *AuthAPIHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.svc.revokeToken(r.Context(), signatureHashVal, userIdStr, expVal, iatVal)
+	// auth tokens signed before sessions were introduced don't have a session
+	sessionID, _ := r.Context().Value(constants.ContextSessionID).(string)
+
+	err := h.svc.revokeToken(r.Context(), signatureHashVal, sessionID, userIdStr, expVal, iatVal)
 	if err != nil {
 		log.Logger().Error().Err(err).Msg("Request failed due to errors")
 		httperrors.InternalError(w, 500, "Request failed due to errors")
 		return
 	}
 
+	h.clearCookies(w)
+	w.WriteHeader(http.StatusOK)
+}
+
+// ListSigningKeys handles GET /api/v1/auth/keys
+func (h *AuthAPIHandler) ListSigningKeys(w http.ResponseWriter, r *http.Request) {
+	signingKeys, err := h.keyManager.List(r.Context())
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Listing signing keys failed due to errors")
+		httperrors.InternalError(w, 500, "Request failed due to errors")
+		return
+	}
+
+	res := make([]*mgmt.SigningKeyDTO, 0, len(signingKeys))
+	for _, key := range signingKeys {
+		res = append(res, toSigningKeyDTO(h.keyManager, key))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when writing signing keys to client")
+	}
+}
+
+// RotateSigningKey handles POST /api/v1/auth/keys/rotate. A new key signs tokens from now on, and the previous key
+// verifies tokens until its grace period ends.
+func (h *AuthAPIHandler) RotateSigningKey(w http.ResponseWriter, r *http.Request) {
+	key, err := h.keyManager.Rotate(r.Context())
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Rotating signing key failed due to errors")
+		httperrors.InternalError(w, 500, "Request failed due to errors")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(toSigningKeyDTO(h.keyManager, key))
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when writing signing key to client")
+	}
+}
+
+// ActivateSigningKey handles PUT /api/v1/auth/keys/{kid}/activate. It switches back to a retired key which is still
+// in its grace period.
+func (h *AuthAPIHandler) ActivateSigningKey(w http.ResponseWriter, r *http.Request) {
+	kid := chi.URLParam(r, "kid")
+
+	found, err := h.keyManager.Activate(r.Context(), kid)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Activating signing key failed due to errors")
+		httperrors.InternalError(w, 500, "Request failed due to errors")
+		return
+	}
+	if !found {
+		httperrors.NotFound(w, 404, "Signing key not found")
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
+// JWKS handles GET /.well-known/jwks.json. It returns the public keys which verify auth tokens.
+func (h *AuthAPIHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	jwks, err := toJWKSet(h.keyManager.Algorithm(), h.keyManager.PublicKeys())
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when creating JWKS")
+		httperrors.InternalError(w, 500, "Error occurred when loading signing keys")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(jwks)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when writing JWKS to client")
+	}
+}
+
 func (h *AuthAPIHandler) Routes() chi.Router {
 
 	router := chi.NewRouter()
 	router.Route("/", func(r chi.Router) {
 		r.Post("/login", h.Login)
+		r.Post("/login/2fa", h.LoginTwoFactor)
+		r.Post("/login/2fa/enroll", h.LoginTwoFactorEnroll)
+		r.Post("/refresh", h.Refresh)
+		r.Get("/oidc/login", h.OIDCLogin)
+		r.Get("/oidc/callback", h.OIDCCallback)
 		r.With(h.authenticator.Authenticate).Post("/logout", h.Logout)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.authenticator.Authenticate, middleware.RequireRole(constants.RoleAdmin))
+			r.Get("/keys", h.ListSigningKeys)
+			r.Post("/keys/rotate", h.RotateSigningKey)
+			r.Put("/keys/{kid}/activate", h.ActivateSigningKey)
+		})
 	})
 
 	return router
@@ -181,4 +501,44 @@ func (h *AuthAPIHandler) setAuthCookie(w http.ResponseWriter, token string, expi
 	}
 
 	http.SetCookie(w, cookie)
-}
\ No newline at end of file
+}
+
+// setRefreshCookie sets the refresh token. It is sent only to auth APIs.
+func (h *AuthAPIHandler) setRefreshCookie(w http.ResponseWriter, token string, expiryInSeconds int) {
+	cookie := &http.Cookie{
+		Name:     constants.RefreshTokenCookie,
+		Value:    token,
+		Path:     constants.RefreshTokenCookiePath,
+		MaxAge:   expiryInSeconds,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+
+	if config.GetDevelopmentConfig().Enable {
+		cookie.SameSite = http.SameSiteLaxMode
+		cookie.Secure = false
+	}
+
+	http.SetCookie(w, cookie)
+}
+
+// setOIDCStateCookie binds the OIDC login to the browser. It must be sent on the redirect from the identity provider,
+// so SameSite is Lax.
+func (h *AuthAPIHandler) setOIDCStateCookie(w http.ResponseWriter, state string, expiryInSeconds int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     constants.OIDCStateCookie,
+		Value:    state,
+		Path:     constants.OIDCStateCookiePath,
+		MaxAge:   expiryInSeconds,
+		HttpOnly: true,
+		Secure:   !config.GetDevelopmentConfig().Enable,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (h *AuthAPIHandler) clearCookies(w http.ResponseWriter) {
+	// negative MaxAge deletes the cookie
+	h.setAuthCookie(w, "", -1)
+	h.setRefreshCookie(w, "", -1)
+}
@@ -0,0 +1,403 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/lib"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+// reloadInterval is how often keys are reloaded from the database, so keys rotated by other instances or the CLI
+// are applied
+const reloadInterval = time.Minute
+
+// unknownKeyReloadInterval limits reloads for tokens signed with unknown keys, so tokens with forged kid headers
+// can't make each request query the database
+const unknownKeyReloadInterval = 10 * time.Second
+
+// unknownKeyReloadTimeout limits how long verifying a token waits for the reload
+const unknownKeyReloadTimeout = 5 * time.Second
+
+// Manager keeps the signing keys of auth tokens in the database and applies them to the authenticator. Tokens are
+// signed with the active key. Retired keys verify tokens until their grace period ends, so rotating keys doesn't end
+// existing logins. Only keys of the algorithm of the authenticator are used, so keys of a previous algorithm are ignored
+// after the algorithm is changed.
+type Manager struct {
+	store         store.Store
+	authenticator *lib.OAuthJWTAuthenticator
+	retiredGrace  time.Duration
+	// mu serializes changes of keys
+	mu sync.Mutex
+	// generation counts the key sets applied to the authenticator. It is incremented while holding mu.
+	generation atomic.Uint64
+	// unknownKeyMu guards unknownKeyReloadAt
+	unknownKeyMu sync.Mutex
+	// unknownKeyReloadAt is when keys were reloaded for a token signed with an unknown key
+	unknownKeyReloadAt time.Time
+}
+
+func NewManager(store store.Store, authenticator *lib.OAuthJWTAuthenticator,
+	retiredGrace time.Duration) *Manager {
+	m := &Manager{
+		store:         store,
+		authenticator: authenticator,
+		retiredGrace:  retiredGrace,
+	}
+	authenticator.SetUnknownKeyHandler(m.reloadForUnknownKey)
+	return m
+}
+
+// Init imports the configured key pair as the active key if there is no active key of the configured algorithm,
+// activates the key of active_key_id, and loads the keys.
+func (m *Manager) Init(ctx context.Context, authConfig *config.AuthTokenConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys, err := m.store.SigningKeys().List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var activeKey *models.SigningKey
+	for _, key := range keys {
+		if key.Active {
+			activeKey = key
+		}
+	}
+
+	// the algorithm is changed or there are no keys yet
+	if activeKey == nil || activeKey.Algorithm != m.authenticator.Algorithm() {
+		key, err := newSigningKey(authConfig.GetPrivateKey(), m.authenticator.Algorithm(), false)
+		if err != nil {
+			return err
+		}
+
+		existing, err := m.store.SigningKeys().Get(ctx, key.KeyID)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.Algorithm != key.Algorithm {
+			return fmt.Errorf("configured key pair is a signing key of %s. Use another key pair for %s",
+				existing.Algorithm, key.Algorithm)
+		}
+		if existing == nil {
+			err = m.store.SigningKeys().Create(ctx, key)
+			if err != nil {
+				return err
+			}
+			log.Logger().Info().Msgf("Imported the configured key pair as signing key: %s", key.KeyID)
+		}
+
+		found, err := m.activate(ctx, key.KeyID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("configured key pair does not exist as signing key: %s", key.KeyID)
+		}
+	}
+
+	if authConfig.ActiveKeyID != "" {
+		found, err := m.activate(ctx, authConfig.ActiveKeyID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("signing key of security.auth_token.active_key_id does not exist: %s",
+				authConfig.ActiveKeyID)
+		}
+	}
+
+	return m.reload(ctx)
+}
+
+// Start reloads keys periodically until ctx is cancelled
+func (m *Manager) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(reloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := m.Reload(ctx)
+				if err != nil {
+					log.Logger().Error().Err(err).Msg("Error occurred when reloading signing keys")
+				}
+			}
+		}
+	}()
+}
+
+// Reload applies keys of the database to the authenticator. Keys retired longer than the grace period are deleted.
+func (m *Manager) Reload(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.reload(ctx)
+}
+
+// reloadForUnknownKey reloads keys when a token is signed with a key which isn't loaded, e.g. a key rotated by another
+// instance since the last reload. Reloads are rate limited, so unknown keys are rejected without reloading in between.
+// Keys are loaded without holding mu, so a slow reload doesn't block rotations or other tokens.
+func (m *Manager) reloadForUnknownKey(kid string) {
+	m.unknownKeyMu.Lock()
+	if time.Since(m.unknownKeyReloadAt) < unknownKeyReloadInterval {
+		m.unknownKeyMu.Unlock()
+		return
+	}
+	m.unknownKeyReloadAt = time.Now()
+	m.unknownKeyMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), unknownKeyReloadTimeout)
+	defer cancel()
+
+	generation := m.generation.Load()
+	signingKey, verifyKeys, err := m.loadKeys(ctx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when reloading signing keys for unknown key: %s", kid)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// keys applied meanwhile by a rotation or another reload aren't older than the loaded keys
+	if m.generation.Load() != generation {
+		return
+	}
+	m.apply(signingKey, verifyKeys)
+}
+
+// List returns the keys from the newest. Private keys are included.
+func (m *Manager) List(ctx context.Context) ([]*models.SigningKey, error) {
+	err := m.store.SigningKeys().DeleteRetired(ctx, m.graceSeconds())
+	if err != nil {
+		return nil, err
+	}
+	return m.store.SigningKeys().List(ctx)
+}
+
+// Rotate generates a key and activates it. The active key is retired.
+func (m *Manager) Rotate(ctx context.Context) (*models.SigningKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	privateKey, err := lib.GenerateKey(m.authenticator.Algorithm())
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when generating signing key")
+		return nil, err
+	}
+
+	key, err := newSigningKey(privateKey, m.authenticator.Algorithm(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.update(ctx, func(ctx context.Context) error {
+		err := m.store.SigningKeys().RetireActive(ctx)
+		if err != nil {
+			return err
+		}
+		return m.store.SigningKeys().Create(ctx, key)
+	})
+	if err == nil {
+		err = m.reload(ctx)
+	}
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when rotating signing key")
+		return nil, err
+	}
+
+	log.Logger().Info().Msgf("Signing key is rotated. Active key: %s", key.KeyID)
+	return m.store.SigningKeys().Get(ctx, key.KeyID)
+}
+
+// Activate makes the key the active key. The active key is retired. found is false if the key doesn't exist, its
+// grace period ended or it is a key of another algorithm.
+func (m *Manager) Activate(ctx context.Context, kid string) (found bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.activate(ctx, kid)
+}
+
+func (m *Manager) activate(ctx context.Context, kid string) (found bool, err error) {
+	err = m.update(ctx, func(ctx context.Context) error {
+		err := m.store.SigningKeys().DeleteRetired(ctx, m.graceSeconds())
+		if err != nil {
+			return err
+		}
+
+		key, err := m.store.SigningKeys().Get(ctx, kid)
+		if err != nil || key == nil || key.Algorithm != m.authenticator.Algorithm() {
+			return err
+		}
+		found = true
+		if key.Active {
+			return nil
+		}
+
+		err = m.store.SigningKeys().RetireActive(ctx)
+		if err != nil {
+			return err
+		}
+		return m.store.SigningKeys().Activate(ctx, kid)
+	})
+	if err == nil && found {
+		err = m.reload(ctx)
+	}
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when activating signing key: %s", kid)
+		return false, err
+	}
+
+	if found {
+		log.Logger().Info().Msgf("Signing key is activated: %s", kid)
+	}
+	return found, nil
+}
+
+// update changes keys in a transaction
+func (m *Manager) update(reqCtx context.Context, fn func(ctx context.Context) error) (err error) {
+	tx, err := m.store.Begin(reqCtx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	return fn(store.WithTxContext(reqCtx, tx))
+}
+
+func (m *Manager) reload(ctx context.Context) error {
+	signingKey, verifyKeys, err := m.loadKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.apply(signingKey, verifyKeys)
+	return nil
+}
+
+// loadKeys reads keys of the algorithm of the authenticator from the database
+func (m *Manager) loadKeys(ctx context.Context) (signingKey *lib.JWTKey, verifyKeys []*lib.JWTKey, err error) {
+	err = m.store.SigningKeys().DeleteRetired(ctx, m.graceSeconds())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys, err := m.store.SigningKeys().List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, key := range keys {
+		if key.Algorithm != m.authenticator.Algorithm() {
+			continue
+		}
+		jwtKey, err := parseSigningKey(key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid signing key %s: %w", key.KeyID, err)
+		}
+		if key.Active {
+			signingKey = jwtKey
+		} else {
+			// retired keys only verify tokens
+			jwtKey.PrivateKey = nil
+			verifyKeys = append(verifyKeys, jwtKey)
+		}
+	}
+
+	if signingKey == nil {
+		return nil, nil, errors.New("there is no active signing key")
+	}
+	return signingKey, verifyKeys, nil
+}
+
+// apply sets the keys of the authenticator. mu must be held.
+func (m *Manager) apply(signingKey *lib.JWTKey, verifyKeys []*lib.JWTKey) {
+	m.authenticator.SetKeys(signingKey, verifyKeys)
+	m.generation.Add(1)
+}
+
+func (m *Manager) graceSeconds() int {
+	return int(m.retiredGrace.Seconds())
+}
+
+// ExpiresAt returns when the retired key stops verifying tokens. It is nil for the active key.
+func (m *Manager) ExpiresAt(key *models.SigningKey) *time.Time {
+	if key.RetiredAt == nil {
+		return nil
+	}
+	expiresAt := key.RetiredAt.Add(m.retiredGrace)
+	return &expiresAt
+}
+
+// Algorithm returns the algorithm of signing keys
+func (m *Manager) Algorithm() string {
+	return m.authenticator.Algorithm()
+}
+
+// PublicKeys returns the keys which verify tokens. The active key is the first.
+func (m *Manager) PublicKeys() []*lib.JWTKey {
+	return m.authenticator.PublicKeys()
+}
+
+func newSigningKey(privateKey crypto.Signer, algorithm string, active bool) (*models.SigningKey, error) {
+	keyID, err := lib.KeyID(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	privateKeyPEM, err := lib.MarshalPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKeyPEM, err := lib.MarshalPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SigningKey{
+		KeyID:      keyID,
+		Algorithm:  algorithm,
+		PrivateKey: privateKeyPEM,
+		PublicKey:  publicKeyPEM,
+		Active:     active,
+	}, nil
+}
+
+func parseSigningKey(key *models.SigningKey) (*lib.JWTKey, error) {
+	privateKey, err := lib.ParsePrivateKey([]byte(key.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	err = lib.ValidateKey(key.Algorithm, privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &lib.JWTKey{
+		ID:         key.KeyID,
+		PrivateKey: privateKey,
+		PublicKey:  privateKey.Public(),
+	}, nil
+}
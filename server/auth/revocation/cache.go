@@ -0,0 +1,135 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+)
+
+// purgeInterval is how often revocations of expired tokens are deleted from the database
+const purgeInterval = time.Hour
+
+// Cache keeps signature hashes of revoked auth tokens in memory, so authenticating requests doesn't query the
+// database. Tokens revoked by this instance are added on logout. Tokens revoked by other instances are applied when
+// the cache is reloaded. Tokens of login sessions which aren't in the cache are checked in the database, so tokens
+// revoked by other instances are rejected right away. Other tokens are checked in the database only if the cache
+// couldn't be reloaded recently.
+type Cache struct {
+	store          store.Store
+	reloadInterval time.Duration
+	mu             sync.RWMutex
+	// revoked maps signature hashes to expiry of tokens in unix time
+	revoked  map[string]int64
+	loadedAt time.Time
+}
+
+func NewCache(store store.Store, reloadInterval time.Duration) *Cache {
+	return &Cache{
+		store:          store,
+		reloadInterval: reloadInterval,
+		revoked:        make(map[string]int64),
+	}
+}
+
+// Load replaces the cache with revoked tokens of the database which haven't expired
+func (c *Cache) Load(ctx context.Context) error {
+	tokens, err := c.store.Auth().ListRevokedTokens(ctx, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	revoked := make(map[string]int64, len(tokens))
+	for _, token := range tokens {
+		revoked[token.SignatureHash] = token.ExpiresAt
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// revocations are never undone, so cached tokens are kept until they expire. The list may not have tokens
+	// added while loading.
+	for signatureHash, expiresAt := range c.revoked {
+		if _, ok := revoked[signatureHash]; !ok && expiresAt > time.Now().Unix() {
+			revoked[signatureHash] = expiresAt
+		}
+	}
+	c.revoked = revoked
+	c.loadedAt = time.Now()
+	return nil
+}
+
+// Start reloads the cache and purges revocations of expired tokens periodically until ctx is cancelled
+func (c *Cache) Start(ctx context.Context) {
+	go func() {
+		reloadTicker := time.NewTicker(c.reloadInterval)
+		defer reloadTicker.Stop()
+		purgeTicker := time.NewTicker(purgeInterval)
+		defer purgeTicker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reloadTicker.C:
+				err := c.Load(ctx)
+				if err != nil {
+					log.Logger().Error().Err(err).Msg("Error occurred when reloading revoked tokens")
+				}
+			case <-purgeTicker.C:
+				err := c.Purge(ctx)
+				if err != nil {
+					log.Logger().Error().Err(err).Msg("Error occurred when purging revoked tokens")
+				}
+			}
+		}
+	}()
+}
+
+// Purge deletes revocations of expired tokens from the database. Expired tokens are rejected regardless of
+// revocation.
+func (c *Cache) Purge(ctx context.Context) error {
+	count, err := c.store.Auth().DeleteExpiredRevokedTokens(ctx, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		log.Logger().Info().Msgf("Purged %d revoked tokens which have expired", count)
+	}
+	return nil
+}
+
+// Add adds the revoked token to the cache. The revocation must be committed to the database.
+func (c *Cache) Add(signatureHash string, expiresAt int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.revoked[signatureHash] = expiresAt
+}
+
+// IsRevoked returns whether the token of the signature hash is revoked. Tokens which aren't in the cache are checked
+// in the database if sessionBound is set, since authenticating them queries the database for the session anyway.
+func (c *Cache) IsRevoked(ctx context.Context, signatureHash string, sessionBound bool) (bool, error) {
+	c.mu.RLock()
+	_, revoked := c.revoked[signatureHash]
+	// a missed reload is tolerated, e.g. while the database is busy
+	stale := time.Since(c.loadedAt) > 2*c.reloadInterval
+	c.mu.RUnlock()
+
+	if revoked || (!stale && !sessionBound) {
+		return revoked, nil
+	}
+
+	token, err := c.store.Auth().GetRevokedToken(ctx, signatureHash)
+	if err != nil {
+		return false, err
+	}
+	if token == nil {
+		return false, nil
+	}
+
+	c.Add(token.SignatureHash, token.ExpiresAt)
+	return true, nil
+}
@@ -2,22 +2,36 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/ksankeerth/open-image-registry/auth/revocation"
+	"github.com/ksankeerth/open-image-registry/client/ldap"
+	"github.com/ksankeerth/open-image-registry/client/oidc"
 	"github.com/ksankeerth/open-image-registry/config"
 	"github.com/ksankeerth/open-image-registry/constants"
 	"github.com/ksankeerth/open-image-registry/lib"
 	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/resource/access"
 	"github.com/ksankeerth/open-image-registry/security"
 	"github.com/ksankeerth/open-image-registry/store"
 	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
 	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
 )
 
 type authService struct {
 	store            store.Store
 	jwtAuthenticator lib.JWTProvider
+	accessManager    *access.Manager
+	revocations      *revocation.Cache
+	// oidcClient is nil if single sign-on is disabled
+	oidcClient *oidc.Client
+	// ldapClient is nil if LDAP login is disabled
+	ldapClient *ldap.Client
 }
 
 type authLoginResult struct {
@@ -29,11 +43,29 @@ type authLoginResult struct {
 	username        string
 	jwtToken        string
 	expiryInSeconds int
+	// refreshToken renews jwtToken until the session expires in refreshExpiryInSeconds
+	refreshToken           string
+	refreshExpiryInSeconds int
+	// twoFactorToken is set instead of a session if the login needs a second factor
+	twoFactorToken              string
+	twoFactorEnrollmentRequired bool
+	// recoveryCodes are set if two-factor authentication was enrolled during the login
+	recoveryCodes []string
 }
 
 func (svc *authService) authenticateUser(reqCtx context.Context, req *mgmt.AuthLoginRequest, userAgent, clientIp string) (*authLoginResult, error) {
 	loginRes := &authLoginResult{}
 
+	if svc.ldapClient != nil {
+		directoryLogin, err := svc.isDirectoryLogin(reqCtx, req.Username)
+		if err != nil {
+			return loginRes.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), err
+		}
+		if directoryLogin {
+			return svc.authenticateLDAPUser(reqCtx, req, userAgent, clientIp)
+		}
+	}
+
 	tx, err := svc.store.Begin(reqCtx)
 	if err != nil {
 		log.Logger().Error().Err(err).Msg("error occurred when starting transaction")
@@ -71,6 +103,37 @@ func (svc *authService) authenticateUser(reqCtx context.Context, req *mgmt.AuthL
 		return loginRes, nil
 	}
 
+	// Machine accounts authenticate only with their secrets. Since they don't have passwords, login attempts are
+	// rejected without counting them towards the lockout.
+	if userAccount.AccountType == constants.AccountTypeMachine {
+		log.Logger().Warn().Msgf("Login attempt with machine account: %s", req.Username)
+		loginRes.success = false
+		loginRes.errorMessage = "Invalid username or password!"
+		loginRes.statusCode = http.StatusUnauthorized
+
+		return loginRes, nil
+	}
+
+	// Accounts of identity providers don't have passwords. Same as machine accounts, login attempts are rejected
+	// without counting them towards the lockout. Accounts of LDAP reach here only if LDAP login is disabled.
+	identityProvider, err := svc.store.Users().GetIdentityProvider(ctx, userAccount.Id)
+	if err != nil {
+		loginRes.success = false
+		loginRes.errorMessage = "Opps! Error occured when logging in!"
+		loginRes.statusCode = http.StatusInternalServerError
+
+		return loginRes, err
+	}
+	if identityProvider != "" {
+		log.Logger().Warn().Msgf("Password login attempt with account of identity provider(%s): %s",
+			identityProvider, req.Username)
+		loginRes.success = false
+		loginRes.errorMessage = "Invalid username or password!"
+		loginRes.statusCode = http.StatusUnauthorized
+
+		return loginRes, nil
+	}
+
 	if userAccount.Locked && userAccount.LockedReason != constants.MaxFailedLoginAttempts {
 		loginRes.success = false
 		loginRes.errorMessage = "User account has been locked! Contact system administrator."
@@ -146,38 +209,578 @@ func (svc *authService) authenticateUser(reqCtx context.Context, req *mgmt.AuthL
 
 	loginRes.userRole = roleName
 
+	// the session is started after the second factor is verified by completeTwoFactorLogin
+	started, err := svc.startTwoFactorChallenge(ctx, loginRes, userAccount, scopeHash(req.Scopes),
+		constants.GrantTypePassword)
+	if err != nil || started {
+		return loginRes, err
+	}
+
+	err = svc.startSession(ctx, loginRes, userAccount, scopeHash(req.Scopes), constants.GrantTypePassword,
+		userAgent, clientIp)
+	return loginRes, err
+}
+
+// startSession creates a login session of the user and signs its first auth token. userRole of loginRes must be set.
+func (svc *authService) startSession(ctx context.Context, loginRes *authLoginResult, userAccount *models.UserAccount,
+	sessionScopeHash, grantType, userAgent, clientIp string) error {
+	err := svc.store.Auth().DeleteExpiredSessions(ctx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to delete expired sessions of user(%s)", userAccount.Username)
+		loginRes.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!")
+		return err
+	}
+
+	refreshToken, err := security.GenerateAccessToken(constants.RefreshTokenPrefix)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when generating refresh token")
+		loginRes.fail(http.StatusInternalServerError, "Opps! Token gernation failed. Please try again!")
+		return err
+	}
+
+	refreshExpiry := config.GetAuthTokenConfig().RefreshExpiry
+	sessionID, err := svc.store.Auth().CreateSession(ctx, &models.AuthSession{
+		UserID:           userAccount.Id,
+		ScopeHash:        sessionScopeHash,
+		RefreshTokenHash: utils.CalcuateDigest([]byte(refreshToken)),
+		UserAgent:        userAgent,
+		IPAddress:        clientIp,
+		GrantType:        grantType,
+	}, refreshExpiry)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to create login session of user(%s)", userAccount.Username)
+		loginRes.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!")
+		return err
+	}
+
 	token, err := svc.jwtAuthenticator.Sign(map[string]any{
-		"sub":  req.Username,
-		"role": loginRes.userRole,
+		"sub":                    userAccount.Username,
+		"role":                   loginRes.userRole,
+		constants.ClaimSessionID: sessionID,
 	})
 	if err != nil {
-		log.Logger().Error().Err(err).Msgf("User(%s) login failed due to jwt token genaration errors", req.Username)
-		loginRes.success = false
-		loginRes.statusCode = http.StatusInternalServerError
-		loginRes.errorMessage = "Opps! Token gernation failed. Please try again!"
-		return loginRes, err
+		log.Logger().Error().Err(err).Msgf("User(%s) login failed due to jwt token genaration errors",
+			userAccount.Username)
+		loginRes.fail(http.StatusInternalServerError, "Opps! Token gernation failed. Please try again!")
+		return err
 	}
 
 	// Write last accessed time to db
 	err = svc.store.Users().RecordLastAccessedTime(ctx, userAccount.Id, time.Now())
 	if err != nil {
-		log.Logger().Error().Err(err).Msgf("Unable to record user login(%s) time due to errors", req.Username)
-		loginRes.statusCode = http.StatusInternalServerError
-		loginRes.errorMessage = "Opps! Failed to persist data. Please try again!"
-		loginRes.success = false
-		return loginRes, err
+		log.Logger().Error().Err(err).Msgf("Unable to record user login(%s) time due to errors", userAccount.Username)
+		loginRes.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!")
+		return err
 	}
 
 	loginRes.success = true
+	loginRes.statusCode = http.StatusOK
 	loginRes.jwtToken = token
 	loginRes.expiryInSeconds = config.GetAuthTokenConfig().Expiry
+	loginRes.refreshToken = refreshToken
+	loginRes.refreshExpiryInSeconds = refreshExpiry
 	loginRes.userID = userAccount.Id
 	loginRes.username = userAccount.Username
 
-	return loginRes, nil
+	return nil
 }
 
-func (svc *authService) revokeToken(reqCtx context.Context, signatureHash, userID string, expAt, issuedAt int64) error {
+// refreshSession signs a new auth token for the session of the refresh token. Refresh tokens are rotated, so a
+// refresh token can be used only once.
+func (svc *authService) refreshSession(reqCtx context.Context, refreshToken string) (*authLoginResult, error) {
+	res := &authLoginResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("error occurred when starting transaction")
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when refreshing session!"), err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	refreshTokenHash := utils.CalcuateDigest([]byte(refreshToken))
+	session, err := svc.store.Auth().GetSessionByRefreshToken(ctx, refreshTokenHash)
+	if err != nil {
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when refreshing session!"), err
+	}
+	if session == nil {
+		return res.fail(http.StatusUnauthorized, "Session has expired or been revoked"), nil
+	}
+
+	userAccount, err := svc.store.Users().Get(ctx, session.UserID)
+	if err != nil {
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when refreshing session!"), err
+	}
+	if userAccount == nil || userAccount.Locked {
+		err = svc.store.Auth().DeleteSession(ctx, session.ID)
+		if err != nil {
+			return res.fail(http.StatusInternalServerError, "Opps! Error occured when refreshing session!"), err
+		}
+		return res.fail(http.StatusUnauthorized, "Session has expired or been revoked"), nil
+	}
+
+	roleName, err := svc.store.Users().GetRole(ctx, userAccount.Id)
+	if err != nil {
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when refreshing session!"), err
+	}
+
+	newRefreshToken, err := security.GenerateAccessToken(constants.RefreshTokenPrefix)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when generating refresh token")
+		return res.fail(http.StatusInternalServerError, "Opps! Token gernation failed. Please try again!"), err
+	}
+
+	rotated, err := svc.store.Auth().RotateRefreshToken(ctx, session.ID, refreshTokenHash,
+		utils.CalcuateDigest([]byte(newRefreshToken)))
+	if err != nil {
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when refreshing session!"), err
+	}
+	if !rotated {
+		// refresh token was used by a concurrent request
+		return res.fail(http.StatusUnauthorized, "Session has expired or been revoked"), nil
+	}
+
+	token, err := svc.jwtAuthenticator.Sign(map[string]any{
+		"sub":                    userAccount.Username,
+		"role":                   roleName,
+		constants.ClaimSessionID: session.ID,
+	})
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Session(%s) refresh failed due to jwt token genaration errors", session.ID)
+		return res.fail(http.StatusInternalServerError, "Opps! Token gernation failed. Please try again!"), err
+	}
+
+	res.success = true
+	res.statusCode = http.StatusOK
+	res.jwtToken = token
+	res.expiryInSeconds = config.GetAuthTokenConfig().Expiry
+	res.refreshToken = newRefreshToken
+	res.refreshExpiryInSeconds = int(time.Until(session.ExpiresAt).Seconds())
+	res.userRole = roleName
+	res.userID = userAccount.Id
+	res.username = userAccount.Username
+
+	return res, nil
+}
+
+// startOIDCLogin persists a new authorization request and returns the URL of the identity provider where the
+// browser is sent to log in. state identifies the authorization request in the callback.
+func (svc *authService) startOIDCLogin(reqCtx context.Context) (authURL, state string, statusCode int, err error) {
+	state, err = oidc.NewRandomString()
+	if err != nil {
+		return "", "", http.StatusInternalServerError, err
+	}
+	nonce, err := oidc.NewRandomString()
+	if err != nil {
+		return "", "", http.StatusInternalServerError, err
+	}
+	codeVerifier, err := oidc.NewRandomString()
+	if err != nil {
+		return "", "", http.StatusInternalServerError, err
+	}
+
+	authURL, err = svc.oidcClient.AuthCodeURL(reqCtx, state, nonce, codeVerifier)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Unable to build authorization url of identity provider")
+		return "", "", http.StatusBadGateway, err
+	}
+
+	err = svc.store.Auth().DeleteExpiredAuthorizationRequests(reqCtx)
+	if err != nil {
+		return "", "", http.StatusInternalServerError, err
+	}
+
+	err = svc.store.Auth().CreateAuthorizationRequest(reqCtx, &models.AuthorizationRequest{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+	}, constants.DefaultOIDCAuthRequestExpiry)
+	if err != nil {
+		return "", "", http.StatusInternalServerError, err
+	}
+
+	return authURL, state, http.StatusFound, nil
+}
+
+// completeOIDCLogin redeems the authorization code of the callback and starts a session, or the two-factor challenge
+// if the user needs a second factor. Accounts are created on the first login. Profile, role and mapped namespace access are synced from the ID token on every login.
+func (svc *authService) completeOIDCLogin(reqCtx context.Context, state, code, userAgent,
+	clientIp string) (*authLoginResult, error) {
+	res := &authLoginResult{}
+
+	authReq, err := svc.store.Auth().TakeAuthorizationRequest(reqCtx, state)
+	if err != nil {
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), err
+	}
+	if authReq == nil {
+		return res.fail(http.StatusUnauthorized, "Login request has expired. Please try again!"), nil
+	}
+
+	// Identity provider is called before starting the transaction, so the database is not locked meanwhile
+	claims, err := svc.oidcClient.Exchange(reqCtx, code, authReq.CodeVerifier, authReq.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrLoginRejected) {
+			log.Logger().Warn().Err(err).Msg("Single sign-on login was rejected")
+			return res.fail(http.StatusUnauthorized, "Login was rejected by identity provider"), nil
+		}
+		log.Logger().Error().Err(err).Msg("Single sign-on login failed due to identity provider errors")
+		return res.fail(http.StatusBadGateway, "Unable to reach identity provider. Please try again!"), err
+	}
+
+	oidcConfig := config.GetOIDCConfig()
+	username := claims.String(oidcConfig.UsernameClaim)
+	email := claims.String("email")
+	if username == "" || email == "" {
+		log.Logger().Warn().Msgf("ID token of subject(%s) doesn't have %s or email claims", claims.String("sub"),
+			oidcConfig.UsernameClaim)
+		return res.fail(http.StatusUnauthorized, "Identity provider didn't share username or email"), nil
+	}
+	displayName := claims.String("name")
+	if displayName == "" {
+		displayName = username
+	}
+
+	role := mapRole(claims.Strings(oidcConfig.RoleClaim), oidcConfig.RoleMapping, oidcConfig.DefaultRole)
+	if role == "" {
+		log.Logger().Warn().Msgf("Single sign-on user(%s) doesn't have a mapped role", username)
+		return res.fail(http.StatusForbidden, "You are not allowed to access the registry"), nil
+	}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("error occurred when starting transaction")
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	userAccount, err := svc.provisionExternalUser(ctx, res, constants.IdentityProviderOIDC, claims.String("sub"),
+		username, email, displayName, role)
+	if err != nil || userAccount == nil {
+		return res, err
+	}
+
+	err = svc.grantMappedAccess(ctx, userAccount.Id, claims.Strings(oidcConfig.GroupsClaim),
+		oidcConfig.NamespaceMapping)
+	if err != nil {
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), err
+	}
+
+	res.userRole = role
+
+	// the session is started after the second factor is verified by completeTwoFactorLogin
+	started, err := svc.startTwoFactorChallenge(ctx, res, userAccount, scopeHash(nil),
+		constants.GrantTypeAuthorizationCode)
+	if err != nil || started {
+		return res, err
+	}
+
+	err = svc.startSession(ctx, res, userAccount, scopeHash(nil), constants.GrantTypeAuthorizationCode, userAgent,
+		clientIp)
+	return res, err
+}
+
+// isDirectoryLogin returns true if the user logs in with LDAP. Users without a local account and accounts created
+// by LDAP are authenticated with the directory. Other accounts, such as the admin, keep their own passwords, so the
+// directory can't take them over.
+func (svc *authService) isDirectoryLogin(ctx context.Context, username string) (bool, error) {
+	userAccount, err := svc.store.Users().GetByUsername(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	if userAccount == nil {
+		return true, nil
+	}
+
+	identityProvider, err := svc.store.Users().GetIdentityProvider(ctx, userAccount.Id)
+	if err != nil {
+		return false, err
+	}
+	return identityProvider == constants.IdentityProviderLDAP, nil
+}
+
+// authenticateLDAPUser verifies the password with the directory and starts a session, or the two-factor challenge if
+// the user needs a second factor. Accounts are created on the first login. Profile and role are synced from the directory on every login. Failed attempts are not counted since
+// the directory enforces its own lockout policy.
+func (svc *authService) authenticateLDAPUser(reqCtx context.Context, req *mgmt.AuthLoginRequest, userAgent,
+	clientIp string) (*authLoginResult, error) {
+	res := &authLoginResult{}
+
+	// Directory is called before starting the transaction, so the database is not locked meanwhile
+	user, err := svc.ldapClient.Authenticate(req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, ldap.ErrInvalidCredentials) {
+			log.Logger().Warn().Err(err).Msgf("LDAP login of user(%s) failed", req.Username)
+			return res.fail(http.StatusUnauthorized, "Invalid username or password!"), nil
+		}
+		log.Logger().Error().Err(err).Msg("LDAP login failed due to directory errors")
+		return res.fail(http.StatusBadGateway, "Unable to reach directory server. Please try again!"), err
+	}
+
+	if user.Username == "" || user.Email == "" {
+		log.Logger().Warn().Msgf("LDAP entry(%s) doesn't have username or email", user.DN)
+		return res.fail(http.StatusUnauthorized, "Directory didn't share username or email"), nil
+	}
+	displayName := user.DisplayName
+	if displayName == "" {
+		displayName = user.Username
+	}
+
+	ldapConfig := config.GetLDAPConfig()
+	role := mapRole(user.Groups, ldapConfig.RoleMapping, ldapConfig.DefaultRole)
+	if role == "" {
+		log.Logger().Warn().Msgf("LDAP user(%s) doesn't have a mapped role", user.Username)
+		return res.fail(http.StatusForbidden, "You are not allowed to access the registry"), nil
+	}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("error occurred when starting transaction")
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	userAccount, err := svc.provisionExternalUser(ctx, res, constants.IdentityProviderLDAP, user.ID, user.Username,
+		user.Email, displayName, role)
+	if err != nil || userAccount == nil {
+		return res, err
+	}
+
+	res.userRole = role
+
+	// the session is started after the second factor is verified by completeTwoFactorLogin
+	started, err := svc.startTwoFactorChallenge(ctx, res, userAccount, scopeHash(req.Scopes),
+		constants.GrantTypePassword)
+	if err != nil || started {
+		return res, err
+	}
+
+	err = svc.startSession(ctx, res, userAccount, scopeHash(req.Scopes), constants.GrantTypePassword, userAgent,
+		clientIp)
+	return res, err
+}
+
+// provisionExternalUser returns the account linked to the subject of the identity provider after syncing its profile
+// and role. The account is created if the subject logs in for the first time. It returns a nil account if login is
+// not allowed.
+func (svc *authService) provisionExternalUser(ctx context.Context, res *authLoginResult, provider, subject, username,
+	email, displayName, role string) (*models.UserAccount, error) {
+	userID, err := svc.store.Users().GetIDByIdentity(ctx, provider, subject)
+	if err != nil {
+		res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!")
+		return nil, err
+	}
+
+	if userID == "" {
+		// Existing local accounts are not linked since the identity provider can't prove the ownership of them
+		usernameAvail, emailAvail, err := svc.store.Users().CheckAvailability(ctx, username, email)
+		if err != nil {
+			res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!")
+			return nil, err
+		}
+		if !usernameAvail || !emailAvail {
+			log.Logger().Warn().Msgf("User(%s) of identity provider(%s) conflicts with an existing account",
+				username, provider)
+			res.fail(http.StatusConflict, "An account with the same username or email already exists")
+			return nil, nil
+		}
+
+		userID, err = svc.store.Users().CreateExternal(ctx, username, email, displayName)
+		if err != nil {
+			res.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!")
+			return nil, err
+		}
+		err = svc.store.Users().LinkIdentity(ctx, userID, provider, subject)
+		if err != nil {
+			res.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!")
+			return nil, err
+		}
+		err = svc.store.Users().AssignRole(ctx, userID, role)
+		if err != nil {
+			res.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!")
+			return nil, err
+		}
+
+		log.Logger().Info().Msgf("Created account(%s) of identity provider(%s) with role: %s", username, provider,
+			role)
+
+		userAccount, err := svc.store.Users().Get(ctx, userID)
+		if err != nil {
+			res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!")
+			return nil, err
+		}
+		return userAccount, nil
+	}
+
+	userAccount, err := svc.store.Users().Get(ctx, userID)
+	if err != nil {
+		res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!")
+		return nil, err
+	}
+	if userAccount.Locked {
+		res.fail(http.StatusForbidden, "User account has been locked! Contact system administrator.")
+		return nil, nil
+	}
+
+	// Profile is owned by the identity provider. Username is kept as it is since it identifies the account in
+	// resource access.
+	if email != userAccount.Email {
+		_, emailAvail, err := svc.store.Users().CheckAvailability(ctx, "", email)
+		if err != nil {
+			res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!")
+			return nil, err
+		}
+		if emailAvail {
+			err = svc.store.Users().UpdateEmail(ctx, userID, email)
+			if err != nil {
+				res.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!")
+				return nil, err
+			}
+			userAccount.Email = email
+		} else {
+			log.Logger().Warn().Msgf("Email of user(%s) of identity provider(%s) is not synced since it is in use",
+				userAccount.Username, provider)
+		}
+	}
+	if displayName != userAccount.DisplayName {
+		err = svc.store.Users().UpdateDisplayName(ctx, userID, displayName)
+		if err != nil {
+			res.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!")
+			return nil, err
+		}
+		userAccount.DisplayName = displayName
+	}
+
+	currentRole, err := svc.store.Users().GetRole(ctx, userID)
+	if err != nil {
+		res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!")
+		return nil, err
+	}
+	if currentRole != role {
+		revoked, err := svc.accessManager.RevokeAccessNotAllowedForRole(ctx, userID, role)
+		if err != nil {
+			res.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!")
+			return nil, err
+		}
+		err = svc.store.Users().UnAssignRole(ctx, userID)
+		if err != nil {
+			res.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!")
+			return nil, err
+		}
+		err = svc.store.Users().AssignRole(ctx, userID, role)
+		if err != nil {
+			res.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!")
+			return nil, err
+		}
+
+		log.Logger().Info().Msgf("Role of user(%s) of identity provider(%s) is changed from %s to %s. "+
+			"Revoked %d resource access", userAccount.Username, provider, currentRole, role, revoked)
+	}
+
+	return userAccount, nil
+}
+
+// grantMappedAccess grants access to namespaces of hosted registry mapped from the groups of the user. Namespaces
+// which don't exist and access the user already has are skipped.
+func (svc *authService) grantMappedAccess(ctx context.Context, userID string, groups []string,
+	namespaceMapping map[string][]config.OIDCNamespaceAccess) error {
+	for _, group := range groups {
+		for _, mapped := range namespaceMapping[group] {
+			nsID, err := svc.store.Namespaces().GetID(ctx, constants.HostedRegistryID, mapped.Namespace)
+			if err != nil {
+				return err
+			}
+			if nsID == "" {
+				log.Logger().Warn().Msgf("Namespace(%s) mapped to group(%s) doesn't exist", mapped.Namespace, group)
+				continue
+			}
+
+			reason, err := svc.accessManager.GrantMappedAccess(ctx, nsID, constants.ResourceTypeNamespace, userID,
+				mapped.AccessLevel)
+			if err != nil {
+				return err
+			}
+			if reason != access.Success && reason != access.HasSameAccessAlready && reason != access.Conflict {
+				log.Logger().Warn().Msgf("Access to namespace(%s) mapped to group(%s) is not granted: %s",
+					mapped.Namespace, group, reason)
+			}
+		}
+	}
+	return nil
+}
+
+// mappableRoles are roles which can be mapped from identity providers, highest first
+var mappableRoles = []string{constants.RoleAdmin, constants.RoleMaintainer, constants.RoleDeveloper,
+	constants.RoleGuest}
+
+// mapRole returns the highest role mapped from the values, e.g. groups of the user, or the default role if none is
+// mapped
+func mapRole(values []string, roleMapping map[string]string, defaultRole string) string {
+	role := defaultRole
+	rank := len(mappableRoles)
+
+	for _, value := range values {
+		i := slices.Index(mappableRoles, roleMapping[value])
+		if i >= 0 && i < rank {
+			role, rank = mappableRoles[i], i
+		}
+	}
+
+	return role
+}
+
+func (res *authLoginResult) fail(statusCode int, errMsg string) *authLoginResult {
+	res.success = false
+	res.statusCode = statusCode
+	res.errorMessage = errMsg
+	return res
+}
+
+// scopeHash identifies the requested scopes of a session regardless of their order
+func scopeHash(scopes []string) string {
+	sorted := slices.Clone(scopes)
+	slices.Sort(sorted)
+	return utils.CalcuateDigest([]byte(strings.Join(sorted, ",")))
+}
+
+// revokeToken revokes the auth token and deletes its session if the token belongs to one
+func (svc *authService) revokeToken(reqCtx context.Context, signatureHash, sessionID, userID string, expAt,
+	issuedAt int64) error {
+	err := svc.recordRevocation(reqCtx, signatureHash, sessionID, userID, expAt, issuedAt)
+	if err != nil {
+		return err
+	}
+
+	// this instance rejects the token right away. Other instances apply it when they reload revoked tokens.
+	svc.revocations.Add(signatureHash, expAt)
+	return nil
+}
+
+// recordRevocation persists the revocation of the auth token and deletes its session in a transaction
+func (svc *authService) recordRevocation(reqCtx context.Context, signatureHash, sessionID, userID string, expAt,
+	issuedAt int64) (err error) {
 
 	tx, err := svc.store.Begin(reqCtx)
 	if err != nil {
@@ -189,12 +792,20 @@ func (svc *authService) revokeToken(reqCtx context.Context, signatureHash, userI
 		if err != nil {
 			tx.Rollback()
 		} else {
-			tx.Commit()
+			err = tx.Commit()
 		}
 	}()
 
 	ctx := store.WithTxContext(reqCtx, tx)
 
+	if sessionID != "" {
+		err = svc.store.Auth().DeleteSession(ctx, sessionID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to delete session due to errors")
+			return err
+		}
+	}
+
 	// Check the database first
 	revokedToken, err := svc.store.Auth().GetRevokedToken(ctx, signatureHash)
 	if err != nil {
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"github.com/ksankeerth/open-image-registry/auth/keys"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/lib"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+func toSigningKeyDTO(keyManager *keys.Manager, key *models.SigningKey) *mgmt.SigningKeyDTO {
+	status := constants.SigningKeyStatusRetired
+	if key.Active {
+		status = constants.SigningKeyStatusActive
+	}
+
+	return &mgmt.SigningKeyDTO{
+		KeyID:     key.KeyID,
+		Algorithm: key.Algorithm,
+		Status:    status,
+		CreatedAt: key.CreatedAt,
+		RetiredAt: key.RetiredAt,
+		ExpiresAt: keyManager.ExpiresAt(key),
+	}
+}
+
+// toJWKSet returns the public keys which verify auth tokens, so other services can verify them
+func toJWKSet(algorithm string, publicKeys []*lib.JWTKey) (*mgmt.JWKSet, error) {
+	jwks := &mgmt.JWKSet{Keys: make([]mgmt.JWK, 0, len(publicKeys))}
+	for _, key := range publicKeys {
+		members, err := lib.PublicJWK(key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		jwks.Keys = append(jwks.Keys, mgmt.JWK{
+			KeyType:   members["kty"],
+			Use:       "sig",
+			Algorithm: algorithm,
+			KeyID:     key.ID,
+			Curve:     members["crv"],
+			X:         members["x"],
+			Y:         members["y"],
+			N:         members["n"],
+			E:         members["e"],
+		})
+	}
+	return jwks, nil
+}
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/security"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/user"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+// startTwoFactorChallenge starts the second step of the login if the user enabled two-factor authentication or the
+// role of the user requires it. It is called by every interactive login, whether the first factor is a password,
+// LDAP or OIDC. started is false if the session can be started right away. userRole of loginRes must be set.
+func (svc *authService) startTwoFactorChallenge(ctx context.Context, loginRes *authLoginResult,
+	userAccount *models.UserAccount, sessionScopeHash, grantType string) (started bool, err error) {
+	enrollment, err := svc.store.TwoFactor().GetEnrollment(ctx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve two-factor enrollment of user(%s)", userAccount.Username)
+		loginRes.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!")
+		return false, err
+	}
+	enabled := enrollment != nil && enrollment.Enabled
+
+	required, err := svc.store.TwoFactor().IsRequiredForRole(ctx, loginRes.userRole)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve two-factor policy of role(%s)", loginRes.userRole)
+		loginRes.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!")
+		return false, err
+	}
+
+	if !enabled && !required {
+		return false, nil
+	}
+
+	token, err := security.GenerateAccessToken(constants.TwoFactorTokenPrefix)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when generating two-factor login token")
+		loginRes.fail(http.StatusInternalServerError, "Opps! Token gernation failed. Please try again!")
+		return false, err
+	}
+
+	err = svc.store.TwoFactor().DeleteExpiredChallenges(ctx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to delete expired two-factor logins")
+		loginRes.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!")
+		return false, err
+	}
+
+	err = svc.store.TwoFactor().CreateChallenge(ctx, &models.TwoFactorChallenge{
+		TokenHash: utils.CalcuateDigest([]byte(token)),
+		UserID:    userAccount.Id,
+		ScopeHash: sessionScopeHash,
+		GrantType: grantType,
+	}, constants.TwoFactorChallengeExpiry)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to persist two-factor login of user(%s)", userAccount.Username)
+		loginRes.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!")
+		return false, err
+	}
+
+	loginRes.success = true
+	loginRes.statusCode = http.StatusOK
+	loginRes.twoFactorToken = token
+	loginRes.twoFactorEnrollmentRequired = !enabled
+
+	return true, nil
+}
+
+// completeTwoFactorLogin starts the session of the login if the code is valid. If the role requires two-factor
+// authentication and the user enrolled during the login, the enrollment is enabled by the first valid code. Wrong
+// codes count towards the lockout of the account like wrong passwords.
+func (svc *authService) completeTwoFactorLogin(reqCtx context.Context, req *mgmt.TwoFactorLoginRequest, userAgent,
+	clientIp string) (*authLoginResult, error) {
+	res := &authLoginResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("error occurred when starting transaction")
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	challenge, userAccount, err := svc.takeTwoFactorChallenge(ctx, res, req.Token)
+	if err != nil || userAccount == nil {
+		return res, err
+	}
+
+	enrollment, err := svc.store.TwoFactor().GetEnrollment(ctx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve two-factor enrollment of user(%s)", userAccount.Username)
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), err
+	}
+	if enrollment == nil {
+		return res.fail(http.StatusBadRequest, "Enroll two-factor authentication to complete the login"), nil
+	}
+
+	verified, err := user.VerifySecondFactor(ctx, svc.store, enrollment, req.Code, req.RecoveryCode)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to verify second factor of user(%s)", userAccount.Username)
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), err
+	}
+	if !verified {
+		var locked bool
+		locked, err = user.RecordFailedSecondFactor(ctx, svc.store, userAccount)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Failed to record failed login of user(%s)", userAccount.Username)
+			return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), err
+		}
+		if locked {
+			err = svc.store.TwoFactor().DeleteChallenge(ctx, challenge.TokenHash)
+			if err != nil {
+				return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), err
+			}
+			return res.fail(http.StatusForbidden, "User account has been locked! Contact system administrator."), nil
+		}
+		return res.fail(http.StatusUnauthorized, "Invalid verification code!"), nil
+	}
+
+	err = svc.store.TwoFactor().DeleteChallenge(ctx, challenge.TokenHash)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to delete two-factor login of user(%s)", userAccount.Username)
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), err
+	}
+
+	if !enrollment.Enabled {
+		res.recoveryCodes, err = user.EnableTwoFactor(ctx, svc.store, userAccount.Id)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Failed to enable two-factor authentication of user(%s)",
+				userAccount.Username)
+			return res.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!"), err
+		}
+	}
+
+	res.userRole, err = svc.store.Users().GetRole(ctx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve role of user(%s)", userAccount.Username)
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), err
+	}
+
+	err = svc.startSession(ctx, res, userAccount, challenge.ScopeHash, challenge.GrantType, userAgent, clientIp)
+	return res, err
+}
+
+// enrollTwoFactorLogin starts enrollment of a user who has to enroll before completing the login, because the role
+// of the user requires two-factor authentication
+func (svc *authService) enrollTwoFactorLogin(reqCtx context.Context, token string) (
+	res *authLoginResult, enrollment *mgmt.TwoFactorEnrollmentResponse, err error) {
+	res = &authLoginResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("error occurred when starting transaction")
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	_, userAccount, err := svc.takeTwoFactorChallenge(ctx, res, token)
+	if err != nil || userAccount == nil {
+		return res, nil, err
+	}
+
+	current, err := svc.store.TwoFactor().GetEnrollment(ctx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve two-factor enrollment of user(%s)", userAccount.Username)
+		return res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!"), nil, err
+	}
+	if current != nil && current.Enabled {
+		return res.fail(http.StatusConflict, "Two-factor authentication is already enabled"), nil, nil
+	}
+
+	enrollment, err = user.StartTwoFactorEnrollment(ctx, svc.store, userAccount)
+	if err != nil {
+		return res.fail(http.StatusInternalServerError, "Opps! Failed to persist data. Please try again!"), nil, err
+	}
+
+	res.success = true
+	res.statusCode = http.StatusCreated
+	return res, enrollment, nil
+}
+
+// takeTwoFactorChallenge returns the login of the token and its account. The account is nil if the login is not
+// valid anymore, and the failure is set in res.
+func (svc *authService) takeTwoFactorChallenge(ctx context.Context, res *authLoginResult, token string) (
+	*models.TwoFactorChallenge, *models.UserAccount, error) {
+	challenge, err := svc.store.TwoFactor().GetChallenge(ctx, utils.CalcuateDigest([]byte(token)))
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to retrieve two-factor login")
+		res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!")
+		return nil, nil, err
+	}
+	if challenge == nil {
+		res.fail(http.StatusUnauthorized, "Login request has expired. Please log in again!")
+		return nil, nil, nil
+	}
+
+	userAccount, err := svc.store.Users().Get(ctx, challenge.UserID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve user account(%s)", challenge.UserID)
+		res.fail(http.StatusInternalServerError, "Opps! Error occured when logging in!")
+		return nil, nil, err
+	}
+	if userAccount == nil {
+		res.fail(http.StatusUnauthorized, "Login request has expired. Please log in again!")
+		return nil, nil, nil
+	}
+
+	// the account may be locked by an admin or failed attempts after the password was verified
+	if userAccount.Locked {
+		res.fail(http.StatusForbidden, "User account has been locked! Contact system administrator.")
+		return nil, nil, nil
+	}
+
+	return challenge, userAccount, nil
+}
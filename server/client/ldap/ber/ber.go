@@ -0,0 +1,236 @@
+// Package ber encodes and decodes the subset of ASN.1 BER used by the LDAP protocol (RFC 4511 section 5.1):
+// definite lengths and single byte tags.
+package ber
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Classes of tags
+const (
+	ClassUniversal   byte = 0x00
+	ClassApplication byte = 0x40
+	ClassContext     byte = 0x80
+)
+
+// Universal tags used by LDAP
+const (
+	TagBoolean     = 1
+	TagInteger     = 2
+	TagOctetString = 4
+	TagEnumerated  = 10
+	TagSequence    = 16
+	TagSet         = 17
+)
+
+const (
+	constructedBit = 0x20
+	classMask      = 0xc0
+	tagMask        = 0x1f
+)
+
+// MaxPacketSize limits the size of a packet read from the network. LDAP messages of a login are a few kilobytes.
+const MaxPacketSize = 4 << 20
+
+var ErrPacketTooLarge = errors.New("ber packet exceeds maximum size")
+
+// Packet is a BER element. Primitive elements have Data and constructed elements have Children.
+type Packet struct {
+	Class       byte
+	Constructed bool
+	Tag         int
+	Data        []byte
+	Children    []*Packet
+}
+
+func NewSequence(children ...*Packet) *Packet {
+	return NewConstructed(ClassUniversal, TagSequence, children...)
+}
+
+func NewConstructed(class byte, tag int, children ...*Packet) *Packet {
+	return &Packet{Class: class, Constructed: true, Tag: tag, Children: children}
+}
+
+func NewString(class byte, tag int, value string) *Packet {
+	return &Packet{Class: class, Tag: tag, Data: []byte(value)}
+}
+
+func NewBoolean(value bool) *Packet {
+	p := &Packet{Class: ClassUniversal, Tag: TagBoolean, Data: []byte{0x00}}
+	if value {
+		p.Data[0] = 0xff
+	}
+	return p
+}
+
+// NewInteger encodes value as INTEGER or ENUMERATED in two's complement with minimum number of bytes
+func NewInteger(class byte, tag int, value int64) *Packet {
+	data := []byte{byte(value)}
+	for v := value >> 8; ; v >>= 8 {
+		last := data[0]
+		if (v == 0 && last&0x80 == 0) || (v == -1 && last&0x80 != 0) {
+			break
+		}
+		data = append([]byte{byte(v)}, data...)
+	}
+	return &Packet{Class: class, Tag: tag, Data: data}
+}
+
+func (p *Packet) Is(class byte, tag int) bool {
+	return p.Class == class && p.Tag == tag
+}
+
+func (p *Packet) String() string {
+	return string(p.Data)
+}
+
+// Int decodes the INTEGER or ENUMERATED value of the packet
+func (p *Packet) Int() (int64, error) {
+	if p.Constructed || len(p.Data) == 0 || len(p.Data) > 8 {
+		return 0, fmt.Errorf("invalid ber integer of %d bytes", len(p.Data))
+	}
+	var v int64
+	if p.Data[0]&0x80 != 0 {
+		v = -1
+	}
+	for _, b := range p.Data {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+func (p *Packet) Bool() bool {
+	return len(p.Data) == 1 && p.Data[0] != 0
+}
+
+func (p *Packet) AppendChild(child *Packet) {
+	p.Children = append(p.Children, child)
+}
+
+// Bytes returns the BER encoding of the packet
+func (p *Packet) Bytes() []byte {
+	content := p.Data
+	if p.Constructed {
+		content = nil
+		for _, child := range p.Children {
+			content = append(content, child.Bytes()...)
+		}
+	}
+
+	identifier := p.Class | byte(p.Tag)
+	if p.Constructed {
+		identifier |= constructedBit
+	}
+
+	out := append([]byte{identifier}, encodeLength(len(content))...)
+	return append(out, content...)
+}
+
+func encodeLength(length int) []byte {
+	if length < 0x80 {
+		return []byte{byte(length)}
+	}
+	var octets []byte
+	for l := length; l > 0; l >>= 8 {
+		octets = append([]byte{byte(l)}, octets...)
+	}
+	return append([]byte{0x80 | byte(len(octets))}, octets...)
+}
+
+// ReadPacket reads one packet from r. Indefinite lengths and multi byte tags aren't used by LDAP and are rejected.
+func ReadPacket(r io.Reader) (*Packet, error) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if header[0]&tagMask == tagMask {
+		return nil, fmt.Errorf("multi byte ber tags are not supported")
+	}
+
+	length := int(header[1])
+	if header[1]&0x80 != 0 {
+		n := int(header[1] & 0x7f)
+		if n == 0 || n > 4 {
+			return nil, fmt.Errorf("unsupported ber length of %d bytes", n)
+		}
+		octets := make([]byte, n)
+		_, err = io.ReadFull(r, octets)
+		if err != nil {
+			return nil, err
+		}
+		length = 0
+		for _, b := range octets {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > MaxPacketSize {
+		return nil, ErrPacketTooLarge
+	}
+
+	content := make([]byte, length)
+	_, err = io.ReadFull(r, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return parse(header[0], content, 0)
+}
+
+// maxDepth bounds recursion of nested packets
+const maxDepth = 32
+
+func parse(identifier byte, content []byte, depth int) (*Packet, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("ber packet is nested too deeply")
+	}
+
+	p := &Packet{
+		Class:       identifier & classMask,
+		Constructed: identifier&constructedBit != 0,
+		Tag:         int(identifier & tagMask),
+	}
+	if !p.Constructed {
+		p.Data = content
+		return p, nil
+	}
+
+	for len(content) > 0 {
+		if len(content) < 2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		childIdentifier := content[0]
+		if childIdentifier&tagMask == tagMask {
+			return nil, fmt.Errorf("multi byte ber tags are not supported")
+		}
+
+		offset := 2
+		length := int(content[1])
+		if content[1]&0x80 != 0 {
+			n := int(content[1] & 0x7f)
+			if n == 0 || n > 4 || len(content) < 2+n {
+				return nil, fmt.Errorf("invalid ber length")
+			}
+			length = 0
+			for _, b := range content[2 : 2+n] {
+				length = length<<8 | int(b)
+			}
+			offset += n
+		}
+		if length < 0 || length > math.MaxInt32 || len(content)-offset < length {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		child, err := parse(childIdentifier, content[offset:offset+length], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		p.Children = append(p.Children, child)
+		content = content[offset+length:]
+	}
+	return p, nil
+}
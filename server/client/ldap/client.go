@@ -0,0 +1,215 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/config"
+)
+
+// ErrInvalidCredentials is returned when the user is not found in the directory or the password is wrong. Other
+// errors are failures to reach or search the directory.
+var ErrInvalidCredentials = errors.New("invalid ldap credentials")
+
+// Client authenticates users with an LDAP or Active Directory server. A new connection is used for each login.
+type Client struct {
+	cfg       *config.LDAPConfig
+	tlsConfig *tls.Config
+}
+
+// User is the directory entry of an authenticated user
+type User struct {
+	// ID identifies the user in the directory. It is the value of the id attribute, or DN of the entry.
+	ID          string
+	DN          string
+	Username    string
+	Email       string
+	DisplayName string
+	Groups      []string
+}
+
+func NewClient(cfg *config.LDAPConfig) (*Client, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ldap url: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	// system roots are used if CA file is not set
+	if cfg.CAFile != "" {
+		caCerts, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca file failed: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCerts) {
+			return nil, fmt.Errorf("ca file doesn't have PEM encoded certificates")
+		}
+	}
+
+	return &Client{
+		cfg:       cfg,
+		tlsConfig: tlsConfig,
+	}, nil
+}
+
+// Authenticate searches the user with the service account and verifies the password by binding as the user
+func (c *Client) Authenticate(username, password string) (*User, error) {
+	// Most servers treat a bind without password as an anonymous bind which always succeeds
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = c.bindServiceAccount(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := c.searchUser(conn, username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.bind(entry.DN, password)
+	if err != nil {
+		if isResultCode(err, resultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap bind of user failed: %w", err)
+	}
+
+	user := &User{
+		ID:          entry.DN,
+		DN:          entry.DN,
+		Username:    entry.value(c.cfg.UsernameAttribute),
+		Email:       entry.value(c.cfg.EmailAttribute),
+		DisplayName: entry.value(c.cfg.DisplayNameAttribute),
+	}
+	if c.cfg.IDAttribute != "" {
+		user.ID = attributeID(entry.rawValue(c.cfg.IDAttribute))
+		if user.ID == "" {
+			return nil, fmt.Errorf("ldap entry of user(%s) doesn't have %s", entry.DN, c.cfg.IDAttribute)
+		}
+	}
+
+	// Users may not be allowed to read groups, so groups are searched with the service account
+	err = c.bindServiceAccount(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Groups, err = c.searchGroups(conn, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (c *Client) connect() (*conn, error) {
+	conn, err := dial(c.cfg.URL, c.tlsConfig, time.Duration(c.cfg.TimeoutSeconds)*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to ldap server failed: %w", err)
+	}
+
+	if c.cfg.StartTLS {
+		err = conn.startTLS(c.tlsConfig)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap start tls failed: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// bindServiceAccount binds with the service account, or anonymously if bind dn is not set
+func (c *Client) bindServiceAccount(conn *conn) error {
+	err := conn.bind(c.cfg.BindDN, c.cfg.BindPassword)
+	if err != nil {
+		return fmt.Errorf("ldap bind of service account failed: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) searchUser(conn *conn, username string) (*entry, error) {
+	attributes := []string{c.cfg.UsernameAttribute, c.cfg.EmailAttribute, c.cfg.DisplayNameAttribute}
+	if c.cfg.IDAttribute != "" {
+		attributes = append(attributes, c.cfg.IDAttribute)
+	}
+	if c.cfg.GroupMembershipAttribute != "" {
+		attributes = append(attributes, c.cfg.GroupMembershipAttribute)
+	}
+
+	// size limit of 2 is enough to detect filters matching several users
+	entries, err := conn.search(c.cfg.BaseDN, fmt.Sprintf(c.cfg.UserFilter, EscapeFilter(username)), attributes, 2)
+	if err != nil {
+		if isResultCode(err, resultSizeLimitExceeded) {
+			return nil, fmt.Errorf("%w: user filter matches several entries for %s", ErrInvalidCredentials, username)
+		}
+		return nil, fmt.Errorf("ldap user search failed: %w", err)
+	}
+
+	switch {
+	case len(entries) == 0:
+		return nil, ErrInvalidCredentials
+	case len(entries) > 1:
+		return nil, fmt.Errorf("%w: user filter matches several entries for %s", ErrInvalidCredentials, username)
+	}
+
+	return entries[0], nil
+}
+
+// searchGroups returns names of groups of the user from the group search and the membership attribute
+func (c *Client) searchGroups(conn *conn, user *entry) ([]string, error) {
+	var groups []string
+
+	if c.cfg.GroupMembershipAttribute != "" {
+		for _, groupDN := range user.values(c.cfg.GroupMembershipAttribute) {
+			if name := groupName(groupDN); name != "" {
+				groups = append(groups, name)
+			}
+		}
+	}
+
+	if c.cfg.GroupFilter != "" {
+		entries, err := conn.search(c.cfg.GroupBaseDN, fmt.Sprintf(c.cfg.GroupFilter, EscapeFilter(user.DN)),
+			[]string{c.cfg.GroupNameAttribute}, 0)
+		if err != nil {
+			return nil, fmt.Errorf("ldap group search failed: %w", err)
+		}
+		for _, group := range entries {
+			groups = append(groups, group.values(c.cfg.GroupNameAttribute)...)
+		}
+	}
+
+	return groups, nil
+}
+
+// attributeID returns the id attribute as text. Binary ids such as objectGUID of Active Directory are base64 encoded.
+func attributeID(value []byte) string {
+	for _, b := range value {
+		if b < 0x20 || b > 0x7e {
+			return base64.StdEncoding.EncodeToString(value)
+		}
+	}
+	return strings.TrimSpace(string(value))
+}
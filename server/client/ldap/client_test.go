@@ -0,0 +1,174 @@
+package ldap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, ldaps bool) (*helpers.MockLDAPServer, string) {
+	t.Helper()
+
+	server, err := helpers.NewMockLDAPServer(ldaps)
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	server.AddEntry(&helpers.LDAPEntry{
+		DN:         "cn=registry,dc=example,dc=com",
+		Password:   "service-secret",
+		Attributes: map[string][]string{"cn": {"registry"}},
+	})
+	server.AddEntry(&helpers.LDAPEntry{
+		DN:       "cn=Alice,ou=people,dc=example,dc=com",
+		Password: "alice-secret",
+		Attributes: map[string][]string{
+			"objectClass":    {"user"},
+			"sAMAccountName": {"alice"},
+			"mail":           {"alice@example.com"},
+			"displayName":    {"Alice"},
+			"memberOf":       {"CN=Registry Admins,OU=Groups,DC=example,DC=com"},
+		},
+	})
+	for _, name := range []string{"twin1", "twin2"} {
+		server.AddEntry(&helpers.LDAPEntry{
+			DN:       "cn=" + name + ",ou=people,dc=example,dc=com",
+			Password: "twin-secret",
+			Attributes: map[string][]string{
+				"objectClass":    {"user"},
+				"sAMAccountName": {"twin"},
+				"mail":           {name + "@example.com"},
+			},
+		})
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, server.CACertPEM(), 0600))
+
+	return server, caFile
+}
+
+func testConfig(url, caFile string) *config.LDAPConfig {
+	return &config.LDAPConfig{
+		URL:                      url,
+		CAFile:                   caFile,
+		TimeoutSeconds:           5,
+		BindDN:                   "cn=registry,dc=example,dc=com",
+		BindPassword:             "service-secret",
+		BaseDN:                   "ou=people,dc=example,dc=com",
+		UserFilter:               "(&(objectClass=user)(sAMAccountName=%s))",
+		UsernameAttribute:        "sAMAccountName",
+		EmailAttribute:           "mail",
+		DisplayNameAttribute:     "displayName",
+		GroupMembershipAttribute: "memberOf",
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	ldapsServer, ldapsCA := newTestServer(t, true)
+	startTLSServer, startTLSCA := newTestServer(t, false)
+
+	startTLSConfig := testConfig(startTLSServer.URL(), startTLSCA)
+	startTLSConfig.StartTLS = true
+
+	clients := map[string]*config.LDAPConfig{
+		"LDAPS":    testConfig(ldapsServer.URL(), ldapsCA),
+		"StartTLS": startTLSConfig,
+	}
+
+	for name, cfg := range clients {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(cfg)
+			require.NoError(t, err)
+
+			user, err := client.Authenticate("alice", "alice-secret")
+			require.NoError(t, err)
+			assert.Equal(t, "cn=Alice,ou=people,dc=example,dc=com", user.ID)
+			assert.Equal(t, "alice", user.Username)
+			assert.Equal(t, "alice@example.com", user.Email)
+			assert.Equal(t, "Alice", user.DisplayName)
+			assert.Equal(t, []string{"Registry Admins"}, user.Groups)
+
+			_, err = client.Authenticate("alice", "wrong")
+			assert.True(t, errors.Is(err, ErrInvalidCredentials))
+
+			_, err = client.Authenticate("alice", "")
+			assert.True(t, errors.Is(err, ErrInvalidCredentials))
+
+			_, err = client.Authenticate("bob", "alice-secret")
+			assert.True(t, errors.Is(err, ErrInvalidCredentials))
+
+			// filters matching several users are rejected
+			_, err = client.Authenticate("twin", "twin-secret")
+			assert.True(t, errors.Is(err, ErrInvalidCredentials))
+		})
+	}
+
+	t.Run("Untrusted certificate", func(t *testing.T) {
+		client, err := NewClient(testConfig(ldapsServer.URL(), startTLSCA))
+		require.NoError(t, err)
+
+		_, err = client.Authenticate("alice", "alice-secret")
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, ErrInvalidCredentials))
+	})
+
+	t.Run("Wrong service account password", func(t *testing.T) {
+		cfg := testConfig(ldapsServer.URL(), ldapsCA)
+		cfg.BindPassword = "wrong"
+		client, err := NewClient(cfg)
+		require.NoError(t, err)
+
+		_, err = client.Authenticate("alice", "alice-secret")
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, ErrInvalidCredentials))
+	})
+}
+
+func TestAttributeID(t *testing.T) {
+	assert.Equal(t, "6f1a3c52-7e9c-4f1e-9d1b-2b8c6c7a4e10", attributeID([]byte("6f1a3c52-7e9c-4f1e-9d1b-2b8c6c7a4e10")))
+	// binary objectGUID
+	assert.Equal(t, "AAEC/w==", attributeID([]byte{0x00, 0x01, 0x02, 0xff}))
+}
+
+func TestCompileFilter(t *testing.T) {
+	packet, err := compileFilter("(cn=a)")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xa3, 0x07, 0x04, 0x02, 'c', 'n', 0x04, 0x01, 'a'}, packet.Bytes())
+
+	valid := []string{
+		"(&(objectClass=user)(sAMAccountName=alice))",
+		"(|(uid=alice)(!(mail=*)))",
+		"(cn=Al*c*e)",
+		"(uidNumber>=1000)",
+		"(memberOf:1.2.840.113556.1.4.1941:=cn=admins,dc=example,dc=com)",
+		"(cn:dn:=admins)",
+		"(uid=" + EscapeFilter("a*)(uid=*") + ")",
+	}
+	for _, filter := range valid {
+		_, err := compileFilter(filter)
+		assert.NoError(t, err, filter)
+	}
+
+	invalid := []string{"", "uid=alice", "(uid=alice", "(uid=alice))", "(=alice)", "(uid=\\zz)", "(u d=alice)"}
+	for _, filter := range invalid {
+		_, err := compileFilter(filter)
+		assert.Error(t, err, filter)
+	}
+}
+
+func TestEscapeFilter(t *testing.T) {
+	assert.Equal(t, `\2a\29\28uid=\5c\00`, EscapeFilter("*)(uid=\\\x00"))
+}
+
+func TestGroupName(t *testing.T) {
+	assert.Equal(t, "Registry Admins", groupName("CN=Registry Admins,OU=Groups,DC=example,DC=com"))
+	assert.Equal(t, "Smith, John", groupName(`cn=Smith\, John,ou=groups,dc=org`))
+	assert.Equal(t, "a=b", groupName(`cn=a\3db+uid=1,dc=org`))
+	assert.Equal(t, "", groupName("invalid"))
+}
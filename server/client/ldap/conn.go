@@ -0,0 +1,310 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/client/ldap/ber"
+)
+
+// LDAP protocol operations (RFC 4511 section 4.2 - 4.14)
+const (
+	opBindRequest        = 0
+	opBindResponse       = 1
+	opUnbindRequest      = 2
+	opSearchRequest      = 3
+	opSearchResultEntry  = 4
+	opSearchResultDone   = 5
+	opSearchResultRef    = 19
+	opExtendedRequest    = 23
+	opExtendedResponse   = 24
+	protocolVersion      = 3
+	startTLSOID          = "1.3.6.1.4.1.1466.20037"
+	scopeWholeSubtree    = 2
+	neverDerefAliases    = 0
+	simpleAuthentication = 0
+)
+
+// LDAP result codes used by the client
+const (
+	resultSuccess            = 0
+	resultSizeLimitExceeded  = 4
+	resultInvalidCredentials = 49
+)
+
+// ResultError is a non-success result returned by the LDAP server
+type ResultError struct {
+	Code    int64
+	Message string
+}
+
+func (e *ResultError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap result code %d", e.Code)
+	}
+	return fmt.Sprintf("ldap result code %d: %s", e.Code, e.Message)
+}
+
+func isResultCode(err error, code int64) bool {
+	var resErr *ResultError
+	return errors.As(err, &resErr) && resErr.Code == code
+}
+
+// conn is a connection to the LDAP server. Operations are sent one at a time, so responses are read in order.
+type conn struct {
+	net.Conn
+	timeout time.Duration
+	msgID   int64
+}
+
+// entry is an entry returned by a search. Attribute names are in lowercase.
+type entry struct {
+	DN         string
+	Attributes map[string][][]byte
+}
+
+func (e *entry) value(attribute string) string {
+	values := e.Attributes[strings.ToLower(attribute)]
+	if len(values) == 0 {
+		return ""
+	}
+	return string(values[0])
+}
+
+func (e *entry) values(attribute string) []string {
+	var values []string
+	for _, v := range e.Attributes[strings.ToLower(attribute)] {
+		values = append(values, string(v))
+	}
+	return values
+}
+
+func (e *entry) rawValue(attribute string) []byte {
+	values := e.Attributes[strings.ToLower(attribute)]
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+func dial(rawURL string, tlsConfig *tls.Config, timeout time.Duration) (*conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var c net.Conn
+	switch u.Scheme {
+	case "ldaps":
+		c, err = tls.DialWithDialer(dialer, "tcp", hostPort(u, "636"), tlsConfig)
+	case "ldap":
+		c, err = dialer.Dial("tcp", hostPort(u, "389"))
+	default:
+		return nil, fmt.Errorf("unsupported ldap url scheme: %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{Conn: c, timeout: timeout}, nil
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// Close sends unbind request before closing the connection
+func (c *conn) Close() error {
+	c.send(&ber.Packet{Class: ber.ClassApplication, Tag: opUnbindRequest})
+	return c.Conn.Close()
+}
+
+func (c *conn) send(op *ber.Packet) (int64, error) {
+	c.msgID++
+	msg := ber.NewSequence(ber.NewInteger(ber.ClassUniversal, ber.TagInteger, c.msgID), op)
+
+	c.SetDeadline(time.Now().Add(c.timeout))
+	_, err := c.Write(msg.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	return c.msgID, nil
+}
+
+// receive returns the next protocol operation of the response to msgID
+func (c *conn) receive(msgID int64) (*ber.Packet, error) {
+	for {
+		c.SetDeadline(time.Now().Add(c.timeout))
+		msg, err := ber.ReadPacket(c)
+		if err != nil {
+			return nil, err
+		}
+		if !msg.Is(ber.ClassUniversal, ber.TagSequence) || len(msg.Children) < 2 {
+			return nil, fmt.Errorf("malformed ldap message")
+		}
+		id, err := msg.Children[0].Int()
+		if err != nil {
+			return nil, fmt.Errorf("malformed ldap message id: %w", err)
+		}
+		// unsolicited notifications have message id 0, e.g. notice of disconnection
+		if id == 0 {
+			if err = resultError(msg.Children[1]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if id != msgID {
+			return nil, fmt.Errorf("unexpected ldap message id %d", id)
+		}
+		return msg.Children[1], nil
+	}
+}
+
+// resultError returns error of the LDAPResult in op unless the result code is success
+func resultError(op *ber.Packet) error {
+	if len(op.Children) < 3 {
+		return fmt.Errorf("malformed ldap result")
+	}
+	code, err := op.Children[0].Int()
+	if err != nil {
+		return fmt.Errorf("malformed ldap result code: %w", err)
+	}
+	if code != resultSuccess {
+		return &ResultError{Code: code, Message: op.Children[2].String()}
+	}
+	return nil
+}
+
+func (c *conn) bind(dn, password string) error {
+	req := ber.NewConstructed(ber.ClassApplication, opBindRequest,
+		ber.NewInteger(ber.ClassUniversal, ber.TagInteger, protocolVersion),
+		ber.NewString(ber.ClassUniversal, ber.TagOctetString, dn),
+		ber.NewString(ber.ClassContext, simpleAuthentication, password),
+	)
+
+	msgID, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	res, err := c.receive(msgID)
+	if err != nil {
+		return err
+	}
+	if !res.Is(ber.ClassApplication, opBindResponse) {
+		return fmt.Errorf("unexpected ldap response to bind: %d", res.Tag)
+	}
+	return resultError(res)
+}
+
+// startTLS upgrades the connection to TLS (RFC 4511 section 4.14)
+func (c *conn) startTLS(tlsConfig *tls.Config) error {
+	req := ber.NewConstructed(ber.ClassApplication, opExtendedRequest,
+		ber.NewString(ber.ClassContext, 0, startTLSOID))
+
+	msgID, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	res, err := c.receive(msgID)
+	if err != nil {
+		return err
+	}
+	if !res.Is(ber.ClassApplication, opExtendedResponse) {
+		return fmt.Errorf("unexpected ldap response to start tls: %d", res.Tag)
+	}
+	if err = resultError(res); err != nil {
+		return err
+	}
+
+	tlsConn := tls.Client(c.Conn, tlsConfig)
+	tlsConn.SetDeadline(time.Now().Add(c.timeout))
+	if err = tlsConn.Handshake(); err != nil {
+		return err
+	}
+	c.Conn = tlsConn
+	return nil
+}
+
+// search returns entries matching the filter in the subtree of baseDN. Entries found before the size limit is
+// exceeded are returned with the error.
+func (c *conn) search(baseDN, filter string, attributes []string, sizeLimit int) ([]*entry, error) {
+	compiled, err := compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := ber.NewSequence()
+	for _, attribute := range attributes {
+		attrs.AppendChild(ber.NewString(ber.ClassUniversal, ber.TagOctetString, attribute))
+	}
+
+	req := ber.NewConstructed(ber.ClassApplication, opSearchRequest,
+		ber.NewString(ber.ClassUniversal, ber.TagOctetString, baseDN),
+		ber.NewInteger(ber.ClassUniversal, ber.TagEnumerated, scopeWholeSubtree),
+		ber.NewInteger(ber.ClassUniversal, ber.TagEnumerated, neverDerefAliases),
+		ber.NewInteger(ber.ClassUniversal, ber.TagInteger, int64(sizeLimit)),
+		ber.NewInteger(ber.ClassUniversal, ber.TagInteger, int64(c.timeout/time.Second)),
+		ber.NewBoolean(false),
+		compiled,
+		attrs,
+	)
+
+	msgID, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*entry
+	for {
+		res, err := c.receive(msgID)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case res.Is(ber.ClassApplication, opSearchResultEntry):
+			e, err := parseEntry(res)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		case res.Is(ber.ClassApplication, opSearchResultRef):
+			// referrals to other servers are not followed
+		case res.Is(ber.ClassApplication, opSearchResultDone):
+			return entries, resultError(res)
+		default:
+			return nil, fmt.Errorf("unexpected ldap response to search: %d", res.Tag)
+		}
+	}
+}
+
+func parseEntry(res *ber.Packet) (*entry, error) {
+	// objectName and attributes
+	if len(res.Children) != 2 {
+		return nil, fmt.Errorf("malformed ldap search result entry")
+	}
+
+	e := &entry{
+		DN:         res.Children[0].String(),
+		Attributes: map[string][][]byte{},
+	}
+	for _, attr := range res.Children[1].Children {
+		if len(attr.Children) != 2 {
+			return nil, fmt.Errorf("malformed ldap attribute of %s", e.DN)
+		}
+		name := strings.ToLower(attr.Children[0].String())
+		for _, value := range attr.Children[1].Children {
+			e.Attributes[name] = append(e.Attributes[name], value.Data)
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,291 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ksankeerth/open-image-registry/client/ldap/ber"
+)
+
+// Filter choices of a search request (RFC 4511 section 4.5.1.7)
+const (
+	filterAnd             = 0
+	filterOr              = 1
+	filterNot             = 2
+	filterEqualityMatch   = 3
+	filterSubstrings      = 4
+	filterGreaterOrEqual  = 5
+	filterLessOrEqual     = 6
+	filterPresent         = 7
+	filterApproxMatch     = 8
+	filterExtensibleMatch = 9
+)
+
+// maxFilterDepth bounds nesting of and, or and not filters
+const maxFilterDepth = 16
+
+// EscapeFilter escapes special characters of value so that it matches literally in a search filter (RFC 4515)
+func EscapeFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '*', '(', ')', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// compileFilter encodes the string representation of a search filter (RFC 4515)
+func compileFilter(filter string) (*ber.Packet, error) {
+	packet, rest, err := parseFilter(filter, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ldap filter %q: %w", filter, err)
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid ldap filter %q: unexpected %q", filter, rest)
+	}
+	return packet, nil
+}
+
+// parseFilter parses the filter at the start of s and returns the rest of s
+func parseFilter(s string, depth int) (*ber.Packet, string, error) {
+	if depth > maxFilterDepth {
+		return nil, "", fmt.Errorf("filter is nested too deeply")
+	}
+	if !strings.HasPrefix(s, "(") || len(s) < 2 {
+		return nil, "", fmt.Errorf("filter must be enclosed in parentheses")
+	}
+	s = s[1:]
+
+	var packet *ber.Packet
+	var err error
+	switch s[0] {
+	case '&', '|':
+		tag := filterAnd
+		if s[0] == '|' {
+			tag = filterOr
+		}
+		packet = ber.NewConstructed(ber.ClassContext, tag)
+		s = s[1:]
+		for strings.HasPrefix(s, "(") {
+			var child *ber.Packet
+			child, s, err = parseFilter(s, depth+1)
+			if err != nil {
+				return nil, "", err
+			}
+			packet.AppendChild(child)
+		}
+	case '!':
+		var child *ber.Packet
+		child, s, err = parseFilter(s[1:], depth+1)
+		if err != nil {
+			return nil, "", err
+		}
+		packet = ber.NewConstructed(ber.ClassContext, filterNot, child)
+	default:
+		// values can't contain ')' without escaping it, so the item ends at the first ')'
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			return nil, "", fmt.Errorf("missing ')'")
+		}
+		packet, err = parseItem(s[:end])
+		if err != nil {
+			return nil, "", err
+		}
+		s = s[end:]
+	}
+
+	if !strings.HasPrefix(s, ")") {
+		return nil, "", fmt.Errorf("missing ')'")
+	}
+	return packet, s[1:], nil
+}
+
+func parseItem(item string) (*ber.Packet, error) {
+	eq := strings.IndexByte(item, '=')
+	if eq < 1 {
+		return nil, fmt.Errorf("invalid filter item %q", item)
+	}
+	attribute, value := item[:eq], item[eq+1:]
+
+	tag := filterEqualityMatch
+	switch attribute[len(attribute)-1] {
+	case '~':
+		tag = filterApproxMatch
+	case '>':
+		tag = filterGreaterOrEqual
+	case '<':
+		tag = filterLessOrEqual
+	case ':':
+		return parseExtensibleMatch(attribute[:len(attribute)-1], value)
+	}
+	if tag != filterEqualityMatch {
+		attribute = attribute[:len(attribute)-1]
+	}
+	if !validAttribute(attribute) {
+		return nil, fmt.Errorf("invalid attribute %q", attribute)
+	}
+
+	if tag == filterEqualityMatch && value == "*" {
+		return ber.NewString(ber.ClassContext, filterPresent, attribute), nil
+	}
+	// literal asterisks are escaped, so any asterisk in the value is a wildcard
+	if tag == filterEqualityMatch && strings.Contains(value, "*") {
+		return parseSubstrings(attribute, value)
+	}
+
+	assertion, err := unescapeValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return ber.NewConstructed(ber.ClassContext, tag,
+		ber.NewString(ber.ClassUniversal, ber.TagOctetString, attribute),
+		ber.NewString(ber.ClassUniversal, ber.TagOctetString, assertion),
+	), nil
+}
+
+func parseSubstrings(attribute, value string) (*ber.Packet, error) {
+	substrings := ber.NewSequence()
+	parts := strings.Split(value, "*")
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		unescaped, err := unescapeValue(part)
+		if err != nil {
+			return nil, err
+		}
+		// initial [0], any [1] and final [2]
+		tag := 1
+		switch i {
+		case 0:
+			tag = 0
+		case len(parts) - 1:
+			tag = 2
+		}
+		substrings.AppendChild(ber.NewString(ber.ClassContext, tag, unescaped))
+	}
+
+	return ber.NewConstructed(ber.ClassContext, filterSubstrings,
+		ber.NewString(ber.ClassUniversal, ber.TagOctetString, attribute),
+		substrings,
+	), nil
+}
+
+// parseExtensibleMatch parses `attr[:dn][:rule]:=value` items, e.g. nested group membership filters of Active
+// Directory such as `memberOf:1.2.840.113556.1.4.1941:=cn=admins,dc=org`
+func parseExtensibleMatch(lhs, value string) (*ber.Packet, error) {
+	parts := strings.Split(lhs, ":")
+	attribute, options := parts[0], parts[1:]
+
+	dnAttributes := false
+	rule := ""
+	for i, option := range options {
+		switch {
+		case strings.EqualFold(option, "dn") && i == 0:
+			dnAttributes = true
+		case option != "" && rule == "" && i == len(options)-1:
+			rule = option
+		default:
+			return nil, fmt.Errorf("invalid extensible match %q", lhs)
+		}
+	}
+	if (attribute == "" && rule == "") || (attribute != "" && !validAttribute(attribute)) {
+		return nil, fmt.Errorf("invalid extensible match %q", lhs)
+	}
+
+	assertion, err := unescapeValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := ber.NewConstructed(ber.ClassContext, filterExtensibleMatch)
+	if rule != "" {
+		packet.AppendChild(ber.NewString(ber.ClassContext, 1, rule))
+	}
+	if attribute != "" {
+		packet.AppendChild(ber.NewString(ber.ClassContext, 2, attribute))
+	}
+	packet.AppendChild(ber.NewString(ber.ClassContext, 3, assertion))
+	if dnAttributes {
+		dn := ber.NewBoolean(true)
+		dn.Class, dn.Tag = ber.ClassContext, 4
+		packet.AppendChild(dn)
+	}
+	return packet, nil
+}
+
+// validAttribute reports whether name is an attribute description, e.g. `cn`, `userCertificate;binary` or an OID
+func validAttribute(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '.', c == ';':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// unescapeValue decodes `\XX` escapes of an assertion value
+func unescapeValue(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+2 >= len(value) {
+			return "", fmt.Errorf("invalid escape in value %q", value)
+		}
+		decoded, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in value %q", value)
+		}
+		b.Write(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// groupName returns value of the first RDN of the group DN, e.g. `developers` of `cn=developers,ou=groups,dc=org`
+func groupName(groupDN string) string {
+	_, value, found := strings.Cut(groupDN, "=")
+	if !found {
+		return ""
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && i+2 < len(value) && isHex(value[i+1]) && isHex(value[i+2]):
+			decoded, _ := hex.DecodeString(value[i+1 : i+3])
+			b.Write(decoded)
+			i += 2
+		case c == '\\' && i+1 < len(value):
+			b.WriteByte(value[i+1])
+			i++
+		case c == ',' || c == '+':
+			return strings.TrimSpace(b.String())
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
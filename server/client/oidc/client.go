@@ -0,0 +1,225 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/config"
+)
+
+// ErrLoginRejected is returned when the identity provider rejects the authorization code or returns an ID token
+// which can't be trusted. Other errors are failures to reach the identity provider.
+var ErrLoginRejected = errors.New("oidc login rejected")
+
+const discoveryPath = "/.well-known/openid-configuration"
+
+// Client authenticates users with an OpenID Connect provider using authorization code flow with PKCE.
+type Client struct {
+	cfg        *config.OIDCConfig
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	endpoints *endpoints
+	keys      *keySet
+}
+
+type endpoints struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func NewClient(cfg *config.OIDCConfig) *Client {
+	return &Client{
+		cfg: cfg,
+		httpClient: &http.Client{
+			Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+		},
+	}
+}
+
+// AuthCodeURL returns the URL of the identity provider where the browser is redirected to log in
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	ep, err := c.getEndpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(ep.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+
+	params := authURL.Query()
+	params.Set("response_type", "code")
+	params.Set("client_id", c.cfg.ClientID)
+	params.Set("redirect_uri", c.cfg.RedirectURL)
+	params.Set("scope", strings.Join(c.cfg.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+	authURL.RawQuery = params.Encode()
+
+	return authURL.String(), nil
+}
+
+// Exchange redeems the authorization code at the token endpoint and returns claims of the verified ID token
+func (c *Client) Exchange(ctx context.Context, code, codeVerifier, nonce string) (Claims, error) {
+	ep, err := c.getEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.cfg.RedirectURL)
+	form.Set("client_id", c.cfg.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tokenRes tokenResponse
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokenRes)
+	if err != nil {
+		return nil, fmt.Errorf("reading token response failed: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("%w: %s %s", ErrLoginRejected, tokenRes.Error, tokenRes.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned status: %d", resp.StatusCode)
+	}
+	if tokenRes.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response doesn't have id_token", ErrLoginRejected)
+	}
+
+	claims, err := c.verifyIDToken(ctx, ep, tokenRes.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// getEndpoints returns the configured endpoints. Missing endpoints are discovered from the issuer.
+func (c *Client) getEndpoints(ctx context.Context) (*endpoints, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.endpoints != nil {
+		return c.endpoints, nil
+	}
+
+	ep := &endpoints{
+		Issuer:                c.cfg.Issuer,
+		AuthorizationEndpoint: c.cfg.AuthorizationEndpoint,
+		TokenEndpoint:         c.cfg.TokenEndpoint,
+		JWKSURI:               c.cfg.JWKSURI,
+	}
+
+	if ep.AuthorizationEndpoint == "" || ep.TokenEndpoint == "" || ep.JWKSURI == "" {
+		discovered, err := c.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if ep.AuthorizationEndpoint == "" {
+			ep.AuthorizationEndpoint = discovered.AuthorizationEndpoint
+		}
+		if ep.TokenEndpoint == "" {
+			ep.TokenEndpoint = discovered.TokenEndpoint
+		}
+		if ep.JWKSURI == "" {
+			ep.JWKSURI = discovered.JWKSURI
+		}
+	}
+
+	c.endpoints = ep
+	return ep, nil
+}
+
+func (c *Client) discover(ctx context.Context) (*endpoints, error) {
+	discoveryURL := strings.TrimSuffix(c.cfg.Issuer, "/") + discoveryPath
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("discovery request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery endpoint returned status: %d", resp.StatusCode)
+	}
+
+	var ep endpoints
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&ep)
+	if err != nil {
+		return nil, fmt.Errorf("reading discovery document failed: %w", err)
+	}
+
+	if ep.Issuer != c.cfg.Issuer {
+		return nil, fmt.Errorf("issuer of discovery document(%s) doesn't match the configured issuer", ep.Issuer)
+	}
+	if ep.AuthorizationEndpoint == "" || ep.TokenEndpoint == "" || ep.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document doesn't have all endpoints")
+	}
+
+	return &ep, nil
+}
+
+// NewRandomString returns a random url-safe string. It is used for state, nonce and PKCE code verifier.
+func NewRandomString() (string, error) {
+	bytes := make([]byte, 32)
+
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate random string: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// CodeChallenge returns the S256 PKCE code challenge of the code verifier
+func CodeChallenge(codeVerifier string) string {
+	hashed := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hashed[:])
+}
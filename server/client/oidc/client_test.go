@@ -0,0 +1,157 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeChallenge(t *testing.T) {
+	// RFC 7636 Appendix B
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks := map[string]any{
+		"keys": []map[string]any{
+			{
+				"kid": "rsa-key", "kty": "RSA", "use": "sig",
+				"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kid": "ec-key", "kty": "EC", "crv": "P-256",
+				"x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	defer server.Close()
+
+	issuer := "https://idp.example.com"
+	client := NewClient(&config.OIDCConfig{
+		Issuer:         issuer,
+		ClientID:       "registry",
+		TimeoutSeconds: 5,
+	})
+	ep := &endpoints{Issuer: issuer, JWKSURI: server.URL}
+
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss":   issuer,
+			"aud":   []string{"registry", "other"},
+			"sub":   "user-1",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": "nonce-1",
+		}
+	}
+
+	tcs := []struct {
+		name   string
+		alg    string
+		kid    string
+		key    crypto.Signer
+		claims func(claims map[string]any)
+		valid  bool
+	}{
+		{"RS256", "RS256", "rsa-key", rsaKey, nil, true},
+		{"ES256", "ES256", "ec-key", ecKey, nil, true},
+		{"Audience as string", "RS256", "rsa-key", rsaKey, func(c map[string]any) { c["aud"] = "registry" }, true},
+		{"Signed by another key", "RS256", "rsa-key", otherKey, nil, false},
+		{"Unknown key", "RS256", "rotated-key", rsaKey, nil, false},
+		{"Algorithm doesn't match key", "ES256", "rsa-key", ecKey, nil, false},
+		{"Unsupported algorithm", "HS256", "rsa-key", rsaKey, nil, false},
+		{"Wrong issuer", "RS256", "rsa-key", rsaKey, func(c map[string]any) { c["iss"] = "https://evil.com" }, false},
+		{"Wrong audience", "RS256", "rsa-key", rsaKey, func(c map[string]any) { c["aud"] = "other" }, false},
+		{"Expired", "RS256", "rsa-key", rsaKey, func(c map[string]any) {
+			c["exp"] = time.Now().Add(-2 * clockSkew).Unix()
+		}, false},
+		{"Wrong nonce", "RS256", "rsa-key", rsaKey, func(c map[string]any) { c["nonce"] = "replayed" }, false},
+		{"Missing subject", "RS256", "rsa-key", rsaKey, func(c map[string]any) { delete(c, "sub") }, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := validClaims()
+			if tc.claims != nil {
+				tc.claims(claims)
+			}
+			token := signToken(t, tc.alg, tc.kid, tc.key, claims)
+
+			verified, err := client.verifyIDToken(context.Background(), ep, token, "nonce-1")
+			if tc.valid {
+				require.NoError(t, err)
+				assert.Equal(t, "user-1", verified.String("sub"))
+			} else {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, ErrLoginRejected))
+			}
+		})
+	}
+}
+
+func TestClaimsStrings(t *testing.T) {
+	claims := Claims{
+		"groups": []any{"admins", 1, "developers"},
+		"role":   "admins",
+	}
+
+	assert.Equal(t, []string{"admins", "developers"}, claims.Strings("groups"))
+	assert.Equal(t, []string{"admins"}, claims.Strings("role"))
+	assert.Nil(t, claims.Strings("missing"))
+}
+
+func signToken(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	body, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	unsigned := b64(header) + "." + b64(body)
+	hashed := crypto.SHA256.New()
+	hashed.Write([]byte(unsigned))
+	digest := hashed.Sum(nil)
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest)
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		require.NoError(t, err)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+
+	return unsigned + "." + b64(sig)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,300 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// clockSkew is tolerated when checking expiry of ID tokens
+const clockSkew = time.Minute
+
+// keyRefreshInterval limits how often the key set is fetched again for unknown key ids
+const keyRefreshInterval = time.Minute
+
+// Claims of an ID token
+type Claims map[string]any
+
+// String returns the claim if it is a string
+func (c Claims) String(name string) string {
+	v, _ := c[name].(string)
+	return v
+}
+
+// Strings returns the claim as a list. A string claim is returned as a list with a single value.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+type keySet struct {
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type signingAlgorithm struct {
+	hash crypto.Hash
+	// curve is set for ECDSA algorithms
+	curve elliptic.Curve
+}
+
+var signingAlgorithms = map[string]signingAlgorithm{
+	"RS256": {hash: crypto.SHA256},
+	"RS384": {hash: crypto.SHA384},
+	"RS512": {hash: crypto.SHA512},
+	"ES256": {hash: crypto.SHA256, curve: elliptic.P256()},
+	"ES384": {hash: crypto.SHA384, curve: elliptic.P384()},
+}
+
+// verifyIDToken verifies signature, issuer, audience, expiry and nonce of the ID token
+func (c *Client) verifyIDToken(ctx context.Context, ep *endpoints, token, nonce string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: invalid id token format", ErrLoginRejected)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid id token header", ErrLoginRejected)
+	}
+
+	alg, ok := signingAlgorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported id token algorithm: %s", ErrLoginRejected, header.Alg)
+	}
+
+	key, err := c.getKey(ctx, ep, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid id token signature", ErrLoginRejected)
+	}
+
+	hasher := alg.hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(alg, key, hasher.Sum(nil), sig) {
+		return nil, fmt.Errorf("%w: invalid id token signature", ErrLoginRejected)
+	}
+
+	var claims Claims
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid id token claims", ErrLoginRejected)
+	}
+
+	if claims.String("iss") != ep.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer: %s", ErrLoginRejected, claims.String("iss"))
+	}
+	audience := claims.Strings("aud")
+	if !slices.Contains(audience, c.cfg.ClientID) {
+		return nil, fmt.Errorf("%w: id token is not issued to this client", ErrLoginRejected)
+	}
+	if azp := claims.String("azp"); azp != "" && azp != c.cfg.ClientID {
+		return nil, fmt.Errorf("%w: id token is authorized to another party: %s", ErrLoginRejected, azp)
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || time.Unix(int64(exp), 0).Add(clockSkew).Before(time.Now()) {
+		return nil, fmt.Errorf("%w: id token has expired", ErrLoginRejected)
+	}
+	if claims.String("nonce") != nonce {
+		return nil, fmt.Errorf("%w: nonce of id token doesn't match", ErrLoginRejected)
+	}
+	if claims.String("sub") == "" {
+		return nil, fmt.Errorf("%w: id token doesn't have a subject", ErrLoginRejected)
+	}
+
+	return claims, nil
+}
+
+func verifySignature(alg signingAlgorithm, key crypto.PublicKey, hashed, sig []byte) bool {
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if alg.curve != nil {
+			return false
+		}
+		return rsa.VerifyPKCS1v15(pub, alg.hash, hashed, sig) == nil
+	case *ecdsa.PublicKey:
+		if alg.curve == nil || pub.Curve != alg.curve {
+			return false
+		}
+		size := (alg.curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(pub, hashed, r, s)
+	}
+	return false
+}
+
+// getKey returns the signing key of the identity provider. Keys are fetched again if the key id is unknown, since
+// identity providers rotate their keys.
+func (c *Client) getKey(ctx context.Context, ep *endpoints, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.keys == nil || (c.keys.lookup(kid) == nil && time.Since(c.keys.fetchedAt) >= keyRefreshInterval) {
+		keys, err := c.fetchKeys(ctx, ep.JWKSURI)
+		if err != nil {
+			return nil, err
+		}
+		c.keys = keys
+	}
+
+	key := c.keys.lookup(kid)
+	if key == nil {
+		return nil, fmt.Errorf("%w: unknown signing key: %s", ErrLoginRejected, kid)
+	}
+	return key, nil
+}
+
+// lookup returns the key of the kid. Tokens without a kid can be verified only if there is a single key.
+func (s *keySet) lookup(kid string) crypto.PublicKey {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key
+		}
+	}
+	return s.keys[kid]
+}
+
+func (c *Client) fetchKeys(ctx context.Context, jwksURI string) (*keySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("jwks request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned status: %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&jwks)
+	if err != nil {
+		return nil, fmt.Errorf("reading jwks failed: %w", err)
+	}
+
+	keys := &keySet{
+		keys:      make(map[string]crypto.PublicKey),
+		fetchedAt: time.Now(),
+	}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// keys of unsupported types are ignored
+			continue
+		}
+		keys.keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		// ECDH conversion rejects points which are not on the curve
+		if _, err := pub.ECDH(); err != nil {
+			return nil, fmt.Errorf("invalid ec key: %w", err)
+		}
+		return pub, nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
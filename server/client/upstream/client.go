@@ -1,12 +1,28 @@
 package upstream
 
+import (
+	"context"
+	"errors"
+)
+
+// ErrManifestNotFound is returned by GetManifest when upstream responds with 404.
+var ErrManifestNotFound = errors.New("manifest not found in upstream")
+
+// UpstreamClient reads images from an upstream registry. Requests are aborted when ctx is cancelled, e.g. when
+// the client pulling the image disconnects or the listener shuts down.
 type UpstreamClient interface {
-	GetManifest(namespace, repository, identifier string) (content []byte, mediaType string, err error)
+	GetManifest(ctx context.Context, namespace, repository, identifier string) (content []byte, mediaType string,
+		err error)
+
+	// HeadManifest checks the manifest without downloading it. digest is taken from Docker-Content-Digest header.
+	// exists is false only if upstream responds with 404. Other failures are returned as errors.
+	HeadManifest(ctx context.Context, namespace, repository, identifier string) (exists bool, digest string, err error)
 
-	HeadManifest(namespace, repository, identifier string) (exists bool, err error)
+	GetBlob(ctx context.Context, namespace, repository, digest string) (content []byte, err error)
 
-	GetBlob(namespace, repository, digest string) (content []byte, err error)
+	HeadBlob(ctx context.Context, namespace, repository, digest string) (exists bool, err error)
 
-	HeadBlob(namespace, repository, digest string) (exists bool, err error)
+	// ListTags returns all tags of the repository. Paginated responses are followed until the last page.
+	ListTags(ctx context.Context, namespace, repository string) (tags []string, err error)
 }
 
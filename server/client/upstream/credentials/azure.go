@@ -0,0 +1,192 @@
+package credentials
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAzureAuthorityHost = "https://login.microsoftonline.com"
+	defaultAzureScope         = "https://management.azure.com/.default"
+
+	// ACR expects this username when refresh token is used as password.
+	acrRefreshTokenUsername = "00000000-0000-0000-0000-000000000000"
+)
+
+// AzureServicePrincipalConfig is the CONFIG_JSON of `azure_service_principal` upstreams.
+type AzureServicePrincipalConfig struct {
+	TenantID     string `json:"tenant_id"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// AuthorityHost overrides the Azure AD host. Defaults to https://login.microsoftonline.com
+	AuthorityHost string `json:"authority_host,omitempty"`
+	Scope         string `json:"scope,omitempty"`
+	// ExchangeEndpoint overrides the ACR token exchange endpoint. Defaults to <upstream_url>/oauth2/exchange
+	ExchangeEndpoint string `json:"exchange_endpoint,omitempty"`
+}
+
+// AzureProvider obtains an Azure AD access token using client credentials and
+// exchanges it for an ACR refresh token.
+type AzureProvider struct {
+	cfg              *AzureServicePrincipalConfig
+	tokenEndpoint    string
+	exchangeEndpoint string
+	service          string
+	httpClient       *http.Client
+	now              func() time.Time
+}
+
+type acrExchangeResponse struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func NewAzureProvider(cfg *AzureServicePrincipalConfig, registryURL string, httpClient *http.Client) (*AzureProvider, error) {
+	if cfg.TenantID == "" || cfg.ClientID == "" || cfg.ClientSecret == "" {
+		return nil, fmt.Errorf("azure_service_principal auth config requires tenant_id, client_id and client_secret")
+	}
+
+	registry, err := url.Parse(registryURL)
+	if err != nil || registry.Host == "" {
+		return nil, fmt.Errorf("invalid upstream url for azure container registry: %s", registryURL)
+	}
+
+	authorityHost := strings.TrimSuffix(cfg.AuthorityHost, "/")
+	if authorityHost == "" {
+		authorityHost = defaultAzureAuthorityHost
+	}
+
+	exchangeEndpoint := cfg.ExchangeEndpoint
+	if exchangeEndpoint == "" {
+		exchangeEndpoint = fmt.Sprintf("%s://%s/oauth2/exchange", registry.Scheme, registry.Host)
+	}
+
+	return &AzureProvider{
+		cfg:              cfg,
+		tokenEndpoint:    fmt.Sprintf("%s/%s/oauth2/v2.0/token", authorityHost, url.PathEscape(cfg.TenantID)),
+		exchangeEndpoint: exchangeEndpoint,
+		service:          registry.Host,
+		httpClient:       httpClient,
+		now:              time.Now,
+	}, nil
+}
+
+func (p *AzureProvider) Retrieve(ctx context.Context) (*Credential, error) {
+	issuedAt := p.now()
+
+	aadToken, err := p.aadToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "access_token")
+	form.Set("service", p.service)
+	form.Set("tenant", p.cfg.TenantID)
+	form.Set("access_token", aadToken.AccessToken)
+
+	resp, err := p.postForm(ctx, p.exchangeEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call acr exchange endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("acr token exchange failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var exchangeResp acrExchangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&exchangeResp); err != nil {
+		return nil, fmt.Errorf("failed to decode acr exchange response: %w", err)
+	}
+
+	if exchangeResp.RefreshToken == "" {
+		return nil, fmt.Errorf("acr exchange endpoint returned empty refresh token")
+	}
+
+	expiresAt, ok := jwtExpiry(exchangeResp.RefreshToken)
+	if !ok {
+		// fallback to expiry of aad token if refresh token is not a readable JWT
+		expiresAt = issuedAt.Add(time.Duration(aadToken.ExpiresIn) * time.Second)
+	}
+
+	return &Credential{
+		Username:  acrRefreshTokenUsername,
+		Password:  exchangeResp.RefreshToken,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (p *AzureProvider) aadToken(ctx context.Context) (*oauth2TokenResponse, error) {
+	scope := p.cfg.Scope
+	if scope == "" {
+		scope = defaultAzureScope
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("client_secret", p.cfg.ClientSecret)
+	form.Set("scope", scope)
+
+	resp, err := p.postForm(ctx, p.tokenEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call azure ad token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("azure ad token request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp oauth2TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to decode azure ad token response: %w", err)
+	}
+
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("azure ad returned empty access token")
+	}
+
+	return &tokenResp, nil
+}
+
+func (p *AzureProvider) postForm(ctx context.Context, endpoint string, form url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return p.httpClient.Do(req)
+}
+
+// jwtExpiry reads `exp` claim of a JWT without verifying it. Signature of the token is
+// verified by the registry, We only need to know when to refresh it.
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(claims.Exp, 0), true
+}
@@ -0,0 +1,230 @@
+package credentials
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingProvider struct {
+	calls atomic.Int32
+	ttl   time.Duration
+}
+
+func (p *countingProvider) Retrieve(ctx context.Context) (*Credential, error) {
+	n := p.calls.Add(1)
+	return &Credential{
+		Username:  "user",
+		Password:  fmt.Sprintf("password-%d", n),
+		ExpiresAt: time.Now().Add(p.ttl),
+	}, nil
+}
+
+func TestCachingProvider(t *testing.T) {
+	t.Run("Cached credential is reused until refresh window", func(t *testing.T) {
+		source := &countingProvider{ttl: time.Hour}
+		p := NewCachingProvider(source, time.Minute)
+
+		for i := 0; i < 5; i++ {
+			cred, err := p.Retrieve(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, "password-1", cred.Password)
+		}
+		assert.Equal(t, int32(1), source.calls.Load())
+	})
+
+	t.Run("Credential close to expiry is refreshed in background", func(t *testing.T) {
+		source := &countingProvider{ttl: 30 * time.Second}
+		p := NewCachingProvider(source, time.Minute)
+
+		cred, err := p.Retrieve(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "password-1", cred.Password)
+
+		// still valid, so the cached one is returned while a refresh is triggered
+		cred, err = p.Retrieve(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "password-1", cred.Password)
+
+		assert.Eventually(t, func() bool {
+			cred, err := p.Retrieve(context.Background())
+			return err == nil && cred.Password != "password-1"
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Expired credential is fetched again", func(t *testing.T) {
+		source := &countingProvider{ttl: -time.Second}
+		p := NewCachingProvider(source, 0)
+
+		cred, err := p.Retrieve(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "password-1", cred.Password)
+
+		cred, err = p.Retrieve(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "password-2", cred.Password)
+	})
+}
+
+func TestECRProvider(t *testing.T) {
+	expiresAt := time.Now().Add(12 * time.Hour).Truncate(time.Second)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, ecrTargetGetAuthorizationToken, r.Header.Get("X-Amz-Target"))
+		assert.Equal(t, "session-token", r.Header.Get("X-Amz-Security-Token"))
+
+		auth := r.Header.Get("Authorization")
+		assert.True(t, strings.HasPrefix(auth, awsSigningAlgorithm+" Credential=AKIDEXAMPLE/"))
+		assert.Contains(t, auth, "/us-east-1/ecr/aws4_request")
+		assert.Contains(t, auth, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target")
+
+		var body map[string][]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, []string{"123456789012"}, body["registryIds"])
+
+		w.Header().Set("Content-Type", ecrContentType)
+		fmt.Fprintf(w, `{"authorizationData":[{"authorizationToken":"%s","expiresAt":%d,"proxyEndpoint":"https://123456789012.dkr.ecr.us-east-1.amazonaws.com"}]}`,
+			base64.StdEncoding.EncodeToString([]byte("AWS:ecr-password")), expiresAt.Unix())
+	}))
+	defer server.Close()
+
+	configJSON, _ := json.Marshal(ECRConfig{
+		Region:          "us-east-1",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "session-token",
+		RegistryID:      "123456789012",
+		Endpoint:        server.URL,
+	})
+
+	p, err := NewProvider(constants.UpstreamAuthTypeAWSECR, configJSON, "https://123456789012.dkr.ecr.us-east-1.amazonaws.com", nil)
+	require.NoError(t, err)
+
+	cred, err := p.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AWS", cred.Username)
+	assert.Equal(t, "ecr-password", cred.Password)
+	assert.True(t, expiresAt.Equal(cred.ExpiresAt))
+}
+
+func TestGCPProvider(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	var tokenURI string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, gcpJWTBearerGrant, r.PostForm.Get("grant_type"))
+
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		require.Len(t, parts, 3)
+
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+		hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], signature))
+
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+		var claims map[string]any
+		require.NoError(t, json.Unmarshal(payload, &claims))
+		assert.Equal(t, "puller@project.iam.gserviceaccount.com", claims["iss"])
+		assert.Equal(t, tokenURI, claims["aud"])
+		assert.Equal(t, defaultGCPScope, claims["scope"])
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"gcp-access-token","token_type":"Bearer","expires_in":3599}`))
+	}))
+	defer server.Close()
+	tokenURI = server.URL + "/token"
+
+	configJSON, _ := json.Marshal(GCPServiceAccountConfig{
+		ClientEmail:  "puller@project.iam.gserviceaccount.com",
+		PrivateKey:   pemKey,
+		PrivateKeyID: "key-1",
+		TokenURI:     tokenURI,
+	})
+
+	p, err := NewProvider(constants.UpstreamAuthTypeGCPServiceAccount, configJSON, "https://gcr.io", nil)
+	require.NoError(t, err)
+
+	cred, err := p.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, gcpRegistryUsername, cred.Username)
+	assert.Equal(t, "gcp-access-token", cred.Password)
+	assert.WithinDuration(t, time.Now().Add(3599*time.Second), cred.ExpiresAt, 5*time.Second)
+}
+
+func TestAzureProvider(t *testing.T) {
+	refreshExp := time.Now().Add(3 * time.Hour).Unix()
+	refreshToken := "eyJhbGciOiJSUzI1NiJ9." +
+		base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, refreshExp))) + ".c2ln"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tenant-1/oauth2/v2.0/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "client-1", r.PostForm.Get("client_id"))
+		assert.Equal(t, "secret-1", r.PostForm.Get("client_secret"))
+		w.Write([]byte(`{"access_token":"aad-token","token_type":"Bearer","expires_in":3600}`))
+	})
+	mux.HandleFunc("/oauth2/exchange", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "access_token", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "aad-token", r.PostForm.Get("access_token"))
+		assert.Equal(t, "tenant-1", r.PostForm.Get("tenant"))
+		assert.Equal(t, strings.TrimPrefix(r.Host, "http://"), r.PostForm.Get("service"))
+		fmt.Fprintf(w, `{"refresh_token":"%s"}`, refreshToken)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	configJSON, _ := json.Marshal(AzureServicePrincipalConfig{
+		TenantID:      "tenant-1",
+		ClientID:      "client-1",
+		ClientSecret:  "secret-1",
+		AuthorityHost: server.URL,
+	})
+
+	// upstream url points to the fake, so the default exchange endpoint is used.
+	p, err := NewProvider(constants.UpstreamAuthTypeAzureServicePrincipal, configJSON, server.URL, nil)
+	require.NoError(t, err)
+
+	cred, err := p.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, acrRefreshTokenUsername, cred.Username)
+	assert.Equal(t, refreshToken, cred.Password)
+	assert.Equal(t, refreshExp, cred.ExpiresAt.Unix())
+}
+
+func TestNewProviderRejectsInvalidConfig(t *testing.T) {
+	_, err := NewProvider(constants.UpstreamAuthTypeAWSECR, []byte(`{"region":"us-east-1"}`), "", nil)
+	assert.Error(t, err)
+
+	_, err = NewProvider(constants.UpstreamAuthTypeGCPServiceAccount, []byte(`{"client_email":"a@b","private_key":"x"}`), "", nil)
+	assert.Error(t, err)
+
+	_, err = NewProvider(constants.UpstreamAuthTypeBasic, []byte(`{}`), "", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,203 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ecrTargetGetAuthorizationToken = "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken"
+	ecrContentType                 = "application/x-amz-json-1.1"
+	ecrServiceName                 = "ecr"
+	awsSigningAlgorithm            = "AWS4-HMAC-SHA256"
+)
+
+// ECRConfig is the CONFIG_JSON of `aws_ecr` upstreams.
+type ECRConfig struct {
+	Region          string `json:"region"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token,omitempty"`
+	RegistryID      string `json:"registry_id,omitempty"`
+	// Endpoint overrides the ECR API endpoint. Defaults to https://api.ecr.<region>.amazonaws.com
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// ECRProvider calls ECR GetAuthorizationToken API to obtain registry password.
+type ECRProvider struct {
+	cfg        *ECRConfig
+	endpoint   *url.URL
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+type ecrAuthorizationTokenResponse struct {
+	AuthorizationData []struct {
+		AuthorizationToken string  `json:"authorizationToken"`
+		ExpiresAt          float64 `json:"expiresAt"`
+		ProxyEndpoint      string  `json:"proxyEndpoint"`
+	} `json:"authorizationData"`
+}
+
+func NewECRProvider(cfg *ECRConfig, httpClient *http.Client) (*ECRProvider, error) {
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("aws_ecr auth config requires region")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("aws_ecr auth config requires access_key_id and secret_access_key")
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://api.ecr.%s.amazonaws.com", cfg.Region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ecr endpoint: %w", err)
+	}
+
+	return &ECRProvider{
+		cfg:        cfg,
+		endpoint:   u,
+		httpClient: httpClient,
+		now:        time.Now,
+	}, nil
+}
+
+func (p *ECRProvider) Retrieve(ctx context.Context) (*Credential, error) {
+	body := []byte("{}")
+	if p.cfg.RegistryID != "" {
+		body, _ = json.Marshal(map[string][]string{"registryIds": {p.cfg.RegistryID}})
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ecr request: %w", err)
+	}
+	req.Header.Set("Content-Type", ecrContentType)
+	req.Header.Set("X-Amz-Target", ecrTargetGetAuthorizationToken)
+
+	p.sign(req, body, p.now().UTC())
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call ecr GetAuthorizationToken: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ecr GetAuthorizationToken failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var tokenResp ecrAuthorizationTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to decode ecr response: %w", err)
+	}
+
+	if len(tokenResp.AuthorizationData) == 0 {
+		return nil, fmt.Errorf("ecr returned no authorization data")
+	}
+
+	data := tokenResp.AuthorizationData[0]
+	decoded, err := base64.StdEncoding.DecodeString(data.AuthorizationToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ecr authorization token: %w", err)
+	}
+
+	username, password, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return nil, fmt.Errorf("invalid ecr authorization token format")
+	}
+
+	sec := int64(data.ExpiresAt)
+	nsec := int64((data.ExpiresAt - float64(sec)) * 1e9)
+
+	return &Credential{
+		Username:  username,
+		Password:  password,
+		ExpiresAt: time.Unix(sec, nsec),
+	}, nil
+}
+
+// sign signs the request with AWS Signature Version 4.
+func (p *ECRProvider) sign(req *http.Request, body []byte, t time.Time) {
+	amzDate := t.Format("20060102T150405Z")
+	dateStamp := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if p.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.cfg.SessionToken)
+	}
+
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	credentialScope := fmt.Sprintf("%s/%s/%s/aws4_request", dateStamp, p.cfg.Region, ecrServiceName)
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		awsSigningAlgorithm,
+		amzDate,
+		credentialScope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+p.cfg.SecretAccessKey), dateStamp)
+	signingKey = hmacSHA256(signingKey, p.cfg.Region)
+	signingKey = hmacSHA256(signingKey, ecrServiceName)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsSigningAlgorithm, p.cfg.AccessKeyID, credentialScope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
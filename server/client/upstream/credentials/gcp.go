@@ -0,0 +1,192 @@
+package credentials
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGCPTokenURI = "https://oauth2.googleapis.com/token"
+	defaultGCPScope    = "https://www.googleapis.com/auth/cloud-platform"
+	gcpJWTBearerGrant  = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	gcpAssertionTTL    = time.Hour
+
+	// GCR and Artifact Registry accept OAuth2 access tokens as password with this username.
+	gcpRegistryUsername = "oauth2accesstoken"
+)
+
+// GCPServiceAccountConfig is the CONFIG_JSON of `gcp_service_account` upstreams.
+// Fields are the same as the JSON key file of the service account, so the key file
+// can be stored as it is.
+type GCPServiceAccountConfig struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id,omitempty"`
+	// TokenURI is the OAuth2 token endpoint. Defaults to https://oauth2.googleapis.com/token
+	TokenURI string   `json:"token_uri,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+// GCPProvider exchanges a signed JWT assertion for an OAuth2 access token.
+type GCPProvider struct {
+	cfg        *GCPServiceAccountConfig
+	key        *rsa.PrivateKey
+	tokenURI   string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+type oauth2TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func NewGCPProvider(cfg *GCPServiceAccountConfig, httpClient *http.Client) (*GCPProvider, error) {
+	if cfg.ClientEmail == "" {
+		return nil, fmt.Errorf("gcp_service_account auth config requires client_email")
+	}
+
+	key, err := parseRSAPrivateKey(cfg.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gcp service account private key: %w", err)
+	}
+
+	tokenURI := cfg.TokenURI
+	if tokenURI == "" {
+		tokenURI = defaultGCPTokenURI
+	}
+
+	return &GCPProvider{
+		cfg:        cfg,
+		key:        key,
+		tokenURI:   tokenURI,
+		httpClient: httpClient,
+		now:        time.Now,
+	}, nil
+}
+
+func (p *GCPProvider) Retrieve(ctx context.Context) (*Credential, error) {
+	issuedAt := p.now()
+
+	assertion, err := p.assertion(issuedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", gcpJWTBearerGrant)
+	form.Set("assertion", assertion)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcp token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call gcp token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("gcp token request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp oauth2TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to decode gcp token response: %w", err)
+	}
+
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("gcp token endpoint returned empty access token")
+	}
+
+	return &Credential{
+		Username:  gcpRegistryUsername,
+		Password:  tokenResp.AccessToken,
+		ExpiresAt: issuedAt.Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// assertion creates RS256 signed JWT as described in
+// https://developers.google.com/identity/protocols/oauth2/service-account#authorizingrequests
+func (p *GCPProvider) assertion(issuedAt time.Time) (string, error) {
+	scopes := p.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{defaultGCPScope}
+	}
+
+	header := map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+	}
+	if p.cfg.PrivateKeyID != "" {
+		header["kid"] = p.cfg.PrivateKeyID
+	}
+
+	claims := map[string]any{
+		"iss":   p.cfg.ClientEmail,
+		"scope": strings.Join(scopes, " "),
+		"aud":   p.tokenURI,
+		"iat":   issuedAt.Unix(),
+		"exp":   issuedAt.Add(gcpAssertionTTL).Unix(),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal assertion header: %w", err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal assertion claims: %w", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign assertion: %w", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func parseRSAPrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not a RSA key")
+	}
+	return key, nil
+}
@@ -0,0 +1,182 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/log"
+)
+
+// defaultRefreshWindow is how long before expiry a cached credential
+// is refreshed in the background.
+const defaultRefreshWindow = 5 * time.Minute
+
+// Credential is a username/password pair which can be presented to an
+// upstream registry. Cloud providers hand out short-lived passwords, so
+// ExpiresAt is set for them. Zero value of ExpiresAt means it never expires.
+type Credential struct {
+	Username  string
+	Password  string
+	ExpiresAt time.Time
+}
+
+func (c *Credential) expired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
+}
+
+func (c *Credential) needsRefresh(now time.Time, window time.Duration) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt.Add(-window))
+}
+
+// DefaultTimeout is the timeout of requests made to obtain credentials from cloud providers.
+const DefaultTimeout = 30 * time.Second
+
+// Provider turns stored upstream credentials into credentials accepted by
+// the upstream registry.
+type Provider interface {
+	Retrieve(ctx context.Context) (*Credential, error)
+}
+
+// NewProvider creates a caching provider for the given auth type. `configJSON` is the
+// CONFIG_JSON stored for the upstream and `registryURL` is the upstream url.
+func NewProvider(authType string, configJSON []byte, registryURL string, httpClient *http.Client) (Provider, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	var source Provider
+
+	switch authType {
+	case constants.UpstreamAuthTypeAWSECR:
+		var cfg ECRConfig
+		if err := json.Unmarshal(configJSON, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid aws_ecr auth config: %w", err)
+		}
+		p, err := NewECRProvider(&cfg, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		source = p
+	case constants.UpstreamAuthTypeGCPServiceAccount:
+		var cfg GCPServiceAccountConfig
+		if err := json.Unmarshal(configJSON, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid gcp_service_account auth config: %w", err)
+		}
+		p, err := NewGCPProvider(&cfg, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		source = p
+	case constants.UpstreamAuthTypeAzureServicePrincipal:
+		var cfg AzureServicePrincipalConfig
+		if err := json.Unmarshal(configJSON, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid azure_service_principal auth config: %w", err)
+		}
+		p, err := NewAzureProvider(&cfg, registryURL, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		source = p
+	default:
+		return nil, fmt.Errorf("unsupported auth type: %s", authType)
+	}
+
+	return NewCachingProvider(source, defaultRefreshWindow), nil
+}
+
+// IsCloudAuthType returns true if credentials of `authType` have to be exchanged
+// with a cloud provider before talking to the upstream.
+func IsCloudAuthType(authType string) bool {
+	return authType == constants.UpstreamAuthTypeAWSECR ||
+		authType == constants.UpstreamAuthTypeGCPServiceAccount ||
+		authType == constants.UpstreamAuthTypeAzureServicePrincipal
+}
+
+// CachingProvider caches the credential returned by source until it expires.
+// When a credential gets close to expiry (within refresh window), it will be
+// refreshed in the background while callers keep using the cached credential.
+type CachingProvider struct {
+	source        Provider
+	refreshWindow time.Duration
+
+	mu         sync.Mutex
+	cred       *Credential
+	refreshing bool
+
+	// fetchMu makes sure only one go-routine calls the source at a time.
+	fetchMu sync.Mutex
+}
+
+func NewCachingProvider(source Provider, refreshWindow time.Duration) *CachingProvider {
+	return &CachingProvider{
+		source:        source,
+		refreshWindow: refreshWindow,
+	}
+}
+
+func (c *CachingProvider) Retrieve(ctx context.Context) (*Credential, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	cred := c.cred
+	if cred != nil && !cred.expired(now) {
+		if cred.needsRefresh(now, c.refreshWindow) && !c.refreshing {
+			c.refreshing = true
+			go c.refresh()
+		}
+		c.mu.Unlock()
+		return cred, nil
+	}
+	c.mu.Unlock()
+
+	return c.fetch(ctx)
+}
+
+// Invalidate drops the cached credential. Next call to Retrieve will fetch a new one.
+func (c *CachingProvider) Invalidate() {
+	c.mu.Lock()
+	c.cred = nil
+	c.mu.Unlock()
+}
+
+func (c *CachingProvider) fetch(ctx context.Context) (*Credential, error) {
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+
+	// another go-routine may have fetched the credential while we were waiting.
+	c.mu.Lock()
+	cred := c.cred
+	c.mu.Unlock()
+	if cred != nil && !cred.needsRefresh(time.Now(), c.refreshWindow) {
+		return cred, nil
+	}
+
+	cred, err := c.source.Retrieve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.cred = cred
+	c.mu.Unlock()
+
+	return cred, nil
+}
+
+func (c *CachingProvider) refresh() {
+	defer func() {
+		c.mu.Lock()
+		c.refreshing = false
+		c.mu.Unlock()
+	}()
+
+	_, err := c.fetch(context.Background())
+	if err != nil {
+		log.Logger().Warn().Err(err).Msg("Failed to refresh upstream credentials. Cached credentials will be used until expiry")
+	}
+}
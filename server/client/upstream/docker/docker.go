@@ -2,14 +2,21 @@ package docker
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ksankeerth/open-image-registry/client/upstream"
+	"github.com/ksankeerth/open-image-registry/client/upstream/credentials"
+	"github.com/ksankeerth/open-image-registry/client/upstream/health"
+	clienterrors "github.com/ksankeerth/open-image-registry/errors/client"
 	"github.com/ksankeerth/open-image-registry/lib"
 	"github.com/ksankeerth/open-image-registry/log"
 )
@@ -17,6 +24,7 @@ import (
 const (
 	defaultRegistryURL = "https://registry-1.docker.io"
 	defaultTokenURL    = "https://auth.docker.io/token"
+	defaultService     = "registry.docker.io"
 )
 
 type Config struct {
@@ -26,16 +34,43 @@ type Config struct {
 	Username    string
 	Password    string
 
+	// Service is sent as `service` parameter in token requests. Defaults to
+	// registry.docker.io for DockerHub and host of RegistryURL for others.
+	Service string
+
+	// Credentials exchanges stored credentials of cloud registries(ECR, GCR, ACR)
+	// for registry credentials. If set, Username and Password are ignored.
+	Credentials credentials.Provider
+
+	// BasicAuth sends credentials with each request instead of fetching
+	// a bearer token from TokenURL. ECR requires this.
+	BasicAuth bool
+
 	ConnectionTimeout time.Duration
-	RequestTimeout    time.Duration
+	// ReadTimeout is the deadline of each attempt of a request, including reading the response body.
+	ReadTimeout time.Duration
+	// WriteTimeout is the deadline for sending a request and receiving headers of the response.
+	WriteTimeout time.Duration
 
 	MaxConnections     int
 	MaxIdleConnections int
 
+	// Transport is used to reach upstream if it is set. It carries outbound proxy and TLS settings
+	// of the upstream. Otherwise, a transport is created from ConnectionTimeout and connection limits.
+	Transport *http.Transport
+
 	MaxRetries             int
 	RetryDelay             time.Duration
 	RetryBackOffMultiplier float32
 
+	// Breaker rejects requests while upstream is unhealthy, so requests don't wait for
+	// timeouts and retries. Results of requests are recorded in the breaker.
+	Breaker *health.CircuitBreaker
+
+	// RateLimiter tracks quota reported by the upstream. Requests are not sent while the upstream rejects
+	// them with 429, so they are not retried until `Retry-After` elapses.
+	RateLimiter *health.RateLimiter
+
 	// debug configuration
 	LogHeaders bool
 	LogBody    bool
@@ -59,6 +94,18 @@ type dockerClient struct {
 	tokenCache *lib.Cache
 
 	httpClient *http.Client
+
+	// challengeMu guards challenge, which is discovered once from the upstream if TokenURL is not configured
+	challengeMu sync.Mutex
+	challenge   *authChallenge
+}
+
+// authChallenge is how the upstream asks clients to authenticate, from the `WWW-Authenticate` header of `/v2/`
+type authChallenge struct {
+	// scheme is "bearer", "basic", or empty if the upstream doesn't require authentication
+	scheme  string
+	realm   string
+	service string
 }
 
 func NewClient(cfg *Config) upstream.UpstreamClient {
@@ -70,14 +117,26 @@ func NewClient(cfg *Config) upstream.UpstreamClient {
 	if cfg.RegistryURL == "" {
 		cfg.RegistryURL = defaultRegistryURL
 	}
-	if cfg.TokenURL == "" {
+	// token endpoints of other registries are discovered from their `WWW-Authenticate` challenge
+	if cfg.TokenURL == "" && cfg.RegistryURL == defaultRegistryURL {
 		cfg.TokenURL = defaultTokenURL
 	}
+	if cfg.Service == "" {
+		cfg.Service = defaultService
+		if cfg.RegistryURL != defaultRegistryURL {
+			if u, err := url.Parse(cfg.RegistryURL); err == nil && u.Host != "" {
+				cfg.Service = u.Host
+			}
+		}
+	}
 	if cfg.ConnectionTimeout == 0 {
 		cfg.ConnectionTimeout = 10 * time.Second
 	}
-	if cfg.RequestTimeout == 0 {
-		cfg.RequestTimeout = 30 * time.Second
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = 30 * time.Second
+	}
+	if cfg.WriteTimeout == 0 {
+		cfg.WriteTimeout = 30 * time.Second
 	}
 	if cfg.MaxConnections == 0 {
 		cfg.MaxConnections = 100
@@ -95,19 +154,24 @@ func NewClient(cfg *Config) upstream.UpstreamClient {
 		cfg.RetryBackOffMultiplier = 2.0
 	}
 
+	transport := cfg.Transport
+	if transport == nil {
+		transport = &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout:   cfg.ConnectionTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConnsPerHost: cfg.MaxConnections,
+			MaxIdleConns:        cfg.MaxIdleConnections,
+		}
+	}
+
 	client := &dockerClient{
 		config:     cfg,
 		tokenCache: lib.NewCache(1 * time.Minute),
+		// deadlines are set on each attempt by send
 		httpClient: &http.Client{
-			Timeout: cfg.RequestTimeout,
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   cfg.ConnectionTimeout,
-					KeepAlive: 30 * time.Second,
-				}).DialContext,
-				MaxIdleConnsPerHost: cfg.MaxConnections,
-				MaxIdleConns:        cfg.MaxIdleConnections,
-			},
+			Transport: transport,
 		},
 	}
 
@@ -116,7 +180,7 @@ func NewClient(cfg *Config) upstream.UpstreamClient {
 	return client
 }
 
-func (d *dockerClient) GetManifest(namespace, repository, identifier string) (content []byte,
+func (d *dockerClient) GetManifest(ctx context.Context, namespace, repository, identifier string) (content []byte,
 	mediaType string, err error) {
 
 	log.Logger().Debug().
@@ -125,7 +189,7 @@ func (d *dockerClient) GetManifest(namespace, repository, identifier string) (co
 		Str("identifier", identifier).
 		Msg("Fetching manifest")
 
-	token, err := d.getToken(namespace, repository, "pull")
+	authorization, err := d.authorization(ctx, namespace, repository, "pull")
 	if err != nil {
 		log.Logger().Error().Err(err).
 			Str("namespace", namespace).
@@ -143,13 +207,13 @@ func (d *dockerClient) GetManifest(namespace, repository, identifier string) (co
 		return nil, "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.list.v2+json")
-	req.Header.Set("Accept", "application/vnd.oci.image.manifest.v1+json")
-	req.Header.Set("Accept", "application/vnd.oci.image.index.v1+json")
+	setAuthorization(req, authorization)
+	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.v2+json")
+	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.list.v2+json")
+	req.Header.Add("Accept", "application/vnd.oci.image.manifest.v1+json")
+	req.Header.Add("Accept", "application/vnd.oci.image.index.v1+json")
 
-	resp, err := d.doWithRetry(req)
+	resp, err := d.doWithRetry(ctx, req)
 	if err != nil {
 		log.Logger().Error().Err(err).
 			Str("url", url).
@@ -158,6 +222,14 @@ func (d *dockerClient) GetManifest(namespace, repository, identifier string) (co
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		log.Logger().Debug().
+			Str("url", url).
+			Msg("Manifest does not exist in upstream")
+		return nil, "", clienterrors.NewProxyClientError(url, fmt.Errorf("%w: %s/%s:%s", upstream.ErrManifestNotFound,
+			namespace, repository, identifier), clienterrors.CodeProxyArtifactNotFound)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		log.Logger().Error().
@@ -165,7 +237,7 @@ func (d *dockerClient) GetManifest(namespace, repository, identifier string) (co
 			Str("url", url).
 			Str("response_body", string(body)).
 			Msg("Unexpected status code while fetching manifest")
-		return nil, "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		return nil, "", clienterrors.ClassifyError(nil, url, resp)
 	}
 
 	content, err = io.ReadAll(resp.Body)
@@ -173,7 +245,7 @@ func (d *dockerClient) GetManifest(namespace, repository, identifier string) (co
 		log.Logger().Error().Err(err).
 			Str("url", url).
 			Msg("Failed to read manifest response body")
-		return nil, "", fmt.Errorf("failed to read response: %w", err)
+		return nil, "", fmt.Errorf("failed to read response: %w", clienterrors.ClassifyError(err, url, resp))
 	}
 
 	mediaType = resp.Header.Get("Content-Type")
@@ -196,20 +268,20 @@ func (d *dockerClient) GetManifest(namespace, repository, identifier string) (co
 	return content, mediaType, nil
 }
 
-func (d *dockerClient) HeadManifest(namespace, repository, identifier string) (exists bool, err error) {
+func (d *dockerClient) HeadManifest(ctx context.Context, namespace, repository, identifier string) (exists bool, digest string, err error) {
 	log.Logger().Debug().
 		Str("namespace", namespace).
 		Str("repository", repository).
 		Str("identifier", identifier).
 		Msg("Checking manifest existence")
 
-	token, err := d.getToken(namespace, repository, "pull")
+	authorization, err := d.authorization(ctx, namespace, repository, "pull")
 	if err != nil {
 		log.Logger().Error().Err(err).
 			Str("namespace", namespace).
 			Str("repository", repository).
 			Msg("Failed to get token for manifest check")
-		return false, fmt.Errorf("failed to get token: %w", err)
+		return false, "", fmt.Errorf("failed to get token: %w", err)
 	}
 
 	url := fmt.Sprintf("%s/v2/%s/%s/manifests/%s",
@@ -218,42 +290,58 @@ func (d *dockerClient) HeadManifest(namespace, repository, identifier string) (e
 	req, err := http.NewRequest(http.MethodHead, url, nil)
 	if err != nil {
 		log.Logger().Error().Err(err).Msgf("Failed to create request to %s", url)
-		return false, fmt.Errorf("failed to create request: %w", err)
+		return false, "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
+	setAuthorization(req, authorization)
+	// Same media types as GetManifest, so the digest is the digest of the manifest which GetManifest returns
+	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.v2+json")
+	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.list.v2+json")
+	req.Header.Add("Accept", "application/vnd.oci.image.manifest.v1+json")
+	req.Header.Add("Accept", "application/vnd.oci.image.index.v1+json")
 
-	resp, err := d.doWithRetry(req)
+	resp, err := d.doWithRetry(ctx, req)
 	if err != nil {
 		log.Logger().Error().Err(err).
 			Str("url", url).
 			Msg("Failed to check manifest existence")
-		return false, fmt.Errorf("failed to check manifest: %w", err)
+		return false, "", fmt.Errorf("failed to check manifest: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		log.Logger().Error().
+			Int("status_code", resp.StatusCode).
+			Str("url", url).
+			Msg("Unexpected status code while checking manifest existence")
+		return false, "", clienterrors.ClassifyError(nil, url, resp)
+	}
+
 	exists = resp.StatusCode == http.StatusOK
+	if exists {
+		digest = resp.Header.Get("Docker-Content-Digest")
+	}
 
 	log.Logger().Debug().
 		Str("namespace", namespace).
 		Str("repository", repository).
 		Str("identifier", identifier).
 		Bool("exists", exists).
+		Str("digest", digest).
 		Int("status_code", resp.StatusCode).
 		Msg("Manifest existence check completed")
 
-	return exists, nil
+	return exists, digest, nil
 }
 
-func (d *dockerClient) GetBlob(namespace, repository, digest string) (content []byte, err error) {
+func (d *dockerClient) GetBlob(ctx context.Context, namespace, repository, digest string) (content []byte, err error) {
 	log.Logger().Debug().
 		Str("namespace", namespace).
 		Str("repository", repository).
 		Str("digest", digest).
 		Msg("Fetching blob")
 
-	token, err := d.getToken(namespace, repository, "pull")
+	authorization, err := d.authorization(ctx, namespace, repository, "pull")
 	if err != nil {
 		log.Logger().Error().Err(err).
 			Str("namespace", namespace).
@@ -272,9 +360,9 @@ func (d *dockerClient) GetBlob(namespace, repository, digest string) (content []
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
+	setAuthorization(req, authorization)
 
-	resp, err := d.doWithRetry(req)
+	resp, err := d.doWithRetry(ctx, req)
 	if err != nil {
 		log.Logger().Error().Err(err).
 			Str("url", url).
@@ -290,13 +378,13 @@ func (d *dockerClient) GetBlob(namespace, repository, digest string) (content []
 			Str("url", url).
 			Str("response_body", string(body)).
 			Msg("Unexpected status code while fetching blob")
-		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		return nil, clienterrors.ClassifyError(nil, url, resp)
 	}
 
 	content, err = io.ReadAll(resp.Body)
 	if err != nil {
 		log.Logger().Error().Err(err).Msgf("Failed to read blob response from upstream: %s", url)
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", clienterrors.ClassifyError(err, url, resp))
 	}
 
 	if d.config.LogBody {
@@ -313,14 +401,14 @@ func (d *dockerClient) GetBlob(namespace, repository, digest string) (content []
 	return content, nil
 }
 
-func (d *dockerClient) HeadBlob(namespace, repository, digest string) (exists bool, err error) {
+func (d *dockerClient) HeadBlob(ctx context.Context, namespace, repository, digest string) (exists bool, err error) {
 	log.Logger().Debug().
 		Str("namespace", namespace).
 		Str("repository", repository).
 		Str("digest", digest).
 		Msg("Checking blob existence")
 
-	token, err := d.getToken(namespace, repository, "pull")
+	authorization, err := d.authorization(ctx, namespace, repository, "pull")
 	if err != nil {
 		log.Logger().Error().Err(err).
 			Str("namespace", namespace).
@@ -339,9 +427,9 @@ func (d *dockerClient) HeadBlob(namespace, repository, digest string) (exists bo
 		return false, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
+	setAuthorization(req, authorization)
 
-	resp, err := d.doWithRetry(req)
+	resp, err := d.doWithRetry(ctx, req)
 	if err != nil {
 		log.Logger().Error().Err(err).
 			Str("url", url).
@@ -350,6 +438,14 @@ func (d *dockerClient) HeadBlob(namespace, repository, digest string) (exists bo
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		log.Logger().Error().
+			Int("status_code", resp.StatusCode).
+			Str("url", url).
+			Msg("Unexpected status code while checking blob existence")
+		return false, clienterrors.ClassifyError(nil, url, resp)
+	}
+
 	exists = resp.StatusCode == http.StatusOK
 
 	log.Logger().Debug().
@@ -363,7 +459,239 @@ func (d *dockerClient) HeadBlob(namespace, repository, digest string) (exists bo
 	return exists, nil
 }
 
-func (d *dockerClient) getToken(namespace, repository, scope string) (string, error) {
+// tagListResponse is the body of tag list responses of the distribution API.
+type tagListResponse struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+func (d *dockerClient) ListTags(ctx context.Context, namespace, repository string) (tags []string, err error) {
+	log.Logger().Debug().
+		Str("namespace", namespace).
+		Str("repository", repository).
+		Msg("Listing tags")
+
+	authorization, err := d.authorization(ctx, namespace, repository, "pull")
+	if err != nil {
+		log.Logger().Error().Err(err).
+			Str("namespace", namespace).
+			Str("repository", repository).
+			Msg("Failed to get token for tag list")
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/%s/%s/tags/list", d.config.RegistryURL, namespace, repository)
+
+	for url != "" {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Failed to create tag list request to %s", url)
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		setAuthorization(req, authorization)
+
+		resp, err := d.doWithRetry(ctx, req)
+		if err != nil {
+			log.Logger().Error().Err(err).
+				Str("url", url).
+				Msg("Failed to list tags from upstream")
+			return nil, fmt.Errorf("failed to list tags: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			log.Logger().Error().
+				Int("status_code", resp.StatusCode).
+				Str("url", url).
+				Str("response_body", string(body)).
+				Msg("Unexpected status code while listing tags")
+			return nil, clienterrors.ClassifyError(nil, url, resp)
+		}
+
+		var page tagListResponse
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Failed to decode tag list response from %s", url)
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		tags = append(tags, page.Tags...)
+		url = d.nextPageURL(resp.Header.Get("Link"))
+	}
+
+	log.Logger().Debug().
+		Str("namespace", namespace).
+		Str("repository", repository).
+		Int("tags", len(tags)).
+		Msg("Tags listed successfully")
+
+	return tags, nil
+}
+
+// nextPageURL returns the URL of next page from `Link` header. e.g. </v2/library/alpine/tags/list?n=100&last=3.19>;
+// rel="next". Empty string is returned if there is no next page.
+func (d *dockerClient) nextPageURL(link string) string {
+	target, params, found := strings.Cut(link, ";")
+	if !found || !strings.Contains(params, `rel="next"`) {
+		return ""
+	}
+
+	target = strings.Trim(strings.TrimSpace(target), "<>")
+	base, err := url.Parse(d.config.RegistryURL)
+	if err != nil {
+		return ""
+	}
+	ref, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// authorization returns the value of `Authorization` header for requests to upstream. It is empty if the upstream
+// doesn't require authentication.
+func (d *dockerClient) authorization(ctx context.Context, namespace, repository, scope string) (string, error) {
+	if !d.config.BasicAuth {
+		challenge, err := d.authChallenge(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		switch challenge.scheme {
+		case "bearer":
+			token, err := d.getToken(ctx, challenge, namespace, repository, scope)
+			if err != nil {
+				return "", err
+			}
+			return "Bearer " + token, nil
+		case "":
+			return "", nil
+		}
+	}
+
+	username, password, err := d.credentials(ctx)
+	if err != nil {
+		return "", err
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+}
+
+func setAuthorization(req *http.Request, authorization string) {
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+}
+
+// credentials returns username and password to authenticate with upstream.
+func (d *dockerClient) credentials(ctx context.Context) (username, password string, err error) {
+	if d.config.Credentials == nil {
+		return d.config.Username, d.config.Password, nil
+	}
+
+	cred, err := d.config.Credentials.Retrieve(ctx)
+	if err != nil {
+		log.Logger().Error().Err(err).Str("registry_url", d.config.RegistryURL).
+			Msg("Failed to retrieve upstream credentials")
+		return "", "", fmt.Errorf("failed to retrieve credentials: %w", err)
+	}
+	return cred.Username, cred.Password, nil
+}
+
+// authChallenge returns how to authenticate with the upstream. The configured token endpoint is used if it is set.
+// Otherwise, the challenge is requested from `/v2/` of the upstream and kept for later requests.
+func (d *dockerClient) authChallenge(ctx context.Context) (*authChallenge, error) {
+	if d.config.TokenURL != "" {
+		return &authChallenge{scheme: "bearer", realm: d.config.TokenURL, service: d.config.Service}, nil
+	}
+
+	d.challengeMu.Lock()
+	defer d.challengeMu.Unlock()
+
+	if d.challenge != nil {
+		return d.challenge, nil
+	}
+
+	if d.config.Breaker != nil && d.config.Breaker.IsOpen() {
+		return nil, health.ErrCircuitOpen
+	}
+
+	url := d.config.RegistryURL + "/v2/"
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to create request to %s", url)
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := d.send(ctx, req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to request auth challenge from %s", url)
+		return nil, fmt.Errorf("failed to request auth challenge: %w", clienterrors.ClassifyError(err, url, nil))
+	}
+	defer resp.Body.Close()
+
+	challenge := &authChallenge{}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// upstream allows anonymous access
+	case http.StatusUnauthorized:
+		challenge = parseAuthChallenge(resp.Header.Get("WWW-Authenticate"))
+		if challenge.scheme == "bearer" && challenge.realm == "" {
+			return nil, fmt.Errorf("auth challenge of %s does not have a realm", url)
+		}
+		if challenge.scheme != "bearer" && challenge.scheme != "basic" {
+			return nil, fmt.Errorf("unsupported auth challenge from %s: %q", url, resp.Header.Get("WWW-Authenticate"))
+		}
+		if challenge.service == "" {
+			challenge.service = d.config.Service
+		}
+	default:
+		return nil, clienterrors.ProxyUnexpectedStatusError(http.StatusUnauthorized, resp.StatusCode, url)
+	}
+
+	log.Logger().Debug().
+		Str("registry_url", d.config.RegistryURL).
+		Str("scheme", challenge.scheme).
+		Str("realm", challenge.realm).
+		Msg("Auth challenge discovered")
+
+	d.challenge = challenge
+	return challenge, nil
+}
+
+// parseAuthChallenge parses a `WWW-Authenticate` header. e.g. Bearer realm="https://auth.docker.io/token",
+// service="registry.docker.io"
+func parseAuthChallenge(header string) *authChallenge {
+	scheme, params, _ := strings.Cut(strings.TrimSpace(header), " ")
+	challenge := &authChallenge{scheme: strings.ToLower(scheme)}
+
+	for params != "" {
+		var key, value string
+		key, params, _ = strings.Cut(strings.TrimLeft(params, " ,"), "=")
+		if strings.HasPrefix(params, `"`) {
+			value, params, _ = strings.Cut(params[1:], `"`)
+		} else {
+			value, params, _ = strings.Cut(params, ",")
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "realm":
+			challenge.realm = value
+		case "service":
+			challenge.service = value
+		}
+	}
+	return challenge
+}
+
+func (d *dockerClient) getToken(ctx context.Context, challenge *authChallenge, namespace, repository,
+	scope string) (string, error) {
+	if d.config.Breaker != nil && d.config.Breaker.IsOpen() {
+		return "", health.ErrCircuitOpen
+	}
+
 	cacheKey := fmt.Sprintf("token:%s:%s:%s", namespace, repository, scope)
 
 	if token := d.tokenCache.Get(cacheKey); token != "" {
@@ -381,28 +709,33 @@ func (d *dockerClient) getToken(namespace, repository, scope string) (string, er
 		Str("scope", scope).
 		Msg("Fetching new token from auth server")
 
-	url := fmt.Sprintf("%s?service=registry.docker.io&scope=repository:%s/%s:%s", d.config.TokenURL,
-		namespace, repository, scope)
+	tokenURL := fmt.Sprintf("%s?service=%s&scope=repository:%s/%s:%s", challenge.realm,
+		url.QueryEscape(challenge.service), namespace, repository, scope)
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequest(http.MethodGet, tokenURL, nil)
 	if err != nil {
-		log.Logger().Error().Err(err).Msgf("Failed to create token request to %s", url)
+		log.Logger().Error().Err(err).Msgf("Failed to create token request to %s", tokenURL)
 		return "", fmt.Errorf("failed to create token request: %w", err)
 	}
 
-	if d.config.Username != "" && d.config.Password != "" {
-		req.SetBasicAuth(d.config.Username, d.config.Password)
-		log.Logger().Debug().Str("username", d.config.Username).Msg("Using authenticated token request")
+	username, password, err := d.credentials(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if username != "" && password != "" {
+		req.SetBasicAuth(username, password)
+		log.Logger().Debug().Str("username", username).Msg("Using authenticated token request")
 	} else {
 		log.Logger().Debug().Msg("Using anonymous token request")
 	}
 
 	timeStart := time.Now()
 
-	resp, err := d.httpClient.Do(req)
+	resp, err := d.send(ctx, req)
 	if err != nil {
-		log.Logger().Error().Err(err).Msgf("Failed to fetch token from %s", url)
-		return "", fmt.Errorf("failed to fetch token: %w", err)
+		log.Logger().Error().Err(err).Msgf("Failed to fetch token from %s", tokenURL)
+		return "", fmt.Errorf("failed to fetch token: %w", clienterrors.ClassifyError(err, tokenURL, nil))
 	}
 	defer resp.Body.Close()
 
@@ -410,15 +743,19 @@ func (d *dockerClient) getToken(namespace, repository, scope string) (string, er
 		body, _ := io.ReadAll(resp.Body)
 		log.Logger().Error().
 			Int("status_code", resp.StatusCode).
-			Str("url", url).
+			Str("url", tokenURL).
 			Str("response_body", string(body)).
 			Msg("Token request failed")
-		return "", fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, string(body))
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return "", fmt.Errorf("token request failed: %w", clienterrors.ClassifyError(nil, tokenURL, resp))
+		}
+		return "", fmt.Errorf("token request failed: %w", clienterrors.ProxyUnexpectedStatusError(http.StatusOK,
+			resp.StatusCode, tokenURL))
 	}
 
 	var loginResp loginResponse
 	if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
-		log.Logger().Error().Err(err).Msgf("Failed to decode token response from %s", url)
+		log.Logger().Error().Err(err).Msgf("Failed to decode token response from %s", tokenURL)
 		return "", fmt.Errorf("failed to decode token response: %w", err)
 	}
 
@@ -446,7 +783,7 @@ func (d *dockerClient) getToken(namespace, repository, scope string) (string, er
 	return token, nil
 }
 
-func (d *dockerClient) doWithRetry(req *http.Request) (*http.Response, error) {
+func (d *dockerClient) doWithRetry(ctx context.Context, req *http.Request) (*http.Response, error) {
 	var resp *http.Response
 	var err error
 
@@ -459,13 +796,54 @@ func (d *dockerClient) doWithRetry(req *http.Request) (*http.Response, error) {
 				Dur("delay", delay).
 				Str("url", req.URL.String()).
 				Msg("Retrying request")
-			time.Sleep(delay)
-			delay = time.Duration((float32(delay) * d.config.RetryBackOffMultiplier) * float32(time.Second))
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+			delay = time.Duration(float32(delay) * d.config.RetryBackOffMultiplier)
+		}
+
+		if d.config.RateLimiter != nil {
+			if limitErr := d.config.RateLimiter.Allow(); limitErr != nil {
+				log.Logger().Warn().Err(limitErr).
+					Str("url", req.URL.String()).
+					Msg("Upstream rate limit is exceeded, request is not sent")
+				return nil, limitErr
+			}
+		}
+
+		// checked right before sending, since the request may be the probe of a half-open circuit
+		if d.config.Breaker != nil && !d.config.Breaker.Allow() {
+			log.Logger().Warn().
+				Str("url", req.URL.String()).
+				Msg("Upstream is unhealthy, request is not sent")
+			return nil, health.ErrCircuitOpen
 		}
 
-		reqClone := req.Clone(context.Background())
+		start := time.Now()
+		resp, err = d.send(ctx, req)
+		if err != nil && ctx.Err() != nil {
+			if d.config.Breaker != nil {
+				d.config.Breaker.Cancel()
+			}
+			// cancelled by the caller, e.g. the client disconnected. Upstream is not at fault.
+			log.Logger().Debug().Err(err).
+				Str("url", req.URL.String()).
+				Msg("Request is cancelled")
+			return nil, ctx.Err()
+		}
+		d.recordResult(resp, err, time.Since(start))
+
+		if limitErr := d.recordRateLimit(resp); limitErr != nil {
+			resp.Body.Close()
+			log.Logger().Warn().Err(limitErr).
+				Str("url", req.URL.String()).
+				Msg("Upstream rejected the request due to rate limits, not retrying")
+			return nil, limitErr
+		}
 
-		resp, err = d.httpClient.Do(reqClone)
 		if err == nil && resp.StatusCode < 500 {
 			return resp, nil
 		}
@@ -502,7 +880,7 @@ func (d *dockerClient) doWithRetry(req *http.Request) (*http.Response, error) {
 			Str("url", req.URL.String()).
 			Int("max_retries", d.config.MaxRetries).
 			Msg("Request failed after max retries")
-		return nil, fmt.Errorf("max retries exceeded: %w", err)
+		return nil, fmt.Errorf("max retries exceeded: %w", clienterrors.ClassifyError(err, req.URL.String(), nil))
 	}
 
 	log.Logger().Error().
@@ -511,4 +889,68 @@ func (d *dockerClient) doWithRetry(req *http.Request) (*http.Response, error) {
 		Msg("Request failed after max retries with no error")
 
 	return resp, nil
-}
\ No newline at end of file
+}
+
+// send sends a single attempt of the request. ReadTimeout bounds the whole attempt and WriteTimeout bounds sending
+// the request until headers of the response are received. The deadline is released when the body of the response
+// is closed.
+func (d *dockerClient) send(ctx context.Context, req *http.Request) (*http.Response, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, d.config.ReadTimeout)
+	writeTimer := time.AfterFunc(d.config.WriteTimeout, cancel)
+
+	resp, err := d.httpClient.Do(req.Clone(attemptCtx))
+	writeTimer.Stop()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases the deadline of a request once its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// recordRateLimit records quota reported by the upstream. An error is returned if the upstream rejected
+// the request with 429.
+func (d *dockerClient) recordRateLimit(resp *http.Response) error {
+	if resp == nil {
+		return nil
+	}
+
+	if d.config.RateLimiter == nil {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return health.ErrRateLimited
+		}
+		return nil
+	}
+	return d.config.RateLimiter.Record(resp.StatusCode, resp.Header)
+}
+
+// recordResult records the result of a request in the breaker. Server errors and network errors
+// are considered as failures.
+func (d *dockerClient) recordResult(resp *http.Response, err error, latency time.Duration) {
+	if d.config.Breaker == nil {
+		return
+	}
+
+	if err != nil {
+		d.config.Breaker.RecordFailure(err, latency)
+		return
+	}
+	if resp.StatusCode >= 500 {
+		d.config.Breaker.RecordFailure(fmt.Errorf("upstream returned status %d", resp.StatusCode), latency)
+		return
+	}
+	d.config.Breaker.RecordSuccess(latency)
+}
@@ -0,0 +1,189 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/client/upstream/health"
+	clienterrors "github.com/ksankeerth/open-image-registry/errors/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[]}`
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*dockerClient, *health.CircuitBreaker) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	breaker := health.NewCircuitBreaker(5, time.Minute)
+	client := NewClient(&Config{
+		RegistryURL:  server.URL,
+		BasicAuth:    true,
+		Username:     "puller",
+		Password:     "secret",
+		ReadTimeout:  500 * time.Millisecond,
+		WriteTimeout: 200 * time.Millisecond,
+		MaxRetries:   1,
+		RetryDelay:   10 * time.Millisecond,
+		Breaker:      breaker,
+	})
+	return client.(*dockerClient), breaker
+}
+
+func TestClientDeadlines(t *testing.T) {
+	t.Run("Manifest is fetched within deadlines", func(t *testing.T) {
+		client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Write([]byte(testManifest))
+		})
+
+		content, _, err := client.GetManifest(context.Background(), "team", "app", "latest")
+		require.NoError(t, err)
+		assert.Equal(t, testManifest, string(content))
+	})
+
+	t.Run("Write timeout aborts requests waiting for response headers", func(t *testing.T) {
+		client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		})
+
+		start := time.Now()
+		_, _, err := client.GetManifest(context.Background(), "team", "app", "latest")
+		require.Error(t, err)
+		assert.True(t, clienterrors.IsProxyConnectionFailed(err))
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("Read timeout aborts slow responses", func(t *testing.T) {
+		client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+		})
+
+		start := time.Now()
+		_, err := client.GetBlob(context.Background(), "team", "app", "sha256:abc")
+		require.Error(t, err)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("Cancelled requests are not recorded as failures", func(t *testing.T) {
+		client, breaker := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		_, _, err := client.HeadManifest(ctx, "team", "app", "latest")
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Zero(t, breaker.Status().ConsecutiveFailures)
+	})
+}
+
+func TestAuthChallenge(t *testing.T) {
+	t.Run("Token endpoint is discovered from challenge", func(t *testing.T) {
+		var server *httptest.Server
+		var challenges, tokenRequests int
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v2/":
+				challenges++
+				w.Header().Set("WWW-Authenticate",
+					fmt.Sprintf(`Bearer realm="%s/oauth2/token",service="test.registry.io"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+			case "/oauth2/token":
+				tokenRequests++
+				username, password, _ := r.BasicAuth()
+				assert.Equal(t, "puller", username)
+				assert.Equal(t, "secret", password)
+				assert.Equal(t, "test.registry.io", r.URL.Query().Get("service"))
+				assert.Equal(t, "repository:team/app:pull", r.URL.Query().Get("scope"))
+				w.Write([]byte(`{"token":"registry-token","expires_in":300}`))
+			default:
+				if r.Header.Get("Authorization") != "Bearer registry-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+				w.Write([]byte(testManifest))
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		client := NewClient(&Config{RegistryURL: server.URL, Username: "puller", Password: "secret"})
+
+		for range 2 {
+			content, _, err := client.GetManifest(context.Background(), "team", "app", "latest")
+			require.NoError(t, err)
+			assert.Equal(t, testManifest, string(content))
+		}
+		assert.Equal(t, 1, challenges)
+		assert.Equal(t, 1, tokenRequests)
+	})
+
+	t.Run("Anonymous upstream", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Empty(t, r.Header.Get("Authorization"))
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Write([]byte(testManifest))
+		}))
+		t.Cleanup(server.Close)
+
+		client := NewClient(&Config{RegistryURL: server.URL})
+		content, _, err := client.GetManifest(context.Background(), "team", "app", "latest")
+		require.NoError(t, err)
+		assert.Equal(t, testManifest, string(content))
+	})
+
+	t.Run("Basic challenge", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			if !ok || username != "puller" || password != "secret" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Write([]byte(testManifest))
+		}))
+		t.Cleanup(server.Close)
+
+		client := NewClient(&Config{RegistryURL: server.URL, Username: "puller", Password: "secret"})
+		content, _, err := client.GetManifest(context.Background(), "team", "app", "latest")
+		require.NoError(t, err)
+		assert.Equal(t, testManifest, string(content))
+	})
+
+	t.Run("DockerHub uses its token endpoint", func(t *testing.T) {
+		client := NewClient(&Config{}).(*dockerClient)
+		challenge, err := client.authChallenge(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, defaultTokenURL, challenge.realm)
+		assert.Equal(t, defaultService, challenge.service)
+	})
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	challenge := parseAuthChallenge(`Bearer realm="https://gcr.io/v2/token",service="gcr.io",scope="a,b"`)
+	assert.Equal(t, "bearer", challenge.scheme)
+	assert.Equal(t, "https://gcr.io/v2/token", challenge.realm)
+	assert.Equal(t, "gcr.io", challenge.service)
+
+	challenge = parseAuthChallenge(`Basic realm=registry`)
+	assert.Equal(t, "basic", challenge.scheme)
+	assert.Equal(t, "registry", challenge.realm)
+}
@@ -0,0 +1,186 @@
+package health
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+)
+
+// ErrCircuitOpen is returned instead of calling the upstream while it is considered unhealthy.
+var ErrCircuitOpen = errors.New("upstream is unavailable: circuit is open")
+
+// Status is a snapshot of the health of an upstream registry.
+type Status struct {
+	State               string
+	CircuitState        string
+	LastError           string
+	Latency             time.Duration
+	ConsecutiveFailures int
+	LastCheckedAt       time.Time
+	LastHealthyAt       time.Time
+	RateLimit           RateLimitStatus
+}
+
+// CircuitBreaker tracks the outcome of calls to an upstream registry.
+//
+// The circuit opens after `failureThreshold` consecutive failures. While it is open, calls are
+// rejected with ErrCircuitOpen. After `openDuration`, the circuit becomes half-open and a single
+// call is allowed as a probe; its success closes the circuit and its failure opens it again. Other
+// calls are rejected until the probe completes. A probe which doesn't complete within `openDuration`
+// is abandoned and another call is allowed.
+type CircuitBreaker struct {
+	failureThreshold int
+	openDuration     time.Duration
+
+	mu            sync.Mutex
+	state         string
+	failures      int
+	openedAt      time.Time
+	probing       bool
+	probeAt       time.Time
+	lastErr       string
+	latency       time.Duration
+	lastCheckedAt time.Time
+	lastHealthyAt time.Time
+	now           func() time.Time
+}
+
+func NewCircuitBreaker(failureThreshold int, openDuration time.Duration) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = constants.DefaultUpstreamFailureThreshold
+	}
+	if openDuration <= 0 {
+		openDuration = constants.DefaultUpstreamCircuitOpenDuration * time.Second
+	}
+
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+		state:            constants.CircuitStateClosed,
+		now:              time.Now,
+	}
+}
+
+// Allow reports whether a call to the upstream can be made. In half-open state, the allowed call is
+// the probe and its result must be recorded.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case constants.CircuitStateOpen:
+		return false
+	case constants.CircuitStateHalfOpen:
+		if b.isProbing() {
+			return false
+		}
+		b.probing = true
+		b.probeAt = b.now()
+		return true
+	default:
+		return true
+	}
+}
+
+// IsOpen reports whether calls to the upstream are rejected at the moment. Unlike Allow, it doesn't
+// start a probe in half-open state.
+func (b *CircuitBreaker) IsOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case constants.CircuitStateOpen:
+		return true
+	case constants.CircuitStateHalfOpen:
+		return b.isProbing()
+	default:
+		return false
+	}
+}
+
+// Cancel releases the probe of half-open state when the allowed call is cancelled, so another call
+// can be the probe.
+func (b *CircuitBreaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// RecordSuccess closes the circuit.
+func (b *CircuitBreaker) RecordSuccess(latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.state = constants.CircuitStateClosed
+	b.probing = false
+	b.failures = 0
+	b.lastErr = ""
+	b.latency = latency
+	b.lastCheckedAt = now
+	b.lastHealthyAt = now
+}
+
+// RecordFailure opens the circuit once the failure threshold is reached. A failure in half-open
+// state opens the circuit immediately.
+func (b *CircuitBreaker) RecordFailure(err error, latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	state := b.currentState()
+
+	b.failures++
+	b.latency = latency
+	b.lastCheckedAt = now
+	if err != nil {
+		b.lastErr = err.Error()
+	}
+
+	if state == constants.CircuitStateHalfOpen || b.failures >= b.failureThreshold {
+		b.state = constants.CircuitStateOpen
+		b.openedAt = now
+		b.probing = false
+	}
+}
+
+func (b *CircuitBreaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := constants.UpstreamHealthUnknown
+	switch {
+	case b.lastCheckedAt.IsZero():
+	case b.failures == 0:
+		state = constants.UpstreamHealthHealthy
+	default:
+		state = constants.UpstreamHealthUnhealthy
+	}
+
+	return Status{
+		State:               state,
+		CircuitState:        b.currentState(),
+		LastError:           b.lastErr,
+		Latency:             b.latency,
+		ConsecutiveFailures: b.failures,
+		LastCheckedAt:       b.lastCheckedAt,
+		LastHealthyAt:       b.lastHealthyAt,
+	}
+}
+
+// isProbing reports whether a probe is in progress in half-open state. It must be called while
+// holding the lock.
+func (b *CircuitBreaker) isProbing() bool {
+	return b.probing && b.now().Before(b.probeAt.Add(b.openDuration))
+}
+
+// currentState must be called while holding the lock.
+func (b *CircuitBreaker) currentState() string {
+	if b.state == constants.CircuitStateOpen && !b.now().Before(b.openedAt.Add(b.openDuration)) {
+		b.state = constants.CircuitStateHalfOpen
+	}
+	return b.state
+}
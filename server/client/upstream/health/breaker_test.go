@@ -0,0 +1,184 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBreaker(now *time.Time) *CircuitBreaker {
+	b := NewCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return *now }
+	return b
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+
+	t.Run("Unknown until first result", func(t *testing.T) {
+		b := newTestBreaker(&now)
+
+		status := b.Status()
+		assert.Equal(t, constants.UpstreamHealthUnknown, status.State)
+		assert.Equal(t, constants.CircuitStateClosed, status.CircuitState)
+		assert.True(t, b.Allow())
+	})
+
+	t.Run("Opens after failure threshold", func(t *testing.T) {
+		b := newTestBreaker(&now)
+
+		b.RecordFailure(errors.New("connection refused"), time.Millisecond)
+		assert.True(t, b.Allow())
+		assert.Equal(t, constants.UpstreamHealthUnhealthy, b.Status().State)
+
+		b.RecordFailure(errors.New("connection refused"), time.Millisecond)
+		assert.False(t, b.Allow())
+
+		status := b.Status()
+		assert.Equal(t, constants.CircuitStateOpen, status.CircuitState)
+		assert.Equal(t, "connection refused", status.LastError)
+		assert.Equal(t, 2, status.ConsecutiveFailures)
+	})
+
+	t.Run("Half-open after open duration", func(t *testing.T) {
+		current := now
+		b := newTestBreaker(&current)
+
+		b.RecordFailure(errors.New("timeout"), 0)
+		b.RecordFailure(errors.New("timeout"), 0)
+		require.False(t, b.Allow())
+
+		current = current.Add(time.Minute)
+		assert.True(t, b.Allow())
+		assert.Equal(t, constants.CircuitStateHalfOpen, b.Status().CircuitState)
+
+		// single failure in half-open state opens the circuit again
+		b.RecordFailure(errors.New("timeout"), 0)
+		assert.False(t, b.Allow())
+	})
+
+	t.Run("Half-open allows a single probe", func(t *testing.T) {
+		current := now
+		b := newTestBreaker(&current)
+
+		b.RecordFailure(errors.New("timeout"), 0)
+		b.RecordFailure(errors.New("timeout"), 0)
+		current = current.Add(time.Minute)
+
+		assert.False(t, b.IsOpen(), "checking the circuit must not start the probe")
+		require.True(t, b.Allow())
+		assert.False(t, b.Allow(), "only the probe is allowed while it is in progress")
+		assert.True(t, b.IsOpen())
+
+		// a probe which never completes is abandoned after open duration
+		current = current.Add(time.Minute)
+		require.True(t, b.Allow())
+		assert.False(t, b.Allow())
+
+		b.Cancel()
+		require.True(t, b.Allow())
+		assert.False(t, b.Allow())
+
+		b.RecordSuccess(0)
+		assert.True(t, b.Allow())
+		assert.True(t, b.Allow())
+		assert.Equal(t, constants.CircuitStateClosed, b.Status().CircuitState)
+	})
+
+	t.Run("Concurrent calls in half-open state", func(t *testing.T) {
+		current := now
+		b := newTestBreaker(&current)
+
+		b.RecordFailure(errors.New("timeout"), 0)
+		b.RecordFailure(errors.New("timeout"), 0)
+		current = current.Add(time.Minute)
+
+		var allowed atomic.Int32
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if b.Allow() {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), allowed.Load())
+
+		// a failed probe opens the circuit again
+		b.RecordFailure(errors.New("timeout"), 0)
+		assert.False(t, b.Allow())
+		assert.Equal(t, constants.CircuitStateOpen, b.Status().CircuitState)
+	})
+
+	t.Run("Success closes the circuit", func(t *testing.T) {
+		current := now
+		b := newTestBreaker(&current)
+
+		b.RecordFailure(errors.New("timeout"), 0)
+		b.RecordFailure(errors.New("timeout"), 0)
+		require.False(t, b.Allow())
+
+		b.RecordSuccess(20 * time.Millisecond)
+
+		status := b.Status()
+		assert.True(t, b.Allow())
+		assert.Equal(t, constants.UpstreamHealthHealthy, status.State)
+		assert.Equal(t, constants.CircuitStateClosed, status.CircuitState)
+		assert.Empty(t, status.LastError)
+		assert.Equal(t, 20*time.Millisecond, status.Latency)
+		assert.Equal(t, current, status.LastHealthyAt)
+	})
+}
+
+func TestMonitorProbe(t *testing.T) {
+	statusCode := http.StatusUnauthorized
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/", r.URL.Path)
+		w.WriteHeader(statusCode)
+	}))
+	defer server.Close()
+
+	m := NewMonitor(config.UpstreamHealthCheckConfig{
+		Enabled:             true,
+		IntervalSeconds:     60,
+		TimeoutSeconds:      1,
+		FailureThreshold:    1,
+		OpenDurationSeconds: 60,
+	}, config.UpstreamRateLimitConfig{})
+	b := m.Breaker("reg1")
+	client := m.newProbeClient(nil)
+
+	t.Run("Unauthorized response is considered healthy", func(t *testing.T) {
+		m.probe(t.Context(), client, "reg1", server.URL, b)
+		assert.Equal(t, constants.UpstreamHealthHealthy, b.Status().State)
+	})
+
+	t.Run("Server error opens the circuit", func(t *testing.T) {
+		statusCode = http.StatusServiceUnavailable
+		m.probe(t.Context(), client, "reg1", server.URL, b)
+
+		status, ok := m.Status("reg1")
+		require.True(t, ok)
+		assert.Equal(t, constants.UpstreamHealthUnhealthy, status.State)
+		assert.Equal(t, constants.CircuitStateOpen, status.CircuitState)
+		assert.Contains(t, status.LastError, "503")
+	})
+
+	t.Run("Unwatched upstream has no status", func(t *testing.T) {
+		m.Unwatch("reg1")
+		_, ok := m.Status("reg1")
+		assert.False(t, ok)
+	})
+}
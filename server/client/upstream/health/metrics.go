@@ -0,0 +1,85 @@
+package health
+
+import (
+	"fmt"
+	"io"
+	"slices"
+)
+
+type gauge struct {
+	name  string
+	help  string
+	value func(status RateLimitStatus) (float64, bool)
+}
+
+var rateLimitGauges = []gauge{
+	{
+		name: "oir_upstream_rate_limit_limit",
+		help: "Requests allowed by the upstream within the rate limit window.",
+		value: func(s RateLimitStatus) (float64, bool) {
+			return float64(s.Limit), s.Known
+		},
+	},
+	{
+		name: "oir_upstream_rate_limit_remaining",
+		help: "Requests remaining within the rate limit window of the upstream.",
+		value: func(s RateLimitStatus) (float64, bool) {
+			return float64(s.Remaining), s.Known
+		},
+	},
+	{
+		name: "oir_upstream_rate_limit_low",
+		help: "Whether remaining quota of the upstream is at or below the low quota threshold.",
+		value: func(s RateLimitStatus) (float64, bool) {
+			return boolValue(s.Low), true
+		},
+	},
+	{
+		name: "oir_upstream_rate_limited",
+		help: "Whether requests to the upstream are held back until Retry-After elapses.",
+		value: func(s RateLimitStatus) (float64, bool) {
+			return boolValue(s.Limited), true
+		},
+	},
+}
+
+// WriteMetrics writes quota of monitored upstreams in Prometheus text format. Limit and remaining quota
+// are written only for upstreams which reported them.
+func (m *Monitor) WriteMetrics(w io.Writer) error {
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.upstreams))
+	statuses := make(map[string]RateLimitStatus, len(m.upstreams))
+	for id, u := range m.upstreams {
+		ids = append(ids, id)
+		statuses[id] = u.rateLimiter.Status()
+	}
+	m.mu.Unlock()
+
+	slices.Sort(ids)
+
+	for _, g := range rateLimitGauges {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			value, ok := g.value(statuses[id])
+			if !ok {
+				continue
+			}
+			_, err = fmt.Fprintf(w, "%s{upstream_id=%q} %g\n", g.name, id, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,195 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/log"
+)
+
+var once sync.Once
+
+var monitor *Monitor
+
+// Monitor probes `/v2/` endpoint of each upstream registry periodically and keeps a CircuitBreaker
+// per upstream. The same breaker is shared with the client of the upstream, so results of proxied
+// requests and probes are both taken into account. Quota of each upstream is tracked by a RateLimiter
+// which is shared with the client in the same way.
+type Monitor struct {
+	cfg          config.UpstreamHealthCheckConfig
+	rateLimitCfg config.UpstreamRateLimitConfig
+	upstreams    map[string]*watchedUpstream
+	mu           sync.Mutex
+}
+
+type watchedUpstream struct {
+	breaker     *CircuitBreaker
+	rateLimiter *RateLimiter
+	cancel      context.CancelFunc
+}
+
+func GetMonitor() *Monitor {
+	once.Do(func() {
+		monitor = NewMonitor(config.GetUpstreamHealthCheckConfig(), config.GetUpstreamRateLimitConfig())
+	})
+	return monitor
+}
+
+func NewMonitor(cfg config.UpstreamHealthCheckConfig, rateLimitCfg config.UpstreamRateLimitConfig) *Monitor {
+	return &Monitor{
+		cfg:          cfg,
+		rateLimitCfg: rateLimitCfg,
+		upstreams:    make(map[string]*watchedUpstream),
+	}
+}
+
+// Breaker returns the circuit breaker of the upstream. It is created if it does not exist.
+func (m *Monitor) Breaker(regID string) *CircuitBreaker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.getOrCreate(regID).breaker
+}
+
+// RateLimiter returns the rate limiter of the upstream. It is created if it does not exist.
+func (m *Monitor) RateLimiter(regID string) *RateLimiter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.getOrCreate(regID).rateLimiter
+}
+
+// Watch starts probing the upstream through the given transport, so outbound proxy and TLS settings
+// of the upstream are applied to probes. If the upstream is already probed, probes are restarted with
+// the new URL and transport. Health of the upstream is kept.
+func (m *Monitor) Watch(regID, upstreamURL string, transport http.RoundTripper) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := m.getOrCreate(regID)
+	if !m.cfg.Enabled {
+		return
+	}
+
+	if u.cancel != nil {
+		u.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	u.cancel = cancel
+
+	go m.run(ctx, regID, upstreamURL, m.newProbeClient(transport), u.breaker)
+}
+
+// Unwatch stops probing the upstream and forgets its health and quota.
+func (m *Monitor) Unwatch(regID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.upstreams[regID]
+	if !ok {
+		return
+	}
+	if u.cancel != nil {
+		u.cancel()
+	}
+	delete(m.upstreams, regID)
+}
+
+// Status returns the health of the upstream. False is returned if the upstream is not monitored.
+func (m *Monitor) Status(regID string) (Status, bool) {
+	m.mu.Lock()
+	u, ok := m.upstreams[regID]
+	m.mu.Unlock()
+
+	if !ok {
+		return Status{}, false
+	}
+
+	status := u.breaker.Status()
+	status.RateLimit = u.rateLimiter.Status()
+	return status, true
+}
+
+// getOrCreate must be called while holding the lock.
+func (m *Monitor) getOrCreate(regID string) *watchedUpstream {
+	u, ok := m.upstreams[regID]
+	if !ok {
+		u = &watchedUpstream{
+			breaker: NewCircuitBreaker(m.cfg.FailureThreshold, time.Duration(m.cfg.OpenDurationSeconds)*time.Second),
+			rateLimiter: NewRateLimiter(m.rateLimitCfg.LowQuotaThreshold,
+				time.Duration(m.rateLimitCfg.DefaultRetryAfterSeconds)*time.Second),
+		}
+		m.upstreams[regID] = u
+	}
+	return u
+}
+
+func (m *Monitor) newProbeClient(transport http.RoundTripper) *http.Client {
+	return &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(m.cfg.TimeoutSeconds) * time.Second,
+		// Redirects are not followed. Any response from `/v2/` means upstream is reachable.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (m *Monitor) run(ctx context.Context, regID, upstreamURL string, client *http.Client, breaker *CircuitBreaker) {
+	ticker := time.NewTicker(time.Duration(m.cfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		m.probe(ctx, client, regID, upstreamURL, breaker)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Monitor) probe(ctx context.Context, client *http.Client, regID, upstreamURL string, breaker *CircuitBreaker) {
+	url := strings.TrimSuffix(upstreamURL, "/") + "/v2/"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		log.Logger().Error().Err(err).Str("registry", regID).Msgf("Failed to create health probe request to %s", url)
+		breaker.RecordFailure(err, 0)
+		return
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	latency := time.Since(start)
+
+	if ctx.Err() != nil {
+		// probe was cancelled since upstream is no longer watched
+		return
+	}
+
+	if err != nil {
+		log.Logger().Warn().Err(err).Str("registry", regID).Msg("Upstream health probe failed")
+		breaker.RecordFailure(err, latency)
+		return
+	}
+	resp.Body.Close()
+
+	// `/v2/` returns 401 for registries which require authentication. It still means that the
+	// upstream is up and running.
+	if resp.StatusCode >= http.StatusInternalServerError {
+		log.Logger().Warn().Int("status_code", resp.StatusCode).Str("registry", regID).
+			Msg("Upstream health probe failed")
+		breaker.RecordFailure(fmt.Errorf("health probe returned status %d", resp.StatusCode), latency)
+		return
+	}
+
+	breaker.RecordSuccess(latency)
+}
@@ -0,0 +1,210 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+)
+
+// ErrRateLimited is returned when the upstream rejected requests with 429. Requests are not sent to the
+// upstream until the time in `Retry-After` of the response.
+var ErrRateLimited = errors.New("upstream rate limit exceeded")
+
+// RateLimitedError carries the time after which requests to the upstream are allowed again.
+type RateLimitedError struct {
+	RetryAt time.Time
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", ErrRateLimited, e.RetryAt.UTC().Format(time.RFC3339))
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// RateLimitStatus is a snapshot of the quota of an upstream registry. Known is false until the upstream
+// sends rate limit headers.
+type RateLimitStatus struct {
+	Known     bool
+	Limit     int
+	Remaining int
+	Window    time.Duration
+	// Low is true when remaining quota is at or below the threshold or requests are rejected.
+	Low       bool
+	Limited   bool
+	RetryAt   time.Time
+	UpdatedAt time.Time
+}
+
+// RateLimiter tracks the quota which an upstream reports with `RateLimit-Limit` and `RateLimit-Remaining`
+// headers (e.g. `100;w=21600` of DockerHub) and `Retry-After` of 429 responses.
+//
+// After a 429 response, Allow returns false until `Retry-After` elapses, so requests are not retried while
+// they are sure to be rejected. Once the window of the reported quota elapses, the quota is not considered
+// low anymore since it is replenished.
+type RateLimiter struct {
+	lowThreshold      int
+	defaultRetryAfter time.Duration
+
+	mu        sync.Mutex
+	known     bool
+	limit     int
+	remaining int
+	window    time.Duration
+	retryAt   time.Time
+	updatedAt time.Time
+	now       func() time.Time
+}
+
+func NewRateLimiter(lowThreshold int, defaultRetryAfter time.Duration) *RateLimiter {
+	if lowThreshold <= 0 {
+		lowThreshold = constants.DefaultUpstreamLowQuotaThreshold
+	}
+	if defaultRetryAfter <= 0 {
+		defaultRetryAfter = constants.DefaultUpstreamRetryAfter * time.Second
+	}
+
+	return &RateLimiter{
+		lowThreshold:      lowThreshold,
+		defaultRetryAfter: defaultRetryAfter,
+		now:               time.Now,
+	}
+}
+
+// Allow reports whether a request can be sent to the upstream. An error is returned until `Retry-After`
+// of the last 429 response elapses.
+func (r *RateLimiter) Allow() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.now().Before(r.retryAt) {
+		return &RateLimitedError{RetryAt: r.retryAt}
+	}
+	return nil
+}
+
+// Record updates the quota from headers of an upstream response. If the response is 429, an error is
+// returned and requests are rejected until `Retry-After`.
+func (r *RateLimiter) Record(statusCode int, header http.Header) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+
+	limit, window, okLimit := parseRateLimitHeader(header.Get("RateLimit-Limit"))
+	remaining, _, okRemaining := parseRateLimitHeader(header.Get("RateLimit-Remaining"))
+	if okLimit && okRemaining {
+		r.known = true
+		r.limit = limit
+		r.remaining = remaining
+		r.window = window
+		r.updatedAt = now
+	}
+
+	if statusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	r.retryAt = parseRetryAfter(header.Get("Retry-After"), now)
+	if r.retryAt.IsZero() {
+		r.retryAt = now.Add(r.defaultRetryAfter)
+	}
+	if r.known {
+		r.remaining = 0
+		r.updatedAt = now
+	}
+	return &RateLimitedError{RetryAt: r.retryAt}
+}
+
+// IsLow reports whether remaining quota is at or below the threshold, or requests are rejected.
+func (r *RateLimiter) IsLow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.isLow(r.now())
+}
+
+func (r *RateLimiter) Status() RateLimitStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	status := RateLimitStatus{
+		Known:     r.known,
+		Limit:     r.limit,
+		Remaining: r.remaining,
+		Window:    r.window,
+		Low:       r.isLow(now),
+		Limited:   now.Before(r.retryAt),
+		UpdatedAt: r.updatedAt,
+	}
+	if status.Limited {
+		status.RetryAt = r.retryAt
+	}
+	return status
+}
+
+// isLow must be called while holding the lock.
+func (r *RateLimiter) isLow(now time.Time) bool {
+	if now.Before(r.retryAt) {
+		return true
+	}
+	if !r.known || r.remaining > r.lowThreshold {
+		return false
+	}
+	// quota is replenished once the window elapses
+	return r.window == 0 || now.Before(r.updatedAt.Add(r.window))
+}
+
+// parseRateLimitHeader parses values like `100;w=21600`. Window is zero if it is not given.
+func parseRateLimitHeader(value string) (count int, window time.Duration, ok bool) {
+	if value == "" {
+		return 0, 0, false
+	}
+
+	parts := strings.Split(value, ";")
+	count, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || count < 0 {
+		return 0, 0, false
+	}
+
+	for _, param := range parts[1:] {
+		key, val, found := strings.Cut(strings.TrimSpace(param), "=")
+		if !found || key != "w" {
+			continue
+		}
+		seconds, err := strconv.Atoi(val)
+		if err == nil && seconds > 0 {
+			window = time.Duration(seconds) * time.Second
+		}
+	}
+	return count, window, true
+}
+
+// parseRetryAfter parses `Retry-After` given in seconds or as an HTTP date. Zero time is returned if the
+// value is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return time.Time{}
+		}
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,129 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRateLimiter(now *time.Time) *RateLimiter {
+	r := NewRateLimiter(10, time.Minute)
+	r.now = func() time.Time { return *now }
+	return r
+}
+
+func rateLimitHeader(limit, remaining string) http.Header {
+	header := http.Header{}
+	header.Set("RateLimit-Limit", limit)
+	header.Set("RateLimit-Remaining", remaining)
+	return header
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+
+	t.Run("Unknown until headers are received", func(t *testing.T) {
+		r := newTestRateLimiter(&now)
+
+		require.NoError(t, r.Record(http.StatusOK, http.Header{}))
+		assert.False(t, r.Status().Known)
+		assert.False(t, r.IsLow())
+		assert.NoError(t, r.Allow())
+	})
+
+	t.Run("Quota is parsed from headers", func(t *testing.T) {
+		r := newTestRateLimiter(&now)
+
+		require.NoError(t, r.Record(http.StatusOK, rateLimitHeader("100;w=21600", "76;w=21600")))
+
+		status := r.Status()
+		assert.True(t, status.Known)
+		assert.Equal(t, 100, status.Limit)
+		assert.Equal(t, 76, status.Remaining)
+		assert.Equal(t, 6*time.Hour, status.Window)
+		assert.False(t, status.Low)
+	})
+
+	t.Run("Invalid headers are ignored", func(t *testing.T) {
+		r := newTestRateLimiter(&now)
+
+		require.NoError(t, r.Record(http.StatusOK, rateLimitHeader("unlimited", "-1")))
+		assert.False(t, r.Status().Known)
+	})
+
+	t.Run("Low until window elapses", func(t *testing.T) {
+		current := now
+		r := newTestRateLimiter(&current)
+
+		require.NoError(t, r.Record(http.StatusOK, rateLimitHeader("100;w=60", "10;w=60")))
+		assert.True(t, r.IsLow())
+
+		current = current.Add(time.Minute)
+		assert.False(t, r.IsLow())
+	})
+
+	t.Run("Retry-After in seconds", func(t *testing.T) {
+		current := now
+		r := newTestRateLimiter(&current)
+
+		header := rateLimitHeader("100;w=21600", "0;w=21600")
+		header.Set("Retry-After", "30")
+
+		err := r.Record(http.StatusTooManyRequests, header)
+		require.ErrorIs(t, err, ErrRateLimited)
+
+		var limitErr *RateLimitedError
+		require.True(t, errors.As(r.Allow(), &limitErr))
+		assert.Equal(t, current.Add(30*time.Second), limitErr.RetryAt)
+
+		status := r.Status()
+		assert.True(t, status.Limited)
+		assert.True(t, status.Low)
+
+		current = current.Add(30 * time.Second)
+		assert.NoError(t, r.Allow())
+		assert.False(t, r.Status().Limited)
+	})
+
+	t.Run("Retry-After as HTTP date", func(t *testing.T) {
+		r := newTestRateLimiter(&now)
+
+		retryAt := now.Add(2 * time.Minute).UTC().Truncate(time.Second)
+		header := http.Header{}
+		header.Set("Retry-After", retryAt.Format(http.TimeFormat))
+
+		require.ErrorIs(t, r.Record(http.StatusTooManyRequests, header), ErrRateLimited)
+		assert.True(t, retryAt.Equal(r.Status().RetryAt))
+	})
+
+	t.Run("Default Retry-After", func(t *testing.T) {
+		r := newTestRateLimiter(&now)
+
+		require.ErrorIs(t, r.Record(http.StatusTooManyRequests, http.Header{}), ErrRateLimited)
+		assert.Equal(t, now.Add(time.Minute), r.Status().RetryAt)
+		assert.False(t, r.Status().Known)
+	})
+}
+
+func TestMonitorMetrics(t *testing.T) {
+	m := NewMonitor(config.UpstreamHealthCheckConfig{}, config.UpstreamRateLimitConfig{})
+	m.RateLimiter("reg1").Record(http.StatusOK, rateLimitHeader("100;w=21600", "5;w=21600"))
+	m.RateLimiter("reg2")
+
+	var sb strings.Builder
+	require.NoError(t, m.WriteMetrics(&sb))
+	metrics := sb.String()
+
+	assert.Contains(t, metrics, "# TYPE oir_upstream_rate_limit_remaining gauge")
+	assert.Contains(t, metrics, `oir_upstream_rate_limit_limit{upstream_id="reg1"} 100`)
+	assert.Contains(t, metrics, `oir_upstream_rate_limit_remaining{upstream_id="reg1"} 5`)
+	assert.Contains(t, metrics, `oir_upstream_rate_limit_low{upstream_id="reg1"} 1`)
+	assert.Contains(t, metrics, `oir_upstream_rate_limit_low{upstream_id="reg2"} 0`)
+	assert.NotContains(t, metrics, `oir_upstream_rate_limit_remaining{upstream_id="reg2"}`)
+}
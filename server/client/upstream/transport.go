@@ -0,0 +1,115 @@
+package upstream
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TransportConfig holds connection settings of an upstream registry which are applied to
+// the http.Transport used to reach it.
+type TransportConfig struct {
+	ConnectionTimeout  time.Duration
+	MaxConnections     int
+	MaxIdleConnections int
+
+	// Outbound HTTP(S) proxy. Credentials are sent as `Proxy-Authorization`.
+	ProxyURL      string
+	ProxyUsername string
+	ProxyPassword string
+
+	// PEM encoded CA certificates which are trusted in addition to the system roots.
+	CABundle string
+	// PEM encoded client certificate and private key for mTLS.
+	ClientCertificate string
+	ClientKey         string
+	// InsecureSkipVerify disables verification of upstream certificates.
+	InsecureSkipVerify bool
+}
+
+func NewTransport(cfg *TransportConfig) (*http.Transport, error) {
+	tlsConfig, err := NewTLSConfig(cfg.CABundle, cfg.ClientCertificate, cfg.ClientKey, cfg.InsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.ConnectionTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: cfg.ConnectionTimeout,
+		MaxIdleConnsPerHost: cfg.MaxConnections,
+		MaxIdleConns:        cfg.MaxIdleConnections,
+		TLSClientConfig:     tlsConfig,
+	}
+
+	if cfg.ProxyURL != "" {
+		proxyURL, err := ParseProxyURL(cfg.ProxyURL, cfg.ProxyUsername, cfg.ProxyPassword)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return transport, nil
+}
+
+// ParseProxyURL parses URL of an outbound proxy. Username and password take precedence over
+// credentials embedded in the URL.
+func ParseProxyURL(rawURL, username, password string) (*url.URL, error) {
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" && proxyURL.Scheme != "socks5" {
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy url must have a host")
+	}
+
+	if username != "" {
+		proxyURL.User = url.UserPassword(username, password)
+	}
+
+	return proxyURL, nil
+}
+
+// NewTLSConfig returns nil if none of the TLS settings are provided, so defaults of http.Transport are used.
+func NewTLSConfig(caBundle, clientCertificate, clientKey string, insecureSkipVerify bool) (*tls.Config, error) {
+	if caBundle == "" && clientCertificate == "" && clientKey == "" && !insecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+
+	if caBundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(caBundle)) {
+			return nil, fmt.Errorf("ca bundle does not contain any valid PEM encoded certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if clientCertificate != "" || clientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(clientCertificate), []byte(clientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate or key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
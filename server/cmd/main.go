@@ -12,14 +12,20 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/ksankeerth/open-image-registry/auth/keys"
+	"github.com/ksankeerth/open-image-registry/auth/revocation"
 	"github.com/ksankeerth/open-image-registry/client/email"
 	"github.com/ksankeerth/open-image-registry/config"
 	"github.com/ksankeerth/open-image-registry/constants"
 	"github.com/ksankeerth/open-image-registry/lib"
 	"github.com/ksankeerth/open-image-registry/listeners"
 	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/middleware"
 	"github.com/ksankeerth/open-image-registry/registry"
+	"github.com/ksankeerth/open-image-registry/replication"
 	"github.com/ksankeerth/open-image-registry/resource/access"
+	replicationmgmt "github.com/ksankeerth/open-image-registry/resource/replication"
+	upstreammgmt "github.com/ksankeerth/open-image-registry/resource/upstream"
 	"github.com/ksankeerth/open-image-registry/rest"
 	"github.com/ksankeerth/open-image-registry/security"
 	"github.com/ksankeerth/open-image-registry/storage"
@@ -27,12 +33,19 @@ import (
 	"github.com/ksankeerth/open-image-registry/store/sqlite"
 )
 
+// registryShutdownGracePeriod is the time given to in-flight requests of registry listeners on shutdown. Requests
+// which are still running are aborted afterwards.
+const registryShutdownGracePeriod = 10 * time.Second
+
 func main() {
 
 	log.Logger().Info().Msg("Starting OpenImageRegistry ...........")
 
 	// ------------- parse flags and options ---------------
 	appHomeDir := flag.String("app_home", "", "Path to app home directory")
+	// The generated private key is stored unencrypted in the database like the imported key pair
+	rotateSigningKey := flag.Bool("rotate_signing_key", false,
+		"Rotate the signing key of auth tokens and exit. Running servers apply the new key within a minute.")
 	flag.Parse()
 
 	if *appHomeDir == "" {
@@ -77,6 +90,43 @@ func main() {
 		return
 	}
 
+	// -------------- create jwt provider --------------------------------------
+	authConfig := appConfig.Security.AuthToken
+	jwtAuth, err := lib.NewOAuthJWTAuthenticator(authConfig.Algorithm, authConfig.GetPrivateKey(), authConfig.Issuer,
+		time.Duration(authConfig.Expiry)*time.Second)
+	if err != nil {
+		log.Logger().Fatal().Err(err).Msg("Server startup failed due to auth token configuration errors")
+		return
+	}
+
+	keyManager := keys.NewManager(store, jwtAuth, time.Duration(authConfig.RetiredKeyGrace)*time.Second)
+	err = keyManager.Init(context.Background(), &authConfig)
+	if err != nil {
+		log.Logger().Fatal().Err(err).Msg("Server startup failed due to signing key initialization errors")
+		return
+	}
+
+	if *rotateSigningKey {
+		key, err := keyManager.Rotate(context.Background())
+		if err != nil {
+			log.Logger().Fatal().Err(err).Msg("Rotating signing key failed")
+			return
+		}
+		// otherwise the configured key is activated again on the next startup
+		if authConfig.ActiveKeyID != "" {
+			log.Logger().Warn().Msgf("Update security.auth_token.active_key_id to the new signing key: %s", key.KeyID)
+		}
+		return
+	}
+
+	// ------------- load revoked auth tokens ------------------
+	revocations := revocation.NewCache(store, time.Duration(authConfig.RevocationReload)*time.Second)
+	err = revocations.Load(context.Background())
+	if err != nil {
+		log.Logger().Fatal().Err(err).Msg("Server startup failed due to errors in loading revoked tokens")
+		return
+	}
+
 	// -------------------- Initialize storage ------------------
 
 	err = storage.Init(&appConfig.Storage)
@@ -117,13 +167,53 @@ func main() {
 	// ------------- create instance of resource access manager ----------------
 	accessManager := access.NewManager(store)
 
-	// -------------- create jwt provider --------------------------------------
-	authConfig := appConfig.Security.AuthToken
-	jwtAuth := lib.NewOAuthEC256JWTAuthenticator(authConfig.GetPrivateKey(), authConfig.GetPublicKey(), authConfig.Issuer,
-		time.Duration(authConfig.Expiry)*time.Second)
+	// ------------- authenticate registry requests with personal access tokens --
+	registryAuthenticator := middleware.NewAuthenticator(store, jwtAuth, revocations)
+
+	// ------------- create controller of upstream proxy listeners ------------
+	upstreamListeners := registry.NewUpstreamListenerController(store, appConfig.ImageRegistry.Routing.IsSinglePort())
+	upstreamListeners.SetAuthenticator(registryAuthenticator.AuthenticateRegistry)
+
+	// ------------- create controller of group registry listeners ------------
+	var hostedRegistry *registry.RegistryHandler
+	if appConfig.ImageRegistry.Enabled {
+		hostedRegistry = registry.NewRegistryHandler(constants.HostedRegistryID, constants.HostedRegistryName, store)
+	}
+	groupListeners := registry.NewGroupListenerController(store, hostedRegistry, upstreamListeners,
+		appConfig.ImageRegistry.Routing.IsSinglePort())
+	groupListeners.SetAuthenticator(registryAuthenticator.AuthenticateRegistry)
+
+	// background jobs are stopped on shutdown, along with their requests to upstream registries
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	// keys rotated by other instances or the CLI are applied without restart
+	keyManager.Start(jobsCtx)
+
+	// tokens revoked by other instances are applied, and revocations of expired tokens are purged
+	revocations.Start(jobsCtx)
+
+	// ------------- start replication of hosted images ----------------------
+	var replicationRunner replicationmgmt.RuleRunner
+	replicationConfig := config.GetReplicationConfig()
+	if replicationConfig.Enabled && hostedRegistry != nil {
+		replicator := replication.NewReplicator(store, hostedRegistry, replicationConfig)
+		hostedRegistry.SetPushObserver(replicator)
+		replicator.Start(jobsCtx)
+		replicationRunner = replicator
+	}
+
+	// ------------- start scheduler of upstream sync jobs ---------------------
+	var syncRunner upstreammgmt.SyncRunner
+	if appConfig.UpstreamRegistry.Enabled {
+		syncScheduler := registry.NewSyncScheduler(store, upstreamListeners, config.GetUpstreamSyncConfig())
+		syncScheduler.Start(jobsCtx)
+		syncRunner = syncScheduler
+	}
 
 	// ------------ start serving ManagementAPIs and UI -----------------------
-	appRouter := rest.AppRouter(&appConfig.WebApp, store, jwtAuth, accessManager, emailClient)
+	appRouter := rest.AppRouter(&appConfig.WebApp, store, jwtAuth, accessManager, emailClient, upstreamListeners,
+		groupListeners, replicationRunner, syncRunner, keyManager, revocations)
 
 	address := fmt.Sprintf("%s:%d", appConfig.Server.Hostname, appConfig.Server.Port)
 
@@ -147,11 +237,15 @@ func main() {
 		log.Logger().Info().Msgf("Serving UI from: %s", appConfig.WebApp.DistPath)
 	}
 
-	go startRegistryListeners(appConfig.ImageRegistry.Enabled, appConfig.ImageRegistry.Port, store)
+	go startRegistryListeners(&appConfig.ImageRegistry, hostedRegistry, upstreamListeners, groupListeners,
+		registryAuthenticator)
 
 	<-shutdown
 
 	log.Logger().Info().Msg("Server is about to shutdown.")
+	stopJobs()
+	listeners.GetListenerManager().Shutdown(registryShutdownGracePeriod)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	err = server.Shutdown(ctx)
@@ -160,33 +254,41 @@ func main() {
 	}
 }
 
-func startRegistryListeners(localRegistryEnabled bool, localRegistryPort uint, store store.Store) {
+func startRegistryListeners(registryConfig *config.ImageRegistryConfig, hostedRegistry *registry.RegistryHandler,
+	upstreamListeners *registry.UpstreamListenerController, groupListeners *registry.GroupListenerController,
+	authenticator *middleware.Authenticator) {
 	lm := listeners.GetListenerManager()
 
-	if localRegistryEnabled {
-		err := lm.RegisterListener(constants.HostedRegistryID, constants.HostedRegistryName, localRegistryPort,
-			registry.NewRegistryHandler(constants.HostedRegistryID, constants.HostedRegistryName, store).Routes(),
-			time.Second*10)
-		if err != nil {
-			log.Logger().Error().Err(err).Msgf("Unable to start listener for LocalRegistry")
-			return
-		}
+	var hostedHandler http.Handler
+	if hostedRegistry != nil {
+		hostedHandler = hostedRegistry.Routes()
 	}
 
-	upstreamAddrs, err := store.Upstreams().GetAllUpstreamRegistryAddresses(context.Background())
-	if err != nil {
-		log.Logger().Error().Err(err).Msgf("Error ocurred when loading active upstream registeries' address")
-		return
+	// In single port mode, upstreams and groups are served by the listener of LocalRegistry. The router
+	// authenticates requests after it selects the registry.
+	if registryConfig.Routing.IsSinglePort() {
+		router := registry.NewSinglePortRouter(hostedHandler, upstreamListeners, groupListeners,
+			registryConfig.Routing)
+		router.SetAuthenticator(authenticator.AuthenticateRegistry)
+		hostedHandler = router
+	} else if hostedHandler != nil {
+		hostedHandler = registry.WithAuthenticator(hostedHandler, constants.HostedRegistryID,
+			authenticator.AuthenticateRegistry)
 	}
 
-	for _, upstreamAddr := range upstreamAddrs {
-		err = lm.RegisterListener(upstreamAddr.ID, upstreamAddr.Name, uint(upstreamAddr.Port),
-			registry.NewRegistryHandler(upstreamAddr.ID, upstreamAddr.Name, store).Routes(), time.Second*10)
+	// listen delay is in seconds
+	if hostedHandler != nil {
+		err := lm.RegisterListener(constants.HostedRegistryID, constants.HostedRegistryName, registryConfig.Port,
+			hostedHandler, 10)
 		if err != nil {
-			log.Logger().Error().Err(err).Msgf("Unable to start listener for %s", upstreamAddr.Name)
-			continue
+			log.Logger().Error().Err(err).Msgf("Unable to start listener for LocalRegistry")
+			return
 		}
 	}
+
+	// Later changes of upstreams and groups are applied to listeners by management APIs.
+	upstreamListeners.StartAll(context.Background(), 10)
+	groupListeners.StartAll(context.Background(), 10)
 }
 
 func initializeAdminUserAccount(s store.Store, adminConfig *config.AdminUserAccountConfig) error {
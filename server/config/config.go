@@ -1,15 +1,18 @@
 package config
 
 import (
-	"crypto/ecdsa"
+	"crypto"
 	"crypto/x509"
-	"encoding/pem"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/lib"
 	"github.com/ksankeerth/open-image-registry/log"
 	"github.com/ksankeerth/open-image-registry/utils"
 	"gopkg.in/yaml.v3"
@@ -21,6 +24,7 @@ type AppConfig struct {
 	Server           MgmtServerConfig       `yaml:"server"`
 	ImageRegistry    ImageRegistryConfig    `yaml:"image_registry"`
 	UpstreamRegistry UpstreamRegistryConfig `yaml:"upstream_registry"`
+	Replication      ReplicationConfig      `yaml:"replication"`
 	Admin            AdminUserAccountConfig `yaml:"admin"`
 	Database         DatabaseConfig         `yaml:"database"`
 	Storage          StorageConfig          `yaml:"storage"`
@@ -45,10 +49,68 @@ type ImageRegistryConfig struct {
 	CreateNamespaceOnPush bool `yaml:"create_namespace_on_push"`
 	// if this is true, it allows developers to create repository on docker push
 	CreateRepositoryOnPush bool `yaml:"create_repository_on_push"`
+	// if this is true, anonymous requests are rejected and clients have to send a personal access token
+	RequireAuthentication bool `yaml:"require_authentication"`
+
+	Routing RegistryRoutingConfig `yaml:"routing"`
+}
+
+// RegistryRoutingConfig decides how requests reach hosted registry and upstream registries.
+// In single_port mode, `Hosts` maps `Host` header and `Namespaces` maps `ns` query parameter
+// (sent by containerd mirrors) to upstream names.
+type RegistryRoutingConfig struct {
+	Mode       string            `yaml:"mode"`
+	Hosts      map[string]string `yaml:"hosts"`
+	Namespaces map[string]string `yaml:"namespaces"`
+}
+
+func (r RegistryRoutingConfig) IsSinglePort() bool {
+	return r.Mode == constants.RegistryRoutingModeSinglePort
 }
 
 type UpstreamRegistryConfig struct {
-	Enabled bool `yaml:"enabled"`
+	Enabled     bool                      `yaml:"enabled"`
+	HealthCheck UpstreamHealthCheckConfig `yaml:"health_check"`
+	RateLimit   UpstreamRateLimitConfig   `yaml:"rate_limit"`
+	Sync        UpstreamSyncConfig        `yaml:"sync"`
+}
+
+// UpstreamHealthCheckConfig configures `/v2/` probes of upstream registries and the circuit breaker.
+// Once FailureThreshold consecutive failures are observed, requests to the upstream fail fast (or
+// are served from cache) for OpenDurationSeconds.
+type UpstreamHealthCheckConfig struct {
+	Enabled             bool `yaml:"enabled"`
+	IntervalSeconds     int  `yaml:"interval_seconds"`
+	TimeoutSeconds      int  `yaml:"timeout_seconds"`
+	FailureThreshold    int  `yaml:"failure_threshold"`
+	OpenDurationSeconds int  `yaml:"open_duration_seconds"`
+}
+
+// UpstreamRateLimitConfig configures handling of rate limits of upstreams (e.g. DockerHub pull limits).
+// Once remaining quota drops to LowQuotaThreshold, cached manifests are preferred over downloads. After a 429
+// response without `Retry-After`, requests are not sent for DefaultRetryAfterSeconds.
+type UpstreamRateLimitConfig struct {
+	LowQuotaThreshold        int `yaml:"low_quota_threshold"`
+	DefaultRetryAfterSeconds int `yaml:"default_retry_after_seconds"`
+}
+
+// UpstreamSyncConfig configures the scheduler of sync jobs which pre-fetch images of upstreams into the cache.
+// Due jobs are looked up every PollIntervalSeconds.
+type UpstreamSyncConfig struct {
+	PollIntervalSeconds int `yaml:"poll_interval_seconds"`
+}
+
+// ReplicationConfig configures workers which copy images of hosted registry to remote registries.
+// Failed tasks are retried after BackoffSeconds, doubled on each attempt up to MaxBackoffSeconds, until
+// MaxAttempts attempts are made.
+type ReplicationConfig struct {
+	Enabled             bool `yaml:"enabled"`
+	PollIntervalSeconds int  `yaml:"poll_interval_seconds"`
+	MaxAttempts         int  `yaml:"max_attempts"`
+	BackoffSeconds      int  `yaml:"backoff_seconds"`
+	MaxBackoffSeconds   int  `yaml:"max_backoff_seconds"`
+	// TimeoutSeconds limits each request to the remote registry.
+	TimeoutSeconds int `yaml:"timeout_seconds"`
 }
 
 type AdminUserAccountConfig struct {
@@ -110,24 +172,115 @@ type AuditEventsConfig struct {
 }
 
 type SecurityConfig struct {
-	AuthToken AuthTokenConfig `yaml:"auth_token"`
+	AuthToken     AuthTokenConfig     `yaml:"auth_token"`
+	OIDC          OIDCConfig          `yaml:"oidc"`
+	LDAP          LDAPConfig          `yaml:"ldap"`
+	PasswordReset PasswordResetConfig `yaml:"password_reset"`
 }
 
 type AuthTokenConfig struct {
-	Algorithm      string `yaml:"algorithm"`
-	PrivateKeyPath string `yaml:"private_key_path"`
-	PublicKeyPath  string `yaml:"public_key_path"`
+	Algorithm      string `yaml:"algorithm"`        // one of ES256, ES384, RS256, PS256 and EdDSA
+	PrivateKeyPath string `yaml:"private_key_path"` // PEM or JWK
+	PublicKeyPath  string `yaml:"public_key_path"`  // optional. It must match the private key if set.
 	Expiry         int    `yaml:"expiry_seconds"`
+	RefreshExpiry  int    `yaml:"refresh_expiry_seconds"` // lifetime of login sessions
 	Issuer         string `yaml:"issuer"`
-	privateKey     *ecdsa.PrivateKey
-	publicKey      *ecdsa.PublicKey
+	// ActiveKeyID selects the signing key by its kid on startup. The key pair of PrivateKeyPath and PublicKeyPath is
+	// the first signing key, and later keys are created by rotation.
+	ActiveKeyID     string `yaml:"active_key_id"`
+	RetiredKeyGrace int    `yaml:"retired_key_grace_seconds"` // how long retired keys verify tokens
+	// RevocationReload is how often revoked tokens are reloaded from the database. Tokens without a login session
+	// revoked by other instances are accepted until then.
+	RevocationReload int `yaml:"revocation_reload_seconds"`
+	privateKey       crypto.Signer
+	publicKey        crypto.PublicKey
+}
+
+// OIDCConfig configures single sign-on with an OpenID Connect provider. Users log in with authorization code flow
+// and PKCE, and accounts are created on their first login. Endpoints are discovered from
+// `<issuer>/.well-known/openid-configuration` unless all of them are set.
+type OIDCConfig struct {
+	Enabled               bool     `yaml:"enabled"`
+	Issuer                string   `yaml:"issuer"`
+	ClientID              string   `yaml:"client_id"`
+	ClientSecret          string   `yaml:"client_secret"`
+	RedirectURL           string   `yaml:"redirect_url"`
+	AuthorizationEndpoint string   `yaml:"authorization_endpoint"`
+	TokenEndpoint         string   `yaml:"token_endpoint"`
+	JWKSURI               string   `yaml:"jwks_uri"`
+	Scopes                []string `yaml:"scopes"`
+	TimeoutSeconds        int      `yaml:"timeout_seconds"`
+	UsernameClaim         string   `yaml:"username_claim"`
+	// RoleMapping maps values of RoleClaim to roles. If several values are mapped, the highest role is assigned.
+	// Users without a mapped value get DefaultRole, or can't log in if it is empty.
+	RoleClaim   string            `yaml:"role_claim"`
+	RoleMapping map[string]string `yaml:"role_mapping"`
+	DefaultRole string            `yaml:"default_role"`
+	// NamespaceMapping grants access to namespaces of hosted registry for values of GroupsClaim
+	GroupsClaim      string                           `yaml:"groups_claim"`
+	NamespaceMapping map[string][]OIDCNamespaceAccess `yaml:"namespace_mapping"`
+	// PostLoginRedirect is where the browser is sent after login
+	PostLoginRedirect string `yaml:"post_login_redirect"`
+}
+
+// PasswordResetConfig configures self-service password reset of local accounts. Reset links are sent to the email
+// of the account, so email notifications must be enabled.
+type PasswordResetConfig struct {
+	// ResetURL is the page of the web app which completes the reset. The token is appended as URL fragment, so it
+	// isn't sent to servers in between.
+	ResetURL      string `yaml:"reset_url"`
+	ExpirySeconds int    `yaml:"expiry_seconds"`
+	// Reset requests are limited per account and per client IP within RateLimitWindowSeconds
+	MaxRequestsPerAccount  int `yaml:"max_requests_per_account"`
+	MaxRequestsPerIP       int `yaml:"max_requests_per_ip"`
+	RateLimitWindowSeconds int `yaml:"rate_limit_window_seconds"`
+}
+
+type OIDCNamespaceAccess struct {
+	Namespace   string `yaml:"namespace"`
+	AccessLevel string `yaml:"access_level"`
+}
+
+// LDAPConfig configures password login against an LDAP or Active Directory server. The user is searched with the
+// service account, then the password is verified by binding as the user. Accounts are created on their first login.
+// Local accounts are not looked up in the directory.
+type LDAPConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// URL is either ldap:// or ldaps://
+	URL                string `yaml:"url"`
+	StartTLS           bool   `yaml:"start_tls"`
+	CAFile             string `yaml:"ca_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	TimeoutSeconds     int    `yaml:"timeout_seconds"`
+	// BindDN and BindPassword are of the service account which searches users and groups
+	BindDN       string `yaml:"bind_dn"`
+	BindPassword string `yaml:"bind_password"`
+	BaseDN       string `yaml:"base_dn"`
+	// UserFilter finds the user. %s is replaced with the escaped username.
+	UserFilter           string `yaml:"user_filter"`
+	UsernameAttribute    string `yaml:"username_attribute"`
+	EmailAttribute       string `yaml:"email_attribute"`
+	DisplayNameAttribute string `yaml:"display_name_attribute"`
+	// IDAttribute identifies the user even if the entry is renamed, e.g. entryUUID or objectGUID. DN of the entry is
+	// used if it is empty.
+	IDAttribute string `yaml:"id_attribute"`
+	// Groups of the user are found with GroupFilter under GroupBaseDN, where %s is replaced with the escaped DN of
+	// the user. Groups are also read from GroupMembershipAttribute of the user, e.g. memberOf.
+	GroupBaseDN              string `yaml:"group_base_dn"`
+	GroupFilter              string `yaml:"group_filter"`
+	GroupNameAttribute       string `yaml:"group_name_attribute"`
+	GroupMembershipAttribute string `yaml:"group_membership_attribute"`
+	// RoleMapping maps group names to roles. If several groups are mapped, the highest role is assigned. Users
+	// without a mapped group get DefaultRole, or can't log in if it is empty.
+	RoleMapping map[string]string `yaml:"role_mapping"`
+	DefaultRole string            `yaml:"default_role"`
 }
 
-func (a *AuthTokenConfig) GetPrivateKey() *ecdsa.PrivateKey {
+func (a *AuthTokenConfig) GetPrivateKey() crypto.Signer {
 	return a.privateKey
 }
 
-func (a *AuthTokenConfig) GetPublicKey() *ecdsa.PublicKey {
+func (a *AuthTokenConfig) GetPublicKey() crypto.PublicKey {
 	return a.publicKey
 }
 
@@ -153,6 +306,68 @@ func GetImageRegistryConfig() ImageRegistryConfig {
 	return appConfiguration.ImageRegistry
 }
 
+func GetUpstreamHealthCheckConfig() UpstreamHealthCheckConfig {
+	if appConfiguration == nil {
+		return defaultUpstreamHealthCheckConfig()
+	}
+	return appConfiguration.UpstreamRegistry.HealthCheck
+}
+
+func defaultUpstreamHealthCheckConfig() UpstreamHealthCheckConfig {
+	return UpstreamHealthCheckConfig{
+		Enabled:             true,
+		IntervalSeconds:     constants.DefaultUpstreamHealthCheckInterval,
+		TimeoutSeconds:      constants.DefaultUpstreamHealthCheckTimeout,
+		FailureThreshold:    constants.DefaultUpstreamFailureThreshold,
+		OpenDurationSeconds: constants.DefaultUpstreamCircuitOpenDuration,
+	}
+}
+
+func GetUpstreamRateLimitConfig() UpstreamRateLimitConfig {
+	if appConfiguration == nil {
+		return defaultUpstreamRateLimitConfig()
+	}
+	return appConfiguration.UpstreamRegistry.RateLimit
+}
+
+func defaultUpstreamRateLimitConfig() UpstreamRateLimitConfig {
+	return UpstreamRateLimitConfig{
+		LowQuotaThreshold:        constants.DefaultUpstreamLowQuotaThreshold,
+		DefaultRetryAfterSeconds: constants.DefaultUpstreamRetryAfter,
+	}
+}
+
+func GetUpstreamSyncConfig() UpstreamSyncConfig {
+	if appConfiguration == nil {
+		return defaultUpstreamSyncConfig()
+	}
+	return appConfiguration.UpstreamRegistry.Sync
+}
+
+func defaultUpstreamSyncConfig() UpstreamSyncConfig {
+	return UpstreamSyncConfig{
+		PollIntervalSeconds: constants.DefaultUpstreamSyncPollInterval,
+	}
+}
+
+func GetReplicationConfig() ReplicationConfig {
+	if appConfiguration == nil {
+		return defaultReplicationConfig()
+	}
+	return appConfiguration.Replication
+}
+
+func defaultReplicationConfig() ReplicationConfig {
+	return ReplicationConfig{
+		Enabled:             true,
+		PollIntervalSeconds: constants.DefaultReplicationPollInterval,
+		MaxAttempts:         constants.DefaultReplicationMaxAttempts,
+		BackoffSeconds:      constants.DefaultReplicationBackoff,
+		MaxBackoffSeconds:   constants.DefaultReplicationMaxBackoff,
+		TimeoutSeconds:      constants.DefaultReplicationTimeout,
+	}
+}
+
 func GetDefaultEmailSenderConfig() *EmailSenderConfig {
 	return &EmailSenderConfig{
 		Enabled:      false,
@@ -168,6 +383,37 @@ func GetAuthTokenConfig() *AuthTokenConfig {
 	return &appConfiguration.Security.AuthToken
 }
 
+func GetOIDCConfig() *OIDCConfig {
+	if appConfiguration == nil {
+		return &OIDCConfig{}
+	}
+	return &appConfiguration.Security.OIDC
+}
+
+func GetLDAPConfig() *LDAPConfig {
+	if appConfiguration == nil {
+		return &LDAPConfig{}
+	}
+	return &appConfiguration.Security.LDAP
+}
+
+func GetPasswordResetConfig() *PasswordResetConfig {
+	if appConfiguration == nil {
+		return defaultPasswordResetConfig()
+	}
+	return &appConfiguration.Security.PasswordReset
+}
+
+func defaultPasswordResetConfig() *PasswordResetConfig {
+	return &PasswordResetConfig{
+		ResetURL:               constants.DefaultPasswordResetPath,
+		ExpirySeconds:          constants.DefaultPasswordResetExpiry,
+		MaxRequestsPerAccount:  constants.DefaultPasswordResetRequestsPerAccount,
+		MaxRequestsPerIP:       constants.DefaultPasswordResetRequestsPerIP,
+		RateLimitWindowSeconds: constants.DefaultPasswordResetRateLimitWindow,
+	}
+}
+
 func LoadConfig(configPath, appHome string) (*AppConfig, error) {
 	appConfig := defaultConfig(filepath.Join(appHome, "server"))
 
@@ -227,6 +473,74 @@ func validateConfig(cfg *AppConfig) (bool, string) {
 			return false, "image_registry.port must be greater than 0 when image_registry.enabled = true"
 		}
 	}
+	switch cfg.ImageRegistry.Routing.Mode {
+	case "":
+		cfg.ImageRegistry.Routing.Mode = constants.RegistryRoutingModePort
+	case constants.RegistryRoutingModePort:
+	case constants.RegistryRoutingModeSinglePort:
+		if cfg.ImageRegistry.Port == 0 {
+			return false, "image_registry.port must be greater than 0 when image_registry.routing.mode = single_port"
+		}
+	default:
+		return false, fmt.Sprintf("unsupported image_registry.routing.mode: %s", cfg.ImageRegistry.Routing.Mode)
+	}
+
+	// --- Upstream Registry ---
+	if cfg.UpstreamRegistry.HealthCheck.Enabled {
+		healthCheck := cfg.UpstreamRegistry.HealthCheck
+		if healthCheck.IntervalSeconds <= 0 {
+			return false, "upstream_registry.health_check.interval_seconds must be greater than 0"
+		}
+		if healthCheck.TimeoutSeconds <= 0 {
+			return false, "upstream_registry.health_check.timeout_seconds must be greater than 0"
+		}
+		if healthCheck.FailureThreshold <= 0 {
+			return false, "upstream_registry.health_check.failure_threshold must be greater than 0"
+		}
+		if healthCheck.OpenDurationSeconds <= 0 {
+			return false, "upstream_registry.health_check.open_duration_seconds must be greater than 0"
+		}
+	}
+
+	if cfg.UpstreamRegistry.RateLimit.LowQuotaThreshold == 0 {
+		cfg.UpstreamRegistry.RateLimit.LowQuotaThreshold = constants.DefaultUpstreamLowQuotaThreshold
+	}
+	if cfg.UpstreamRegistry.RateLimit.LowQuotaThreshold < 0 {
+		return false, "upstream_registry.rate_limit.low_quota_threshold must be greater than 0"
+	}
+	if cfg.UpstreamRegistry.RateLimit.DefaultRetryAfterSeconds == 0 {
+		cfg.UpstreamRegistry.RateLimit.DefaultRetryAfterSeconds = constants.DefaultUpstreamRetryAfter
+	}
+	if cfg.UpstreamRegistry.RateLimit.DefaultRetryAfterSeconds < 0 {
+		return false, "upstream_registry.rate_limit.default_retry_after_seconds must be greater than 0"
+	}
+
+	if cfg.UpstreamRegistry.Sync.PollIntervalSeconds == 0 {
+		cfg.UpstreamRegistry.Sync.PollIntervalSeconds = constants.DefaultUpstreamSyncPollInterval
+	}
+	if cfg.UpstreamRegistry.Sync.PollIntervalSeconds < 0 {
+		return false, "upstream_registry.sync.poll_interval_seconds must be greater than 0"
+	}
+
+	// --- Replication ---
+	if cfg.Replication.Enabled {
+		replication := cfg.Replication
+		if replication.PollIntervalSeconds <= 0 {
+			return false, "replication.poll_interval_seconds must be greater than 0"
+		}
+		if replication.MaxAttempts <= 0 {
+			return false, "replication.max_attempts must be greater than 0"
+		}
+		if replication.BackoffSeconds <= 0 {
+			return false, "replication.backoff_seconds must be greater than 0"
+		}
+		if replication.MaxBackoffSeconds < replication.BackoffSeconds {
+			return false, "replication.max_backoff_seconds must not be less than replication.backoff_seconds"
+		}
+		if replication.TimeoutSeconds <= 0 {
+			return false, "replication.timeout_seconds must be greater than 0"
+		}
+	}
 
 	// --- Admin Account ---
 	if cfg.Admin.CreateAccount {
@@ -308,11 +622,54 @@ func validateConfig(cfg *AppConfig) (bool, string) {
 	if cfg.Security.AuthToken.Issuer == "" {
 		return false, "Auth token issuer must be set for security.auth_token.issuer"
 	}
-	if cfg.Security.AuthToken.Algorithm != constants.TokenSigningAlgoES256 {
-		return false, "Unsupported algorithm for security.auth_token.algorithm"
+	if !slices.Contains(lib.SupportedAlgorithms(), cfg.Security.AuthToken.Algorithm) {
+		return false, fmt.Sprintf("Unsupported algorithm for security.auth_token.algorithm. Supported algorithms: %s",
+			strings.Join(lib.SupportedAlgorithms(), ", "))
+	}
+	if cfg.Security.AuthToken.RefreshExpiry == 0 {
+		cfg.Security.AuthToken.RefreshExpiry = constants.DefaultRefreshTokenExpiry
+	}
+	if cfg.Security.AuthToken.RefreshExpiry < cfg.Security.AuthToken.Expiry {
+		return false, "security.auth_token.refresh_expiry_seconds must not be less than security.auth_token.expiry_seconds"
+	}
+	if cfg.Security.AuthToken.RetiredKeyGrace == 0 {
+		cfg.Security.AuthToken.RetiredKeyGrace = constants.DefaultRetiredKeyGrace
+	}
+	// tokens signed before rotation must stay valid until they expire
+	if cfg.Security.AuthToken.RetiredKeyGrace < cfg.Security.AuthToken.Expiry {
+		return false, "security.auth_token.retired_key_grace_seconds must not be less than security.auth_token.expiry_seconds"
+	}
+	if cfg.Security.AuthToken.RevocationReload == 0 {
+		cfg.Security.AuthToken.RevocationReload = constants.DefaultRevocationReload
+	}
+	if cfg.Security.AuthToken.RevocationReload < 0 {
+		return false, "security.auth_token.revocation_reload_seconds must be greater than 0"
+	}
+
+	// Security - OIDC
+	if cfg.Security.OIDC.Enabled {
+		valid, errMsg := validateOIDCConfig(&cfg.Security.OIDC)
+		if !valid {
+			return false, errMsg
+		}
+	}
+
+	// Security - LDAP
+	if cfg.Security.LDAP.Enabled {
+		valid, errMsg := validateLDAPConfig(&cfg.Security.LDAP)
+		if !valid {
+			return false, errMsg
+		}
 	}
 
-	privKey, pubKey, err := validateES256KeyPair(cfg.Security.AuthToken.PrivateKeyPath, cfg.Security.AuthToken.PublicKeyPath)
+	// Security - Password Reset
+	valid, errMsg := validatePasswordResetConfig(&cfg.Security.PasswordReset, &cfg.Server)
+	if !valid {
+		return false, errMsg
+	}
+
+	privKey, pubKey, err := loadSigningKeyPair(cfg.Security.AuthToken.Algorithm, cfg.Security.AuthToken.PrivateKeyPath,
+		cfg.Security.AuthToken.PublicKeyPath)
 	if err != nil {
 		log.Logger().Error().Err(err).Msg("Error occurred when validating auth_token key pairs")
 		if privKey != nil {
@@ -321,17 +678,178 @@ func validateConfig(cfg *AppConfig) (bool, string) {
 		return false, fmt.Sprintf("Invalid private key for security.auth_token.private_key_path : %s", err.Error())
 	}
 
-	// It is only allowed to use P-256. Reason is to avoid P-521 since is CPU heavy algo.
-	if privKey.Curve.Params().Name != "P-256" {
-		return false, fmt.Sprintf("Unsupported curve: %s", privKey.Curve.Params().Name)
-	}
-
 	cfg.Security.AuthToken.privateKey = privKey
 	cfg.Security.AuthToken.publicKey = pubKey
 
 	return true, ""
 }
 
+func validateOIDCConfig(oidc *OIDCConfig) (bool, string) {
+	if oidc.Issuer == "" {
+		return false, "security.oidc.issuer cannot be empty when security.oidc.enabled = true"
+	}
+	if oidc.ClientID == "" {
+		return false, "security.oidc.client_id cannot be empty when security.oidc.enabled = true"
+	}
+	if oidc.RedirectURL == "" {
+		return false, "security.oidc.redirect_url cannot be empty when security.oidc.enabled = true"
+	}
+
+	if len(oidc.Scopes) == 0 {
+		oidc.Scopes = constants.DefaultOIDCScopes
+	}
+	if !slices.Contains(oidc.Scopes, "openid") {
+		return false, "security.oidc.scopes must contain openid"
+	}
+	if oidc.TimeoutSeconds == 0 {
+		oidc.TimeoutSeconds = constants.DefaultOIDCTimeout
+	}
+	if oidc.TimeoutSeconds < 0 {
+		return false, "security.oidc.timeout_seconds must be greater than 0"
+	}
+	if oidc.UsernameClaim == "" {
+		oidc.UsernameClaim = constants.DefaultOIDCUsernameClaim
+	}
+	if oidc.RoleClaim == "" {
+		oidc.RoleClaim = constants.DefaultOIDCGroupsClaim
+	}
+	if oidc.GroupsClaim == "" {
+		oidc.GroupsClaim = constants.DefaultOIDCGroupsClaim
+	}
+	if oidc.PostLoginRedirect == "" {
+		oidc.PostLoginRedirect = "/"
+	}
+
+	// Machine role is only for machine accounts
+	roles := []string{constants.RoleAdmin, constants.RoleMaintainer, constants.RoleDeveloper, constants.RoleGuest}
+	for value, role := range oidc.RoleMapping {
+		if !slices.Contains(roles, role) {
+			return false, fmt.Sprintf("unsupported role(%s) for security.oidc.role_mapping.%s", role, value)
+		}
+	}
+	if oidc.DefaultRole != "" && !slices.Contains(roles, oidc.DefaultRole) {
+		return false, fmt.Sprintf("unsupported role for security.oidc.default_role: %s", oidc.DefaultRole)
+	}
+
+	accessLevels := []string{constants.AccessLevelMaintainer, constants.AccessLevelDeveloper,
+		constants.AccessLevelGuest}
+	for group, accessList := range oidc.NamespaceMapping {
+		for _, access := range accessList {
+			if access.Namespace == "" {
+				return false, fmt.Sprintf("namespace cannot be empty in security.oidc.namespace_mapping.%s", group)
+			}
+			if !slices.Contains(accessLevels, access.AccessLevel) {
+				return false, fmt.Sprintf("unsupported access level(%s) in security.oidc.namespace_mapping.%s",
+					access.AccessLevel, group)
+			}
+		}
+	}
+
+	return true, ""
+}
+
+func validatePasswordResetConfig(reset *PasswordResetConfig, server *MgmtServerConfig) (bool, string) {
+	if reset.ResetURL == "" {
+		reset.ResetURL = fmt.Sprintf("http://%s:%d%s", server.Hostname, server.Port, constants.DefaultPasswordResetPath)
+	}
+	u, err := url.Parse(reset.ResetURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") || u.Fragment != "" {
+		return false, "security.password_reset.reset_url must be a http:// or https:// url without fragment"
+	}
+
+	if reset.ExpirySeconds == 0 {
+		reset.ExpirySeconds = constants.DefaultPasswordResetExpiry
+	}
+	if reset.MaxRequestsPerAccount == 0 {
+		reset.MaxRequestsPerAccount = constants.DefaultPasswordResetRequestsPerAccount
+	}
+	if reset.MaxRequestsPerIP == 0 {
+		reset.MaxRequestsPerIP = constants.DefaultPasswordResetRequestsPerIP
+	}
+	if reset.RateLimitWindowSeconds == 0 {
+		reset.RateLimitWindowSeconds = constants.DefaultPasswordResetRateLimitWindow
+	}
+	if reset.ExpirySeconds < 0 || reset.MaxRequestsPerAccount < 0 || reset.MaxRequestsPerIP < 0 ||
+		reset.RateLimitWindowSeconds < 0 {
+		return false, "security.password_reset values must be greater than 0"
+	}
+
+	return true, ""
+}
+
+func validateLDAPConfig(ldap *LDAPConfig) (bool, string) {
+	u, err := url.Parse(ldap.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "ldap" && u.Scheme != "ldaps") {
+		return false, "security.ldap.url must be a ldap:// or ldaps:// url"
+	}
+	if ldap.StartTLS && u.Scheme == "ldaps" {
+		return false, "security.ldap.start_tls cannot be used with ldaps:// url"
+	}
+	if u.Scheme == "ldap" && !ldap.StartTLS {
+		log.Logger().Warn().Msg("Passwords are sent to LDAP server without encryption. Use ldaps:// url or " +
+			"security.ldap.start_tls")
+	}
+	if ldap.BaseDN == "" {
+		return false, "security.ldap.base_dn cannot be empty when security.ldap.enabled = true"
+	}
+	if ldap.BindDN != "" && ldap.BindPassword == "" {
+		return false, "security.ldap.bind_password cannot be empty when security.ldap.bind_dn is set"
+	}
+
+	if ldap.TimeoutSeconds == 0 {
+		ldap.TimeoutSeconds = constants.DefaultLDAPTimeout
+	}
+	if ldap.TimeoutSeconds < 0 {
+		return false, "security.ldap.timeout_seconds must be greater than 0"
+	}
+	if ldap.UserFilter == "" {
+		ldap.UserFilter = constants.DefaultLDAPUserFilter
+	}
+	if strings.Count(ldap.UserFilter, "%s") != 1 {
+		return false, "security.ldap.user_filter must contain %s once"
+	}
+	if ldap.GroupFilter != "" && strings.Count(ldap.GroupFilter, "%s") != 1 {
+		return false, "security.ldap.group_filter must contain %s once"
+	}
+	if ldap.GroupBaseDN == "" {
+		ldap.GroupBaseDN = ldap.BaseDN
+	}
+	if ldap.UsernameAttribute == "" {
+		ldap.UsernameAttribute = constants.DefaultLDAPUsernameAttribute
+	}
+	if ldap.EmailAttribute == "" {
+		ldap.EmailAttribute = constants.DefaultLDAPEmailAttribute
+	}
+	if ldap.DisplayNameAttribute == "" {
+		ldap.DisplayNameAttribute = constants.DefaultLDAPDisplayNameAttribute
+	}
+	if ldap.GroupNameAttribute == "" {
+		ldap.GroupNameAttribute = constants.DefaultLDAPGroupNameAttribute
+	}
+
+	roles := []string{constants.RoleAdmin, constants.RoleMaintainer, constants.RoleDeveloper, constants.RoleGuest}
+	for group, role := range ldap.RoleMapping {
+		if !slices.Contains(roles, role) {
+			return false, fmt.Sprintf("unsupported role(%s) for security.ldap.role_mapping.%s", role, group)
+		}
+	}
+	if ldap.DefaultRole != "" && !slices.Contains(roles, ldap.DefaultRole) {
+		return false, fmt.Sprintf("unsupported role for security.ldap.default_role: %s", ldap.DefaultRole)
+	}
+
+	if ldap.CAFile != "" {
+		caCerts, err := os.ReadFile(ldap.CAFile)
+		if err != nil {
+			return false, fmt.Sprintf("unable to read security.ldap.ca_file: %s", err.Error())
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(caCerts) {
+			return false, "security.ldap.ca_file doesn't have PEM encoded certificates"
+		}
+	}
+
+	return true, ""
+}
+
 func defaultConfig(severHome string) *AppConfig {
 	return &AppConfig{
 		Server: MgmtServerConfig{
@@ -342,10 +860,17 @@ func defaultConfig(severHome string) *AppConfig {
 			Enabled:  true,
 			Hostname: "localhost",
 			Port:     5000,
+			Routing: RegistryRoutingConfig{
+				Mode: constants.RegistryRoutingModePort,
+			},
 		},
 		UpstreamRegistry: UpstreamRegistryConfig{
-			Enabled: true,
+			Enabled:     true,
+			HealthCheck: defaultUpstreamHealthCheckConfig(),
+			RateLimit:   defaultUpstreamRateLimitConfig(),
+			Sync:        defaultUpstreamSyncConfig(),
 		},
+		Replication: defaultReplicationConfig(),
 		Admin: AdminUserAccountConfig{
 			Username:      "admin",
 			Password:      "admin",
@@ -376,44 +901,41 @@ func defaultConfig(severHome string) *AppConfig {
 	}
 }
 
-func validateES256KeyPair(privPath, pubPath string) (*ecdsa.PrivateKey, *ecdsa.PublicKey, error) {
+// loadSigningKeyPair reads the key pair of auth tokens. Keys can be PEM or JWK. The public key is derived from the
+// private key if pubPath is empty.
+func loadSigningKeyPair(algorithm, privPath, pubPath string) (crypto.Signer, crypto.PublicKey, error) {
 	privBytes, err := os.ReadFile(privPath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("private key file is missing or unreadable: %w", err)
 	}
 
-	block, _ := pem.Decode(privBytes)
-	if block == nil || block.Type != "EC PRIVATE KEY" {
-		return nil, nil, fmt.Errorf("invalid private key format: expected EC PRIVATE KEY PEM block")
-	}
-
-	privKey, err := x509.ParseECPrivateKey(block.Bytes)
+	privKey, err := lib.ParsePrivateKey(privBytes)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse ECDSA private key: %w", err)
+		return nil, nil, err
 	}
 
-	pubBytes, err := os.ReadFile(pubPath)
+	err = lib.ValidateKey(algorithm, privKey.Public())
 	if err != nil {
-		return privKey, nil, fmt.Errorf("public key file is missing or unreadable: %w", err)
+		return nil, nil, fmt.Errorf("key can't be used with %s: %w", algorithm, err)
 	}
 
-	pubBlock, _ := pem.Decode(pubBytes)
-	if pubBlock == nil || pubBlock.Type != "PUBLIC KEY" {
-		return privKey, nil, fmt.Errorf("invalid public key format: expected PUBLIC KEY PEM block")
+	if pubPath == "" {
+		return privKey, privKey.Public(), nil
 	}
 
-	pubKeyInterface, err := x509.ParsePKIXPublicKey(pubBlock.Bytes)
+	pubBytes, err := os.ReadFile(pubPath)
 	if err != nil {
-		return privKey, nil, fmt.Errorf("failed to parse public key: %w", err)
+		return privKey, nil, fmt.Errorf("public key file is missing or unreadable: %w", err)
 	}
 
-	pubkey, ok := pubKeyInterface.(*ecdsa.PublicKey)
-	if !ok {
-		return privKey, nil, fmt.Errorf("public key is not an ECDSA key")
+	pubKey, err := lib.ParsePublicKey(pubBytes)
+	if err != nil {
+		return privKey, nil, err
 	}
 
-	if privKey.PublicKey.X.Cmp(pubkey.X) != 0 || privKey.PublicKey.Y.Cmp(pubkey.Y) != 0 {
+	equal, ok := privKey.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !equal.Equal(pubKey) {
 		return nil, nil, fmt.Errorf("config error: public key does not match the private key")
 	}
-	return privKey, pubkey, nil
+	return privKey, pubKey, nil
 }
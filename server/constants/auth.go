@@ -5,9 +5,54 @@ const (
 	MaxFailedLoginAttempts = 5
 )
 
+// Refresh tokens renew auth tokens of a login session. They are sent only to auth APIs.
 const (
-	ClaimSubject = "sub"
-	ClaimRole    = "role"
+	RefreshTokenCookie     = "refresh_token"
+	RefreshTokenCookiePath = "/api/v1/auth"
+	RefreshTokenPrefix     = "oir_rt_"
+)
+
+// Grant types of login sessions
+const (
+	GrantTypePassword          = "password"
+	GrantTypeAuthorizationCode = "authorization_code"
+)
+
+// Identity providers of external accounts. Accounts without a linked identity are local accounts.
+const (
+	IdentityProviderOIDC = "oidc"
+	IdentityProviderLDAP = "ldap"
+)
+
+// Local accounts with two-factor authentication complete login with a TOTP or recovery code. The login challenge
+// token identifies the login between the two steps.
+const (
+	TwoFactorTokenPrefix     = "oir_2fa_"
+	TwoFactorChallengeExpiry = 300
+	TwoFactorRecoveryCodes   = 10
+	TwoFactorIssuer          = "Open Image Registry"
+)
+
+// PasswordResetTokenPrefix is the prefix of tokens in password reset links. Only the sha256 hash of a token is stored.
+const PasswordResetTokenPrefix = "oir_pwr_"
+
+// Signing keys of auth tokens. Retired keys verify tokens until their grace period ends.
+const (
+	SigningKeyStatusActive  = "active"
+	SigningKeyStatusRetired = "retired"
+	JWKSPath                = "/.well-known/jwks.json"
+)
+
+// OIDCStateCookie binds the authorization request to the browser which started the OIDC login.
+const (
+	OIDCStateCookie     = "oidc_state"
+	OIDCStateCookiePath = "/api/v1/auth/oidc"
+)
+
+const (
+	ClaimSubject   = "sub"
+	ClaimRole      = "role"
+	ClaimSessionID = "sid"
 )
 
 const (
@@ -16,4 +61,37 @@ const (
 	ContextSignatureHash = "sig_hash"
 	ContextExpAt         = "exp"
 	ContextIssuedAt      = "iat"
+	// ContextSessionID holds the login session of the auth token. It is not set for personal access tokens.
+	ContextSessionID = "session_id"
+	// ContextAuthMethod is either AuthMethodSession or AuthMethodAccessToken
+	ContextAuthMethod = "auth_method"
+	// ContextTokenScopes holds scopes of the personal access token used by the request
+	ContextTokenScopes = "token_scopes"
+	// ContextRegistryID holds the registry which serves the registry request. It is set before the request is
+	// authenticated.
+	ContextRegistryID = "registry_id"
+)
+
+const (
+	AuthMethodSession     = "session"
+	AuthMethodAccessToken = "access_token"
 )
+
+// Personal access tokens start with AccessTokenPrefix, so they can be told apart from JWTs in `Authorization`
+// header.
+const (
+	AccessTokenPrefix = "oir_pat_"
+	// AccessTokenDisplayLength is the number of leading characters of a token kept to identify it in listings
+	AccessTokenDisplayLength = 12
+	MaxAccessTokenExpiryDays = 365
+)
+
+// Scopes of personal access tokens. push implies pull and management:write implies management:read.
+const (
+	ScopePull            = "pull"
+	ScopePush            = "push"
+	ScopeManagementRead  = "management:read"
+	ScopeManagementWrite = "management:write"
+)
+
+var AccessTokenScopes = []string{ScopePull, ScopePush, ScopeManagementRead, ScopeManagementWrite}
@@ -13,4 +13,77 @@ const (
 // security
 const (
 	TokenSigningAlgoES256 = "ES256"
-)
\ No newline at end of file
+	// DefaultRefreshTokenExpiry is the lifetime of login sessions in seconds
+	DefaultRefreshTokenExpiry = 7 * 24 * 60 * 60
+	// DefaultRetiredKeyGrace is how long signing keys verify tokens after they are retired, in seconds
+	DefaultRetiredKeyGrace = 24 * 60 * 60
+	// DefaultRevocationReload is how often revoked tokens are reloaded from the database, in seconds
+	DefaultRevocationReload = 10
+)
+
+// oidc
+const (
+	// DefaultOIDCAuthRequestExpiry is how long an OIDC login may take in seconds
+	DefaultOIDCAuthRequestExpiry = 10 * 60
+	DefaultOIDCUsernameClaim     = "preferred_username"
+	DefaultOIDCGroupsClaim       = "groups"
+	DefaultOIDCTimeout           = 10
+)
+
+var DefaultOIDCScopes = []string{"openid", "profile", "email"}
+
+// ldap
+const (
+	DefaultLDAPUserFilter           = "(uid=%s)"
+	DefaultLDAPUsernameAttribute    = "uid"
+	DefaultLDAPEmailAttribute       = "mail"
+	DefaultLDAPDisplayNameAttribute = "cn"
+	DefaultLDAPGroupNameAttribute   = "cn"
+	DefaultLDAPTimeout              = 10
+)
+
+// password reset
+const (
+	// DefaultPasswordResetExpiry is how long a password reset link is valid in seconds
+	DefaultPasswordResetExpiry = 30 * 60
+	// DefaultPasswordResetPath is the page of the web app which completes password resets
+	DefaultPasswordResetPath               = "/reset-password"
+	DefaultPasswordResetRequestsPerAccount = 3
+	DefaultPasswordResetRequestsPerIP      = 10
+	DefaultPasswordResetRateLimitWindow    = 60 * 60
+)
+
+// upstream health checks
+const (
+	DefaultUpstreamHealthCheckInterval = 30
+	DefaultUpstreamHealthCheckTimeout  = 5
+	DefaultUpstreamFailureThreshold    = 3
+	DefaultUpstreamCircuitOpenDuration = 60
+)
+
+// upstream cache
+const (
+	// DefaultUpstreamNegativeCacheTTL is how long manifests which upstream reported as not found are
+	// not looked up again.
+	DefaultUpstreamNegativeCacheTTL = 60
+)
+
+// upstream rate limits
+const (
+	DefaultUpstreamLowQuotaThreshold = 10
+	DefaultUpstreamRetryAfter        = 60
+)
+
+// upstream sync jobs
+const (
+	DefaultUpstreamSyncPollInterval = 30
+)
+
+// replication
+const (
+	DefaultReplicationPollInterval = 10
+	DefaultReplicationMaxAttempts  = 5
+	DefaultReplicationBackoff      = 30
+	DefaultReplicationMaxBackoff   = 3600
+	DefaultReplicationTimeout      = 300
+)
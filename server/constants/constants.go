@@ -25,6 +25,39 @@ const HostedRegistryName = "HostedRegistry"
 // If namespace is not provided, We'll use this namespace.
 const DefaultNamespace = "library"
 
+// Types of members of group registries.
+const (
+	GroupMemberTypeHosted   = "Hosted"
+	GroupMemberTypeUpstream = "Upstream"
+)
+
+// Triggers of replication rules.
+const (
+	ReplicationTriggerPush      = "push"
+	ReplicationTriggerScheduled = "scheduled"
+)
+
+// Statuses of replication tasks.
+const (
+	ReplicationTaskPending   = "Pending"
+	ReplicationTaskRunning   = "Running"
+	ReplicationTaskSucceeded = "Succeeded"
+	ReplicationTaskFailed    = "Failed"
+)
+
+// Statuses of the last run of upstream sync jobs.
+const (
+	SyncJobRunning   = "Running"
+	SyncJobSucceeded = "Succeeded"
+	SyncJobFailed    = "Failed"
+)
+
+// Types of entries in upstream caches. Manifests are cached by tag or by digest.
+const (
+	CacheEntryTypeTag    = "tag"
+	CacheEntryTypeDigest = "digest"
+)
+
 const UnknownBlobMediaType = "unknown_media_type"
 
 const (
@@ -39,4 +72,39 @@ const (
 	RegistryVendorArtifactory = "artifactory"
 	RegistryVendorNexus       = "nexus"
 	RegistryVendorCustom      = "custom"
-)
\ No newline at end of file
+)
+const (
+	UpstreamAuthTypeAnonymous             = "anonymous"
+	UpstreamAuthTypeBasic                 = "basic"
+	UpstreamAuthTypeBearer                = "bearer"
+	UpstreamAuthTypeOAuth2                = "oauth2"
+	UpstreamAuthTypeAWSECR                = "aws_ecr"
+	UpstreamAuthTypeGCPServiceAccount     = "gcp_service_account"
+	UpstreamAuthTypeAzureServicePrincipal = "azure_service_principal"
+	UpstreamAuthTypeHarborRobot           = "harbor_robot"
+	UpstreamAuthTypeArtifactoryToken      = "artifactory_token"
+	UpstreamAuthTypeGitLabToken           = "gitlab_token"
+	UpstreamAuthTypeGitHubToken           = "github_token"
+)
+
+// Health of upstream registries. It is decided by periodic probes and results of proxied requests.
+const (
+	UpstreamHealthUnknown   = "Unknown"
+	UpstreamHealthHealthy   = "Healthy"
+	UpstreamHealthUnhealthy = "Unhealthy"
+)
+
+const (
+	CircuitStateClosed   = "Closed"
+	CircuitStateOpen     = "Open"
+	CircuitStateHalfOpen = "HalfOpen"
+)
+
+// Routing modes of registry listeners.
+//   - port: hosted registry and each upstream are served on their own ports.
+//   - single_port: hosted registry and all upstreams are served on the port of image registry. Upstream is
+//     selected by `Host` header, `/v2/<upstream>/` path prefix or `ns` query parameter.
+const (
+	RegistryRoutingModePort       = "port"
+	RegistryRoutingModeSinglePort = "single_port"
+)
@@ -24,6 +24,33 @@ var (
 	AllowedRepositorySortFields   = []string{"name", "tags", "created_at"}
 )
 
+var (
+	AllowedUpstreamFilterFields = []string{"state", "vendor"}
+	AllowedUpstreamSortFields   = []string{"name", "port", "created_at"}
+)
+
+var (
+	AllowedGroupFilterFields = []string{"state"}
+	AllowedGroupSortFields   = []string{"name", "port", "created_at"}
+)
+
+var (
+	AllowedReplicationRuleFilterFields = []string{"trigger"}
+	AllowedReplicationRuleSortFields   = []string{"name", "created_at"}
+)
+
+var (
+	AllowedSyncJobFilterFields = []string{"enabled"}
+	AllowedSyncJobSortFields   = []string{"name", "created_at"}
+)
+
+var (
+	AllowedCachedRepositorySortFields = []string{"namespace", "name", "size", "last_accessed_at", "created_at"}
+	AllowedCacheEntryFilterFields     = []string{"type"}
+	AllowedCacheEntrySortFields       = []string{"identifier", "size", "expires_at", "last_accessed_at", "created_at"}
+	AllowedCachedBlobSortFields       = []string{"digest", "size", "last_accessed_at", "created_at"}
+)
+
 var (
 	AllowedResourceAccessFilterFields = []string{"access_level", "user_id", "resource_type", "resource_id"}
 	AllowedResourceAccessSortFields   = []string{"user", "granted_user", "granted_at"}
@@ -36,4 +63,5 @@ const FilterFieldIsPublic = "is_public"
 const FilterFieldTagCount = "tags"
 const FilterFieldRepositoryID = "repository_id"
 const FilterFieldAccessLevel = "access_level"
-const FilterFieldUserID = "user_id"
\ No newline at end of file
+const FilterFieldUserID = "user_id"
+const FilterFieldRegistryID = "registry_id"
\ No newline at end of file
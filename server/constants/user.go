@@ -23,4 +23,14 @@ const (
 	RoleMaintainer = "Maintainer"
 	RoleDeveloper  = "Developer"
 	RoleGuest      = "Guest"
-)
\ No newline at end of file
+	// RoleMachine is assigned only to machine accounts
+	RoleMachine = "Machine"
+)
+
+const (
+	AccountTypeUser    = "User"
+	AccountTypeMachine = "Machine"
+)
+
+// MachineSecretName is the name of the personal access token which is used as secret of a machine account
+const MachineSecretName = "machine-secret"
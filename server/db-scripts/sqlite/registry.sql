@@ -7,7 +7,7 @@ CREATE TABLE IF NOT EXISTS UPSTREAM_REGISTRY(
     'docker_hub', 'gcr', 'ecr', 'acr', 'ghcr', 'gitlab', 
     'quay', 'harbor', 'artifactory', 'nexus', 'custom'
   )),
-  STATE TEXT NOT NULL DEFAULT 'active' CHECK(STATE IN ('Active', 'Deprecated', 'Disabled')),
+  STATE TEXT NOT NULL DEFAULT 'Active' CHECK(STATE IN ('Active', 'Deprecated', 'Disabled')),
   PORT INTEGER NOT NULL UNIQUE CHECK(PORT BETWEEN 1025 AND 65535),
   UPSTREAM_URL TEXT NOT NULL CHECK(
     UPSTREAM_URL LIKE 'http%' AND 
@@ -35,6 +35,10 @@ CREATE TABLE IF NOT EXISTS UPSTREAM_REGISTRY_CACHE_STORAGE_CONFIG (
   REGISTRY_ID TEXT NOT NULL,
   CACHE_ENABLED INTEGER NOT NULL DEFAULT 0 CHECK(CACHE_ENABLED IN (0, 1)),
   TTL_SECONDS INTEGER NOT NULL DEFAULT 3600 CHECK(TTL_SECONDS BETWEEN 60 AND 2592000),
+  -- JSON array of {"tag_pattern", "ttl_seconds"}. The first rule which matches a tag overrides TTL_SECONDS.
+  TTL_RULES TEXT NOT NULL DEFAULT '[]',
+  -- Manifests which upstream reported as not found are not looked up again for NEGATIVE_TTL_SECONDS. 0 disables it.
+  NEGATIVE_TTL_SECONDS INTEGER NOT NULL DEFAULT 60 CHECK(NEGATIVE_TTL_SECONDS BETWEEN 0 AND 3600),
 
   STORAGE_LIMIT REAL DEFAULT 100 CHECK(STORAGE_LIMIT >= 1),
   CLEANUP_THRESHOLD_PERCENTAGE REAL NOT NULL DEFAULT 80.0 CHECK(
@@ -63,14 +67,114 @@ CREATE TABLE IF NOT EXISTS UPSTREAM_REGISTRY_NETWORK_CONFIG(
   RETRY_DELAY INTEGER NOT NULL DEFAULT 5 CHECK(RETRY_DELAY BETWEEN 1 AND 60),
   RETRY_BACKOFF_MULTIPLIER REAL NOT NULL DEFAULT 2.0 CHECK(RETRY_BACKOFF_MULTIPLIER BETWEEN 1.0 AND 5.0),
 
+  -- Outbound proxy
+  PROXY_ENABLED INTEGER NOT NULL DEFAULT 0,
+  PROXY_URL TEXT NOT NULL DEFAULT '',
+  PROXY_USERNAME TEXT NOT NULL DEFAULT '',
+  PROXY_PASSWORD TEXT NOT NULL DEFAULT '',
+
+  -- TLS (PEM encoded)
+  TLS_CA_BUNDLE TEXT NOT NULL DEFAULT '',
+  TLS_CLIENT_CERT TEXT NOT NULL DEFAULT '',
+  TLS_CLIENT_KEY TEXT NOT NULL DEFAULT '',
+  TLS_INSECURE_SKIP_VERIFY INTEGER NOT NULL DEFAULT 0,
+
   CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   UPDATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   FOREIGN KEY (REGISTRY_ID) REFERENCES UPSTREAM_REGISTRY(ID) ON DELETE CASCADE,
   UNIQUE(REGISTRY_ID)
 );
 
+-- Sync job pre-fetches images of an upstream into the cache. Repositories, tag patterns and platforms are
+-- comma separated. Empty platforms means all platforms. Jobs with zero interval are run on demand only.
+CREATE TABLE IF NOT EXISTS UPSTREAM_SYNC_JOB(
+  ID TEXT PRIMARY KEY DEFAULT (HEX(RANDOMBLOB(16))),
+  REGISTRY_ID TEXT NOT NULL,
+  NAME TEXT NOT NULL CHECK(LENGTH(NAME) BETWEEN 3 AND 255),
+  REPOSITORIES TEXT NOT NULL,
+  TAG_PATTERNS TEXT NOT NULL,
+  PLATFORMS TEXT NOT NULL DEFAULT '',
+  SCHEDULE_INTERVAL_SECONDS INTEGER NOT NULL DEFAULT 0,
+  ENABLED INTEGER NOT NULL DEFAULT 1 CHECK(ENABLED IN (0, 1)),
+  LAST_RUN_AT TIMESTAMP,
+  LAST_RUN_STATUS TEXT NOT NULL DEFAULT '',
+  LAST_RUN_MESSAGE TEXT NOT NULL DEFAULT '',
+  LAST_SYNCED_IMAGES INTEGER NOT NULL DEFAULT 0,
+  CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UPDATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE(REGISTRY_ID, NAME),
+  FOREIGN KEY (REGISTRY_ID) REFERENCES UPSTREAM_REGISTRY(ID) ON DELETE CASCADE
+);
+
 ---------------- End of Upstream Registry and config -----------------------------------------------
 
+------ Group Registry -------------------------------------------------------------------------------
+-- Group registry resolves images through an ordered list of member registries (hosted registry or upstreams).
+CREATE TABLE IF NOT EXISTS GROUP_REGISTRY(
+  ID TEXT PRIMARY KEY DEFAULT (HEX(RANDOMBLOB(16))),
+  NAME TEXT NOT NULL UNIQUE CHECK(LENGTH(NAME) BETWEEN 3 AND 255),
+  DESCRIPTION TEXT NOT NULL CHECK(LENGTH(DESCRIPTION) <= 1000),
+  STATE TEXT NOT NULL DEFAULT 'Active' CHECK(STATE IN ('Active', 'Deprecated', 'Disabled')),
+  PORT INTEGER NOT NULL UNIQUE CHECK(PORT BETWEEN 1025 AND 65535),
+  -- Pushes are forwarded to this member. Empty value means the group is read-only.
+  PUSH_MEMBER_ID TEXT NOT NULL DEFAULT '',
+  CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UPDATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS GROUP_REGISTRY_MEMBER(
+  GROUP_ID TEXT NOT NULL,
+  MEMBER_ID TEXT NOT NULL, -- ID of hosted registry or an upstream registry
+  POSITION INTEGER NOT NULL,
+  PRIMARY KEY (GROUP_ID, MEMBER_ID),
+  FOREIGN KEY (GROUP_ID) REFERENCES GROUP_REGISTRY(ID) ON DELETE CASCADE
+);
+
+---------------- End of Group Registry ----------------------------------------------------------------
+
+------ Replication ----------------------------------------------------------------------------------
+-- Replication rule copies images of hosted registry to a remote OCI registry. Filters are comma separated
+-- glob patterns. Empty filter matches everything.
+CREATE TABLE IF NOT EXISTS REPLICATION_RULE(
+  ID TEXT PRIMARY KEY DEFAULT (HEX(RANDOMBLOB(16))),
+  NAME TEXT NOT NULL UNIQUE CHECK(LENGTH(NAME) BETWEEN 3 AND 255),
+  DESCRIPTION TEXT NOT NULL CHECK(LENGTH(DESCRIPTION) <= 1000),
+  TARGET_URL TEXT NOT NULL CHECK(
+    TARGET_URL LIKE 'http%' AND
+    LENGTH(TARGET_URL) <= 2048
+  ),
+  USERNAME TEXT NOT NULL DEFAULT '',
+  PASSWORD TEXT NOT NULL DEFAULT '',
+  NAMESPACE_FILTER TEXT NOT NULL DEFAULT '',
+  REPOSITORY_FILTER TEXT NOT NULL DEFAULT '',
+  TAG_FILTER TEXT NOT NULL DEFAULT '',
+  TRIGGER_TYPE TEXT NOT NULL CHECK(TRIGGER_TYPE IN ('push', 'scheduled')),
+  SCHEDULE_INTERVAL_SECONDS INTEGER NOT NULL DEFAULT 0,
+  ENABLED INTEGER NOT NULL DEFAULT 1 CHECK(ENABLED IN (0, 1)),
+  LAST_SCHEDULED_AT TIMESTAMP,
+  CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UPDATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Each task replicates a tag. Failed tasks are retried at NEXT_ATTEMPT_AT until attempts are exhausted.
+CREATE TABLE IF NOT EXISTS REPLICATION_TASK(
+  ID TEXT PRIMARY KEY DEFAULT (HEX(RANDOMBLOB(16))),
+  RULE_ID TEXT NOT NULL,
+  NAMESPACE TEXT NOT NULL,
+  REPOSITORY TEXT NOT NULL,
+  TAG TEXT NOT NULL,
+  STATUS TEXT NOT NULL DEFAULT 'Pending' CHECK(STATUS IN ('Pending', 'Running', 'Succeeded', 'Failed')),
+  ATTEMPTS INTEGER NOT NULL DEFAULT 0,
+  LAST_ERROR TEXT NOT NULL DEFAULT '',
+  NEXT_ATTEMPT_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  COMPLETED_AT TIMESTAMP,
+  CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UPDATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (RULE_ID) REFERENCES REPLICATION_RULE(ID) ON DELETE CASCADE
+);
+
+---------------- End of Replication -------------------------------------------------------------------
+
 ----------------- Namespace and Repository ---------------------------------------------------------
 
 CREATE TABLE IF NOT EXISTS REGISTRY_NAMESPACE (
@@ -115,6 +219,8 @@ CREATE TABLE IF NOT EXISTS IMAGE_BLOB_META (
   BLOB_DIGEST TEXT NOT NULL,
   SIZE INTEGER NOT NULL,
   LOCATION TEXT NOT NULL UNIQUE,
+  -- updated when a cached blob of an upstream is served
+  LAST_ACCESSED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   UPDATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   UNIQUE (REGISTRY_ID, NAMESPACE_ID, REPOSITORY_ID, BLOB_DIGEST),
@@ -149,8 +255,9 @@ CREATE TABLE IF NOT EXISTS IMAGE_TAG (
   FOREIGN KEY (NAMESPACE_ID) REFERENCES REGISTRY_NAMESPACE(ID) ON DELETE CASCADE
 );
 
+-- An image can be pushed with many tags. Therefore, a manifest can be linked to many tags.
 CREATE TABLE IF NOT EXISTS IMAGE_MANIFEST_TAG_MAPPING (
-  MANIFEST_ID  TEXT NOT NULL UNIQUE,
+  MANIFEST_ID  TEXT NOT NULL,
   TAG_ID TEXT NOT NULL UNIQUE,
   FOREIGN KEY (MANIFEST_ID) REFERENCES IMAGE_MANIFEST(ID),
   FOREIGN KEY (TAG_ID) REFERENCES IMAGE_TAG(ID)
@@ -183,6 +290,8 @@ CREATE TABLE IF NOT EXISTS IMAGE_REGISTRY_CACHE (
   IDENTIFIER TEXT NOT NULL,
   DIGEST TEXT NOT NULL,
   EXPIRES_AT TIMESTAMP NOT NULL,
+  -- updated when the cached manifest is served
+  LAST_ACCESSED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   UPDATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -239,6 +348,54 @@ BEGIN
     WHERE rowid = NEW.rowid;
 END;
 
+-- Trigger for GROUP_REGISTRY table
+DROP TRIGGER IF EXISTS trg_update_group_registry;
+CREATE TRIGGER trg_update_group_registry
+BEFORE UPDATE ON GROUP_REGISTRY
+FOR EACH ROW
+WHEN NEW.UPDATED_AT = OLD.UPDATED_AT
+BEGIN
+    UPDATE GROUP_REGISTRY 
+    SET UPDATED_AT = CURRENT_TIMESTAMP 
+    WHERE rowid = NEW.rowid;
+END;
+
+-- Trigger for UPSTREAM_SYNC_JOB table
+DROP TRIGGER IF EXISTS trg_update_upstream_sync_job;
+CREATE TRIGGER trg_update_upstream_sync_job
+BEFORE UPDATE ON UPSTREAM_SYNC_JOB
+FOR EACH ROW
+WHEN NEW.UPDATED_AT = OLD.UPDATED_AT
+BEGIN
+    UPDATE UPSTREAM_SYNC_JOB 
+    SET UPDATED_AT = CURRENT_TIMESTAMP 
+    WHERE rowid = NEW.rowid;
+END;
+
+-- Trigger for REPLICATION_RULE table
+DROP TRIGGER IF EXISTS trg_update_replication_rule;
+CREATE TRIGGER trg_update_replication_rule
+BEFORE UPDATE ON REPLICATION_RULE
+FOR EACH ROW
+WHEN NEW.UPDATED_AT = OLD.UPDATED_AT
+BEGIN
+    UPDATE REPLICATION_RULE 
+    SET UPDATED_AT = CURRENT_TIMESTAMP 
+    WHERE rowid = NEW.rowid;
+END;
+
+-- Trigger for REPLICATION_TASK table
+DROP TRIGGER IF EXISTS trg_update_replication_task;
+CREATE TRIGGER trg_update_replication_task
+BEFORE UPDATE ON REPLICATION_TASK
+FOR EACH ROW
+WHEN NEW.UPDATED_AT = OLD.UPDATED_AT
+BEGIN
+    UPDATE REPLICATION_TASK 
+    SET UPDATED_AT = CURRENT_TIMESTAMP 
+    WHERE rowid = NEW.rowid;
+END;
+
 -- Trigger for REGISTRY_NAMESPACE table
 DROP TRIGGER IF EXISTS trg_update_registry_namespace;
 CREATE TRIGGER trg_update_registry_namespace
@@ -328,7 +485,7 @@ END;
 CREATE TABLE IF NOT EXISTS USER_ACCOUNT (
     ID TEXT PRIMARY KEY DEFAULT (HEX(RANDOMBLOB(16))),
     USERNAME TEXT NOT NULL UNIQUE,
-    EMAIL TEXT NOT NULL UNIQUE,
+    EMAIL TEXT NOT NULL, -- machine accounts don't have an email
     PASSWORD TEXT NOT NULL,
     SALT TEXT NOT NULL,
     DISPLAY_NAME TEXT NOT NULL,
@@ -337,11 +494,56 @@ CREATE TABLE IF NOT EXISTS USER_ACCOUNT (
     LOCKED_AT TIMESTAMP,
     DELETED INTEGER NOT NULL DEFAULT 0,
     FAILED_ATTEMPTS INTEGER NOT NULL DEFAULT 0,
+    -- 'Machine' accounts are used by CI systems. They authenticate only with generated secrets.
+    ACCOUNT_TYPE TEXT NOT NULL DEFAULT 'User' CHECK(ACCOUNT_TYPE IN ('User', 'Machine')),
     CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     UPDATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     LAST_ACCESSED_AT TIMESTAMP -- it can be null
 );
 
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_account_email ON USER_ACCOUNT(EMAIL) WHERE ACCOUNT_TYPE = 'User';
+
+-- Accounts of external identity providers. Accounts without an identity are local accounts.
+CREATE TABLE IF NOT EXISTS USER_IDENTITY (
+  USER_ID TEXT PRIMARY KEY, -- an account is linked to a single identity
+  PROVIDER TEXT NOT NULL CHECK(PROVIDER IN ('oidc', 'ldap')),
+  SUBJECT TEXT NOT NULL, -- unique identifier of the user in the provider
+  CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE(PROVIDER, SUBJECT),
+  FOREIGN KEY (USER_ID) REFERENCES USER_ACCOUNT(ID) ON DELETE CASCADE
+);
+
+-- TOTP(RFC 6238) second factor of local accounts. Enrollment is pending until the user confirms a code.
+CREATE TABLE IF NOT EXISTS USER_TWO_FACTOR (
+  USER_ID TEXT PRIMARY KEY,
+  SECRET TEXT NOT NULL, -- base32 encoded
+  ENABLED INTEGER NOT NULL DEFAULT 0,
+  LAST_USED_STEP INTEGER NOT NULL DEFAULT 0, -- time step of the last accepted code, so codes can't be replayed
+  CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  ENABLED_AT TIMESTAMP,
+  FOREIGN KEY (USER_ID) REFERENCES USER_ACCOUNT(ID) ON DELETE CASCADE
+);
+
+-- One-time codes to log in without the authenticator app. Only sha256 hashes are stored.
+CREATE TABLE IF NOT EXISTS USER_TWO_FACTOR_RECOVERY_CODE (
+  USER_ID TEXT NOT NULL,
+  CODE_HASH TEXT NOT NULL,
+  USED_AT TIMESTAMP,
+  PRIMARY KEY (USER_ID, CODE_HASH),
+  FOREIGN KEY (USER_ID) REFERENCES USER_ACCOUNT(ID) ON DELETE CASCADE
+);
+
+-- Logins waiting for the second factor after the first factor was verified by a password, LDAP or OIDC
+CREATE TABLE IF NOT EXISTS TWO_FACTOR_LOGIN_CHALLENGE (
+  TOKEN_HASH TEXT PRIMARY KEY,
+  USER_ID TEXT NOT NULL,
+  SCOPE_HASH_SHA256 TEXT,
+  GRANT_TYPE TEXT NOT NULL DEFAULT 'password',
+  CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  EXPIRES_AT TIMESTAMP NOT NULL,
+  FOREIGN KEY (USER_ID) REFERENCES USER_ACCOUNT(ID) ON DELETE CASCADE
+);
+
 CREATE TABLE IF NOT EXISTS USER_ACCOUNT_RECOVERY(
   RECOVERY_UUID TEXT PRIMARY KEY,
   USER_ID TEXT NOT NULL UNIQUE, -- a user only have a password-recovery at a time.
@@ -353,6 +555,8 @@ CREATE TABLE IF NOT EXISTS USER_ACCOUNT_RECOVERY(
 
 CREATE TABLE IF NOT EXISTS USER_ROLE(
     NAME TEXT PRIMARY KEY,
+    -- local accounts of the role must log in with a second factor
+    REQUIRE_TWO_FACTOR INTEGER NOT NULL DEFAULT 0,
     CREATED_AT TEXT DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -369,6 +573,7 @@ INSERT OR IGNORE INTO USER_ROLE(NAME) VALUES('Admin');
 INSERT OR IGNORE INTO USER_ROLE(NAME) VALUES('Maintainer');
 INSERT OR IGNORE INTO USER_ROLE(NAME) VALUES('Developer');
 INSERT OR IGNORE INTO USER_ROLE(NAME) VALUES('Guest');
+INSERT OR IGNORE INTO USER_ROLE(NAME) VALUES('Machine');
 
 CREATE TABLE IF NOT EXISTS RESOURCE_ACCESS (
     ID TEXT PRIMARY KEY DEFAULT (HEX(RANDOMBLOB(16))),
@@ -421,15 +626,26 @@ CREATE TABLE IF NOT EXISTS OAUTH_SCOPE_ROLE_BINDING (
 );
 
 CREATE TABLE IF NOT EXISTS OAUTH_AUTH_SESSION (
-  SESSION_ID TEXT PRIMARY KEY,
+  SESSION_ID TEXT PRIMARY KEY DEFAULT (HEX(RANDOMBLOB(16))),
   USER_ID TEXT NOT NULL,
   SCOPE_HASH_SHA256 TEXT NOT NULL,
+  REFRESH_TOKEN_HASH TEXT NOT NULL UNIQUE, -- rotated on every refresh
   ISSUED_AT  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   EXPIRES_AT  TIMESTAMP NOT NULL,
   LAST_ACCESSED_AT  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   USER_AGENT TEXT NOT NULL,
   IP_ADDRESS TEXT NOT NULL,
-  GRANT_TYPE TEXT NOT NULL -- -- "password", CURRENTLLY WE ONLY SUPPORTS PASSWORD
+  GRANT_TYPE TEXT NOT NULL, -- "password" or "authorization_code"
+  FOREIGN KEY (USER_ID) REFERENCES USER_ACCOUNT(ID) ON DELETE CASCADE
+);
+
+-- OIDC logins which are waiting for the callback of the identity provider. Rows are deleted on callback.
+CREATE TABLE IF NOT EXISTS OAUTH_AUTHORIZATION_REQUEST (
+  STATE TEXT PRIMARY KEY,
+  NONCE TEXT NOT NULL,
+  CODE_VERIFIER TEXT NOT NULL, -- PKCE
+  CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  EXPIRES_AT TIMESTAMP NOT NULL
 );
 
 CREATE TABLE IF NOT EXISTS OAUTH_AUTH_SESSION_SCOPE (
@@ -481,4 +697,42 @@ CREATE TABLE IF NOT EXISTS REVOKED_TOKENS(
   ISSUED_AT BIGINT NOT NULL,
   USER_ID TEXT NOT NULL,
   FOREIGN KEY (USER_ID) REFERENCES USER_ACCOUNT(ID) ON DELETE CASCADE
-);
\ No newline at end of file
+);
+-- revoked tokens are loaded and purged by expiry
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON REVOKED_TOKENS(EXPIRES_AT);
+-- Personal access tokens authenticate scripts and docker clients. Only the sha256 hash of the token is stored.
+-- SCOPES is a comma separated list of pull, push, management:read and management:write.
+CREATE TABLE IF NOT EXISTS PERSONAL_ACCESS_TOKEN(
+  ID TEXT PRIMARY KEY DEFAULT (HEX(RANDOMBLOB(16))),
+  USER_ID TEXT NOT NULL,
+  NAME TEXT NOT NULL CHECK(LENGTH(NAME) BETWEEN 3 AND 100),
+  TOKEN_PREFIX TEXT NOT NULL,
+  TOKEN_HASH TEXT NOT NULL UNIQUE,
+  SCOPES TEXT NOT NULL,
+  EXPIRES_AT TIMESTAMP, -- null means the token never expires
+  LAST_USED_AT TIMESTAMP,
+  REVOKED_AT TIMESTAMP,
+  CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (USER_ID) REFERENCES USER_ACCOUNT(ID) ON DELETE CASCADE
+);
+
+-- Names of revoked tokens can be reused
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_access_token_name
+ON PERSONAL_ACCESS_TOKEN(USER_ID, NAME) WHERE REVOKED_AT IS NULL;
+
+-- Keys which sign auth tokens, identified by the kid header of tokens. Tokens are signed with the active key.
+-- Retired keys only verify tokens until their grace period ends.
+-- Private keys are stored as plaintext PEM. Anyone who can read the database can sign tokens, so restrict access to
+-- the database and its backups like access to the key files.
+CREATE TABLE IF NOT EXISTS JWT_SIGNING_KEY(
+  KID TEXT PRIMARY KEY,
+  ALGORITHM TEXT NOT NULL,
+  PRIVATE_KEY TEXT NOT NULL, -- PEM encoded
+  PUBLIC_KEY TEXT NOT NULL, -- PEM encoded
+  ACTIVE INTEGER NOT NULL DEFAULT 0,
+  CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  RETIRED_AT TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jwt_signing_key_active
+ON JWT_SIGNING_KEY(ACTIVE) WHERE ACTIVE = 1;
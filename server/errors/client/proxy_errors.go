@@ -14,6 +14,7 @@ const (
 	CodeProxyResponseBodyMismatch = 7003
 	CodeProxyUnsupportedMediaType = 7004
 	CodeProxyArtifactNotFound     = 7005
+	CodeProxyUnauthorized         = 7006
 	CodeUnclassifiedClientError   = 7049
 )
 
@@ -33,6 +34,9 @@ var (
 	ErrProxyArtifactNotFound = &ProxyClientError{
 		errCode: CodeProxyArtifactNotFound,
 	}
+	ErrProxyUnauthorized = &ProxyClientError{
+		errCode: CodeProxyUnauthorized,
+	}
 	ErrUnclassifiedClientError = &ProxyClientError{
 		errCode: CodeUnclassifiedClientError,
 	}
@@ -58,9 +62,17 @@ func (ce *ProxyClientError) Error() string {
 	if ce.request != "" {
 		msg += fmt.Sprintf(" (request: %q)", ce.request)
 	}
+	if ce.err != nil {
+		msg += ": " + ce.err.Error()
+	}
 	return msg
 }
 
+// Code returns the error code of the client error. e.g. CodeProxyArtifactNotFound
+func (ce *ProxyClientError) Code() int {
+	return ce.errCode
+}
+
 func (ce *ProxyClientError) Unwrap() error {
 	return ce.err
 }
@@ -72,13 +84,35 @@ func (ce *ProxyClientError) Is(target error) bool {
 	return false
 }
 
+// ClassifyError classifies errors of requests to upstream. If err is nil, the error is classified by the status
+// code of resp.
 func ClassifyError(err error, request string, resp *http.Response) *ProxyClientError {
 	if err == nil {
-		if resp != nil && resp.StatusCode == http.StatusNotFound {
+		if resp == nil {
+			return &ProxyClientError{
+				errCode: CodeUnclassifiedClientError,
+				request: request,
+			}
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
 			return &ProxyClientError{
 				errCode: CodeProxyArtifactNotFound,
 				request: request,
 			}
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			return &ProxyClientError{
+				err:     fmt.Errorf("upstream rejected credentials with status code: %d", resp.StatusCode),
+				errCode: CodeProxyUnauthorized,
+				request: request,
+			}
+		case resp.StatusCode >= 400:
+			return &ProxyClientError{
+				err:     fmt.Errorf("unexpected status code: %d", resp.StatusCode),
+				errCode: CodeProxyUnexpectedStatusCode,
+				request: request,
+			}
 		}
 
 		return &ProxyClientError{
@@ -119,31 +153,33 @@ func ClassifyError(err error, request string, resp *http.Response) *ProxyClientE
 	}
 }
 
+// UnwrapClientError returns the code of the client error in the chain of err.
 func UnwrapClientError(err error) (ok bool, errorCode int) {
-	if ce, ok := err.(*ProxyClientError); ok {
-		return ok, ce.errCode
+	var ce *ProxyClientError
+	if errors.As(err, &ce) {
+		return true, ce.errCode
 	}
 	return false, CodeUnclassifiedClientError
 }
 
 func IsProxyConnectionFailed(err error) bool {
-	cErr, ok := err.(*ProxyClientError)
-	return ok && cErr.errCode == CodeProxyConnectionFailed
+	return errors.Is(err, ErrProxyConnectionFailed)
 }
 
 func IsProxyUnexpectedStatus(err error) bool {
-	cErr, ok := err.(*ProxyClientError)
-	return ok && cErr.errCode == CodeProxyUnexpectedStatusCode
+	return errors.Is(err, ErrProxyUnexpectedStatusCode)
 }
 
 func IsProxyResponseBodyMismatch(err error) bool {
-	cErr, ok := err.(*ProxyClientError)
-	return ok && cErr.errCode == CodeProxyResponseBodyMismatch
+	return errors.Is(err, ErrProxyResponseBodyMismatch)
 }
 
 func IsNotFound(err error) bool {
-	cErr, ok := err.(*ProxyClientError)
-	return ok && cErr.errCode == CodeProxyArtifactNotFound
+	return errors.Is(err, ErrProxyArtifactNotFound)
+}
+
+func IsProxyUnauthorized(err error) bool {
+	return errors.Is(err, ErrProxyUnauthorized)
 }
 
 func ProxyUnexpectedStatusError(expected, actual int, req string) *ProxyClientError {
@@ -156,7 +192,7 @@ func ProxyUnexpectedStatusError(expected, actual int, req string) *ProxyClientEr
 
 func ProxyUnsupportedMediaTypeError(mediaType, req string) *ProxyClientError {
 	return &ProxyClientError{
-		errCode: CodeProxyUnexpectedStatusCode,
+		errCode: CodeProxyUnsupportedMediaType,
 		err:     fmt.Errorf("Unsuppoted Media type: %s", mediaType),
 		request: req,
 	}
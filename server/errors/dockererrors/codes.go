@@ -32,6 +32,7 @@ const (
 	ErrCodeUnsupported             = "UNSUPPORTED"
 	ErrCodeTooManyRequests         = "TOOMANYREQUESTS"
 	ErrCodePaginationNumberInvalid = "PAGINATION_NUMBER_INVALID"
+	ErrCodeUnavailable             = "UNAVAILABLE"
 )
 
 // ErrorMessages maps error codes to their standard messages
@@ -54,6 +55,7 @@ var ErrorMessages = map[string]string{
 	ErrCodeUnsupported:             "The operation is unsupported",
 	ErrCodeTooManyRequests:         "too many requests",
 	ErrCodePaginationNumberInvalid: "invalid number of results requested",
+	ErrCodeUnavailable:             "upstream registry is unavailable",
 }
 
 // StatusCodes maps error codes to HTTP status codes
@@ -76,4 +78,5 @@ var StatusCodes = map[string]int{
 	ErrCodeUnsupported:             405,
 	ErrCodeTooManyRequests:         429,
 	ErrCodePaginationNumberInvalid: 400,
+	ErrCodeUnavailable:             503,
 }
\ No newline at end of file
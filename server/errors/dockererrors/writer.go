@@ -52,6 +52,18 @@ func WriteErrorWithStatus(w http.ResponseWriter, statusCode int, errorCode strin
 }
 
 
+// WriteErrorWithMessage writes an error with a custom HTTP status code and a message other than the standard
+// message of the error code
+func WriteErrorWithMessage(w http.ResponseWriter, statusCode int, errorCode, message string, detail interface{}) {
+	dockerError := NewDockerError(errorCode, detail)
+	dockerError.Message = message
+	response := NewDockerErrorResponse(dockerError)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
 func WriteManifestNotFound(w http.ResponseWriter) {
 	WriteError(w, ErrCodeManifestUnknown, nil)
 }
@@ -77,6 +89,17 @@ func WriteUnauthorized(w http.ResponseWriter, realm, service string) {
 	WriteError(w, ErrCodeUnauthorized, nil)
 }
 
+// WriteBasicUnauthorized writes UNAUTHORIZED with a Basic challenge. Docker clients send the credentials of
+// `docker login` only after they are challenged.
+func WriteBasicUnauthorized(w http.ResponseWriter, realm string) {
+	if realm == "" {
+		realm = "registry"
+	}
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s"`, realm))
+	WriteError(w, ErrCodeUnauthorized, nil)
+}
+
 func WriteInvalidDigest(w http.ResponseWriter, digest string) {
 	detail := map[string]string{"digest": digest}
 	WriteError(w, ErrCodeDigestInvalid, detail)
@@ -101,6 +124,28 @@ func WriteTooManyRequests(w http.ResponseWriter) {
 	WriteError(w, ErrCodeTooManyRequests, nil)
 }
 
+func WriteUnavailable(w http.ResponseWriter) {
+	WriteError(w, ErrCodeUnavailable, nil)
+}
+
+// WriteUpstreamUnreachable writes UNAVAILABLE with 503 when the upstream registry can't be reached.
+func WriteUpstreamUnreachable(w http.ResponseWriter) {
+	WriteErrorWithMessage(w, http.StatusServiceUnavailable, ErrCodeUnavailable, "upstream registry is unreachable", nil)
+}
+
+// WriteBadGateway writes UNAVAILABLE with 502 when the upstream registry responded with an invalid response.
+func WriteBadGateway(w http.ResponseWriter) {
+	WriteErrorWithMessage(w, http.StatusBadGateway, ErrCodeUnavailable,
+		"upstream registry returned an invalid response", nil)
+}
+
+// WriteUpstreamUnauthorized writes UNAVAILABLE with 502 when the upstream registry rejected the configured
+// credentials. 401 isn't used since clients treat it as a login prompt, but they can't fix credentials of the upstream.
+func WriteUpstreamUnauthorized(w http.ResponseWriter) {
+	WriteErrorWithMessage(w, http.StatusBadGateway, ErrCodeUnavailable,
+		"upstream registry rejected the credentials of the proxy", nil)
+}
+
 func WriteInvalidRepository(w http.ResponseWriter) {
 	WriteError(w, ErrCodeNameInvalid, nil)
 }
@@ -0,0 +1,242 @@
+package lib
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // hash functions of crypto.Hash must be linked
+	_ "crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+const (
+	AlgoES256 = "ES256"
+	AlgoES384 = "ES384"
+	AlgoRS256 = "RS256"
+	AlgoPS256 = "PS256"
+	AlgoEdDSA = "EdDSA"
+)
+
+// RSAKeyBits is the size of generated RSA keys. Smaller keys are rejected.
+const RSAKeyBits = 2048
+
+var errInvalidSignature = errors.New("invalid signature")
+
+// signingMethod signs and verifies tokens of one algorithm (RFC 7518, RFC 8037)
+type signingMethod interface {
+	// validateKey checks the key can be used with the algorithm
+	validateKey(publicKey crypto.PublicKey) error
+	generateKey() (crypto.Signer, error)
+	sign(privateKey crypto.Signer, data []byte) ([]byte, error)
+	verify(publicKey crypto.PublicKey, data, signature []byte) error
+}
+
+var signingMethods = map[string]signingMethod{
+	AlgoES256: &ecdsaMethod{curve: elliptic.P256(), hash: crypto.SHA256},
+	AlgoES384: &ecdsaMethod{curve: elliptic.P384(), hash: crypto.SHA384},
+	AlgoRS256: &rsaMethod{hash: crypto.SHA256},
+	AlgoPS256: &rsaMethod{hash: crypto.SHA256, pss: true},
+	AlgoEdDSA: &ed25519Method{},
+}
+
+// SupportedAlgorithms returns the algorithms which can sign auth tokens
+func SupportedAlgorithms() []string {
+	algorithms := make([]string, 0, len(signingMethods))
+	for algorithm := range signingMethods {
+		algorithms = append(algorithms, algorithm)
+	}
+	slices.Sort(algorithms)
+	return algorithms
+}
+
+func getSigningMethod(algorithm string) (signingMethod, error) {
+	method, ok := signingMethods[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported jwt algorithm: %s", algorithm)
+	}
+	return method, nil
+}
+
+// ValidateKey checks the key can be used with the algorithm
+func ValidateKey(algorithm string, publicKey crypto.PublicKey) error {
+	method, err := getSigningMethod(algorithm)
+	if err != nil {
+		return err
+	}
+	return method.validateKey(publicKey)
+}
+
+// GenerateKey generates a private key for the algorithm
+func GenerateKey(algorithm string) (crypto.Signer, error) {
+	method, err := getSigningMethod(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return method.generateKey()
+}
+
+func digest(hash crypto.Hash, data []byte) []byte {
+	hasher := hash.New()
+	hasher.Write(data)
+	return hasher.Sum(nil)
+}
+
+// ecdsaMethod signs with ECDSA. Signatures are r and s of the curve size, as required by JWS.
+type ecdsaMethod struct {
+	curve elliptic.Curve
+	hash  crypto.Hash
+}
+
+func (m *ecdsaMethod) validateKey(publicKey crypto.PublicKey) error {
+	key, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("expected an ECDSA key, got %T", publicKey)
+	}
+	if key.Curve != m.curve {
+		return fmt.Errorf("expected a key of curve %s, got %s", m.curve.Params().Name, key.Curve.Params().Name)
+	}
+	return nil
+}
+
+func (m *ecdsaMethod) generateKey() (crypto.Signer, error) {
+	return ecdsa.GenerateKey(m.curve, rand.Reader)
+}
+
+func (m *ecdsaMethod) byteSize() int {
+	return (m.curve.Params().BitSize + 7) / 8
+}
+
+func (m *ecdsaMethod) sign(privateKey crypto.Signer, data []byte) ([]byte, error) {
+	key, ok := privateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an ECDSA key, got %T", privateKey)
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest(m.hash, data))
+	if err != nil {
+		return nil, err
+	}
+
+	byteSize := m.byteSize()
+	signature := make([]byte, 2*byteSize)
+	r.FillBytes(signature[:byteSize])
+	s.FillBytes(signature[byteSize:])
+	return signature, nil
+}
+
+func (m *ecdsaMethod) verify(publicKey crypto.PublicKey, data, signature []byte) error {
+	key, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("expected an ECDSA key, got %T", publicKey)
+	}
+
+	byteSize := m.byteSize()
+	if len(signature) != 2*byteSize {
+		return errInvalidSignature
+	}
+
+	r := new(big.Int).SetBytes(signature[:byteSize])
+	s := new(big.Int).SetBytes(signature[byteSize:])
+	if !ecdsa.Verify(key, digest(m.hash, data), r, s) {
+		return errInvalidSignature
+	}
+	return nil
+}
+
+// rsaMethod signs with RSASSA-PKCS1-v1_5, or RSASSA-PSS if pss is set
+type rsaMethod struct {
+	hash crypto.Hash
+	pss  bool
+}
+
+// pssOptions uses the salt length of the hash, as required by JWS
+var pssOptions = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+
+func (m *rsaMethod) validateKey(publicKey crypto.PublicKey) error {
+	key, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("expected an RSA key, got %T", publicKey)
+	}
+	if key.N.BitLen() < RSAKeyBits {
+		return fmt.Errorf("RSA key must be at least %d bits, got %d", RSAKeyBits, key.N.BitLen())
+	}
+	return nil
+}
+
+func (m *rsaMethod) generateKey() (crypto.Signer, error) {
+	return rsa.GenerateKey(rand.Reader, RSAKeyBits)
+}
+
+func (m *rsaMethod) sign(privateKey crypto.Signer, data []byte) ([]byte, error) {
+	key, ok := privateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an RSA key, got %T", privateKey)
+	}
+
+	if m.pss {
+		return rsa.SignPSS(rand.Reader, key, m.hash, digest(m.hash, data), pssOptions)
+	}
+	return rsa.SignPKCS1v15(rand.Reader, key, m.hash, digest(m.hash, data))
+}
+
+func (m *rsaMethod) verify(publicKey crypto.PublicKey, data, signature []byte) error {
+	key, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("expected an RSA key, got %T", publicKey)
+	}
+
+	var err error
+	if m.pss {
+		err = rsa.VerifyPSS(key, m.hash, digest(m.hash, data), signature, pssOptions)
+	} else {
+		err = rsa.VerifyPKCS1v15(key, m.hash, digest(m.hash, data), signature)
+	}
+	if err != nil {
+		return errInvalidSignature
+	}
+	return nil
+}
+
+// ed25519Method signs with EdDSA. Only Ed25519 keys are supported.
+type ed25519Method struct{}
+
+func (m *ed25519Method) validateKey(publicKey crypto.PublicKey) error {
+	key, ok := publicKey.(ed25519.PublicKey)
+	if !ok {
+		return fmt.Errorf("expected an Ed25519 key, got %T", publicKey)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return errors.New("invalid Ed25519 key size")
+	}
+	return nil
+}
+
+func (m *ed25519Method) generateKey() (crypto.Signer, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	return privateKey, err
+}
+
+func (m *ed25519Method) sign(privateKey crypto.Signer, data []byte) ([]byte, error) {
+	key, ok := privateKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an Ed25519 key, got %T", privateKey)
+	}
+	return ed25519.Sign(key, data), nil
+}
+
+func (m *ed25519Method) verify(publicKey crypto.PublicKey, data, signature []byte) error {
+	key, ok := publicKey.(ed25519.PublicKey)
+	if !ok {
+		return fmt.Errorf("expected an Ed25519 key, got %T", publicKey)
+	}
+	if !ed25519.Verify(key, data, signature) {
+		return errInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package lib
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// jwk holds the members of a JSON Web Key (RFC 7517) used by supported algorithms
+type jwk struct {
+	KeyType string `json:"kty"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	D       string `json:"d"`
+	P       string `json:"p"`
+	Q       string `json:"q"`
+}
+
+var jwkCurves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+}
+
+// ParsePrivateKey reads a private key from PEM (PKCS #8, SEC 1 or PKCS #1) or JWK
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	if isJSON(data) {
+		return parsePrivateJWK(data)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid private key format: expected PEM or JWK")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block: %s", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key: %T", key)
+	}
+	return signer, nil
+}
+
+// ParsePublicKey reads a public key from PEM (PKIX or PKCS #1) or JWK
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	if isJSON(data) {
+		var k jwk
+		err := json.Unmarshal(data, &k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWK: %w", err)
+		}
+		return k.publicKey()
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid public key format: expected PEM or JWK")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		return key, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block: %s", block.Type)
+	}
+}
+
+// MarshalPrivateKey encodes the key as PKCS #8 PEM
+func MarshalPrivateKey(privateKey crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// MarshalPublicKey encodes the key as PKIX PEM
+func MarshalPublicKey(publicKey crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// PublicJWK returns the required members of the key in JWK. kid, use and alg are not included.
+func PublicJWK(publicKey crypto.PublicKey) (map[string]string, error) {
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		byteSize := (key.Curve.Params().BitSize + 7) / 8
+		return map[string]string{
+			"kty": "EC",
+			"crv": key.Curve.Params().Name,
+			"x":   b64Encode(key.X.FillBytes(make([]byte, byteSize))),
+			"y":   b64Encode(key.Y.FillBytes(make([]byte, byteSize))),
+		}, nil
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"n":   b64Encode(key.N.Bytes()),
+			"e":   b64Encode(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   b64Encode(key),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key: %T", publicKey)
+	}
+}
+
+// KeyID returns the JWK thumbprint (RFC 7638) of the key. It identifies the key in the kid header.
+func KeyID(publicKey crypto.PublicKey) (string, error) {
+	members, err := PublicJWK(publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	// json.Marshal sorts the members and adds no whitespace, as required by RFC 7638
+	thumbprint, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(thumbprint)
+	return b64Encode(sum[:]), nil
+}
+
+func isJSON(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+func parsePrivateJWK(data []byte) (crypto.Signer, error) {
+	var k jwk
+	err := json.Unmarshal(data, &k)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWK: %w", err)
+	}
+	if k.D == "" {
+		return nil, errors.New("JWK is not a private key")
+	}
+
+	publicKey, err := k.publicKey()
+	if err != nil {
+		return nil, err
+	}
+	d, err := b64Decode(k.D)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWK member d: %w", err)
+	}
+
+	var privateKey crypto.Signer
+	switch pub := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		privateKey = &ecdsa.PrivateKey{PublicKey: *pub, D: new(big.Int).SetBytes(d)}
+	case *rsa.PublicKey:
+		p, err := b64Decode(k.P)
+		if err != nil || len(p) == 0 {
+			return nil, errors.New("invalid JWK member p")
+		}
+		q, err := b64Decode(k.Q)
+		if err != nil || len(q) == 0 {
+			return nil, errors.New("invalid JWK member q")
+		}
+		rsaKey := &rsa.PrivateKey{
+			PublicKey: *pub,
+			D:         new(big.Int).SetBytes(d),
+			Primes:    []*big.Int{new(big.Int).SetBytes(p), new(big.Int).SetBytes(q)},
+		}
+		err = rsaKey.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA key: %w", err)
+		}
+		rsaKey.Precompute()
+		privateKey = rsaKey
+	case ed25519.PublicKey:
+		if len(d) != ed25519.SeedSize {
+			return nil, errors.New("invalid JWK member d")
+		}
+		privateKey = ed25519.NewKeyFromSeed(d)
+	}
+
+	// the private key must belong to the public key of the JWK. RSA keys are checked by Validate.
+	errMismatch := errors.New("private key does not match the public key of the JWK")
+	switch key := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		// ECDH derives the public key from d, unlike the coordinates copied from the JWK
+		derived, err := key.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC key: %w", err)
+		}
+		expected, err := key.PublicKey.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC key: %w", err)
+		}
+		if !derived.PublicKey().Equal(expected) {
+			return nil, errMismatch
+		}
+	case ed25519.PrivateKey:
+		if !key.Public().(ed25519.PublicKey).Equal(publicKey) {
+			return nil, errMismatch
+		}
+	}
+
+	return privateKey, nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "EC":
+		curve, ok := jwkCurves[k.Curve]
+		if !ok {
+			return nil, fmt.Errorf("unsupported JWK curve: %s", k.Curve)
+		}
+		x, err := b64Decode(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWK member x: %w", err)
+		}
+		y, err := b64Decode(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWK member y: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		// ECDH rejects points which are not on the curve
+		if _, err := key.ECDH(); err != nil {
+			return nil, fmt.Errorf("invalid EC key: %w", err)
+		}
+		return key, nil
+	case "RSA":
+		n, err := b64Decode(k.N)
+		if err != nil || len(n) == 0 {
+			return nil, errors.New("invalid JWK member n")
+		}
+		e, err := b64Decode(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid JWK member e")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported JWK curve: %s", k.Curve)
+		}
+		x, err := b64Decode(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid JWK member x")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported JWK key type: %s", k.KeyType)
+	}
+}
@@ -1,14 +1,13 @@
 package lib
 
 import (
-	"crypto/ecdsa"
-	"crypto/rand"
-	"crypto/sha256"
+	"crypto"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"math/big"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,43 +20,155 @@ const (
 
 const (
 	HeaderFieldAlg = "alg"
+	HeaderFieldKid = "kid"
 	headerFieldTyp = "typ"
 )
 
-const (
-	AlgoES256 = "ES256"
-	TypeJWT   = "JWT"
-)
+const TypeJWT = "JWT"
 
 type JWTProvider interface {
 	Sign(claims map[string]any) (string, error)
 	Verify(token string) (map[string]any, error)
 }
 
-type OAuthEC256JWTAuthenticator struct {
-	privKey *ecdsa.PrivateKey
-	pubKey  *ecdsa.PublicKey
-	issuer  string
-	expiry  time.Duration
+// JWTKey is a key pair of auth tokens, identified by the kid header of tokens. PrivateKey is nil for keys which only
+// verify tokens.
+type JWTKey struct {
+	ID         string
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// OAuthJWTAuthenticator signs and verifies auth tokens with one algorithm. Tokens of other algorithms are rejected.
+type OAuthJWTAuthenticator struct {
+	algorithm  string
+	method     signingMethod
+	mu         sync.RWMutex
+	signingKey *JWTKey
+	verifyKeys map[string]*JWTKey
+	// defaultKeyID verifies tokens without kid header, which were issued before keys were rotated
+	defaultKeyID string
+	// unknownKeyHandler is called when a token is signed with a key which isn't loaded, so keys can be reloaded
+	unknownKeyHandler func(kid string)
+	issuer            string
+	expiry            time.Duration
+}
+
+// NewOAuthJWTAuthenticator creates an authenticator which signs tokens with the private key
+func NewOAuthJWTAuthenticator(algorithm string, privateKey crypto.Signer, issuer string,
+	expiry time.Duration) (*OAuthJWTAuthenticator, error) {
+	method, err := getSigningMethod(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	err = method.validateKey(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	keyID, err := KeyID(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	key := &JWTKey{
+		ID:         keyID,
+		PrivateKey: privateKey,
+		PublicKey:  privateKey.Public(),
+	}
+
+	return &OAuthJWTAuthenticator{
+		algorithm:    algorithm,
+		method:       method,
+		signingKey:   key,
+		verifyKeys:   map[string]*JWTKey{key.ID: key},
+		defaultKeyID: key.ID,
+		issuer:       issuer,
+		expiry:       expiry,
+	}, nil
+}
+
+// Algorithm returns the algorithm of tokens
+func (g *OAuthJWTAuthenticator) Algorithm() string {
+	return g.algorithm
+}
+
+// SetKeys replaces the keys. Tokens are signed with signingKey and verified with signingKey or one of verifyKeys.
+// Keys must be valid for the algorithm.
+func (g *OAuthJWTAuthenticator) SetKeys(signingKey *JWTKey, verifyKeys []*JWTKey) {
+	keys := make(map[string]*JWTKey, len(verifyKeys)+1)
+	for _, key := range verifyKeys {
+		keys[key.ID] = key
+	}
+	keys[signingKey.ID] = signingKey
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.signingKey = signingKey
+	g.verifyKeys = keys
+}
+
+// SetUnknownKeyHandler sets the handler which is called when a token is signed with a key which isn't loaded. Keys
+// are looked up again after the handler returns, so the handler can load keys rotated elsewhere with SetKeys.
+func (g *OAuthJWTAuthenticator) SetUnknownKeyHandler(handler func(kid string)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.unknownKeyHandler = handler
 }
 
-func NewOAuthEC256JWTAuthenticator(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey, issuer string,
-	expiry time.Duration) *OAuthEC256JWTAuthenticator {
-	return &OAuthEC256JWTAuthenticator{
-		privKey: privateKey,
-		pubKey:  publicKey,
-		issuer:  issuer,
-		expiry:  expiry,
+// PublicKeys returns the keys which verify tokens. The signing key is the first.
+func (g *OAuthJWTAuthenticator) PublicKeys() []*JWTKey {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	keys := []*JWTKey{g.signingKey}
+	for id, key := range g.verifyKeys {
+		if id != g.signingKey.ID {
+			keys = append(keys, key)
+		}
 	}
+	slices.SortFunc(keys[1:], func(a, b *JWTKey) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return keys
+}
+
+func (g *OAuthJWTAuthenticator) verifyKey(kid string) (*JWTKey, bool) {
+	key, ok, handler := g.lookupKey(kid)
+	if ok || handler == nil || kid == "" {
+		return key, ok
+	}
+
+	handler(kid)
+	key, ok, _ = g.lookupKey(kid)
+	return key, ok
 }
 
-func (g *OAuthEC256JWTAuthenticator) Sign(claims map[string]any) (string, error) {
+func (g *OAuthJWTAuthenticator) lookupKey(kid string) (key *JWTKey, ok bool, unknownKeyHandler func(kid string)) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if kid == "" {
+		kid = g.defaultKeyID
+	}
+	key, ok = g.verifyKeys[kid]
+	return key, ok, g.unknownKeyHandler
+}
+
+func (g *OAuthJWTAuthenticator) Sign(claims map[string]any) (string, error) {
 	// 1. Check subject claim
 	sub, ok := claims[ClaimSub]
 	if !ok || sub == "" {
 		return "", fmt.Errorf("invalid subject claim")
 	}
 
+	g.mu.RLock()
+	signingKey := g.signingKey
+	g.mu.RUnlock()
+
 	now := time.Now()
 
 	claims[ClaimIssuer] = g.issuer
@@ -70,7 +181,8 @@ func (g *OAuthEC256JWTAuthenticator) Sign(claims map[string]any) (string, error)
 	}
 
 	header := map[string]string{
-		HeaderFieldAlg: AlgoES256,
+		HeaderFieldAlg: g.algorithm,
+		HeaderFieldKid: signingKey.ID,
 		headerFieldTyp: TypeJWT,
 	}
 
@@ -84,34 +196,15 @@ func (g *OAuthEC256JWTAuthenticator) Sign(claims map[string]any) (string, error)
 
 	unsignedToken := headerEncoded + "." + bodyEncoded
 
-	hasher := sha256.New()
-	n, err := hasher.Write([]byte(unsignedToken))
-	if n != len(unsignedToken) {
-		return "", fmt.Errorf("jwt generation hashing failed")
-	}
-	if err != nil {
-		return "", fmt.Errorf("jwt generation hashing failed: %w", err)
-	}
-
-	hashed := hasher.Sum(nil)
-
-	// ECDSA signing
-	r, s, err := ecdsa.Sign(rand.Reader, g.privKey, hashed)
+	signature, err := g.method.sign(signingKey.PrivateKey, []byte(unsignedToken))
 	if err != nil {
 		return "", fmt.Errorf("jwt signing failed: %w", err)
 	}
 
-	params := g.privKey.Curve.Params()
-	byteSize := params.BitSize / 8 // Since we only consider P-256, We can divide by 8 withou any issues
-
-	signature := make([]byte, 2*byteSize)
-	r.FillBytes(signature[0:byteSize])
-	s.FillBytes(signature[byteSize:])
-
 	return unsignedToken + "." + b64Encode(signature), nil
 }
 
-func (g *OAuthEC256JWTAuthenticator) Verify(token string) (map[string]any, error) {
+func (g *OAuthJWTAuthenticator) Verify(token string) (map[string]any, error) {
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
 		return nil, fmt.Errorf("invalid jwt token format")
@@ -127,37 +220,30 @@ func (g *OAuthEC256JWTAuthenticator) Verify(token string) (map[string]any, error
 		return nil, fmt.Errorf("invald jwt header")
 	}
 
-	if header[HeaderFieldAlg] != AlgoES256 {
-		return nil, fmt.Errorf("unsupported jwt algorithm")
+	// only the configured algorithm is accepted, so tokens can't choose a weaker one
+	if header[HeaderFieldAlg] != g.algorithm {
+		return nil, fmt.Errorf("unexpected jwt algorithm")
 	}
 
 	if header[headerFieldTyp] != TypeJWT {
 		return nil, fmt.Errorf("not a jwt")
 	}
 
-	unsignedToken := parts[0] + "." + parts[1]
-	hasher := sha256.New()
-	n, err := hasher.Write([]byte(unsignedToken))
-	if err != nil {
-		return nil, fmt.Errorf("jwt hash calculation failed: %w", err)
-	}
-	if n != len(unsignedToken) {
-		return nil, fmt.Errorf("jwt hash calculation faild")
+	// keys are removed when their grace period after rotation ends. Unknown keys may have been rotated by another
+	// instance, so they are looked up again after the unknown key handler.
+	key, ok := g.verifyKey(header[HeaderFieldKid])
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key")
 	}
 
-	hashed := hasher.Sum(nil)
-
 	sig, err := b64Decode(parts[2])
 	if err != nil {
 		return nil, fmt.Errorf("signature decode failed: %w", err)
 	}
 
-	byteSize := len(sig) / 2
-	r := new(big.Int).SetBytes(sig[:byteSize])
-	s := new(big.Int).SetBytes(sig[byteSize:])
-
-	if !ecdsa.Verify(g.pubKey, hashed, r, s) {
-		return nil, fmt.Errorf("invalid signature")
+	err = g.method.verify(key.PublicKey, []byte(parts[0]+"."+parts[1]), sig)
+	if err != nil {
+		return nil, err
 	}
 
 	bodyBytes, err := b64Decode(parts[1])
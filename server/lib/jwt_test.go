@@ -0,0 +1,179 @@
+package lib
+
+import (
+	"crypto"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAuthenticator(t *testing.T, algorithm string) *OAuthJWTAuthenticator {
+	t.Helper()
+
+	privateKey, err := GenerateKey(algorithm)
+	require.NoError(t, err)
+
+	authenticator, err := NewOAuthJWTAuthenticator(algorithm, privateKey, "test-issuer", time.Minute)
+	require.NoError(t, err)
+	return authenticator
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, algorithm := range SupportedAlgorithms() {
+		t.Run(algorithm, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t, algorithm)
+
+			token, err := authenticator.Sign(map[string]any{ClaimSub: "user"})
+			require.NoError(t, err)
+
+			claims, err := authenticator.Verify(token)
+			require.NoError(t, err)
+			assert.Equal(t, "user", claims[ClaimSub])
+
+			// tampered tokens are rejected
+			parts := strings.Split(token, ".")
+			tampered, err := json.Marshal(map[string]any{ClaimSub: "admin", ClaimIssuer: "test-issuer",
+				ClaimExp: time.Now().Add(time.Minute).Unix()})
+			require.NoError(t, err)
+			_, err = authenticator.Verify(parts[0] + "." + b64Encode(tampered) + "." + parts[2])
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestVerifyRejectsOtherAlgorithms(t *testing.T) {
+	es256 := newTestAuthenticator(t, AlgoES256)
+	rs256 := newTestAuthenticator(t, AlgoRS256)
+
+	token, err := rs256.Sign(map[string]any{ClaimSub: "user"})
+	require.NoError(t, err)
+	_, err = es256.Verify(token)
+	assert.Error(t, err)
+
+	// tokens can't switch the algorithm of the key, e.g. RS256 to PS256
+	parts := strings.Split(token, ".")
+	header, err := json.Marshal(map[string]string{HeaderFieldAlg: AlgoPS256, HeaderFieldKid: rs256.PublicKeys()[0].ID,
+		headerFieldTyp: TypeJWT})
+	require.NoError(t, err)
+	_, err = rs256.Verify(b64Encode(header) + "." + parts[1] + "." + parts[2])
+	assert.Error(t, err)
+
+	header, err = json.Marshal(map[string]string{HeaderFieldAlg: "none", headerFieldTyp: TypeJWT})
+	require.NoError(t, err)
+	_, err = rs256.Verify(b64Encode(header) + "." + parts[1] + ".")
+	assert.Error(t, err)
+}
+
+func TestVerifyLooksUpUnknownKeysAgain(t *testing.T) {
+	authenticator := newTestAuthenticator(t, AlgoES256)
+	// another instance rotated the key
+	rotated := newTestAuthenticator(t, AlgoES256)
+
+	token, err := rotated.Sign(map[string]any{ClaimSub: "user"})
+	require.NoError(t, err)
+
+	_, err = authenticator.Verify(token)
+	assert.Error(t, err)
+
+	var unknownKIDs []string
+	authenticator.SetUnknownKeyHandler(func(kid string) {
+		unknownKIDs = append(unknownKIDs, kid)
+		authenticator.SetKeys(rotated.PublicKeys()[0], authenticator.PublicKeys())
+	})
+
+	claims, err := authenticator.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user", claims[ClaimSub])
+	assert.Equal(t, []string{rotated.PublicKeys()[0].ID}, unknownKIDs)
+
+	// known keys don't call the handler
+	_, err = authenticator.Verify(token)
+	require.NoError(t, err)
+	assert.Len(t, unknownKIDs, 1)
+}
+
+func TestNewOAuthJWTAuthenticatorRejectsInvalidKeys(t *testing.T) {
+	ecKey, err := GenerateKey(AlgoES256)
+	require.NoError(t, err)
+
+	_, err = NewOAuthJWTAuthenticator(AlgoES384, ecKey, "test-issuer", time.Minute)
+	assert.Error(t, err)
+	_, err = NewOAuthJWTAuthenticator(AlgoRS256, ecKey, "test-issuer", time.Minute)
+	assert.Error(t, err)
+	_, err = NewOAuthJWTAuthenticator("HS256", ecKey, "test-issuer", time.Minute)
+	assert.Error(t, err)
+}
+
+func TestParseKeys(t *testing.T) {
+	for _, algorithm := range SupportedAlgorithms() {
+		t.Run(algorithm, func(t *testing.T) {
+			privateKey, err := GenerateKey(algorithm)
+			require.NoError(t, err)
+
+			privatePEM, err := MarshalPrivateKey(privateKey)
+			require.NoError(t, err)
+			parsed, err := ParsePrivateKey([]byte(privatePEM))
+			require.NoError(t, err)
+			assert.True(t, parsed.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(privateKey.Public()))
+
+			publicPEM, err := MarshalPublicKey(privateKey.Public())
+			require.NoError(t, err)
+			parsedPublic, err := ParsePublicKey([]byte(publicPEM))
+			require.NoError(t, err)
+			assert.True(t, privateKey.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(parsedPublic))
+
+			members, err := PublicJWK(privateKey.Public())
+			require.NoError(t, err)
+			publicJWK, err := json.Marshal(members)
+			require.NoError(t, err)
+			parsedPublic, err = ParsePublicKey(publicJWK)
+			require.NoError(t, err)
+			assert.True(t, privateKey.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(parsedPublic))
+
+			// a JWK without d is not a private key
+			_, err = ParsePrivateKey(publicJWK)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParsePrivateJWK(t *testing.T) {
+	// Ed25519 key of RFC 8037 appendix A.1
+	privateJWK := `{"kty":"OKP","crv":"Ed25519",
+		"d":"nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A",
+		"x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`
+
+	privateKey, err := ParsePrivateKey([]byte(privateJWK))
+	require.NoError(t, err)
+	require.NoError(t, ValidateKey(AlgoEdDSA, privateKey.Public()))
+
+	// thumbprint of RFC 8037 appendix A.3
+	kid, err := KeyID(privateKey.Public())
+	require.NoError(t, err)
+	assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", kid)
+
+	// d of another key
+	mismatched := strings.Replace(privateJWK, "nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A",
+		"AWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A", 1)
+	_, err = ParsePrivateKey([]byte(mismatched))
+	assert.Error(t, err)
+}
+
+func TestKeyID(t *testing.T) {
+	// RSA key of RFC 7638 section 3.1
+	publicJWK := `{"kty":"RSA","e":"AQAB","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aP` +
+		`FFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2Qvzq` +
+		`Y368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINH` +
+		`aQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}`
+
+	publicKey, err := ParsePublicKey([]byte(publicJWK))
+	require.NoError(t, err)
+
+	kid, err := KeyID(publicKey)
+	require.NoError(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", kid)
+}
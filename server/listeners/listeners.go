@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"sync"
@@ -36,8 +37,11 @@ type RegistryListener struct {
 	RegName string
 	Server  *http.Server
 	Cancel  context.CancelFunc
-	Done    chan struct{}
-	Port    uint
+	// Abort cancels contexts of in-flight requests, e.g. pulls waiting on upstream registries. It is called
+	// when requests don't complete within the shutdown grace period.
+	Abort context.CancelFunc
+	Done  chan struct{}
+	Port  uint
 }
 
 func GetListenerManager() *ListenerManager {
@@ -93,18 +97,24 @@ func (lm *ListenerManager) RegisterListener(regId, regName string, port uint, h
 	// }
 
 	ctx, cancel := context.WithCancel(context.Background())
+	requestsCtx, abort := context.WithCancel(context.Background())
 
 	server := &http.Server{
 		Addr:    addr,
 		Handler: h,
+		BaseContext: func(net.Listener) context.Context {
+			return requestsCtx
+		},
 	}
 
+	done := make(chan struct{})
 	regLn := &RegistryListener{
 		RegId:   regId,
 		RegName: regName,
 		Server:  server,
 		Cancel:  cancel,
-		Done:    make(chan struct{}),
+		Abort:   abort,
+		Done:    done,
 		Port:    port,
 	}
 
@@ -113,17 +123,21 @@ func (lm *ListenerManager) RegisterListener(regId, regName string, port uint, h
 	lm.mu.Unlock()
 
 	go func() {
-		defer close(regLn.Done)
 		time.Sleep(listenDelayInSeconds * time.Second)
 		log.Logger().Info().Msgf("Listener is about to start on port %d for registry %s", port, regName)
 		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Logger().Error().Err(err).Msgf("Registry Listener went shutdown due to errors: %s", regId)
-
-			lm.cleanup(regId, port)
+			// release the port and registry through shutdown flow
+			cancel()
 		}
 	}()
 
 	go func() {
+		// Done is closed only after locks are released. So callers of `UnregisterListener`
+		// can register a listener on the same port immediately.
+		defer close(done)
+		defer abort()
+
 		<-ctx.Done()
 		log.Logger().Info().Msgf("Shutting down HTTP listener for registry: %s", regId)
 
@@ -132,18 +146,27 @@ func (lm *ListenerManager) RegisterListener(regId, regName string, port uint, h
 
 		if err := server.Shutdown(shutdownCtx); err != nil {
 			log.Logger().Error().Err(err).Msgf("Error occurred while shutting down HTTP listener for registry: %s", regId)
+			abort()
 			server.Close()
 		}
 
-		lm.cleanup(regId, port)
-
+		lm.release(regLn)
 	}()
 
 	return nil
 }
 
-func (lm *ListenerManager) UnregisterListener(regId string, waitTimeout time.Duration) error {
+// GetListener returns the running listener of the registry.
+func (lm *ListenerManager) GetListener(regId string) (*RegistryListener, bool) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
 	regLn, ok := lm.listeners[regId]
+	return regLn, ok
+}
+
+func (lm *ListenerManager) UnregisterListener(regId string, waitTimeout time.Duration) error {
+	regLn, ok := lm.GetListener(regId)
 	if !ok {
 		return nil
 	}
@@ -155,11 +178,59 @@ func (lm *ListenerManager) UnregisterListener(regId string, waitTimeout time.Dur
 	case <-regLn.Done:
 		return nil
 	case <-time.After(waitTimeout * time.Second):
-		lm.cleanup(regId, regLn.Port)
+		lm.release(regLn)
 		return fmt.Errorf("Timeout occurred while shutting down listener for registry: %s", regId)
 	}
 }
 
+// Shutdown stops all listeners. Requests which don't complete within gracePeriod are aborted, so shutdown isn't
+// held by pulls waiting on upstream registries.
+func (lm *ListenerManager) Shutdown(gracePeriod time.Duration) {
+	lm.mu.Lock()
+	regListeners := make([]*RegistryListener, 0, len(lm.listeners))
+	for _, regLn := range lm.listeners {
+		regListeners = append(regListeners, regLn)
+	}
+	lm.mu.Unlock()
+
+	for _, regLn := range regListeners {
+		regLn.Cancel()
+	}
+
+	deadline := time.After(gracePeriod)
+	for _, regLn := range regListeners {
+		select {
+		case <-regLn.Done:
+			continue
+		case <-deadline:
+		}
+
+		log.Logger().Warn().Msg("Aborting in-flight requests of registry listeners after the shutdown grace period")
+		for _, ln := range regListeners {
+			ln.Abort()
+		}
+		break
+	}
+
+	for _, regLn := range regListeners {
+		<-regLn.Done
+	}
+}
+
+// release cleans up the given listener only if it is still the registered listener of its registry.
+// A listener can be released twice (timeout and shutdown), the second call must not
+// release locks of a newer listener.
+func (lm *ListenerManager) release(regLn *RegistryListener) {
+	lm.mu.Lock()
+	current, ok := lm.listeners[regLn.RegId]
+	lm.mu.Unlock()
+
+	if !ok || current != regLn {
+		return
+	}
+	lm.cleanup(regLn.RegId, regLn.Port)
+}
+
 func (lm *ListenerManager) cleanup(regId string, port uint) {
 	lm.mu.Lock()
 	delete(lm.listeners, regId)
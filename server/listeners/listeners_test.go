@@ -1,6 +1,7 @@
 package listeners
 
 import (
+	"net"
 	"net/http"
 	"sync"
 	"testing"
@@ -115,3 +116,48 @@ func TestUnregisterListener(t *testing.T) {
 		lm.mu.Unlock()
 	})
 }
+
+func TestShutdown(t *testing.T) {
+	resetListenerManager()
+	GetListenerManager()
+
+	started := make(chan struct{})
+	aborted := make(chan struct{})
+	blockingHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(aborted)
+	})
+
+	err := lm.RegisterListener("test-shutdown", "test-shutdown", 6000, blockingHandler, 0)
+	require.Nil(t, err)
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", "localhost:6000")
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+
+	go http.Get("http://localhost:6000/")
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request did not reach the listener")
+	}
+
+	start := time.Now()
+	lm.Shutdown(200 * time.Millisecond)
+
+	select {
+	case <-aborted:
+	default:
+		t.Fatal("in-flight request was not aborted")
+	}
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	_, ok := lm.GetListener("test-shutdown")
+	assert.False(t, ok)
+}
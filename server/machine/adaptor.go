@@ -0,0 +1,47 @@
+package machine
+
+import (
+	"github.com/ksankeerth/open-image-registry/resource/access"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+func toMachineAccountDTO(m *machineAccount) *mgmt.MachineAccountDTO {
+	if m == nil {
+		return nil
+	}
+
+	dto := &mgmt.MachineAccountDTO{
+		Id:          m.account.Id,
+		Username:    m.account.Username,
+		DisplayName: m.account.DisplayName,
+		Locked:      m.account.Locked,
+		CreatedAt:   m.account.CreatedAt,
+		Access:      make([]*mgmt.ResourceAccessViewDTO, len(m.access)),
+		Secret:      toMachineSecretDTO(m.secret),
+	}
+
+	for i, a := range m.access {
+		dto.Access[i] = access.ToResourceAccessViewDTO(a)
+	}
+
+	if dto.Secret != nil {
+		dto.Secret.Secret = m.plainSecret
+	}
+
+	return dto
+}
+
+func toMachineSecretDTO(m *models.PersonalAccessToken) *mgmt.MachineSecretDTO {
+	if m == nil {
+		return nil
+	}
+
+	return &mgmt.MachineSecretDTO{
+		Id:          m.ID,
+		TokenPrefix: m.TokenPrefix,
+		ExpiresAt:   m.ExpiresAt,
+		LastUsedAt:  m.LastUsedAt,
+		CreatedAt:   m.CreatedAt,
+	}
+}
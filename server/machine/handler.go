@@ -0,0 +1,185 @@
+package machine
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/errors/httperrors"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/middleware"
+	"github.com/ksankeerth/open-image-registry/resource/access"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+)
+
+type MachineAPIHandler struct {
+	svc *machineService
+}
+
+// NewMachineAPIHandler creates a new handler of machine account APIs
+func NewMachineAPIHandler(s store.Store, accessManager *access.Manager) *MachineAPIHandler {
+	return &MachineAPIHandler{
+		svc: &machineService{
+			store:         s,
+			accessManager: accessManager,
+		},
+	}
+}
+
+func (h *MachineAPIHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	// Machine accounts are managed by admins and namespace maintainers.
+	r.Use(middleware.RequireRole(constants.RoleAdmin, constants.RoleMaintainer))
+
+	r.Post("/", h.createMachine)
+	r.Get("/", h.listMachines)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Get("/", h.getMachine)
+		r.Delete("/", h.deleteMachine)
+		r.Post("/secret", h.rotateSecret)
+	})
+
+	return r
+}
+
+// createMachine handles POST /api/v1/machines
+func (h *MachineAPIHandler) createMachine(w http.ResponseWriter, r *http.Request) {
+	var req mgmt.CreateMachineAccountRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when reading json request body: %s", r.RequestURI)
+		httperrors.BadRequest(w, 400, "Invalid payload")
+		return
+	}
+
+	valid, errMsg := ValidateCreateMachineAccount(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	username, _ := identity(r)
+
+	res, err := h.svc.createMachine(r.Context(), username, &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	h.writeResult(w, r, res)
+}
+
+// listMachines handles GET /api/v1/machines
+func (h *MachineAPIHandler) listMachines(w http.ResponseWriter, r *http.Request) {
+	username, role := identity(r)
+
+	machines, err := h.svc.listMachines(r.Context(), username, role)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	response := mgmt.ListMachineAccountsResponse{
+		Machines: make([]*mgmt.MachineAccountDTO, len(machines)),
+	}
+	for i, machine := range machines {
+		response.Machines[i] = toMachineAccountDTO(machine)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+// getMachine handles GET /api/v1/machines/{id}
+func (h *MachineAPIHandler) getMachine(w http.ResponseWriter, r *http.Request) {
+	username, role := identity(r)
+
+	res, err := h.svc.getMachine(r.Context(), username, role, chi.URLParam(r, "id"))
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	h.writeResult(w, r, res)
+}
+
+// rotateSecret handles POST /api/v1/machines/{id}/secret
+func (h *MachineAPIHandler) rotateSecret(w http.ResponseWriter, r *http.Request) {
+	var req mgmt.RotateMachineSecretRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	// body is optional since the secret never expires by default
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Logger().Error().Err(err).Msgf("Error occurred when reading json request body: %s", r.RequestURI)
+		httperrors.BadRequest(w, 400, "Invalid payload")
+		return
+	}
+
+	valid, errMsg := ValidateRotateMachineSecret(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	username, role := identity(r)
+
+	res, err := h.svc.rotateSecret(r.Context(), username, role, chi.URLParam(r, "id"), req.ExpiresInDays)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	h.writeResult(w, r, res)
+}
+
+// deleteMachine handles DELETE /api/v1/machines/{id}
+func (h *MachineAPIHandler) deleteMachine(w http.ResponseWriter, r *http.Request) {
+	username, role := identity(r)
+
+	res, err := h.svc.deleteMachine(r.Context(), username, role, chi.URLParam(r, "id"))
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if res.failed() {
+		httperrors.SendError(w, res.statusCode, res.errMsg)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *MachineAPIHandler) writeResult(w http.ResponseWriter, r *http.Request, res *machineOpResult) {
+	if res.failed() {
+		httperrors.SendError(w, res.statusCode, res.errMsg)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(res.statusCode)
+	err := json.NewEncoder(w).Encode(toMachineAccountDTO(res.machine))
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+func identity(r *http.Request) (username, role string) {
+	username, _ = r.Context().Value(constants.ContextUsername).(string)
+	role, _ = r.Context().Value(constants.ContextRole).(string)
+	return username, role
+}
@@ -0,0 +1,473 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/errors/dberrors"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/resource/access"
+	"github.com/ksankeerth/open-image-registry/security"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+// machineListLimit is the maximum number of machine accounts and resource access entries of a machine account
+// which are loaded at once
+const machineListLimit = 1000
+
+type machineService struct {
+	store         store.Store
+	accessManager *access.Manager
+}
+
+// machineAccount is a machine account along with its resource access and active secret
+type machineAccount struct {
+	account *models.UserAccount
+	access  []*models.ResourceAccessView
+	secret  *models.PersonalAccessToken
+	// plainSecret is set only when the secret is generated. It is returned to the caller only once.
+	plainSecret string
+}
+
+type machineOpResult struct {
+	statusCode int
+	errMsg     string
+	machine    *machineAccount
+}
+
+func (res *machineOpResult) failed() bool {
+	return res.statusCode >= http.StatusBadRequest
+}
+
+func (res *machineOpResult) fail(statusCode int, errMsg string) *machineOpResult {
+	res.statusCode = statusCode
+	res.errMsg = errMsg
+	return res
+}
+
+// createMachine creates a machine account scoped to the requested resources and generates its first secret.
+// Resource access is granted on behalf of the initiator, so Maintainers can only scope machine accounts to the
+// namespaces they maintain.
+func (svc *machineService) createMachine(reqCtx context.Context, initiator string,
+	req *mgmt.CreateMachineAccountRequest) (res *machineOpResult, err error) {
+	res = &machineOpResult{statusCode: http.StatusCreated}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil || res.failed() {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	grantor, err := svc.store.Users().GetByUsername(ctx, initiator)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", initiator)
+		return nil, err
+	}
+	if grantor == nil {
+		return res.fail(access.InitiatorNotFound.MapToHTTP(false)), nil
+	}
+
+	displayName := req.DisplayName
+	if displayName == "" {
+		displayName = req.Username
+	}
+
+	machineID, err := svc.store.Users().CreateMachine(ctx, req.Username, displayName)
+	if err != nil {
+		if yes, _ := dberrors.IsUniqueConstraint(err); yes {
+			err = nil
+			return res.fail(http.StatusConflict, "Username is already taken"), nil
+		}
+		log.Logger().Error().Err(err).Msgf("Error occurred when creating machine account: %s", req.Username)
+		return nil, err
+	}
+
+	err = svc.store.Users().AssignRole(ctx, machineID, constants.RoleMachine)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when assigning role to machine account: %s", req.Username)
+		return nil, err
+	}
+
+	for _, a := range req.Access {
+		reason, err := svc.accessManager.GrantAccess(ctx, a.ResourceID, a.ResourceType, grantor.Id, machineID,
+			a.AccessLevel)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Error occurred when granting access to machine account: %s",
+				req.Username)
+			return nil, err
+		}
+		if reason != access.Success {
+			log.Logger().Warn().Msgf("Machine account(%s) was not created since access to %s(%s) was not granted: %s",
+				req.Username, a.ResourceType, a.ResourceID, reason)
+			return res.fail(reason.MapToHTTP(false)), nil
+		}
+	}
+
+	plainSecret, err := svc.issueSecret(ctx, machineID, req.SecretExpiresInDays)
+	if err != nil {
+		return nil, err
+	}
+
+	res.machine, err = svc.loadMachine(ctx, machineID)
+	if err != nil {
+		return nil, err
+	}
+	res.machine.plainSecret = plainSecret
+
+	log.Logger().Info().Msgf("Machine account(%s) was created by %s with access to %d resource(s)", req.Username,
+		initiator, len(req.Access))
+
+	return res, nil
+}
+
+// listMachines returns machine accounts which the initiator can manage
+func (svc *machineService) listMachines(reqCtx context.Context, initiator, role string) (
+	machines []*machineAccount, err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	initiatorAcc, err := svc.store.Users().GetByUsername(ctx, initiator)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", initiator)
+		return nil, err
+	}
+	if initiatorAcc == nil {
+		return []*machineAccount{}, nil
+	}
+
+	accounts, _, err := svc.store.Users().ListUserAccounts(ctx, &store.ListQueryConditions{
+		Page:  1,
+		Limit: machineListLimit,
+		Filters: []store.Filter{
+			{
+				Field:    "role",
+				Operator: store.OpEqual,
+				Values:   []any{constants.RoleMachine},
+			},
+		},
+	})
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when listing machine accounts")
+		return nil, err
+	}
+
+	machines = make([]*machineAccount, 0, len(accounts))
+	for _, acc := range accounts {
+		machine, err := svc.loadMachine(ctx, acc.Id)
+		if err != nil {
+			return nil, err
+		}
+		if machine == nil {
+			continue
+		}
+
+		allowed, err := svc.canManage(ctx, initiatorAcc.Id, role, machine)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			machines = append(machines, machine)
+		}
+	}
+
+	return machines, nil
+}
+
+func (svc *machineService) getMachine(reqCtx context.Context, initiator, role, machineID string) (
+	res *machineOpResult, err error) {
+	res = &machineOpResult{statusCode: http.StatusOK}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	res.machine, err = svc.loadManagedMachine(ctx, initiator, role, machineID, res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// rotateSecret revokes the active secret of the machine account and generates a new one
+func (svc *machineService) rotateSecret(reqCtx context.Context, initiator, role, machineID string,
+	expiresInDays int) (res *machineOpResult, err error) {
+	res = &machineOpResult{statusCode: http.StatusOK}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil || res.failed() {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	machine, err := svc.loadManagedMachine(ctx, initiator, role, machineID, res)
+	if err != nil || machine == nil {
+		return res, err
+	}
+
+	if machine.secret != nil {
+		err = svc.store.AccessTokens().RevokeToken(ctx, machine.secret.ID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Error occurred when revoking secret of machine account(%s)", machineID)
+			return nil, err
+		}
+	}
+
+	plainSecret, err := svc.issueSecret(ctx, machineID, expiresInDays)
+	if err != nil {
+		return nil, err
+	}
+
+	res.machine, err = svc.loadMachine(ctx, machineID)
+	if err != nil {
+		return nil, err
+	}
+	res.machine.plainSecret = plainSecret
+
+	log.Logger().Info().Msgf("Secret of machine account(%s) was rotated by %s", machine.account.Username, initiator)
+
+	return res, nil
+}
+
+// deleteMachine revokes the secret and resource access of the machine account before deleting it
+func (svc *machineService) deleteMachine(reqCtx context.Context, initiator, role, machineID string) (
+	res *machineOpResult, err error) {
+	res = &machineOpResult{statusCode: http.StatusOK}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil || res.failed() {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	machine, err := svc.loadManagedMachine(ctx, initiator, role, machineID, res)
+	if err != nil || machine == nil {
+		return res, err
+	}
+
+	if machine.secret != nil {
+		err = svc.store.AccessTokens().RevokeToken(ctx, machine.secret.ID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Error occurred when revoking secret of machine account(%s)", machineID)
+			return nil, err
+		}
+	}
+
+	for _, a := range machine.access {
+		err = svc.store.Access().RevokeAccess(ctx, a.ResourceID, a.ResourceType, machineID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Error occurred when revoking access of machine account(%s)", machineID)
+			return nil, err
+		}
+	}
+
+	err = svc.store.Users().Delete(ctx, machineID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when deleting machine account(%s)", machineID)
+		return nil, err
+	}
+
+	log.Logger().Info().Msgf("Machine account(%s) was deleted by %s", machine.account.Username, initiator)
+
+	return res, nil
+}
+
+// loadManagedMachine loads the machine account if the initiator is allowed to manage it. Otherwise, the failure is
+// set to res and nil is returned.
+func (svc *machineService) loadManagedMachine(ctx context.Context, initiator, role, machineID string,
+	res *machineOpResult) (*machineAccount, error) {
+	initiatorAcc, err := svc.store.Users().GetByUsername(ctx, initiator)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", initiator)
+		return nil, err
+	}
+	if initiatorAcc == nil {
+		res.fail(access.InitiatorNotFound.MapToHTTP(false))
+		return nil, nil
+	}
+
+	machine, err := svc.loadMachine(ctx, machineID)
+	if err != nil {
+		return nil, err
+	}
+	if machine == nil {
+		res.fail(http.StatusNotFound, "Machine account not found")
+		return nil, nil
+	}
+
+	allowed, err := svc.canManage(ctx, initiatorAcc.Id, role, machine)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		res.fail(http.StatusForbidden, "Not allowed to manage this machine account")
+		return nil, nil
+	}
+
+	return machine, nil
+}
+
+// loadMachine returns nil if there is no machine account with the given id
+func (svc *machineService) loadMachine(ctx context.Context, machineID string) (*machineAccount, error) {
+	acc, err := svc.store.Users().Get(ctx, machineID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving machine account(%s) from database", machineID)
+		return nil, err
+	}
+	if acc == nil || acc.AccountType != constants.AccountTypeMachine {
+		return nil, nil
+	}
+
+	accessList, _, err := svc.store.Access().List(ctx, &store.ListQueryConditions{
+		Page:  1,
+		Limit: machineListLimit,
+		Filters: []store.Filter{
+			{
+				Field:    constants.FilterFieldUserID,
+				Operator: store.OpEqual,
+				Values:   []any{acc.Id},
+			},
+		},
+	})
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving access of machine account(%s)", machineID)
+		return nil, err
+	}
+
+	secret, err := svc.store.AccessTokens().GetActiveTokenByName(ctx, acc.Id, constants.MachineSecretName)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving secret of machine account(%s)", machineID)
+		return nil, err
+	}
+
+	return &machineAccount{
+		account: acc,
+		access:  accessList,
+		secret:  secret,
+	}, nil
+}
+
+// canManage reports whether the initiator can manage the machine account. Admins can manage all machine accounts.
+// Maintainers can manage a machine account only if they maintain all the namespaces it is scoped to.
+func (svc *machineService) canManage(ctx context.Context, initiatorID, role string,
+	machine *machineAccount) (bool, error) {
+	if role == constants.RoleAdmin {
+		return true, nil
+	}
+	if role != constants.RoleMaintainer || len(machine.access) == 0 {
+		return false, nil
+	}
+
+	for _, a := range machine.access {
+		nsID := a.ResourceID
+		if a.ResourceType == constants.ResourceTypeRepository {
+			repo, err := svc.store.Repositories().Get(ctx, a.ResourceID)
+			if err != nil {
+				log.Logger().Error().Err(err).Msgf("Error occurred when retriving repository(%s)", a.ResourceID)
+				return false, err
+			}
+			if repo == nil {
+				return false, fmt.Errorf("repository %s not found", a.ResourceID)
+			}
+			nsID = repo.NamespaceID
+		}
+
+		nsAccess, err := svc.store.Access().GetUserAccess(ctx, nsID, constants.ResourceTypeNamespace, initiatorID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Error occurred when retriving access of user(%s)", initiatorID)
+			return false, err
+		}
+		if nsAccess == nil || nsAccess.AccessLevel != constants.AccessLevelMaintainer {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// issueSecret generates a new secret for the machine account and returns the plain secret
+func (svc *machineService) issueSecret(ctx context.Context, machineID string, expiresInDays int) (string, error) {
+	plainSecret, err := security.GenerateAccessToken(constants.AccessTokenPrefix)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when generating machine account secret")
+		return "", err
+	}
+
+	// What a machine account can pull or push is decided by its resource access, so the secret is given both scopes.
+	m := &models.PersonalAccessToken{
+		UserID:      machineID,
+		Name:        constants.MachineSecretName,
+		TokenPrefix: plainSecret[:constants.AccessTokenDisplayLength],
+		TokenHash:   utils.CalcuateDigest([]byte(plainSecret)),
+		Scopes:      strings.Join([]string{constants.ScopePull, constants.ScopePush}, ","),
+	}
+
+	_, err = svc.store.AccessTokens().CreateToken(ctx, m, expiresInDays)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when creating secret of machine account(%s)", machineID)
+		return "", err
+	}
+
+	return plainSecret, nil
+}
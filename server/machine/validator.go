@@ -0,0 +1,57 @@
+package machine
+
+import (
+	"fmt"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+func ValidateCreateMachineAccount(req *mgmt.CreateMachineAccountRequest) (bool, string) {
+	if !utils.IsValidUsername(req.Username) {
+		return false, "Invalid username"
+	}
+
+	if len(req.DisplayName) > 255 {
+		return false, "Invalid display name"
+	}
+
+	if len(req.Access) == 0 {
+		return false, "Machine account must be scoped to at least one namespace or repository"
+	}
+
+	for i, access := range req.Access {
+		if access == nil || access.ResourceID == "" {
+			return false, "Resource ID is not set"
+		}
+
+		if !(access.ResourceType == constants.ResourceTypeNamespace ||
+			access.ResourceType == constants.ResourceTypeRepository) {
+			return false, fmt.Sprintf("Invalid resource type: %s", access.ResourceType)
+		}
+
+		if !(access.AccessLevel == constants.AccessLevelDeveloper || access.AccessLevel == constants.AccessLevelGuest) {
+			return false, fmt.Sprintf("Invalid access level for machine accounts: %s", access.AccessLevel)
+		}
+
+		for _, prev := range req.Access[:i] {
+			if prev.ResourceID == access.ResourceID && prev.ResourceType == access.ResourceType {
+				return false, fmt.Sprintf("Duplicate resource: %s", access.ResourceID)
+			}
+		}
+	}
+
+	return validateSecretExpiry(req.SecretExpiresInDays)
+}
+
+func ValidateRotateMachineSecret(req *mgmt.RotateMachineSecretRequest) (bool, string) {
+	return validateSecretExpiry(req.ExpiresInDays)
+}
+
+func validateSecretExpiry(days int) (bool, string) {
+	if days < 0 || days > constants.MaxAccessTokenExpiryDays {
+		return false, fmt.Sprintf("Secret expiry must be between 0 and %d days", constants.MaxAccessTokenExpiryDays)
+	}
+	return true, ""
+}
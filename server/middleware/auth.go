@@ -2,11 +2,17 @@ package middleware
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/ksankeerth/open-image-registry/auth/revocation"
+	"github.com/ksankeerth/open-image-registry/config"
 	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/errors/dockererrors"
 	"github.com/ksankeerth/open-image-registry/errors/httperrors"
 	"github.com/ksankeerth/open-image-registry/lib"
 	"github.com/ksankeerth/open-image-registry/log"
@@ -14,20 +20,39 @@ import (
 	"github.com/ksankeerth/open-image-registry/utils"
 )
 
+// accessTokenUsageInterval limits how often last used time of a personal access token is written to db
+const accessTokenUsageInterval = time.Minute
+
+// sessionAccessInterval limits how often last accessed time of a login session is written to db
+const sessionAccessInterval = time.Minute
+
+// registryRealm is sent in the Basic challenge of registry listeners
+const registryRealm = "open-image-registry"
+
 type Authenticator struct {
 	store       store.Store
 	jwtProvider lib.JWTProvider
+	revocations *revocation.Cache
 }
 
-func NewAuthenticator(store store.Store, jwtProvider lib.JWTProvider) *Authenticator {
+func NewAuthenticator(store store.Store, jwtProvider lib.JWTProvider, revocations *revocation.Cache) *Authenticator {
 	return &Authenticator{
 		store:       store,
 		jwtProvider: jwtProvider,
+		revocations: revocations,
 	}
 }
 
+// Authenticate authenticates requests of management APIs. Credentials are read from `Authorization` header if it is
+// present, otherwise from the auth token cookie. `Authorization` header accepts a JWT or a personal access token as
+// Bearer token, or a personal access token as the password of Basic credentials.
 func (a *Authenticator) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if authorization != "" {
+			a.authenticateHeader(w, r, next, authorization)
+			return
+		}
 
 		c, err := r.Cookie(constants.AuthTokenCookie)
 		if errors.Is(err, http.ErrNoCookie) || c == nil || c.Value == "" {
@@ -35,66 +60,307 @@ func (a *Authenticator) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		token := c.Value
+		a.authenticateJWT(w, r, next, c.Value)
+	})
+}
 
-		claims, err := a.jwtProvider.Verify(token)
-		if err != nil {
-			log.Logger().Error().Err(err).Msgf("Token verification failed")
-			httperrors.Unauthorized(w, 401, "invalid token")
+func (a *Authenticator) authenticateHeader(w http.ResponseWriter, r *http.Request, next http.Handler,
+	authorization string) {
+	username, secret, ok := parseAuthorization(authorization)
+	if !ok {
+		httperrors.Unauthorized(w, 401, "unsupported authorization header")
+		return
+	}
+
+	if !strings.HasPrefix(secret, constants.AccessTokenPrefix) {
+		if username == "" {
+			a.authenticateJWT(w, r, next, secret)
 			return
 		}
+		httperrors.Unauthorized(w, 401, "basic credentials must use a personal access token")
+		return
+	}
+
+	identity, failure, err := a.verifyAccessToken(r.Context(), username, secret)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to verify personal access token")
+		httperrors.InternalError(w, 500, "unable to verify token due to errors")
+		return
+	}
+	if failure != "" {
+		httperrors.Unauthorized(w, 401, failure)
+		return
+	}
+
+	requiredScope := constants.ScopeManagementWrite
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		requiredScope = constants.ScopeManagementRead
+	}
+	if !HasScope(identity.scopes, requiredScope) {
+		httperrors.NotAllowed(w, 403, "token does not have scope: "+requiredScope)
+		return
+	}
+
+	next.ServeHTTP(w, r.WithContext(identity.withContext(r.Context())))
+}
+
+func (a *Authenticator) authenticateJWT(w http.ResponseWriter, r *http.Request, next http.Handler, token string) {
+	claims, err := a.jwtProvider.Verify(token)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Token verification failed")
+		httperrors.Unauthorized(w, 401, "invalid token")
+		return
+	}
 
-		username, ok := claims[constants.ClaimSubject]
-		if !ok || username == "" {
-			httperrors.Unauthorized(w, 401, "user is not found in token")
+	username, ok := claims[constants.ClaimSubject]
+	if !ok || username == "" {
+		httperrors.Unauthorized(w, 401, "user is not found in token")
+		return
+	}
+
+	role, ok := claims[constants.ClaimRole]
+	if !ok || role == "" {
+		httperrors.Unauthorized(w, 401, "role is not found in token")
+		return
+	}
+
+	tokenParts := strings.Split(token, ".")
+	signature := tokenParts[2]
+
+	signatureHash := utils.CalcuateDigest([]byte(signature))
+
+	sessionID, _ := claims[constants.ClaimSessionID].(string)
+
+	revoked, err := a.revocations.IsRevoked(r.Context(), signatureHash, sessionID != "")
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to check whether token was already revoked")
+		httperrors.InternalError(w, 500, "unable to check revoked tokens")
+		return
+	}
+	if revoked {
+		httperrors.Unauthorized(w, 401, "invalid token")
+		return
+	}
+
+	// Auth tokens are bound to the login session which issued them. Revoking the session revokes its tokens as well.
+	if sessionID != "" {
+		session, err := a.store.Auth().GetSession(r.Context(), sessionID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to retrieve session of token")
+			httperrors.InternalError(w, 500, "unable to verify session due to errors")
 			return
 		}
-
-		role, ok := claims[constants.ClaimRole]
-		if !ok || role == "" {
-			httperrors.Unauthorized(w, 401, "role is not found in token")
+		if session == nil {
+			httperrors.Unauthorized(w, 401, "session has expired or been revoked")
 			return
 		}
 
-		tokenParts := strings.Split(token, ".")
-		signature := tokenParts[2]
+		if time.Since(session.LastAccessedAt) >= sessionAccessInterval {
+			err = a.store.Auth().RecordSessionAccess(r.Context(), sessionID)
+			if err != nil {
+				// access tracking must not fail the request
+				log.Logger().Warn().Err(err).Msgf("Failed to record access of session: %s", sessionID)
+			}
+		}
+	}
 
-		signatureHash := utils.CalcuateDigest([]byte(signature))
+	user, err := a.store.Users().Get(r.Context(), username.(string))
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Unable to verify login due to user retrieval errors: %s",
+			username)
+		httperrors.InternalError(w, 500, "unable to verify user due to errors")
+		return
+	}
 
-		revokedToken, err := a.store.Auth().GetRevokedToken(r.Context(), signatureHash)
-		if err != nil {
-			log.Logger().Error().Err(err).Msg("Failed to check whether token was already revoked")
-			httperrors.InternalError(w, 500, "unable to check revoked tokens")
+	if user == nil {
+		httperrors.Unauthorized(w, 401, "user not found")
+		return
+	}
+
+	expiresAt, _ := claims[lib.ClaimExp]
+	issuedAt, _ := claims[lib.ClaimIat]
+
+	ctx := r.Context()
+	ctx = context.WithValue(ctx, constants.ContextUsername, username)
+	ctx = context.WithValue(ctx, constants.ContextRole, role)
+	ctx = context.WithValue(ctx, constants.ContextSignatureHash, signatureHash)
+	ctx = context.WithValue(ctx, constants.ContextExpAt, expiresAt)
+	ctx = context.WithValue(ctx, constants.ContextIssuedAt, issuedAt)
+	ctx = context.WithValue(ctx, constants.ContextSessionID, sessionID)
+	ctx = context.WithValue(ctx, constants.ContextAuthMethod, constants.AuthMethodSession)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// AuthenticateRegistry authenticates requests of registry listeners with personal access tokens. Pulls need the
+// pull scope and other requests need the push scope. Requests of machine accounts are further limited to the
+// namespaces and repositories they are scoped to, in the registry set in the context by the listener. Anonymous requests are passed through unless
+// `image_registry.require_authentication` is enabled.
+func (a *Authenticator) AuthenticateRegistry(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			if config.GetImageRegistryConfig().RequireAuthentication {
+				dockererrors.WriteBasicUnauthorized(w, registryRealm)
+				return
+			}
+			next.ServeHTTP(w, r)
 			return
 		}
-		if revokedToken != nil {
-			httperrors.Unauthorized(w, 401, "invalid token")
+
+		username, secret, ok := parseAuthorization(authorization)
+		if !ok || !strings.HasPrefix(secret, constants.AccessTokenPrefix) {
+			dockererrors.WriteBasicUnauthorized(w, registryRealm)
 			return
 		}
 
-		user, err := a.store.Users().Get(r.Context(), username.(string))
+		identity, failure, err := a.verifyAccessToken(r.Context(), username, secret)
 		if err != nil {
-			log.Logger().Error().Err(err).Msgf("Unable to verify login due to user retrieval errors: %s",
-				username)
-			httperrors.InternalError(w, 500, "unable to verify user due to errors")
+			log.Logger().Error().Err(err).Msg("Failed to verify personal access token")
+			dockererrors.WriteUnavailable(w)
 			return
 		}
-
-		if user == nil {
-			httperrors.Unauthorized(w, 401, "user not found")
+		if failure != "" {
+			log.Logger().Debug().Msgf("Registry request was rejected: %s", failure)
+			dockererrors.WriteBasicUnauthorized(w, registryRealm)
 			return
 		}
 
-		expiresAt, _ := claims[lib.ClaimExp]
-		issuedAt, _ := claims[lib.ClaimIat]
+		requiredScope := constants.ScopePush
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			requiredScope = constants.ScopePull
+		}
+		if !HasScope(identity.scopes, requiredScope) {
+			dockererrors.WriteAccessDenied(w)
+			return
+		}
 
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, constants.ContextUsername, username)
-		ctx = context.WithValue(ctx, constants.ContextRole, role)
-		ctx = context.WithValue(ctx, constants.ContextSignatureHash, signatureHash)
-		ctx = context.WithValue(ctx, constants.ContextExpAt, expiresAt)
-		ctx = context.WithValue(ctx, constants.ContextIssuedAt, issuedAt)
+		if identity.accountType == constants.AccountTypeMachine {
+			registryID, _ := r.Context().Value(constants.ContextRegistryID).(string)
+			allowed, err := a.authorizeMachine(r.Context(), identity.userID, registryID, r.URL.Path,
+				requiredScope == constants.ScopePush)
+			if err != nil {
+				log.Logger().Error().Err(err).Msgf("Failed to verify access of machine account: %s", identity.username)
+				dockererrors.WriteUnavailable(w)
+				return
+			}
+			if !allowed {
+				log.Logger().Debug().Msgf("Machine account(%s) is not allowed to access %s", identity.username,
+					r.URL.Path)
+				dockererrors.WriteAccessDenied(w)
+				return
+			}
+		}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(identity.withContext(r.Context())))
 	})
-}
\ No newline at end of file
+}
+
+// accessTokenIdentity is the user authenticated by a personal access token
+type accessTokenIdentity struct {
+	userID      string
+	username    string
+	role        string
+	accountType string
+	scopes      []string
+}
+
+func (i *accessTokenIdentity) withContext(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, constants.ContextUsername, i.username)
+	ctx = context.WithValue(ctx, constants.ContextRole, i.role)
+	ctx = context.WithValue(ctx, constants.ContextAuthMethod, constants.AuthMethodAccessToken)
+	ctx = context.WithValue(ctx, constants.ContextTokenScopes, i.scopes)
+	return ctx
+}
+
+// verifyAccessToken returns the reason as failure if the token can't be accepted. If username is given, it has to be
+// the owner of the token.
+func (a *Authenticator) verifyAccessToken(ctx context.Context, username, token string) (
+	identity *accessTokenIdentity, failure string, err error) {
+	m, err := a.store.AccessTokens().GetTokenByHash(ctx, utils.CalcuateDigest([]byte(token)))
+	if err != nil {
+		return nil, "", err
+	}
+	if m == nil {
+		return nil, "invalid token", nil
+	}
+	if m.RevokedAt != nil {
+		return nil, "token has been revoked", nil
+	}
+	now := time.Now()
+	if m.ExpiresAt != nil && !now.Before(*m.ExpiresAt) {
+		return nil, "token has expired", nil
+	}
+
+	user, err := a.store.Users().Get(ctx, m.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+	if user == nil {
+		return nil, "user not found", nil
+	}
+	if username != "" && username != user.Username {
+		return nil, "token does not belong to user", nil
+	}
+	if user.Locked {
+		return nil, "user account has been locked", nil
+	}
+
+	role, err := a.store.Users().GetRole(ctx, user.Id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if m.LastUsedAt == nil || now.Sub(*m.LastUsedAt) >= accessTokenUsageInterval {
+		err = a.store.AccessTokens().RecordTokenUsage(ctx, m.ID)
+		if err != nil {
+			// usage tracking must not fail the request
+			log.Logger().Warn().Err(err).Msgf("Failed to record usage of personal access token: %s", m.ID)
+		}
+	}
+
+	return &accessTokenIdentity{
+		userID:      user.Id,
+		username:    user.Username,
+		role:        role,
+		accountType: user.AccountType,
+		scopes:      strings.Split(m.Scopes, ","),
+	}, "", nil
+}
+
+// HasScope reports whether scopes grant the required scope. push implies pull and management:write implies
+// management:read.
+func HasScope(scopes []string, required string) bool {
+	if slices.Contains(scopes, required) {
+		return true
+	}
+	switch required {
+	case constants.ScopePull:
+		return slices.Contains(scopes, constants.ScopePush)
+	case constants.ScopeManagementRead:
+		return slices.Contains(scopes, constants.ScopeManagementWrite)
+	}
+	return false
+}
+
+// parseAuthorization reads Bearer and Basic credentials. username is empty for Bearer tokens.
+func parseAuthorization(authorization string) (username, secret string, ok bool) {
+	scheme, credentials, found := strings.Cut(authorization, " ")
+	if !found {
+		return "", "", false
+	}
+	credentials = strings.TrimSpace(credentials)
+
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		return "", credentials, credentials != ""
+	case "basic":
+		decoded, err := base64.StdEncoding.DecodeString(credentials)
+		if err != nil {
+			return "", "", false
+		}
+		username, secret, found = strings.Cut(string(decoded), ":")
+		return username, secret, found && secret != ""
+	}
+	return "", "", false
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/errors/httperrors"
+	"github.com/ksankeerth/open-image-registry/log"
+)
+
+// RequireRole allows the request only if the authenticated user has one of the given roles.
+// It must be used after `Authenticator.Authenticate` since it reads the role from request context.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value(constants.ContextRole).(string)
+			if role == "" {
+				httperrors.Unauthorized(w, 401, "role is not found in request")
+				return
+			}
+
+			if !slices.Contains(roles, role) {
+				log.Logger().Warn().Msgf("Request(%s) was rejected since role(%s) is not allowed", r.RequestURI, role)
+				httperrors.NotAllowed(w, 403, "Not allowed to perform this operation")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
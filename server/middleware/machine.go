@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+)
+
+// registryAPIs are the path segments which follow the image name in registry APIs
+var registryAPIs = []string{"blobs", "manifests", "tags"}
+
+// authorizeMachine checks the resource access of a machine account against the namespace and repository of the
+// registry request. Namespaces and repositories are looked up in the registry which serves the request. Guest
+// access allows pulls and Developer access allows pushes as well.
+func (a *Authenticator) authorizeMachine(ctx context.Context, machineID, registryID, path string,
+	push bool) (bool, error) {
+	namespace, repository, ok := parseRegistryPath(path)
+	if !ok {
+		// API version check doesn't target any repository
+		return strings.TrimSuffix(path, "/") == "/v2", nil
+	}
+
+	registryIDs, err := a.servingRegistries(ctx, registryID, push)
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range registryIDs {
+		accessLevel, err := a.machineAccessLevel(ctx, machineID, id, namespace, repository)
+		if err != nil {
+			return false, err
+		}
+
+		switch accessLevel {
+		case constants.AccessLevelDeveloper:
+			return true, nil
+		case constants.AccessLevelGuest:
+			if !push {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// servingRegistries returns the registries whose namespaces are checked for a request of the given registry.
+// Group registries don't have namespaces. Pulls of a group are resolved through its members and pushes are
+// forwarded to the hosted registry, so their namespaces are checked instead.
+func (a *Authenticator) servingRegistries(ctx context.Context, registryID string, push bool) ([]string, error) {
+	if registryID == "" {
+		// registry of the request isn't known
+		return nil, nil
+	}
+	if registryID == constants.HostedRegistryID {
+		return []string{registryID}, nil
+	}
+
+	group, err := a.store.Groups().GetGroup(ctx, registryID)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return []string{registryID}, nil
+	}
+
+	if push {
+		if group.PushMemberID != constants.HostedRegistryID {
+			return nil, nil
+		}
+		return []string{constants.HostedRegistryID}, nil
+	}
+
+	members, err := a.store.Groups().GetMembers(ctx, registryID)
+	if err != nil {
+		return nil, err
+	}
+	registryIDs := make([]string, len(members))
+	for i, m := range members {
+		registryIDs[i] = m.MemberID
+	}
+	return registryIDs, nil
+}
+
+// machineAccessLevel returns the access level of a machine account to the repository in the registry. Access to the
+// namespace applies to all its repositories. Empty access level is returned if the machine account doesn't have
+// access.
+func (a *Authenticator) machineAccessLevel(ctx context.Context, machineID, registryID, namespace,
+	repository string) (string, error) {
+	nsID, err := a.store.Namespaces().GetID(ctx, registryID, namespace)
+	if err != nil {
+		return "", err
+	}
+	if nsID == "" {
+		return "", nil
+	}
+
+	access, err := a.store.Access().GetUserAccess(ctx, nsID, constants.ResourceTypeNamespace, machineID)
+	if err != nil {
+		return "", err
+	}
+	if access != nil {
+		return access.AccessLevel, nil
+	}
+
+	repoID, err := a.store.Repositories().GetID(ctx, nsID, repository)
+	if err != nil {
+		return "", err
+	}
+	if repoID == "" {
+		return "", nil
+	}
+
+	access, err = a.store.Access().GetUserAccess(ctx, repoID, constants.ResourceTypeRepository, machineID)
+	if err != nil {
+		return "", err
+	}
+	if access == nil {
+		return "", nil
+	}
+	return access.AccessLevel, nil
+}
+
+// parseRegistryPath extracts namespace and repository from paths of registry APIs. Namespace defaults to
+// `constants.DefaultNamespace` if the path has only the repository, same as registry handlers.
+func parseRegistryPath(path string) (namespace, repository string, ok bool) {
+	name, found := strings.CutPrefix(path, "/v2/")
+	if !found {
+		return "", "", false
+	}
+
+	segments := strings.Split(name, "/")
+	switch {
+	case len(segments) > 3 && slices.Contains(registryAPIs, segments[2]):
+		namespace, repository = segments[0], segments[1]
+	case len(segments) > 2 && slices.Contains(registryAPIs, segments[1]):
+		namespace, repository = constants.DefaultNamespace, segments[0]
+	default:
+		return "", "", false
+	}
+
+	return namespace, repository, namespace != "" && repository != ""
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRegistryPath(t *testing.T) {
+	tcs := []struct {
+		name       string
+		path       string
+		namespace  string
+		repository string
+		ok         bool
+	}{
+		{"Manifest", "/v2/team/app/manifests/latest", "team", "app", true},
+		{"Blob", "/v2/team/app/blobs/sha256:abc", "team", "app", true},
+		{"Upload", "/v2/team/app/blobs/uploads/", "team", "app", true},
+		{"Upload session", "/v2/team/app/blobs/uploads/1234", "team", "app", true},
+		{"Tags", "/v2/team/app/tags/list", "team", "app", true},
+		{"Default namespace", "/v2/app/manifests/latest", constants.DefaultNamespace, "app", true},
+		{"Repository named like an API", "/v2/team/manifests/manifests/latest", "team", "manifests", true},
+		{"API version check", "/v2/", "", "", false},
+		{"Catalog", "/v2/_catalog", "", "", false},
+		{"Missing name", "/v2//manifests/latest", "", "", false},
+		{"Not a registry path", "/api/v1/users", "", "", false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			namespace, repository, ok := parseRegistryPath(tc.path)
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, tc.namespace, namespace)
+				assert.Equal(t, tc.repository, repository)
+			}
+		})
+	}
+}
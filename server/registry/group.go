@@ -0,0 +1,395 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httplog/v2"
+
+	"github.com/ksankeerth/open-image-registry/client/upstream/health"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/errors/dockererrors"
+	"github.com/ksankeerth/open-image-registry/listeners"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+)
+
+// GroupListenerController keeps listeners of group registries in sync with their persisted configs.
+//
+// A group registry does not store images. It resolves pulls through its members in order and the first
+// member which has the image wins. Members are the hosted registry and upstream registries, and their
+// running RegistryServices are used, so caches and health of members are shared with their own listeners.
+//
+// In single port mode, groups do not have listeners. Their handlers are served by SinglePortRouter.
+type GroupListenerController struct {
+	store      store.Store
+	lm         *listeners.ListenerManager
+	singlePort bool
+	hosted     *RegistryHandler
+	// hostedRoutes serves pushes forwarded by groups to the hosted registry.
+	hostedRoutes http.Handler
+	upstreams    *UpstreamListenerController
+	groups       map[string]*groupRoute
+	// authenticate wraps handlers of listeners. Listeners are served without authentication if it is not set.
+	authenticate func(http.Handler) http.Handler
+	mu           sync.RWMutex
+}
+
+type groupRoute struct {
+	name    string
+	handler *swappableHandler
+}
+
+// NewGroupListenerController creates the controller. hosted can be nil if hosted registry is disabled.
+// In that case, hosted registry is skipped when resolving images and pushes are rejected.
+func NewGroupListenerController(s store.Store, hosted *RegistryHandler, upstreams *UpstreamListenerController,
+	singlePort bool) *GroupListenerController {
+	c := &GroupListenerController{
+		store:      s,
+		lm:         listeners.GetListenerManager(),
+		singlePort: singlePort,
+		hosted:     hosted,
+		upstreams:  upstreams,
+		groups:     make(map[string]*groupRoute),
+	}
+	if hosted != nil {
+		c.hostedRoutes = hosted.Routes()
+	}
+	return c
+}
+
+// SetAuthenticator sets the middleware which authenticates requests of group listeners. It has to be set before
+// listeners are started.
+func (c *GroupListenerController) SetAuthenticator(authenticate func(http.Handler) http.Handler) {
+	c.authenticate = authenticate
+}
+
+// StartAll starts listeners of all group registries which are not disabled. Listeners start to
+// serve after the given delay.
+func (c *GroupListenerController) StartAll(ctx context.Context, listenDelayInSeconds time.Duration) {
+	groups, err := c.store.Groups().GetActiveGroups(ctx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when loading active group registries")
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, group := range groups {
+		err = c.start(ctx, group.ID, group.Name, group.Port, group.PushMemberID, listenDelayInSeconds)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Unable to start listener for group registry %s", group.Name)
+			continue
+		}
+	}
+}
+
+// Sync applies the persisted state of the group to its listener.
+//   - Disabled or deleted group: listener is stopped.
+//   - Port is changed: listener is restarted on the new port. Ignored in single port mode.
+//   - Otherwise: handler is rebuilt, so changes of members and their order take effect for new requests.
+func (c *GroupListenerController) Sync(ctx context.Context, groupID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, err := c.store.Groups().GetGroup(ctx, groupID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Syncing listener of group registry(%s) failed due to database errors", groupID)
+		return err
+	}
+
+	if group == nil || group.State == constants.ResourceStateDisabled {
+		return c.stop(groupID)
+	}
+
+	route, ok := c.groups[groupID]
+	if !c.singlePort {
+		groupLn, running := c.lm.GetListener(groupID)
+		if running && (!ok || groupLn.Port != group.Port) {
+			err = c.stop(groupID)
+			if err != nil {
+				return err
+			}
+		}
+		ok = ok && running && groupLn.Port == group.Port
+	}
+
+	if !ok {
+		return c.start(ctx, group.ID, group.Name, group.Port, group.PushMemberID, 0)
+	}
+
+	gh, err := c.newGroupHandler(ctx, group.ID, group.Name, group.PushMemberID)
+	if err != nil {
+		return err
+	}
+	route.handler.swap(gh.Routes())
+	route.name = group.Name
+	log.Logger().Info().Msgf("Members of group registry(%s) were reloaded", group.Name)
+	return nil
+}
+
+// Stop stops the listener of the group if it is running.
+func (c *GroupListenerController) Stop(groupID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stop(groupID)
+}
+
+func (c *GroupListenerController) start(ctx context.Context, groupID, groupName string, port uint,
+	pushMemberID string, listenDelayInSeconds time.Duration) error {
+	gh, err := c.newGroupHandler(ctx, groupID, groupName, pushMemberID)
+	if err != nil {
+		return err
+	}
+
+	sh := &swappableHandler{}
+	sh.swap(gh.Routes())
+
+	if !c.singlePort {
+		err = c.lm.RegisterListener(groupID, groupName, port, WithAuthenticator(sh, groupID, c.authenticate),
+			listenDelayInSeconds)
+		if err != nil {
+			return err
+		}
+	}
+	c.groups[groupID] = &groupRoute{
+		name:    groupName,
+		handler: sh,
+	}
+	return nil
+}
+
+func (c *GroupListenerController) stop(groupID string) error {
+	delete(c.groups, groupID)
+
+	err := c.lm.UnregisterListener(groupID, upstreamShutdownTimeoutInSeconds)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Stopping listener of group registry(%s) failed", groupID)
+		return err
+	}
+	return nil
+}
+
+func (c *GroupListenerController) newGroupHandler(ctx context.Context, groupID, groupName,
+	pushMemberID string) (*groupHandler, error) {
+	members, err := c.store.Groups().GetMembers(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load members of group registry %s: %w", groupName, err)
+	}
+
+	gh := &groupHandler{
+		groupName: groupName,
+		memberIDs: make([]string, len(members)),
+		members:   c,
+	}
+	for i, m := range members {
+		gh.memberIDs[i] = m.MemberID
+	}
+
+	if pushMemberID == constants.HostedRegistryID && c.hostedRoutes != nil {
+		gh.push = c.hostedRoutes
+	}
+	return gh, nil
+}
+
+// memberService returns the registry service of a member. nil is returned if the member is disabled,
+// deleted or not started yet.
+func (c *GroupListenerController) memberService(memberID string) *RegistryService {
+	if memberID == constants.HostedRegistryID {
+		if c.hosted == nil {
+			return nil
+		}
+		return c.hosted.svc
+	}
+	if c.upstreams == nil {
+		return nil
+	}
+	return c.upstreams.service(memberID)
+}
+
+// handlerByName returns the id and the handler of the running group with the given name.
+func (c *GroupListenerController) handlerByName(name string) (string, http.Handler) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for groupID, route := range c.groups {
+		if route.name == name {
+			return groupID, route.handler
+		}
+	}
+	return "", nil
+}
+
+// groupHandler serves Docker V2 APIs of a group registry. Pulls are resolved through members in order.
+// Pushes are forwarded to the hosted registry if it is the push member of the group, otherwise the group
+// is read-only.
+type groupHandler struct {
+	groupName string
+	memberIDs []string
+	members   *GroupListenerController
+	push      http.Handler
+}
+
+func (gh *groupHandler) Routes() http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(httplog.RequestLogger(httplog.NewLogger(fmt.Sprintf("DockerV2API-%s", gh.groupName), httplog.Options{
+		LogLevel:         slog.LevelDebug,
+		Concise:          true,
+		RequestHeaders:   true,
+		MessageFieldName: "message",
+	})))
+
+	r.Route("/v2", func(r chi.Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) { writeAPIVersionResponse(w) })
+
+		r.Head("/{namespace}/{repository}/blobs/{digest}", gh.blobExists)
+		r.Head("/{repository}/blobs/{digest}", gh.blobExists)
+
+		r.Get("/{namespace}/{repository}/blobs/{digest}", gh.getImageBlob)
+		r.Get("/{repository}/blobs/{digest}", gh.getImageBlob)
+
+		r.Head("/{namespace}/{repository}/manifests/{tag_or_digest}", gh.manifestExists)
+		r.Head("/{repository}/manifests/{tag_or_digest}", gh.manifestExists)
+
+		r.Get("/{namespace}/{repository}/manifests/{tag_or_digest}", gh.getManifest)
+		r.Get("/{repository}/manifests/{tag_or_digest}", gh.getManifest)
+	})
+
+	// Pushes are dispatched before routing, so the hosted registry routes the request by itself.
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			r.ServeHTTP(w, req)
+			return
+		}
+		if gh.push == nil {
+			dockererrors.WriteUnsupported(w)
+			return
+		}
+		gh.push.ServeHTTP(w, req)
+	})
+}
+
+// resolve calls find with the registry service of each member in order until a member has the image.
+// Errors of members are logged and treated as misses. If no member has the image and a member was
+// skipped because its upstream is unhealthy or rate limited, that error is returned so the client can
+// retry later.
+func (gh *groupHandler) resolve(find func(svc *RegistryService) (bool, error)) (found bool, err error) {
+	var unavailableErr error
+	for _, memberID := range gh.memberIDs {
+		svc := gh.members.memberService(memberID)
+		if svc == nil {
+			continue
+		}
+
+		found, err = find(svc)
+		if err != nil {
+			log.Logger().Warn().Err(err).Msgf("Member(%s) of group registry(%s) failed to resolve the request",
+				svc.registryName, gh.groupName)
+			if errors.Is(err, health.ErrCircuitOpen) || errors.Is(err, health.ErrRateLimited) {
+				unavailableErr = err
+			}
+			continue
+		}
+		if found {
+			return true, nil
+		}
+	}
+	return false, unavailableErr
+}
+
+func (gh *groupHandler) blobExists(w http.ResponseWriter, r *http.Request) {
+	namespace, repository, digest := extractNamespaceRepositoryAndDigest(r)
+
+	found, err := gh.resolve(func(svc *RegistryService) (bool, error) {
+		return svc.blobExists(r.Context(), namespace, repository, digest)
+	})
+	if err != nil {
+		writeUpstreamReadError(w, err)
+	} else if found {
+		writeBlobExistsResponse(w, digest)
+	} else {
+		dockererrors.WriteBlobNotFound(w)
+	}
+}
+
+func (gh *groupHandler) getImageBlob(w http.ResponseWriter, r *http.Request) {
+	namespace, repository, digest := extractNamespaceRepositoryAndDigest(r)
+
+	var content []byte
+	found, err := gh.resolve(func(svc *RegistryService) (exists bool, err error) {
+		exists, content, err = svc.getImageBlob(r.Context(), namespace, repository, digest)
+		return exists, err
+	})
+	if err != nil {
+		writeUpstreamReadError(w, err)
+		return
+	}
+	if !found {
+		dockererrors.WriteBlobNotFound(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Write(content)
+}
+
+func (gh *groupHandler) manifestExists(w http.ResponseWriter, r *http.Request) {
+	namespace, repository, tagOrDigest := extractNamespaceRepositoryAndTagOrDigest(r)
+
+	var mediaType, digest string
+	found, err := gh.resolve(func(svc *RegistryService) (exists bool, err error) {
+		exists, mediaType, digest, err = svc.manifestExists(r.Context(), namespace, repository, tagOrDigest)
+		return exists, err
+	})
+	if err != nil {
+		writeUpstreamReadError(w, err)
+		return
+	}
+	if !found {
+		dockererrors.WriteManifestNotFound(w)
+		return
+	}
+
+	w.Header().Set("Content-Length", "0")
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (gh *groupHandler) getManifest(w http.ResponseWriter, r *http.Request) {
+	namespace, repository, tagOrDigest := extractNamespaceRepositoryAndTagOrDigest(r)
+
+	var mediaType, digest string
+	var content []byte
+	found, err := gh.resolve(func(svc *RegistryService) (exists bool, err error) {
+		exists, mediaType, digest, content, err = svc.getImageManifest(r.Context(), namespace, repository, tagOrDigest)
+		return exists, err
+	})
+	if err != nil {
+		writeUpstreamReadError(w, err)
+		return
+	}
+	if !found {
+		dockererrors.WriteManifestNotFound(w)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(int64(len(content)), 10))
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(http.StatusOK)
+
+	w.Write(content)
+}
@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
@@ -21,6 +22,13 @@ type RegistryHandler struct {
 	registryId   string
 	registryName string
 	svc          *RegistryService
+	pushObserver PushObserver
+}
+
+// PushObserver is notified after a manifest is pushed to the registry. It is called after the manifest is
+// persisted, before the response is sent to the client.
+type PushObserver interface {
+	ManifestPushed(ctx context.Context, namespace, repository, reference, digest string)
 }
 
 func NewRegistryHandler(registryId, registryName string, s store.Store) *RegistryHandler {
@@ -34,6 +42,32 @@ func NewRegistryHandler(registryId, registryName string, s store.Store) *Registr
 	}
 }
 
+// SetPushObserver sets the observer which is notified on manifest pushes. It must be called before the
+// registry starts serving requests.
+func (rh *RegistryHandler) SetPushObserver(o PushObserver) {
+	rh.pushObserver = o
+}
+
+// GetManifest loads the manifest by tag or digest. It allows other components(e.g. replication) to read
+// images of the registry without going through HTTP.
+func (rh *RegistryHandler) GetManifest(ctx context.Context, namespace, repository, tagOrDigest string) (exists bool,
+	mediaType, digest string, content []byte, err error) {
+	exists, mediaType, digest, content, err = rh.svc.getImageManifest(ctx, namespace, repository, tagOrDigest)
+	if err != nil || !exists {
+		return exists, "", "", nil, err
+	}
+	if digest == "" {
+		digest = utils.CalcuateDigest(content)
+	}
+	return true, mediaType, digest, content, nil
+}
+
+// GetBlob loads the blob of the repository.
+func (rh *RegistryHandler) GetBlob(ctx context.Context, namespace, repository, digest string) (exists bool,
+	content []byte, err error) {
+	return rh.svc.getImageBlob(ctx, namespace, repository, digest)
+}
+
 func (rh *RegistryHandler) Routes() chi.Router {
 	r := chi.NewRouter()
 
@@ -77,11 +111,7 @@ func (rh *RegistryHandler) Routes() chi.Router {
 }
 
 func (rh *RegistryHandler) dockerV2APISupport(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
-	w.Header().Set("Content-Type", "application/json")
-
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"Docker-Distribution-API-Version": "registry/2.0"}`))
+	writeAPIVersionResponse(w)
 }
 
 func (rh *RegistryHandler) initiateBlobUpload(w http.ResponseWriter, r *http.Request) {
@@ -117,7 +147,8 @@ func (rh *RegistryHandler) initiateBlobUpload(w http.ResponseWriter, r *http.Req
 		scheme = "https"
 	}
 
-	uploadUrl := fmt.Sprintf("%s://%s/v2/%s/%s/blobs/uploads/%s", scheme, r.Host, namespace, repository, sessionID)
+	uploadUrl := fmt.Sprintf("%s://%s/v2%s/%s/%s/blobs/uploads/%s", scheme, r.Host, routePrefix(r), namespace, repository,
+		sessionID)
 
 	w.Header().Set("Location", uploadUrl)
 	w.Header().Set("Docker-Upload-UUID", sessionID)
@@ -131,7 +162,7 @@ func (rh *RegistryHandler) blobExists(w http.ResponseWriter, r *http.Request) {
 
 	exists, err := rh.svc.blobExists(r.Context(), namespace, repository, digest)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeUpstreamReadError(w, err)
 	} else if exists {
 		writeBlobExistsResponse(w, digest)
 	} else {
@@ -268,7 +299,7 @@ func (rh *RegistryHandler) getImageBlob(w http.ResponseWriter, r *http.Request)
 
 	exists, content, err := rh.svc.getImageBlob(r.Context(), namespace, repository, digest)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeUpstreamReadError(w, err)
 		return
 	}
 	if !exists {
@@ -287,7 +318,7 @@ func (rh *RegistryHandler) manifestExists(w http.ResponseWriter, r *http.Request
 
 	exists, mediaType, digest, err := rh.svc.manifestExists(r.Context(), namespace, repository, tagOrDigest)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeUpstreamReadError(w, err)
 		return
 	}
 
@@ -309,7 +340,7 @@ func (rh *RegistryHandler) getManifest(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 
-		w.WriteHeader(http.StatusInternalServerError)
+		writeUpstreamReadError(w, err)
 		return
 	}
 	if !exists {
@@ -351,6 +382,11 @@ func (rh *RegistryHandler) updateManifest(w http.ResponseWriter, r *http.Request
 		log.Logger().Error().Err(err).Msgf("Error occured when updating manifest for request: %s", r.RequestURI)
 		return
 	}
+
+	if rh.pushObserver != nil {
+		rh.pushObserver.ManifestPushed(r.Context(), namespace, repository, tag, digest)
+	}
+
 	w.Header().Set("Content-Length", "0")
 	w.Header().Set("Docker-Content-Digest", digest)
 	w.WriteHeader(http.StatusCreated)
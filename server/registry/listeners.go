@@ -0,0 +1,271 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/client/upstream/health"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/listeners"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+)
+
+// upstreamShutdownTimeoutInSeconds is the time given for in-flight requests when a proxy listener is stopped.
+const upstreamShutdownTimeoutInSeconds = 30
+
+// UpstreamListenerController keeps proxy listeners of upstream registries in sync with their
+// persisted configs. Upstream changes made through management APIs are applied without restarting the server.
+//
+// In single port mode, upstreams do not have listeners. Their handlers are served by SinglePortRouter.
+type UpstreamListenerController struct {
+	store      store.Store
+	lm         *listeners.ListenerManager
+	singlePort bool
+	// upstreams holds the handler of each running upstream. Handler is swapped when only the config
+	// of the upstream changes, so the port is not closed.
+	upstreams map[string]*upstreamRoute
+	// authenticate wraps handlers of listeners. Listeners are served without authentication if it is not set.
+	authenticate func(http.Handler) http.Handler
+	mu           sync.RWMutex
+}
+
+// upstreamRoute is used by SinglePortRouter to select the upstream of a request.
+type upstreamRoute struct {
+	name string
+	// host of the upstream url. Compared with `ns` query parameter of containerd mirrors.
+	upstreamHost string
+	handler      *swappableHandler
+	// svc is the registry service used by the handler. Group registries resolve images through it.
+	svc *RegistryService
+}
+
+// swappableHandler allows replacing the RegistryHandler of a running listener.
+type swappableHandler struct {
+	handler atomic.Pointer[http.Handler]
+}
+
+func (s *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*s.handler.Load()).ServeHTTP(w, r)
+}
+
+func (s *swappableHandler) swap(h http.Handler) {
+	s.handler.Store(&h)
+}
+
+// WithAuthenticator wraps the handler of a registry with the authenticator. The registry is kept in the context of
+// requests, so access of machine accounts is checked against namespaces of the registry which serves the request.
+func WithAuthenticator(h http.Handler, regID string, authenticate func(http.Handler) http.Handler) http.Handler {
+	if authenticate == nil {
+		return h
+	}
+	authenticated := authenticate(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), constants.ContextRegistryID, regID)))
+	})
+}
+
+func NewUpstreamListenerController(s store.Store, singlePort bool) *UpstreamListenerController {
+	return &UpstreamListenerController{
+		store:      s,
+		lm:         listeners.GetListenerManager(),
+		singlePort: singlePort,
+		upstreams:  make(map[string]*upstreamRoute),
+	}
+}
+
+// SetAuthenticator sets the middleware which authenticates requests of proxy listeners. It has to be set before
+// listeners are started.
+func (c *UpstreamListenerController) SetAuthenticator(authenticate func(http.Handler) http.Handler) {
+	c.authenticate = authenticate
+}
+
+// StartAll starts listeners of all upstream registries which are not disabled. Listeners start to
+// serve after the given delay.
+func (c *UpstreamListenerController) StartAll(ctx context.Context, listenDelayInSeconds time.Duration) {
+	upstreamAddrs, err := c.store.Upstreams().GetAllUpstreamRegistryAddresses(ctx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error ocurred when loading active upstream registeries' address")
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, upstreamAddr := range upstreamAddrs {
+		err = c.start(upstreamAddr.ID, upstreamAddr.Name, upstreamAddr.UpstreamUrl, uint(upstreamAddr.Port),
+			listenDelayInSeconds)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Unable to start listener for %s", upstreamAddr.Name)
+			continue
+		}
+	}
+}
+
+// Sync applies the persisted state of the upstream to its listener.
+//   - Disabled or deleted upstream: listener is stopped.
+//   - Port is changed: listener is restarted on the new port. Ignored in single port mode.
+//   - Otherwise: RegistryService is rebuilt, so changes of configs take effect for new requests.
+func (c *UpstreamListenerController) Sync(ctx context.Context, regID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reg, err := c.store.Upstreams().GetRegistry(ctx, regID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Syncing listener of upstream(%s) failed due to database errors", regID)
+		return err
+	}
+
+	if reg == nil || reg.State == constants.ResourceStateDisabled {
+		return c.stop(regID)
+	}
+
+	route, ok := c.upstreams[regID]
+	if !c.singlePort {
+		regLn, running := c.lm.GetListener(regID)
+		if running && (!ok || regLn.Port != reg.Port) {
+			err = c.stop(regID)
+			if err != nil {
+				return err
+			}
+		}
+		ok = ok && running && regLn.Port == reg.Port
+	}
+
+	if !ok {
+		return c.start(reg.ID, reg.Name, reg.UpstreamURL, reg.Port, 0)
+	}
+
+	rh, err := newUpstreamHandler(reg.ID, reg.Name, c.store)
+	if err != nil {
+		return err
+	}
+	route.handler.swap(rh.Routes())
+	route.svc = rh.svc
+	route.name = reg.Name
+	route.upstreamHost = hostOf(reg.UpstreamURL)
+	health.GetMonitor().Watch(reg.ID, reg.UpstreamURL, rh.svc.transport)
+	log.Logger().Info().Msgf("Registry service of upstream(%s) was rebuilt", reg.Name)
+	return nil
+}
+
+// Stop stops the listener of the upstream if it is running.
+func (c *UpstreamListenerController) Stop(regID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stop(regID)
+}
+
+func (c *UpstreamListenerController) start(regID, regName, upstreamURL string, port uint,
+	listenDelayInSeconds time.Duration) error {
+	rh, err := newUpstreamHandler(regID, regName, c.store)
+	if err != nil {
+		health.GetMonitor().Unwatch(regID)
+		return err
+	}
+
+	sh := &swappableHandler{}
+	sh.swap(rh.Routes())
+
+	if !c.singlePort {
+		err = c.lm.RegisterListener(regID, regName, port, WithAuthenticator(sh, regID, c.authenticate),
+			listenDelayInSeconds)
+		if err != nil {
+			health.GetMonitor().Unwatch(regID)
+			return err
+		}
+	}
+	c.upstreams[regID] = &upstreamRoute{
+		name:         regName,
+		upstreamHost: hostOf(upstreamURL),
+		handler:      sh,
+		svc:          rh.svc,
+	}
+	health.GetMonitor().Watch(regID, upstreamURL, rh.svc.transport)
+	return nil
+}
+
+func (c *UpstreamListenerController) stop(regID string) error {
+	delete(c.upstreams, regID)
+	health.GetMonitor().Unwatch(regID)
+
+	err := c.lm.UnregisterListener(regID, upstreamShutdownTimeoutInSeconds)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Stopping listener of upstream(%s) failed", regID)
+		return err
+	}
+	return nil
+}
+
+func newUpstreamHandler(regID, regName string, s store.Store) (*RegistryHandler, error) {
+	rh := NewRegistryHandler(regID, regName, s)
+	if rh.svc == nil {
+		return nil, fmt.Errorf("unable to create registry service for upstream: %s", regName)
+	}
+	return rh, nil
+}
+
+// handlerByName returns the id and the handler of the running upstream with the given name.
+func (c *UpstreamListenerController) handlerByName(name string) (string, http.Handler) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for regID, route := range c.upstreams {
+		if route.name == name {
+			return regID, route.handler
+		}
+	}
+	return "", nil
+}
+
+// service returns the registry service of the running upstream. nil is returned if the upstream is
+// disabled, deleted or not started yet.
+func (c *UpstreamListenerController) service(regID string) *RegistryService {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	route, ok := c.upstreams[regID]
+	if !ok {
+		return nil
+	}
+	return route.svc
+}
+
+// FlushNotFound forgets manifests which the upstream reported as not found, so they are looked up again
+// on the next pull. Only entries of the repository (e.g. `library/alpine`) are flushed if it is given.
+// Count of flushed entries is returned. Nothing is flushed if the upstream is not running.
+func (c *UpstreamListenerController) FlushNotFound(regID, repository string) int {
+	svc := c.service(regID)
+	if svc == nil {
+		return 0
+	}
+	return svc.notFound.flush(repository)
+}
+
+// handlerByUpstreamHost returns the id and the handler of the running upstream whose url has the given host.
+func (c *UpstreamListenerController) handlerByUpstreamHost(host string) (string, http.Handler) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for regID, route := range c.upstreams {
+		if strings.EqualFold(route.upstreamHost, host) {
+			return regID, route.handler
+		}
+	}
+	return "", nil
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxNegativeCacheEntries bounds the memory used for not-found results of an upstream. New results are not
+// cached once the limit is reached until entries expire.
+const maxNegativeCacheEntries = 10000
+
+// negativeCache remembers manifests which upstream reported as not found. Pulls of them are answered with
+// MANIFEST_UNKNOWN until the entry expires, so typos and missing tags don't reach upstream repeatedly.
+// Entries are kept in memory and are dropped when the registry service is rebuilt.
+type negativeCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]time.Time
+	now     func() time.Time
+}
+
+// newNegativeCache returns a cache which never holds entries if ttl is zero.
+func newNegativeCache(ttl time.Duration) *negativeCache {
+	return &negativeCache{
+		ttl:     ttl,
+		entries: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+func negativeCacheKey(namespace, repository, reference string) string {
+	return namespace + "/" + repository + "/" + reference
+}
+
+func (c *negativeCache) contains(namespace, repository, reference string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := negativeCacheKey(namespace, repository, reference)
+	expiresAt, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	if !c.now().Before(expiresAt) {
+		delete(c.entries, key)
+		return false
+	}
+	return true
+}
+
+func (c *negativeCache) add(namespace, repository, reference string) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if len(c.entries) >= maxNegativeCacheEntries {
+		c.removeExpired(now)
+		if len(c.entries) >= maxNegativeCacheEntries {
+			return
+		}
+	}
+	c.entries[negativeCacheKey(namespace, repository, reference)] = now.Add(c.ttl)
+}
+
+// flush removes entries of the repository (e.g. `library/alpine`), or all entries if repository is empty.
+// Count of removed entries which were not expired is returned.
+func (c *negativeCache) flush(repository string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeExpired(c.now())
+
+	prefix := repository + "/"
+	flushed := 0
+	for key := range c.entries {
+		if repository == "" || strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+			flushed++
+		}
+	}
+	return flushed
+}
+
+// removeExpired must be called while holding the lock.
+func (c *negativeCache) removeExpired(now time.Time) {
+	for key, expiresAt := range c.entries {
+		if !now.Before(expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegativeCache(t *testing.T) {
+	now := time.Now()
+
+	t.Run("Entries expire after ttl", func(t *testing.T) {
+		current := now
+		c := newNegativeCache(time.Minute)
+		c.now = func() time.Time { return current }
+
+		c.add("library", "alpine", "latst")
+		assert.True(t, c.contains("library", "alpine", "latst"))
+		assert.False(t, c.contains("library", "alpine", "latest"))
+
+		current = current.Add(time.Minute)
+		assert.False(t, c.contains("library", "alpine", "latst"))
+	})
+
+	t.Run("Zero ttl disables caching", func(t *testing.T) {
+		c := newNegativeCache(0)
+
+		c.add("library", "alpine", "latst")
+		assert.False(t, c.contains("library", "alpine", "latst"))
+	})
+
+	t.Run("Flush repository", func(t *testing.T) {
+		c := newNegativeCache(time.Minute)
+		c.add("library", "alpine", "latst")
+		c.add("library", "alpine", "3.99")
+		c.add("library", "alpine-extra", "latest")
+
+		assert.Equal(t, 2, c.flush("library/alpine"))
+		assert.False(t, c.contains("library", "alpine", "3.99"))
+		assert.True(t, c.contains("library", "alpine-extra", "latest"))
+
+		assert.Equal(t, 1, c.flush(""))
+		assert.False(t, c.contains("library", "alpine-extra", "latest"))
+	})
+}
@@ -1,10 +1,27 @@
 package registry
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/client/upstream/health"
+	clienterrors "github.com/ksankeerth/open-image-registry/errors/client"
+	"github.com/ksankeerth/open-image-registry/errors/dockererrors"
+	"github.com/ksankeerth/open-image-registry/log"
 )
 
+func writeAPIVersionResponse(w http.ResponseWriter) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	w.Header().Set("Content-Type", "application/json")
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"Docker-Distribution-API-Version": "registry/2.0"}`))
+}
+
 func writeBlobExistsResponse(w http.ResponseWriter, digest string) {
 	w.Header().Set("Content-Length", "0")
 	w.Header().Set("Docker-Content-Digest", digest)
@@ -17,4 +34,43 @@ func writeBlobUploadSuccess(w http.ResponseWriter, url, sessionId string, start,
 	w.Header().Add("Docker-Upload-UUID", sessionId)
 	w.Header().Set("Content-Length", "0")
 	w.WriteHeader(http.StatusCreated)
-}
\ No newline at end of file
+}
+
+// writeUpstreamReadError writes the response for errors occurred when reading manifests or blobs.
+// Requests fail fast with 503 while the upstream is unhealthy and with 429 while it is rate limited.
+// Classified errors of the upstream client are written as distribution errors, so clients show the cause.
+func writeUpstreamReadError(w http.ResponseWriter, err error) {
+	if errors.Is(err, health.ErrCircuitOpen) {
+		dockererrors.WriteUnavailable(w)
+		return
+	}
+	if errors.Is(err, health.ErrRateLimited) {
+		var limitErr *health.RateLimitedError
+		if errors.As(err, &limitErr) {
+			retryAfter := int(math.Ceil(time.Until(limitErr.RetryAt).Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+		}
+		dockererrors.WriteTooManyRequests(w)
+		return
+	}
+
+	ok, code := clienterrors.UnwrapClientError(err)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Logger().Warn().Err(err).Msg("Request to upstream registry failed")
+
+	switch code {
+	case clienterrors.CodeProxyUnauthorized:
+		dockererrors.WriteUpstreamUnauthorized(w)
+	case clienterrors.CodeProxyConnectionFailed:
+		dockererrors.WriteUpstreamUnreachable(w)
+	case clienterrors.CodeProxyUnexpectedStatusCode, clienterrors.CodeProxyResponseBodyMismatch,
+		clienterrors.CodeProxyUnsupportedMediaType:
+		dockererrors.WriteBadGateway(w)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/client/upstream/health"
+	clienterrors "github.com/ksankeerth/open-image-registry/errors/client"
+	"github.com/ksankeerth/open-image-registry/errors/dockererrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteUpstreamReadError(t *testing.T) {
+	tcs := []struct {
+		name       string
+		err        error
+		statusCode int
+		code       string
+	}{
+		{"Circuit open", health.ErrCircuitOpen, http.StatusServiceUnavailable, dockererrors.ErrCodeUnavailable},
+		{"Rate limited", &health.RateLimitedError{RetryAt: time.Now().Add(time.Minute)}, http.StatusTooManyRequests,
+			dockererrors.ErrCodeTooManyRequests},
+		{"Unauthorized", clienterrors.NewProxyClientError("", nil, clienterrors.CodeProxyUnauthorized),
+			http.StatusBadGateway, dockererrors.ErrCodeUnavailable},
+		{"Connection failed", fmt.Errorf("failed to fetch manifest: %w",
+			clienterrors.NewProxyClientError("", errors.New("connection refused"), clienterrors.CodeProxyConnectionFailed)),
+			http.StatusServiceUnavailable, dockererrors.ErrCodeUnavailable},
+		{"Unexpected status", clienterrors.ProxyUnexpectedStatusError(http.StatusOK, http.StatusTeapot, ""),
+			http.StatusBadGateway, dockererrors.ErrCodeUnavailable},
+		{"Body mismatch", clienterrors.NewProxyClientError("", nil, clienterrors.CodeProxyResponseBodyMismatch),
+			http.StatusBadGateway, dockererrors.ErrCodeUnavailable},
+		{"Unclassified", errors.New("database is locked"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeUpstreamReadError(w, tc.err)
+
+			assert.Equal(t, tc.statusCode, w.Code)
+			if tc.code == "" {
+				assert.Zero(t, w.Body.Len())
+				return
+			}
+
+			var resp dockererrors.DockerErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Len(t, resp.Errors, 1)
+			assert.Equal(t, tc.code, resp.Errors[0].Code)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package registry
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/errors/dockererrors"
+)
+
+// dockerHubHosts are hosts of Docker Hub registry. containerd sends `ns=docker.io` for images of Docker Hub.
+var dockerHubHosts = []string{"registry-1.docker.io", "index.docker.io"}
+
+type routePrefixKey struct{}
+
+// SinglePortRouter serves hosted registry, all upstream registries and group registries on a single port.
+// Upstream or group of a request is selected in the following order:
+//   - `Host` header which is mapped to an upstream name in config.
+//   - `ns` query parameter sent by containerd mirrors. It is mapped to an upstream name in config or
+//     matched with the host of upstream url. Unknown namespaces are not served by hosted registry.
+//   - `/v2/<upstream-name>/` or `/v2/<group-name>/` path prefix. The prefix is removed before the request
+//     reaches the upstream or group.
+//
+// Remaining requests are served by hosted registry.
+//
+// Requests are authenticated after the registry is selected and the prefix is removed, so access of machine
+// accounts is checked against namespaces of the selected registry.
+type SinglePortRouter struct {
+	hosted     http.Handler
+	upstreams  *UpstreamListenerController
+	groups     *GroupListenerController
+	hosts      map[string]string
+	namespaces map[string]string
+	// authenticate wraps handlers of selected registries. Requests are served without authentication if it is
+	// not set.
+	authenticate func(http.Handler) http.Handler
+}
+
+// NewSinglePortRouter creates the router. hosted can be nil if hosted registry is disabled and groups can be
+// nil if group registries are not served.
+func NewSinglePortRouter(hosted http.Handler, upstreams *UpstreamListenerController, groups *GroupListenerController,
+	cfg config.RegistryRoutingConfig) *SinglePortRouter {
+	hosts := make(map[string]string, len(cfg.Hosts))
+	for host, name := range cfg.Hosts {
+		hosts[strings.ToLower(host)] = name
+	}
+
+	namespaces := make(map[string]string, len(cfg.Namespaces))
+	for ns, name := range cfg.Namespaces {
+		namespaces[strings.ToLower(ns)] = name
+	}
+
+	return &SinglePortRouter{
+		hosted:     hosted,
+		upstreams:  upstreams,
+		groups:     groups,
+		hosts:      hosts,
+		namespaces: namespaces,
+	}
+}
+
+// SetAuthenticator sets the middleware which authenticates requests of the selected registries.
+func (rt *SinglePortRouter) SetAuthenticator(authenticate func(http.Handler) http.Handler) {
+	rt.authenticate = authenticate
+}
+
+func (rt *SinglePortRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if name, ok := rt.hosts[strings.ToLower(requestHost(r))]; ok {
+		regID, h := rt.handlerByName(name)
+		rt.serve(w, r, regID, h)
+		return
+	}
+
+	if ns := r.URL.Query().Get("ns"); ns != "" {
+		regID, h := rt.handlerByNamespace(strings.ToLower(ns))
+		rt.serve(w, r, regID, h)
+		return
+	}
+
+	if name, rest, ok := splitUpstreamPrefix(r.URL.Path); ok {
+		if regID, h := rt.handlerByName(name); h != nil {
+			rt.serve(w, withoutUpstreamPrefix(r, name, rest), regID, h)
+			return
+		}
+	}
+
+	if rt.hosted == nil {
+		rt.serve(w, r, "", nil)
+		return
+	}
+	rt.serve(w, r, constants.HostedRegistryID, rt.hosted)
+}
+
+// serve serves the request by the selected registry. Requests which don't have a registry are authenticated as
+// well, so clients are challenged for credentials before they learn which registries exist.
+func (rt *SinglePortRouter) serve(w http.ResponseWriter, r *http.Request, regID string, h http.Handler) {
+	if h == nil {
+		// upstream is not found, disabled or not started yet
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			dockererrors.WriteRepositoryNotFound(w)
+		})
+	}
+	WithAuthenticator(h, regID, rt.authenticate).ServeHTTP(w, r)
+}
+
+// handlerByName returns the id and the handler of the running upstream or group with the given name.
+func (rt *SinglePortRouter) handlerByName(name string) (string, http.Handler) {
+	if regID, h := rt.upstreams.handlerByName(name); h != nil {
+		return regID, h
+	}
+	if rt.groups == nil {
+		return "", nil
+	}
+	return rt.groups.handlerByName(name)
+}
+
+func (rt *SinglePortRouter) handlerByNamespace(ns string) (string, http.Handler) {
+	if name, ok := rt.namespaces[ns]; ok {
+		return rt.handlerByName(name)
+	}
+
+	if regID, h := rt.upstreams.handlerByUpstreamHost(ns); h != nil {
+		return regID, h
+	}
+
+	if ns == "docker.io" {
+		for _, host := range dockerHubHosts {
+			if regID, h := rt.upstreams.handlerByUpstreamHost(host); h != nil {
+				return regID, h
+			}
+		}
+	}
+	return "", nil
+}
+
+// splitUpstreamPrefix splits `/v2/<name>/<rest>` paths. Paths of repositories without namespace
+// (`/v2/<repository>/manifests/...`) are not split since `<name>` is the repository in that case.
+func splitUpstreamPrefix(path string) (name, rest string, ok bool) {
+	trimmed, found := strings.CutPrefix(path, "/v2/")
+	if !found {
+		return "", "", false
+	}
+
+	name, rest, found = strings.Cut(trimmed, "/")
+	if !found || name == "" {
+		return "", "", false
+	}
+
+	for _, segment := range []string{"manifests/", "blobs/", "tags/"} {
+		if strings.HasPrefix(rest, segment) {
+			return "", "", false
+		}
+	}
+	return name, rest, true
+}
+
+// withoutUpstreamPrefix is similar to http.StripPrefix. Removed prefix is kept in the context, so
+// urls returned to clients can include it.
+func withoutUpstreamPrefix(r *http.Request, name, rest string) *http.Request {
+	r2 := r.WithContext(context.WithValue(r.Context(), routePrefixKey{}, "/"+name))
+	u := *r.URL
+	u.Path = "/v2/" + rest
+	u.RawPath = ""
+	r2.URL = &u
+	return r2
+}
+
+// routePrefix returns the upstream prefix which was removed from the path of the request by SinglePortRouter.
+func routePrefix(r *http.Request) string {
+	prefix, _ := r.Context().Value(routePrefixKey{}).(string)
+	return prefix
+}
+
+func requestHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		return r.Host
+	}
+	return host
+}
@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRoute(name, upstreamHost string) *upstreamRoute {
+	sh := &swappableHandler{}
+	sh.swap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", name)
+		w.Header().Set("X-Path", r.URL.Path)
+		w.Header().Set("X-Route-Prefix", routePrefix(r))
+	}))
+	return &upstreamRoute{name: name, upstreamHost: upstreamHost, handler: sh}
+}
+
+func TestSinglePortRouter(t *testing.T) {
+	upstreams := &UpstreamListenerController{
+		upstreams: map[string]*upstreamRoute{
+			"reg1": newTestRoute("dockerhub", "registry-1.docker.io"),
+			"reg2": newTestRoute("quay-mirror", "quay.io"),
+			"reg3": newTestRoute("internal", "registry.internal.example.com"),
+		},
+	}
+
+	groups := &GroupListenerController{
+		groups: map[string]*groupRoute{
+			"group1": {name: "all-images", handler: newTestRoute("all-images", "").handler},
+		},
+	}
+
+	hosted := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", "hosted")
+		w.Header().Set("X-Path", r.URL.Path)
+	})
+
+	router := NewSinglePortRouter(hosted, upstreams, groups, config.RegistryRoutingConfig{
+		Hosts:      map[string]string{"Internal.Registry.Example.com": "internal", "images.example.com": "all-images"},
+		Namespaces: map[string]string{"ghcr.io": "quay-mirror"},
+	})
+
+	tcs := []struct {
+		name           string
+		host           string
+		target         string
+		expectedStatus int
+		servedBy       string
+		path           string
+		prefix         string
+	}{
+		{"Host header", "internal.registry.example.com:5000", "/v2/team/app/manifests/1.0", http.StatusOK,
+			"internal", "/v2/team/app/manifests/1.0", ""},
+		{"Docker Hub namespace", "localhost:5000", "/v2/library/nginx/manifests/latest?ns=docker.io", http.StatusOK,
+			"dockerhub", "/v2/library/nginx/manifests/latest", ""},
+		{"Namespace matches upstream host", "localhost:5000", "/v2/coreos/etcd/manifests/latest?ns=quay.io",
+			http.StatusOK, "quay-mirror", "/v2/coreos/etcd/manifests/latest", ""},
+		{"Namespace mapped in config", "localhost:5000", "/v2/org/tool/manifests/latest?ns=ghcr.io",
+			http.StatusOK, "quay-mirror", "/v2/org/tool/manifests/latest", ""},
+		{"Unknown namespace", "localhost:5000", "/v2/org/tool/manifests/latest?ns=gcr.io", http.StatusNotFound,
+			"", "", ""},
+		{"Path prefix", "localhost:5000", "/v2/dockerhub/library/nginx/manifests/latest", http.StatusOK,
+			"dockerhub", "/v2/library/nginx/manifests/latest", "/dockerhub"},
+		{"Path prefix of version check", "localhost:5000", "/v2/dockerhub/", http.StatusOK,
+			"dockerhub", "/v2/", "/dockerhub"},
+		{"Group path prefix", "localhost:5000", "/v2/all-images/team/app/manifests/1.0", http.StatusOK,
+			"all-images", "/v2/team/app/manifests/1.0", "/all-images"},
+		{"Group host header", "images.example.com", "/v2/team/app/manifests/1.0", http.StatusOK,
+			"all-images", "/v2/team/app/manifests/1.0", ""},
+		{"Repository without namespace", "localhost:5000", "/v2/dockerhub/manifests/latest", http.StatusOK,
+			"hosted", "/v2/dockerhub/manifests/latest", ""},
+		{"Hosted repository", "localhost:5000", "/v2/team/app/blobs/sha256:abc", http.StatusOK,
+			"hosted", "/v2/team/app/blobs/sha256:abc", ""},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			req.Host = tc.host
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, tc.servedBy, rec.Header().Get("X-Served-By"))
+			assert.Equal(t, tc.path, rec.Header().Get("X-Path"))
+			assert.Equal(t, tc.prefix, rec.Header().Get("X-Route-Prefix"))
+		})
+	}
+
+	t.Run("Hosted registry disabled", func(t *testing.T) {
+		router := NewSinglePortRouter(nil, upstreams, nil, config.RegistryRoutingConfig{})
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2/team/app/manifests/1.0", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2/dockerhub/library/nginx/manifests/latest", nil))
+		assert.Equal(t, "dockerhub", rec.Header().Get("X-Served-By"))
+	})
+
+	t.Run("Authenticates selected registry", func(t *testing.T) {
+		router.SetAuthenticator(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				registryID, _ := r.Context().Value(constants.ContextRegistryID).(string)
+				w.Header().Set("X-Registry-ID", registryID)
+				w.Header().Set("X-Authenticated-Path", r.URL.Path)
+				next.ServeHTTP(w, r)
+			})
+		})
+		defer router.SetAuthenticator(nil)
+
+		authTcs := []struct {
+			name       string
+			target     string
+			registryID string
+			path       string
+		}{
+			{"Hosted repository", "/v2/team/app/manifests/1.0", constants.HostedRegistryID,
+				"/v2/team/app/manifests/1.0"},
+			{"Path prefix", "/v2/dockerhub/library/nginx/manifests/latest", "reg1",
+				"/v2/library/nginx/manifests/latest"},
+			{"Group path prefix", "/v2/all-images/team/app/manifests/1.0", "group1", "/v2/team/app/manifests/1.0"},
+			{"Unknown namespace", "/v2/org/tool/manifests/latest?ns=gcr.io", "", "/v2/org/tool/manifests/latest"},
+		}
+
+		for _, tc := range authTcs {
+			t.Run(tc.name, func(t *testing.T) {
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.target, nil))
+
+				assert.Equal(t, tc.registryID, rec.Header().Get("X-Registry-ID"))
+				assert.Equal(t, tc.path, rec.Header().Get("X-Authenticated-Path"))
+			})
+		}
+	})
+}
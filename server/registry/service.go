@@ -3,15 +3,21 @@ package registry
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"path"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	up "github.com/ksankeerth/open-image-registry/client/upstream"
+	"github.com/ksankeerth/open-image-registry/client/upstream/credentials"
 	"github.com/ksankeerth/open-image-registry/client/upstream/docker"
+	"github.com/ksankeerth/open-image-registry/client/upstream/health"
 	"github.com/ksankeerth/open-image-registry/config"
 	"github.com/ksankeerth/open-image-registry/constants"
+	clienterrors "github.com/ksankeerth/open-image-registry/errors/client"
 	"github.com/ksankeerth/open-image-registry/store"
 	"github.com/ksankeerth/open-image-registry/types/models"
 
@@ -24,8 +30,13 @@ import (
 type upstreamInfo struct {
 	cacheEnabled bool
 	cacheTTL     int
+	ttlRules     []models.CacheTTLRule
+	negativeTTL  int
 }
 
+// digestCacheExpiry is the expiry of manifests cached by digest. Content of a digest never changes.
+var digestCacheExpiry = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
 type RegistryService struct {
 	store           store.Store
 	registryName    string
@@ -34,12 +45,21 @@ type RegistryService struct {
 	repositoryIdMap sync.Map
 	upstream        *upstreamInfo
 	client          up.UpstreamClient
+	breaker         *health.CircuitBreaker
+	rateLimiter     *health.RateLimiter
+	// notFound holds manifests which upstream reported as not found.
+	notFound *negativeCache
+	// transport carries outbound proxy and TLS settings of the upstream. Health probes use it as well.
+	transport *http.Transport
 }
 
 func NewRegistryService(registryID, registryName string, store store.Store) *RegistryService {
 
 	var upstream upstreamInfo
 	var client up.UpstreamClient
+	var breaker *health.CircuitBreaker
+	var rateLimiter *health.RateLimiter
+	var transport *http.Transport
 
 	if registryID != constants.HostedRegistryID {
 		registryModel, err := store.Upstreams().GetRegistry(context.Background(), registryID)
@@ -48,10 +68,15 @@ func NewRegistryService(registryID, registryName string, store store.Store) *Reg
 			return nil
 		}
 
-		if registryModel.Vendor != constants.RegistryVendorDockerHub {
-			// For now, we only support docker-hub
+		if registryModel == nil {
+			log.Logger().Warn().Str("registry", registryName).Msg("Upstream Registry does not exist")
+			return nil
+		}
+
+		if !isSupportedVendor(registryModel.Vendor) {
+			// For now, we only support docker-hub and registries of cloud providers
 			// TODO: add support for other upstream registeries
-			log.Logger().Warn().Msg("OpenImageRegistry currently supports  DockerHub only")
+			log.Logger().Warn().Msg("OpenImageRegistry currently supports DockerHub, ECR, GCR and ACR only")
 			return nil
 		}
 
@@ -60,8 +85,16 @@ func NewRegistryService(registryID, registryName string, store store.Store) *Reg
 			log.Logger().Error().Err(err).Msg("Registry Service Initialization failed due to database errors")
 			return nil
 		}
+
+		if cacheModel == nil {
+			log.Logger().Warn().Str("registry", registryName).
+				Msg("Upstream Registry exists without cache config")
+			return nil
+		}
 		upstream.cacheEnabled = cacheModel.CacheEnabled
 		upstream.cacheTTL = cacheModel.TTLSeconds
+		upstream.ttlRules = cacheModel.TTLRules
+		upstream.negativeTTL = cacheModel.NegativeTTLSeconds
 
 		networkConfig, err := store.Upstreams().GetRegistryNetworkConfig(context.Background(), registryID)
 		if err != nil {
@@ -100,13 +133,40 @@ func NewRegistryService(registryID, registryName string, store store.Store) *Reg
 		cfg.Username = dockerAuthConfig.Username
 		cfg.Password = dockerAuthConfig.Credential
 
-		cfg.ConnectionTimeout = time.Duration(networkConfig.ConnectionTimeout)
-		cfg.RequestTimeout = time.Duration(networkConfig.ReadTimeout)
+		cfg.ConnectionTimeout = time.Duration(networkConfig.ConnectionTimeout) * time.Second
+		cfg.ReadTimeout = time.Duration(networkConfig.ReadTimeout) * time.Second
+		cfg.WriteTimeout = time.Duration(networkConfig.WriteTimeout) * time.Second
 		cfg.MaxConnections = networkConfig.MaxConnections
 		cfg.MaxIdleConnections = networkConfig.MaxIdleConnections
 		cfg.MaxRetries = networkConfig.MaxRetries
 		cfg.RetryBackOffMultiplier = networkConfig.RetryBackOffMultiplier
 
+		transport, err = up.NewTransport(toTransportConfig(networkConfig))
+		if err != nil {
+			log.Logger().Error().Err(err).Str("registry", registryName).
+				Msg("Registry Service Initialization failed due to invalid network config")
+			return nil
+		}
+		cfg.Transport = transport
+
+		if credentials.IsCloudAuthType(authConfig.AuthType) {
+			// token exchanges go through the proxy and TLS config of the upstream as well
+			cfg.Credentials, err = credentials.NewProvider(authConfig.AuthType, authConfig.ConfigJSON,
+				registryModel.UpstreamURL, &http.Client{Transport: transport, Timeout: credentials.DefaultTimeout})
+			if err != nil {
+				log.Logger().Error().Err(err).Str("registry", registryName).
+					Msg("Registry Service Initialization failed due to invalid auth config")
+				return nil
+			}
+			// ECR does not issue bearer tokens. Credentials have to be sent with each request.
+			cfg.BasicAuth = authConfig.AuthType == constants.UpstreamAuthTypeAWSECR
+		}
+
+		breaker = health.GetMonitor().Breaker(registryID)
+		cfg.Breaker = breaker
+		rateLimiter = health.GetMonitor().RateLimiter(registryID)
+		cfg.RateLimiter = rateLimiter
+
 		client = docker.NewClient(&cfg)
 	}
 
@@ -116,7 +176,35 @@ func NewRegistryService(registryID, registryName string, store store.Store) *Reg
 		store:        store,
 		upstream:     &upstream,
 		client:       client,
+		breaker:      breaker,
+		rateLimiter:  rateLimiter,
+		notFound:     newNegativeCache(time.Duration(upstream.negativeTTL) * time.Second),
+		transport:    transport,
+	}
+}
+
+func toTransportConfig(networkConfig *models.UpstreamRegistryNetworkConfig) *up.TransportConfig {
+	cfg := &up.TransportConfig{
+		ConnectionTimeout:  time.Duration(networkConfig.ConnectionTimeout) * time.Second,
+		MaxConnections:     networkConfig.MaxConnections,
+		MaxIdleConnections: networkConfig.MaxIdleConnections,
+		CABundle:           networkConfig.TLSCABundle,
+		ClientCertificate:  networkConfig.TLSClientCert,
+		ClientKey:          networkConfig.TLSClientKey,
+		InsecureSkipVerify: networkConfig.TLSInsecureSkipVerify,
+	}
+
+	if networkConfig.ProxyEnabled {
+		cfg.ProxyURL = networkConfig.ProxyURL
+		cfg.ProxyUsername = networkConfig.ProxyUsername
+		cfg.ProxyPassword = networkConfig.ProxyPassword
 	}
+	return cfg
+}
+
+func isSupportedVendor(vendor string) bool {
+	return vendor == constants.RegistryVendorDockerHub || vendor == constants.RegistryVendorECR ||
+		vendor == constants.RegistryVendorGCR || vendor == constants.RegistryVendorACR
 }
 
 func (svc *RegistryService) initiateBlobUpload(reqCtx context.Context, namespace, repository string) (sessionID string,
@@ -409,6 +497,23 @@ func (svc *RegistryService) uploadBlobWhole(reqCtx context.Context, namespace, r
 		return nil, err
 	}
 
+	nsId, repoId, err := svc.getNameSpaceIdAndRepositoryId(ctx, namespace, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	// blob meta is needed to serve the blob(e.g. pulls and replication), same as chunked uploads.
+	blobMeta, err := svc.store.Blobs().Get(ctx, digest, repoId)
+	if err != nil {
+		return nil, err
+	}
+	if blobMeta == nil {
+		err = svc.store.Blobs().Create(ctx, svc.registryId, nsId, repoId, digest, location, int64(len(payload)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err = svc.store.Blobs().DeleteUploadSession(ctx, sessionID)
 	return result, err
 }
@@ -431,9 +536,9 @@ func (svc *RegistryService) getImageBlob(reqCtx context.Context, namespace, repo
 	return svc.loadImageBlob(ctx, namespace, repository, digest, false)
 }
 
-func (svc *RegistryService) pullBlobFromUpstream(_ context.Context, namespace, repository, digest string) (content []byte,
+func (svc *RegistryService) pullBlobFromUpstream(ctx context.Context, namespace, repository, digest string) (content []byte,
 	err error) {
-	return svc.client.GetBlob(namespace, repository, digest)
+	return svc.client.GetBlob(ctx, namespace, repository, digest)
 }
 
 func (svc *RegistryService) loadImageBlob(ctx context.Context, namespace, repository,
@@ -493,6 +598,9 @@ func (svc *RegistryService) loadImageBlobFromUpstream(ctx context.Context, names
 		if blobMeta == nil {
 			// not found in cache, load from cache and store it in cache
 			content, err = svc.pullBlobFromUpstream(ctx, namespace, repository, digest)
+			if clienterrors.IsNotFound(err) {
+				return false, nil, nil
+			}
 			if err != nil {
 				return false, nil, err
 			}
@@ -504,6 +612,8 @@ func (svc *RegistryService) loadImageBlobFromUpstream(ctx context.Context, names
 			return true, content, nil
 		}
 
+		svc.touchBlob(ctx, repositoryID, digest)
+
 		if skipContent {
 			return true, nil, nil
 		}
@@ -520,13 +630,16 @@ func (svc *RegistryService) loadImageBlobFromUpstream(ctx context.Context, names
 		return true, content, nil
 	} else {
 		if skipContent {
-			exists, err = svc.client.HeadBlob(namespace, repository, digest)
+			exists, err = svc.client.HeadBlob(ctx, namespace, repository, digest)
 			if err != nil {
 				return false, nil, err
 			}
-			return true, nil, nil
+			return exists, nil, nil
 		} else {
-			content, err = svc.client.GetBlob(namespace, repository, digest)
+			content, err = svc.client.GetBlob(ctx, namespace, repository, digest)
+			if clienterrors.IsNotFound(err) {
+				return false, nil, nil
+			}
 			if err != nil {
 				return false, nil, err
 			}
@@ -535,6 +648,14 @@ func (svc *RegistryService) loadImageBlobFromUpstream(ctx context.Context, names
 	}
 }
 
+// touchBlob records access of the cached blob. Serving the blob is not affected by failures.
+func (svc *RegistryService) touchBlob(ctx context.Context, repositoryID, digest string) {
+	err := svc.store.Blobs().Touch(ctx, repositoryID, digest)
+	if err != nil {
+		log.Logger().Warn().Err(err).Msgf("Failed to record access of cached blob (%s@%s)", svc.registryName, digest)
+	}
+}
+
 // cacheBlob stores the blob fetched from upstream registry and add meta information in database
 func (svc *RegistryService) cacheBlob(ctx context.Context, namespace, repository, digest string,
 	payload []byte) error {
@@ -544,7 +665,7 @@ func (svc *RegistryService) cacheBlob(ctx context.Context, namespace, repository
 		return err
 	}
 
-	nsId, repoId, err := svc.getNameSpaceIdAndRepositoryId(ctx, namespace, repository)
+	nsId, repoId, err := svc.getOrCreateRepository(ctx, namespace, repository)
 	if err != nil {
 		return err
 	}
@@ -581,6 +702,36 @@ func (svc *RegistryService) getNameSpaceIdAndRepositoryId(ctx context.Context, n
 	return nsId, repositoryId, nil
 }
 
+// getOrCreateRepository returns IDs of the namespace and repository. Images of upstream registries are cached
+// under namespaces and repositories of the upstream registry. Therefore, they are created on first cache.
+func (svc *RegistryService) getOrCreateRepository(ctx context.Context, namespace,
+	repository string) (nsId, repositoryId string, err error) {
+	nsId, repositoryId, err = svc.getNameSpaceIdAndRepositoryId(ctx, namespace, repository)
+	if err != nil {
+		return "", "", err
+	}
+
+	if nsId == "" {
+		nsId, err = svc.store.Namespaces().Create(ctx, svc.registryId, namespace, "", "", false, svc.registryName)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Failed to create namespace(%s) of upstream registry", namespace)
+			return "", "", err
+		}
+	}
+
+	if repositoryId == "" {
+		repositoryId, err = svc.store.Repositories().Create(ctx, svc.registryId, nsId, repository, "", false,
+			svc.registryName)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Failed to create repository(%s/%s) of upstream registry", namespace,
+				repository)
+			return "", "", err
+		}
+	}
+
+	return nsId, repositoryId, nil
+}
+
 func (svc *RegistryService) getNamespaceID(ctx context.Context, namespace string) (string, error) {
 	val, ok := svc.namespaceIdMap.Load(namespace)
 	if ok {
@@ -592,7 +743,10 @@ func (svc *RegistryService) getNamespaceID(ctx context.Context, namespace string
 		return "", err
 	}
 
-	svc.namespaceIdMap.Store(namespace, nsId)
+	// missing namespaces are not cached since they can be created on push
+	if nsId != "" {
+		svc.namespaceIdMap.Store(namespace, nsId)
+	}
 	return nsId, nil
 }
 
@@ -613,7 +767,9 @@ func (svc *RegistryService) getRepositoryID(ctx context.Context, namespace, repo
 	if err != nil {
 		return "", err
 	}
-	svc.repositoryIdMap.Store(key, repositoryId)
+	if repositoryId != "" {
+		svc.repositoryIdMap.Store(key, repositoryId)
+	}
 
 	return repositoryId, nil
 }
@@ -678,6 +834,12 @@ func (svc *RegistryService) loadImageManifest(ctx context.Context, namespace, re
 
 		return exists, mediaType, digest, content, err
 	} else {
+		if svc.notFound.contains(namespace, repository, tagOrDigest) {
+			log.Logger().Debug().Msgf("Manifest (%s/%s/%s:%s) was not found in upstream recently",
+				svc.registryName, namespace, repository, tagOrDigest)
+			return false, "", "", nil, nil
+		}
+
 		if svc.upstream.cacheEnabled {
 			exists, digest, mediaType, content, err = svc.loadManifestFromCache(ctx, namespace, repository,
 				tagOrDigest, skipContent)
@@ -687,7 +849,29 @@ func (svc *RegistryService) loadImageManifest(ctx context.Context, namespace, re
 			if exists {
 				return true, mediaType, digest, content, nil
 			}
-			content, mediaType, err := svc.client.GetManifest(namespace, repository, tagOrDigest)
+
+			if !utils.IsImageDigest(tagOrDigest) && svc.quotaLow() {
+				// HEAD requests don't consume quota. The manifest is not downloaded if it is cached by digest.
+				exists, digest, mediaType, content, err = svc.loadManifestByUpstreamDigest(ctx, namespace,
+					repository, tagOrDigest, skipContent)
+				if err != nil {
+					return false, "", "", nil, err
+				}
+				if exists {
+					return true, mediaType, digest, content, nil
+				}
+			}
+
+			// HEAD requests above may have found that the manifest does not exist
+			if svc.notFound.contains(namespace, repository, tagOrDigest) {
+				return false, "", "", nil, nil
+			}
+
+			content, mediaType, err := svc.client.GetManifest(ctx, namespace, repository, tagOrDigest)
+			if errors.Is(err, up.ErrManifestNotFound) {
+				svc.notFound.add(namespace, repository, tagOrDigest)
+				return false, "", "", nil, nil
+			}
 			if err != nil {
 				return false, "", "", nil, err
 			}
@@ -705,15 +889,25 @@ func (svc *RegistryService) loadImageManifest(ctx context.Context, namespace, re
 			return true, mediaType, digest, content, nil
 		} else {
 			if skipContent {
-				exists, err = svc.client.HeadManifest(namespace, repository, tagOrDigest)
+				exists, digest, err = svc.client.HeadManifest(ctx, namespace, repository, tagOrDigest)
 			} else {
-				content, mediaType, err = svc.client.GetManifest(namespace, repository, tagOrDigest)
-				exists = true
+				content, mediaType, err = svc.client.GetManifest(ctx, namespace, repository, tagOrDigest)
+				exists = err == nil
+				if errors.Is(err, up.ErrManifestNotFound) {
+					err = nil
+				}
 			}
 			if err != nil {
 				return false, "", "", nil, err
 			}
-			return exists, mediaType, utils.CalcuateDigest(content), content, nil
+			if !exists {
+				svc.notFound.add(namespace, repository, tagOrDigest)
+				return false, "", "", nil, nil
+			}
+			if !skipContent {
+				digest = utils.CalcuateDigest(content)
+			}
+			return true, mediaType, digest, content, nil
 		}
 	}
 }
@@ -735,12 +929,22 @@ func (svc *RegistryService) loadManifestFromCache(ctx context.Context, namsespac
 		return false, "", "", nil, nil
 	}
 
-	// TODO stable tag
-	if cacheModel.ExpiresAt.Before(time.Now()) {
-		// We won't delete the cache entry. Even if the time expired, manifest may not be changed in upstream
-		// After retriving manifest from upstream proxy, we'll check digest values. if they are same, We'll
-		// refresh the cache entry instead of deleting and adding again.
-		return false, "", "", nil, nil
+	// Manifests cached by digest never expire. Entries which were cached with a TTL are served as well.
+	if !utils.IsImageDigest(tagOrDigest) && cacheModel.ExpiresAt.Before(time.Now()) && !svc.upstreamUnavailable() {
+		// We won't delete the cache entry. Even if the time expired, manifest may not be changed in upstream.
+		// Digest of the tag is checked with a HEAD request. If it is same, the cache entry is refreshed instead
+		// of downloading the manifest again.
+		valid, err := svc.revalidateTag(ctx, namsespace, repository, cacheModel)
+		if err != nil {
+			log.Logger().Warn().Err(err).Msgf("Revalidation of cached manifest (%s/%s/%s:%s) failed",
+				svc.registryName, namsespace, repository, tagOrDigest)
+			if !svc.quotaLow() {
+				return false, "", "", nil, nil
+			}
+			// Downloading the manifest would consume the remaining quota, so the expired manifest is served.
+		} else if !valid {
+			return false, "", "", nil, nil
+		}
 	}
 
 	if utils.IsImageDigest(tagOrDigest) {
@@ -749,6 +953,16 @@ func (svc *RegistryService) loadManifestFromCache(ctx context.Context, namsespac
 		exists, _, mediaType, content, err = svc.loadManifestByTag(ctx, namsespace, repository, tagOrDigest, skipContent)
 	}
 
+	if exists {
+		err = svc.store.Cache().Touch(ctx, repoId, tagOrDigest)
+		if err != nil {
+			// Serving the manifest is not affected by failures of recording accesses
+			log.Logger().Warn().Err(err).Msgf("Failed to record access of cached manifest (%s/%s/%s:%s)",
+				svc.registryName, namsespace, repository, tagOrDigest)
+			err = nil
+		}
+	}
+
 	if !exists {
 		log.Logger().Warn().Msgf("Cache reference for manifest: (%s/%s/%s@%s) exists but actual manifest is not available in the database",
 			svc.registryName, namsespace, repository, digest)
@@ -763,86 +977,201 @@ func (svc *RegistryService) loadManifestFromCache(ctx context.Context, namsespac
 	return
 }
 
-// cacheManifest stores a  manifest reference in cache table and actual manifest will be stored
+// revalidateTag checks whether the tag still refers the cached digest in upstream. The cache entry is
+// refreshed if so.
+func (svc *RegistryService) revalidateTag(ctx context.Context, namespace, repository string,
+	cacheModel *models.RegistryCacheModel) (valid bool, err error) {
+	exists, digest, err := svc.client.HeadManifest(ctx, namespace, repository, cacheModel.Identifier)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		svc.notFound.add(namespace, repository, cacheModel.Identifier)
+	}
+
+	if !exists || digest != cacheModel.Digest {
+		log.Logger().Debug().Msgf("Cached manifest (%s/%s/%s:%s) is stale", svc.registryName, namespace,
+			repository, cacheModel.Identifier)
+		if exists && svc.quotaLow() {
+			return svc.retagCachedManifest(ctx, namespace, repository, cacheModel.RepositoryID,
+				cacheModel.Identifier, digest)
+		}
+		return false, nil
+	}
+
+	err = svc.store.Cache().Refresh(ctx, cacheModel.RepositoryID, cacheModel.Identifier,
+		svc.cacheExpiry(cacheModel.Identifier))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// cacheExpiry returns the expiry of a cache entry. Tags expire after TTL of the first matching rule, or TTL
+// of the upstream if none of the rules match.
+func (svc *RegistryService) cacheExpiry(identifier string) time.Time {
+	if utils.IsImageDigest(identifier) {
+		return digestCacheExpiry
+	}
+
+	ttl := svc.upstream.cacheTTL
+	for _, rule := range svc.upstream.ttlRules {
+		if ok, _ := path.Match(rule.TagPattern, identifier); ok {
+			ttl = rule.TTLSeconds
+			break
+		}
+	}
+	return time.Now().Add(time.Duration(ttl) * time.Second)
+}
+
+// loadManifestByUpstreamDigest resolves the tag to a digest with a HEAD request and loads the manifest from
+// cache if it is cached by digest or another tag. The tag is cached as well.
+func (svc *RegistryService) loadManifestByUpstreamDigest(ctx context.Context, namespace, repository, tag string,
+	skipContent bool) (exists bool, digest, mediaType string, content []byte, err error) {
+	repositoryID, err := svc.getRepositoryID(ctx, namespace, repository)
+	if err != nil || repositoryID == "" {
+		return false, "", "", nil, err
+	}
+
+	exists, digest, err = svc.client.HeadManifest(ctx, namespace, repository, tag)
+	if err != nil {
+		log.Logger().Warn().Err(err).Msgf("Failed to resolve digest of %s/%s/%s:%s", svc.registryName, namespace,
+			repository, tag)
+		return false, "", "", nil, nil
+	}
+	if !exists {
+		svc.notFound.add(namespace, repository, tag)
+		return false, "", "", nil, nil
+	}
+	if digest == "" {
+		return false, "", "", nil, nil
+	}
+
+	found, err := svc.retagCachedManifest(ctx, namespace, repository, repositoryID, tag, digest)
+	if err != nil || !found {
+		return false, "", "", nil, err
+	}
+
+	return svc.loadManifestByTag(ctx, namespace, repository, tag, skipContent)
+}
+
+// retagCachedManifest points the cached tag to the manifest of the digest if the manifest is cached already,
+// so it is not downloaded again.
+func (svc *RegistryService) retagCachedManifest(ctx context.Context, namespace, repository, repositoryID, tag,
+	digest string) (found bool, err error) {
+	manifest, err := svc.store.Manifests().GetByDigest(ctx, false, repositoryID, digest)
+	if err != nil || manifest == nil {
+		return false, err
+	}
+
+	log.Logger().Debug().Msgf("Upstream quota is low, %s/%s/%s:%s is served from cached manifest %s",
+		svc.registryName, namespace, repository, tag, digest)
+
+	err = svc.cacheManifest(ctx, namespace, repository, tag, digest, manifest.MediaType, nil)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// upstreamUnavailable reports whether requests to upstream are rejected by the circuit breaker or held back
+// due to rate limits. Expired cache entries are served until the upstream accepts requests again.
+func (svc *RegistryService) upstreamUnavailable() bool {
+	if svc.breaker != nil && svc.breaker.IsOpen() {
+		log.Logger().Warn().Str("registry", svc.registryName).
+			Msg("Upstream is unhealthy, serving expired manifest from cache")
+		return true
+	}
+
+	if svc.rateLimiter != nil && svc.rateLimiter.Allow() != nil {
+		log.Logger().Warn().Str("registry", svc.registryName).
+			Msg("Upstream rate limit is exceeded, serving expired manifest from cache")
+		return true
+	}
+	return false
+}
+
+// quotaLow reports whether remaining quota of the upstream is low. Cached manifests and HEAD requests are
+// preferred over downloading manifests then.
+func (svc *RegistryService) quotaLow() bool {
+	return svc.rateLimiter != nil && svc.rateLimiter.IsLow()
+}
+
+// cacheManifest stores a manifest reference in cache table and actual manifest will be stored
 func (svc *RegistryService) cacheManifest(ctx context.Context, namespace, repository, identifier, digest,
 	mediaType string, content []byte) error {
 
-	validTill := time.Now().Add(time.Duration(svc.upstream.cacheTTL) * time.Second)
-	nsId, repositoryId, err := svc.getNameSpaceIdAndRepositoryId(ctx, namespace, repository)
+	validTill := svc.cacheExpiry(identifier)
+	nsId, repositoryId, err := svc.getOrCreateRepository(ctx, namespace, repository)
 	if err != nil {
 		return err
 	}
 
-	cacheEntry, err := svc.store.Cache().Get(ctx, repositoryId, digest)
+	cacheEntry, err := svc.store.Cache().Get(ctx, repositoryId, identifier)
 	if err != nil {
 		return err
 	}
 
-	var cacheMiss bool
-	if cacheEntry == nil { // no cache entry
-		err = svc.store.Cache().Create(ctx, svc.registryId, nsId, repositoryId, identifier, digest, validTill)
-		cacheMiss = true
-	} else { // cache entry exists
-		if cacheEntry.Digest == digest { // digest is same
-			err = svc.store.Cache().Refresh(ctx, repositoryId, identifier, validTill)
-		} else { // digest has changed. so let's add new manifest, identifier is a tag
-			cacheMiss = true
-			err = svc.store.Cache().Delete(ctx, repositoryId, identifier)
-			if err != nil {
-				return err
-			}
-			err = svc.store.Manifests().DeleteByDigest(ctx, repositoryId, digest)
-			if err != nil {
-				return err
-			}
-			err = svc.store.Cache().Create(ctx, svc.registryId, nsId, repositoryId, identifier, digest, validTill)
+	if cacheEntry != nil && cacheEntry.Digest == digest { // digest is same
+		return svc.store.Cache().Refresh(ctx, repositoryId, identifier, validTill)
+	}
+
+	if cacheEntry != nil { // digest has changed. so let's add new manifest, identifier is a tag
+		err = svc.store.Cache().Delete(ctx, repositoryId, identifier)
+		if err != nil {
+			return err
 		}
 	}
 
+	err = svc.store.Cache().Create(ctx, svc.registryId, nsId, repositoryId, identifier, digest, validTill)
 	if err != nil {
 		return err
 	}
 
-	// for upstream manifests, unique-digest = digest
-	if cacheMiss {
-		manifestID, err := svc.store.Manifests().Create(ctx, svc.registryId, nsId, repositoryId, digest, mediaType, digest,
-			int64(len(content)), content)
+	// manifest may be cached already by another tag or by digest
+	manifest, err := svc.store.Manifests().GetByDigest(ctx, false, repositoryId, digest)
+	if err != nil {
+		return err
+	}
+
+	var manifestID string
+	if manifest != nil {
+		manifestID = manifest.ID
+	} else {
+		// for upstream manifests, unique-digest = digest
+		manifestID, err = svc.store.Manifests().Create(ctx, svc.registryId, nsId, repositoryId, digest, mediaType,
+			digest, int64(len(content)), content)
 		if err != nil {
 			return err
 		}
+	}
 
-		// if identifier is tag, link the tag and manifest
-		if !utils.IsImageDigest(identifier) {
-			tag, err := svc.store.Tags().Get(ctx, repositoryId, identifier)
-			if err != nil {
-				return err
-			}
-
-			if tag == nil {
-				tagID, err := svc.store.Tags().Create(ctx, svc.registryId, nsId, repositoryId, identifier)
-				if err != nil {
-					return err
-				}
+	// if identifier is tag, link the tag and manifest
+	if utils.IsImageDigest(identifier) {
+		return nil
+	}
 
-				err = svc.store.Tags().LinkManifest(ctx, tagID, manifestID)
-				if err != nil {
-					return err
-				}
-			} else {
-				err = svc.store.Tags().UnlinkManifest(ctx, tag.Id)
-				if err != nil {
-					return err
-				}
-				err = svc.store.Tags().LinkManifest(ctx, tag.Id, manifestID)
-				if err != nil {
-					return err
-				}
-			}
+	tag, err := svc.store.Tags().Get(ctx, repositoryId, identifier)
+	if err != nil {
+		return err
+	}
 
+	if tag == nil {
+		tagID, err := svc.store.Tags().Create(ctx, svc.registryId, nsId, repositoryId, identifier)
+		if err != nil {
+			return err
 		}
+		return svc.store.Tags().LinkManifest(ctx, tagID, manifestID)
 	}
 
-	return nil
+	linkedManifestID, err := svc.store.Tags().GetManifestID(ctx, tag.Id)
+	if err != nil {
+		return err
+	}
+	if linkedManifestID == "" {
+		return svc.store.Tags().LinkManifest(ctx, tag.Id, manifestID)
+	}
+	return svc.store.Tags().UpdateManifest(ctx, tag.Id, manifestID)
 }
 
 func (svc *RegistryService) loadManifestByTag(ctx context.Context, namespace, repository, tag string,
@@ -937,8 +1266,12 @@ func (svc *RegistryService) updateManifest(reqCtx context.Context, namespace, re
 			return "", err
 		}
 		res.ManifestId = manifestId
+		// existing tag is moved to the new manifest
+		res.TagManifestLinkChanged = res.TagManifestLinkExists
+	}
 
-		svc.store.Tags().LinkManifest(ctx, res.TagId, res.ManifestId)
+	if !res.TagManifestLinkExists {
+		err = svc.store.Tags().LinkManifest(ctx, res.TagId, res.ManifestId)
 		if err != nil {
 			return "", err
 		}
@@ -967,16 +1300,15 @@ func (svc *RegistryService) scanManifest(ctx context.Context, uniqueDigest, name
 		return nil, err
 	}
 
-	if manifestModel == nil {
-		return &ManifestScanResult{ManifestExists: false}, nil
+	result := &ManifestScanResult{
+		UniqueDigest: uniqueDigest,
+		NamespaceId:  nsId,
+		RepositoryId: repoId,
 	}
 
-	result := &ManifestScanResult{
-		ManifestExists: true,
-		UniqueDigest:   uniqueDigest,
-		ManifestId:     manifestModel.ID,
-		NamespaceId:    nsId,
-		RepositoryId:   repoId,
+	if manifestModel != nil {
+		result.ManifestExists = true
+		result.ManifestId = manifestModel.ID
 	}
 
 	// Check tag existence
@@ -995,9 +1327,8 @@ func (svc *RegistryService) scanManifest(ctx context.Context, uniqueDigest, name
 		if err != nil {
 			return nil, err
 		}
-		if oldManifestId == "" {
-			result.TagManifestLinkExists = false
-		}
+		result.TagManifestLinkExists = oldManifestId != ""
+		result.TagManifestLinkChanged = result.TagManifestLinkExists && oldManifestId != result.ManifestId
 	}
 
 	return result, nil
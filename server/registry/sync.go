@@ -0,0 +1,362 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+// SyncScheduler runs sync jobs of upstreams. Scheduled jobs are run once their interval elapses and
+// jobs can be run on demand. A job is not run again while it is running.
+//
+// A run resolves tags of the repositories against the upstream and caches the manifests and blobs of
+// matching tags, so images are served from cache when they are pulled.
+type SyncScheduler struct {
+	store     store.Store
+	upstreams *UpstreamListenerController
+	cfg       config.UpstreamSyncConfig
+	// ctx is the context of the scheduler. Jobs which are run on demand outlive their requests.
+	ctx context.Context
+}
+
+// syncManifest holds the fields of image manifests and indexes which are needed to find references.
+type syncManifest struct {
+	Config    *syncDescriptor  `json:"config"`
+	Layers    []syncDescriptor `json:"layers"`
+	Manifests []syncDescriptor `json:"manifests"`
+}
+
+type syncDescriptor struct {
+	MediaType string        `json:"mediaType"`
+	Digest    string        `json:"digest"`
+	Platform  *syncPlatform `json:"platform"`
+}
+
+type syncPlatform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant"`
+}
+
+func NewSyncScheduler(s store.Store, upstreams *UpstreamListenerController,
+	cfg config.UpstreamSyncConfig) *SyncScheduler {
+	return &SyncScheduler{
+		store:     s,
+		upstreams: upstreams,
+		cfg:       cfg,
+		ctx:       context.Background(),
+	}
+}
+
+// Start runs due jobs in background until the context is cancelled.
+func (s *SyncScheduler) Start(ctx context.Context) {
+	s.ctx = ctx
+
+	// Jobs which were running when the server stopped are marked as failed.
+	err := s.store.SyncJobs().ResetRunningJobs(ctx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to reset interrupted upstream sync jobs")
+	}
+
+	go s.run(ctx)
+}
+
+// RunJob runs the job in background. started is false if the job is already running.
+func (s *SyncScheduler) RunJob(ctx context.Context, job *models.UpstreamSyncJob) (started bool, err error) {
+	started, err = s.store.SyncJobs().MarkJobRunning(ctx, job.ID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to start upstream sync job(%s)", job.Name)
+		return false, err
+	}
+	if !started {
+		return false, nil
+	}
+
+	go s.execute(s.ctx, job)
+	return true, nil
+}
+
+func (s *SyncScheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.cfg.PollIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		s.runDueJobs(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *SyncScheduler) runDueJobs(ctx context.Context) {
+	jobs, err := s.store.SyncJobs().GetDueJobs(ctx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to load scheduled upstream sync jobs")
+		return
+	}
+
+	for _, job := range jobs {
+		started, err := s.store.SyncJobs().MarkJobRunning(ctx, job.ID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Failed to start upstream sync job(%s)", job.Name)
+			continue
+		}
+		if started {
+			s.execute(ctx, job)
+		}
+	}
+}
+
+func (s *SyncScheduler) execute(ctx context.Context, job *models.UpstreamSyncJob) {
+	var synced int
+	var err error
+
+	svc := s.upstreams.service(job.RegistryID)
+	switch {
+	case svc == nil:
+		err = fmt.Errorf("upstream is disabled or not running")
+	case !svc.upstream.cacheEnabled:
+		err = fmt.Errorf("cache is disabled for the upstream")
+	default:
+		synced, err = svc.syncImages(ctx, job)
+	}
+
+	status, message := constants.SyncJobSucceeded, ""
+	if err != nil {
+		log.Logger().Warn().Err(err).Msgf("Upstream sync job(%s) failed", job.Name)
+		status, message = constants.SyncJobFailed, err.Error()
+	} else {
+		log.Logger().Info().Msgf("Upstream sync job(%s) cached %d images", job.Name, synced)
+	}
+
+	err = s.store.SyncJobs().RecordJobResult(ctx, job.ID, status, message, synced)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to record result of upstream sync job(%s)", job.Name)
+	}
+}
+
+// syncImages caches all tags of the job's repositories which match its tag patterns. Failures of an
+// image don't stop the others. The first failure is returned along with the count of failed images.
+func (svc *RegistryService) syncImages(ctx context.Context, job *models.UpstreamSyncJob) (synced int, err error) {
+	patterns := splitList(job.TagPatterns)
+	platforms := splitList(job.Platforms)
+
+	var failed int
+	var firstErr error
+	fail := func(image string, err error) {
+		log.Logger().Warn().Err(err).Msgf("Upstream sync job(%s) failed to cache %s", job.Name, image)
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", image, err)
+		}
+		failed++
+	}
+
+	for _, name := range splitList(job.Repositories) {
+		if ctx.Err() != nil {
+			return synced, ctx.Err()
+		}
+
+		namespace, repository := splitRepositoryName(name)
+
+		tags, err := svc.client.ListTags(ctx, namespace, repository)
+		if err != nil {
+			fail(name, err)
+			continue
+		}
+
+		for _, tag := range tags {
+			if !matchesAnyPattern(patterns, tag) {
+				continue
+			}
+
+			err = svc.syncImage(ctx, namespace, repository, tag, platforms)
+			if err != nil {
+				fail(name+":"+tag, err)
+				continue
+			}
+			synced++
+		}
+	}
+
+	if firstErr != nil {
+		return synced, fmt.Errorf("%d images failed, first failure: %w", failed, firstErr)
+	}
+	return synced, nil
+}
+
+// syncImage caches the manifest of the tag after the blobs and child manifests referred by it. Children of
+// indexes are filtered by platforms. Manifests are fetched before transactions are opened, so the
+// database is not locked while waiting for the upstream.
+func (svc *RegistryService) syncImage(ctx context.Context, namespace, repository, tag string,
+	platforms []string) error {
+	content, mediaType, err := svc.client.GetManifest(ctx, namespace, repository, tag)
+	if err != nil {
+		return err
+	}
+
+	var manifest syncManifest
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	for _, child := range manifest.Manifests {
+		if !matchesPlatform(platforms, child.Platform) {
+			continue
+		}
+
+		childContent, childMediaType, err := svc.client.GetManifest(ctx, namespace, repository, child.Digest)
+		if err != nil {
+			return err
+		}
+		if utils.CalcuateDigest(childContent) != child.Digest {
+			return fmt.Errorf("digest of manifest %s does not match its content", child.Digest)
+		}
+
+		err = svc.syncManifest(ctx, namespace, repository, child.Digest, child.Digest, childMediaType,
+			childContent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return svc.syncManifest(ctx, namespace, repository, tag, utils.CalcuateDigest(content), mediaType, content)
+}
+
+// syncManifest caches the blobs referred by the manifest and then the manifest.
+func (svc *RegistryService) syncManifest(reqCtx context.Context, namespace, repository, identifier, digest,
+	mediaType string, content []byte) error {
+	var manifest syncManifest
+	err := json.Unmarshal(content, &manifest)
+	if err != nil {
+		return fmt.Errorf("failed to parse manifest %s: %w", identifier, err)
+	}
+
+	blobs := manifest.Layers
+	if manifest.Config != nil && manifest.Config.Digest != "" {
+		blobs = append(blobs, *manifest.Config)
+	}
+	for _, blob := range blobs {
+		err = svc.syncBlob(reqCtx, namespace, repository, blob.Digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	return svc.withTx(reqCtx, func(ctx context.Context) error {
+		return svc.cacheManifest(ctx, namespace, repository, identifier, digest, mediaType, content)
+	})
+}
+
+// syncBlob downloads the blob only if it is not cached yet.
+func (svc *RegistryService) syncBlob(reqCtx context.Context, namespace, repository, digest string) error {
+	repositoryID, err := svc.getRepositoryID(reqCtx, namespace, repository)
+	if err != nil {
+		return err
+	}
+
+	if repositoryID != "" {
+		blobMeta, err := svc.store.Blobs().Get(reqCtx, digest, repositoryID)
+		if err != nil {
+			return err
+		}
+		if blobMeta != nil {
+			return nil
+		}
+	}
+
+	content, err := svc.client.GetBlob(reqCtx, namespace, repository, digest)
+	if err != nil {
+		return err
+	}
+	if utils.CalcuateDigest(content) != digest {
+		return fmt.Errorf("digest of blob %s does not match its content", digest)
+	}
+
+	return svc.withTx(reqCtx, func(ctx context.Context) error {
+		return svc.cacheBlob(ctx, namespace, repository, digest, content)
+	})
+}
+
+func (svc *RegistryService) withTx(reqCtx context.Context, fn func(ctx context.Context) error) (err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to cache image due to database transaction errors")
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	return fn(store.WithTxContext(reqCtx, tx))
+}
+
+// splitList splits comma separated values of sync jobs.
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// splitRepositoryName splits names like `library/alpine`. Names without a namespace belong to the default
+// namespace.
+func splitRepositoryName(name string) (namespace, repository string) {
+	namespace, repository, found := strings.Cut(name, "/")
+	if !found {
+		return constants.DefaultNamespace, name
+	}
+	return namespace, repository
+}
+
+func matchesAnyPattern(patterns []string, tag string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPlatform checks the platform against filters like `linux/amd64` or `linux/arm/v7`. Variant is
+// compared only if the filter has it. Empty filters match all platforms.
+func matchesPlatform(filters []string, platform *syncPlatform) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	if platform == nil {
+		return false
+	}
+
+	for _, filter := range filters {
+		parts := strings.Split(filter, "/")
+		if len(parts) < 2 || parts[0] != platform.OS || parts[1] != platform.Architecture {
+			continue
+		}
+		if len(parts) == 2 || parts[2] == platform.Variant {
+			return true
+		}
+	}
+	return false
+}
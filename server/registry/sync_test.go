@@ -0,0 +1,42 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesPlatform(t *testing.T) {
+	amd64 := &syncPlatform{OS: "linux", Architecture: "amd64"}
+	armV7 := &syncPlatform{OS: "linux", Architecture: "arm", Variant: "v7"}
+
+	tests := []struct {
+		name     string
+		filters  []string
+		platform *syncPlatform
+		matches  bool
+	}{
+		{"Empty filters match all", nil, armV7, true},
+		{"Exact platform", []string{"linux/amd64"}, amd64, true},
+		{"Architecture not selected", []string{"linux/arm64"}, amd64, false},
+		{"Filter without variant", []string{"linux/arm"}, armV7, true},
+		{"Variant not selected", []string{"linux/arm/v6"}, armV7, false},
+		{"Descriptor without platform", []string{"linux/amd64"}, nil, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.matches, matchesPlatform(tc.filters, tc.platform))
+		})
+	}
+}
+
+func TestSplitRepositoryName(t *testing.T) {
+	namespace, repository := splitRepositoryName("alpine")
+	assert.Equal(t, "library", namespace)
+	assert.Equal(t, "alpine", repository)
+
+	namespace, repository = splitRepositoryName("team/app")
+	assert.Equal(t, "team", namespace)
+	assert.Equal(t, "app", repository)
+}
@@ -0,0 +1,50 @@
+package replication
+
+import (
+	"path"
+	"strings"
+
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+// ValidateFilter checks whether the filter is a comma separated list of valid glob patterns.
+func ValidateFilter(filter string) bool {
+	for _, pattern := range splitFilter(filter) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesFilter reports whether the value matches any pattern of the filter. Empty filter matches all values.
+func matchesFilter(filter, value string) bool {
+	patterns := splitFilter(filter)
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func splitFilter(filter string) []string {
+	patterns := make([]string, 0)
+	for _, pattern := range strings.Split(filter, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// matchesRule reports whether the image is selected by the rule.
+func matchesRule(rule *models.ReplicationRule, namespace, repository, tag string) bool {
+	return matchesFilter(rule.NamespaceFilter, namespace) && matchesFilter(rule.RepositoryFilter, repository) &&
+		matchesFilter(rule.TagFilter, tag)
+}
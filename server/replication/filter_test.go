@@ -0,0 +1,43 @@
+package replication
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+func TestMatchesRule(t *testing.T) {
+	rule := &models.ReplicationRule{
+		NamespaceFilter:  "team-*, platform",
+		RepositoryFilter: "",
+		TagFilter:        "v*,latest",
+	}
+
+	tests := []struct {
+		name       string
+		namespace  string
+		repository string
+		tag        string
+		matches    bool
+	}{
+		{"Namespace pattern", "team-a", "api", "v1.0.0", true},
+		{"Exact namespace", "platform", "gateway", "latest", true},
+		{"Namespace not selected", "sandbox", "api", "v1.0.0", false},
+		{"Tag not selected", "team-a", "api", "dev", false},
+		{"Empty filter matches all repositories", "team-b", "any-repository", "v2", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.matches, matchesRule(rule, tc.namespace, tc.repository, tc.tag))
+		})
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	assert.True(t, ValidateFilter(""))
+	assert.True(t, ValidateFilter("release-*, v[0-9]*"))
+	assert.False(t, ValidateFilter("v[0-9"))
+}
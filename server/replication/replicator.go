@@ -0,0 +1,337 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+// tasksPerBatch limits the tasks which are loaded from the queue at once.
+const tasksPerBatch = 10
+
+// ImageSource reads images which are replicated. Hosted registry implements it.
+type ImageSource interface {
+	GetManifest(ctx context.Context, namespace, repository, tagOrDigest string) (exists bool, mediaType, digest string,
+		content []byte, err error)
+
+	GetBlob(ctx context.Context, namespace, repository, digest string) (exists bool, content []byte, err error)
+}
+
+// Replicator copies images of hosted registry to remote registries according to replication rules.
+//
+// Each image(tag) to be copied is queued as a task in the database. Pushes queue tasks for push rules and
+// scheduled rules queue tasks for all matching tags once their interval elapses. Queued tasks are processed
+// periodically. Failed tasks are retried with exponential backoff until attempts are exhausted.
+type Replicator struct {
+	store  store.Store
+	source ImageSource
+	cfg    config.ReplicationConfig
+	// wake triggers processing of the queue without waiting for the next poll.
+	wake chan struct{}
+}
+
+// imageManifest holds the fields of image manifests and indexes which are needed to find references.
+type imageManifest struct {
+	MediaType string            `json:"mediaType"`
+	Config    *imageDescriptor  `json:"config"`
+	Layers    []imageDescriptor `json:"layers"`
+	Manifests []imageDescriptor `json:"manifests"`
+}
+
+type imageDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+func NewReplicator(s store.Store, source ImageSource, cfg config.ReplicationConfig) *Replicator {
+	return &Replicator{
+		store:  s,
+		source: source,
+		cfg:    cfg,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Start processes the queue in background until the context is cancelled.
+func (r *Replicator) Start(ctx context.Context) {
+	// Tasks which were running when the server stopped are queued again.
+	err := r.store.Replications().ResetRunningTasks(ctx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to reset interrupted replication tasks")
+	}
+
+	go r.run(ctx)
+}
+
+// ManifestPushed queues replication of the pushed tag for matching push rules.
+func (r *Replicator) ManifestPushed(ctx context.Context, namespace, repository, reference, digest string) {
+	// Manifests pushed by digest are children of an index. They are replicated along with the index.
+	if utils.IsImageDigest(reference) {
+		return
+	}
+
+	rules, err := r.store.Replications().GetEnabledRules(ctx, constants.ReplicationTriggerPush)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to load replication rules for %s/%s:%s", namespace, repository,
+			reference)
+		return
+	}
+
+	queued := false
+	for _, rule := range rules {
+		if !matchesRule(rule, namespace, repository, reference) {
+			continue
+		}
+
+		ok, err := r.store.Replications().EnqueueTask(ctx, rule.ID, namespace, repository, reference)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Failed to queue replication of %s/%s:%s for rule(%s)", namespace,
+				repository, reference, rule.Name)
+			continue
+		}
+		queued = queued || ok
+	}
+
+	if queued {
+		r.notify()
+	}
+}
+
+// EnqueueRule queues replication of all tags of hosted registry which match the rule.
+func (r *Replicator) EnqueueRule(ctx context.Context, rule *models.ReplicationRule) (queued int, err error) {
+	tags, err := r.store.ImageQueries().ListImageTags(ctx, constants.HostedRegistryID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to load images for replication rule(%s)", rule.Name)
+		return 0, err
+	}
+
+	for _, t := range tags {
+		if utils.IsImageDigest(t.Tag) || !matchesRule(rule, t.Namespace, t.Repository, t.Tag) {
+			continue
+		}
+
+		ok, err := r.store.Replications().EnqueueTask(ctx, rule.ID, t.Namespace, t.Repository, t.Tag)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Failed to queue replication of %s/%s:%s for rule(%s)", t.Namespace,
+				t.Repository, t.Tag, rule.Name)
+			return queued, err
+		}
+		if ok {
+			queued++
+		}
+	}
+
+	if queued > 0 {
+		r.notify()
+	}
+	return queued, nil
+}
+
+func (r *Replicator) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Replicator) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(r.cfg.PollIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		r.scheduleDueRules(ctx)
+		r.processDueTasks(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+func (r *Replicator) scheduleDueRules(ctx context.Context) {
+	rules, err := r.store.Replications().GetDueScheduledRules(ctx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to load scheduled replication rules")
+		return
+	}
+
+	for _, rule := range rules {
+		queued, err := r.EnqueueRule(ctx, rule)
+		if err != nil {
+			continue
+		}
+
+		err = r.store.Replications().MarkRuleScheduled(ctx, rule.ID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Failed to update last schedule of replication rule(%s)", rule.Name)
+			continue
+		}
+
+		log.Logger().Debug().Msgf("Scheduled replication rule(%s) queued %d images", rule.Name, queued)
+	}
+}
+
+func (r *Replicator) processDueTasks(ctx context.Context) {
+	for ctx.Err() == nil {
+		tasks, err := r.store.Replications().GetDueTasks(ctx, tasksPerBatch)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to load replication tasks")
+			return
+		}
+		if len(tasks) == 0 {
+			return
+		}
+
+		for _, task := range tasks {
+			r.processTask(ctx, task)
+		}
+	}
+}
+
+func (r *Replicator) processTask(ctx context.Context, task *models.ReplicationTask) {
+	image := fmt.Sprintf("%s/%s:%s", task.Namespace, task.Repository, task.Tag)
+
+	err := r.store.Replications().MarkTaskRunning(ctx, task.ID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to start replication of %s", image)
+		return
+	}
+
+	// tasks of deleted or disabled rules are not retried
+	abandoned := false
+
+	rule, err := r.store.Replications().GetRule(ctx, task.RuleID)
+	switch {
+	case err != nil:
+	case rule == nil || !rule.Enabled:
+		err = fmt.Errorf("replication rule is deleted or disabled")
+		abandoned = true
+	default:
+		err = r.replicate(ctx, rule, task)
+	}
+
+	attempts := task.Attempts + 1
+	switch {
+	case err == nil:
+		log.Logger().Info().Msgf("Replicated %s to %s by rule(%s)", image, rule.TargetURL, rule.Name)
+		err = r.store.Replications().MarkTaskSucceeded(ctx, task.ID)
+	case abandoned || attempts >= r.cfg.MaxAttempts:
+		log.Logger().Error().Err(err).Msgf("Replication of %s failed after %d attempts", image, attempts)
+		err = r.store.Replications().MarkTaskFailed(ctx, task.ID, attempts, err.Error())
+	default:
+		delay := r.backoff(attempts)
+		log.Logger().Warn().Err(err).Msgf("Replication of %s failed, retrying in %s", image, delay)
+		err = r.store.Replications().MarkTaskForRetry(ctx, task.ID, attempts, err.Error(), delay)
+	}
+
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to record result of replication of %s", image)
+	}
+}
+
+// backoff returns the delay before the next attempt. It doubles on each attempt.
+func (r *Replicator) backoff(attempts int) time.Duration {
+	delay := time.Duration(r.cfg.BackoffSeconds) * time.Second
+	maxDelay := time.Duration(r.cfg.MaxBackoffSeconds) * time.Second
+
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+func (r *Replicator) replicate(ctx context.Context, rule *models.ReplicationRule, task *models.ReplicationTask) error {
+	exists, mediaType, digest, content, err := r.source.GetManifest(ctx, task.Namespace, task.Repository, task.Tag)
+	if err != nil {
+		return fmt.Errorf("failed to load manifest: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("image does not exist in hosted registry")
+	}
+
+	client := newTargetClient(rule, time.Duration(r.cfg.TimeoutSeconds)*time.Second)
+	name := task.Namespace + "/" + task.Repository
+
+	return r.copyManifest(ctx, client, task, name, task.Tag, mediaType, digest, content)
+}
+
+// copyManifest copies the manifest after the blobs and child manifests which are referred by it, so the
+// remote registry can verify references when the manifest is pushed.
+func (r *Replicator) copyManifest(ctx context.Context, client *targetClient, task *models.ReplicationTask, name,
+	reference, mediaType, digest string, content []byte) error {
+	var manifest imageManifest
+	err := json.Unmarshal(content, &manifest)
+	if err != nil {
+		return fmt.Errorf("failed to parse manifest %s: %w", reference, err)
+	}
+
+	for _, child := range manifest.Manifests {
+		exists, childMediaType, childDigest, childContent, err := r.source.GetManifest(ctx, task.Namespace,
+			task.Repository, child.Digest)
+		if err != nil {
+			return fmt.Errorf("failed to load manifest %s: %w", child.Digest, err)
+		}
+		if !exists {
+			return fmt.Errorf("manifest %s does not exist in hosted registry", child.Digest)
+		}
+
+		err = r.copyManifest(ctx, client, task, name, child.Digest, childMediaType, childDigest, childContent)
+		if err != nil {
+			return err
+		}
+	}
+
+	blobs := manifest.Layers
+	if manifest.Config != nil && manifest.Config.Digest != "" {
+		blobs = append(blobs, *manifest.Config)
+	}
+	for _, blob := range blobs {
+		err = r.copyBlob(ctx, client, task, name, blob.Digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	remoteDigest, err := client.manifestDigest(ctx, name, reference, mediaType)
+	if err != nil {
+		return err
+	}
+	if remoteDigest == digest {
+		return nil
+	}
+
+	return client.putManifest(ctx, name, reference, mediaType, content)
+}
+
+// copyBlob uploads the blob only if it is missing in the remote registry.
+func (r *Replicator) copyBlob(ctx context.Context, client *targetClient, task *models.ReplicationTask, name,
+	digest string) error {
+	exists, err := client.blobExists(ctx, name, digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	exists, content, err := r.source.GetBlob(ctx, task.Namespace, task.Repository, digest)
+	if err != nil {
+		return fmt.Errorf("failed to load blob %s: %w", digest, err)
+	}
+	if !exists {
+		return fmt.Errorf("blob %s does not exist in hosted registry", digest)
+	}
+
+	return client.uploadBlob(ctx, name, digest, content)
+}
@@ -0,0 +1,320 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+// targetClient pushes images to a remote registry through the OCI distribution API. Credentials of the
+// rule are sent as basic credentials or exchanged for a bearer token, depending on the challenge returned
+// by the remote registry.
+type targetClient struct {
+	baseURL    string
+	username   string
+	password   string
+	httpClient *http.Client
+
+	// authorizations caches `Authorization` header values by repository.
+	authorizations map[string]string
+	mu             sync.Mutex
+}
+
+type tokenResponse struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+}
+
+func newTargetClient(rule *models.ReplicationRule, timeout time.Duration) *targetClient {
+	return &targetClient{
+		baseURL:        strings.TrimSuffix(rule.TargetURL, "/"),
+		username:       rule.Username,
+		password:       rule.Password,
+		httpClient:     &http.Client{Timeout: timeout},
+		authorizations: make(map[string]string),
+	}
+}
+
+// blobExists checks the blob with a HEAD request, so only missing blobs are transferred.
+func (c *targetClient) blobExists(ctx context.Context, name, digest string) (bool, error) {
+	resp, err := c.do(ctx, name, http.MethodHead, fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL, name, digest), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status %d when checking blob %s", resp.StatusCode, digest)
+	}
+}
+
+// uploadBlob uploads the blob monolithically: a POST to open an upload session followed by a PUT with
+// the content.
+func (c *targetClient) uploadBlob(ctx context.Context, name, digest string, content []byte) error {
+	resp, err := c.do(ctx, name, http.MethodPost, fmt.Sprintf("%s/v2/%s/blobs/uploads/", c.baseURL, name), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected status %d when initiating upload of blob %s", resp.StatusCode, digest)
+	}
+
+	location, err := c.resolveLocation(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("invalid upload location for blob %s: %w", digest, err)
+	}
+
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+
+	resp, err = c.do(ctx, name, http.MethodPut, location.String(), header, content)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected status %d when uploading blob %s", resp.StatusCode, digest)
+	}
+	return nil
+}
+
+// manifestDigest returns the digest of the manifest in the remote registry. Empty digest is returned if
+// the manifest does not exist.
+func (c *targetClient) manifestDigest(ctx context.Context, name, reference, mediaType string) (string, error) {
+	header := http.Header{}
+	header.Set("Accept", mediaType)
+
+	resp, err := c.do(ctx, name, http.MethodHead, fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, name, reference),
+		header, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Header.Get("Docker-Content-Digest"), nil
+	case http.StatusNotFound:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unexpected status %d when checking manifest %s", resp.StatusCode, reference)
+	}
+}
+
+func (c *targetClient) putManifest(ctx context.Context, name, reference, mediaType string, content []byte) error {
+	header := http.Header{}
+	header.Set("Content-Type", mediaType)
+
+	resp, err := c.do(ctx, name, http.MethodPut, fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, name, reference),
+		header, content)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d when pushing manifest %s: %s", resp.StatusCode, reference,
+			string(body))
+	}
+	return nil
+}
+
+func (c *targetClient) resolveLocation(location string) (*url.URL, error) {
+	if location == "" {
+		return nil, fmt.Errorf("missing Location header")
+	}
+
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	return base.ResolveReference(ref), nil
+}
+
+// do sends the request to the remote registry. If the registry responds with 401, the challenge is
+// answered and the request is sent once more.
+func (c *targetClient) do(ctx context.Context, name, method, url string, header http.Header,
+	body []byte) (*http.Response, error) {
+	resp, err := c.send(ctx, method, url, header, body, c.authorization(name))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	authorization, err := c.authorize(ctx, name, challenge)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(ctx, method, url, header, body, authorization)
+}
+
+func (c *targetClient) send(ctx context.Context, method, url string, header http.Header, body []byte,
+	authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %w", c.baseURL, err)
+	}
+	return resp, nil
+}
+
+func (c *targetClient) authorization(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.authorizations[name]
+}
+
+// authorize answers the challenge of the remote registry and caches the resulting `Authorization` header
+// for the repository.
+func (c *targetClient) authorize(ctx context.Context, name, challenge string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+
+	var authorization string
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.username == "" {
+			return "", fmt.Errorf("remote registry requires credentials")
+		}
+		authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(c.username+":"+c.password))
+	case "bearer":
+		token, err := c.fetchToken(ctx, name, params)
+		if err != nil {
+			return "", err
+		}
+		authorization = "Bearer " + token
+	default:
+		return "", fmt.Errorf("remote registry rejected the request with unsupported challenge: %q", challenge)
+	}
+
+	c.mu.Lock()
+	c.authorizations[name] = authorization
+	c.mu.Unlock()
+
+	return authorization, nil
+}
+
+func (c *targetClient) fetchToken(ctx context.Context, name string, params map[string]string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("bearer challenge of remote registry does not have realm")
+	}
+
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid realm in bearer challenge: %w", err)
+	}
+
+	query := tokenURL.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	// Pushes need both pull and push access on the repository.
+	query.Set("scope", fmt.Sprintf("repository:%s:pull,push", name))
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	var tokenResp tokenResponse
+	err = json.NewDecoder(resp.Body).Decode(&tokenResp)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	token := tokenResp.Token
+	if token == "" {
+		token = tokenResp.AccessToken
+	}
+	if token == "" {
+		log.Logger().Warn().Str("realm", realm).Msg("Received empty token from auth server of remote registry")
+		return "", fmt.Errorf("received empty token from auth server")
+	}
+	return token, nil
+}
+
+// parseChallenge parses `WWW-Authenticate` header. e.g. Bearer realm="https://auth.example.com/token",
+// service="registry.example.com",scope="repository:library/alpine:pull,push"
+func parseChallenge(challenge string) (scheme string, params map[string]string) {
+	params = make(map[string]string)
+
+	challenge = strings.TrimSpace(challenge)
+	scheme, rest, _ := strings.Cut(challenge, " ")
+
+	for rest != "" {
+		var key string
+		key, rest, _ = strings.Cut(rest, "=")
+		key = strings.ToLower(strings.Trim(key, " ,"))
+
+		var value string
+		rest = strings.TrimLeft(rest, " ")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+
+		if key != "" {
+			params[key] = value
+		}
+		rest = strings.TrimLeft(rest, " ,")
+	}
+	return scheme, params
+}
@@ -0,0 +1,21 @@
+package replication
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",` +
+		`scope="repository:team/api:pull,push"`)
+
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, "https://auth.example.com/token", params["realm"])
+	assert.Equal(t, "registry.example.com", params["service"])
+	assert.Equal(t, "repository:team/api:pull,push", params["scope"])
+
+	scheme, params = parseChallenge(`Basic realm="Registry"`)
+	assert.Equal(t, "Basic", scheme)
+	assert.Equal(t, "Registry", params["realm"])
+}
@@ -69,6 +69,19 @@ func (g AccessOpFailure) String() string {
 // reason will be zero.
 func (m *Manager) GrantAccess(ctx context.Context, resourceID, resourceType, grantorID, granteeID,
 	accessLevel string) (reason AccessOpFailure, err error) {
+	return m.grantAccess(ctx, resourceID, resourceType, grantorID, granteeID, accessLevel, true)
+}
+
+// GrantMappedAccess grants resource access which is mapped from groups of an identity provider. Same conditions as
+// GrantAccess are assessed except the authority of the grantor, since there isn't one. The grantee is recorded as
+// the grantor.
+func (m *Manager) GrantMappedAccess(ctx context.Context, resourceID, resourceType, granteeID,
+	accessLevel string) (reason AccessOpFailure, err error) {
+	return m.grantAccess(ctx, resourceID, resourceType, granteeID, granteeID, accessLevel, false)
+}
+
+func (m *Manager) grantAccess(ctx context.Context, resourceID, resourceType, grantorID, granteeID,
+	accessLevel string, verifyGrantor bool) (reason AccessOpFailure, err error) {
 
 	if tx, _ := store.TxFromContext(ctx); tx == nil {
 		tx, err = m.store.Begin(ctx)
@@ -94,13 +107,15 @@ func (m *Manager) GrantAccess(ctx context.Context, resourceID, resourceType, gra
 	}
 
 	// 1. Verify grantor
-	ok, reason, err := m.verifyInitiatorAuthority(ctx, grantorID, accessLevel, resourceType, resourceID)
-	if !ok {
-		return reason, err
+	if verifyGrantor {
+		ok, reason, err := m.verifyInitiatorAuthority(ctx, grantorID, accessLevel, resourceType, resourceID)
+		if !ok {
+			return reason, err
+		}
 	}
 
 	// 2. Verify resource
-	ok, reason, err = m.verifyResource(ctx, resourceID, resourceType)
+	ok, reason, err := m.verifyResource(ctx, resourceID, resourceType)
 	if !ok {
 		return reason, err
 	}
@@ -354,6 +369,43 @@ func (m *Manager) GetUserAccessByLevels(ctx context.Context, userId string, page
 	return m.store.Access().List(ctx, &queryConds)
 }
 
+// RevokeAccessNotAllowedForRole revokes resource access of the user which isn't allowed for the given role. Roles
+// of users of identity providers are changed on login, so their access can't be revoked by admins beforehand.
+func (m *Manager) RevokeAccessNotAllowedForRole(ctx context.Context, userID, role string) (revoked int, err error) {
+	var notAllowed []*models.ResourceAccessView
+	allowedAccessLevels := accessLevelsByRole(role)
+
+	for page := uint(1); ; page++ {
+		accesses, total, err := m.GetUserAccessByLevels(ctx, userID, page, 100)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Revoking resource access failed when retrieving user access")
+			return 0, err
+		}
+
+		for _, a := range accesses {
+			if !slices.Contains(allowedAccessLevels, a.AccessLevel) ||
+				(role == constants.RoleAdmin && a.ResourceType != constants.ResourceTypeNamespace) {
+				notAllowed = append(notAllowed, a)
+			}
+		}
+
+		if len(accesses) == 0 || int(page)*100 >= total {
+			break
+		}
+	}
+
+	for _, a := range notAllowed {
+		err = m.store.Access().RevokeAccess(ctx, a.ResourceID, a.ResourceType, userID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Revoking resource access failed")
+			return revoked, err
+		}
+		revoked++
+	}
+
+	return revoked, nil
+}
+
 func (m *Manager) getResourceInfo(ctx context.Context, resourceType, id string) (exists bool,
 	state, parentState string, err error) {
 	switch resourceType {
@@ -434,7 +486,7 @@ func (m *Manager) verifyInitiatorAuthority(ctx context.Context, grantorID, acces
 		}
 
 		if nsId != "" {
-			currentAccess, err := m.store.Access().GetUserAccess(ctx, resourceId, resourceType, grantorID)
+			currentAccess, err := m.store.Access().GetUserAccess(ctx, nsId, constants.ResourceTypeNamespace, grantorID)
 			if err != nil {
 				return false, 0, err
 			}
@@ -549,9 +601,9 @@ func getRolesSatisfyingAccessLevel(accessLevel string) []string {
 	case constants.AccessLevelMaintainer:
 		return []string{constants.RoleMaintainer, constants.RoleAdmin}
 	case constants.AccessLevelDeveloper:
-		return []string{constants.RoleDeveloper, constants.RoleMaintainer}
+		return []string{constants.RoleDeveloper, constants.RoleMaintainer, constants.RoleMachine}
 	case constants.AccessLevelGuest:
-		return []string{constants.RoleGuest, constants.RoleDeveloper, constants.RoleMaintainer}
+		return []string{constants.RoleGuest, constants.RoleDeveloper, constants.RoleMaintainer, constants.RoleMachine}
 	default:
 		return []string{}
 	}
@@ -567,6 +619,9 @@ func accessLevelsByRole(role string) []string {
 		return []string{constants.AccessLevelDeveloper, constants.AccessLevelGuest}
 	case constants.RoleGuest:
 		return []string{constants.AccessLevelGuest}
+	case constants.RoleMachine:
+		// machine accounts can pull, or push and pull, but never manage resources
+		return []string{constants.AccessLevelDeveloper, constants.AccessLevelGuest}
 	}
 
 	return []string{}
@@ -0,0 +1,63 @@
+package group
+
+import (
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+func toGroupModel(id string, req *mgmt.CreateGroupRegistryRequest) *models.GroupRegistry {
+	state := req.Status
+	if state == "" {
+		state = constants.ResourceStateActive
+	}
+
+	return &models.GroupRegistry{
+		ID:           id,
+		Name:         req.Name,
+		Description:  req.Description,
+		State:        state,
+		Port:         uint(req.Port),
+		PushMemberID: req.PushMemberID,
+	}
+}
+
+func toGroupSummaryDTO(m *models.GroupRegistry) *mgmt.GroupRegistrySummaryDTO {
+	if m == nil {
+		return nil
+	}
+
+	return &mgmt.GroupRegistrySummaryDTO{
+		Id:           m.ID,
+		Name:         m.Name,
+		Description:  m.Description,
+		Port:         int(m.Port),
+		Status:       m.State,
+		PushMemberID: m.PushMemberID,
+		CreatedAt:    m.CreatedAt,
+		UpdatedAt:    m.UpdatedAt,
+	}
+}
+
+func makeGetGroupResponse(g *groupAggregate) *mgmt.GroupRegistryResponse {
+	res := &mgmt.GroupRegistryResponse{
+		GroupRegistrySummaryDTO: *toGroupSummaryDTO(g.group),
+		Members:                 make([]*mgmt.GroupRegistryMemberDTO, len(g.members)),
+	}
+
+	for i, m := range g.members {
+		member := &mgmt.GroupRegistryMemberDTO{
+			Id:       m.MemberID,
+			Name:     m.Name,
+			Type:     constants.GroupMemberTypeUpstream,
+			State:    m.State,
+			Position: m.Position,
+		}
+		if m.MemberID == constants.HostedRegistryID {
+			member.Name = constants.HostedRegistryName
+			member.Type = constants.GroupMemberTypeHosted
+		}
+		res.Members[i] = member
+	}
+	return res
+}
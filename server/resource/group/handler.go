@@ -0,0 +1,282 @@
+package group
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/errors/httperrors"
+	"github.com/ksankeerth/open-image-registry/lib"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/middleware"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+)
+
+type GroupHandler struct {
+	svc *groupService
+}
+
+func NewHandler(s store.Store, listeners ListenerSyncer) *GroupHandler {
+	svc := &groupService{
+		store:     s,
+		listeners: listeners,
+	}
+	return &GroupHandler{
+		svc,
+	}
+}
+
+func (g *GroupHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	// Group registries are managed by admins only.
+	r.Use(middleware.RequireRole(constants.RoleAdmin))
+
+	r.Post("/", g.CreateGroupRegistry)
+	r.Get("/", g.ListGroupRegistries)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Get("/", g.GetGroupRegistry)
+		r.Put("/", g.UpdateGroupRegistry)
+		r.Delete("/", g.DeleteGroupRegistry)
+		r.Patch("/state", g.ChangeGroupRegistryState)
+		r.Put("/members", g.UpdateGroupRegistryMembers)
+	})
+
+	return r
+}
+
+func (g *GroupHandler) CreateGroupRegistry(w http.ResponseWriter, r *http.Request) {
+	var req mgmt.CreateGroupRegistryRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to bad request: %s", r.RequestURI)
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
+
+	valid, errMsg := validateCreateGroupRequest(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	res, err := g.svc.createGroup(r.Context(), &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if res.statusCode != http.StatusCreated {
+		httperrors.SendError(w, res.statusCode, res.errMsg)
+		return
+	}
+
+	g.svc.syncListener(r.Context(), res.groupID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := mgmt.CreateGroupRegistryResponse{
+		GroupId:   res.groupID,
+		GroupName: req.Name,
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when writing response :%s", r.RequestURI)
+	}
+}
+
+func (g *GroupHandler) ListGroupRegistries(w http.ResponseWriter, r *http.Request) {
+	cond := lib.ParseListConditions(r, map[string]store.FilterOperator{})
+
+	ok, errMsg := validateListGroupCondition(cond)
+	if !ok {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	groups, total, err := g.svc.listGroups(r.Context(), cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	res := mgmt.EntityListResponse[*mgmt.GroupRegistrySummaryDTO]{
+		Total:    total,
+		Page:     int(cond.Page),
+		Limit:    int(cond.Limit),
+		Entities: make([]*mgmt.GroupRegistrySummaryDTO, len(groups)),
+	}
+
+	for index, group := range groups {
+		res.Entities[index] = toGroupSummaryDTO(group)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+func (g *GroupHandler) GetGroupRegistry(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	group, err := g.svc.getGroup(r.Context(), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if group == nil {
+		httperrors.NotFound(w, 404, "Not found")
+		return
+	}
+
+	res := makeGetGroupResponse(group)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when writing response :%s", r.RequestURI)
+	}
+}
+
+func (g *GroupHandler) UpdateGroupRegistry(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req mgmt.UpdateGroupRegistryRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Parsing request body of update group registry request failed")
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
+
+	valid, errMsg := validateUpdateGroupRequest(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	if id != req.GroupId {
+		log.Logger().Warn().Msgf("Group ID in request body does not match the ID in the URL path")
+		httperrors.BadRequest(w, 400, "Group ID in request body does not match the ID in the URL path")
+		return
+	}
+
+	result, err := g.svc.updateGroup(r.Context(), id, &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Request aborted due to errors")
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !result.success {
+		httperrors.SendError(w, result.httpStatusCode, result.httpErrorMsg)
+		return
+	}
+
+	g.svc.syncListener(r.Context(), id)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// UpdateGroupRegistryMembers replaces the members of the group. Images are resolved through members in
+// the order of the request.
+func (g *GroupHandler) UpdateGroupRegistryMembers(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req mgmt.UpdateGroupMembersRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Parsing request body of update group members request failed")
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
+
+	valid, errMsg := validateMembers(req.Members)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	result, err := g.svc.updateMembers(r.Context(), id, req.Members)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Request aborted due to errors")
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !result.success {
+		httperrors.SendError(w, result.httpStatusCode, result.httpErrorMsg)
+		return
+	}
+
+	g.svc.syncListener(r.Context(), id)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *GroupHandler) DeleteGroupRegistry(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	notFound, err := g.svc.deleteGroup(r.Context(), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if notFound {
+		httperrors.NotFound(w, 404, "Group registry not found")
+		return
+	}
+
+	g.svc.syncListener(r.Context(), id)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *GroupHandler) ChangeGroupRegistryState(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	state := r.URL.Query().Get("state")
+
+	if state == "" {
+		log.Logger().Warn().Msg("Changing group registry state request was rejected due to empty state")
+		httperrors.BadRequest(w, 400, "Missing query param state in request")
+		return
+	}
+
+	if !isValidState(state) {
+		log.Logger().Warn().Msgf("Changing group registry state request was rejected due to invalid state '%s'", state)
+		httperrors.BadRequest(w, 400, "Invalid group state")
+		return
+	}
+
+	result, err := g.svc.changeState(r.Context(), id, state)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Request aborted due to errors")
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !result.success {
+		httperrors.SendError(w, result.httpStatusCode, result.httpErrorMsg)
+		return
+	}
+
+	g.svc.syncListener(r.Context(), id)
+
+	w.WriteHeader(http.StatusOK)
+}
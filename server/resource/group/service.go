@@ -0,0 +1,365 @@
+package group
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+type groupService struct {
+	store     store.Store
+	listeners ListenerSyncer
+}
+
+// ListenerSyncer applies changes of a group registry to its listener. It starts, restarts or stops the
+// listener and reloads the members of the group.
+type ListenerSyncer interface {
+	Sync(ctx context.Context, groupID string) error
+}
+
+// groupAggregate holds group registry along with its members in resolution order.
+type groupAggregate struct {
+	group   *models.GroupRegistry
+	members []*models.GroupRegistryMemberView
+}
+
+type createGroupResult struct {
+	groupID    string
+	statusCode int
+	errMsg     string
+}
+
+type patchResult struct {
+	httpStatusCode int
+	httpErrorMsg   string
+	success        bool
+}
+
+func (svc *groupService) createGroup(reqCtx context.Context, req *mgmt.CreateGroupRegistryRequest) (res *createGroupResult,
+	err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to create group registry due to transaction errors")
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	res = &createGroupResult{}
+
+	res.statusCode, res.errMsg, err = svc.checkGroup(ctx, "", req)
+	if err != nil {
+		return nil, err
+	}
+	if res.errMsg != "" {
+		log.Logger().Warn().Msgf("Creating group registry(%s) was rejected: %s", req.Name, res.errMsg)
+		return res, nil
+	}
+
+	groupID, err := svc.store.Groups().CreateGroup(ctx, toGroupModel("", req))
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when creating group registry: %s", req.Name)
+		return nil, err
+	}
+
+	err = svc.store.Groups().SetMembers(ctx, groupID, req.Members)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when persisting members of group registry: %s", req.Name)
+		return nil, err
+	}
+
+	res.groupID = groupID
+	res.statusCode = http.StatusCreated
+	return res, nil
+}
+
+// checkGroup checks conflicts of name and port with other group and upstream registries and whether all
+// members exist. It returns the http status and the error message if the group is rejected.
+func (svc *groupService) checkGroup(ctx context.Context, id string, req *mgmt.CreateGroupRegistryRequest) (statusCode int,
+	errMsg string, err error) {
+	sameName, err := svc.store.Groups().GetGroupByName(ctx, req.Name)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to check group registry from database")
+		return 0, "", err
+	}
+	if sameName != nil && sameName.ID != id {
+		return http.StatusConflict, "Another group registry is available with same name", nil
+	}
+
+	upstream, err := svc.store.Upstreams().GetRegistryByName(ctx, req.Name)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to check upstream from database")
+		return 0, "", err
+	}
+	if upstream != nil {
+		return http.StatusConflict, "An upstream is available with same name", nil
+	}
+
+	inUse, err := svc.store.Groups().IsPortInUse(ctx, uint(req.Port), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to check usage of port: %d", req.Port)
+		return 0, "", err
+	}
+	if inUse {
+		return http.StatusConflict, "Another registry is available with same port", nil
+	}
+
+	return svc.checkMembers(ctx, req.Members)
+}
+
+func (svc *groupService) checkMembers(ctx context.Context, members []string) (statusCode int, errMsg string, err error) {
+	for _, memberID := range members {
+		if memberID == constants.HostedRegistryID {
+			continue
+		}
+
+		reg, err := svc.store.Upstreams().GetRegistry(ctx, memberID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Failed to check member(%s) from database", memberID)
+			return 0, "", err
+		}
+		if reg == nil {
+			return http.StatusBadRequest, "Unknown member: " + memberID, nil
+		}
+	}
+	return 0, "", nil
+}
+
+func (svc *groupService) getGroup(reqCtx context.Context, id string) (g *groupAggregate, err error) {
+	group, err := svc.store.Groups().GetGroup(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in retrieving group registry: %s", id)
+		return nil, err
+	}
+
+	if group == nil {
+		return nil, nil
+	}
+
+	members, err := svc.store.Groups().GetMembers(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in retrieving members of group registry: %s", id)
+		return nil, err
+	}
+
+	return &groupAggregate{group: group, members: members}, nil
+}
+
+func (svc *groupService) listGroups(reqCtx context.Context, cond *store.ListQueryConditions) (groups []*models.GroupRegistry,
+	total int, err error) {
+	groups, total, err = svc.store.Groups().ListGroups(reqCtx, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when listing group registries")
+		return nil, -1, err
+	}
+	return groups, total, nil
+}
+
+func (svc *groupService) updateGroup(reqCtx context.Context, id string, req *mgmt.UpdateGroupRegistryRequest) (result *patchResult,
+	err error) {
+	result = &patchResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update group registry due to transaction errors")
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	group, err := svc.store.Groups().GetGroup(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update group registry due to database errors")
+		return nil, err
+	}
+	if group == nil {
+		log.Logger().Warn().Msgf("Failed to update non existent group registry: %s", id)
+		result.httpStatusCode = http.StatusNotFound
+		result.httpErrorMsg = "Group registry " + id + " is not found"
+		return result, nil
+	}
+
+	result.httpStatusCode, result.httpErrorMsg, err = svc.checkGroup(ctx, id, &req.CreateGroupRegistryRequest)
+	if err != nil {
+		return nil, err
+	}
+	if result.httpErrorMsg != "" {
+		return result, nil
+	}
+
+	err = svc.store.Groups().UpdateGroup(ctx, toGroupModel(id, &req.CreateGroupRegistryRequest))
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to update group registry(%s) due to database errors", id)
+		return nil, err
+	}
+
+	err = svc.store.Groups().SetMembers(ctx, id, req.Members)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to update members of group registry(%s)", id)
+		return nil, err
+	}
+
+	result.success = true
+	return result, nil
+}
+
+func (svc *groupService) updateMembers(reqCtx context.Context, id string, members []string) (result *patchResult,
+	err error) {
+	result = &patchResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update members of group registry due to transaction errors")
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	group, err := svc.store.Groups().GetGroup(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update members of group registry due to database errors")
+		return nil, err
+	}
+	if group == nil {
+		result.httpStatusCode = http.StatusNotFound
+		result.httpErrorMsg = "Group registry " + id + " is not found"
+		return result, nil
+	}
+
+	if group.PushMemberID != "" && !slices.Contains(members, group.PushMemberID) {
+		result.httpStatusCode = http.StatusBadRequest
+		result.httpErrorMsg = "Push member should be a member of the group"
+		return result, nil
+	}
+
+	result.httpStatusCode, result.httpErrorMsg, err = svc.checkMembers(ctx, members)
+	if err != nil {
+		return nil, err
+	}
+	if result.httpErrorMsg != "" {
+		return result, nil
+	}
+
+	err = svc.store.Groups().SetMembers(ctx, id, members)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to update members of group registry(%s)", id)
+		return nil, err
+	}
+
+	result.success = true
+	return result, nil
+}
+
+func (svc *groupService) deleteGroup(reqCtx context.Context, id string) (notFound bool, err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to delete group registry due to transaction errors")
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	group, err := svc.store.Groups().GetGroup(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in checking group registry: %s", id)
+		return false, err
+	}
+
+	if group == nil {
+		log.Logger().Warn().Msgf("Attempt to delete non-existing group registry(%s) failed", id)
+		return true, nil
+	}
+
+	err = svc.store.Groups().SetMembers(ctx, id, nil)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in deleting members of group registry: %s", id)
+		return false, err
+	}
+
+	err = svc.store.Groups().DeleteGroup(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in deleting group registry: %s", id)
+		return false, err
+	}
+
+	return false, nil
+}
+
+func (svc *groupService) changeState(reqCtx context.Context, id, newState string) (result *patchResult, err error) {
+	result = &patchResult{}
+
+	group, err := svc.store.Groups().GetGroup(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to change state of group registry due to database errors")
+		return nil, err
+	}
+
+	if group == nil {
+		log.Logger().Warn().Msgf("Failed to change state of non existent group registry: %s", id)
+		result.httpStatusCode = http.StatusNotFound
+		result.httpErrorMsg = "Group registry " + id + " is not found"
+		return result, nil
+	}
+
+	if group.State == newState {
+		log.Logger().Debug().Msgf("No changes in state. Updating state of group registry(%s) is skipped", id)
+		result.success = true
+		return result, nil
+	}
+
+	err = svc.store.Groups().ChangeGroupState(reqCtx, id, newState)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to change state of group registry(%s) due to database errors", id)
+		return nil, err
+	}
+
+	result.success = true
+	return result, nil
+}
+
+// syncListener applies committed changes of the group to its listener. Errors are only logged
+// since the changes are already persisted.
+func (svc *groupService) syncListener(ctx context.Context, id string) {
+	if svc.listeners == nil {
+		return
+	}
+
+	err := svc.listeners.Sync(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Changes of group registry(%s) were not applied to its listener", id)
+	}
+}
@@ -0,0 +1,89 @@
+package group
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+func validateCreateGroupRequest(req *mgmt.CreateGroupRegistryRequest) (valid bool, errMsg string) {
+	if len(req.Name) < 3 || len(req.Name) > 255 || !utils.IsValidRegistry(req.Name) {
+		return false, "Invalid group name"
+	}
+
+	if len(req.Description) > 1000 {
+		return false, "Description should not exceed 1000 characters"
+	}
+
+	if req.Port < 1025 || req.Port > 65535 {
+		return false, "Port should be between 1025 and 65535"
+	}
+
+	if req.Status != "" && !isValidState(req.Status) {
+		return false, "Invalid group state"
+	}
+
+	if valid, errMsg = validateMembers(req.Members); !valid {
+		return false, errMsg
+	}
+
+	if req.PushMemberID != "" {
+		if req.PushMemberID != constants.HostedRegistryID {
+			return false, "Only hosted registry can be the push member"
+		}
+		if !slices.Contains(req.Members, req.PushMemberID) {
+			return false, "Push member should be a member of the group"
+		}
+	}
+
+	return true, ""
+}
+
+func validateUpdateGroupRequest(req *mgmt.UpdateGroupRegistryRequest) (valid bool, errMsg string) {
+	if req.GroupId == "" {
+		return false, "Invalid group ID in body"
+	}
+
+	return validateCreateGroupRequest(&req.CreateGroupRegistryRequest)
+}
+
+func validateMembers(members []string) (valid bool, errMsg string) {
+	if len(members) == 0 {
+		return false, "Group should have at least one member"
+	}
+
+	seen := make(map[string]bool, len(members))
+	for _, memberID := range members {
+		if memberID == "" {
+			return false, "Invalid member ID"
+		}
+		if seen[memberID] {
+			return false, fmt.Sprintf("Duplicate member: %s", memberID)
+		}
+		seen[memberID] = true
+	}
+	return true, ""
+}
+
+func validateListGroupCondition(cond *store.ListQueryConditions) (bool, string) {
+	if cond.SortField != "" && !slices.Contains(constants.AllowedGroupSortFields, cond.SortField) {
+		return false, fmt.Sprintf("Not allowed sort field: %s", cond.SortField)
+	}
+
+	for _, f := range cond.Filters {
+		if !slices.Contains(constants.AllowedGroupFilterFields, f.Field) {
+			return false, fmt.Sprintf("Not allowed filter field: %s", f.Field)
+		}
+	}
+
+	return true, ""
+}
+
+func isValidState(state string) bool {
+	return state == constants.ResourceStateActive || state == constants.ResourceStateDeprecated ||
+		state == constants.ResourceStateDisabled
+}
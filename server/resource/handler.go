@@ -4,23 +4,31 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	acesss "github.com/ksankeerth/open-image-registry/resource/access"
+	"github.com/ksankeerth/open-image-registry/resource/group"
 	"github.com/ksankeerth/open-image-registry/resource/namespace"
+	"github.com/ksankeerth/open-image-registry/resource/replication"
 	"github.com/ksankeerth/open-image-registry/resource/repository"
 	"github.com/ksankeerth/open-image-registry/resource/upstream"
 	"github.com/ksankeerth/open-image-registry/store"
 )
 
 type RegistryResourceHandler struct {
-	namespaceHandler  *namespace.NamespaceHandler
-	repositoryHandler *repository.RepositoryHandler
-	upstreamHandler   *upstream.UpstreamAccessHandler
+	namespaceHandler   *namespace.NamespaceHandler
+	repositoryHandler  *repository.RepositoryHandler
+	upstreamHandler    *upstream.UpstreamAccessHandler
+	groupHandler       *group.GroupHandler
+	replicationHandler *replication.ReplicationHandler
 }
 
-func NewRegistryResourceHandler(s store.Store, accessManager *acesss.Manager) *RegistryResourceHandler {
+func NewRegistryResourceHandler(s store.Store, accessManager *acesss.Manager,
+	upstreamListeners upstream.ListenerSyncer, groupListeners group.ListenerSyncer,
+	replicationRunner replication.RuleRunner, syncRunner upstream.SyncRunner) *RegistryResourceHandler {
 	return &RegistryResourceHandler{
-		namespaceHandler:  namespace.NewHandler(s, accessManager),
-		repositoryHandler: repository.NewHandler(s, accessManager),
-		upstreamHandler:   upstream.NewHandler(s),
+		namespaceHandler:   namespace.NewHandler(s, accessManager),
+		repositoryHandler:  repository.NewHandler(s, accessManager),
+		upstreamHandler:    upstream.NewHandler(s, upstreamListeners, syncRunner),
+		groupHandler:       group.NewHandler(s, groupListeners),
+		replicationHandler: replication.NewHandler(s, replicationRunner),
 	}
 }
 
@@ -28,9 +36,11 @@ func (h *RegistryResourceHandler) Routes() chi.Router {
 	router := chi.NewRouter()
 	router.Route("/", func(r chi.Router) {
 		r.Mount("/upstreams", h.upstreamHandler.Routes())
+		r.Mount("/groups", h.groupHandler.Routes())
+		r.Mount("/replications", h.replicationHandler.Routes())
 		r.Mount("/namespaces", h.namespaceHandler.Routes())
 		r.Mount("/repositories", h.repositoryHandler.Routes())
 	})
 
 	return router
-}
\ No newline at end of file
+}
@@ -0,0 +1,84 @@
+package replication
+
+import (
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+func toRuleModel(id string, req *mgmt.CreateReplicationRuleRequest) *models.ReplicationRule {
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	return &models.ReplicationRule{
+		ID:                      id,
+		Name:                    req.Name,
+		Description:             req.Description,
+		TargetURL:               req.TargetURL,
+		Username:                req.Username,
+		Password:                req.Password,
+		NamespaceFilter:         req.NamespaceFilter,
+		RepositoryFilter:        req.RepositoryFilter,
+		TagFilter:               req.TagFilter,
+		Trigger:                 req.Trigger,
+		ScheduleIntervalSeconds: req.ScheduleIntervalSeconds,
+		Enabled:                 enabled,
+	}
+}
+
+func toRuleDTO(m *models.ReplicationRule) *mgmt.ReplicationRuleDTO {
+	if m == nil {
+		return nil
+	}
+
+	return &mgmt.ReplicationRuleDTO{
+		Id:                      m.ID,
+		Name:                    m.Name,
+		Description:             m.Description,
+		TargetURL:               m.TargetURL,
+		Username:                m.Username,
+		NamespaceFilter:         m.NamespaceFilter,
+		RepositoryFilter:        m.RepositoryFilter,
+		TagFilter:               m.TagFilter,
+		Trigger:                 m.Trigger,
+		ScheduleIntervalSeconds: m.ScheduleIntervalSeconds,
+		Enabled:                 m.Enabled,
+		LastScheduledAt:         m.LastScheduledAt,
+		CreatedAt:               m.CreatedAt,
+		UpdatedAt:               m.UpdatedAt,
+	}
+}
+
+func makeRuleStatusResponse(s *ruleStatus) *mgmt.ReplicationRuleStatusDTO {
+	res := &mgmt.ReplicationRuleStatusDTO{
+		RuleId:         s.rule.ID,
+		RuleName:       s.rule.Name,
+		Pending:        s.stats.Pending,
+		Running:        s.stats.Running,
+		Succeeded:      s.stats.Succeeded,
+		Failed:         s.stats.Failed,
+		LastSuccessAt:  s.stats.LastSuccessAt,
+		LastFailureAt:  s.stats.LastFailureAt,
+		LastError:      s.stats.LastError,
+		RecentFailures: make([]*mgmt.ReplicationFailureDTO, len(s.failures)),
+	}
+
+	if s.stats.OldestPendingAt != nil {
+		res.LagSeconds = max(int64(time.Since(*s.stats.OldestPendingAt).Seconds()), 0)
+	}
+
+	for i, task := range s.failures {
+		res.RecentFailures[i] = &mgmt.ReplicationFailureDTO{
+			Namespace:  task.Namespace,
+			Repository: task.Repository,
+			Tag:        task.Tag,
+			Attempts:   task.Attempts,
+			LastError:  task.LastError,
+			FailedAt:   task.CompletedAt,
+		}
+	}
+	return res
+}
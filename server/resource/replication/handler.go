@@ -0,0 +1,304 @@
+package replication
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/errors/httperrors"
+	"github.com/ksankeerth/open-image-registry/lib"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/middleware"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+)
+
+type ReplicationHandler struct {
+	svc *replicationService
+}
+
+// NewHandler creates handler of replication rules. runner can be nil if replication is disabled.
+func NewHandler(s store.Store, runner RuleRunner) *ReplicationHandler {
+	svc := &replicationService{
+		store:  s,
+		runner: runner,
+	}
+	return &ReplicationHandler{
+		svc,
+	}
+}
+
+func (h *ReplicationHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	// Replication rules are managed by admins only.
+	r.Use(middleware.RequireRole(constants.RoleAdmin))
+
+	r.Get("/status", h.ListReplicationStatuses)
+	r.Route("/rules", func(r chi.Router) {
+		r.Post("/", h.CreateReplicationRule)
+		r.Get("/", h.ListReplicationRules)
+		r.Route("/{id}", func(r chi.Router) {
+			r.Get("/", h.GetReplicationRule)
+			r.Put("/", h.UpdateReplicationRule)
+			r.Delete("/", h.DeleteReplicationRule)
+			r.Post("/run", h.RunReplicationRule)
+			r.Get("/status", h.GetReplicationStatus)
+		})
+	})
+
+	return r
+}
+
+func (h *ReplicationHandler) CreateReplicationRule(w http.ResponseWriter, r *http.Request) {
+	var req mgmt.CreateReplicationRuleRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to bad request: %s", r.RequestURI)
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
+
+	valid, errMsg := validateCreateRuleRequest(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	res, err := h.svc.createRule(r.Context(), &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if res.statusCode != http.StatusCreated {
+		httperrors.SendError(w, res.statusCode, res.errMsg)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := mgmt.CreateReplicationRuleResponse{
+		RuleId:   res.ruleID,
+		RuleName: req.Name,
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when writing response :%s", r.RequestURI)
+	}
+}
+
+func (h *ReplicationHandler) ListReplicationRules(w http.ResponseWriter, r *http.Request) {
+	cond := lib.ParseListConditions(r, map[string]store.FilterOperator{})
+
+	ok, errMsg := validateListRuleCondition(cond)
+	if !ok {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	rules, total, err := h.svc.listRules(r.Context(), cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	res := mgmt.EntityListResponse[*mgmt.ReplicationRuleDTO]{
+		Total:    total,
+		Page:     int(cond.Page),
+		Limit:    int(cond.Limit),
+		Entities: make([]*mgmt.ReplicationRuleDTO, len(rules)),
+	}
+
+	for index, rule := range rules {
+		res.Entities[index] = toRuleDTO(rule)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+func (h *ReplicationHandler) GetReplicationRule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	rule, err := h.svc.getRule(r.Context(), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if rule == nil {
+		httperrors.NotFound(w, 404, "Not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(toRuleDTO(rule))
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when writing response :%s", r.RequestURI)
+	}
+}
+
+func (h *ReplicationHandler) UpdateReplicationRule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req mgmt.UpdateReplicationRuleRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Parsing request body of update replication rule request failed")
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
+
+	valid, errMsg := validateUpdateRuleRequest(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	if id != req.RuleId {
+		log.Logger().Warn().Msgf("Rule ID in request body does not match the ID in the URL path")
+		httperrors.BadRequest(w, 400, "Rule ID in request body does not match the ID in the URL path")
+		return
+	}
+
+	result, err := h.svc.updateRule(r.Context(), id, &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Request aborted due to errors")
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !result.success {
+		httperrors.SendError(w, result.httpStatusCode, result.httpErrorMsg)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *ReplicationHandler) DeleteReplicationRule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	notFound, err := h.svc.deleteRule(r.Context(), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if notFound {
+		httperrors.NotFound(w, 404, "Replication rule not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RunReplicationRule queues replication of all images selected by the rule. Images are copied in background.
+func (h *ReplicationHandler) RunReplicationRule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	result, err := h.svc.runRule(r.Context(), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !result.success {
+		httperrors.SendError(w, result.httpStatusCode, result.httpErrorMsg)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	err = json.NewEncoder(w).Encode(mgmt.RunReplicationRuleResponse{QueuedImages: result.queued})
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when writing response :%s", r.RequestURI)
+	}
+}
+
+func (h *ReplicationHandler) GetReplicationStatus(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	rule, err := h.svc.getRule(r.Context(), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if rule == nil {
+		httperrors.NotFound(w, 404, "Not found")
+		return
+	}
+
+	status, err := h.svc.getRuleStatus(r.Context(), rule)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(makeRuleStatusResponse(status))
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when writing response :%s", r.RequestURI)
+	}
+}
+
+// ListReplicationStatuses returns status of the rules in the page.
+func (h *ReplicationHandler) ListReplicationStatuses(w http.ResponseWriter, r *http.Request) {
+	cond := lib.ParseListConditions(r, map[string]store.FilterOperator{})
+
+	ok, errMsg := validateListRuleCondition(cond)
+	if !ok {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	rules, total, err := h.svc.listRules(r.Context(), cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	res := mgmt.EntityListResponse[*mgmt.ReplicationRuleStatusDTO]{
+		Total:    total,
+		Page:     int(cond.Page),
+		Limit:    int(cond.Limit),
+		Entities: make([]*mgmt.ReplicationRuleStatusDTO, len(rules)),
+	}
+
+	for index, rule := range rules {
+		status, err := h.svc.getRuleStatus(r.Context(), rule)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+			httperrors.InternalError(w, 500, "Request aborted due to errors")
+			return
+		}
+		res.Entities[index] = makeRuleStatusResponse(status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
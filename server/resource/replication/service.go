@@ -0,0 +1,253 @@
+package replication
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+// recentFailuresLimit limits failed tasks which are shown in the status of a rule.
+const recentFailuresLimit = 10
+
+type replicationService struct {
+	store  store.Store
+	runner RuleRunner
+}
+
+// RuleRunner queues replication of all images of hosted registry which are selected by the rule.
+type RuleRunner interface {
+	EnqueueRule(ctx context.Context, rule *models.ReplicationRule) (queued int, err error)
+}
+
+type ruleStatus struct {
+	rule     *models.ReplicationRule
+	stats    *models.ReplicationRuleStats
+	failures []*models.ReplicationTask
+}
+
+type createRuleResult struct {
+	ruleID     string
+	statusCode int
+	errMsg     string
+}
+
+type patchResult struct {
+	httpStatusCode int
+	httpErrorMsg   string
+	success        bool
+}
+
+type runRuleResult struct {
+	queued int
+	patchResult
+}
+
+func (svc *replicationService) createRule(reqCtx context.Context, req *mgmt.CreateReplicationRuleRequest) (
+	res *createRuleResult, err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to create replication rule due to transaction errors")
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	res = &createRuleResult{}
+
+	sameName, err := svc.store.Replications().GetRuleByName(ctx, req.Name)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to check replication rule from database")
+		return nil, err
+	}
+	if sameName != nil {
+		log.Logger().Warn().Msgf("Creating replication rule(%s) was rejected due to name conflict", req.Name)
+		res.statusCode = http.StatusConflict
+		res.errMsg = "Another replication rule is available with same name"
+		return res, nil
+	}
+
+	ruleID, err := svc.store.Replications().CreateRule(ctx, toRuleModel("", req))
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when creating replication rule: %s", req.Name)
+		return nil, err
+	}
+
+	res.ruleID = ruleID
+	res.statusCode = http.StatusCreated
+	return res, nil
+}
+
+func (svc *replicationService) getRule(reqCtx context.Context, id string) (*models.ReplicationRule, error) {
+	rule, err := svc.store.Replications().GetRule(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in retrieving replication rule: %s", id)
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (svc *replicationService) listRules(reqCtx context.Context, cond *store.ListQueryConditions) (
+	rules []*models.ReplicationRule, total int, err error) {
+	rules, total, err = svc.store.Replications().ListRules(reqCtx, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when listing replication rules")
+		return nil, -1, err
+	}
+	return rules, total, nil
+}
+
+func (svc *replicationService) updateRule(reqCtx context.Context, id string, req *mgmt.UpdateReplicationRuleRequest) (
+	result *patchResult, err error) {
+	result = &patchResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update replication rule due to transaction errors")
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	rule, err := svc.store.Replications().GetRule(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update replication rule due to database errors")
+		return nil, err
+	}
+	if rule == nil {
+		log.Logger().Warn().Msgf("Failed to update non existent replication rule: %s", id)
+		result.httpStatusCode = http.StatusNotFound
+		result.httpErrorMsg = "Replication rule " + id + " is not found"
+		return result, nil
+	}
+
+	sameName, err := svc.store.Replications().GetRuleByName(ctx, req.Name)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to check replication rule from database")
+		return nil, err
+	}
+	if sameName != nil && sameName.ID != id {
+		result.httpStatusCode = http.StatusConflict
+		result.httpErrorMsg = "Another replication rule is available with same name"
+		return result, nil
+	}
+
+	updated := toRuleModel(id, &req.CreateReplicationRuleRequest)
+	// Password is not returned to clients. Therefore, empty password keeps the existing one.
+	if updated.Password == "" && updated.Username == rule.Username {
+		updated.Password = rule.Password
+	}
+
+	err = svc.store.Replications().UpdateRule(ctx, updated)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to update replication rule(%s) due to database errors", id)
+		return nil, err
+	}
+
+	result.success = true
+	return result, nil
+}
+
+func (svc *replicationService) deleteRule(reqCtx context.Context, id string) (notFound bool, err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to delete replication rule due to transaction errors")
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	rule, err := svc.store.Replications().GetRule(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in checking replication rule: %s", id)
+		return false, err
+	}
+
+	if rule == nil {
+		log.Logger().Warn().Msgf("Attempt to delete non-existing replication rule(%s) failed", id)
+		return true, nil
+	}
+
+	err = svc.store.Replications().DeleteRule(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in deleting replication rule: %s", id)
+		return false, err
+	}
+
+	return false, nil
+}
+
+// runRule queues all images selected by the rule, regardless of its trigger.
+func (svc *replicationService) runRule(reqCtx context.Context, id string) (result *runRuleResult, err error) {
+	result = &runRuleResult{}
+
+	if svc.runner == nil {
+		result.httpStatusCode = http.StatusServiceUnavailable
+		result.httpErrorMsg = "Replication is disabled"
+		return result, nil
+	}
+
+	rule, err := svc.getRule(reqCtx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		result.httpStatusCode = http.StatusNotFound
+		result.httpErrorMsg = "Replication rule " + id + " is not found"
+		return result, nil
+	}
+	if !rule.Enabled {
+		result.httpStatusCode = http.StatusBadRequest
+		result.httpErrorMsg = "Replication rule is disabled"
+		return result, nil
+	}
+
+	result.queued, err = svc.runner.EnqueueRule(reqCtx, rule)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to run replication rule: %s", id)
+		return nil, err
+	}
+
+	result.success = true
+	return result, nil
+}
+
+func (svc *replicationService) getRuleStatus(reqCtx context.Context, rule *models.ReplicationRule) (*ruleStatus, error) {
+	stats, err := svc.store.Replications().GetRuleStats(reqCtx, rule.ID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in retrieving status of replication rule: %s", rule.ID)
+		return nil, err
+	}
+
+	failures, err := svc.store.Replications().ListFailedTasks(reqCtx, rule.ID, recentFailuresLimit)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in retrieving failures of replication rule: %s", rule.ID)
+		return nil, err
+	}
+
+	return &ruleStatus{rule: rule, stats: stats, failures: failures}, nil
+}
@@ -0,0 +1,94 @@
+package replication
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	rep "github.com/ksankeerth/open-image-registry/replication"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+// minScheduleIntervalSeconds prevents scheduled rules from scanning hosted registry too often.
+const minScheduleIntervalSeconds = 60
+
+func validateCreateRuleRequest(req *mgmt.CreateReplicationRuleRequest) (valid bool, errMsg string) {
+	if len(req.Name) < 3 || len(req.Name) > 255 || !utils.IsValidRegistry(req.Name) {
+		return false, "Invalid replication rule name"
+	}
+
+	if len(req.Description) > 1000 {
+		return false, "Description should not exceed 1000 characters"
+	}
+
+	if !isValidTargetURL(req.TargetURL) {
+		return false, "Invalid target url"
+	}
+
+	if req.Password != "" && req.Username == "" {
+		return false, "Username is required when password is provided"
+	}
+
+	if !rep.ValidateFilter(req.NamespaceFilter) {
+		return false, "Invalid pattern in namespace_filter"
+	}
+	if !rep.ValidateFilter(req.RepositoryFilter) {
+		return false, "Invalid pattern in repository_filter"
+	}
+	if !rep.ValidateFilter(req.TagFilter) {
+		return false, "Invalid pattern in tag_filter"
+	}
+
+	switch req.Trigger {
+	case constants.ReplicationTriggerPush:
+		if req.ScheduleIntervalSeconds != 0 {
+			return false, "Schedule interval is only allowed for scheduled rules"
+		}
+	case constants.ReplicationTriggerScheduled:
+		if req.ScheduleIntervalSeconds < minScheduleIntervalSeconds {
+			return false, fmt.Sprintf("Schedule interval should be at least %d seconds", minScheduleIntervalSeconds)
+		}
+	default:
+		return false, fmt.Sprintf("Unsupported trigger: %s", req.Trigger)
+	}
+
+	return true, ""
+}
+
+func validateUpdateRuleRequest(req *mgmt.UpdateReplicationRuleRequest) (valid bool, errMsg string) {
+	if req.RuleId == "" {
+		return false, "Invalid replication rule ID in body"
+	}
+
+	return validateCreateRuleRequest(&req.CreateReplicationRuleRequest)
+}
+
+func validateListRuleCondition(cond *store.ListQueryConditions) (bool, string) {
+	if cond.SortField != "" && !slices.Contains(constants.AllowedReplicationRuleSortFields, cond.SortField) {
+		return false, fmt.Sprintf("Not allowed sort field: %s", cond.SortField)
+	}
+
+	for _, f := range cond.Filters {
+		if !slices.Contains(constants.AllowedReplicationRuleFilterFields, f.Field) {
+			return false, fmt.Sprintf("Not allowed filter field: %s", f.Field)
+		}
+	}
+
+	return true, ""
+}
+
+func isValidTargetURL(rawURL string) bool {
+	if len(rawURL) > 2048 {
+		return false
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
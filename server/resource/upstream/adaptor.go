@@ -0,0 +1,398 @@
+package upstream
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/client/upstream/health"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+const maskedSecret = "********"
+
+// secretKeys are keys of CONFIG_JSON which are never sent back to clients.
+var secretKeys = []string{
+	"password", "credential", "secret_access_key", "session_token", "private_key", "client_secret",
+}
+
+func toUpstreamModel(id string, req *mgmt.CreateUpstreamRegistryRequest) *models.UpstreamRegistry {
+	vendor := req.Vendor
+	if vendor == "" {
+		vendor = constants.RegistryVendorDockerHub
+	}
+
+	state := req.Status
+	if state == "" {
+		state = constants.ResourceStateActive
+	}
+
+	return &models.UpstreamRegistry{
+		ID:          id,
+		Name:        req.Name,
+		Description: req.Description,
+		Vendor:      vendor,
+		State:       state,
+		Port:        uint(req.Port),
+		UpstreamURL: req.UpstreamUrl,
+	}
+}
+
+// toAuthConfigModel stores `credentials_json` along with `token_endpoint` as CONFIG_JSON.
+// `password` is stored as `credential` since the upstream clients read it from that key.
+func toAuthConfigModel(registryID string, dto *mgmt.UpstreamAuthConfigDTO) (*models.UpstreamRegistryAuthConfig, error) {
+	cfg := make(map[string]any, len(dto.CredentialJson)+1)
+	for k, v := range dto.CredentialJson {
+		cfg[k] = v
+	}
+
+	if password, ok := cfg["password"]; ok {
+		if _, found := cfg["credential"]; !found {
+			cfg["credential"] = password
+		}
+		delete(cfg, "password")
+	}
+
+	if dto.TokenEndpoint != "" {
+		cfg["token_endpoint"] = dto.TokenEndpoint
+	}
+
+	configJSON, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UpstreamRegistryAuthConfig{
+		RegistryID: registryID,
+		AuthType:   dto.AuthType,
+		ConfigJSON: configJSON,
+	}, nil
+}
+
+func toNetworkConfigModel(registryID string, dto *mgmt.UpstreamAccessConfigDTO) *models.UpstreamRegistryNetworkConfig {
+	return &models.UpstreamRegistryNetworkConfig{
+		RegistryID:             registryID,
+		ConnectionTimeout:      dto.ConnectionTimeoutInSeconds,
+		ReadTimeout:            dto.ReadTimeoutInSeconds,
+		WriteTimeout:           dto.WriteTimeoutInSeconds,
+		MaxConnections:         dto.MaxConnections,
+		MaxIdleConnections:     dto.MaxIdleConnections,
+		MaxRetries:             dto.MaxRetries,
+		RetryDelay:             dto.RetryDelayInSeconds,
+		RetryBackOffMultiplier: dto.RetryBackoffMultiplier,
+		ProxyEnabled:           dto.ProxyEnabled,
+		ProxyURL:               dto.ProxyUrl,
+		ProxyUsername:          dto.ProxyUsername,
+		ProxyPassword:          dto.ProxyPassword,
+		TLSCABundle:            dto.TLSCABundle,
+		TLSClientCert:          dto.TLSClientCert,
+		TLSClientKey:           dto.TLSClientKey,
+		TLSInsecureSkipVerify:  dto.TLSInsecureSkipVerify,
+	}
+}
+
+// toCacheStoreConfigModel converts cache and storage configs. Negative cache TTL defaults to
+// constants.DefaultUpstreamNegativeCacheTTL when it is not set.
+func toCacheStoreConfigModel(registryID string, cache *mgmt.UpstreamCacheConfigDTO,
+	storage *mgmt.UpstreamStorageConfigDTO) *models.UpstreamRegistryCacheStoreConfig {
+	negativeTTL := constants.DefaultUpstreamNegativeCacheTTL
+	if cache.NegativeTtlInSeconds != nil {
+		negativeTTL = *cache.NegativeTtlInSeconds
+	}
+
+	return &models.UpstreamRegistryCacheStoreConfig{
+		RegistryID:         registryID,
+		CacheEnabled:       cache.Enabled,
+		TTLSeconds:         cache.TtlInSeconds,
+		TTLRules:           toTTLRuleModels(cache.TtlRules),
+		StorageLimit:       storage.StorageLimitInMbs,
+		CleanupThreshold:   storage.CleanupThreshold,
+		NegativeTTLSeconds: negativeTTL,
+	}
+}
+
+func toTTLRuleModels(rules []mgmt.UpstreamCacheTTLRuleDTO) []models.CacheTTLRule {
+	ruleModels := make([]models.CacheTTLRule, len(rules))
+	for i, rule := range rules {
+		ruleModels[i].TagPattern = rule.TagPattern
+		ruleModels[i].TTLSeconds = rule.TtlInSeconds
+	}
+	return ruleModels
+}
+
+func toTTLRuleDTOs(rules []models.CacheTTLRule) []mgmt.UpstreamCacheTTLRuleDTO {
+	dtos := make([]mgmt.UpstreamCacheTTLRuleDTO, len(rules))
+	for i, rule := range rules {
+		dtos[i].TagPattern = rule.TagPattern
+		dtos[i].TtlInSeconds = rule.TTLSeconds
+	}
+	return dtos
+}
+
+func toUpstreamSummaryDTO(view *models.UpstreamRegistryView, status *health.Status) *mgmt.UpstreamRegistrySummaryDTO {
+	if view == nil {
+		return nil
+	}
+
+	return &mgmt.UpstreamRegistrySummaryDTO{
+		Id:                view.ID,
+		Name:              view.Name,
+		Description:       view.Description,
+		Vendor:            view.Vendor,
+		Port:              view.Port,
+		Status:            view.State,
+		UpstreamUrl:       view.UpstreamURL,
+		CachedImagesCount: view.CachedImagesCount,
+		HealthState:       toHealthDTO(status).State,
+		CreatedAt:         view.CreatedAt,
+		UpdatedAt:         view.UpdatedAt,
+	}
+}
+
+func makeGetUpstreamResponse(u *upstreamAggregate) *mgmt.UpstreamRegistryResponse {
+	if u == nil || u.registry == nil {
+		return nil
+	}
+
+	res := &mgmt.UpstreamRegistryResponse{
+		Id:          u.registry.ID,
+		Name:        u.registry.Name,
+		Description: u.registry.Description,
+		Vendor:      u.registry.Vendor,
+		Port:        int(u.registry.Port),
+		Status:      u.registry.State,
+		UpstreamUrl: u.registry.UpstreamURL,
+		CreatedAt:   u.registry.CreatedAt,
+		UpdatedAt:   u.registry.UpdatedAt,
+		Health:      toHealthDTO(u.health),
+	}
+
+	if u.auth != nil {
+		res.AuthConfig = mgmt.UpstreamAuthConfigResponse{
+			UpstreamAuthConfigDTO: toAuthConfigDTO(u.auth),
+			CreatedAt:             u.auth.CreatedAt,
+			UpdatedAt:             u.auth.UpdatedAt,
+		}
+	}
+
+	if u.network != nil {
+		res.AccessConfig = mgmt.UpstreamAccessConfigResponse{
+			UpstreamAccessConfigDTO: mgmt.UpstreamAccessConfigDTO{
+				ConnectionTimeoutInSeconds: u.network.ConnectionTimeout,
+				ReadTimeoutInSeconds:       u.network.ReadTimeout,
+				WriteTimeoutInSeconds:      u.network.WriteTimeout,
+				MaxConnections:             u.network.MaxConnections,
+				MaxIdleConnections:         u.network.MaxIdleConnections,
+				MaxRetries:                 u.network.MaxRetries,
+				RetryDelayInSeconds:        u.network.RetryDelay,
+				RetryBackoffMultiplier:     u.network.RetryBackOffMultiplier,
+				ProxyEnabled:               u.network.ProxyEnabled,
+				ProxyUrl:                   u.network.ProxyURL,
+				ProxyUsername:              u.network.ProxyUsername,
+				ProxyPassword:              maskIfSet(u.network.ProxyPassword),
+				TLSCABundle:                u.network.TLSCABundle,
+				TLSClientCert:              u.network.TLSClientCert,
+				TLSClientKey:               maskIfSet(u.network.TLSClientKey),
+				TLSInsecureSkipVerify:      u.network.TLSInsecureSkipVerify,
+			},
+			CreatedAt: u.network.CreatedAt,
+			UpdatedAt: u.network.UpdatedAt,
+		}
+	}
+
+	if u.cacheStore != nil {
+		res.CacheConfig = mgmt.UpstreamCacheConfigResponse{
+			UpstreamCacheConfigDTO: mgmt.UpstreamCacheConfigDTO{
+				Enabled:              u.cacheStore.CacheEnabled,
+				TtlInSeconds:         u.cacheStore.TTLSeconds,
+				TtlRules:             toTTLRuleDTOs(u.cacheStore.TTLRules),
+				NegativeTtlInSeconds: &u.cacheStore.NegativeTTLSeconds,
+			},
+			CreatedAt: u.cacheStore.CreatedAt,
+			UpdatedAt: u.cacheStore.UpdatedAt,
+		}
+		res.StorageConfig = mgmt.UpstreamStorageConfigResponse{
+			UpstreamStorageConfigDTO: mgmt.UpstreamStorageConfigDTO{
+				StorageLimitInMbs: u.cacheStore.StorageLimit,
+				CleanupThreshold:  u.cacheStore.CleanupThreshold,
+			},
+			CreatedAt: u.cacheStore.CreatedAt,
+			UpdatedAt: u.cacheStore.UpdatedAt,
+		}
+	}
+
+	return res
+}
+
+// toAuthConfigDTO converts CONFIG_JSON back to DTO. Secrets are masked.
+func toAuthConfigDTO(m *models.UpstreamRegistryAuthConfig) mgmt.UpstreamAuthConfigDTO {
+	dto := mgmt.UpstreamAuthConfigDTO{
+		AuthType: m.AuthType,
+	}
+
+	var cfg map[string]any
+	if err := json.Unmarshal(m.ConfigJSON, &cfg); err != nil || len(cfg) == 0 {
+		return dto
+	}
+
+	if tokenEndpoint, ok := cfg["token_endpoint"].(string); ok {
+		dto.TokenEndpoint = tokenEndpoint
+		delete(cfg, "token_endpoint")
+	}
+
+	for _, key := range secretKeys {
+		if _, ok := cfg[key]; ok {
+			cfg[key] = maskedSecret
+		}
+	}
+
+	if len(cfg) > 0 {
+		dto.CredentialJson = cfg
+	}
+
+	return dto
+}
+
+func maskIfSet(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return maskedSecret
+}
+
+// toHealthDTO converts health status of an upstream. Upstreams which are not monitored (e.g. disabled)
+// are reported with unknown state.
+func toHealthDTO(status *health.Status) mgmt.UpstreamHealthDTO {
+	if status == nil {
+		return mgmt.UpstreamHealthDTO{State: constants.UpstreamHealthUnknown}
+	}
+
+	dto := mgmt.UpstreamHealthDTO{
+		State:               status.State,
+		CircuitState:        status.CircuitState,
+		LastError:           status.LastError,
+		LatencyInMs:         status.Latency.Milliseconds(),
+		ConsecutiveFailures: status.ConsecutiveFailures,
+	}
+	if !status.LastCheckedAt.IsZero() {
+		dto.LastCheckedAt = timePtr(status.LastCheckedAt)
+	}
+	if !status.LastHealthyAt.IsZero() {
+		dto.LastHealthyAt = timePtr(status.LastHealthyAt)
+	}
+	dto.RateLimit = toRateLimitDTO(status.RateLimit)
+	return dto
+}
+
+func toRateLimitDTO(status health.RateLimitStatus) *mgmt.UpstreamRateLimitDTO {
+	if !status.Known && !status.Limited {
+		return nil
+	}
+
+	dto := &mgmt.UpstreamRateLimitDTO{
+		Limit:           status.Limit,
+		Remaining:       status.Remaining,
+		WindowInSeconds: int64(status.Window.Seconds()),
+		Low:             status.Low,
+		Limited:         status.Limited,
+	}
+	if !status.RetryAt.IsZero() {
+		dto.RetryAt = timePtr(status.RetryAt)
+	}
+	if !status.UpdatedAt.IsZero() {
+		dto.UpdatedAt = timePtr(status.UpdatedAt)
+	}
+	return dto
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func toSyncJobModel(id, registryID string, req *mgmt.CreateSyncJobRequest) *models.UpstreamSyncJob {
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	return &models.UpstreamSyncJob{
+		ID:                      id,
+		RegistryID:              registryID,
+		Name:                    req.Name,
+		Repositories:            strings.Join(req.Repositories, ","),
+		TagPatterns:             strings.Join(req.TagPatterns, ","),
+		Platforms:               strings.Join(req.Platforms, ","),
+		ScheduleIntervalSeconds: req.ScheduleIntervalSeconds,
+		Enabled:                 enabled,
+	}
+}
+
+func toSyncJobDTO(m *models.UpstreamSyncJob) *mgmt.SyncJobDTO {
+	return &mgmt.SyncJobDTO{
+		Id:                      m.ID,
+		UpstreamId:              m.RegistryID,
+		Name:                    m.Name,
+		Repositories:            splitList(m.Repositories),
+		TagPatterns:             splitList(m.TagPatterns),
+		Platforms:               splitList(m.Platforms),
+		ScheduleIntervalSeconds: m.ScheduleIntervalSeconds,
+		Enabled:                 m.Enabled,
+		LastRunAt:               m.LastRunAt,
+		LastRunStatus:           m.LastRunStatus,
+		LastRunMessage:          m.LastRunMessage,
+		LastSyncedImages:        m.LastSyncedImages,
+		CreatedAt:               m.CreatedAt,
+		UpdatedAt:               m.UpdatedAt,
+	}
+}
+
+// splitList splits comma separated values of sync jobs. Empty value results in an empty list.
+func splitList(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
+
+func toCachedRepositoryDTO(m *models.CachedRepositoryView) *mgmt.CachedRepositoryDTO {
+	return &mgmt.CachedRepositoryDTO{
+		Id:             m.ID,
+		Namespace:      m.Namespace,
+		Name:           m.Name,
+		Tags:           m.TagsCount,
+		Digests:        m.DigestsCount,
+		Blobs:          m.BlobsCount,
+		Size:           m.Size,
+		LastAccessedAt: m.LastAccessedAt,
+		CreatedAt:      m.CreatedAt,
+	}
+}
+
+func toCacheEntryDTO(m *models.CacheEntryView) *mgmt.CacheEntryDTO {
+	dto := &mgmt.CacheEntryDTO{
+		Identifier:     m.Identifier,
+		Type:           m.Type,
+		Digest:         m.Digest,
+		MediaType:      m.MediaType,
+		Size:           m.Size,
+		LastAccessedAt: m.LastAccessedAt,
+		CreatedAt:      m.CreatedAt,
+	}
+	if m.Type == constants.CacheEntryTypeTag {
+		expiresAt := m.ExpiresAt
+		dto.ExpiresAt = &expiresAt
+		dto.Expired = expiresAt.Before(time.Now())
+	}
+	return dto
+}
+
+func toCachedBlobDTO(m *models.ImageBlobMetaModel) *mgmt.CachedBlobDTO {
+	return &mgmt.CachedBlobDTO{
+		Digest:         m.Digest,
+		Size:           int64(m.Size),
+		LastAccessedAt: m.LastAccessedAt,
+		CreatedAt:      m.CreatedAt,
+	}
+}
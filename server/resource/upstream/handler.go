@@ -1,19 +1,31 @@
 package upstream
 
 import (
+	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/errors/httperrors"
+	"github.com/ksankeerth/open-image-registry/lib"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/middleware"
+	"github.com/ksankeerth/open-image-registry/resource/access"
 	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
 )
 
 type UpstreamAccessHandler struct {
 	svc *upstreamService
 }
 
-func NewHandler(s store.Store) *UpstreamAccessHandler {
+func NewHandler(s store.Store, listeners ListenerSyncer, syncRunner SyncRunner) *UpstreamAccessHandler {
 	svc := &upstreamService{
-		s,
+		store:      s,
+		listeners:  listeners,
+		syncRunner: syncRunner,
 	}
 	return &UpstreamAccessHandler{
 		svc,
@@ -21,37 +33,850 @@ func NewHandler(s store.Store) *UpstreamAccessHandler {
 }
 
 func (u *UpstreamAccessHandler) Routes() chi.Router {
-	return chi.NewRouter()
+	r := chi.NewRouter()
+
+	// Upstream registries are managed by admins only.
+	r.Use(middleware.RequireRole(constants.RoleAdmin))
+
+	r.Post("/", u.CreateUpstreamRegistry)
+	r.Get("/", u.ListUpstreamRegistries)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Get("/", u.GetUpstreamRegistry)
+		r.Put("/", u.UpdateUpstreamRegistry)
+		r.Delete("/", u.DeleteUpstreamRegistry)
+		r.Patch("/state", u.ChangeUpstreamRegistryState)
+
+		r.Put("/auth-config", u.UpdateUpstreamRegistryAuthConfig)
+		r.Put("/cache-config", u.UpdateUpstreamRegistryCacheConfig)
+		r.Put("/network-config", u.UpdateUpstreamRegistryNetworkConfig)
+		r.Put("/storage-config", u.UpdateUpstreamRegistryStorageConfig)
+
+		r.Get("/users", u.GetUserAccessList)
+		r.Get("/health", u.GetUpstreamRegistryHealth)
+
+		r.Route("/sync-jobs", func(r chi.Router) {
+			r.Post("/", u.CreateSyncJob)
+			r.Get("/", u.ListSyncJobs)
+			r.Route("/{jobId}", func(r chi.Router) {
+				r.Get("/", u.GetSyncJob)
+				r.Put("/", u.UpdateSyncJob)
+				r.Delete("/", u.DeleteSyncJob)
+				r.Post("/run", u.RunSyncJob)
+			})
+		})
+
+		r.Route("/cache", func(r chi.Router) {
+			r.Delete("/", u.PurgeCache)
+			r.Delete("/not-found", u.FlushNotFoundCache)
+			r.Get("/repositories", u.ListCachedRepositories)
+			r.Route("/repositories/{repositoryId}", func(r chi.Router) {
+				r.Delete("/", u.PurgeCachedRepository)
+				r.Get("/entries", u.ListCacheEntries)
+				r.Get("/blobs", u.ListCachedBlobs)
+				r.Delete("/tags/{tag}", u.PurgeCachedTag)
+			})
+		})
+	})
+
+	return r
 }
 
 func (u *UpstreamAccessHandler) CreateUpstreamRegistry(w http.ResponseWriter, r *http.Request) {
+	var req mgmt.CreateUpstreamRegistryRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to bad request: %s", r.RequestURI)
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
+
+	valid, errMsg := validateCreateUpstreamRequest(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	res, err := u.svc.createUpstream(r.Context(), &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if res.statusCode != http.StatusCreated {
+		httperrors.SendError(w, res.statusCode, res.errMsg)
+		return
+	}
+
+	u.svc.syncListener(r.Context(), res.regID)
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := mgmt.CreateUpstreamRegistryResponse{
+		RegId:   res.regID,
+		RegName: req.Name,
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when writing response :%s", r.RequestURI)
+	}
+}
+
+func (u *UpstreamAccessHandler) ListUpstreamRegistries(w http.ResponseWriter, r *http.Request) {
+	cond := lib.ParseListConditions(r, map[string]store.FilterOperator{})
+
+	ok, errMsg := validateListUpstreamCondition(cond)
+	if !ok {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	registries, total, err := u.svc.listUpstreams(r.Context(), cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	res := mgmt.EntityListResponse[*mgmt.UpstreamRegistrySummaryDTO]{
+		Total:    total,
+		Page:     int(cond.Page),
+		Limit:    int(cond.Limit),
+		Entities: make([]*mgmt.UpstreamRegistrySummaryDTO, len(registries)),
+	}
+
+	for index, reg := range registries {
+		res.Entities[index] = toUpstreamSummaryDTO(reg, u.svc.upstreamHealth(reg.ID))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+func (u *UpstreamAccessHandler) GetUpstreamRegistry(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	upstream, err := u.svc.getUpstream(r.Context(), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if upstream == nil {
+		httperrors.NotFound(w, 404, "Not found")
+		return
+	}
+
+	res := makeGetUpstreamResponse(upstream)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when writing response :%s", r.RequestURI)
+	}
+}
+
+func (u *UpstreamAccessHandler) GetUpstreamRegistryHealth(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	status, notFound, err := u.svc.getUpstreamHealth(r.Context(), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if notFound {
+		httperrors.NotFound(w, 404, "Not found")
+		return
+	}
+
+	res := toHealthDTO(status)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when writing response :%s", r.RequestURI)
+	}
 }
 
 func (u *UpstreamAccessHandler) DeleteUpstreamRegistry(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	notFound, err := u.svc.deleteUpstream(r.Context(), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
 
+	if notFound {
+		httperrors.NotFound(w, 404, "Upstream not found")
+		return
+	}
+
+	u.svc.syncListener(r.Context(), id)
+
+	w.WriteHeader(http.StatusOK)
 }
 
 func (u *UpstreamAccessHandler) UpdateUpstreamRegistry(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req mgmt.UpdateUpstreamRegistryRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Parsing request body of update upstream request failed")
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
+
+	err = u.svc.restoreMaskedCredentials(r.Context(), id, req.UpstreamUrl, &req.AuthConfig)
+	if err == nil {
+		err = u.svc.restoreMaskedNetworkSecrets(r.Context(), id, req.UpstreamUrl, &req.AccessConfig)
+	}
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Request aborted due to errors")
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	valid, errMsg := validateUpdateUpstreamRequest(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	if id != req.RegId {
+		log.Logger().Warn().Msgf("Upstream ID in request body does not match the ID in the URL path")
+		httperrors.BadRequest(w, 400, "Upstream ID in request body does not match the ID in the URL path")
+		return
+	}
+
+	result, err := u.svc.updateUpstream(r.Context(), id, &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Request aborted due to errors")
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
 
+	if !result.success {
+		httperrors.SendError(w, result.httpStatusCode, result.httpErrorMsg)
+		return
+	}
+
+	u.svc.syncListener(r.Context(), id)
+
+	w.WriteHeader(http.StatusOK)
 }
 
 func (u *UpstreamAccessHandler) UpdateUpstreamRegistryAuthConfig(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req mgmt.UpstreamAuthConfigDTO
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Parsing request body of update upstream auth config request failed")
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
 
+	upstream, err := u.svc.getUpstream(r.Context(), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Request aborted due to errors")
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+	if upstream == nil {
+		httperrors.NotFound(w, 404, "Upstream not found")
+		return
+	}
+
+	err = u.svc.restoreMaskedCredentials(r.Context(), id, upstream.registry.UpstreamURL, &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Request aborted due to errors")
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	valid, errMsg := validateAuthConfig(&req, upstream.registry.UpstreamURL)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	notFound, err := u.svc.updateAuthConfig(r.Context(), id, &req)
+	u.writeConfigUpdateResponse(w, r, id, notFound, err)
 }
 
 func (u *UpstreamAccessHandler) UpdateUpstreamRegistryCacheConfig(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req mgmt.UpstreamCacheConfigDTO
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Parsing request body of update upstream cache config request failed")
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
+
+	valid, errMsg := validateCacheConfig(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
 
+	notFound, err := u.svc.updateCacheStoreConfig(r.Context(), id, &req, nil)
+	u.writeConfigUpdateResponse(w, r, id, notFound, err)
+}
+
+func (u *UpstreamAccessHandler) UpdateUpstreamRegistryStorageConfig(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req mgmt.UpstreamStorageConfigDTO
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Parsing request body of update upstream storage config request failed")
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
+
+	valid, errMsg := validateStorageConfig(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	notFound, err := u.svc.updateCacheStoreConfig(r.Context(), id, nil, &req)
+	u.writeConfigUpdateResponse(w, r, id, notFound, err)
 }
 
 func (u *UpstreamAccessHandler) UpdateUpstreamRegistryNetworkConfig(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req mgmt.UpstreamAccessConfigDTO
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Parsing request body of update upstream network config request failed")
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
+
+	upstream, err := u.svc.getUpstream(r.Context(), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Request aborted due to errors")
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+	if upstream == nil {
+		httperrors.NotFound(w, 404, "Upstream not found")
+		return
+	}
+
+	err = u.svc.restoreMaskedNetworkSecrets(r.Context(), id, upstream.registry.UpstreamURL, &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Request aborted due to errors")
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
 
+	valid, errMsg := validateAccessConfig(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	notFound, err := u.svc.updateNetworkConfig(r.Context(), id, &req)
+	u.writeConfigUpdateResponse(w, r, id, notFound, err)
+}
+
+func (u *UpstreamAccessHandler) writeConfigUpdateResponse(w http.ResponseWriter, r *http.Request, id string, notFound bool,
+	err error) {
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if notFound {
+		httperrors.NotFound(w, 404, "Upstream not found")
+		return
+	}
+
+	u.svc.syncListener(r.Context(), id)
+
+	w.WriteHeader(http.StatusOK)
 }
 
 func (u *UpstreamAccessHandler) ChangeUpstreamRegistryState(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	state := r.URL.Query().Get("state")
+
+	if state == "" {
+		log.Logger().Warn().Msg("Changing upstream state request was rejected due to empty state")
+		httperrors.BadRequest(w, 400, "Missing query param state in request")
+		return
+	}
+
+	if !isValidState(state) {
+		log.Logger().Warn().Msgf("Changing upstream state request was rejected due to invalid state '%s'", state)
+		httperrors.BadRequest(w, 400, "Invalid upstream state")
+		return
+	}
+
+	result, err := u.svc.changeState(r.Context(), id, state)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Request aborted due to errors")
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !result.success {
+		httperrors.SendError(w, result.httpStatusCode, result.httpErrorMsg)
+		return
+	}
 
+	u.svc.syncListener(r.Context(), id)
+
+	w.WriteHeader(http.StatusOK)
 }
 
 func (u *UpstreamAccessHandler) GetUserAccessList(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	cond := lib.ParseListConditions(r, map[string]store.FilterOperator{})
+
+	ok, errMsg := access.ValidateListUserAccessCondition(cond)
+	if !ok {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	userAccesses, total, err := u.svc.listUsers(r.Context(), id, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	res := mgmt.EntityListResponse[*mgmt.ResourceAccessViewDTO]{
+		Total:    total,
+		Page:     int(cond.Page),
+		Limit:    int(cond.Limit),
+		Entities: make([]*mgmt.ResourceAccessViewDTO, len(userAccesses)),
+	}
 
-}
\ No newline at end of file
+	for index, acc := range userAccesses {
+		res.Entities[index] = access.ToResourceAccessViewDTO(acc)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+func (u *UpstreamAccessHandler) CreateSyncJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req mgmt.CreateSyncJobRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to bad request: %s", r.RequestURI)
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
+
+	valid, errMsg := validateCreateSyncJobRequest(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	res, err := u.svc.createSyncJob(r.Context(), id, &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if res.statusCode != http.StatusCreated {
+		httperrors.SendError(w, res.statusCode, res.errMsg)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := mgmt.CreateSyncJobResponse{
+		JobId:   res.jobID,
+		JobName: req.Name,
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when writing response :%s", r.RequestURI)
+	}
+}
+
+func (u *UpstreamAccessHandler) ListSyncJobs(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	cond := lib.ParseListConditions(r, map[string]store.FilterOperator{})
+
+	ok, errMsg := validateListSyncJobCondition(cond)
+	if !ok {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	jobs, total, notFound, err := u.svc.listSyncJobs(r.Context(), id, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if notFound {
+		httperrors.NotFound(w, 404, "Upstream not found")
+		return
+	}
+
+	res := mgmt.EntityListResponse[*mgmt.SyncJobDTO]{
+		Total:    total,
+		Page:     int(cond.Page),
+		Limit:    int(cond.Limit),
+		Entities: make([]*mgmt.SyncJobDTO, len(jobs)),
+	}
+
+	for index, job := range jobs {
+		res.Entities[index] = toSyncJobDTO(job)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+func (u *UpstreamAccessHandler) GetSyncJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	jobID := chi.URLParam(r, "jobId")
+
+	job, err := u.svc.getSyncJob(r.Context(), id, jobID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if job == nil {
+		httperrors.NotFound(w, 404, "Not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(toSyncJobDTO(job))
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when writing response :%s", r.RequestURI)
+	}
+}
+
+func (u *UpstreamAccessHandler) UpdateSyncJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	jobID := chi.URLParam(r, "jobId")
+
+	var req mgmt.UpdateSyncJobRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Parsing request body of update upstream sync job request failed")
+		httperrors.BadRequest(w, 400, "Bad request")
+		return
+	}
+
+	valid, errMsg := validateUpdateSyncJobRequest(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	if jobID != req.JobId {
+		log.Logger().Warn().Msgf("Sync job ID in request body does not match the ID in the URL path")
+		httperrors.BadRequest(w, 400, "Sync job ID in request body does not match the ID in the URL path")
+		return
+	}
+
+	result, err := u.svc.updateSyncJob(r.Context(), id, jobID, &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Request aborted due to errors")
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !result.success {
+		httperrors.SendError(w, result.httpStatusCode, result.httpErrorMsg)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (u *UpstreamAccessHandler) DeleteSyncJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	jobID := chi.URLParam(r, "jobId")
+
+	notFound, err := u.svc.deleteSyncJob(r.Context(), id, jobID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if notFound {
+		httperrors.NotFound(w, 404, "Sync job not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (u *UpstreamAccessHandler) RunSyncJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	jobID := chi.URLParam(r, "jobId")
+
+	result, err := u.svc.runSyncJob(r.Context(), id, jobID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !result.success {
+		httperrors.SendError(w, result.httpStatusCode, result.httpErrorMsg)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (u *UpstreamAccessHandler) ListCachedRepositories(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	cond := lib.ParseListConditions(r, map[string]store.FilterOperator{})
+
+	ok, errMsg := validateListCachedRepositoryCondition(cond)
+	if !ok {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	repositories, total, notFound, err := u.svc.listCachedRepositories(r.Context(), id, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if notFound {
+		httperrors.NotFound(w, 404, "Upstream not found")
+		return
+	}
+
+	res := mgmt.EntityListResponse[*mgmt.CachedRepositoryDTO]{
+		Total:    total,
+		Page:     int(cond.Page),
+		Limit:    int(cond.Limit),
+		Entities: make([]*mgmt.CachedRepositoryDTO, len(repositories)),
+	}
+
+	for index, repository := range repositories {
+		res.Entities[index] = toCachedRepositoryDTO(repository)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+func (u *UpstreamAccessHandler) ListCacheEntries(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	repositoryID := chi.URLParam(r, "repositoryId")
+
+	cond := lib.ParseListConditions(r, map[string]store.FilterOperator{})
+
+	ok, errMsg := validateListCacheEntryCondition(cond)
+	if !ok {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	entries, total, notFound, err := u.svc.listCacheEntries(r.Context(), id, repositoryID, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if notFound {
+		httperrors.NotFound(w, 404, "Cached repository not found")
+		return
+	}
+
+	res := mgmt.EntityListResponse[*mgmt.CacheEntryDTO]{
+		Total:    total,
+		Page:     int(cond.Page),
+		Limit:    int(cond.Limit),
+		Entities: make([]*mgmt.CacheEntryDTO, len(entries)),
+	}
+
+	for index, entry := range entries {
+		res.Entities[index] = toCacheEntryDTO(entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+func (u *UpstreamAccessHandler) ListCachedBlobs(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	repositoryID := chi.URLParam(r, "repositoryId")
+
+	cond := lib.ParseListConditions(r, map[string]store.FilterOperator{})
+
+	ok, errMsg := validateListCachedBlobCondition(cond)
+	if !ok {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	blobs, total, notFound, err := u.svc.listCachedBlobs(r.Context(), id, repositoryID, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if notFound {
+		httperrors.NotFound(w, 404, "Cached repository not found")
+		return
+	}
+
+	res := mgmt.EntityListResponse[*mgmt.CachedBlobDTO]{
+		Total:    total,
+		Page:     int(cond.Page),
+		Limit:    int(cond.Limit),
+		Entities: make([]*mgmt.CachedBlobDTO, len(blobs)),
+	}
+
+	for index, blob := range blobs {
+		res.Entities[index] = toCachedBlobDTO(blob)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+func (u *UpstreamAccessHandler) PurgeCache(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	result, err := u.svc.purgeCache(r.Context(), id)
+	u.writePurgeResult(w, r, result, err, "Upstream not found")
+}
+
+func (u *UpstreamAccessHandler) FlushNotFoundCache(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	repository := r.URL.Query().Get("repository")
+
+	if repository != "" && !strings.Contains(repository, "/") {
+		repository = constants.DefaultNamespace + "/" + repository
+	}
+
+	flushed, notFound, err := u.svc.flushNotFound(r.Context(), id, repository)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if notFound {
+		httperrors.NotFound(w, 404, "Upstream not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(mgmt.FlushNotFoundCacheResponse{FlushedEntries: flushed})
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+func (u *UpstreamAccessHandler) PurgeCachedRepository(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	repositoryID := chi.URLParam(r, "repositoryId")
+
+	result, err := u.svc.purgeCachedRepository(r.Context(), id, repositoryID)
+	u.writePurgeResult(w, r, result, err, "Cached repository not found")
+}
+
+func (u *UpstreamAccessHandler) PurgeCachedTag(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	repositoryID := chi.URLParam(r, "repositoryId")
+	tag := chi.URLParam(r, "tag")
+
+	result, err := u.svc.purgeCachedTag(r.Context(), id, repositoryID, tag)
+	u.writePurgeResult(w, r, result, err, "Cached tag not found")
+}
+
+func (u *UpstreamAccessHandler) writePurgeResult(w http.ResponseWriter, r *http.Request, result *purgeCacheResult,
+	err error, notFoundMsg string) {
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if result.notFound {
+		httperrors.NotFound(w, 404, notFoundMsg)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(mgmt.PurgeCacheResponse{
+		DeletedBlobs: result.deletedBlobs,
+		FreedBytes:   result.freedBytes,
+	})
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
@@ -1,7 +1,1318 @@
 package upstream
 
-import "github.com/ksankeerth/open-image-registry/store"
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ksankeerth/open-image-registry/client/upstream/health"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/errors/dberrors"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/storage"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
 
 type upstreamService struct {
-	s store.Store
-}
\ No newline at end of file
+	store      store.Store
+	listeners  ListenerSyncer
+	syncRunner SyncRunner
+}
+
+// ListenerSyncer applies changes of an upstream to its proxy listener. It starts, restarts or stops the
+// listener and rebuilds the registry service of the upstream.
+type ListenerSyncer interface {
+	Sync(ctx context.Context, regID string) error
+	// FlushNotFound forgets manifests which the upstream reported as not found and returns their count.
+	FlushNotFound(regID, repository string) int
+}
+
+// SyncRunner runs sync jobs of upstreams in background. started is false if the job is already running.
+type SyncRunner interface {
+	RunJob(ctx context.Context, job *models.UpstreamSyncJob) (started bool, err error)
+}
+
+// upstreamAggregate holds upstream registry along with its configs.
+type upstreamAggregate struct {
+	registry   *models.UpstreamRegistry
+	auth       *models.UpstreamRegistryAuthConfig
+	network    *models.UpstreamRegistryNetworkConfig
+	cacheStore *models.UpstreamRegistryCacheStoreConfig
+	health     *health.Status
+}
+
+type createUpstreamResult struct {
+	regID      string
+	statusCode int
+	errMsg     string
+}
+
+type patchResult struct {
+	httpStatusCode int
+	httpErrorMsg   string
+	success        bool
+}
+
+type createSyncJobResult struct {
+	jobID      string
+	statusCode int
+	errMsg     string
+}
+
+type purgeCacheResult struct {
+	notFound     bool
+	deletedBlobs int
+	freedBytes   int64
+}
+
+// manifestReferences holds the descriptors of image manifests and indexes which refer blobs and child
+// manifests. FSLayers are blobs of docker schema 1 manifests.
+type manifestReferences struct {
+	Config    *manifestDescriptor  `json:"config"`
+	Layers    []manifestDescriptor `json:"layers"`
+	Manifests []manifestDescriptor `json:"manifests"`
+	FSLayers  []struct {
+		BlobSum string `json:"blobSum"`
+	} `json:"fsLayers"`
+}
+
+type manifestDescriptor struct {
+	Digest string `json:"digest"`
+}
+
+// parseManifestReferences parses the manifest content. Manifests which can't be parsed don't refer anything.
+func parseManifestReferences(digest, content string) *manifestReferences {
+	var refs manifestReferences
+	err := json.Unmarshal([]byte(content), &refs)
+	if err != nil {
+		log.Logger().Warn().Err(err).Msgf("Failed to parse cached manifest: %s", digest)
+		return &manifestReferences{}
+	}
+	return &refs
+}
+
+// blobs returns digests of the config and layers.
+func (r *manifestReferences) blobs() []string {
+	var digests []string
+	if r.Config != nil && r.Config.Digest != "" {
+		digests = append(digests, r.Config.Digest)
+	}
+	for _, layer := range r.Layers {
+		digests = append(digests, layer.Digest)
+	}
+	for _, layer := range r.FSLayers {
+		digests = append(digests, layer.BlobSum)
+	}
+	return digests
+}
+
+func (svc *upstreamService) createUpstream(reqCtx context.Context, req *mgmt.CreateUpstreamRegistryRequest) (res *createUpstreamResult,
+	err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to create upstream due to transaction errors")
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	res = &createUpstreamResult{}
+
+	existing, err := svc.store.Upstreams().GetRegistryByName(ctx, req.Name)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to check upstream from database")
+		return nil, err
+	}
+	if existing != nil {
+		res.statusCode = http.StatusConflict
+		res.errMsg = "Another upstream is available with same name"
+		return res, nil
+	}
+
+	res.errMsg, err = svc.checkGroupConflicts(ctx, "", req.Name, req.Port)
+	if err != nil {
+		return nil, err
+	}
+	if res.errMsg != "" {
+		res.statusCode = http.StatusConflict
+		return res, nil
+	}
+
+	regID, err := svc.store.Upstreams().CreateRegistry(ctx, toUpstreamModel("", req))
+	if err != nil {
+		if conflict, msg := isConflict(err); conflict {
+			log.Logger().Warn().Msgf("Creating upstream(%s) was rejected: %s", req.Name, msg)
+			res.statusCode = http.StatusConflict
+			res.errMsg = msg
+			return res, nil
+		}
+		log.Logger().Error().Err(err).Msgf("Error occurred when creating upstream: %s", req.Name)
+		return nil, err
+	}
+
+	err = svc.persistConfigs(ctx, regID, req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when persisting configs of upstream: %s", req.Name)
+		return nil, err
+	}
+
+	res.regID = regID
+	res.statusCode = http.StatusCreated
+	return res, nil
+}
+
+func (svc *upstreamService) persistConfigs(ctx context.Context, regID string, req *mgmt.CreateUpstreamRegistryRequest) error {
+	authConfig, err := toAuthConfigModel(regID, &req.AuthConfig)
+	if err != nil {
+		return err
+	}
+
+	err = svc.store.Upstreams().PersistRegistryAuthConfig(ctx, authConfig)
+	if err != nil {
+		return err
+	}
+
+	err = svc.store.Upstreams().PersistRegistryCacheConfig(ctx, toCacheStoreConfigModel(regID, &req.CacheConfig,
+		&req.StorageConfig))
+	if err != nil {
+		return err
+	}
+
+	return svc.store.Upstreams().PersistRegistryNetworkConfig(ctx, toNetworkConfigModel(regID, &req.AccessConfig))
+}
+
+func (svc *upstreamService) getUpstream(reqCtx context.Context, id string) (u *upstreamAggregate, err error) {
+	reg, err := svc.store.Upstreams().GetRegistry(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in retrieving upstream: %s", id)
+		return nil, err
+	}
+
+	if reg == nil {
+		return nil, nil
+	}
+
+	u = &upstreamAggregate{registry: reg}
+
+	u.auth, err = svc.store.Upstreams().GetRegistryAuthConfig(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in retrieving auth config of upstream: %s", id)
+		return nil, err
+	}
+
+	u.network, err = svc.store.Upstreams().GetRegistryNetworkConfig(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in retrieving network config of upstream: %s", id)
+		return nil, err
+	}
+
+	u.cacheStore, err = svc.store.Upstreams().GetRegistryCacheConfig(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in retrieving cache config of upstream: %s", id)
+		return nil, err
+	}
+
+	u.health = svc.upstreamHealth(id)
+
+	return u, nil
+}
+
+// getUpstreamHealth returns nil status if the upstream is not monitored.
+func (svc *upstreamService) getUpstreamHealth(reqCtx context.Context, id string) (status *health.Status, notFound bool,
+	err error) {
+	reg, err := svc.store.Upstreams().GetRegistry(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in retrieving upstream: %s", id)
+		return nil, false, err
+	}
+
+	if reg == nil {
+		return nil, true, nil
+	}
+
+	return svc.upstreamHealth(id), false, nil
+}
+
+func (svc *upstreamService) upstreamHealth(id string) *health.Status {
+	status, ok := health.GetMonitor().Status(id)
+	if !ok {
+		return nil
+	}
+	return &status
+}
+
+func (svc *upstreamService) listUpstreams(reqCtx context.Context, cond *store.ListQueryConditions) (registries []*models.UpstreamRegistryView,
+	total int, err error) {
+	registries, total, err = svc.store.Upstreams().ListRegistries(reqCtx, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when listing upstreams")
+		return nil, -1, err
+	}
+	return registries, total, nil
+}
+
+func (svc *upstreamService) updateUpstream(reqCtx context.Context, id string, req *mgmt.UpdateUpstreamRegistryRequest) (result *patchResult,
+	err error) {
+	result = &patchResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update upstream due to transaction errors")
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	reg, err := svc.store.Upstreams().GetRegistry(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update upstream due to database errors")
+		return nil, err
+	}
+	if reg == nil {
+		log.Logger().Warn().Msgf("Failed to update non existent upstream: %s", id)
+		result.httpStatusCode = http.StatusNotFound
+		result.httpErrorMsg = "Upstream " + id + " is not found"
+		return result, nil
+	}
+
+	sameName, err := svc.store.Upstreams().GetRegistryByName(ctx, req.Name)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update upstream due to database errors")
+		return nil, err
+	}
+	if sameName != nil && sameName.ID != id {
+		result.httpStatusCode = http.StatusConflict
+		result.httpErrorMsg = "Another upstream is available with same name"
+		return result, nil
+	}
+
+	result.httpErrorMsg, err = svc.checkGroupConflicts(ctx, id, req.Name, req.Port)
+	if err != nil {
+		return nil, err
+	}
+	if result.httpErrorMsg != "" {
+		result.httpStatusCode = http.StatusConflict
+		return result, nil
+	}
+
+	err = svc.store.Upstreams().UpdateRegistry(ctx, toUpstreamModel(id, &req.CreateUpstreamRegistryRequest))
+	if err != nil {
+		if conflict, msg := isConflict(err); conflict {
+			result.httpStatusCode = http.StatusConflict
+			result.httpErrorMsg = msg
+			return result, nil
+		}
+		log.Logger().Error().Err(err).Msgf("Failed to update upstream(%s) due to database errors", id)
+		return nil, err
+	}
+
+	err = svc.updateAuthConfigInTx(ctx, id, &req.AuthConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	err = svc.updateNetworkConfigInTx(ctx, id, &req.AccessConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheConfig := toCacheStoreConfigModel(id, &req.CacheConfig, &req.StorageConfig)
+	if req.CacheConfig.NegativeTtlInSeconds == nil {
+		existing, err := svc.store.Upstreams().GetRegistryCacheConfig(ctx, id)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Failed to retrieve cache config of upstream(%s)", id)
+			return nil, err
+		}
+		if existing != nil {
+			cacheConfig.NegativeTTLSeconds = existing.NegativeTTLSeconds
+		}
+	}
+
+	err = svc.store.Upstreams().UpdateRegistryCacheConfig(ctx, cacheConfig)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to update cache config of upstream(%s)", id)
+		return nil, err
+	}
+
+	result.success = true
+	return result, nil
+}
+
+func (svc *upstreamService) deleteUpstream(reqCtx context.Context, id string) (notFound bool, err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to delete upstream due to transaction errors")
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	reg, err := svc.store.Upstreams().GetRegistry(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in checking upstream: %s", id)
+		return false, err
+	}
+
+	if reg == nil {
+		log.Logger().Warn().Msgf("Attempt to delete non-existing upstream(%s) failed", id)
+		return true, nil
+	}
+
+	_, err = svc.store.Groups().RemoveMember(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in removing upstream(%s) from group registries", id)
+		return false, err
+	}
+
+	err = svc.store.SyncJobs().DeleteJobsOfRegistry(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in deleting sync jobs of upstream: %s", id)
+		return false, err
+	}
+
+	err = svc.store.Upstreams().DeleteRegistry(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in deleting upstream: %s", id)
+		return false, err
+	}
+
+	return false, nil
+}
+
+func (svc *upstreamService) changeState(reqCtx context.Context, id, newState string) (result *patchResult, err error) {
+	result = &patchResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to change state of upstream due to transaction errors")
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	reg, err := svc.store.Upstreams().GetRegistry(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to change state of upstream due to database errors")
+		return nil, err
+	}
+
+	if reg == nil {
+		log.Logger().Warn().Msgf("Failed to change state of non existent upstream: %s", id)
+		result.httpStatusCode = http.StatusNotFound
+		result.httpErrorMsg = "Upstream " + id + " is not found"
+		return result, nil
+	}
+
+	if reg.State == newState {
+		log.Logger().Debug().Msgf("No changes in state. Updating state of upstream(%s) is skipped", id)
+		result.success = true
+		return result, nil
+	}
+
+	err = svc.store.Upstreams().ChangeRegistryState(ctx, id, newState)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to change state of upstream(%s) due to database errors", id)
+		return nil, err
+	}
+
+	result.success = true
+	return result, nil
+}
+
+func (svc *upstreamService) updateAuthConfig(reqCtx context.Context, id string, dto *mgmt.UpstreamAuthConfigDTO) (notFound bool,
+	err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update auth config due to transaction errors")
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	reg, err := svc.store.Upstreams().GetRegistry(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update auth config due to database errors")
+		return false, err
+	}
+	if reg == nil {
+		return true, nil
+	}
+
+	err = svc.updateAuthConfigInTx(ctx, id, dto)
+	return false, err
+}
+
+// updateAuthConfigInTx replaces the auth config of upstream. Since secrets are masked in responses, masked
+// values sent back by clients are replaced by the persisted values.
+func (svc *upstreamService) updateAuthConfigInTx(ctx context.Context, id string, dto *mgmt.UpstreamAuthConfigDTO) error {
+	authConfig, err := toAuthConfigModel(id, dto)
+	if err != nil {
+		return err
+	}
+
+	existing, err := svc.store.Upstreams().GetRegistryAuthConfig(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve auth config of upstream(%s)", id)
+		return err
+	}
+
+	if existing == nil {
+		return svc.store.Upstreams().PersistRegistryAuthConfig(ctx, authConfig)
+	}
+
+	if existing.AuthType == authConfig.AuthType {
+		authConfig.ConfigJSON, err = restoreMaskedSecrets(authConfig.ConfigJSON, existing.ConfigJSON)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = svc.store.Upstreams().UpdateRegistryAuthConfig(ctx, authConfig)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to update auth config of upstream(%s)", id)
+		return err
+	}
+	return nil
+}
+
+func (svc *upstreamService) updateNetworkConfig(reqCtx context.Context, id string, dto *mgmt.UpstreamAccessConfigDTO) (notFound bool,
+	err error) {
+	reg, err := svc.store.Upstreams().GetRegistry(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update network config due to database errors")
+		return false, err
+	}
+	if reg == nil {
+		return true, nil
+	}
+
+	err = svc.updateNetworkConfigInTx(reqCtx, id, dto)
+	return false, err
+}
+
+// updateNetworkConfigInTx replaces the network config of upstream. Masked proxy password and client key are
+// replaced by the persisted values with restoreMaskedNetworkSecrets before validating.
+func (svc *upstreamService) updateNetworkConfigInTx(ctx context.Context, id string, dto *mgmt.UpstreamAccessConfigDTO) error {
+	networkConfig := toNetworkConfigModel(id, dto)
+
+	existing, err := svc.store.Upstreams().GetRegistryNetworkConfig(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve network config of upstream(%s)", id)
+		return err
+	}
+
+	if existing == nil {
+		return svc.store.Upstreams().PersistRegistryNetworkConfig(ctx, networkConfig)
+	}
+
+	err = svc.store.Upstreams().UpdateRegistryNetworkConfig(ctx, networkConfig)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to update network config of upstream(%s)", id)
+		return err
+	}
+	return nil
+}
+
+// updateCacheStoreConfig updates cache or storage config. Both configs are persisted in same table, so the
+// config which is not provided is kept as it is.
+func (svc *upstreamService) updateCacheStoreConfig(reqCtx context.Context, id string, cache *mgmt.UpstreamCacheConfigDTO,
+	storage *mgmt.UpstreamStorageConfigDTO) (notFound bool, err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update cache config due to transaction errors")
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	existing, err := svc.store.Upstreams().GetRegistryCacheConfig(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update cache config due to database errors")
+		return false, err
+	}
+	if existing == nil {
+		return true, nil
+	}
+
+	if cache != nil {
+		existing.CacheEnabled = cache.Enabled
+		existing.TTLSeconds = cache.TtlInSeconds
+		existing.TTLRules = toTTLRuleModels(cache.TtlRules)
+		if cache.NegativeTtlInSeconds != nil {
+			existing.NegativeTTLSeconds = *cache.NegativeTtlInSeconds
+		}
+	}
+	if storage != nil {
+		existing.StorageLimit = storage.StorageLimitInMbs
+		existing.CleanupThreshold = storage.CleanupThreshold
+	}
+
+	err = svc.store.Upstreams().UpdateRegistryCacheConfig(ctx, existing)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to update cache config of upstream(%s)", id)
+		return false, err
+	}
+	return false, nil
+}
+
+func (svc *upstreamService) listUsers(reqCtx context.Context, id string, cond *store.ListQueryConditions) (accesses []*models.ResourceAccessView,
+	total int, err error) {
+	reg, err := svc.store.Upstreams().GetRegistry(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Listing user accesses of upstream(%s) failed", id)
+		return nil, -1, err
+	}
+
+	if reg == nil {
+		log.Logger().Warn().Msgf("Unable to retrieve the user accesses of non-existent upstream(%s)", id)
+		return
+	}
+
+	if cond == nil {
+		cond = &store.ListQueryConditions{
+			Page:      1,
+			Limit:     20,
+			SortOrder: store.SortAsc,
+		}
+	}
+	cond.Filters = append(cond.Filters,
+		store.Filter{
+			Field:    constants.FilterFieldResourceID,
+			Values:   []any{reg.ID},
+			Operator: store.OpEqual,
+		},
+		store.Filter{
+			Field:    constants.FilterFieldResourceType,
+			Values:   []any{constants.ResourceTypeUpstream},
+			Operator: store.OpEqual,
+		})
+
+	accesses, total, err = svc.store.Access().List(reqCtx, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Listing user accesses of upstream(%s) failed", id)
+		return nil, -1, err
+	}
+
+	return accesses, total, nil
+}
+
+// syncListener applies committed changes of the upstream to its proxy listener. Errors are only logged
+// since the changes are already persisted.
+func (svc *upstreamService) syncListener(ctx context.Context, id string) {
+	if svc.listeners == nil {
+		return
+	}
+
+	err := svc.listeners.Sync(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Changes of upstream(%s) were not applied to its listener", id)
+	}
+}
+
+// checkGroupConflicts checks whether the name or port of the upstream is already used by a group registry.
+// It returns the conflict message or an empty string.
+func (svc *upstreamService) checkGroupConflicts(ctx context.Context, id, name string, port int) (string, error) {
+	group, err := svc.store.Groups().GetGroupByName(ctx, name)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to check group registries with name: %s", name)
+		return "", err
+	}
+	if group != nil {
+		return "A group registry is available with same name", nil
+	}
+
+	inUse, err := svc.store.Groups().IsPortInUse(ctx, uint(port), id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to check usage of port: %d", port)
+		return "", err
+	}
+	if inUse {
+		return "Another registry is available with same port", nil
+	}
+	return "", nil
+}
+
+func isConflict(err error) (bool, string) {
+	unique, column := dberrors.IsUniqueConstraint(err)
+	if !unique {
+		return false, ""
+	}
+
+	switch column {
+	case "PORT":
+		return true, "Another upstream is available with same port"
+	case "NAME":
+		return true, "Another upstream is available with same name"
+	default:
+		return true, "Upstream conflicts with another upstream"
+	}
+}
+
+// restoreMaskedCredentials replaces masked secrets of the auth config sent by clients with the persisted values, so
+// the credentials can be validated before updating. Secrets are restored only if the auth type, the upstream URL and
+// the token endpoint are not changed, so persisted secrets are never sent to another host.
+func (svc *upstreamService) restoreMaskedCredentials(ctx context.Context, id, upstreamURL string,
+	dto *mgmt.UpstreamAuthConfigDTO) error {
+	if len(dto.CredentialJson) == 0 {
+		return nil
+	}
+
+	reg, err := svc.store.Upstreams().GetRegistry(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve upstream(%s)", id)
+		return err
+	}
+	if reg == nil || reg.UpstreamURL != upstreamURL {
+		return nil
+	}
+
+	existing, err := svc.store.Upstreams().GetRegistryAuthConfig(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve auth config of upstream(%s)", id)
+		return err
+	}
+	if existing == nil || existing.AuthType != dto.AuthType {
+		return nil
+	}
+
+	var persisted map[string]any
+	if err := json.Unmarshal(existing.ConfigJSON, &persisted); err != nil {
+		// persisted config is not readable, nothing to restore
+		return nil
+	}
+
+	if tokenEndpoint, _ := persisted["token_endpoint"].(string); tokenEndpoint != dto.TokenEndpoint {
+		return nil
+	}
+
+	for _, key := range secretKeys {
+		if v, ok := dto.CredentialJson[key].(string); ok && v == maskedSecret {
+			dto.CredentialJson[key] = persisted[key]
+		}
+	}
+	return nil
+}
+
+// restoreMaskedNetworkSecrets replaces masked proxy password and client key sent by clients with the persisted values.
+// The proxy password is restored only if the proxy URL is not changed, and the client key only if the upstream URL is
+// not changed, so persisted secrets are never sent to another host.
+func (svc *upstreamService) restoreMaskedNetworkSecrets(ctx context.Context, id, upstreamURL string,
+	dto *mgmt.UpstreamAccessConfigDTO) error {
+	if dto.ProxyPassword != maskedSecret && dto.TLSClientKey != maskedSecret {
+		return nil
+	}
+
+	reg, err := svc.store.Upstreams().GetRegistry(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve upstream(%s)", id)
+		return err
+	}
+	if reg == nil {
+		return nil
+	}
+
+	existing, err := svc.store.Upstreams().GetRegistryNetworkConfig(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve network config of upstream(%s)", id)
+		return err
+	}
+	if existing == nil {
+		return nil
+	}
+
+	if dto.ProxyPassword == maskedSecret && existing.ProxyURL == dto.ProxyUrl && existing.ProxyPassword != "" {
+		dto.ProxyPassword = existing.ProxyPassword
+	}
+	if dto.TLSClientKey == maskedSecret && reg.UpstreamURL == upstreamURL && existing.TLSClientKey != "" {
+		dto.TLSClientKey = existing.TLSClientKey
+	}
+	return nil
+}
+
+func restoreMaskedSecrets(configJSON, persistedJSON []byte) ([]byte, error) {
+	var cfg, persisted map[string]any
+	if err := json.Unmarshal(configJSON, &cfg); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(persistedJSON, &persisted); err != nil {
+		// persisted config is not readable, nothing to restore
+		return configJSON, nil
+	}
+
+	for _, key := range secretKeys {
+		if v, ok := cfg[key].(string); ok && v == maskedSecret {
+			cfg[key] = persisted[key]
+		}
+	}
+
+	return json.Marshal(cfg)
+}
+
+func (svc *upstreamService) createSyncJob(reqCtx context.Context, id string, req *mgmt.CreateSyncJobRequest) (
+	res *createSyncJobResult, err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to create upstream sync job due to transaction errors")
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	res = &createSyncJobResult{}
+
+	reg, err := svc.store.Upstreams().GetRegistry(ctx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in checking upstream: %s", id)
+		return nil, err
+	}
+	if reg == nil {
+		res.statusCode = http.StatusNotFound
+		res.errMsg = "Upstream not found"
+		return res, nil
+	}
+
+	sameName, err := svc.store.SyncJobs().GetJobByName(ctx, id, req.Name)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to check upstream sync job from database")
+		return nil, err
+	}
+	if sameName != nil {
+		log.Logger().Warn().Msgf("Creating upstream sync job(%s) was rejected due to name conflict", req.Name)
+		res.statusCode = http.StatusConflict
+		res.errMsg = "Another sync job of the upstream is available with same name"
+		return res, nil
+	}
+
+	jobID, err := svc.store.SyncJobs().CreateJob(ctx, toSyncJobModel("", id, req))
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when creating upstream sync job: %s", req.Name)
+		return nil, err
+	}
+
+	res.jobID = jobID
+	res.statusCode = http.StatusCreated
+	return res, nil
+}
+
+// getSyncJob returns the job only if it belongs to the upstream.
+func (svc *upstreamService) getSyncJob(reqCtx context.Context, id, jobID string) (*models.UpstreamSyncJob, error) {
+	job, err := svc.store.SyncJobs().GetJob(reqCtx, jobID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in retrieving upstream sync job: %s", jobID)
+		return nil, err
+	}
+	if job == nil || job.RegistryID != id {
+		return nil, nil
+	}
+	return job, nil
+}
+
+func (svc *upstreamService) listSyncJobs(reqCtx context.Context, id string, cond *store.ListQueryConditions) (
+	jobs []*models.UpstreamSyncJob, total int, notFound bool, err error) {
+	reg, err := svc.store.Upstreams().GetRegistry(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Listing sync jobs of upstream(%s) failed", id)
+		return nil, -1, false, err
+	}
+	if reg == nil {
+		return nil, -1, true, nil
+	}
+
+	cond.Filters = append(cond.Filters, store.Filter{
+		Field:    constants.FilterFieldRegistryID,
+		Values:   []any{reg.ID},
+		Operator: store.OpEqual,
+	})
+
+	jobs, total, err = svc.store.SyncJobs().ListJobs(reqCtx, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Listing sync jobs of upstream(%s) failed", id)
+		return nil, -1, false, err
+	}
+	return jobs, total, false, nil
+}
+
+func (svc *upstreamService) updateSyncJob(reqCtx context.Context, id, jobID string, req *mgmt.UpdateSyncJobRequest) (
+	result *patchResult, err error) {
+	result = &patchResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to update upstream sync job due to transaction errors")
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	job, err := svc.getSyncJob(ctx, id, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		log.Logger().Warn().Msgf("Failed to update non existent upstream sync job: %s", jobID)
+		result.httpStatusCode = http.StatusNotFound
+		result.httpErrorMsg = "Sync job " + jobID + " is not found"
+		return result, nil
+	}
+
+	sameName, err := svc.store.SyncJobs().GetJobByName(ctx, id, req.Name)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to check upstream sync job from database")
+		return nil, err
+	}
+	if sameName != nil && sameName.ID != jobID {
+		result.httpStatusCode = http.StatusConflict
+		result.httpErrorMsg = "Another sync job of the upstream is available with same name"
+		return result, nil
+	}
+
+	err = svc.store.SyncJobs().UpdateJob(ctx, toSyncJobModel(jobID, id, &req.CreateSyncJobRequest))
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to update upstream sync job(%s) due to database errors", jobID)
+		return nil, err
+	}
+
+	result.success = true
+	return result, nil
+}
+
+func (svc *upstreamService) deleteSyncJob(reqCtx context.Context, id, jobID string) (notFound bool, err error) {
+	job, err := svc.getSyncJob(reqCtx, id, jobID)
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		log.Logger().Warn().Msgf("Attempt to delete non-existing upstream sync job(%s) failed", jobID)
+		return true, nil
+	}
+
+	err = svc.store.SyncJobs().DeleteJob(reqCtx, jobID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in deleting upstream sync job: %s", jobID)
+		return false, err
+	}
+	return false, nil
+}
+
+// runSyncJob starts the job in background, regardless of its schedule.
+func (svc *upstreamService) runSyncJob(reqCtx context.Context, id, jobID string) (result *patchResult, err error) {
+	result = &patchResult{}
+
+	if svc.syncRunner == nil {
+		result.httpStatusCode = http.StatusServiceUnavailable
+		result.httpErrorMsg = "Upstream sync is disabled"
+		return result, nil
+	}
+
+	job, err := svc.getSyncJob(reqCtx, id, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		result.httpStatusCode = http.StatusNotFound
+		result.httpErrorMsg = "Sync job " + jobID + " is not found"
+		return result, nil
+	}
+	if !job.Enabled {
+		result.httpStatusCode = http.StatusBadRequest
+		result.httpErrorMsg = "Sync job is disabled"
+		return result, nil
+	}
+
+	started, err := svc.syncRunner.RunJob(reqCtx, job)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to run upstream sync job: %s", jobID)
+		return nil, err
+	}
+	if !started {
+		result.httpStatusCode = http.StatusConflict
+		result.httpErrorMsg = "Sync job is already running"
+		return result, nil
+	}
+
+	result.success = true
+	return result, nil
+}
+
+// getCachedRepository returns the repository if it belongs to the upstream. nil is returned otherwise.
+func (svc *upstreamService) getCachedRepository(ctx context.Context, id, repositoryID string) (
+	*models.RepositoryModel, error) {
+	repo, err := svc.store.Repositories().Get(ctx, repositoryID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in retrieving cached repository(%s) of upstream(%s)", repositoryID, id)
+		return nil, err
+	}
+	if repo == nil || repo.RegistryID != id {
+		return nil, nil
+	}
+	return repo, nil
+}
+
+func (svc *upstreamService) listCachedRepositories(reqCtx context.Context, id string,
+	cond *store.ListQueryConditions) (repositories []*models.CachedRepositoryView, total int, notFound bool,
+	err error) {
+	reg, err := svc.store.Upstreams().GetRegistry(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Listing cached repositories of upstream(%s) failed", id)
+		return nil, -1, false, err
+	}
+	if reg == nil {
+		return nil, -1, true, nil
+	}
+
+	cond.Filters = append(cond.Filters, store.Filter{
+		Field:    constants.FilterFieldRegistryID,
+		Values:   []any{reg.ID},
+		Operator: store.OpEqual,
+	})
+
+	repositories, total, err = svc.store.Cache().ListRepositories(reqCtx, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Listing cached repositories of upstream(%s) failed", id)
+		return nil, -1, false, err
+	}
+	return repositories, total, false, nil
+}
+
+func (svc *upstreamService) listCacheEntries(reqCtx context.Context, id, repositoryID string,
+	cond *store.ListQueryConditions) (entries []*models.CacheEntryView, total int, notFound bool, err error) {
+	repo, err := svc.getCachedRepository(reqCtx, id, repositoryID)
+	if err != nil {
+		return nil, -1, false, err
+	}
+	if repo == nil {
+		return nil, -1, true, nil
+	}
+
+	cond.Filters = append(cond.Filters, store.Filter{
+		Field:    constants.FilterFieldRepositoryID,
+		Values:   []any{repo.ID},
+		Operator: store.OpEqual,
+	})
+
+	entries, total, err = svc.store.Cache().ListEntries(reqCtx, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Listing cache entries of repository(%s) failed", repositoryID)
+		return nil, -1, false, err
+	}
+	return entries, total, false, nil
+}
+
+func (svc *upstreamService) listCachedBlobs(reqCtx context.Context, id, repositoryID string,
+	cond *store.ListQueryConditions) (blobs []*models.ImageBlobMetaModel, total int, notFound bool, err error) {
+	repo, err := svc.getCachedRepository(reqCtx, id, repositoryID)
+	if err != nil {
+		return nil, -1, false, err
+	}
+	if repo == nil {
+		return nil, -1, true, nil
+	}
+
+	cond.Filters = append(cond.Filters, store.Filter{
+		Field:    constants.FilterFieldRepositoryID,
+		Values:   []any{repo.ID},
+		Operator: store.OpEqual,
+	})
+
+	blobs, total, err = svc.store.Blobs().List(reqCtx, cond)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Listing cached blobs of repository(%s) failed", repositoryID)
+		return nil, -1, false, err
+	}
+	return blobs, total, false, nil
+}
+
+// purgeCache deletes all cached images of the upstream along with its repositories and namespaces.
+func (svc *upstreamService) purgeCache(reqCtx context.Context, id string) (result *purgeCacheResult, err error) {
+	return svc.purge(reqCtx, func(ctx context.Context) (found bool, blobs []*models.ImageBlobMetaModel, err error) {
+		reg, err := svc.store.Upstreams().GetRegistry(ctx, id)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Error in checking upstream: %s", id)
+			return false, nil, err
+		}
+		if reg == nil {
+			return false, nil, nil
+		}
+
+		blobs, err = svc.store.Cache().PurgeRegistry(ctx, reg.ID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Error in purging cache of upstream: %s", id)
+			return false, nil, err
+		}
+		return true, blobs, nil
+	}, id)
+}
+
+// flushNotFound flushes not-found results of the upstream. Results of all repositories are flushed if
+// repository is empty.
+func (svc *upstreamService) flushNotFound(reqCtx context.Context, id, repository string) (flushed int,
+	notFound bool, err error) {
+	reg, err := svc.store.Upstreams().GetRegistry(reqCtx, id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error in checking upstream: %s", id)
+		return 0, false, err
+	}
+	if reg == nil {
+		return 0, true, nil
+	}
+
+	return svc.listeners.FlushNotFound(id, repository), false, nil
+}
+
+// purgeCachedRepository deletes all cached images of the repository along with the repository.
+func (svc *upstreamService) purgeCachedRepository(reqCtx context.Context, id, repositoryID string) (
+	result *purgeCacheResult, err error) {
+	return svc.purge(reqCtx, func(ctx context.Context) (found bool, blobs []*models.ImageBlobMetaModel, err error) {
+		repo, err := svc.getCachedRepository(ctx, id, repositoryID)
+		if err != nil || repo == nil {
+			return false, nil, err
+		}
+
+		blobs, err = svc.store.Cache().PurgeRepository(ctx, repo.ID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Error in purging cached repository: %s", repositoryID)
+			return false, nil, err
+		}
+		return true, blobs, nil
+	}, id)
+}
+
+// purgeCachedTag deletes the tag from cache. Its manifest is deleted unless another tag refers it. Child
+// manifests of a deleted index are deleted too unless other indexes refer them. Blobs which are no longer
+// referred by cached manifests of the repository are deleted.
+func (svc *upstreamService) purgeCachedTag(reqCtx context.Context, id, repositoryID, tag string) (
+	result *purgeCacheResult, err error) {
+	return svc.purge(reqCtx, func(ctx context.Context) (found bool, blobs []*models.ImageBlobMetaModel, err error) {
+		repo, err := svc.getCachedRepository(ctx, id, repositoryID)
+		if err != nil || repo == nil {
+			return false, nil, err
+		}
+
+		entry, err := svc.store.Cache().Get(ctx, repo.ID, tag)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Error in retrieving cached tag(%s) of repository: %s", tag, repositoryID)
+			return false, nil, err
+		}
+		if entry == nil || utils.IsImageDigest(tag) {
+			return false, nil, nil
+		}
+
+		err = svc.store.Cache().Delete(ctx, repo.ID, tag)
+		if err != nil {
+			return false, nil, err
+		}
+
+		tagModel, err := svc.store.Tags().Get(ctx, repo.ID, tag)
+		if err != nil {
+			return false, nil, err
+		}
+		if tagModel != nil {
+			err = svc.store.Tags().UnlinkManifest(ctx, tagModel.Id)
+			if err != nil {
+				return false, nil, err
+			}
+			err = svc.store.Tags().Delete(ctx, repo.ID, tag)
+			if err != nil {
+				return false, nil, err
+			}
+		}
+
+		blobs, err = svc.collectUnreferenced(ctx, repo.ID, entry.Digest)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Error in purging cached tag(%s) of repository: %s", tag, repositoryID)
+			return false, nil, err
+		}
+		return true, blobs, nil
+	}, "")
+}
+
+// collectUnreferenced deletes manifests and blobs of the repository which are no longer referred by cache
+// entries. Digest entries of the dropped manifest and its children are not considered as references, so
+// they are deleted along with the manifests.
+func (svc *upstreamService) collectUnreferenced(ctx context.Context, repositoryID, droppedDigest string) (
+	[]*models.ImageBlobMetaModel, error) {
+	manifests, err := svc.store.Manifests().GetByRepository(ctx, repositoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]*manifestReferences, len(manifests))
+	for _, m := range manifests {
+		refs[m.Digest] = parseManifestReferences(m.Digest, m.Content)
+	}
+
+	dropped := map[string]bool{droppedDigest: true}
+	if ref, ok := refs[droppedDigest]; ok {
+		for _, child := range ref.Manifests {
+			dropped[child.Digest] = true
+		}
+	}
+
+	entries, err := svc.store.Cache().GetEntries(ctx, repositoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	live := map[string]bool{}
+	var mark func(digest string)
+	mark = func(digest string) {
+		if live[digest] {
+			return
+		}
+		live[digest] = true
+		if ref, ok := refs[digest]; ok {
+			for _, child := range ref.Manifests {
+				mark(child.Digest)
+			}
+		}
+	}
+	for _, entry := range entries {
+		if !utils.IsImageDigest(entry.Identifier) || !dropped[entry.Digest] {
+			mark(entry.Digest)
+		}
+	}
+
+	referencedBlobs := map[string]bool{}
+	for _, m := range manifests {
+		if live[m.Digest] {
+			for _, digest := range refs[m.Digest].blobs() {
+				referencedBlobs[digest] = true
+			}
+			continue
+		}
+
+		err = svc.store.Manifests().DeleteByDigest(ctx, repositoryID, m.Digest)
+		if err != nil {
+			return nil, err
+		}
+		err = svc.store.Cache().Delete(ctx, repositoryID, m.Digest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	blobs, err := svc.store.Blobs().GetByRepository(ctx, repositoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]*models.ImageBlobMetaModel, 0)
+	for _, blob := range blobs {
+		if referencedBlobs[blob.Digest] {
+			continue
+		}
+		err = svc.store.Blobs().Delete(ctx, repositoryID, blob.Digest)
+		if err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, blob)
+	}
+	return deleted, nil
+}
+
+// purge runs fn in a transaction and removes files of the deleted blobs once the transaction is committed.
+// Listener of the upstream is synced if syncID is set, since the repositories known to it may be deleted.
+func (svc *upstreamService) purge(reqCtx context.Context, fn func(ctx context.Context) (found bool,
+	blobs []*models.ImageBlobMetaModel, err error), syncID string) (result *purgeCacheResult, err error) {
+	found, blobs, err := svc.purgeInTx(reqCtx, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	result = &purgeCacheResult{notFound: !found}
+	if !found {
+		return result, nil
+	}
+
+	if syncID != "" {
+		svc.syncListener(reqCtx, syncID)
+	}
+
+	for _, blob := range blobs {
+		err = storage.DeleteFile(blob.Location)
+		if err != nil {
+			// Metadata is already deleted, so the file is not served anymore.
+			log.Logger().Warn().Err(err).Msgf("Failed to remove purged blob from storage: %s", blob.Location)
+		}
+		result.deletedBlobs++
+		result.freedBytes += int64(blob.Size)
+	}
+	return result, nil
+}
+
+func (svc *upstreamService) purgeInTx(reqCtx context.Context, fn func(ctx context.Context) (found bool,
+	blobs []*models.ImageBlobMetaModel, err error)) (found bool, blobs []*models.ImageBlobMetaModel, err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to purge upstream cache due to transaction errors")
+		return false, nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	return fn(store.WithTxContext(reqCtx, tx))
+}
@@ -0,0 +1,422 @@
+package upstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	up "github.com/ksankeerth/open-image-registry/client/upstream"
+	"github.com/ksankeerth/open-image-registry/client/upstream/credentials"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+var supportedVendors = []string{
+	constants.RegistryVendorDockerHub, constants.RegistryVendorGCR, constants.RegistryVendorECR,
+	constants.RegistryVendorACR, constants.RegistryVendorGHCR, constants.RegistryVendorGitLab,
+	constants.RegistryVendorQuay, constants.RegistryVendorHarbor, constants.RegistryVendorArtifactory,
+	constants.RegistryVendorNexus, constants.RegistryVendorCustom,
+}
+
+var supportedAuthTypes = []string{
+	constants.UpstreamAuthTypeAnonymous, constants.UpstreamAuthTypeBasic, constants.UpstreamAuthTypeBearer,
+	constants.UpstreamAuthTypeOAuth2, constants.UpstreamAuthTypeAWSECR, constants.UpstreamAuthTypeGCPServiceAccount,
+	constants.UpstreamAuthTypeAzureServicePrincipal, constants.UpstreamAuthTypeHarborRobot,
+	constants.UpstreamAuthTypeArtifactoryToken, constants.UpstreamAuthTypeGitLabToken, constants.UpstreamAuthTypeGitHubToken,
+}
+
+func validateCreateUpstreamRequest(req *mgmt.CreateUpstreamRegistryRequest) (valid bool, errMsg string) {
+	if len(req.Name) < 3 || len(req.Name) > 255 || !utils.IsValidRegistry(req.Name) {
+		return false, "Invalid upstream name"
+	}
+
+	if len(req.Description) > 1000 {
+		return false, "Description should not exceed 1000 characters"
+	}
+
+	if req.Vendor != "" && !slices.Contains(supportedVendors, req.Vendor) {
+		return false, fmt.Sprintf("Unsupported vendor: %s", req.Vendor)
+	}
+
+	if req.Port < 1025 || req.Port > 65535 {
+		return false, "Port should be between 1025 and 65535"
+	}
+
+	if req.Status != "" && !isValidState(req.Status) {
+		return false, "Invalid upstream state"
+	}
+
+	if !isValidUpstreamURL(req.UpstreamUrl) {
+		return false, "Invalid upstream url"
+	}
+
+	if valid, errMsg = validateAuthConfig(&req.AuthConfig, req.UpstreamUrl); !valid {
+		return false, errMsg
+	}
+
+	if valid, errMsg = validateAccessConfig(&req.AccessConfig); !valid {
+		return false, errMsg
+	}
+
+	if valid, errMsg = validateCacheConfig(&req.CacheConfig); !valid {
+		return false, errMsg
+	}
+
+	return validateStorageConfig(&req.StorageConfig)
+}
+
+func validateUpdateUpstreamRequest(req *mgmt.UpdateUpstreamRegistryRequest) (valid bool, errMsg string) {
+	if req.RegId == "" {
+		return false, "Invalid upstream ID in body"
+	}
+
+	return validateCreateUpstreamRequest(&req.CreateUpstreamRegistryRequest)
+}
+
+func validateAuthConfig(cfg *mgmt.UpstreamAuthConfigDTO, upstreamURL string) (valid bool, errMsg string) {
+	if cfg.AuthType == "" {
+		cfg.AuthType = constants.UpstreamAuthTypeAnonymous
+	}
+
+	if !slices.Contains(supportedAuthTypes, cfg.AuthType) {
+		return false, fmt.Sprintf("Unsupported auth type: %s", cfg.AuthType)
+	}
+
+	if cfg.TokenEndpoint != "" && !isValidUpstreamURL(cfg.TokenEndpoint) {
+		return false, "Invalid token endpoint"
+	}
+
+	// masked secrets are replaced by the persisted values before validating, so remaining ones can't be restored
+	for _, key := range secretKeys {
+		if v, ok := cfg.CredentialJson[key].(string); ok && v == maskedSecret {
+			return false, fmt.Sprintf("Masked %s can only be used to keep the persisted value of same auth type, "+
+				"upstream_url and token_endpoint", key)
+		}
+	}
+
+	switch {
+	case cfg.AuthType == constants.UpstreamAuthTypeBasic:
+		username, _ := cfg.CredentialJson["username"].(string)
+		password, _ := cfg.CredentialJson["password"].(string)
+		credential, _ := cfg.CredentialJson["credential"].(string)
+		if username == "" || (password == "" && credential == "") {
+			return false, "Basic auth requires username and password"
+		}
+	case credentials.IsCloudAuthType(cfg.AuthType):
+		configJSON, err := json.Marshal(cfg.CredentialJson)
+		if err != nil {
+			return false, "Invalid credentials"
+		}
+		// Providers validate the config when they are created. No calls are made to cloud provider here.
+		_, err = credentials.NewProvider(cfg.AuthType, configJSON, upstreamURL, nil)
+		if err != nil {
+			return false, err.Error()
+		}
+	}
+
+	return true, ""
+}
+
+func validateAccessConfig(cfg *mgmt.UpstreamAccessConfigDTO) (valid bool, errMsg string) {
+	// masked secrets are replaced by the persisted values before validating, so remaining ones can't be restored
+	if cfg.ProxyPassword == maskedSecret {
+		return false, "Masked proxy_password can only be used to keep the persisted value of same proxy_url"
+	}
+	if cfg.TLSClientKey == maskedSecret {
+		return false, "Masked tls_client_key can only be used to keep the persisted value of same upstream_url"
+	}
+
+	if cfg.ProxyEnabled {
+		if cfg.ProxyUrl == "" {
+			return false, "proxy_url is required when proxy is enabled"
+		}
+		if len(cfg.ProxyUrl) > 2048 {
+			return false, "proxy_url is too long"
+		}
+		_, err := up.ParseProxyURL(cfg.ProxyUrl, cfg.ProxyUsername, cfg.ProxyPassword)
+		if err != nil {
+			return false, err.Error()
+		}
+	}
+
+	if cfg.ProxyUsername == "" && cfg.ProxyPassword != "" {
+		return false, "proxy_username is required when proxy_password is provided"
+	}
+
+	if valid, errMsg = validateTLSConfig(cfg); !valid {
+		return false, errMsg
+	}
+
+	ranges := []struct {
+		name     string
+		value    *int
+		def      int
+		min, max int
+	}{
+		{"connection_timeout", &cfg.ConnectionTimeoutInSeconds, 10, 1, 300},
+		{"read_timeout", &cfg.ReadTimeoutInSeconds, 30, 1, 600},
+		{"write_timeout", &cfg.WriteTimeoutInSeconds, 30, 1, 600},
+		{"max_connections", &cfg.MaxConnections, 100, 1, 1000},
+		{"max_idle_connections", &cfg.MaxIdleConnections, 10, 1, 100},
+		{"retry_delay", &cfg.RetryDelayInSeconds, 5, 1, 60},
+	}
+
+	// zero values are replaced with defaults
+	for _, r := range ranges {
+		if *r.value == 0 {
+			*r.value = r.def
+		}
+		if *r.value < r.min || *r.value > r.max {
+			return false, fmt.Sprintf("%s should be between %d and %d", r.name, r.min, r.max)
+		}
+	}
+
+	if cfg.MaxRetries < 0 || cfg.MaxRetries > 10 {
+		return false, "max_retries should be between 0 and 10"
+	}
+
+	if cfg.RetryBackoffMultiplier == 0 {
+		cfg.RetryBackoffMultiplier = 2.0
+	}
+	if cfg.RetryBackoffMultiplier < 1 || cfg.RetryBackoffMultiplier > 5 {
+		return false, "retry_backoff_multiplier should be between 1 and 5"
+	}
+
+	return true, ""
+}
+
+// validateTLSConfig validates PEM encoded CA bundle and client certificate along with its key.
+func validateTLSConfig(cfg *mgmt.UpstreamAccessConfigDTO) (valid bool, errMsg string) {
+	if (cfg.TLSClientCert == "") != (cfg.TLSClientKey == "") {
+		return false, "tls_client_cert and tls_client_key should be provided together"
+	}
+
+	_, err := up.NewTLSConfig(cfg.TLSCABundle, cfg.TLSClientCert, cfg.TLSClientKey, cfg.TLSInsecureSkipVerify)
+	if err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
+
+// maxTTLRules limits rules which are evaluated for each cached tag.
+const maxTTLRules = 50
+
+func validateCacheConfig(cfg *mgmt.UpstreamCacheConfigDTO) (valid bool, errMsg string) {
+	if cfg.TtlInSeconds == 0 {
+		cfg.TtlInSeconds = 3600
+	}
+
+	if cfg.TtlInSeconds < 60 || cfg.TtlInSeconds > 2592000 {
+		return false, "ttl_seconds should be between 60 and 2592000"
+	}
+
+	// negative_ttl_seconds is optional, so updates without it keep the persisted value
+	if cfg.NegativeTtlInSeconds != nil && (*cfg.NegativeTtlInSeconds < 0 || *cfg.NegativeTtlInSeconds > 3600) {
+		return false, "negative_ttl_seconds should be between 0 and 3600"
+	}
+
+	if len(cfg.TtlRules) > maxTTLRules {
+		return false, fmt.Sprintf("ttl_rules should not exceed %d rules", maxTTLRules)
+	}
+	for _, rule := range cfg.TtlRules {
+		if !isValidTagPattern(rule.TagPattern) {
+			return false, fmt.Sprintf("Invalid tag pattern in ttl_rules: %s", rule.TagPattern)
+		}
+		// 0 revalidates the tag on every pull
+		if rule.TtlInSeconds < 0 || rule.TtlInSeconds > 2592000 {
+			return false, "ttl_seconds of ttl_rules should be between 0 and 2592000"
+		}
+	}
+
+	return true, ""
+}
+
+func validateStorageConfig(cfg *mgmt.UpstreamStorageConfigDTO) (valid bool, errMsg string) {
+	if cfg.StorageLimitInMbs == 0 {
+		cfg.StorageLimitInMbs = 100
+	}
+	if cfg.StorageLimitInMbs < 1 {
+		return false, "storage_limit should be at least 1"
+	}
+
+	if cfg.CleanupThreshold == 0 {
+		cfg.CleanupThreshold = 80
+	}
+	if cfg.CleanupThreshold < 50 || cfg.CleanupThreshold > 95 {
+		return false, "cleanup_threshold should be between 50 and 95"
+	}
+
+	return true, ""
+}
+
+func validateListUpstreamCondition(cond *store.ListQueryConditions) (bool, string) {
+	if cond.SortField != "" && !slices.Contains(constants.AllowedUpstreamSortFields, cond.SortField) {
+		return false, fmt.Sprintf("Not allowed sort field: %s", cond.SortField)
+	}
+
+	for _, f := range cond.Filters {
+		if !slices.Contains(constants.AllowedUpstreamFilterFields, f.Field) {
+			return false, fmt.Sprintf("Not allowed filter field: %s", f.Field)
+		}
+	}
+
+	return true, ""
+}
+
+func isValidState(state string) bool {
+	return state == constants.ResourceStateActive || state == constants.ResourceStateDeprecated ||
+		state == constants.ResourceStateDisabled
+}
+
+func isValidUpstreamURL(rawURL string) bool {
+	if len(rawURL) > 2048 {
+		return false
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// minSyncIntervalSeconds prevents scheduled sync jobs from flooding upstreams.
+const minSyncIntervalSeconds = 60
+
+func validateCreateSyncJobRequest(req *mgmt.CreateSyncJobRequest) (valid bool, errMsg string) {
+	if len(req.Name) < 3 || len(req.Name) > 255 || !utils.IsValidRegistry(req.Name) {
+		return false, "Invalid sync job name"
+	}
+
+	if len(req.Repositories) == 0 {
+		return false, "At least one repository is required"
+	}
+	for _, name := range req.Repositories {
+		if !isValidSyncRepository(name) {
+			return false, fmt.Sprintf("Invalid repository: %s", name)
+		}
+	}
+
+	if len(req.TagPatterns) == 0 {
+		return false, "At least one tag pattern is required"
+	}
+	for _, pattern := range req.TagPatterns {
+		if !isValidTagPattern(pattern) {
+			return false, fmt.Sprintf("Invalid tag pattern: %s", pattern)
+		}
+	}
+
+	for _, platform := range req.Platforms {
+		if !isValidPlatform(platform) {
+			return false, fmt.Sprintf("Invalid platform: %s", platform)
+		}
+	}
+
+	if req.ScheduleIntervalSeconds != 0 && req.ScheduleIntervalSeconds < minSyncIntervalSeconds {
+		return false, fmt.Sprintf("Schedule interval should be 0 or at least %d seconds", minSyncIntervalSeconds)
+	}
+
+	return true, ""
+}
+
+func validateUpdateSyncJobRequest(req *mgmt.UpdateSyncJobRequest) (valid bool, errMsg string) {
+	if req.JobId == "" {
+		return false, "Invalid sync job ID in body"
+	}
+
+	return validateCreateSyncJobRequest(&req.CreateSyncJobRequest)
+}
+
+func validateListSyncJobCondition(cond *store.ListQueryConditions) (bool, string) {
+	if cond.SortField != "" && !slices.Contains(constants.AllowedSyncJobSortFields, cond.SortField) {
+		return false, fmt.Sprintf("Not allowed sort field: %s", cond.SortField)
+	}
+
+	for _, f := range cond.Filters {
+		if !slices.Contains(constants.AllowedSyncJobFilterFields, f.Field) {
+			return false, fmt.Sprintf("Not allowed filter field: %s", f.Field)
+		}
+	}
+
+	return true, ""
+}
+
+// isValidSyncRepository accepts names like `library/alpine` or `alpine`.
+func isValidSyncRepository(name string) bool {
+	namespace, repository, found := strings.Cut(name, "/")
+	if !found {
+		return utils.IsValidRepository(name)
+	}
+	return utils.IsValidNamespace(namespace) && utils.IsValidRepository(repository)
+}
+
+func isValidTagPattern(pattern string) bool {
+	if pattern == "" || len(pattern) > 128 || strings.Contains(pattern, ",") {
+		return false
+	}
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// isValidPlatform accepts platforms like `linux/amd64` or `linux/arm/v7`.
+func isValidPlatform(platform string) bool {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.ContainsAny(part, ", ") {
+			return false
+		}
+	}
+	return true
+}
+
+func validateListCachedRepositoryCondition(cond *store.ListQueryConditions) (bool, string) {
+	if cond.SortField != "" && !slices.Contains(constants.AllowedCachedRepositorySortFields, cond.SortField) {
+		return false, fmt.Sprintf("Not allowed sort field: %s", cond.SortField)
+	}
+
+	if len(cond.Filters) > 0 {
+		return false, fmt.Sprintf("Not allowed filter field: %s", cond.Filters[0].Field)
+	}
+
+	return true, ""
+}
+
+func validateListCacheEntryCondition(cond *store.ListQueryConditions) (bool, string) {
+	if cond.SortField != "" && !slices.Contains(constants.AllowedCacheEntrySortFields, cond.SortField) {
+		return false, fmt.Sprintf("Not allowed sort field: %s", cond.SortField)
+	}
+
+	for _, f := range cond.Filters {
+		if !slices.Contains(constants.AllowedCacheEntryFilterFields, f.Field) {
+			return false, fmt.Sprintf("Not allowed filter field: %s", f.Field)
+		}
+		for _, v := range f.Values {
+			if v != constants.CacheEntryTypeTag && v != constants.CacheEntryTypeDigest {
+				return false, fmt.Sprintf("Invalid type: %v", v)
+			}
+		}
+	}
+
+	return true, ""
+}
+
+func validateListCachedBlobCondition(cond *store.ListQueryConditions) (bool, string) {
+	if cond.SortField != "" && !slices.Contains(constants.AllowedCachedBlobSortFields, cond.SortField) {
+		return false, fmt.Sprintf("Not allowed sort field: %s", cond.SortField)
+	}
+
+	if len(cond.Filters) > 0 {
+		return false, fmt.Sprintf("Not allowed filter field: %s", cond.Filters[0].Field)
+	}
+
+	return true, ""
+}
@@ -11,19 +11,29 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/go-chi/httplog/v2"
 	"github.com/ksankeerth/open-image-registry/auth"
+	"github.com/ksankeerth/open-image-registry/auth/keys"
+	"github.com/ksankeerth/open-image-registry/auth/revocation"
 	"github.com/ksankeerth/open-image-registry/client/email"
+	"github.com/ksankeerth/open-image-registry/client/upstream/health"
 	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/constants"
 	"github.com/ksankeerth/open-image-registry/lib"
 	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/machine"
 	"github.com/ksankeerth/open-image-registry/middleware"
 	"github.com/ksankeerth/open-image-registry/resource"
 	"github.com/ksankeerth/open-image-registry/resource/access"
+	"github.com/ksankeerth/open-image-registry/resource/group"
+	"github.com/ksankeerth/open-image-registry/resource/replication"
+	"github.com/ksankeerth/open-image-registry/resource/upstream"
 	"github.com/ksankeerth/open-image-registry/store"
 	"github.com/ksankeerth/open-image-registry/user"
 )
 
 func AppRouter(webappConfig *config.WebAppConfig, store store.Store, jwtProvider lib.JWTProvider,
-	accessManager *access.Manager, ec *email.EmailClient) *chi.Mux {
+	accessManager *access.Manager, ec *email.EmailClient, upstreamListeners upstream.ListenerSyncer,
+	groupListeners group.ListenerSyncer, replicationRunner replication.RuleRunner,
+	syncRunner upstream.SyncRunner, keyManager *keys.Manager, revocations *revocation.Cache) *chi.Mux {
 	router := chi.NewRouter()
 
 	// Middleware setup
@@ -44,22 +54,38 @@ func AppRouter(webappConfig *config.WebAppConfig, store store.Store, jwtProvider
 
 	router.Use(middleware.EnforceJSON)
 
-	authMiddleware := middleware.NewAuthenticator(store, jwtProvider)
+	authMiddleware := middleware.NewAuthenticator(store, jwtProvider, revocations)
 
-	authHandler := auth.NewAuthAPIHandler(store, jwtProvider, authMiddleware)
+	authHandler := auth.NewAuthAPIHandler(store, jwtProvider, authMiddleware, accessManager, keyManager, revocations)
 	userHandler := user.NewUserAPIHandler(store, ec)
-	registryResourceHandler := resource.NewRegistryResourceHandler(store, accessManager)
+	machineHandler := machine.NewMachineAPIHandler(store, accessManager)
+	registryResourceHandler := resource.NewRegistryResourceHandler(store, accessManager, upstreamListeners,
+		groupListeners, replicationRunner, syncRunner)
+
+	// public keys of auth tokens
+	router.Get(constants.JWKSPath, authHandler.JWKS)
 
 	// API routes
 	router.Route("/api/v1", func(r chi.Router) {
 		r.Mount("/onboarding", userHandler.OnboardingRoutes())
+		r.Mount("/forgot-password", userHandler.ForgotPasswordRoutes())
 		r.Mount("/users", authMiddleware.Authenticate(userHandler.Routes()))
+		r.Mount("/machines", authMiddleware.Authenticate(machineHandler.Routes()))
 		r.Mount("/auth", authHandler.Routes())
 		r.Mount("/resource", authMiddleware.Authenticate(registryResourceHandler.Routes()))
 		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 			//TODO: develop health check endpoint later
 			w.WriteHeader(http.StatusOK)
 		})
+		// metrics expose health and quota of every upstream, so only admins can read them
+		r.With(authMiddleware.Authenticate, middleware.RequireRole(constants.RoleAdmin)).Get("/metrics",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+				err := health.GetMonitor().WriteMetrics(w)
+				if err != nil {
+					log.Logger().Warn().Err(err).Msg("Failed to write metrics")
+				}
+			})
 		// Add other API routes here
 	})
 
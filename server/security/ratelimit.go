@@ -0,0 +1,66 @@
+package security
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter allows a number of events per key within a fixed window. Counts are kept in memory, so each server
+// instance limits on its own.
+type RateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	windows   map[string]*rateLimitWindow
+	nextPurge time.Time
+	now       func() time.Time
+}
+
+type rateLimitWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*rateLimitWindow),
+		now:     time.Now,
+	}
+}
+
+// Allow records an event of the key. If the limit of the key is reached, the event is not recorded and retryAfter
+// is the time until the window of the key resets.
+func (l *RateLimiter) Allow(key string) (allowed bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.purge(now)
+
+	w, ok := l.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		w = &rateLimitWindow{resetAt: now.Add(l.window)}
+		l.windows[key] = w
+	}
+
+	if w.count >= l.limit {
+		return false, w.resetAt.Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// purge removes windows which are reset, at most once per window
+func (l *RateLimiter) purge(now time.Time) {
+	if now.Before(l.nextPurge) {
+		return
+	}
+	for key, w := range l.windows {
+		if !now.Before(w.resetAt) {
+			delete(l.windows, key)
+		}
+	}
+	l.nextPurge = now.Add(l.window)
+}
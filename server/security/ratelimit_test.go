@@ -0,0 +1,50 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewRateLimiter(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for range 2 {
+		allowed, _ := l.Allow("alice")
+		assert.True(t, allowed)
+	}
+
+	allowed, retryAfter := l.Allow("alice")
+	assert.False(t, allowed)
+	assert.Equal(t, time.Minute, retryAfter)
+
+	// keys are limited separately
+	allowed, _ = l.Allow("bob")
+	assert.True(t, allowed)
+
+	now = now.Add(40 * time.Second)
+	allowed, retryAfter = l.Allow("alice")
+	assert.False(t, allowed)
+	assert.Equal(t, 20*time.Second, retryAfter)
+
+	now = now.Add(20 * time.Second)
+	allowed, _ = l.Allow("alice")
+	assert.True(t, allowed)
+}
+
+func TestRateLimiterPurge(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewRateLimiter(1, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Allow("alice")
+	l.Allow("bob")
+	assert.Len(t, l.windows, 2)
+
+	now = now.Add(time.Minute)
+	l.Allow("carol")
+	assert.Len(t, l.windows, 1)
+}
@@ -27,4 +27,16 @@ func GeneratePasswordHash(password, salt string) string {
 func ComparePasswordAndHash(password, salt, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password+salt))
 	return err == nil
-}
\ No newline at end of file
+}
+
+// GenerateAccessToken generates a random personal access token. Tokens start with constants.AccessTokenPrefix.
+func GenerateAccessToken(prefix string) (string, error) {
+	bytes := make([]byte, 32)
+
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	return prefix + base64.RawURLEncoding.EncodeToString(bytes), nil
+}
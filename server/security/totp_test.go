@@ -0,0 +1,81 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// secret of the SHA1 test vectors of RFC 6238 appendix B, "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// last 6 digits of the 8 digit codes of RFC 6238 appendix B
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range vectors {
+		code, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code, unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, valid := ValidateTOTP(rfc6238Secret, "081804", now)
+	assert.True(t, valid)
+	assert.Equal(t, TOTPStep(now), step)
+
+	// codes of adjacent steps are accepted for clock drift
+	_, valid = ValidateTOTP(rfc6238Secret, "081804", now.Add(TOTPPeriod*time.Second))
+	assert.True(t, valid)
+	_, valid = ValidateTOTP(rfc6238Secret, "081804", now.Add(2*TOTPPeriod*time.Second))
+	assert.False(t, valid)
+
+	_, valid = ValidateTOTP(rfc6238Secret, "000000", now)
+	assert.False(t, valid)
+	_, valid = ValidateTOTP(rfc6238Secret, "", now)
+	assert.False(t, valid)
+	_, valid = ValidateTOTP("not base32!", "081804", now)
+	assert.False(t, valid)
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	code, err := TOTPCode(secret, TOTPStep(time.Now()))
+	require.NoError(t, err)
+	_, valid := ValidateTOTP(secret, code, time.Now())
+	assert.True(t, valid)
+
+	uri := TOTPProvisioningURI("Open Image Registry", "alice", secret)
+	assert.Contains(t, uri, "otpauth://totp/Open%20Image%20Registry:alice?")
+	assert.Contains(t, uri, "secret="+secret)
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+
+	assert.Equal(t, "abcdefghij", NormalizeRecoveryCode(" ABCDE-fghij"))
+}
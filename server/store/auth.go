@@ -10,4 +10,45 @@ type AuthStore interface {
 	RecordTokenRevocation(ctx context.Context, m *models.RevokedToken) error
 
 	GetRevokedToken(ctx context.Context, signatureHash string) (m *models.RevokedToken, err error)
-}
\ No newline at end of file
+
+	// ListRevokedTokens returns revoked tokens which expire after the unix time
+	ListRevokedTokens(ctx context.Context, expiresAfter int64) ([]*models.RevokedToken, error)
+
+	// DeleteExpiredRevokedTokens deletes revoked tokens which expired at or before the unix time. Expired tokens are
+	// rejected regardless of revocation.
+	DeleteExpiredRevokedTokens(ctx context.Context, expiredAt int64) (count int64, err error)
+
+	// CreateSession persists the session. It expires after expirySeconds.
+	CreateSession(ctx context.Context, m *models.AuthSession, expirySeconds int) (id string, err error)
+
+	// GetSession returns the session if it is not expired
+	GetSession(ctx context.Context, sessionID string) (*models.AuthSession, error)
+
+	// GetSessionByRefreshToken returns the session if it is not expired
+	GetSessionByRefreshToken(ctx context.Context, refreshTokenHash string) (*models.AuthSession, error)
+
+	// ListSessions returns sessions of the user which are not expired, last accessed first
+	ListSessions(ctx context.Context, userID string) ([]*models.AuthSession, error)
+
+	// RotateRefreshToken replaces the refresh token of the session. rotated is false if the current refresh token
+	// is not oldHash anymore.
+	RotateRefreshToken(ctx context.Context, sessionID, oldHash, newHash string) (rotated bool, err error)
+
+	RecordSessionAccess(ctx context.Context, sessionID string) error
+
+	DeleteSession(ctx context.Context, sessionID string) error
+
+	// DeleteUserSessions deletes all sessions of the user including expired ones
+	DeleteUserSessions(ctx context.Context, userID string) (count int64, err error)
+
+	DeleteExpiredSessions(ctx context.Context, userID string) error
+
+	// CreateAuthorizationRequest persists an OIDC login until the callback. It expires after expirySeconds.
+	CreateAuthorizationRequest(ctx context.Context, m *models.AuthorizationRequest, expirySeconds int) error
+
+	// TakeAuthorizationRequest deletes the authorization request of the state and returns it if it is not expired.
+	// Therefore, an authorization request can be used only once.
+	TakeAuthorizationRequest(ctx context.Context, state string) (*models.AuthorizationRequest, error)
+
+	DeleteExpiredAuthorizationRequests(ctx context.Context) error
+}
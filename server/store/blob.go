@@ -11,6 +11,16 @@ type BlobMetaStore interface {
 
 	Create(ctx context.Context, registryId, namespaceId, repositoryId, digest, location string, size int64) (err error)
 
+	Delete(ctx context.Context, repositoryId, digest string) error
+
+	// Touch records that the cached blob was served.
+	Touch(ctx context.Context, repositoryId, digest string) error
+
+	GetByRepository(ctx context.Context, repositoryId string) ([]*models.ImageBlobMetaModel, error)
+
+	List(ctx context.Context, conditions *ListQueryConditions) (blobs []*models.ImageBlobMetaModel, total int,
+		err error)
+
 	CreateUploadSession(ctx context.Context, sessionID, namespaceID, repositoryID string) error
 
 	UpdateUploadSession(ctx context.Context, sessionID string, bytesReceived int) error
@@ -15,4 +15,26 @@ type RegistryCacheStore interface {
 	Delete(ctx context.Context, repositoryId, identifier string) (err error)
 
 	Refresh(ctx context.Context, repositoryId, identifier string, expiresAt time.Time) error
+
+	// Touch records that the cached manifest was served.
+	Touch(ctx context.Context, repositoryId, identifier string) error
+
+	// GetEntries returns all cache entries of the repository.
+	GetEntries(ctx context.Context, repositoryId string) ([]*models.RegistryCacheModel, error)
+
+	// ListRepositories lists repositories of upstreams along with the usage of their caches.
+	ListRepositories(ctx context.Context, conditions *ListQueryConditions) (repositories []*models.CachedRepositoryView,
+		total int, err error)
+
+	// ListEntries lists cached tags and digests along with the manifests they refer.
+	ListEntries(ctx context.Context, conditions *ListQueryConditions) (entries []*models.CacheEntryView,
+		total int, err error)
+
+	// PurgeRepository deletes cached images of the repository along with the repository. Metadata of deleted
+	// blobs is returned, so their files can be removed from storage.
+	PurgeRepository(ctx context.Context, repositoryId string) (blobs []*models.ImageBlobMetaModel, err error)
+
+	// PurgeRegistry deletes cached images, repositories and namespaces of the upstream. Metadata of deleted
+	// blobs is returned, so their files can be removed from storage.
+	PurgeRegistry(ctx context.Context, registryId string) (blobs []*models.ImageBlobMetaModel, err error)
 }
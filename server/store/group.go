@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+type GroupRegistryStore interface {
+	CreateGroup(ctx context.Context, m *models.GroupRegistry) (id string, err error)
+
+	UpdateGroup(ctx context.Context, m *models.GroupRegistry) error
+
+	GetGroup(ctx context.Context, groupID string) (*models.GroupRegistry, error)
+
+	GetGroupByName(ctx context.Context, name string) (*models.GroupRegistry, error)
+
+	ListGroups(ctx context.Context, conditions *ListQueryConditions) (groups []*models.GroupRegistry, total int, err error)
+
+	// GetActiveGroups returns groups which are not disabled.
+	GetActiveGroups(ctx context.Context) ([]*models.GroupRegistry, error)
+
+	DeleteGroup(ctx context.Context, groupID string) error
+
+	ChangeGroupState(ctx context.Context, groupID, state string) error
+
+	// SetMembers replaces members of the group. Members are resolved in the given order.
+	SetMembers(ctx context.Context, groupID string, memberIDs []string) error
+
+	GetMembers(ctx context.Context, groupID string) ([]*models.GroupRegistryMemberView, error)
+
+	// RemoveMember removes the registry from all groups and returns IDs of the affected groups.
+	RemoveMember(ctx context.Context, memberID string) (groupIDs []string, err error)
+
+	// IsPortInUse checks ports of upstreams and groups except the given registry.
+	IsPortInUse(ctx context.Context, port uint, excludeRegistryID string) (bool, error)
+}
@@ -15,4 +15,7 @@ type ManifestStore interface {
 	GetByDigest(ctx context.Context, withContent bool, repositoryId, digest string) (*models.ImageManifestModel, error)
 
 	DeleteByDigest(ctx context.Context, repositoryId, digest string) error
+
+	// GetByRepository returns all manifests of the repository along with their content.
+	GetByRepository(ctx context.Context, repositoryId string) ([]*models.ImageManifestModel, error)
 }
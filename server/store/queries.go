@@ -24,4 +24,7 @@ type ImageQueries interface {
 	GetManifestByTag(ctx context.Context, withContent bool, repositoryId, tag string) (*models.ImageManifestModel, error)
 
 	GetRepositoryByNames(ctx context.Context, namespace, repository string) (*models.RepositoryModel, error)
+
+	// ListImageTags returns all tags of the registry.
+	ListImageTags(ctx context.Context, registryID string) ([]*models.ImageTagView, error)
 }
@@ -13,6 +13,9 @@ type AccountRecoveryStore interface {
 
 	GetByUserID(ctx context.Context, userId string) (*models.AccountRecovery, error)
 
+	// GetUnexpired returns the recovery if it is of the reason and was created within expirySeconds
+	GetUnexpired(ctx context.Context, uuid string, reason, expirySeconds int) (*models.AccountRecovery, error)
+
 	Delete(ctx context.Context, uuid string) (err error)
 
 	DeleteByUserID(ctx context.Context, userId string) (err error)
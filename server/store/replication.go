@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+type ReplicationStore interface {
+	CreateRule(ctx context.Context, m *models.ReplicationRule) (id string, err error)
+
+	UpdateRule(ctx context.Context, m *models.ReplicationRule) error
+
+	GetRule(ctx context.Context, ruleID string) (*models.ReplicationRule, error)
+
+	GetRuleByName(ctx context.Context, name string) (*models.ReplicationRule, error)
+
+	ListRules(ctx context.Context, conditions *ListQueryConditions) (rules []*models.ReplicationRule, total int, err error)
+
+	// GetEnabledRules returns enabled rules of the given trigger.
+	GetEnabledRules(ctx context.Context, trigger string) ([]*models.ReplicationRule, error)
+
+	// GetDueScheduledRules returns enabled scheduled rules whose interval has elapsed since the last run.
+	GetDueScheduledRules(ctx context.Context) ([]*models.ReplicationRule, error)
+
+	MarkRuleScheduled(ctx context.Context, ruleID string) error
+
+	// DeleteRule deletes the rule along with its tasks.
+	DeleteRule(ctx context.Context, ruleID string) error
+
+	// EnqueueTask queues replication of the tag. It is skipped if the tag is already waiting in the queue.
+	EnqueueTask(ctx context.Context, ruleID, namespace, repository, tag string) (queued bool, err error)
+
+	// GetDueTasks returns pending tasks whose next attempt is due, oldest first.
+	GetDueTasks(ctx context.Context, limit int) ([]*models.ReplicationTask, error)
+
+	MarkTaskRunning(ctx context.Context, taskID string) error
+
+	MarkTaskSucceeded(ctx context.Context, taskID string) error
+
+	// MarkTaskForRetry records the failure and moves the task back to the queue after the given delay.
+	MarkTaskForRetry(ctx context.Context, taskID string, attempts int, lastError string, delay time.Duration) error
+
+	MarkTaskFailed(ctx context.Context, taskID string, attempts int, lastError string) error
+
+	// ResetRunningTasks moves tasks which were interrupted(e.g. by a restart) back to the queue.
+	ResetRunningTasks(ctx context.Context) error
+
+	GetRuleStats(ctx context.Context, ruleID string) (*models.ReplicationRuleStats, error)
+
+	// ListFailedTasks returns the most recent failed tasks of the rule.
+	ListFailedTasks(ctx context.Context, ruleID string, limit int) ([]*models.ReplicationTask, error)
+}
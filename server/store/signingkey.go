@@ -0,0 +1,24 @@
+package store
+
+import (
+	"context"
+
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+type SigningKeyStore interface {
+	Create(ctx context.Context, m *models.SigningKey) error
+
+	Get(ctx context.Context, kid string) (*models.SigningKey, error)
+
+	// List returns keys from the newest
+	List(ctx context.Context) ([]*models.SigningKey, error)
+
+	// RetireActive retires the active key, so another key can be activated
+	RetireActive(ctx context.Context) error
+
+	Activate(ctx context.Context, kid string) error
+
+	// DeleteRetired deletes keys retired more than graceSeconds ago
+	DeleteRetired(ctx context.Context, graceSeconds int) error
+}
@@ -59,7 +59,7 @@ func (a *resourceAccessStore) List(ctx context.Context, conditions *store.ListQu
 
 	qb := store.NewQueryBuilder(store.DBTypeSqlite).
 		WithSearchFields("user").
-		WithAllowedFilterFields("access_level", "resource_type", "resource_id").
+		WithAllowedFilterFields("access_level", "resource_type", "resource_id", "user_id").
 		WithFieldTransformation("resource_id", "ra.RESOURCE_ID").
 		WithFieldTransformation("resource_type", "ra.RESOURCE_TYPE").
 		WithAllowedSortFields("user", "granted_user", "granted_at").
@@ -4,11 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 
 	"github.com/ksankeerth/open-image-registry/errors/dberrors"
 	"github.com/ksankeerth/open-image-registry/log"
 	"github.com/ksankeerth/open-image-registry/store"
 	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
 )
 
 type authStore struct {
@@ -56,3 +58,216 @@ func (a *authStore) GetRevokedToken(ctx context.Context, signatureHash string) (
 
 	return m, nil
 }
+
+func (a *authStore) ListRevokedTokens(ctx context.Context, expiresAfter int64) ([]*models.RevokedToken, error) {
+	q := a.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, RevokedTokenListQuery, expiresAfter)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to list revoked tokens")
+		return nil, dberrors.ClassifyError(err, RevokedTokenListQuery)
+	}
+	defer rows.Close()
+
+	var tokens []*models.RevokedToken
+	for rows.Next() {
+		var m models.RevokedToken
+		err = rows.Scan(&m.SignatureHash, &m.ExpiresAt, &m.IssuedAt, &m.UserID)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to scan revoked token")
+			return nil, dberrors.ClassifyError(err, RevokedTokenListQuery)
+		}
+		tokens = append(tokens, &m)
+	}
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("failed to list revoked tokens")
+		return nil, dberrors.ClassifyError(err, RevokedTokenListQuery)
+	}
+
+	return tokens, nil
+}
+
+func (a *authStore) DeleteExpiredRevokedTokens(ctx context.Context, expiredAt int64) (count int64, err error) {
+	return a.exec(ctx, RevokedTokenDeleteExpiredQuery, expiredAt)
+}
+
+func (a *authStore) CreateSession(ctx context.Context, m *models.AuthSession, expirySeconds int) (id string,
+	err error) {
+	q := a.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, SessionCreateQuery, m.UserID, m.ScopeHash, m.RefreshTokenHash, expirySeconds,
+		m.UserAgent, m.IPAddress, m.GrantType).Scan(&id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to create auth session")
+		return "", dberrors.ClassifyError(err, SessionCreateQuery)
+	}
+
+	return id, nil
+}
+
+func (a *authStore) GetSession(ctx context.Context, sessionID string) (*models.AuthSession, error) {
+	return a.getSession(ctx, SessionGetQuery, sessionID)
+}
+
+func (a *authStore) GetSessionByRefreshToken(ctx context.Context, refreshTokenHash string) (*models.AuthSession,
+	error) {
+	return a.getSession(ctx, SessionGetByRefreshTokenQuery, refreshTokenHash)
+}
+
+func (a *authStore) getSession(ctx context.Context, query string, args ...any) (*models.AuthSession, error) {
+	q := a.getQuerier(ctx)
+
+	m, err := scanAuthSession(q.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve auth session")
+		return nil, dberrors.ClassifyError(err, query)
+	}
+
+	return m, nil
+}
+
+func (a *authStore) ListSessions(ctx context.Context, userID string) ([]*models.AuthSession, error) {
+	q := a.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, SessionListQuery, userID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to retrieve auth sessions")
+		return nil, dberrors.ClassifyError(err, SessionListQuery)
+	}
+	defer rows.Close()
+
+	sessions := make([]*models.AuthSession, 0)
+	for rows.Next() {
+		m, err := scanAuthSession(rows)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to scan auth session")
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		sessions = append(sessions, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("Error during row iteration")
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return sessions, nil
+}
+
+func scanAuthSession(row rowScanner) (*models.AuthSession, error) {
+	var m models.AuthSession
+	var issuedAt, expiresAt, lastAccessedAt sql.NullString
+
+	err := row.Scan(&m.ID, &m.UserID, &m.ScopeHash, &m.RefreshTokenHash, &issuedAt, &expiresAt, &lastAccessedAt,
+		&m.UserAgent, &m.IPAddress, &m.GrantType)
+	if err != nil {
+		return nil, err
+	}
+
+	issued, err := utils.ParseSqliteTimestamp(issuedAt.String)
+	if err != nil {
+		return nil, err
+	}
+	if issued != nil {
+		m.IssuedAt = *issued
+	}
+
+	expires, err := utils.ParseSqliteTimestamp(expiresAt.String)
+	if err != nil {
+		return nil, err
+	}
+	if expires != nil {
+		m.ExpiresAt = *expires
+	}
+
+	lastAccessed, err := utils.ParseSqliteTimestamp(lastAccessedAt.String)
+	if err != nil {
+		return nil, err
+	}
+	if lastAccessed != nil {
+		m.LastAccessedAt = *lastAccessed
+	}
+
+	return &m, nil
+}
+
+func (a *authStore) RotateRefreshToken(ctx context.Context, sessionID, oldHash, newHash string) (rotated bool,
+	err error) {
+	q := a.getQuerier(ctx)
+
+	res, err := q.ExecContext(ctx, SessionRotateRefreshTokenQuery, newHash, sessionID, oldHash)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to rotate refresh token")
+		return false, dberrors.ClassifyError(err, SessionRotateRefreshTokenQuery)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to read affected rows of refresh token rotation")
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (a *authStore) RecordSessionAccess(ctx context.Context, sessionID string) error {
+	_, err := a.exec(ctx, SessionRecordAccessQuery, sessionID)
+	return err
+}
+
+func (a *authStore) DeleteSession(ctx context.Context, sessionID string) error {
+	_, err := a.exec(ctx, SessionDeleteQuery, sessionID)
+	return err
+}
+
+func (a *authStore) DeleteUserSessions(ctx context.Context, userID string) (count int64, err error) {
+	return a.exec(ctx, SessionDeleteByUserQuery, userID)
+}
+
+func (a *authStore) DeleteExpiredSessions(ctx context.Context, userID string) error {
+	_, err := a.exec(ctx, SessionDeleteExpiredByUserQuery, userID)
+	return err
+}
+
+func (a *authStore) CreateAuthorizationRequest(ctx context.Context, m *models.AuthorizationRequest,
+	expirySeconds int) error {
+	_, err := a.exec(ctx, AuthorizationRequestCreateQuery, m.State, m.Nonce, m.CodeVerifier, expirySeconds)
+	return err
+}
+
+func (a *authStore) TakeAuthorizationRequest(ctx context.Context, state string) (*models.AuthorizationRequest,
+	error) {
+	q := a.getQuerier(ctx)
+
+	var m models.AuthorizationRequest
+	err := q.QueryRowContext(ctx, AuthorizationRequestTakeQuery, state).Scan(&m.State, &m.Nonce, &m.CodeVerifier)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve authorization request")
+		return nil, dberrors.ClassifyError(err, AuthorizationRequestTakeQuery)
+	}
+
+	return &m, nil
+}
+
+func (a *authStore) DeleteExpiredAuthorizationRequests(ctx context.Context) error {
+	_, err := a.exec(ctx, AuthorizationRequestDeleteExpiredQuery)
+	return err
+}
+
+func (a *authStore) exec(ctx context.Context, query string, args ...any) (affected int64, err error) {
+	q := a.getQuerier(ctx)
+
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to update auth sessions")
+		return 0, dberrors.ClassifyError(err, query)
+	}
+
+	return res.RowsAffected()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 
 	"github.com/ksankeerth/open-image-registry/errors/dberrors"
 	"github.com/ksankeerth/open-image-registry/log"
@@ -28,7 +29,9 @@ func (b *blobMetaStore) getQuerier(ctx context.Context) store.Querier {
 }
 
 func (b *blobMetaStore) Get(ctx context.Context, digest, repositoryId string) (*models.ImageBlobMetaModel, error) {
-	row := b.db.QueryRowContext(ctx, BlobMetaGetQuery, repositoryId, digest)
+	q := b.getQuerier(ctx)
+
+	row := q.QueryRowContext(ctx, BlobMetaGetQuery, repositoryId, digest)
 
 	var m models.ImageBlobMetaModel
 	err := row.Scan(
@@ -38,6 +41,7 @@ func (b *blobMetaStore) Get(ctx context.Context, digest, repositoryId string) (*
 		&m.Digest,
 		&m.Size,
 		&m.Location,
+		&m.LastAccessedAt,
 		&m.CreatedAt,
 		&m.UpdatedAt,
 	)
@@ -53,7 +57,9 @@ func (b *blobMetaStore) Get(ctx context.Context, digest, repositoryId string) (*
 }
 
 func (b *blobMetaStore) Create(ctx context.Context, registryId, namespaceId, repositoryId, digest, location string, size int64) (err error) {
-	_, err = b.db.ExecContext(ctx, BlobMetaCreateQuery,
+	q := b.getQuerier(ctx)
+
+	_, err = q.ExecContext(ctx, BlobMetaCreateQuery,
 		namespaceId, registryId, repositoryId, digest, size, location,
 	)
 	if err != nil {
@@ -63,6 +69,120 @@ func (b *blobMetaStore) Create(ctx context.Context, registryId, namespaceId, rep
 	return nil
 }
 
+func (b *blobMetaStore) Delete(ctx context.Context, repositoryId, digest string) error {
+	q := b.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, BlobMetaDeleteQuery, repositoryId, digest)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to delete image blob meta")
+		return dberrors.ClassifyError(err, BlobMetaDeleteQuery)
+	}
+	return nil
+}
+
+func (b *blobMetaStore) Touch(ctx context.Context, repositoryId, digest string) error {
+	q := b.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, BlobMetaTouchQuery, repositoryId, digest)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to update last access of image blob meta")
+		return dberrors.ClassifyError(err, BlobMetaTouchQuery)
+	}
+	return nil
+}
+
+func (b *blobMetaStore) GetByRepository(ctx context.Context, repositoryId string) ([]*models.ImageBlobMetaModel, error) {
+	blobs, err := queryBlobMetas(ctx, b.getQuerier(ctx), BlobMetaGetByRepositoryQuery, repositoryId)
+	if err != nil {
+		return nil, dberrors.ClassifyError(err, BlobMetaGetByRepositoryQuery)
+	}
+	return blobs, nil
+}
+
+func (b *blobMetaStore) List(ctx context.Context, conditions *store.ListQueryConditions) (
+	blobs []*models.ImageBlobMetaModel, total int, err error) {
+	qb := store.NewQueryBuilder(store.DBTypeSqlite).
+		WithSearchFields("bm.BLOB_DIGEST").
+		WithFieldTransformation("repository_id", "bm.REPOSITORY_ID").
+		WithFieldTransformation("digest", "bm.BLOB_DIGEST").
+		WithFieldTransformation("size", "bm.SIZE").
+		WithFieldTransformation("last_accessed_at", "bm.LAST_ACCESSED_AT").
+		WithFieldTransformation("created_at", "bm.CREATED_AT").
+		WithAllowedFilterFields("repository_id").
+		WithAllowedSortFields("DIGEST", "SIZE", "LAST_ACCESSED_AT", "CREATED_AT")
+
+	listQuery, countQuery, args, err := qb.Build(BlobMetaListBaseQuery, BlobMetaCountBaseQuery, conditions)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to build image blob list query")
+		return nil, 0, fmt.Errorf("build query: %w", err)
+	}
+
+	// Execute count query first (without limit/offset)
+	countArgs := args[:len(args)-2]
+
+	q := b.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to count total image blobs")
+		return nil, 0, fmt.Errorf("count image blobs: %w", err)
+	}
+
+	blobs, err = queryBlobMetas(ctx, q, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return blobs, total, nil
+}
+
+func queryBlobMetas(ctx context.Context, q store.Querier, query string, args ...any) ([]*models.ImageBlobMetaModel,
+	error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to retrieve image blob metas")
+		return nil, fmt.Errorf("query image blob metas: %w", err)
+	}
+	defer rows.Close()
+
+	blobs := make([]*models.ImageBlobMetaModel, 0)
+	for rows.Next() {
+		var m models.ImageBlobMetaModel
+		var lastAccessedAt, createdAt, updatedAt sql.NullString
+
+		err = rows.Scan(&m.NamespaceID, &m.RegistryID, &m.RepositoryID, &m.Digest, &m.Size, &m.Location,
+			&lastAccessedAt, &createdAt, &updatedAt)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to scan image blob meta")
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+
+		m.LastAccessedAt, err = utils.ParseSqliteTimestamp(lastAccessedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		created, err := utils.ParseSqliteTimestamp(createdAt.String)
+		if err != nil {
+			return nil, err
+		}
+		if created != nil {
+			m.CreatedAt = *created
+		}
+		m.UpdatedAt, err = utils.ParseSqliteTimestamp(updatedAt.String)
+		if err != nil {
+			return nil, err
+		}
+
+		blobs = append(blobs, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("Error during row iteration")
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return blobs, nil
+}
+
 func (b *blobMetaStore) CreateUploadSession(ctx context.Context, sessionID, namespaceID, repositoryID string) error {
 	q := b.getQuerier(ctx)
 
@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/ksankeerth/open-image-registry/errors/dberrors"
@@ -115,3 +116,267 @@ func (c *registryCacheStore) Refresh(ctx context.Context, repositoryId, identifi
 
 	return nil
 }
+
+func (c *registryCacheStore) Touch(ctx context.Context, repositoryId, identifier string) error {
+	q := c.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, CacheTouchEntryQuery, repositoryId, identifier)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to update last access of registry cache entry")
+		return dberrors.ClassifyError(err, CacheTouchEntryQuery)
+	}
+
+	return nil
+}
+
+func (c *registryCacheStore) GetEntries(ctx context.Context, repositoryId string) ([]*models.RegistryCacheModel,
+	error) {
+	q := c.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, CacheGetEntriesQuery, repositoryId)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to get registry cache entries")
+		return nil, dberrors.ClassifyError(err, CacheGetEntriesQuery)
+	}
+	defer rows.Close()
+
+	entries := make([]*models.RegistryCacheModel, 0)
+	for rows.Next() {
+		var m models.RegistryCacheModel
+		var expiresAt, createdAt, updatedAt sql.NullString
+
+		err = rows.Scan(&m.NamespaceID, &m.RegistryID, &m.RepositoryID, &m.Identifier, &m.Digest, &expiresAt,
+			&createdAt, &updatedAt)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to scan registry cache entry")
+			return nil, dberrors.ClassifyError(err, CacheGetEntriesQuery)
+		}
+
+		err = parseCacheTimestamps(&m.ExpiresAt, &m.CreatedAt, expiresAt.String, createdAt.String)
+		if err != nil {
+			return nil, dberrors.ClassifyError(err, CacheGetEntriesQuery)
+		}
+		m.UpdatedAt, err = utils.ParseSqliteTimestamp(updatedAt.String)
+		if err != nil {
+			return nil, dberrors.ClassifyError(err, CacheGetEntriesQuery)
+		}
+
+		entries = append(entries, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("failed to iterate registry cache entries")
+		return nil, dberrors.ClassifyError(err, CacheGetEntriesQuery)
+	}
+
+	return entries, nil
+}
+
+func (c *registryCacheStore) ListRepositories(ctx context.Context, conditions *store.ListQueryConditions) (
+	repositories []*models.CachedRepositoryView, total int, err error) {
+	qb := store.NewQueryBuilder(store.DBTypeSqlite).
+		WithSearchFields("NAMESPACE", "NAME").
+		WithFieldTransformation("registry_id", "REGISTRY_ID").
+		WithFieldTransformation("namespace", "NAMESPACE").
+		WithFieldTransformation("name", "NAME").
+		WithFieldTransformation("size", "SIZE").
+		WithFieldTransformation("last_accessed_at", "LAST_ACCESSED_AT").
+		WithFieldTransformation("created_at", "CREATED_AT").
+		WithAllowedFilterFields("registry_id").
+		WithAllowedSortFields("NAMESPACE", "NAME", "SIZE", "LAST_ACCESSED_AT", "CREATED_AT")
+
+	listQuery, countQuery, args, err := qb.Build(CacheRepositoryListBaseQuery, CacheRepositoryCountBaseQuery,
+		conditions)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to build cached repository list query")
+		return nil, 0, fmt.Errorf("build query: %w", err)
+	}
+
+	// Execute count query first (without limit/offset)
+	countArgs := args[:len(args)-2]
+
+	q := c.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to count total cached repositories")
+		return nil, 0, fmt.Errorf("count cached repositories: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to retrieve cached repositories")
+		return nil, 0, fmt.Errorf("query cached repositories: %w", err)
+	}
+	defer rows.Close()
+
+	repositories = make([]*models.CachedRepositoryView, 0)
+	for rows.Next() {
+		var m models.CachedRepositoryView
+		var lastAccessedAt, createdAt sql.NullString
+
+		err = rows.Scan(&m.RegistryID, &m.ID, &m.Namespace, &m.Name, &m.TagsCount, &m.DigestsCount, &m.BlobsCount,
+			&m.Size, &lastAccessedAt, &createdAt)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to scan cached repository")
+			return nil, 0, fmt.Errorf("scan row: %w", err)
+		}
+
+		m.LastAccessedAt, err = utils.ParseSqliteTimestamp(lastAccessedAt.String)
+		if err != nil {
+			return nil, 0, err
+		}
+		created, err := utils.ParseSqliteTimestamp(createdAt.String)
+		if err != nil {
+			return nil, 0, err
+		}
+		if created != nil {
+			m.CreatedAt = *created
+		}
+
+		repositories = append(repositories, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("Error during row iteration")
+		return nil, 0, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return repositories, total, nil
+}
+
+func (c *registryCacheStore) ListEntries(ctx context.Context, conditions *store.ListQueryConditions) (
+	entries []*models.CacheEntryView, total int, err error) {
+	qb := store.NewQueryBuilder(store.DBTypeSqlite).
+		WithSearchFields("IDENTIFIER", "DIGEST").
+		WithFieldTransformation("repository_id", "REPOSITORY_ID").
+		WithFieldTransformation("type", "TYPE").
+		WithFieldTransformation("identifier", "IDENTIFIER").
+		WithFieldTransformation("size", "SIZE").
+		WithFieldTransformation("expires_at", "EXPIRES_AT").
+		WithFieldTransformation("last_accessed_at", "LAST_ACCESSED_AT").
+		WithFieldTransformation("created_at", "CREATED_AT").
+		WithAllowedFilterFields("repository_id", "type").
+		WithAllowedSortFields("IDENTIFIER", "SIZE", "EXPIRES_AT", "LAST_ACCESSED_AT", "CREATED_AT")
+
+	listQuery, countQuery, args, err := qb.Build(CacheEntryListBaseQuery, CacheEntryCountBaseQuery, conditions)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to build cache entry list query")
+		return nil, 0, fmt.Errorf("build query: %w", err)
+	}
+
+	// Execute count query first (without limit/offset)
+	countArgs := args[:len(args)-2]
+
+	q := c.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to count total cache entries")
+		return nil, 0, fmt.Errorf("count cache entries: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to retrieve cache entries")
+		return nil, 0, fmt.Errorf("query cache entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries = make([]*models.CacheEntryView, 0)
+	for rows.Next() {
+		var m models.CacheEntryView
+		var expiresAt, lastAccessedAt, createdAt sql.NullString
+
+		err = rows.Scan(&m.RegistryID, &m.RepositoryID, &m.Identifier, &m.Type, &m.Digest, &m.MediaType, &m.Size,
+			&expiresAt, &lastAccessedAt, &createdAt)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to scan cache entry")
+			return nil, 0, fmt.Errorf("scan row: %w", err)
+		}
+
+		err = parseCacheTimestamps(&m.ExpiresAt, &m.CreatedAt, expiresAt.String, createdAt.String)
+		if err != nil {
+			return nil, 0, err
+		}
+		m.LastAccessedAt, err = utils.ParseSqliteTimestamp(lastAccessedAt.String)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		entries = append(entries, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("Error during row iteration")
+		return nil, 0, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+func (c *registryCacheStore) PurgeRepository(ctx context.Context, repositoryId string) (
+	blobs []*models.ImageBlobMetaModel, err error) {
+	return c.purge(ctx, repositoryId, CachePurgeRepositoryBlobsQuery,
+		CachePurgeRepositoryEntriesQuery,
+		CachePurgeRepositoryMappingQuery,
+		CachePurgeRepositoryTagsQuery,
+		CachePurgeRepositoryManifestQuery,
+		CachePurgeRepositoryBlobMetaQuery,
+		CachePurgeRepositoryQuery,
+	)
+}
+
+func (c *registryCacheStore) PurgeRegistry(ctx context.Context, registryId string) (
+	blobs []*models.ImageBlobMetaModel, err error) {
+	return c.purge(ctx, registryId, CachePurgeRegistryBlobsQuery,
+		CachePurgeRegistryEntriesQuery,
+		CachePurgeRegistryMappingQuery,
+		CachePurgeRegistryTagsQuery,
+		CachePurgeRegistryManifestQuery,
+		CachePurgeRegistryBlobMetaQuery,
+		CachePurgeRegistryRepositoryQuery,
+		CachePurgeRegistryNamespaceQuery,
+	)
+}
+
+// purge selects blobs with blobsQuery and then runs the delete queries in order. All the queries take id
+// as the only argument.
+func (c *registryCacheStore) purge(ctx context.Context, id, blobsQuery string, deleteQueries ...string) (
+	blobs []*models.ImageBlobMetaModel, err error) {
+	q := c.getQuerier(ctx)
+
+	blobs, err = queryBlobMetas(ctx, q, blobsQuery, id)
+	if err != nil {
+		return nil, dberrors.ClassifyError(err, blobsQuery)
+	}
+
+	for _, query := range deleteQueries {
+		_, err = q.ExecContext(ctx, query, id)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to purge registry cache")
+			return nil, dberrors.ClassifyError(err, query)
+		}
+	}
+
+	return blobs, nil
+}
+
+func parseCacheTimestamps(expiresAt, createdAt *time.Time, expiresAtValue, createdAtValue string) error {
+	exp, err := utils.ParseSqliteTimestamp(expiresAtValue)
+	if err != nil {
+		return err
+	}
+	if exp != nil {
+		*expiresAt = *exp
+	}
+
+	created, err := utils.ParseSqliteTimestamp(createdAtValue)
+	if err != nil {
+		return err
+	}
+	if created != nil {
+		*createdAt = *created
+	}
+	return nil
+}
@@ -87,7 +87,7 @@ const (
 
 const (
 	TagCreateQuery         = `INSERT INTO IMAGE_TAG(REGISTRY_ID, NAMESPACE_ID, REPOSITORY_ID, IS_STABLE, TAG) VALUES(?, ?, ?, ?, ?) RETURNING ID`
-	TagGetQuery            = `SELECT REGISTRY_ID, NAMESPACE_ID, ID, TAG, IS_STABLE, CREATED_AT, UPDATED_AT FROM IMAGE_TAG WHERE REPOSITORY_ID = ? AND TAG = ?`
+	TagGetQuery            = `SELECT ID, REGISTRY_ID, NAMESPACE_ID, REPOSITORY_ID, TAG, IS_STABLE, CREATED_AT, UPDATED_AT FROM IMAGE_TAG WHERE REPOSITORY_ID = ? AND TAG = ?`
 	TagDeleteQuery         = `DELETE FROM IMAGE_TAG WHERE REPOSITORY_ID = ? AND TAG = ?`
 	TagLinkManifestQuery   = `INSERT INTO IMAGE_MANIFEST_TAG_MAPPING(MANIFEST_ID, TAG_ID) VALUES(?, ?)`
 	TagUpdateManifestQuery = `UPDATE IMAGE_MANIFEST_TAG_MAPPING SET MANIFEST_ID = ? WHERE TAG_ID = ?`
@@ -99,23 +99,42 @@ const (
 	AccountRecoveryCreateQuery         = `INSERT INTO USER_ACCOUNT_RECOVERY(RECOVERY_UUID, USER_ID, REASON_TYPE) VALUES(?, ?, ?)`
 	AccountRecoveryGetQuery            = `SELECT RECOVERY_UUID, USER_ID, REASON_TYPE, CREATED_AT FROM USER_ACCOUNT_RECOVERY WHERE RECOVERY_UUID = ?`
 	AccountRecoveryGetByUserIDQuery    = `SELECT RECOVERY_UUID, USER_ID, REASON_TYPE, CREATED_AT FROM USER_ACCOUNT_RECOVERY WHERE USER_ID = ?`
+	AccountRecoveryGetUnexpiredQuery   = `SELECT RECOVERY_UUID, USER_ID, REASON_TYPE, CREATED_AT FROM USER_ACCOUNT_RECOVERY WHERE RECOVERY_UUID = ? AND REASON_TYPE = ? AND CREATED_AT > DATETIME(CURRENT_TIMESTAMP, '-' || ? || ' seconds')`
 	AccountRecoveeryDeleteQuery        = `DELETE FROM USER_ACCOUNT_RECOVERY WHERE RECOVERY_UUID = ?`
 	AccountRecoveryDeleteByUserIDQuery = `DELETE FROM USER_ACCOUNT_RECOVERY WHERE USER_ID = ?`
 	AccountRecoveryUpdateReasonQuery   = `UPDATE USER_ACCOUNT_RECOVERY SET REASON_TYPE = ? WHERE RECOVERY_UUID =?`
 )
 
 const (
-	ManifestCreateQuery                       = `INSERT INTO IMAGE_MANIFEST(DIGEST, SIZE, MEDIA_TYPE, MANIFEST_CONTENT, NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, UNIQUE_DIGEST) VALUES(?, ?, ?, ?, ?, ?, ?, ?)`
-	ManifestGetbyUniqueDigestWithContentQuery = `SELECT ID, DIGEST, SIZE, MEDIA_TYPE, MANIFEST_CONTENT, NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, UNIQUE_DIGEST, CREATED_AT, UPDATED_AT FROM IMAGE_MANIFEST WHERE UNIQUE_DIGEST = ?`
-	ManifestGetbyUniqueDigestQuery            = `SELECT ID, DIGEST, SIZE, MEDIA_TYPE, NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, UNIQUE_DIGEST, CREATED_AT, UPDATED_AT FROM IMAGE_MANIFEST WHERE UNIQUE_DIGEST = ?`
-	ManifestGetbyDigestWithContentQuery       = `SELECT ID, DIGEST, SIZE, MEDIA_TYPE, MANIFEST_CONTENT, NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, UNIQUE_DIGEST, CREATED_AT, UPDATED_AT FROM IMAGE_MANIFEST WHERE DIGEST = ?`
-	ManifestGetbyDigestQuery                  = `SELECT ID, DIGEST, SIZE, MEDIA_TYPE, NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, UNIQUE_DIGEST, CREATED_AT, UPDATED_AT FROM IMAGE_MANIFEST WHERE UNIQUE_DIGEST = ?`
+	ManifestCreateQuery                       = `INSERT INTO IMAGE_MANIFEST(DIGEST, SIZE, MEDIA_TYPE, MANIFEST_CONTENT, NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, UNIQUE_DIGEST) VALUES(?, ?, ?, ?, ?, ?, ?, ?) RETURNING ID`
+	ManifestGetbyUniqueDigestWithContentQuery = `SELECT ID, DIGEST, SIZE, MEDIA_TYPE, MANIFEST_CONTENT, NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, UNIQUE_DIGEST, CREATED_AT, UPDATED_AT FROM IMAGE_MANIFEST WHERE UNIQUE_DIGEST = ? AND REPOSITORY_ID = ?`
+	ManifestGetbyUniqueDigestQuery            = `SELECT ID, DIGEST, SIZE, MEDIA_TYPE, NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, UNIQUE_DIGEST, CREATED_AT, UPDATED_AT FROM IMAGE_MANIFEST WHERE UNIQUE_DIGEST = ? AND REPOSITORY_ID = ?`
+	ManifestGetbyDigestWithContentQuery       = `SELECT ID, DIGEST, SIZE, MEDIA_TYPE, MANIFEST_CONTENT, NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, UNIQUE_DIGEST, CREATED_AT, UPDATED_AT FROM IMAGE_MANIFEST WHERE DIGEST = ? AND REPOSITORY_ID = ?`
+	ManifestGetbyDigestQuery                  = `SELECT ID, DIGEST, SIZE, MEDIA_TYPE, NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, UNIQUE_DIGEST, CREATED_AT, UPDATED_AT FROM IMAGE_MANIFEST WHERE DIGEST = ? AND REPOSITORY_ID = ?`
 	ManifestDeleteByDigest                    = `DELETE FROM IMAGE_MANIFEST WHERE REPOSITORY_ID = ? AND DIGEST = ?`
+	ManifestGetByRepositoryQuery              = `SELECT ID, DIGEST, SIZE, MEDIA_TYPE, MANIFEST_CONTENT, NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, UNIQUE_DIGEST, CREATED_AT, UPDATED_AT FROM IMAGE_MANIFEST WHERE REPOSITORY_ID = ?`
 )
 
 const (
-	BlobMetaCreateQuery = `INSERT INTO IMAGE_BLOB_META(NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, BLOB_DIGEST, SIZE, LOCATION) VALUES(?, ?, ?, ?, ?, ?)`
-	BlobMetaGetQuery    = `SELECT NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, BLOB_DIGEST, SIZE, LOCATION, CREATED_AT, UPDATED_AT FROM IMAGE_BLOB_META WHERE REPOSITORY_ID = ? AND BLOB_DIGEST = ?`
+	BlobMetaCreateQuery          = `INSERT INTO IMAGE_BLOB_META(NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, BLOB_DIGEST, SIZE, LOCATION) VALUES(?, ?, ?, ?, ?, ?)`
+	BlobMetaGetQuery             = `SELECT NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, BLOB_DIGEST, SIZE, LOCATION, LAST_ACCESSED_AT, CREATED_AT, UPDATED_AT FROM IMAGE_BLOB_META WHERE REPOSITORY_ID = ? AND BLOB_DIGEST = ?`
+	BlobMetaGetByRepositoryQuery = `SELECT NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, BLOB_DIGEST, SIZE, LOCATION, LAST_ACCESSED_AT, CREATED_AT, UPDATED_AT FROM IMAGE_BLOB_META WHERE REPOSITORY_ID = ?`
+	BlobMetaDeleteQuery          = `DELETE FROM IMAGE_BLOB_META WHERE REPOSITORY_ID = ? AND BLOB_DIGEST = ?`
+	BlobMetaTouchQuery           = `UPDATE IMAGE_BLOB_META SET LAST_ACCESSED_AT = CURRENT_TIMESTAMP WHERE REPOSITORY_ID = ? AND BLOB_DIGEST = ?`
+	// IMPORTANT: Base list query avoids WHERE keywords. Refer NamespaceListBaseQuery
+	BlobMetaListBaseQuery = `
+	SELECT
+		bm.NAMESPACE_ID AS NAMESPACE_ID,
+		bm.REGISTRY_ID AS REGISTRY_ID,
+		bm.REPOSITORY_ID AS REPOSITORY_ID,
+		bm.BLOB_DIGEST AS BLOB_DIGEST,
+		bm.SIZE AS SIZE,
+		bm.LOCATION AS LOCATION,
+		bm.LAST_ACCESSED_AT AS LAST_ACCESSED_AT,
+		bm.CREATED_AT AS CREATED_AT,
+		bm.UPDATED_AT AS UPDATED_AT
+	FROM IMAGE_BLOB_META bm`
+	BlobMetaCountBaseQuery = `SELECT count(*) FROM IMAGE_BLOB_META bm `
 
 	BlobSessionCreateQuery = `INSERT INTO IMAGE_BLOB_UPLOAD_SESSION(SESSION_ID, NAMESPACE_ID, REPOSITORY_ID) VALUES(?, ?, ?)`
 	BlobSessionUpdateQuery = `UPDATE IMAGE_BLOB_UPLOAD_SESSION SET BYTES_RECEIVED = ? WHERE SESSION_ID = ?`
@@ -128,10 +147,86 @@ const (
 	CacheGetEntryQuery     = `SELECT NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, IDENTIFIER, DIGEST, EXPIRES_AT, CREATED_AT, UPDATED_AT FROM IMAGE_REGISTRY_CACHE WHERE REPOSITORY_ID = ? AND IDENTIFIER = ?`
 	CacheDeleteEntryQuery  = `DELETE FROM IMAGE_REGISTRY_CACHE WHERE REPOSITORY_ID = ? AND IDENTIFIER = ?`
 	CacheRefreshEntryQuery = `UPDATE IMAGE_REGISTRY_CACHE SET EXPIRES_AT = ? WHERE REPOSITORY_ID = ? AND IDENTIFIER = ?`
+	CacheTouchEntryQuery   = `UPDATE IMAGE_REGISTRY_CACHE SET LAST_ACCESSED_AT = CURRENT_TIMESTAMP WHERE REPOSITORY_ID = ? AND IDENTIFIER = ?`
+	CacheGetEntriesQuery   = `SELECT NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, IDENTIFIER, DIGEST, EXPIRES_AT, CREATED_AT, UPDATED_AT FROM IMAGE_REGISTRY_CACHE WHERE REPOSITORY_ID = ?`
+
+	// Cached repositories of upstreams along with the usage of their caches. Size includes manifests and blobs.
+	cachedRepositoriesQuery = `
+		SELECT
+			rr.REGISTRY_ID AS REGISTRY_ID,
+			rr.ID AS ID,
+			rn.NAME AS NAMESPACE,
+			rr.NAME AS NAME,
+			COALESCE(ce.TAGS, 0) AS TAGS,
+			COALESCE(ce.DIGESTS, 0) AS DIGESTS,
+			COALESCE(bm.BLOBS, 0) AS BLOBS,
+			COALESCE(im.SIZE, 0) + COALESCE(bm.SIZE, 0) AS SIZE,
+			NULLIF(MAX(COALESCE(ce.LAST_ACCESSED_AT, ''), COALESCE(bm.LAST_ACCESSED_AT, '')), '') AS LAST_ACCESSED_AT,
+			rr.CREATED_AT AS CREATED_AT
+		FROM REGISTRY_REPOSITORY rr
+		JOIN REGISTRY_NAMESPACE rn ON rn.ID = rr.NAMESPACE_ID
+		LEFT JOIN (
+			SELECT
+				REPOSITORY_ID,
+				SUM(CASE WHEN INSTR(IDENTIFIER, ':') = 0 THEN 1 ELSE 0 END) AS TAGS,
+				SUM(CASE WHEN INSTR(IDENTIFIER, ':') > 0 THEN 1 ELSE 0 END) AS DIGESTS,
+				MAX(LAST_ACCESSED_AT) AS LAST_ACCESSED_AT
+			FROM IMAGE_REGISTRY_CACHE GROUP BY REPOSITORY_ID
+		) AS ce ON ce.REPOSITORY_ID = rr.ID
+		LEFT JOIN (
+			SELECT REPOSITORY_ID, COUNT(*) AS BLOBS, SUM(SIZE) AS SIZE, MAX(LAST_ACCESSED_AT) AS LAST_ACCESSED_AT
+			FROM IMAGE_BLOB_META GROUP BY REPOSITORY_ID
+		) AS bm ON bm.REPOSITORY_ID = rr.ID
+		LEFT JOIN (
+			SELECT REPOSITORY_ID, SUM(SIZE) AS SIZE FROM IMAGE_MANIFEST GROUP BY REPOSITORY_ID
+		) AS im ON im.REPOSITORY_ID = rr.ID`
+	// IMPORTANT: Base list query avoids WHERE keywords. Refer NamespaceListBaseQuery
+	CacheRepositoryListBaseQuery  = `SELECT * FROM (` + cachedRepositoriesQuery + `) AS cached_repositories`
+	CacheRepositoryCountBaseQuery = `SELECT count(*) FROM (` + cachedRepositoriesQuery + `) AS cached_repositories`
+	// Cached tags and digests along with the manifests they refer.
+	cacheEntriesQuery = `
+		SELECT
+			ce.REGISTRY_ID AS REGISTRY_ID,
+			ce.REPOSITORY_ID AS REPOSITORY_ID,
+			ce.IDENTIFIER AS IDENTIFIER,
+			CASE WHEN INSTR(ce.IDENTIFIER, ':') > 0 THEN 'digest' ELSE 'tag' END AS TYPE,
+			ce.DIGEST AS DIGEST,
+			COALESCE(im.MEDIA_TYPE, '') AS MEDIA_TYPE,
+			COALESCE(im.SIZE, 0) AS SIZE,
+			ce.EXPIRES_AT AS EXPIRES_AT,
+			ce.LAST_ACCESSED_AT AS LAST_ACCESSED_AT,
+			ce.CREATED_AT AS CREATED_AT
+		FROM IMAGE_REGISTRY_CACHE ce
+		LEFT JOIN IMAGE_MANIFEST im ON im.REPOSITORY_ID = ce.REPOSITORY_ID AND im.DIGEST = ce.DIGEST`
+	// IMPORTANT: Base list query avoids WHERE keywords. Refer NamespaceListBaseQuery
+	CacheEntryListBaseQuery  = `SELECT * FROM (` + cacheEntriesQuery + `) AS cache_entries`
+	CacheEntryCountBaseQuery = `SELECT count(*) FROM (` + cacheEntriesQuery + `) AS cache_entries`
+
+	// Purging a repository deletes all cached images of it along with the repository. Blob metadata is
+	// selected first so the files can be removed from storage.
+	CachePurgeRepositoryBlobsQuery    = `SELECT NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, BLOB_DIGEST, SIZE, LOCATION, LAST_ACCESSED_AT, CREATED_AT, UPDATED_AT FROM IMAGE_BLOB_META WHERE REPOSITORY_ID = ?`
+	CachePurgeRepositoryEntriesQuery  = `DELETE FROM IMAGE_REGISTRY_CACHE WHERE REPOSITORY_ID = ?`
+	CachePurgeRepositoryMappingQuery  = `DELETE FROM IMAGE_MANIFEST_TAG_MAPPING WHERE TAG_ID IN (SELECT ID FROM IMAGE_TAG WHERE REPOSITORY_ID = ?)`
+	CachePurgeRepositoryTagsQuery     = `DELETE FROM IMAGE_TAG WHERE REPOSITORY_ID = ?`
+	CachePurgeRepositoryManifestQuery = `DELETE FROM IMAGE_MANIFEST WHERE REPOSITORY_ID = ?`
+	CachePurgeRepositoryBlobMetaQuery = `DELETE FROM IMAGE_BLOB_META WHERE REPOSITORY_ID = ?`
+	CachePurgeRepositoryQuery         = `DELETE FROM REGISTRY_REPOSITORY WHERE ID = ?`
+
+	// Purging an upstream deletes all cached images, repositories and namespaces of it.
+	CachePurgeRegistryBlobsQuery      = `SELECT NAMESPACE_ID, REGISTRY_ID, REPOSITORY_ID, BLOB_DIGEST, SIZE, LOCATION, LAST_ACCESSED_AT, CREATED_AT, UPDATED_AT FROM IMAGE_BLOB_META WHERE REGISTRY_ID = ?`
+	CachePurgeRegistryEntriesQuery    = `DELETE FROM IMAGE_REGISTRY_CACHE WHERE REGISTRY_ID = ?`
+	CachePurgeRegistryMappingQuery    = `DELETE FROM IMAGE_MANIFEST_TAG_MAPPING WHERE TAG_ID IN (SELECT ID FROM IMAGE_TAG WHERE REGISTRY_ID = ?)`
+	CachePurgeRegistryTagsQuery       = `DELETE FROM IMAGE_TAG WHERE REGISTRY_ID = ?`
+	CachePurgeRegistryManifestQuery   = `DELETE FROM IMAGE_MANIFEST WHERE REGISTRY_ID = ?`
+	CachePurgeRegistryBlobMetaQuery   = `DELETE FROM IMAGE_BLOB_META WHERE REGISTRY_ID = ?`
+	CachePurgeRegistryRepositoryQuery = `DELETE FROM REGISTRY_REPOSITORY WHERE REGISTRY_ID = ?`
+	CachePurgeRegistryNamespaceQuery  = `DELETE FROM REGISTRY_NAMESPACE WHERE REGISTRY_ID = ?`
 )
 
 const (
 	UserCreateAccountQuery                = `INSERT INTO USER_ACCOUNT(USERNAME, EMAIL, DISPLAY_NAME, PASSWORD, SALT) VALUES(?, ?, ?, ?, ?) RETURNING ID`
+	UserCreateMachineAccountQuery         = `INSERT INTO USER_ACCOUNT(USERNAME, EMAIL, DISPLAY_NAME, PASSWORD, SALT, LOCKED, ACCOUNT_TYPE) VALUES(?, '', ?, ?, ?, 0, 'Machine') RETURNING ID`
+	UserCreateExternalAccountQuery        = `INSERT INTO USER_ACCOUNT(USERNAME, EMAIL, DISPLAY_NAME, PASSWORD, SALT, LOCKED) VALUES(?, ?, ?, ?, ?, 0) RETURNING ID`
 	UserDeleteAccountQuery                = `UPDATE USER_ACCOUNT SET DELETED = 1, USERNAME = '[DELETED]' || USERNAME, EMAIL = '[DELETED]' || EMAIL, PASSWORD = '[DELETED]', SALT = '[DELETED]' WHERE ID = ?`
 	UserUpdateAccountQuery                = `UPDATE USER_ACCOUNT SET DISPLAY_NAME = ? WHERE ID = ?`
 	UserUpdateEmailAccountQuery           = `UPDATE USER_ACCOUNT SET EMAIL = ? WHERE ID = ?`
@@ -145,11 +240,13 @@ const (
 	UserUpdatePasswordQuery               = `UPDATE USER_ACCOUNT SET PASSWORD = ?, SALT = ? WHERE ID = ?`
 	UserValidateUsernameAndEmailQuery     = `SELECT acc.USERNAME, acc.EMAIL FROM USER_ACCOUNT acc WHERE USERNAME = ? OR EMAIL = ?`
 	UserGetPasswordAndSaltQuery           = `SELECT PASSWORD, SALT FROM USER_ACCOUNT WHERE DELETED = 0 AND ID = ?`
-	UserGetUserAccountByUsernameQuery     = `SELECT ID, USERNAME, EMAIL, DISPLAY_NAME, LOCKED, LOCKED_REASON, FAILED_ATTEMPTS, CREATED_AT, UPDATED_AT, LOCKED_AT
+	UserGetUserAccountByUsernameQuery     = `SELECT ID, USERNAME, EMAIL, DISPLAY_NAME, LOCKED, LOCKED_REASON, FAILED_ATTEMPTS, CREATED_AT, UPDATED_AT, LOCKED_AT,
+		ACCOUNT_TYPE
 		FROM USER_ACCOUNT
 		WHERE DELETED = 0 AND USERNAME = ?
 	`
-	UserGetUserAccountQuery = `SELECT ID, USERNAME, EMAIL, DISPLAY_NAME, LOCKED, LOCKED_REASON, FAILED_ATTEMPTS, CREATED_AT, UPDATED_AT, LOCKED_AT
+	UserGetUserAccountQuery = `SELECT ID, USERNAME, EMAIL, DISPLAY_NAME, LOCKED, LOCKED_REASON, FAILED_ATTEMPTS, CREATED_AT, UPDATED_AT, LOCKED_AT,
+		ACCOUNT_TYPE
 		FROM USER_ACCOUNT
 		WHERE DELETED = 0 AND (ID = ? OR USERNAME = ?)
 	`
@@ -174,7 +271,6 @@ const (
 	FROM USER_ACCOUNT acc
 	LEFT JOIN USER_ACCOUNT_RECOVERY ar ON acc.ID = ar.USER_ID
 	LEFT JOIN USER_ROLE_ASSIGNMENT ra ON acc.ID = ra.USER_ID
-	LEFT JOIN OAUTH_AUTH_SESSION session ON acc.ID = session.USER_ID
 	WHERE acc.DELETED = 0
 `
 	UserCountActiveAccountByIdsQuery = `SELECT COUNT(*) FROM USER_ACCOUNT WHERE ID IN (%s) AND DELETED = 0 AND LOCKED = 0`
@@ -183,14 +279,16 @@ const (
 	FROM USER_ACCOUNT acc
 	LEFT JOIN USER_ACCOUNT_RECOVERY ar ON acc.ID = ar.USER_ID
 	LEFT JOIN USER_ROLE_ASSIGNMENT ra ON acc.ID = ra.USER_ID
-	LEFT JOIN OAUTH_AUTH_SESSION session ON acc.ID = session.USER_ID
 	WHERE acc.DELETED = 0`
 
-	UserGetRoleQuery           = `SELECT ROLE_NAME FROM USER_ROLE_ASSIGNMENT WHERE USER_ID = ?`
-	UserAssignRoleQuery        = `INSERT INTO USER_ROLE_ASSIGNMENT (USER_ID, ROLE_NAME) VALUES(?, ?)`
-	UserUnassignRoleQuery      = `DELETE FROM USER_ROLE_ASSIGNMENT WHERE USER_ID = ?`
-	UserRecordLastAccessedTime = `UPDATE USER_ACCOUNT SET LAST_ACCESSED_AT = ? WHERE ID = ?`
-	DeleteAllNonAdminAccounts  = `UPDATE USER_ACCOUNT SET DELETED = 1 WHERE ID NOT IN (SELECT USER_ID FROM USER_ROLE_ASSIGNMENT WHERE ROLE_NAME = 'Admin')`
+	UserGetRoleQuery             = `SELECT ROLE_NAME FROM USER_ROLE_ASSIGNMENT WHERE USER_ID = ?`
+	UserAssignRoleQuery          = `INSERT INTO USER_ROLE_ASSIGNMENT (USER_ID, ROLE_NAME) VALUES(?, ?)`
+	UserUnassignRoleQuery        = `DELETE FROM USER_ROLE_ASSIGNMENT WHERE USER_ID = ?`
+	UserLinkIdentityQuery        = `INSERT OR REPLACE INTO USER_IDENTITY(USER_ID, PROVIDER, SUBJECT) VALUES(?, ?, ?)`
+	UserGetIDByIdentityQuery     = `SELECT ui.USER_ID FROM USER_IDENTITY ui JOIN USER_ACCOUNT acc ON acc.ID = ui.USER_ID WHERE acc.DELETED = 0 AND ui.PROVIDER = ? AND ui.SUBJECT = ?`
+	UserGetIdentityProviderQuery = `SELECT PROVIDER FROM USER_IDENTITY WHERE USER_ID = ?`
+	UserRecordLastAccessedTime   = `UPDATE USER_ACCOUNT SET LAST_ACCESSED_AT = ? WHERE ID = ?`
+	DeleteAllNonAdminAccounts    = `UPDATE USER_ACCOUNT SET DELETED = 1 WHERE ID NOT IN (SELECT USER_ID FROM USER_ROLE_ASSIGNMENT WHERE ROLE_NAME = 'Admin')`
 )
 
 const (
@@ -242,6 +340,63 @@ const (
 const (
 	RecordTokenRevocationQuery = `INSERT INTO REVOKED_TOKENS(SIGNATURE_HASH, EXPIRES_AT, ISSUED_AT, USER_ID) VALUES(?, ?, ?, ?)`
 	GetRevokedTokenQuery       = `SELECT SIGNATURE_HASH, EXPIRES_AT, ISSUED_AT, USER_ID FROM REVOKED_TOKENS WHERE SIGNATURE_HASH = ?`
+
+	RevokedTokenListQuery          = `SELECT SIGNATURE_HASH, EXPIRES_AT, ISSUED_AT, USER_ID FROM REVOKED_TOKENS WHERE EXPIRES_AT > ?`
+	RevokedTokenDeleteExpiredQuery = `DELETE FROM REVOKED_TOKENS WHERE EXPIRES_AT <= ?`
+
+	SessionCreateQuery              = `INSERT INTO OAUTH_AUTH_SESSION(USER_ID, SCOPE_HASH_SHA256, REFRESH_TOKEN_HASH, EXPIRES_AT, USER_AGENT, IP_ADDRESS, GRANT_TYPE) VALUES(?, ?, ?, DATETIME(CURRENT_TIMESTAMP, '+' || ? || ' seconds'), ?, ?, ?) RETURNING SESSION_ID`
+	SessionGetQuery                 = `SELECT SESSION_ID, USER_ID, SCOPE_HASH_SHA256, REFRESH_TOKEN_HASH, ISSUED_AT, EXPIRES_AT, LAST_ACCESSED_AT, USER_AGENT, IP_ADDRESS, GRANT_TYPE FROM OAUTH_AUTH_SESSION WHERE SESSION_ID = ? AND EXPIRES_AT > CURRENT_TIMESTAMP`
+	SessionGetByRefreshTokenQuery   = `SELECT SESSION_ID, USER_ID, SCOPE_HASH_SHA256, REFRESH_TOKEN_HASH, ISSUED_AT, EXPIRES_AT, LAST_ACCESSED_AT, USER_AGENT, IP_ADDRESS, GRANT_TYPE FROM OAUTH_AUTH_SESSION WHERE REFRESH_TOKEN_HASH = ? AND EXPIRES_AT > CURRENT_TIMESTAMP`
+	SessionListQuery                = `SELECT SESSION_ID, USER_ID, SCOPE_HASH_SHA256, REFRESH_TOKEN_HASH, ISSUED_AT, EXPIRES_AT, LAST_ACCESSED_AT, USER_AGENT, IP_ADDRESS, GRANT_TYPE FROM OAUTH_AUTH_SESSION WHERE USER_ID = ? AND EXPIRES_AT > CURRENT_TIMESTAMP ORDER BY LAST_ACCESSED_AT DESC, ISSUED_AT DESC`
+	SessionRotateRefreshTokenQuery  = `UPDATE OAUTH_AUTH_SESSION SET REFRESH_TOKEN_HASH = ?, LAST_ACCESSED_AT = CURRENT_TIMESTAMP WHERE SESSION_ID = ? AND REFRESH_TOKEN_HASH = ?`
+	SessionRecordAccessQuery        = `UPDATE OAUTH_AUTH_SESSION SET LAST_ACCESSED_AT = CURRENT_TIMESTAMP WHERE SESSION_ID = ?`
+	SessionDeleteQuery              = `DELETE FROM OAUTH_AUTH_SESSION WHERE SESSION_ID = ?`
+	SessionDeleteByUserQuery        = `DELETE FROM OAUTH_AUTH_SESSION WHERE USER_ID = ?`
+	SessionDeleteExpiredByUserQuery = `DELETE FROM OAUTH_AUTH_SESSION WHERE USER_ID = ? AND EXPIRES_AT <= CURRENT_TIMESTAMP`
+
+	AuthorizationRequestCreateQuery        = `INSERT INTO OAUTH_AUTHORIZATION_REQUEST(STATE, NONCE, CODE_VERIFIER, EXPIRES_AT) VALUES(?, ?, ?, DATETIME(CURRENT_TIMESTAMP, '+' || ? || ' seconds'))`
+	AuthorizationRequestTakeQuery          = `DELETE FROM OAUTH_AUTHORIZATION_REQUEST WHERE STATE = ? AND EXPIRES_AT > CURRENT_TIMESTAMP RETURNING STATE, NONCE, CODE_VERIFIER`
+	AuthorizationRequestDeleteExpiredQuery = `DELETE FROM OAUTH_AUTHORIZATION_REQUEST WHERE EXPIRES_AT <= CURRENT_TIMESTAMP`
+)
+
+const (
+	AccessTokenCreateQuery          = `INSERT INTO PERSONAL_ACCESS_TOKEN(USER_ID, NAME, TOKEN_PREFIX, TOKEN_HASH, SCOPES, EXPIRES_AT) VALUES(?, ?, ?, ?, ?, CASE WHEN ? > 0 THEN DATETIME(CURRENT_TIMESTAMP, '+' || ? || ' days') END) RETURNING ID`
+	AccessTokenGetQuery             = `SELECT ID, USER_ID, NAME, TOKEN_PREFIX, TOKEN_HASH, SCOPES, EXPIRES_AT, LAST_USED_AT, REVOKED_AT, CREATED_AT FROM PERSONAL_ACCESS_TOKEN WHERE USER_ID = ? AND ID = ?`
+	AccessTokenGetByHashQuery       = `SELECT ID, USER_ID, NAME, TOKEN_PREFIX, TOKEN_HASH, SCOPES, EXPIRES_AT, LAST_USED_AT, REVOKED_AT, CREATED_AT FROM PERSONAL_ACCESS_TOKEN WHERE TOKEN_HASH = ?`
+	AccessTokenGetActiveByNameQuery = `SELECT ID, USER_ID, NAME, TOKEN_PREFIX, TOKEN_HASH, SCOPES, EXPIRES_AT, LAST_USED_AT, REVOKED_AT, CREATED_AT FROM PERSONAL_ACCESS_TOKEN WHERE USER_ID = ? AND NAME = ? AND REVOKED_AT IS NULL`
+	AccessTokenListQuery            = `SELECT ID, USER_ID, NAME, TOKEN_PREFIX, TOKEN_HASH, SCOPES, EXPIRES_AT, LAST_USED_AT, REVOKED_AT, CREATED_AT FROM PERSONAL_ACCESS_TOKEN WHERE USER_ID = ? ORDER BY CREATED_AT DESC, NAME`
+	AccessTokenRevokeQuery          = `UPDATE PERSONAL_ACCESS_TOKEN SET REVOKED_AT = CURRENT_TIMESTAMP WHERE ID = ? AND REVOKED_AT IS NULL`
+	AccessTokenRecordUsageQuery     = `UPDATE PERSONAL_ACCESS_TOKEN SET LAST_USED_AT = CURRENT_TIMESTAMP WHERE ID = ?`
+)
+
+const (
+	TwoFactorSaveEnrollmentQuery         = `INSERT OR REPLACE INTO USER_TWO_FACTOR(USER_ID, SECRET) VALUES(?, ?)`
+	TwoFactorGetEnrollmentQuery          = `SELECT USER_ID, SECRET, ENABLED, LAST_USED_STEP, CREATED_AT, ENABLED_AT FROM USER_TWO_FACTOR WHERE USER_ID = ?`
+	TwoFactorEnableEnrollmentQuery       = `UPDATE USER_TWO_FACTOR SET ENABLED = 1, ENABLED_AT = CURRENT_TIMESTAMP WHERE USER_ID = ?`
+	TwoFactorRecordUsedStepQuery         = `UPDATE USER_TWO_FACTOR SET LAST_USED_STEP = ? WHERE USER_ID = ? AND LAST_USED_STEP < ?`
+	TwoFactorDeleteEnrollmentQuery       = `DELETE FROM USER_TWO_FACTOR WHERE USER_ID = ?`
+	TwoFactorDeleteRecoveryCodesQuery    = `DELETE FROM USER_TWO_FACTOR_RECOVERY_CODE WHERE USER_ID = ?`
+	TwoFactorInsertRecoveryCodeQuery     = `INSERT INTO USER_TWO_FACTOR_RECOVERY_CODE(USER_ID, CODE_HASH) VALUES(?, ?)`
+	TwoFactorUseRecoveryCodeQuery        = `UPDATE USER_TWO_FACTOR_RECOVERY_CODE SET USED_AT = CURRENT_TIMESTAMP WHERE USER_ID = ? AND CODE_HASH = ? AND USED_AT IS NULL`
+	TwoFactorCountRecoveryCodesQuery     = `SELECT COUNT(*) FROM USER_TWO_FACTOR_RECOVERY_CODE WHERE USER_ID = ? AND USED_AT IS NULL`
+	TwoFactorCreateChallengeQuery        = `INSERT INTO TWO_FACTOR_LOGIN_CHALLENGE(TOKEN_HASH, USER_ID, SCOPE_HASH_SHA256, GRANT_TYPE, EXPIRES_AT) VALUES(?, ?, ?, ?, DATETIME(CURRENT_TIMESTAMP, '+' || ? || ' seconds'))`
+	TwoFactorGetChallengeQuery           = `SELECT TOKEN_HASH, USER_ID, SCOPE_HASH_SHA256, GRANT_TYPE FROM TWO_FACTOR_LOGIN_CHALLENGE WHERE TOKEN_HASH = ? AND EXPIRES_AT > CURRENT_TIMESTAMP`
+	TwoFactorDeleteChallengeQuery        = `DELETE FROM TWO_FACTOR_LOGIN_CHALLENGE WHERE TOKEN_HASH = ?`
+	TwoFactorDeleteUserChallengesQuery   = `DELETE FROM TWO_FACTOR_LOGIN_CHALLENGE WHERE USER_ID = ?`
+	TwoFactorDeleteExpiredChallengeQuery = `DELETE FROM TWO_FACTOR_LOGIN_CHALLENGE WHERE EXPIRES_AT <= CURRENT_TIMESTAMP`
+	TwoFactorIsRequiredForRoleQuery      = `SELECT REQUIRE_TWO_FACTOR FROM USER_ROLE WHERE NAME = ?`
+	TwoFactorListRolePoliciesQuery       = `SELECT NAME, REQUIRE_TWO_FACTOR FROM USER_ROLE ORDER BY NAME`
+	TwoFactorSetRequiredForRoleQuery     = `UPDATE USER_ROLE SET REQUIRE_TWO_FACTOR = ? WHERE NAME = ?`
+)
+
+// signing keys
+const (
+	SigningKeyCreateQuery        = `INSERT INTO JWT_SIGNING_KEY(KID, ALGORITHM, PRIVATE_KEY, PUBLIC_KEY, ACTIVE) VALUES(?, ?, ?, ?, ?)`
+	SigningKeyGetQuery           = `SELECT KID, ALGORITHM, PRIVATE_KEY, PUBLIC_KEY, ACTIVE, CREATED_AT, RETIRED_AT FROM JWT_SIGNING_KEY WHERE KID = ?`
+	SigningKeyListQuery          = `SELECT KID, ALGORITHM, PRIVATE_KEY, PUBLIC_KEY, ACTIVE, CREATED_AT, RETIRED_AT FROM JWT_SIGNING_KEY ORDER BY CREATED_AT DESC, ROWID DESC`
+	SigningKeyRetireActiveQuery  = `UPDATE JWT_SIGNING_KEY SET ACTIVE = 0, RETIRED_AT = CURRENT_TIMESTAMP WHERE ACTIVE = 1`
+	SigningKeyActivateQuery      = `UPDATE JWT_SIGNING_KEY SET ACTIVE = 1, RETIRED_AT = NULL WHERE KID = ?`
+	SigningKeyDeleteRetiredQuery = `DELETE FROM JWT_SIGNING_KEY WHERE ACTIVE = 0 AND RETIRED_AT <= DATETIME(CURRENT_TIMESTAMP, '-' || ? || ' seconds')`
 )
 
 const (
@@ -268,22 +423,151 @@ const (
 
 const (
 	UpstreamCreateQuery      = `INSERT INTO UPSTREAM_REGISTRY(NAME, DESCRIPTION, VENDOR, STATE, PORT, UPSTREAM_URL) VALUES(?, ?, ?, ?, ?, ?) RETURNING ID`
-	UpstreamUpdateQuery      = `UPDATE UPSTREAM_REGISTRY DESCRIPTION = ?, STATE = ?, PORT = ?, UPSTREAM_URL = ? WHERE REGISTRY_ID = ?`
-	UpstreamDeleteQuery      = `DELETE FROM UPSTREAM_REGISTRY WHERE REGISTRY_ID = ?`
-	UpstreamGetQuery         = `SELECT ID, NAME, DESCRIPTION, VENDOR, STATE, PORT, UPSTREAM_URL, CREATED_AT, UPDATED_AT WHERE REGISTRY_ID = ?`
-	UpstreamChangeStateQuery = `UPDATE UPSTREAM_REGISTRY SET STATE = ? WHERE REGISTRY_ID = ?`
+	UpstreamUpdateQuery      = `UPDATE UPSTREAM_REGISTRY SET NAME = ?, DESCRIPTION = ?, VENDOR = ?, STATE = ?, PORT = ?, UPSTREAM_URL = ? WHERE ID = ?`
+	UpstreamDeleteQuery      = `DELETE FROM UPSTREAM_REGISTRY WHERE ID = ?`
+	UpstreamGetQuery         = `SELECT ID, NAME, DESCRIPTION, VENDOR, STATE, PORT, UPSTREAM_URL, CREATED_AT, UPDATED_AT FROM UPSTREAM_REGISTRY WHERE ID = ?`
+	UpstreamGetByNameQuery   = `SELECT ID, NAME, DESCRIPTION, VENDOR, STATE, PORT, UPSTREAM_URL, CREATED_AT, UPDATED_AT FROM UPSTREAM_REGISTRY WHERE NAME = ?`
+	UpstreamChangeStateQuery = `UPDATE UPSTREAM_REGISTRY SET STATE = ? WHERE ID = ?`
+	// IMPORTANT: Base list query avoids WHERE keywords. Refer NamespaceListBaseQuery
+	UpstreamListBaseQuery = `
+	SELECT
+		ur.ID AS ID,
+		ur.NAME AS NAME,
+		ur.DESCRIPTION AS DESCRIPTION,
+		ur.VENDOR AS VENDOR,
+		ur.STATE AS STATE,
+		ur.PORT AS PORT,
+		ur.UPSTREAM_URL AS UPSTREAM_URL,
+		COALESCE(mc.CACHED_IMAGES, 0) AS CACHED_IMAGES,
+		ur.CREATED_AT AS CREATED_AT,
+		ur.UPDATED_AT AS UPDATED_AT
+	FROM UPSTREAM_REGISTRY ur
+	LEFT JOIN (
+		SELECT REGISTRY_ID, COUNT(*) AS CACHED_IMAGES FROM IMAGE_MANIFEST GROUP BY REGISTRY_ID
+	) AS mc ON mc.REGISTRY_ID = ur.ID`
+	UpstreamCountBaseQuery = `SELECT count(*) FROM UPSTREAM_REGISTRY ur `
 
 	UpstreamPersistAuthConfigQuery = `INSERT INTO UPSTREAM_REGISTRY_AUTH_CONFIG(AUTH_TYPE, CONFIG_JSON, REGISTRY_ID) VALUES (?, ?, ?)`
 	UpstreamUpdateAuthConfigQuery  = `UPDATE UPSTREAM_REGISTRY_AUTH_CONFIG SET AUTH_TYPE = ?, CONFIG_JSON = ? WHERE REGISTRY_ID = ?`
 	UpstreamGetAuthConfigQuery     = `SELECT AUTH_TYPE, CONFIG_JSON, CREATED_AT, UPDATED_AT FROM UPSTREAM_REGISTRY_AUTH_CONFIG WHERE REGISTRY_ID = ?`
 
-	UpstreamPersistCacheConfigQuery = `INSERT INTO UPSTREAM_REGISTRY_CACHE_STORAGE_CONFIG(REGISTRY_ID, CACHE_ENABLED, TTL_SECONDS, STORAGE_LIMIT, CLEANUP_THRESHOLD_PERCENTAGE) VALUES(?, ?, ?, ?, ?)`
-	UpstreamUpdateCacheConfigQuery  = `UPDATE UPSTREAM_REGISTRY_CACHE_STORAGE_CONFIG SET CACHE_ENABLED = ?, TTL_SECONDS = ?, STORAGE_LIMIT = ?, CLEANUP_THRESHOLD_PERCENTAGE = ? WHERE REGISTRY_ID = ?`
-	UpstreamGetCacheConfigQuery     = `SELECT CACHE_ENABLED, TTL_SECONDS, STORAGE_LIMIT, CLEANUP_THRESHOLD_PERCENTAGE, CREATED_AT, UPDATED_AT FROM UPSTREAM_REGISTRY_CACHE_STORAGE_CONFIG WHERE REGISTRY_ID = ?`
+	UpstreamPersistCacheConfigQuery = `INSERT INTO UPSTREAM_REGISTRY_CACHE_STORAGE_CONFIG(REGISTRY_ID, CACHE_ENABLED, TTL_SECONDS, TTL_RULES, NEGATIVE_TTL_SECONDS, STORAGE_LIMIT, CLEANUP_THRESHOLD_PERCENTAGE) VALUES(?, ?, ?, ?, ?, ?, ?)`
+	UpstreamUpdateCacheConfigQuery  = `UPDATE UPSTREAM_REGISTRY_CACHE_STORAGE_CONFIG SET CACHE_ENABLED = ?, TTL_SECONDS = ?, TTL_RULES = ?, NEGATIVE_TTL_SECONDS = ?, STORAGE_LIMIT = ?, CLEANUP_THRESHOLD_PERCENTAGE = ? WHERE REGISTRY_ID = ?`
+	UpstreamGetCacheConfigQuery     = `SELECT CACHE_ENABLED, TTL_SECONDS, TTL_RULES, NEGATIVE_TTL_SECONDS, STORAGE_LIMIT, CLEANUP_THRESHOLD_PERCENTAGE, CREATED_AT, UPDATED_AT FROM UPSTREAM_REGISTRY_CACHE_STORAGE_CONFIG WHERE REGISTRY_ID = ?`
+
+	UpstreamPersistNetworkConfigQuery = `INSERT INTO UPSTREAM_REGISTRY_NETWORK_CONFIG(REGISTRY_ID, CONNECTION_TIMEOUT, READ_TIMEOUT, WRITE_TIMEOUT, MAX_CONNECTIONS, MAX_IDLE_CONNECTIONS, MAX_RETRIES, RETRY_DELAY, RETRY_BACKOFF_MULTIPLIER, PROXY_ENABLED, PROXY_URL, PROXY_USERNAME, PROXY_PASSWORD, TLS_CA_BUNDLE, TLS_CLIENT_CERT, TLS_CLIENT_KEY, TLS_INSECURE_SKIP_VERIFY) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	UpstreamUpdateNetworkConfigQuery  = `UPDATE UPSTREAM_REGISTRY_NETWORK_CONFIG SET CONNECTION_TIMEOUT = ? , READ_TIMEOUT = ? , WRITE_TIMEOUT = ? , MAX_CONNECTIONS = ? , MAX_IDLE_CONNECTIONS = ?, MAX_RETRIES = ?, RETRY_DELAY = ?, RETRY_BACKOFF_MULTIPLIER = ?, PROXY_ENABLED = ?, PROXY_URL = ?, PROXY_USERNAME = ?, PROXY_PASSWORD = ?, TLS_CA_BUNDLE = ?, TLS_CLIENT_CERT = ?, TLS_CLIENT_KEY = ?, TLS_INSECURE_SKIP_VERIFY = ? WHERE REGISTRY_ID = ?`
+	UpstreamGetNetworkConfigQuery     = `SELECT CONNECTION_TIMEOUT, READ_TIMEOUT, WRITE_TIMEOUT, MAX_CONNECTIONS, MAX_IDLE_CONNECTIONS, MAX_RETRIES, RETRY_DELAY, RETRY_BACKOFF_MULTIPLIER, PROXY_ENABLED, PROXY_URL, PROXY_USERNAME, PROXY_PASSWORD, TLS_CA_BUNDLE, TLS_CLIENT_CERT, TLS_CLIENT_KEY, TLS_INSECURE_SKIP_VERIFY, CREATED_AT, UPDATED_AT FROM UPSTREAM_REGISTRY_NETWORK_CONFIG WHERE REGISTRY_ID = ?`
 
-	UpstreamPersistNetworkConfigQuery = `INSERT INTO UPSTREAM_REGISTRY_NETWORK_CONFIG(REGISTRY_ID, CONNECTION_TIMEOUT, READ_TIMEOUT, WRITE_TIMEOUT, MAX_CONNECTIONS, MAX_IDLE_CONNECTIONS, MAX_RETRIES, RETRY_DELAY, RETRY_BACKOFF_MULTIPLIER) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	UpstreamUpdateNetworkConfigQuery  = `UPDATE UPSTREAM_REGISTRY_NETWORK_CONFIG SET CONNECTION_TIMEOUT = ? , READ_TIMEOUT = ? , WRITE_TIMEOUT = ? , MAX_CONNECTIONS = ? , MAX_IDLE_CONNECTIONS = ?, MAX_RETRIES = ?, RETRY_DELAY = ?, RETRY_BACKOFF_MULTIPLIER = ? WHERE REGISTRY_ID = ?`
-	UpstreamGetNetworkConfigQuery     = `SELECT CONNECTION_TIMEOUT, READ_TIMEOUT, WRITE_TIMEOUT, MAX_CONNECTIONS, MAX_IDLE_CONNECTIONS, MAX_RETRIES, RETRY_DELAY, RETRY_BACKOFF_MULTIPLIER, CREATED_AT, UPDATED_AT FROM UPSTREAM_REGISTRY_NETWORK_CONFIG WHERE REGISTRY_ID = ?`
+	UpstreamGetAllAddresses = `SELECT ID, NAME, PORT, UPSTREAM_URL FROM UPSTREAM_REGISTRY WHERE STATE != 'Disabled'`
+
+	SyncJobCreateQuery           = `INSERT INTO UPSTREAM_SYNC_JOB(REGISTRY_ID, NAME, REPOSITORIES, TAG_PATTERNS, PLATFORMS, SCHEDULE_INTERVAL_SECONDS, ENABLED) VALUES(?, ?, ?, ?, ?, ?, ?) RETURNING ID`
+	SyncJobUpdateQuery           = `UPDATE UPSTREAM_SYNC_JOB SET NAME = ?, REPOSITORIES = ?, TAG_PATTERNS = ?, PLATFORMS = ?, SCHEDULE_INTERVAL_SECONDS = ?, ENABLED = ? WHERE ID = ?`
+	SyncJobGetQuery              = `SELECT ID, REGISTRY_ID, NAME, REPOSITORIES, TAG_PATTERNS, PLATFORMS, SCHEDULE_INTERVAL_SECONDS, ENABLED, LAST_RUN_AT, LAST_RUN_STATUS, LAST_RUN_MESSAGE, LAST_SYNCED_IMAGES, CREATED_AT, UPDATED_AT FROM UPSTREAM_SYNC_JOB WHERE ID = ?`
+	SyncJobGetByNameQuery        = `SELECT ID, REGISTRY_ID, NAME, REPOSITORIES, TAG_PATTERNS, PLATFORMS, SCHEDULE_INTERVAL_SECONDS, ENABLED, LAST_RUN_AT, LAST_RUN_STATUS, LAST_RUN_MESSAGE, LAST_SYNCED_IMAGES, CREATED_AT, UPDATED_AT FROM UPSTREAM_SYNC_JOB WHERE REGISTRY_ID = ? AND NAME = ?`
+	SyncJobGetDueQuery           = `SELECT ID, REGISTRY_ID, NAME, REPOSITORIES, TAG_PATTERNS, PLATFORMS, SCHEDULE_INTERVAL_SECONDS, ENABLED, LAST_RUN_AT, LAST_RUN_STATUS, LAST_RUN_MESSAGE, LAST_SYNCED_IMAGES, CREATED_AT, UPDATED_AT FROM UPSTREAM_SYNC_JOB WHERE ENABLED = 1 AND SCHEDULE_INTERVAL_SECONDS > 0 AND LAST_RUN_STATUS != 'Running' AND (LAST_RUN_AT IS NULL OR DATETIME(LAST_RUN_AT, '+' || SCHEDULE_INTERVAL_SECONDS || ' seconds') <= CURRENT_TIMESTAMP)`
+	SyncJobDeleteQuery           = `DELETE FROM UPSTREAM_SYNC_JOB WHERE ID = ?`
+	SyncJobDeleteByRegistryQuery = `DELETE FROM UPSTREAM_SYNC_JOB WHERE REGISTRY_ID = ?`
+	SyncJobMarkRunningQuery      = `UPDATE UPSTREAM_SYNC_JOB SET LAST_RUN_STATUS = 'Running', LAST_RUN_MESSAGE = '', LAST_RUN_AT = CURRENT_TIMESTAMP WHERE ID = ? AND LAST_RUN_STATUS != 'Running'`
+	SyncJobRecordResultQuery     = `UPDATE UPSTREAM_SYNC_JOB SET LAST_RUN_STATUS = ?, LAST_RUN_MESSAGE = ?, LAST_SYNCED_IMAGES = ? WHERE ID = ?`
+	SyncJobResetRunningQuery     = `UPDATE UPSTREAM_SYNC_JOB SET LAST_RUN_STATUS = 'Failed', LAST_RUN_MESSAGE = 'Interrupted by server shutdown' WHERE LAST_RUN_STATUS = 'Running'`
+	// IMPORTANT: Base list query avoids WHERE keywords. Refer NamespaceListBaseQuery
+	SyncJobListBaseQuery = `
+	SELECT
+		sj.ID AS ID,
+		sj.REGISTRY_ID AS REGISTRY_ID,
+		sj.NAME AS NAME,
+		sj.REPOSITORIES AS REPOSITORIES,
+		sj.TAG_PATTERNS AS TAG_PATTERNS,
+		sj.PLATFORMS AS PLATFORMS,
+		sj.SCHEDULE_INTERVAL_SECONDS AS SCHEDULE_INTERVAL_SECONDS,
+		sj.ENABLED AS ENABLED,
+		sj.LAST_RUN_AT AS LAST_RUN_AT,
+		sj.LAST_RUN_STATUS AS LAST_RUN_STATUS,
+		sj.LAST_RUN_MESSAGE AS LAST_RUN_MESSAGE,
+		sj.LAST_SYNCED_IMAGES AS LAST_SYNCED_IMAGES,
+		sj.CREATED_AT AS CREATED_AT,
+		sj.UPDATED_AT AS UPDATED_AT
+	FROM UPSTREAM_SYNC_JOB sj`
+	SyncJobCountBaseQuery = `SELECT count(*) FROM UPSTREAM_SYNC_JOB sj `
 
-	UpstreamGetAllAddresses = `SELECT ID, NAME, PORT, UPSTREAM_URL FROM UPSTREAM_REGISTRY`
-)
\ No newline at end of file
+	GroupCreateQuery      = `INSERT INTO GROUP_REGISTRY(NAME, DESCRIPTION, STATE, PORT, PUSH_MEMBER_ID) VALUES(?, ?, ?, ?, ?) RETURNING ID`
+	GroupUpdateQuery      = `UPDATE GROUP_REGISTRY SET NAME = ?, DESCRIPTION = ?, STATE = ?, PORT = ?, PUSH_MEMBER_ID = ? WHERE ID = ?`
+	GroupDeleteQuery      = `DELETE FROM GROUP_REGISTRY WHERE ID = ?`
+	GroupGetQuery         = `SELECT ID, NAME, DESCRIPTION, STATE, PORT, PUSH_MEMBER_ID, CREATED_AT, UPDATED_AT FROM GROUP_REGISTRY WHERE ID = ?`
+	GroupGetByNameQuery   = `SELECT ID, NAME, DESCRIPTION, STATE, PORT, PUSH_MEMBER_ID, CREATED_AT, UPDATED_AT FROM GROUP_REGISTRY WHERE NAME = ?`
+	GroupChangeStateQuery = `UPDATE GROUP_REGISTRY SET STATE = ? WHERE ID = ?`
+	GroupGetActiveQuery   = `SELECT ID, NAME, DESCRIPTION, STATE, PORT, PUSH_MEMBER_ID, CREATED_AT, UPDATED_AT FROM GROUP_REGISTRY WHERE STATE != 'Disabled'`
+	// IMPORTANT: Base list query avoids WHERE keywords. Refer NamespaceListBaseQuery
+	GroupListBaseQuery = `
+	SELECT
+		gr.ID AS ID,
+		gr.NAME AS NAME,
+		gr.DESCRIPTION AS DESCRIPTION,
+		gr.STATE AS STATE,
+		gr.PORT AS PORT,
+		gr.PUSH_MEMBER_ID AS PUSH_MEMBER_ID,
+		gr.CREATED_AT AS CREATED_AT,
+		gr.UPDATED_AT AS UPDATED_AT
+	FROM GROUP_REGISTRY gr`
+	GroupCountBaseQuery = `SELECT count(*) FROM GROUP_REGISTRY gr `
+
+	GroupDeleteMembersQuery     = `DELETE FROM GROUP_REGISTRY_MEMBER WHERE GROUP_ID = ?`
+	GroupAddMemberQuery         = `INSERT INTO GROUP_REGISTRY_MEMBER(GROUP_ID, MEMBER_ID, POSITION) VALUES(?, ?, ?)`
+	GroupRemoveMemberQuery      = `DELETE FROM GROUP_REGISTRY_MEMBER WHERE MEMBER_ID = ?`
+	GroupGetMembersQuery        = `SELECT m.MEMBER_ID, COALESCE(ur.NAME, ''), COALESCE(ur.STATE, ''), m.POSITION FROM GROUP_REGISTRY_MEMBER m LEFT JOIN UPSTREAM_REGISTRY ur ON ur.ID = m.MEMBER_ID WHERE m.GROUP_ID = ? ORDER BY m.POSITION`
+	GroupGetGroupsOfMemberQuery = `SELECT GROUP_ID FROM GROUP_REGISTRY_MEMBER WHERE MEMBER_ID = ?`
+	GroupIsPortInUseQuery       = `SELECT (SELECT COUNT(*) FROM UPSTREAM_REGISTRY WHERE PORT = ? AND ID != ?) + (SELECT COUNT(*) FROM GROUP_REGISTRY WHERE PORT = ? AND ID != ?)`
+
+	ListImageTagsQuery = `SELECT rn.NAME, rr.NAME, it.TAG FROM IMAGE_TAG it JOIN REGISTRY_REPOSITORY rr ON rr.ID = it.REPOSITORY_ID JOIN REGISTRY_NAMESPACE rn ON rn.ID = it.NAMESPACE_ID WHERE it.REGISTRY_ID = ? ORDER BY rn.NAME, rr.NAME, it.TAG`
+
+	ReplicationCreateRuleQuery     = `INSERT INTO REPLICATION_RULE(NAME, DESCRIPTION, TARGET_URL, USERNAME, PASSWORD, NAMESPACE_FILTER, REPOSITORY_FILTER, TAG_FILTER, TRIGGER_TYPE, SCHEDULE_INTERVAL_SECONDS, ENABLED) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING ID`
+	ReplicationUpdateRuleQuery     = `UPDATE REPLICATION_RULE SET NAME = ?, DESCRIPTION = ?, TARGET_URL = ?, USERNAME = ?, PASSWORD = ?, NAMESPACE_FILTER = ?, REPOSITORY_FILTER = ?, TAG_FILTER = ?, TRIGGER_TYPE = ?, SCHEDULE_INTERVAL_SECONDS = ?, ENABLED = ? WHERE ID = ?`
+	ReplicationGetRuleQuery        = `SELECT ID, NAME, DESCRIPTION, TARGET_URL, USERNAME, PASSWORD, NAMESPACE_FILTER, REPOSITORY_FILTER, TAG_FILTER, TRIGGER_TYPE, SCHEDULE_INTERVAL_SECONDS, ENABLED, LAST_SCHEDULED_AT, CREATED_AT, UPDATED_AT FROM REPLICATION_RULE WHERE ID = ?`
+	ReplicationGetRuleByNameQuery  = `SELECT ID, NAME, DESCRIPTION, TARGET_URL, USERNAME, PASSWORD, NAMESPACE_FILTER, REPOSITORY_FILTER, TAG_FILTER, TRIGGER_TYPE, SCHEDULE_INTERVAL_SECONDS, ENABLED, LAST_SCHEDULED_AT, CREATED_AT, UPDATED_AT FROM REPLICATION_RULE WHERE NAME = ?`
+	ReplicationGetEnabledRuleQuery = `SELECT ID, NAME, DESCRIPTION, TARGET_URL, USERNAME, PASSWORD, NAMESPACE_FILTER, REPOSITORY_FILTER, TAG_FILTER, TRIGGER_TYPE, SCHEDULE_INTERVAL_SECONDS, ENABLED, LAST_SCHEDULED_AT, CREATED_AT, UPDATED_AT FROM REPLICATION_RULE WHERE ENABLED = 1 AND TRIGGER_TYPE = ?`
+	ReplicationGetDueRulesQuery    = `SELECT ID, NAME, DESCRIPTION, TARGET_URL, USERNAME, PASSWORD, NAMESPACE_FILTER, REPOSITORY_FILTER, TAG_FILTER, TRIGGER_TYPE, SCHEDULE_INTERVAL_SECONDS, ENABLED, LAST_SCHEDULED_AT, CREATED_AT, UPDATED_AT FROM REPLICATION_RULE WHERE ENABLED = 1 AND TRIGGER_TYPE = 'scheduled' AND (LAST_SCHEDULED_AT IS NULL OR DATETIME(LAST_SCHEDULED_AT, '+' || SCHEDULE_INTERVAL_SECONDS || ' seconds') <= CURRENT_TIMESTAMP)`
+	ReplicationMarkScheduledQuery  = `UPDATE REPLICATION_RULE SET LAST_SCHEDULED_AT = CURRENT_TIMESTAMP WHERE ID = ?`
+	ReplicationDeleteRuleQuery     = `DELETE FROM REPLICATION_RULE WHERE ID = ?`
+	ReplicationDeleteTasksQuery    = `DELETE FROM REPLICATION_TASK WHERE RULE_ID = ?`
+	// IMPORTANT: Base list query avoids WHERE keywords. Refer NamespaceListBaseQuery
+	ReplicationListBaseQuery = `
+	SELECT
+		rr.ID AS ID,
+		rr.NAME AS NAME,
+		rr.DESCRIPTION AS DESCRIPTION,
+		rr.TARGET_URL AS TARGET_URL,
+		rr.USERNAME AS USERNAME,
+		rr.PASSWORD AS PASSWORD,
+		rr.NAMESPACE_FILTER AS NAMESPACE_FILTER,
+		rr.REPOSITORY_FILTER AS REPOSITORY_FILTER,
+		rr.TAG_FILTER AS TAG_FILTER,
+		rr.TRIGGER_TYPE AS TRIGGER_TYPE,
+		rr.SCHEDULE_INTERVAL_SECONDS AS SCHEDULE_INTERVAL_SECONDS,
+		rr.ENABLED AS ENABLED,
+		rr.LAST_SCHEDULED_AT AS LAST_SCHEDULED_AT,
+		rr.CREATED_AT AS CREATED_AT,
+		rr.UPDATED_AT AS UPDATED_AT
+	FROM REPLICATION_RULE rr`
+	ReplicationCountBaseQuery = `SELECT count(*) FROM REPLICATION_RULE rr `
+
+	ReplicationEnqueueTaskQuery     = `INSERT INTO REPLICATION_TASK(RULE_ID, NAMESPACE, REPOSITORY, TAG) SELECT ?, ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM REPLICATION_TASK WHERE RULE_ID = ? AND NAMESPACE = ? AND REPOSITORY = ? AND TAG = ? AND STATUS = 'Pending')`
+	ReplicationGetDueTasksQuery     = `SELECT ID, RULE_ID, NAMESPACE, REPOSITORY, TAG, STATUS, ATTEMPTS, LAST_ERROR, NEXT_ATTEMPT_AT, COMPLETED_AT, CREATED_AT, UPDATED_AT FROM REPLICATION_TASK WHERE STATUS = 'Pending' AND NEXT_ATTEMPT_AT <= CURRENT_TIMESTAMP ORDER BY CREATED_AT LIMIT ?`
+	ReplicationMarkRunningQuery     = `UPDATE REPLICATION_TASK SET STATUS = 'Running' WHERE ID = ?`
+	ReplicationMarkSucceededQuery   = `UPDATE REPLICATION_TASK SET STATUS = 'Succeeded', ATTEMPTS = ATTEMPTS + 1, LAST_ERROR = '', COMPLETED_AT = CURRENT_TIMESTAMP WHERE ID = ?`
+	ReplicationMarkRetryQuery       = `UPDATE REPLICATION_TASK SET STATUS = 'Pending', ATTEMPTS = ?, LAST_ERROR = ?, NEXT_ATTEMPT_AT = DATETIME('now', ?) WHERE ID = ?`
+	ReplicationMarkFailedQuery      = `UPDATE REPLICATION_TASK SET STATUS = 'Failed', ATTEMPTS = ?, LAST_ERROR = ?, COMPLETED_AT = CURRENT_TIMESTAMP WHERE ID = ?`
+	ReplicationResetRunningQuery    = `UPDATE REPLICATION_TASK SET STATUS = 'Pending' WHERE STATUS = 'Running'`
+	ReplicationListFailedTasksQuery = `SELECT ID, RULE_ID, NAMESPACE, REPOSITORY, TAG, STATUS, ATTEMPTS, LAST_ERROR, NEXT_ATTEMPT_AT, COMPLETED_AT, CREATED_AT, UPDATED_AT FROM REPLICATION_TASK WHERE RULE_ID = ? AND STATUS = 'Failed' ORDER BY COMPLETED_AT DESC LIMIT ?`
+	ReplicationRuleStatsQuery       = `
+	SELECT
+		COALESCE(SUM(CASE WHEN STATUS = 'Pending' THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN STATUS = 'Running' THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN STATUS = 'Succeeded' THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN STATUS = 'Failed' THEN 1 ELSE 0 END), 0),
+		MIN(CASE WHEN STATUS IN ('Pending', 'Running') THEN CREATED_AT END),
+		MAX(CASE WHEN STATUS = 'Succeeded' THEN COMPLETED_AT END),
+		MAX(CASE WHEN STATUS = 'Failed' THEN COMPLETED_AT END)
+	FROM REPLICATION_TASK WHERE RULE_ID = ?`
+	ReplicationLastErrorQuery = `SELECT LAST_ERROR FROM REPLICATION_TASK WHERE RULE_ID = ? AND LAST_ERROR != '' ORDER BY UPDATED_AT DESC LIMIT 1`
+)
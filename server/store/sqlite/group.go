@@ -0,0 +1,293 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ksankeerth/open-image-registry/errors/dberrors"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+type groupStore struct {
+	db *sql.DB
+}
+
+func newGroupStore(db *sql.DB) *groupStore {
+	return &groupStore{db: db}
+}
+
+func (g *groupStore) getQuerier(ctx context.Context) store.Querier {
+	if tx, ok := store.TxFromContext(ctx); ok {
+		return tx
+	}
+	return g.db
+}
+
+func (g *groupStore) CreateGroup(ctx context.Context, m *models.GroupRegistry) (id string, err error) {
+	q := g.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, GroupCreateQuery, m.Name, m.Description, m.State, m.Port, m.PushMemberID).Scan(&id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to create group registry")
+		return "", dberrors.ClassifyError(err, GroupCreateQuery)
+	}
+
+	return id, nil
+}
+
+func (g *groupStore) UpdateGroup(ctx context.Context, m *models.GroupRegistry) error {
+	q := g.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, GroupUpdateQuery, m.Name, m.Description, m.State, m.Port, m.PushMemberID, m.ID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to update group registry")
+		return dberrors.ClassifyError(err, GroupUpdateQuery)
+	}
+
+	return nil
+}
+
+func (g *groupStore) GetGroup(ctx context.Context, groupID string) (*models.GroupRegistry, error) {
+	return g.getGroup(ctx, GroupGetQuery, groupID)
+}
+
+func (g *groupStore) GetGroupByName(ctx context.Context, name string) (*models.GroupRegistry, error) {
+	return g.getGroup(ctx, GroupGetByNameQuery, name)
+}
+
+func (g *groupStore) getGroup(ctx context.Context, query, arg string) (*models.GroupRegistry, error) {
+	q := g.getQuerier(ctx)
+
+	var createdAt, updatedAt sql.NullString
+	var m models.GroupRegistry
+
+	err := q.QueryRowContext(ctx, query, arg).Scan(&m.ID, &m.Name, &m.Description, &m.State, &m.Port, &m.PushMemberID,
+		&createdAt, &updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve group registry")
+		return nil, dberrors.ClassifyError(err, query)
+	}
+
+	err = setGroupTimestamps(&m, createdAt, updatedAt)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to parse sqlite timestamp")
+		return nil, dberrors.ClassifyError(err, query)
+	}
+
+	return &m, nil
+}
+
+func (g *groupStore) ListGroups(ctx context.Context, conditions *store.ListQueryConditions) (groups []*models.GroupRegistry,
+	total int, err error) {
+	qb := store.NewQueryBuilder(store.DBTypeSqlite).
+		WithSearchFields("gr.NAME", "gr.DESCRIPTION").
+		WithFieldTransformation("name", "gr.NAME").
+		WithFieldTransformation("port", "gr.PORT").
+		WithFieldTransformation("state", "gr.STATE").
+		WithFieldTransformation("created_at", "gr.CREATED_AT").
+		WithAllowedFilterFields("STATE").
+		WithAllowedSortFields("NAME", "PORT", "CREATED_AT")
+
+	listQuery, countQuery, args, err := qb.Build(GroupListBaseQuery, GroupCountBaseQuery, conditions)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to build group list query")
+		return nil, 0, fmt.Errorf("build query: %w", err)
+	}
+
+	// Execute count query first (without limit/offset)
+	countArgs := args[:len(args)-2]
+
+	q := g.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to count total group registries")
+		return nil, 0, fmt.Errorf("count groups: %w", err)
+	}
+
+	groups, err = g.queryGroups(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return groups, total, nil
+}
+
+func (g *groupStore) GetActiveGroups(ctx context.Context) ([]*models.GroupRegistry, error) {
+	groups, err := g.queryGroups(ctx, GroupGetActiveQuery)
+	if err != nil {
+		return nil, dberrors.ClassifyError(err, GroupGetActiveQuery)
+	}
+	return groups, nil
+}
+
+func (g *groupStore) queryGroups(ctx context.Context, query string, args ...any) ([]*models.GroupRegistry, error) {
+	q := g.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to retrieve group registries")
+		return nil, fmt.Errorf("query groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := make([]*models.GroupRegistry, 0)
+	for rows.Next() {
+		var m models.GroupRegistry
+		var createdAt, updatedAt sql.NullString
+
+		err = rows.Scan(&m.ID, &m.Name, &m.Description, &m.State, &m.Port, &m.PushMemberID, &createdAt, &updatedAt)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to scan group registry")
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+
+		err = setGroupTimestamps(&m, createdAt, updatedAt)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to parse sqlite timestamp")
+			return nil, fmt.Errorf("parse time: %w", err)
+		}
+
+		groups = append(groups, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("Error during row iteration")
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return groups, nil
+}
+
+func setGroupTimestamps(m *models.GroupRegistry, createdAt, updatedAt sql.NullString) error {
+	if createdAt.Valid {
+		createdTime, err := utils.ParseSqliteTimestamp(createdAt.String)
+		if err != nil {
+			return err
+		}
+		m.CreatedAt = *createdTime
+	}
+
+	if updatedAt.Valid {
+		updatedTime, err := utils.ParseSqliteTimestamp(updatedAt.String)
+		if err != nil {
+			return err
+		}
+		m.UpdatedAt = updatedTime
+	}
+	return nil
+}
+
+func (g *groupStore) DeleteGroup(ctx context.Context, groupID string) error {
+	q := g.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, GroupDeleteQuery, groupID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to delete group registry")
+		return dberrors.ClassifyError(err, GroupDeleteQuery)
+	}
+	return nil
+}
+
+func (g *groupStore) ChangeGroupState(ctx context.Context, groupID, state string) error {
+	q := g.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, GroupChangeStateQuery, state, groupID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to change state of group registry")
+		return dberrors.ClassifyError(err, GroupChangeStateQuery)
+	}
+	return nil
+}
+
+func (g *groupStore) SetMembers(ctx context.Context, groupID string, memberIDs []string) error {
+	q := g.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, GroupDeleteMembersQuery, groupID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to delete members of group registry")
+		return dberrors.ClassifyError(err, GroupDeleteMembersQuery)
+	}
+
+	for position, memberID := range memberIDs {
+		_, err = q.ExecContext(ctx, GroupAddMemberQuery, groupID, memberID, position)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to add member to group registry")
+			return dberrors.ClassifyError(err, GroupAddMemberQuery)
+		}
+	}
+	return nil
+}
+
+func (g *groupStore) GetMembers(ctx context.Context, groupID string) ([]*models.GroupRegistryMemberView, error) {
+	q := g.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, GroupGetMembersQuery, groupID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to retrieve members of group registry")
+		return nil, dberrors.ClassifyError(err, GroupGetMembersQuery)
+	}
+	defer rows.Close()
+
+	members := make([]*models.GroupRegistryMemberView, 0)
+	for rows.Next() {
+		var m models.GroupRegistryMemberView
+		err = rows.Scan(&m.MemberID, &m.Name, &m.State, &m.Position)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to read members of group registry")
+			return nil, dberrors.ClassifyError(err, GroupGetMembersQuery)
+		}
+		members = append(members, &m)
+	}
+	return members, nil
+}
+
+func (g *groupStore) RemoveMember(ctx context.Context, memberID string) (groupIDs []string, err error) {
+	q := g.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, GroupGetGroupsOfMemberQuery, memberID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to retrieve groups of member")
+		return nil, dberrors.ClassifyError(err, GroupGetGroupsOfMemberQuery)
+	}
+
+	groupIDs = make([]string, 0)
+	for rows.Next() {
+		var groupID string
+		err = rows.Scan(&groupID)
+		if err != nil {
+			rows.Close()
+			log.Logger().Error().Err(err).Msg("failed to read groups of member")
+			return nil, dberrors.ClassifyError(err, GroupGetGroupsOfMemberQuery)
+		}
+		groupIDs = append(groupIDs, groupID)
+	}
+	rows.Close()
+
+	_, err = q.ExecContext(ctx, GroupRemoveMemberQuery, memberID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to remove member from group registries")
+		return nil, dberrors.ClassifyError(err, GroupRemoveMemberQuery)
+	}
+	return groupIDs, nil
+}
+
+func (g *groupStore) IsPortInUse(ctx context.Context, port uint, excludeRegistryID string) (bool, error) {
+	q := g.getQuerier(ctx)
+
+	var count int
+	err := q.QueryRowContext(ctx, GroupIsPortInUseQuery, port, excludeRegistryID, port,
+		excludeRegistryID).Scan(&count)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to check port usage of registries")
+		return false, dberrors.ClassifyError(err, GroupIsPortInUseQuery)
+	}
+	return count > 0, nil
+}
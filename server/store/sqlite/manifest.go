@@ -51,9 +51,9 @@ func (m *manifestStore) GetByUniqueDigest(ctx context.Context, withContent bool,
 
 	var row *sql.Row
 	if withContent {
-		row = q.QueryRowContext(ctx, ManifestGetbyUniqueDigestWithContentQuery, digest)
+		row = q.QueryRowContext(ctx, ManifestGetbyUniqueDigestWithContentQuery, digest, repositoryId)
 	} else {
-		row = q.QueryRowContext(ctx, ManifestGetbyUniqueDigestQuery, digest)
+		row = q.QueryRowContext(ctx, ManifestGetbyUniqueDigestQuery, digest, repositoryId)
 	}
 
 	var createdAt, updatedAt string
@@ -108,9 +108,9 @@ func (m *manifestStore) GetByDigest(ctx context.Context, withContent bool, repos
 
 	var row *sql.Row
 	if withContent {
-		row = q.QueryRowContext(ctx, ManifestGetbyDigestWithContentQuery, digest)
+		row = q.QueryRowContext(ctx, ManifestGetbyDigestWithContentQuery, digest, repositoryId)
 	} else {
-		row = q.QueryRowContext(ctx, ManifestGetbyDigestQuery, digest)
+		row = q.QueryRowContext(ctx, ManifestGetbyDigestQuery, digest, repositoryId)
 	}
 
 	var createdAt, updatedAt string
@@ -168,4 +168,53 @@ func (m *manifestStore) DeleteByDigest(ctx context.Context, repositoryId, digest
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+func (m *manifestStore) GetByRepository(ctx context.Context, repositoryId string) ([]*models.ImageManifestModel,
+	error) {
+	q := m.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, ManifestGetByRepositoryQuery, repositoryId)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to get manifests of repository")
+		return nil, dberrors.ClassifyError(err, ManifestGetByRepositoryQuery)
+	}
+	defer rows.Close()
+
+	manifests := make([]*models.ImageManifestModel, 0)
+	for rows.Next() {
+		var createdAt, updatedAt sql.NullString
+		var model models.ImageManifestModel
+
+		err = rows.Scan(&model.ID, &model.Digest, &model.Size, &model.MediaType, &model.Content,
+			&model.NamespaceID, &model.RegistryID, &model.RepositoryID, &model.UniqueDigest,
+			&createdAt, &updatedAt)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to scan manifest")
+			return nil, dberrors.ClassifyError(err, ManifestGetByRepositoryQuery)
+		}
+
+		createdTime, err := utils.ParseSqliteTimestamp(createdAt.String)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to parse created_at timestamp")
+			return nil, dberrors.ClassifyError(err, ManifestGetByRepositoryQuery)
+		}
+		if createdTime != nil {
+			model.CreatedAt = *createdTime
+		}
+
+		model.UpdatedAt, err = utils.ParseSqliteTimestamp(updatedAt.String)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to parse updated_at timestamp")
+			return nil, dberrors.ClassifyError(err, ManifestGetByRepositoryQuery)
+		}
+
+		manifests = append(manifests, &model)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("failed to iterate manifests of repository")
+		return nil, dberrors.ClassifyError(err, ManifestGetByRepositoryQuery)
+	}
+
+	return manifests, nil
+}
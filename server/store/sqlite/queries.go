@@ -239,3 +239,26 @@ func (q *queries) GetRepositoryByNames(ctx context.Context, namespace, repositor
 
 	return &m, nil
 }
+
+func (q *queries) ListImageTags(ctx context.Context, registryID string) ([]*models.ImageTagView, error) {
+	qr := q.getQuerier(ctx)
+
+	rows, err := qr.QueryContext(ctx, ListImageTagsQuery, registryID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to retrieve image tags")
+		return nil, dberrors.ClassifyError(err, ListImageTagsQuery)
+	}
+	defer rows.Close()
+
+	tags := make([]*models.ImageTagView, 0)
+	for rows.Next() {
+		var t models.ImageTagView
+		err = rows.Scan(&t.Namespace, &t.Repository, &t.Tag)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to read image tags")
+			return nil, dberrors.ClassifyError(err, ListImageTagsQuery)
+		}
+		tags = append(tags, &t)
+	}
+	return tags, nil
+}
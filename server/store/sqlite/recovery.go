@@ -92,6 +92,34 @@ func (r *accountRecoveryStore) GetByUserID(ctx context.Context, userId string) (
 	return &m, nil
 }
 
+func (r *accountRecoveryStore) GetUnexpired(ctx context.Context, uuid string, reason,
+	expirySeconds int) (*models.AccountRecovery, error) {
+	q := r.getQuerier(ctx)
+
+	row := q.QueryRowContext(ctx, AccountRecoveryGetUnexpiredQuery, uuid, reason, expirySeconds)
+
+	var createdAt string
+	var m models.AccountRecovery
+
+	err := row.Scan(&m.RecoveryID, &m.UserID, &m.ReasonType, &createdAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // not found or expired
+		}
+		log.Logger().Error().Err(err).Msg("failed to get unexpired account recovery record")
+		return nil, dberrors.ClassifyError(err, AccountRecoveryGetUnexpiredQuery)
+	}
+
+	t, err := utils.ParseSqliteTimestamp(createdAt)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to parse created_at timestamp")
+		return nil, dberrors.ClassifyError(err, AccountRecoveryGetUnexpiredQuery)
+	}
+	m.CreatedAt = *t
+
+	return &m, nil
+}
+
 func (r *accountRecoveryStore) Delete(ctx context.Context, uuid string) error {
 	q := r.getQuerier(ctx)
 
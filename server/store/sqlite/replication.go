@@ -0,0 +1,394 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/errors/dberrors"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+type replicationStore struct {
+	db *sql.DB
+}
+
+func newReplicationStore(db *sql.DB) *replicationStore {
+	return &replicationStore{db: db}
+}
+
+func (r *replicationStore) getQuerier(ctx context.Context) store.Querier {
+	if tx, ok := store.TxFromContext(ctx); ok {
+		return tx
+	}
+	return r.db
+}
+
+func (r *replicationStore) CreateRule(ctx context.Context, m *models.ReplicationRule) (id string, err error) {
+	q := r.getQuerier(ctx)
+
+	var enabled int
+	if m.Enabled {
+		enabled = 1
+	}
+
+	err = q.QueryRowContext(ctx, ReplicationCreateRuleQuery, m.Name, m.Description, m.TargetURL, m.Username, m.Password,
+		m.NamespaceFilter, m.RepositoryFilter, m.TagFilter, m.Trigger, m.ScheduleIntervalSeconds,
+		enabled).Scan(&id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to create replication rule")
+		return "", dberrors.ClassifyError(err, ReplicationCreateRuleQuery)
+	}
+
+	return id, nil
+}
+
+func (r *replicationStore) UpdateRule(ctx context.Context, m *models.ReplicationRule) error {
+	q := r.getQuerier(ctx)
+
+	var enabled int
+	if m.Enabled {
+		enabled = 1
+	}
+
+	_, err := q.ExecContext(ctx, ReplicationUpdateRuleQuery, m.Name, m.Description, m.TargetURL, m.Username, m.Password,
+		m.NamespaceFilter, m.RepositoryFilter, m.TagFilter, m.Trigger, m.ScheduleIntervalSeconds,
+		enabled, m.ID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to update replication rule")
+		return dberrors.ClassifyError(err, ReplicationUpdateRuleQuery)
+	}
+
+	return nil
+}
+
+func (r *replicationStore) GetRule(ctx context.Context, ruleID string) (*models.ReplicationRule, error) {
+	return r.getRule(ctx, ReplicationGetRuleQuery, ruleID)
+}
+
+func (r *replicationStore) GetRuleByName(ctx context.Context, name string) (*models.ReplicationRule, error) {
+	return r.getRule(ctx, ReplicationGetRuleByNameQuery, name)
+}
+
+func (r *replicationStore) getRule(ctx context.Context, query, arg string) (*models.ReplicationRule, error) {
+	q := r.getQuerier(ctx)
+
+	m, err := scanRule(q.QueryRowContext(ctx, query, arg))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve replication rule")
+		return nil, dberrors.ClassifyError(err, query)
+	}
+
+	return m, nil
+}
+
+func (r *replicationStore) ListRules(ctx context.Context, conditions *store.ListQueryConditions) (
+	rules []*models.ReplicationRule, total int, err error) {
+	qb := store.NewQueryBuilder(store.DBTypeSqlite).
+		WithSearchFields("rr.NAME", "rr.DESCRIPTION", "rr.TARGET_URL").
+		WithFieldTransformation("name", "rr.NAME").
+		WithFieldTransformation("trigger", "rr.TRIGGER_TYPE").
+		WithFieldTransformation("created_at", "rr.CREATED_AT").
+		WithAllowedFilterFields("trigger").
+		WithAllowedSortFields("NAME", "CREATED_AT")
+
+	listQuery, countQuery, args, err := qb.Build(ReplicationListBaseQuery, ReplicationCountBaseQuery, conditions)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to build replication rule list query")
+		return nil, 0, fmt.Errorf("build query: %w", err)
+	}
+
+	// Execute count query first (without limit/offset)
+	countArgs := args[:len(args)-2]
+
+	q := r.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to count total replication rules")
+		return nil, 0, fmt.Errorf("count replication rules: %w", err)
+	}
+
+	rules, err = r.queryRules(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rules, total, nil
+}
+
+func (r *replicationStore) GetEnabledRules(ctx context.Context, trigger string) ([]*models.ReplicationRule, error) {
+	rules, err := r.queryRules(ctx, ReplicationGetEnabledRuleQuery, trigger)
+	if err != nil {
+		return nil, dberrors.ClassifyError(err, ReplicationGetEnabledRuleQuery)
+	}
+	return rules, nil
+}
+
+func (r *replicationStore) GetDueScheduledRules(ctx context.Context) ([]*models.ReplicationRule, error) {
+	rules, err := r.queryRules(ctx, ReplicationGetDueRulesQuery)
+	if err != nil {
+		return nil, dberrors.ClassifyError(err, ReplicationGetDueRulesQuery)
+	}
+	return rules, nil
+}
+
+func (r *replicationStore) queryRules(ctx context.Context, query string, args ...any) ([]*models.ReplicationRule, error) {
+	q := r.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to retrieve replication rules")
+		return nil, fmt.Errorf("query replication rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]*models.ReplicationRule, 0)
+	for rows.Next() {
+		m, err := scanRule(rows)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to scan replication rule")
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		rules = append(rules, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("Error during row iteration")
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return rules, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRule(row rowScanner) (*models.ReplicationRule, error) {
+	var m models.ReplicationRule
+	var enabled int
+	var lastScheduledAt, createdAt, updatedAt sql.NullString
+
+	err := row.Scan(&m.ID, &m.Name, &m.Description, &m.TargetURL, &m.Username, &m.Password, &m.NamespaceFilter,
+		&m.RepositoryFilter, &m.TagFilter, &m.Trigger, &m.ScheduleIntervalSeconds, &enabled, &lastScheduledAt,
+		&createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	m.Enabled = enabled == 1
+
+	m.LastScheduledAt, err = utils.ParseSqliteTimestamp(lastScheduledAt.String)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := utils.ParseSqliteTimestamp(createdAt.String)
+	if err != nil {
+		return nil, err
+	}
+	if created != nil {
+		m.CreatedAt = *created
+	}
+
+	m.UpdatedAt, err = utils.ParseSqliteTimestamp(updatedAt.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (r *replicationStore) MarkRuleScheduled(ctx context.Context, ruleID string) error {
+	q := r.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, ReplicationMarkScheduledQuery, ruleID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to update last schedule of replication rule")
+		return dberrors.ClassifyError(err, ReplicationMarkScheduledQuery)
+	}
+	return nil
+}
+
+func (r *replicationStore) DeleteRule(ctx context.Context, ruleID string) error {
+	q := r.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, ReplicationDeleteTasksQuery, ruleID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to delete tasks of replication rule")
+		return dberrors.ClassifyError(err, ReplicationDeleteTasksQuery)
+	}
+
+	_, err = q.ExecContext(ctx, ReplicationDeleteRuleQuery, ruleID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to delete replication rule")
+		return dberrors.ClassifyError(err, ReplicationDeleteRuleQuery)
+	}
+	return nil
+}
+
+func (r *replicationStore) EnqueueTask(ctx context.Context, ruleID, namespace, repository,
+	tag string) (queued bool, err error) {
+	q := r.getQuerier(ctx)
+
+	res, err := q.ExecContext(ctx, ReplicationEnqueueTaskQuery, ruleID, namespace, repository, tag, ruleID, namespace,
+		repository, tag)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to enqueue replication task")
+		return false, dberrors.ClassifyError(err, ReplicationEnqueueTaskQuery)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, dberrors.ClassifyError(err, ReplicationEnqueueTaskQuery)
+	}
+	return affected > 0, nil
+}
+
+func (r *replicationStore) GetDueTasks(ctx context.Context, limit int) ([]*models.ReplicationTask, error) {
+	return r.queryTasks(ctx, ReplicationGetDueTasksQuery, limit)
+}
+
+func (r *replicationStore) ListFailedTasks(ctx context.Context, ruleID string,
+	limit int) ([]*models.ReplicationTask, error) {
+	return r.queryTasks(ctx, ReplicationListFailedTasksQuery, ruleID, limit)
+}
+
+func (r *replicationStore) queryTasks(ctx context.Context, query string, args ...any) ([]*models.ReplicationTask, error) {
+	q := r.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to retrieve replication tasks")
+		return nil, dberrors.ClassifyError(err, query)
+	}
+	defer rows.Close()
+
+	tasks := make([]*models.ReplicationTask, 0)
+	for rows.Next() {
+		var t models.ReplicationTask
+		var nextAttemptAt, completedAt, createdAt, updatedAt sql.NullString
+
+		err = rows.Scan(&t.ID, &t.RuleID, &t.Namespace, &t.Repository, &t.Tag, &t.Status, &t.Attempts, &t.LastError,
+			&nextAttemptAt, &completedAt, &createdAt, &updatedAt)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to read replication tasks")
+			return nil, dberrors.ClassifyError(err, query)
+		}
+
+		err = setTaskTimestamps(&t, nextAttemptAt, completedAt, createdAt, updatedAt)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to parse sqlite timestamp")
+			return nil, dberrors.ClassifyError(err, query)
+		}
+		tasks = append(tasks, &t)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("Error during row iteration")
+		return nil, dberrors.ClassifyError(err, query)
+	}
+
+	return tasks, nil
+}
+
+func setTaskTimestamps(t *models.ReplicationTask, nextAttemptAt, completedAt, createdAt,
+	updatedAt sql.NullString) (err error) {
+	t.NextAttemptAt, err = utils.ParseSqliteTimestamp(nextAttemptAt.String)
+	if err != nil {
+		return err
+	}
+
+	t.CompletedAt, err = utils.ParseSqliteTimestamp(completedAt.String)
+	if err != nil {
+		return err
+	}
+
+	created, err := utils.ParseSqliteTimestamp(createdAt.String)
+	if err != nil {
+		return err
+	}
+	if created != nil {
+		t.CreatedAt = *created
+	}
+
+	t.UpdatedAt, err = utils.ParseSqliteTimestamp(updatedAt.String)
+	return err
+}
+
+func (r *replicationStore) MarkTaskRunning(ctx context.Context, taskID string) error {
+	return r.execTaskUpdate(ctx, ReplicationMarkRunningQuery, taskID)
+}
+
+func (r *replicationStore) MarkTaskSucceeded(ctx context.Context, taskID string) error {
+	return r.execTaskUpdate(ctx, ReplicationMarkSucceededQuery, taskID)
+}
+
+func (r *replicationStore) MarkTaskForRetry(ctx context.Context, taskID string, attempts int, lastError string,
+	delay time.Duration) error {
+	modifier := fmt.Sprintf("+%d seconds", int(delay.Seconds()))
+	return r.execTaskUpdate(ctx, ReplicationMarkRetryQuery, attempts, lastError, modifier, taskID)
+}
+
+func (r *replicationStore) MarkTaskFailed(ctx context.Context, taskID string, attempts int, lastError string) error {
+	return r.execTaskUpdate(ctx, ReplicationMarkFailedQuery, attempts, lastError, taskID)
+}
+
+func (r *replicationStore) ResetRunningTasks(ctx context.Context) error {
+	return r.execTaskUpdate(ctx, ReplicationResetRunningQuery)
+}
+
+func (r *replicationStore) execTaskUpdate(ctx context.Context, query string, args ...any) error {
+	q := r.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to update replication task")
+		return dberrors.ClassifyError(err, query)
+	}
+	return nil
+}
+
+func (r *replicationStore) GetRuleStats(ctx context.Context, ruleID string) (*models.ReplicationRuleStats, error) {
+	q := r.getQuerier(ctx)
+
+	var stats models.ReplicationRuleStats
+	var oldestPendingAt, lastSuccessAt, lastFailureAt sql.NullString
+
+	err := q.QueryRowContext(ctx, ReplicationRuleStatsQuery, ruleID).Scan(&stats.Pending, &stats.Running,
+		&stats.Succeeded, &stats.Failed, &oldestPendingAt, &lastSuccessAt, &lastFailureAt)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to retrieve statistics of replication rule")
+		return nil, dberrors.ClassifyError(err, ReplicationRuleStatsQuery)
+	}
+
+	err = q.QueryRowContext(ctx, ReplicationLastErrorQuery, ruleID).Scan(&stats.LastError)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Logger().Error().Err(err).Msg("failed to retrieve last error of replication rule")
+		return nil, dberrors.ClassifyError(err, ReplicationLastErrorQuery)
+	}
+
+	for _, ts := range []struct {
+		value sql.NullString
+		dest  **time.Time
+	}{
+		{oldestPendingAt, &stats.OldestPendingAt},
+		{lastSuccessAt, &stats.LastSuccessAt},
+		{lastFailureAt, &stats.LastFailureAt},
+	} {
+		*ts.dest, err = utils.ParseSqliteTimestamp(ts.value.String)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to parse sqlite timestamp")
+			return nil, dberrors.ClassifyError(err, ReplicationRuleStatsQuery)
+		}
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ksankeerth/open-image-registry/errors/dberrors"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+type signingKeyStore struct {
+	db *sql.DB
+}
+
+func newSigningKeyStore(db *sql.DB) *signingKeyStore {
+	return &signingKeyStore{db: db}
+}
+
+func (s *signingKeyStore) getQuerier(ctx context.Context) store.Querier {
+	if tx, ok := store.TxFromContext(ctx); ok {
+		return tx
+	}
+	return s.db
+}
+
+func (s *signingKeyStore) Create(ctx context.Context, m *models.SigningKey) error {
+	return s.exec(ctx, SigningKeyCreateQuery, m.KeyID, m.Algorithm, m.PrivateKey, m.PublicKey, m.Active)
+}
+
+func (s *signingKeyStore) Get(ctx context.Context, kid string) (*models.SigningKey, error) {
+	q := s.getQuerier(ctx)
+
+	m, err := scanSigningKey(q.QueryRowContext(ctx, SigningKeyGetQuery, kid))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve signing key")
+		return nil, dberrors.ClassifyError(err, SigningKeyGetQuery)
+	}
+	return m, nil
+}
+
+func (s *signingKeyStore) List(ctx context.Context) ([]*models.SigningKey, error) {
+	q := s.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, SigningKeyListQuery)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to list signing keys")
+		return nil, dberrors.ClassifyError(err, SigningKeyListQuery)
+	}
+	defer rows.Close()
+
+	var keys []*models.SigningKey
+	for rows.Next() {
+		m, err := scanSigningKey(rows)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("failed to read signing key")
+			return nil, dberrors.ClassifyError(err, SigningKeyListQuery)
+		}
+		keys = append(keys, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, dberrors.ClassifyError(err, SigningKeyListQuery)
+	}
+	return keys, nil
+}
+
+func (s *signingKeyStore) RetireActive(ctx context.Context) error {
+	return s.exec(ctx, SigningKeyRetireActiveQuery)
+}
+
+func (s *signingKeyStore) Activate(ctx context.Context, kid string) error {
+	return s.exec(ctx, SigningKeyActivateQuery, kid)
+}
+
+func (s *signingKeyStore) DeleteRetired(ctx context.Context, graceSeconds int) error {
+	return s.exec(ctx, SigningKeyDeleteRetiredQuery, graceSeconds)
+}
+
+func (s *signingKeyStore) exec(ctx context.Context, query string, args ...any) error {
+	q := s.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to update signing keys")
+		return dberrors.ClassifyError(err, query)
+	}
+	return nil
+}
+
+func scanSigningKey(row rowScanner) (*models.SigningKey, error) {
+	var m models.SigningKey
+	var createdAt, retiredAt sql.NullString
+
+	err := row.Scan(&m.KeyID, &m.Algorithm, &m.PrivateKey, &m.PublicKey, &m.Active, &createdAt, &retiredAt)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := utils.ParseSqliteTimestamp(createdAt.String)
+	if err != nil {
+		return nil, err
+	}
+	if created != nil {
+		m.CreatedAt = *created
+	}
+
+	m.RetiredAt, err = utils.ParseSqliteTimestamp(retiredAt.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
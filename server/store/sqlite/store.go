@@ -14,18 +14,24 @@ import (
 )
 
 type Store struct {
-	db         *sql.DB
-	acesss     *resourceAccessStore
-	auth       *authStore
-	blob       *blobMetaStore
-	cache      *registryCacheStore
-	manifest   *manifestStore
-	namespace  *namespaceStore
-	recovery   *accountRecoveryStore
-	repository *repositoryStore
-	tag        *imageTagStore
-	user       *userStore
-	upstream   *upstreamStore
+	db          *sql.DB
+	acesss      *resourceAccessStore
+	auth        *authStore
+	blob        *blobMetaStore
+	cache       *registryCacheStore
+	manifest    *manifestStore
+	namespace   *namespaceStore
+	recovery    *accountRecoveryStore
+	repository  *repositoryStore
+	tag         *imageTagStore
+	user        *userStore
+	upstream    *upstreamStore
+	group       *groupStore
+	replication *replicationStore
+	syncJob     *syncJobStore
+	accessToken *accessTokenStore
+	twoFactor   *twoFactorStore
+	signingKey  *signingKeyStore
 
 	queries *queries
 }
@@ -79,6 +85,12 @@ func NewWithDB(db *sql.DB) *Store {
 	s.recovery = newAccountRecoveryStore(db)
 	s.repository = newRepositoryStore(db)
 	s.upstream = newUpstreamStore(db)
+	s.group = newGroupStore(db)
+	s.replication = newReplicationStore(db)
+	s.syncJob = newSyncJobStore(db)
+	s.accessToken = newAccessTokenStore(db)
+	s.twoFactor = newTwoFactorStore(db)
+	s.signingKey = newSigningKeyStore(db)
 	s.user = newUserStore(db)
 	s.tag = newImageStore(db)
 
@@ -122,6 +134,30 @@ func (s *Store) Upstreams() store.UpstreamRegistyStore {
 	return s.upstream
 }
 
+func (s *Store) Groups() store.GroupRegistryStore {
+	return s.group
+}
+
+func (s *Store) Replications() store.ReplicationStore {
+	return s.replication
+}
+
+func (s *Store) SyncJobs() store.UpstreamSyncJobStore {
+	return s.syncJob
+}
+
+func (s *Store) AccessTokens() store.AccessTokenStore {
+	return s.accessToken
+}
+
+func (s *Store) TwoFactor() store.TwoFactorStore {
+	return s.twoFactor
+}
+
+func (s *Store) SigningKeys() store.SigningKeyStore {
+	return s.signingKey
+}
+
 func (s *Store) ImageQueries() store.ImageQueries {
 	return s.queries
 }
@@ -151,4 +187,4 @@ func (s *Store) Begin(ctx context.Context) (*sql.Tx, error) {
 		return nil, err
 	}
 	return tx, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,237 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ksankeerth/open-image-registry/errors/dberrors"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+type syncJobStore struct {
+	db *sql.DB
+}
+
+func newSyncJobStore(db *sql.DB) *syncJobStore {
+	return &syncJobStore{db: db}
+}
+
+func (s *syncJobStore) getQuerier(ctx context.Context) store.Querier {
+	if tx, ok := store.TxFromContext(ctx); ok {
+		return tx
+	}
+	return s.db
+}
+
+func (s *syncJobStore) CreateJob(ctx context.Context, m *models.UpstreamSyncJob) (id string, err error) {
+	q := s.getQuerier(ctx)
+
+	var enabled int
+	if m.Enabled {
+		enabled = 1
+	}
+
+	err = q.QueryRowContext(ctx, SyncJobCreateQuery, m.RegistryID, m.Name, m.Repositories, m.TagPatterns, m.Platforms,
+		m.ScheduleIntervalSeconds, enabled).Scan(&id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to create upstream sync job")
+		return "", dberrors.ClassifyError(err, SyncJobCreateQuery)
+	}
+
+	return id, nil
+}
+
+func (s *syncJobStore) UpdateJob(ctx context.Context, m *models.UpstreamSyncJob) error {
+	q := s.getQuerier(ctx)
+
+	var enabled int
+	if m.Enabled {
+		enabled = 1
+	}
+
+	_, err := q.ExecContext(ctx, SyncJobUpdateQuery, m.Name, m.Repositories, m.TagPatterns, m.Platforms,
+		m.ScheduleIntervalSeconds, enabled, m.ID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to update upstream sync job")
+		return dberrors.ClassifyError(err, SyncJobUpdateQuery)
+	}
+
+	return nil
+}
+
+func (s *syncJobStore) GetJob(ctx context.Context, jobID string) (*models.UpstreamSyncJob, error) {
+	return s.getJob(ctx, SyncJobGetQuery, jobID)
+}
+
+func (s *syncJobStore) GetJobByName(ctx context.Context, registryID, name string) (*models.UpstreamSyncJob, error) {
+	return s.getJob(ctx, SyncJobGetByNameQuery, registryID, name)
+}
+
+func (s *syncJobStore) getJob(ctx context.Context, query string, args ...any) (*models.UpstreamSyncJob, error) {
+	q := s.getQuerier(ctx)
+
+	m, err := scanSyncJob(q.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve upstream sync job")
+		return nil, dberrors.ClassifyError(err, query)
+	}
+
+	return m, nil
+}
+
+func (s *syncJobStore) ListJobs(ctx context.Context, conditions *store.ListQueryConditions) (
+	jobs []*models.UpstreamSyncJob, total int, err error) {
+	qb := store.NewQueryBuilder(store.DBTypeSqlite).
+		WithSearchFields("sj.NAME", "sj.REPOSITORIES").
+		WithFieldTransformation("name", "sj.NAME").
+		WithFieldTransformation("enabled", "sj.ENABLED").
+		WithFieldTransformation("registry_id", "sj.REGISTRY_ID").
+		WithFieldTransformation("created_at", "sj.CREATED_AT").
+		WithBooleanField("enabled").
+		WithAllowedFilterFields("enabled", "registry_id").
+		WithAllowedSortFields("NAME", "CREATED_AT")
+
+	listQuery, countQuery, args, err := qb.Build(SyncJobListBaseQuery, SyncJobCountBaseQuery, conditions)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to build upstream sync job list query")
+		return nil, 0, fmt.Errorf("build query: %w", err)
+	}
+
+	// Execute count query first (without limit/offset)
+	countArgs := args[:len(args)-2]
+
+	q := s.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to count total upstream sync jobs")
+		return nil, 0, fmt.Errorf("count upstream sync jobs: %w", err)
+	}
+
+	jobs, err = s.queryJobs(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+func (s *syncJobStore) GetDueJobs(ctx context.Context) ([]*models.UpstreamSyncJob, error) {
+	jobs, err := s.queryJobs(ctx, SyncJobGetDueQuery)
+	if err != nil {
+		return nil, dberrors.ClassifyError(err, SyncJobGetDueQuery)
+	}
+	return jobs, nil
+}
+
+func (s *syncJobStore) queryJobs(ctx context.Context, query string, args ...any) ([]*models.UpstreamSyncJob, error) {
+	q := s.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to retrieve upstream sync jobs")
+		return nil, fmt.Errorf("query upstream sync jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]*models.UpstreamSyncJob, 0)
+	for rows.Next() {
+		m, err := scanSyncJob(rows)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to scan upstream sync job")
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		jobs = append(jobs, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("Error during row iteration")
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return jobs, nil
+}
+
+func scanSyncJob(row rowScanner) (*models.UpstreamSyncJob, error) {
+	var m models.UpstreamSyncJob
+	var enabled int
+	var lastRunAt, createdAt, updatedAt sql.NullString
+
+	err := row.Scan(&m.ID, &m.RegistryID, &m.Name, &m.Repositories, &m.TagPatterns, &m.Platforms,
+		&m.ScheduleIntervalSeconds, &enabled, &lastRunAt, &m.LastRunStatus, &m.LastRunMessage, &m.LastSyncedImages,
+		&createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	m.Enabled = enabled == 1
+
+	m.LastRunAt, err = utils.ParseSqliteTimestamp(lastRunAt.String)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := utils.ParseSqliteTimestamp(createdAt.String)
+	if err != nil {
+		return nil, err
+	}
+	if created != nil {
+		m.CreatedAt = *created
+	}
+
+	m.UpdatedAt, err = utils.ParseSqliteTimestamp(updatedAt.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (s *syncJobStore) DeleteJob(ctx context.Context, jobID string) error {
+	return s.exec(ctx, SyncJobDeleteQuery, jobID)
+}
+
+func (s *syncJobStore) DeleteJobsOfRegistry(ctx context.Context, registryID string) error {
+	return s.exec(ctx, SyncJobDeleteByRegistryQuery, registryID)
+}
+
+func (s *syncJobStore) MarkJobRunning(ctx context.Context, jobID string) (started bool, err error) {
+	q := s.getQuerier(ctx)
+
+	res, err := q.ExecContext(ctx, SyncJobMarkRunningQuery, jobID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to start upstream sync job")
+		return false, dberrors.ClassifyError(err, SyncJobMarkRunningQuery)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, dberrors.ClassifyError(err, SyncJobMarkRunningQuery)
+	}
+	return affected > 0, nil
+}
+
+func (s *syncJobStore) RecordJobResult(ctx context.Context, jobID, status, message string, syncedImages int) error {
+	return s.exec(ctx, SyncJobRecordResultQuery, status, message, syncedImages, jobID)
+}
+
+func (s *syncJobStore) ResetRunningJobs(ctx context.Context) error {
+	return s.exec(ctx, SyncJobResetRunningQuery)
+}
+
+func (s *syncJobStore) exec(ctx context.Context, query string, args ...any) error {
+	q := s.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to update upstream sync jobs")
+		return dberrors.ClassifyError(err, query)
+	}
+	return nil
+}
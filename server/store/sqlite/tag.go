@@ -30,7 +30,8 @@ func (t *imageTagStore) Create(ctx context.Context, registryId, namespaceId, rep
 
 	q := t.getQuerier(ctx)
 
-	err = q.QueryRowContext(ctx, TagCreateQuery, registryId, namespaceId, repositoryId, tag).Scan(&id)
+	// tags are created as unstable. IS_STABLE is not used yet.
+	err = q.QueryRowContext(ctx, TagCreateQuery, registryId, namespaceId, repositoryId, 0, tag).Scan(&id)
 
 	if err != nil {
 		log.Logger().Error().Err(err).Msg("failed to create image tag")
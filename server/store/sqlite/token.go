@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ksankeerth/open-image-registry/errors/dberrors"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+type accessTokenStore struct {
+	db *sql.DB
+}
+
+func newAccessTokenStore(db *sql.DB) *accessTokenStore {
+	return &accessTokenStore{db: db}
+}
+
+func (s *accessTokenStore) getQuerier(ctx context.Context) store.Querier {
+	if tx, ok := store.TxFromContext(ctx); ok {
+		return tx
+	}
+	return s.db
+}
+
+func (s *accessTokenStore) CreateToken(ctx context.Context, m *models.PersonalAccessToken, expiryDays int) (
+	id string, err error) {
+	q := s.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, AccessTokenCreateQuery, m.UserID, m.Name, m.TokenPrefix, m.TokenHash, m.Scopes,
+		expiryDays, expiryDays).Scan(&id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to create personal access token")
+		return "", dberrors.ClassifyError(err, AccessTokenCreateQuery)
+	}
+
+	return id, nil
+}
+
+func (s *accessTokenStore) GetToken(ctx context.Context, userID, tokenID string) (*models.PersonalAccessToken,
+	error) {
+	return s.getToken(ctx, AccessTokenGetQuery, userID, tokenID)
+}
+
+func (s *accessTokenStore) GetTokenByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken,
+	error) {
+	return s.getToken(ctx, AccessTokenGetByHashQuery, tokenHash)
+}
+
+func (s *accessTokenStore) GetActiveTokenByName(ctx context.Context, userID, name string) (
+	*models.PersonalAccessToken, error) {
+	return s.getToken(ctx, AccessTokenGetActiveByNameQuery, userID, name)
+}
+
+func (s *accessTokenStore) getToken(ctx context.Context, query string, args ...any) (*models.PersonalAccessToken,
+	error) {
+	q := s.getQuerier(ctx)
+
+	m, err := scanAccessToken(q.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve personal access token")
+		return nil, dberrors.ClassifyError(err, query)
+	}
+
+	return m, nil
+}
+
+func (s *accessTokenStore) ListTokens(ctx context.Context, userID string) ([]*models.PersonalAccessToken, error) {
+	q := s.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, AccessTokenListQuery, userID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to retrieve personal access tokens")
+		return nil, dberrors.ClassifyError(err, AccessTokenListQuery)
+	}
+	defer rows.Close()
+
+	tokens := make([]*models.PersonalAccessToken, 0)
+	for rows.Next() {
+		m, err := scanAccessToken(rows)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to scan personal access token")
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		tokens = append(tokens, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("Error during row iteration")
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return tokens, nil
+}
+
+func scanAccessToken(row rowScanner) (*models.PersonalAccessToken, error) {
+	var m models.PersonalAccessToken
+	var expiresAt, lastUsedAt, revokedAt, createdAt sql.NullString
+
+	err := row.Scan(&m.ID, &m.UserID, &m.Name, &m.TokenPrefix, &m.TokenHash, &m.Scopes, &expiresAt, &lastUsedAt,
+		&revokedAt, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	m.ExpiresAt, err = utils.ParseSqliteTimestamp(expiresAt.String)
+	if err != nil {
+		return nil, err
+	}
+
+	m.LastUsedAt, err = utils.ParseSqliteTimestamp(lastUsedAt.String)
+	if err != nil {
+		return nil, err
+	}
+
+	m.RevokedAt, err = utils.ParseSqliteTimestamp(revokedAt.String)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := utils.ParseSqliteTimestamp(createdAt.String)
+	if err != nil {
+		return nil, err
+	}
+	if created != nil {
+		m.CreatedAt = *created
+	}
+
+	return &m, nil
+}
+
+func (s *accessTokenStore) RevokeToken(ctx context.Context, tokenID string) error {
+	return s.exec(ctx, AccessTokenRevokeQuery, tokenID)
+}
+
+func (s *accessTokenStore) RecordTokenUsage(ctx context.Context, tokenID string) error {
+	return s.exec(ctx, AccessTokenRecordUsageQuery, tokenID)
+}
+
+func (s *accessTokenStore) exec(ctx context.Context, query string, args ...any) error {
+	q := s.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to update personal access token")
+		return dberrors.ClassifyError(err, query)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ksankeerth/open-image-registry/errors/dberrors"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+type twoFactorStore struct {
+	db *sql.DB
+}
+
+func newTwoFactorStore(db *sql.DB) *twoFactorStore {
+	return &twoFactorStore{db: db}
+}
+
+func (s *twoFactorStore) getQuerier(ctx context.Context) store.Querier {
+	if tx, ok := store.TxFromContext(ctx); ok {
+		return tx
+	}
+	return s.db
+}
+
+func (s *twoFactorStore) SaveEnrollment(ctx context.Context, userID, secret string) error {
+	_, err := s.exec(ctx, TwoFactorSaveEnrollmentQuery, userID, secret)
+	return err
+}
+
+func (s *twoFactorStore) GetEnrollment(ctx context.Context, userID string) (*models.TwoFactorEnrollment, error) {
+	q := s.getQuerier(ctx)
+
+	var m models.TwoFactorEnrollment
+	var createdAt, enabledAt sql.NullString
+
+	err := q.QueryRowContext(ctx, TwoFactorGetEnrollmentQuery, userID).Scan(&m.UserID, &m.Secret, &m.Enabled,
+		&m.LastUsedStep, &createdAt, &enabledAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve two-factor enrollment")
+		return nil, dberrors.ClassifyError(err, TwoFactorGetEnrollmentQuery)
+	}
+
+	created, err := utils.ParseSqliteTimestamp(createdAt.String)
+	if err != nil {
+		return nil, err
+	}
+	if created != nil {
+		m.CreatedAt = *created
+	}
+
+	m.EnabledAt, err = utils.ParseSqliteTimestamp(enabledAt.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (s *twoFactorStore) EnableEnrollment(ctx context.Context, userID string) error {
+	_, err := s.exec(ctx, TwoFactorEnableEnrollmentQuery, userID)
+	return err
+}
+
+func (s *twoFactorStore) RecordUsedStep(ctx context.Context, userID string, step int64) (bool, error) {
+	affected, err := s.exec(ctx, TwoFactorRecordUsedStepQuery, step, userID, step)
+	return affected > 0, err
+}
+
+func (s *twoFactorStore) DeleteEnrollment(ctx context.Context, userID string) (bool, error) {
+	_, err := s.exec(ctx, TwoFactorDeleteRecoveryCodesQuery, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := s.exec(ctx, TwoFactorDeleteEnrollmentQuery, userID)
+	return affected > 0, err
+}
+
+func (s *twoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	_, err := s.exec(ctx, TwoFactorDeleteRecoveryCodesQuery, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		_, err = s.exec(ctx, TwoFactorInsertRecoveryCodeQuery, userID, codeHash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *twoFactorStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	affected, err := s.exec(ctx, TwoFactorUseRecoveryCodeQuery, userID, codeHash)
+	return affected > 0, err
+}
+
+func (s *twoFactorStore) CountUnusedRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	q := s.getQuerier(ctx)
+
+	var count int
+	err := q.QueryRowContext(ctx, TwoFactorCountRecoveryCodesQuery, userID).Scan(&count)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to count recovery codes")
+		return 0, dberrors.ClassifyError(err, TwoFactorCountRecoveryCodesQuery)
+	}
+	return count, nil
+}
+
+func (s *twoFactorStore) CreateChallenge(ctx context.Context, m *models.TwoFactorChallenge,
+	expirySeconds int) error {
+	_, err := s.exec(ctx, TwoFactorCreateChallengeQuery, m.TokenHash, m.UserID, m.ScopeHash, m.GrantType,
+		expirySeconds)
+	return err
+}
+
+func (s *twoFactorStore) GetChallenge(ctx context.Context, tokenHash string) (*models.TwoFactorChallenge, error) {
+	q := s.getQuerier(ctx)
+
+	var m models.TwoFactorChallenge
+	var scopeHash sql.NullString
+
+	err := q.QueryRowContext(ctx, TwoFactorGetChallengeQuery, tokenHash).Scan(&m.TokenHash, &m.UserID, &scopeHash,
+		&m.GrantType)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve two-factor login challenge")
+		return nil, dberrors.ClassifyError(err, TwoFactorGetChallengeQuery)
+	}
+	m.ScopeHash = scopeHash.String
+
+	return &m, nil
+}
+
+func (s *twoFactorStore) DeleteChallenge(ctx context.Context, tokenHash string) error {
+	_, err := s.exec(ctx, TwoFactorDeleteChallengeQuery, tokenHash)
+	return err
+}
+
+func (s *twoFactorStore) DeleteUserChallenges(ctx context.Context, userID string) error {
+	_, err := s.exec(ctx, TwoFactorDeleteUserChallengesQuery, userID)
+	return err
+}
+
+func (s *twoFactorStore) DeleteExpiredChallenges(ctx context.Context) error {
+	_, err := s.exec(ctx, TwoFactorDeleteExpiredChallengeQuery)
+	return err
+}
+
+func (s *twoFactorStore) IsRequiredForRole(ctx context.Context, role string) (bool, error) {
+	q := s.getQuerier(ctx)
+
+	var required bool
+	err := q.QueryRowContext(ctx, TwoFactorIsRequiredForRoleQuery, role).Scan(&required)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve two-factor policy of role")
+		return false, dberrors.ClassifyError(err, TwoFactorIsRequiredForRoleQuery)
+	}
+	return required, nil
+}
+
+func (s *twoFactorStore) ListRolePolicies(ctx context.Context) ([]*models.RoleTwoFactorPolicy, error) {
+	q := s.getQuerier(ctx)
+
+	rows, err := q.QueryContext(ctx, TwoFactorListRolePoliciesQuery)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to retrieve two-factor policies of roles")
+		return nil, dberrors.ClassifyError(err, TwoFactorListRolePoliciesQuery)
+	}
+	defer rows.Close()
+
+	policies := make([]*models.RoleTwoFactorPolicy, 0)
+	for rows.Next() {
+		var m models.RoleTwoFactorPolicy
+		err = rows.Scan(&m.Role, &m.TwoFactorRequired)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to scan two-factor policy of role")
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		policies = append(policies, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("Error during row iteration")
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return policies, nil
+}
+
+func (s *twoFactorStore) SetRequiredForRole(ctx context.Context, role string, required bool) (bool, error) {
+	affected, err := s.exec(ctx, TwoFactorSetRequiredForRoleQuery, required, role)
+	return affected > 0, err
+}
+
+func (s *twoFactorStore) exec(ctx context.Context, query string, args ...any) (affected int64, err error) {
+	q := s.getQuerier(ctx)
+
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to update two-factor authentication data")
+		return 0, dberrors.ClassifyError(err, query)
+	}
+
+	return res.RowsAffected()
+}
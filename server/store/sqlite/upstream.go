@@ -3,7 +3,9 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/ksankeerth/open-image-registry/errors/dberrors"
 	"github.com/ksankeerth/open-image-registry/log"
@@ -42,7 +44,7 @@ func (u *upstreamStore) CreateRegistry(ctx context.Context, m *models.UpstreamRe
 func (u *upstreamStore) UpdateRegistry(ctx context.Context, m *models.UpstreamRegistry) error {
 	q := u.getQuerier(ctx)
 
-	_, err := q.ExecContext(ctx, UpstreamUpdateQuery, m.Description, m.State, m.Port, m.UpstreamURL, m.ID)
+	_, err := q.ExecContext(ctx, UpstreamUpdateQuery, m.Name, m.Description, m.Vendor, m.State, m.Port, m.UpstreamURL, m.ID)
 	if err != nil {
 		log.Logger().Error().Err(err).Msg("failed to update upstream registry")
 		return dberrors.ClassifyError(err, UpstreamUpdateQuery)
@@ -52,41 +54,125 @@ func (u *upstreamStore) UpdateRegistry(ctx context.Context, m *models.UpstreamRe
 }
 
 func (u *upstreamStore) GetRegistry(ctx context.Context, registryID string) (*models.UpstreamRegistry, error) {
+	return u.getRegistry(ctx, UpstreamGetQuery, registryID)
+}
+
+func (u *upstreamStore) GetRegistryByName(ctx context.Context, name string) (*models.UpstreamRegistry, error) {
+	return u.getRegistry(ctx, UpstreamGetByNameQuery, name)
+}
+
+func (u *upstreamStore) getRegistry(ctx context.Context, query, arg string) (*models.UpstreamRegistry, error) {
 	q := u.getQuerier(ctx)
 
 	var m models.UpstreamRegistry
 
 	var createdAt, updatedAt string
-	err := q.QueryRowContext(ctx, UpstreamGetQuery, registryID).Scan(&m.ID, &m.Name, &m.Description, &m.Vendor, &m.State, &m.Port, &m.UpstreamURL,
+	err := q.QueryRowContext(ctx, query, arg).Scan(&m.ID, &m.Name, &m.Description, &m.Vendor, &m.State, &m.Port, &m.UpstreamURL,
 		&createdAt, &updatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		log.Logger().Error().Err(err).Msg("failed to retrieve upstream registry")
-		return nil, dberrors.ClassifyError(err, UpstreamGetQuery)
+		return nil, dberrors.ClassifyError(err, query)
 	}
 
-	if createdAt == "" {
+	if createdAt != "" {
 		createdTime, err := utils.ParseSqliteTimestamp(createdAt)
 		if err != nil {
 			log.Logger().Error().Err(err).Msg("failed to parse sqlite timestamp")
-			return nil, dberrors.ClassifyError(err, UpstreamGetQuery)
+			return nil, dberrors.ClassifyError(err, query)
 		}
 		m.CreatedAt = *createdTime
 	}
 
-	if updatedAt == "" {
+	if updatedAt != "" {
 		m.UpdatedAt, err = utils.ParseSqliteTimestamp(updatedAt)
 		if err != nil {
 			log.Logger().Error().Err(err).Msg("failed to parse sqlite timestamp")
-			return nil, dberrors.ClassifyError(err, UpstreamGetQuery)
+			return nil, dberrors.ClassifyError(err, query)
 		}
 	}
 
 	return &m, nil
 }
 
+func (u *upstreamStore) ListRegistries(ctx context.Context, conditions *store.ListQueryConditions) (registries []*models.UpstreamRegistryView,
+	total int, err error) {
+	qb := store.NewQueryBuilder(store.DBTypeSqlite).
+		WithSearchFields("ur.NAME", "ur.DESCRIPTION", "ur.UPSTREAM_URL").
+		WithFieldTransformation("name", "ur.NAME").
+		WithFieldTransformation("port", "ur.PORT").
+		WithFieldTransformation("state", "ur.STATE").
+		WithFieldTransformation("vendor", "ur.VENDOR").
+		WithFieldTransformation("created_at", "ur.CREATED_AT").
+		WithAllowedFilterFields("STATE", "VENDOR").
+		WithAllowedSortFields("NAME", "PORT", "CREATED_AT")
+
+	listQuery, countQuery, args, err := qb.Build(UpstreamListBaseQuery, UpstreamCountBaseQuery, conditions)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to build upstream list query")
+		return nil, 0, fmt.Errorf("build query: %w", err)
+	}
+
+	// Execute count query first (without limit/offset)
+	countArgs := args[:len(args)-2]
+
+	q := u.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to count total upstream registries")
+		return nil, 0, fmt.Errorf("count upstreams: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Failed to retrieve upstream registries")
+		return nil, 0, fmt.Errorf("query upstreams: %w", err)
+	}
+	defer rows.Close()
+
+	registries = make([]*models.UpstreamRegistryView, 0)
+	for rows.Next() {
+		var v models.UpstreamRegistryView
+		var createdAt, updatedAt sql.NullString
+
+		err = rows.Scan(&v.ID, &v.Name, &v.Description, &v.Vendor, &v.State, &v.Port, &v.UpstreamURL,
+			&v.CachedImagesCount, &createdAt, &updatedAt)
+		if err != nil {
+			log.Logger().Error().Err(err).Msg("Failed to scan upstream registry")
+			return nil, 0, fmt.Errorf("scan row: %w", err)
+		}
+
+		if createdAt.Valid {
+			createdTime, err := utils.ParseSqliteTimestamp(createdAt.String)
+			if err != nil {
+				log.Logger().Error().Err(err).Msg("failed to parse sqlite timestamp")
+				return nil, 0, fmt.Errorf("parse time: %w", err)
+			}
+			v.CreatedAt = *createdTime
+		}
+
+		if updatedAt.Valid {
+			v.UpdatedAt, err = utils.ParseSqliteTimestamp(updatedAt.String)
+			if err != nil {
+				log.Logger().Error().Err(err).Msg("failed to parse sqlite timestamp")
+				return nil, 0, fmt.Errorf("parse time: %w", err)
+			}
+		}
+
+		registries = append(registries, &v)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Logger().Error().Err(err).Msg("Error during row iteration")
+		return nil, 0, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return registries, total, nil
+}
+
 func (u *upstreamStore) DeleteRegistry(ctx context.Context, registryID string) error {
 	q := u.getQuerier(ctx)
 
@@ -124,10 +210,10 @@ func (u *upstreamStore) PersistRegistryAuthConfig(ctx context.Context, m *models
 func (u *upstreamStore) UpdateRegistryAuthConfig(ctx context.Context, m *models.UpstreamRegistryAuthConfig) error {
 	q := u.getQuerier(ctx)
 
-	_, err := q.ExecContext(ctx, UpstreamGetAuthConfigQuery, m.AuthType, m.ConfigJSON, m.RegistryID)
+	_, err := q.ExecContext(ctx, UpstreamUpdateAuthConfigQuery, m.AuthType, m.ConfigJSON, m.RegistryID)
 	if err != nil {
 		log.Logger().Error().Err(err).Msg("failed to update upstream registry auth config")
-		return dberrors.ClassifyError(err, UpstreamGetAuthConfigQuery)
+		return dberrors.ClassifyError(err, UpstreamUpdateAuthConfigQuery)
 	}
 
 	return nil
@@ -142,12 +228,20 @@ func (u *upstreamStore) GetRegistryAuthConfig(ctx context.Context, registryID st
 
 	err := q.QueryRowContext(ctx, UpstreamGetAuthConfigQuery, registryID).
 		Scan(&m.AuthType, &m.ConfigJSON, &createdAt, &updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve registry auth config")
+		return nil, dberrors.ClassifyError(err, UpstreamGetAuthConfigQuery)
+	}
+	m.RegistryID = registryID
 
 	if createdAt != "" {
 		createdTime, err := utils.ParseSqliteTimestamp(createdAt)
 		if err != nil {
 			log.Logger().Error().Err(err).Msg("failed to parse sqlite timestamp")
-			return nil, dberrors.ClassifyError(err, UpstreamGetCacheConfigQuery)
+			return nil, dberrors.ClassifyError(err, UpstreamGetAuthConfigQuery)
 		}
 		m.CreatedAt = *createdTime
 	}
@@ -156,7 +250,7 @@ func (u *upstreamStore) GetRegistryAuthConfig(ctx context.Context, registryID st
 		m.UpdatedAt, err = utils.ParseSqliteTimestamp(updatedAt)
 		if err != nil {
 			log.Logger().Error().Err(err).Msg("failed to parse sqlite timestamp")
-			return nil, dberrors.ClassifyError(err, UpstreamGetCacheConfigQuery)
+			return nil, dberrors.ClassifyError(err, UpstreamGetAuthConfigQuery)
 		}
 	}
 
@@ -170,7 +264,12 @@ func (u *upstreamStore) PersistRegistryCacheConfig(ctx context.Context, m *model
 	if m.CacheEnabled {
 		cacheEnabled = 1
 	}
-	_, err := q.ExecContext(ctx, UpstreamPersistCacheConfigQuery, m.RegistryID, cacheEnabled, m.TTLSeconds, m.StorageLimit, m.CleanupThreshold)
+	ttlRules, err := marshalTTLRules(m.TTLRules)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, UpstreamPersistCacheConfigQuery, m.RegistryID, cacheEnabled, m.TTLSeconds, ttlRules,
+		m.NegativeTTLSeconds, m.StorageLimit, m.CleanupThreshold)
 	if err != nil {
 		log.Logger().Error().Err(err).Msg("failed to persist upstream registry cache config")
 		return dberrors.ClassifyError(err, UpstreamPersistCacheConfigQuery)
@@ -186,8 +285,12 @@ func (u *upstreamStore) UpdateRegistryCacheConfig(ctx context.Context, m *models
 	if m.CacheEnabled {
 		cacheEnabled = 1
 	}
-	_, err := q.ExecContext(ctx, UpstreamUpdateCacheConfigQuery, cacheEnabled, m.TTLSeconds, m.StorageLimit,
-		m.CleanupThreshold, m.RegistryID)
+	ttlRules, err := marshalTTLRules(m.TTLRules)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, UpstreamUpdateCacheConfigQuery, cacheEnabled, m.TTLSeconds, ttlRules,
+		m.NegativeTTLSeconds, m.StorageLimit, m.CleanupThreshold, m.RegistryID)
 	if err != nil {
 		log.Logger().Error().Err(err).Msg("failed to update upstream registry cache config")
 		return dberrors.ClassifyError(err, UpstreamUpdateCacheConfigQuery)
@@ -200,11 +303,12 @@ func (u *upstreamStore) GetRegistryCacheConfig(ctx context.Context, registryID s
 	q := u.getQuerier(ctx)
 
 	var m models.UpstreamRegistryCacheStoreConfig
-	var createdAt, updatedAt string
+	var createdAt, updatedAt, ttlRules string
 	var cacheEnabled int
 
 	err := q.QueryRowContext(ctx, UpstreamGetCacheConfigQuery, registryID).
-		Scan(&cacheEnabled, &m.TTLSeconds, &m.StorageLimit, &m.CleanupThreshold, &createdAt, &updatedAt)
+		Scan(&cacheEnabled, &m.TTLSeconds, &ttlRules, &m.NegativeTTLSeconds, &m.StorageLimit, &m.CleanupThreshold,
+			&createdAt, &updatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -212,6 +316,7 @@ func (u *upstreamStore) GetRegistryCacheConfig(ctx context.Context, registryID s
 		log.Logger().Error().Err(err).Msg("failed to retrieve registry cache config")
 		return nil, dberrors.ClassifyError(err, UpstreamGetCacheConfigQuery)
 	}
+	m.RegistryID = registryID
 
 	if createdAt != "" {
 		createdTime, err := utils.ParseSqliteTimestamp(createdAt)
@@ -234,14 +339,44 @@ func (u *upstreamStore) GetRegistryCacheConfig(ctx context.Context, registryID s
 		m.CacheEnabled = true
 	}
 
+	err = json.Unmarshal([]byte(ttlRules), &m.TTLRules)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to parse ttl rules of registry cache config")
+		return nil, err
+	}
+
 	return &m, nil
 }
 
+// marshalTTLRules stores rules as a JSON array. nil rules are stored as an empty array.
+func marshalTTLRules(rules []models.CacheTTLRule) (string, error) {
+	if rules == nil {
+		rules = []models.CacheTTLRule{}
+	}
+
+	content, err := json.Marshal(rules)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to serialize ttl rules of registry cache config")
+		return "", err
+	}
+	return string(content), nil
+}
+
 func (u *upstreamStore) PersistRegistryNetworkConfig(ctx context.Context, m *models.UpstreamRegistryNetworkConfig) error {
 	q := u.getQuerier(ctx)
 
+	var proxyEnabled, insecureSkipVerify int
+	if m.ProxyEnabled {
+		proxyEnabled = 1
+	}
+	if m.TLSInsecureSkipVerify {
+		insecureSkipVerify = 1
+	}
+
 	_, err := q.ExecContext(ctx, UpstreamPersistNetworkConfigQuery, m.RegistryID, m.ConnectionTimeout, m.ReadTimeout,
-		m.WriteTimeout, m.MaxConnections, m.MaxIdleConnections, m.MaxRetries, m.RetryDelay, m.RetryBackOffMultiplier)
+		m.WriteTimeout, m.MaxConnections, m.MaxIdleConnections, m.MaxRetries, m.RetryDelay, m.RetryBackOffMultiplier,
+		proxyEnabled, m.ProxyURL, m.ProxyUsername, m.ProxyPassword, m.TLSCABundle, m.TLSClientCert, m.TLSClientKey,
+		insecureSkipVerify)
 	if err != nil {
 		log.Logger().Error().Err(err).Msg("failed to persist upstream registry network config")
 		return dberrors.ClassifyError(err, UpstreamPersistNetworkConfigQuery)
@@ -253,8 +388,18 @@ func (u *upstreamStore) PersistRegistryNetworkConfig(ctx context.Context, m *mod
 func (u *upstreamStore) UpdateRegistryNetworkConfig(ctx context.Context, m *models.UpstreamRegistryNetworkConfig) error {
 	q := u.getQuerier(ctx)
 
+	var proxyEnabled, insecureSkipVerify int
+	if m.ProxyEnabled {
+		proxyEnabled = 1
+	}
+	if m.TLSInsecureSkipVerify {
+		insecureSkipVerify = 1
+	}
+
 	_, err := q.ExecContext(ctx, UpstreamUpdateNetworkConfigQuery, m.ConnectionTimeout, m.ReadTimeout, m.WriteTimeout,
-		m.MaxConnections, m.MaxIdleConnections, m.MaxRetries, m.RetryDelay, m.RetryBackOffMultiplier, m.RegistryID)
+		m.MaxConnections, m.MaxIdleConnections, m.MaxRetries, m.RetryDelay, m.RetryBackOffMultiplier, proxyEnabled,
+		m.ProxyURL, m.ProxyUsername, m.ProxyPassword, m.TLSCABundle, m.TLSClientCert, m.TLSClientKey, insecureSkipVerify,
+		m.RegistryID)
 	if err != nil {
 		log.Logger().Error().Err(err).Msg("failed to update upstream registry network config")
 		return dberrors.ClassifyError(err, UpstreamUpdateNetworkConfigQuery)
@@ -267,9 +412,12 @@ func (u *upstreamStore) GetRegistryNetworkConfig(ctx context.Context, registryID
 	q := u.getQuerier(ctx)
 	var m models.UpstreamRegistryNetworkConfig
 	var createdAt, updatedAt string
+	var proxyEnabled, insecureSkipVerify int
 
 	err := q.QueryRowContext(ctx, UpstreamGetNetworkConfigQuery, registryID).Scan(&m.ConnectionTimeout, &m.ReadTimeout, &m.WriteTimeout, &m.MaxConnections,
-		&m.MaxIdleConnections, &m.MaxRetries, &m.RetryDelay, &m.RetryBackOffMultiplier, &createdAt, &updatedAt)
+		&m.MaxIdleConnections, &m.MaxRetries, &m.RetryDelay, &m.RetryBackOffMultiplier, &proxyEnabled, &m.ProxyURL,
+		&m.ProxyUsername, &m.ProxyPassword, &m.TLSCABundle, &m.TLSClientCert, &m.TLSClientKey, &insecureSkipVerify,
+		&createdAt, &updatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -277,6 +425,7 @@ func (u *upstreamStore) GetRegistryNetworkConfig(ctx context.Context, registryID
 		log.Logger().Error().Err(err).Msg("failed to retrieve network config")
 		return nil, dberrors.ClassifyError(err, UpstreamGetNetworkConfigQuery)
 	}
+	m.RegistryID = registryID
 
 	if createdAt != "" {
 		createdTime, err := utils.ParseSqliteTimestamp(createdAt)
@@ -295,6 +444,9 @@ func (u *upstreamStore) GetRegistryNetworkConfig(ctx context.Context, registryID
 		}
 	}
 
+	m.ProxyEnabled = proxyEnabled == 1
+	m.TLSInsecureSkipVerify = insecureSkipVerify == 1
+
 	return &m, nil
 }
 
@@ -317,6 +469,7 @@ func (u *upstreamStore) GetAllUpstreamRegistryAddresses(ctx context.Context) (ad
 			log.Logger().Error().Err(err).Msg("failed to read upstream addresses")
 			return nil, dberrors.ClassifyError(err, UpstreamGetAllAddresses)
 		}
+		addresses = append(addresses, &addr)
 	}
 	return addresses, nil
 }
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ksankeerth/open-image-registry/constants"
 	"github.com/ksankeerth/open-image-registry/errors/dberrors"
 	"github.com/ksankeerth/open-image-registry/log"
 	"github.com/ksankeerth/open-image-registry/store"
@@ -44,6 +45,71 @@ func (u *userStore) Create(ctx context.Context, username, email, displayName, pa
 	return id, nil
 }
 
+func (u *userStore) CreateMachine(ctx context.Context, username, displayName string) (id string, err error) {
+	q := u.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, UserCreateMachineAccountQuery,
+		username, displayName, constants.PasswordNotSet, constants.SaltNotSet,
+	).Scan(&id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to create machine account")
+		return "", dberrors.ClassifyError(err, UserCreateMachineAccountQuery)
+	}
+	return id, nil
+}
+
+func (u *userStore) CreateExternal(ctx context.Context, username, email, displayName string) (id string, err error) {
+	q := u.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, UserCreateExternalAccountQuery,
+		username, email, displayName, constants.PasswordNotSet, constants.SaltNotSet,
+	).Scan(&id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to create external account")
+		return "", dberrors.ClassifyError(err, UserCreateExternalAccountQuery)
+	}
+	return id, nil
+}
+
+func (u *userStore) LinkIdentity(ctx context.Context, userId, provider, subject string) error {
+	q := u.getQuerier(ctx)
+
+	_, err := q.ExecContext(ctx, UserLinkIdentityQuery, userId, provider, subject)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("failed to link identity of user")
+		return dberrors.ClassifyError(err, UserLinkIdentityQuery)
+	}
+	return nil
+}
+
+func (u *userStore) GetIDByIdentity(ctx context.Context, provider, subject string) (id string, err error) {
+	q := u.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, UserGetIDByIdentityQuery, provider, subject).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve user by identity")
+		return "", dberrors.ClassifyError(err, UserGetIDByIdentityQuery)
+	}
+	return id, nil
+}
+
+func (u *userStore) GetIdentityProvider(ctx context.Context, userId string) (provider string, err error) {
+	q := u.getQuerier(ctx)
+
+	err = q.QueryRowContext(ctx, UserGetIdentityProviderQuery, userId).Scan(&provider)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		log.Logger().Error().Err(err).Msg("failed to retrieve identity provider of user")
+		return "", dberrors.ClassifyError(err, UserGetIdentityProviderQuery)
+	}
+	return provider, nil
+}
+
 func (u *userStore) Delete(ctx context.Context, userId string) error {
 	q := u.getQuerier(ctx)
 
@@ -173,7 +239,7 @@ func (u *userStore) Get(ctx context.Context, identifier string) (*models.UserAcc
 	err := row.Scan(
 		&m.Id, &m.Username, &m.Email, &m.DisplayName,
 		&locked, &m.LockedReason, &m.FailedAttempts,
-		&createdAt, &updatedAt, &lockedAt)
+		&createdAt, &updatedAt, &lockedAt, &m.AccountType)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -225,7 +291,7 @@ func (u *userStore) GetByUsername(ctx context.Context, username string) (*models
 	err := row.Scan(
 		&m.Id, &m.Username, &m.Email, &m.DisplayName,
 		&locked, &m.LockedReason, &m.FailedAttempts,
-		&createdAt, &updatedAt, &lockedAt)
+		&createdAt, &updatedAt, &lockedAt, &m.AccountType)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
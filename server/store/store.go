@@ -17,6 +17,12 @@ type Store interface {
 	AccountRecovery() AccountRecoveryStore
 	Auth() AuthStore
 	Upstreams() UpstreamRegistyStore
+	Groups() GroupRegistryStore
+	Replications() ReplicationStore
+	SyncJobs() UpstreamSyncJobStore
+	AccessTokens() AccessTokenStore
+	TwoFactor() TwoFactorStore
+	SigningKeys() SigningKeyStore
 
 	// Queries
 	ImageQueries() ImageQueries
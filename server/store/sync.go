@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+type UpstreamSyncJobStore interface {
+	CreateJob(ctx context.Context, m *models.UpstreamSyncJob) (id string, err error)
+
+	UpdateJob(ctx context.Context, m *models.UpstreamSyncJob) error
+
+	GetJob(ctx context.Context, jobID string) (*models.UpstreamSyncJob, error)
+
+	GetJobByName(ctx context.Context, registryID, name string) (*models.UpstreamSyncJob, error)
+
+	ListJobs(ctx context.Context, conditions *ListQueryConditions) (jobs []*models.UpstreamSyncJob, total int, err error)
+
+	DeleteJob(ctx context.Context, jobID string) error
+
+	// DeleteJobsOfRegistry deletes all jobs of the upstream.
+	DeleteJobsOfRegistry(ctx context.Context, registryID string) error
+
+	// GetDueJobs returns enabled scheduled jobs whose interval has elapsed since the last run.
+	GetDueJobs(ctx context.Context) ([]*models.UpstreamSyncJob, error)
+
+	// MarkJobRunning marks the job as running. started is false if the job is already running.
+	MarkJobRunning(ctx context.Context, jobID string) (started bool, err error)
+
+	// RecordJobResult records the result of the last run of the job.
+	RecordJobResult(ctx context.Context, jobID, status, message string, syncedImages int) error
+
+	// ResetRunningJobs marks jobs which were interrupted(e.g. by a restart) as failed.
+	ResetRunningJobs(ctx context.Context) error
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"context"
+
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+type AccessTokenStore interface {
+	// CreateToken persists the token. expiryDays is 0 if the token never expires.
+	CreateToken(ctx context.Context, m *models.PersonalAccessToken, expiryDays int) (id string, err error)
+
+	GetToken(ctx context.Context, userID, tokenID string) (*models.PersonalAccessToken, error)
+
+	GetTokenByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error)
+
+	// GetActiveTokenByName returns the token of the user with the given name which is not revoked.
+	GetActiveTokenByName(ctx context.Context, userID, name string) (*models.PersonalAccessToken, error)
+
+	ListTokens(ctx context.Context, userID string) ([]*models.PersonalAccessToken, error)
+
+	RevokeToken(ctx context.Context, tokenID string) error
+
+	RecordTokenUsage(ctx context.Context, tokenID string) error
+}
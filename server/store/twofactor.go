@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+
+	"github.com/ksankeerth/open-image-registry/types/models"
+)
+
+type TwoFactorStore interface {
+	// SaveEnrollment replaces the enrollment of the user with a pending enrollment of the secret
+	SaveEnrollment(ctx context.Context, userID, secret string) error
+
+	GetEnrollment(ctx context.Context, userID string) (*models.TwoFactorEnrollment, error)
+
+	EnableEnrollment(ctx context.Context, userID string) error
+
+	// RecordUsedStep records the time step of an accepted code. recorded is false if the step or a later one was
+	// already used.
+	RecordUsedStep(ctx context.Context, userID string, step int64) (recorded bool, err error)
+
+	// DeleteEnrollment deletes the enrollment and recovery codes of the user
+	DeleteEnrollment(ctx context.Context, userID string) (deleted bool, err error)
+
+	// ReplaceRecoveryCodes replaces recovery codes of the user with the hashes
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+
+	// UseRecoveryCode marks the recovery code as used. used is false if the code doesn't exist or was already used.
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (used bool, err error)
+
+	CountUnusedRecoveryCodes(ctx context.Context, userID string) (int, error)
+
+	// CreateChallenge persists a login waiting for the second factor. It expires after expirySeconds.
+	CreateChallenge(ctx context.Context, m *models.TwoFactorChallenge, expirySeconds int) error
+
+	// GetChallenge returns the challenge if it is not expired
+	GetChallenge(ctx context.Context, tokenHash string) (*models.TwoFactorChallenge, error)
+
+	DeleteChallenge(ctx context.Context, tokenHash string) error
+
+	DeleteUserChallenges(ctx context.Context, userID string) error
+
+	DeleteExpiredChallenges(ctx context.Context) error
+
+	IsRequiredForRole(ctx context.Context, role string) (bool, error)
+
+	ListRolePolicies(ctx context.Context) ([]*models.RoleTwoFactorPolicy, error)
+
+	// SetRequiredForRole updates the policy of the role. found is false if the role doesn't exist.
+	SetRequiredForRole(ctx context.Context, role string, required bool) (found bool, err error)
+}
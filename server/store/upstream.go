@@ -13,6 +13,11 @@ type UpstreamRegistyStore interface {
 
 	GetRegistry(ctx context.Context, registryID string) (*models.UpstreamRegistry, error)
 
+	GetRegistryByName(ctx context.Context, name string) (*models.UpstreamRegistry, error)
+
+	ListRegistries(ctx context.Context, conditions *ListQueryConditions) (registries []*models.UpstreamRegistryView,
+		total int, err error)
+
 	DeleteRegistry(ctx context.Context, registryID string) error
 
 	ChangeRegistryState(ctx context.Context, registryID, state string) error
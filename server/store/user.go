@@ -10,6 +10,24 @@ import (
 type UserStore interface {
 	Create(ctx context.Context, username, email, displayName, password, salt string) (id string, err error)
 
+	// CreateMachine creates an unlocked machine account. Machine accounts don't have an email or a password.
+	CreateMachine(ctx context.Context, username, displayName string) (id string, err error)
+
+	// CreateExternal creates an unlocked account of an external identity provider. External accounts don't have a
+	// password.
+	CreateExternal(ctx context.Context, username, email, displayName string) (id string, err error)
+
+	// LinkIdentity links the account to the subject of the identity provider. Existing link of the subject is replaced.
+	LinkIdentity(ctx context.Context, userId, provider, subject string) error
+
+	// GetIDByIdentity returns the account linked to the subject of the identity provider. It returns an empty id
+	// if there is no such account.
+	GetIDByIdentity(ctx context.Context, provider, subject string) (id string, err error)
+
+	// GetIdentityProvider returns the identity provider of the account. It returns an empty string for local
+	// accounts.
+	GetIdentityProvider(ctx context.Context, userId string) (provider string, err error)
+
 	Delete(ctx context.Context, userId string) (err error)
 
 	Update(ctx context.Context, userId, displayName string) error
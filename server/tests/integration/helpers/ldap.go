@@ -0,0 +1,385 @@
+package helpers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/client/ldap/ber"
+)
+
+// LDAP protocol operations used by the mock server (RFC 4511)
+const (
+	ldapBindRequest       = 0
+	ldapBindResponse      = 1
+	ldapUnbindRequest     = 2
+	ldapSearchRequest     = 3
+	ldapSearchResultEntry = 4
+	ldapSearchResultDone  = 5
+	ldapExtendedRequest   = 23
+	ldapExtendedResponse  = 24
+	ldapStartTLSOID       = "1.3.6.1.4.1.1466.20037"
+)
+
+// LDAP result codes
+const (
+	ldapSuccess            = 0
+	ldapProtocolError      = 2
+	ldapSizeLimitExceeded  = 4
+	ldapInvalidCredentials = 49
+	ldapInsufficientAccess = 50
+	ldapUnwillingToPerform = 53
+)
+
+// LDAP search filters and scopes
+const (
+	ldapFilterAnd             = 0
+	ldapFilterOr              = 1
+	ldapFilterNot             = 2
+	ldapFilterEqualityMatch   = 3
+	ldapFilterPresent         = 7
+	ldapScopeBaseObject       = 0
+	ldapScopeSingleLevel      = 1
+	ldapSearchRequestChildren = 8
+)
+
+// LDAPEntry is an entry of the mock LDAP server. Entries with a password can bind.
+type LDAPEntry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// MockLDAPServer is an in-process LDAP server for tests. It supports simple bind, search with and, or, not, equality
+// and presence filters, StartTLS and LDAPS. Only bound entries can search, like most directories.
+type MockLDAPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	caCertPEM []byte
+	ldaps     bool
+
+	mu      sync.Mutex
+	entries []*LDAPEntry
+}
+
+// NewMockLDAPServer starts the server on a random port. If ldaps is true, connections are encrypted from the start.
+// Otherwise clients can encrypt them with StartTLS.
+func NewMockLDAPServer(ldaps bool) (*MockLDAPServer, error) {
+	cert, certPEM, err := newSelfSignedCert()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &MockLDAPServer{
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		caCertPEM: certPEM,
+		ldaps:     ldaps,
+	}
+
+	if ldaps {
+		s.listener, err = tls.Listen("tcp", "127.0.0.1:0", s.tlsConfig)
+	} else {
+		s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	go s.serve()
+	return s, nil
+}
+
+// URL returns ldap:// or ldaps:// url of the server
+func (s *MockLDAPServer) URL() string {
+	if s.ldaps {
+		return "ldaps://" + s.listener.Addr().String()
+	}
+	return "ldap://" + s.listener.Addr().String()
+}
+
+// CACertPEM returns the self-signed certificate of the server
+func (s *MockLDAPServer) CACertPEM() []byte {
+	return s.caCertPEM
+}
+
+func (s *MockLDAPServer) AddEntry(entry *LDAPEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+}
+
+// SetPassword changes the password of the entry
+func (s *MockLDAPServer) SetPassword(dn, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) {
+			entry.Password = password
+		}
+	}
+}
+
+// SetAttribute replaces values of the attribute of the entry
+func (s *MockLDAPServer) SetAttribute(dn, attribute string, values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) {
+			entry.Attributes[attribute] = values
+		}
+	}
+}
+
+func (s *MockLDAPServer) Close() {
+	s.listener.Close()
+}
+
+func (s *MockLDAPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *MockLDAPServer) handle(conn net.Conn) {
+	// conn is replaced by the TLS connection after StartTLS
+	defer func() {
+		conn.Close()
+	}()
+
+	bound := false
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		msgID, err := packet.Children[0].Int()
+		if err != nil {
+			return
+		}
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldapBindRequest:
+			code := s.bind(op)
+			bound = code == ldapSuccess && len(op.Children) == 3 && len(op.Children[2].Data) > 0
+			writeLDAPResult(conn, msgID, ldapBindResponse, code, "")
+		case ldapUnbindRequest:
+			return
+		case ldapSearchRequest:
+			if !bound {
+				writeLDAPResult(conn, msgID, ldapSearchResultDone, ldapInsufficientAccess, "bind required")
+				continue
+			}
+			s.search(conn, msgID, op)
+		case ldapExtendedRequest:
+			if s.ldaps || len(op.Children) == 0 || op.Children[0].String() != ldapStartTLSOID {
+				writeLDAPResult(conn, msgID, ldapExtendedResponse, ldapUnwillingToPerform, "")
+				continue
+			}
+			writeLDAPResult(conn, msgID, ldapExtendedResponse, ldapSuccess, "")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn = tlsConn
+		default:
+			writeLDAPResult(conn, msgID, op.Tag+1, ldapProtocolError, "unsupported operation")
+		}
+	}
+}
+
+// bind returns result code of the simple bind. A bind without password is an anonymous bind.
+func (s *MockLDAPServer) bind(op *ber.Packet) int64 {
+	if len(op.Children) != 3 || op.Children[2].Tag != 0 {
+		return ldapProtocolError
+	}
+	dn := op.Children[1].String()
+	password := op.Children[2].String()
+	if password == "" {
+		return ldapSuccess
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) && entry.Password != "" && entry.Password == password {
+			return ldapSuccess
+		}
+	}
+	return ldapInvalidCredentials
+}
+
+func (s *MockLDAPServer) search(conn net.Conn, msgID int64, op *ber.Packet) {
+	if len(op.Children) != ldapSearchRequestChildren {
+		writeLDAPResult(conn, msgID, ldapSearchResultDone, ldapProtocolError, "")
+		return
+	}
+	baseDN := op.Children[0].String()
+	scope, _ := op.Children[1].Int()
+	sizeLimit, _ := op.Children[3].Int()
+	filter := op.Children[6]
+	var attributes []string
+	for _, attr := range op.Children[7].Children {
+		attributes = append(attributes, attr.String())
+	}
+
+	s.mu.Lock()
+	var matched []*LDAPEntry
+	for _, entry := range s.entries {
+		if inScope(entry.DN, baseDN, scope) && matchFilter(entry, filter) {
+			matched = append(matched, entry)
+		}
+	}
+	s.mu.Unlock()
+
+	code := int64(ldapSuccess)
+	if sizeLimit > 0 && int64(len(matched)) > sizeLimit {
+		matched = matched[:sizeLimit]
+		code = ldapSizeLimitExceeded
+	}
+
+	for _, entry := range matched {
+		conn.Write(searchResultEntry(msgID, entry, attributes).Bytes())
+	}
+	writeLDAPResult(conn, msgID, ldapSearchResultDone, code, "")
+}
+
+func inScope(dn, baseDN string, scope int64) bool {
+	dn, baseDN = strings.ToLower(dn), strings.ToLower(baseDN)
+	switch scope {
+	case ldapScopeBaseObject:
+		return dn == baseDN
+	case ldapScopeSingleLevel:
+		_, parent, found := strings.Cut(dn, ",")
+		return found && parent == baseDN
+	default:
+		return dn == baseDN || strings.HasSuffix(dn, ","+baseDN)
+	}
+}
+
+func matchFilter(entry *LDAPEntry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldapFilterAnd:
+		for _, child := range filter.Children {
+			if !matchFilter(entry, child) {
+				return false
+			}
+		}
+		return true
+	case ldapFilterOr:
+		for _, child := range filter.Children {
+			if matchFilter(entry, child) {
+				return true
+			}
+		}
+		return false
+	case ldapFilterNot:
+		return len(filter.Children) == 1 && !matchFilter(entry, filter.Children[0])
+	case ldapFilterEqualityMatch:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		attribute := filter.Children[0].String()
+		value := filter.Children[1].String()
+		for _, v := range attributeValues(entry, attribute) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case ldapFilterPresent:
+		return len(attributeValues(entry, filter.String())) > 0
+	}
+	return false
+}
+
+func attributeValues(entry *LDAPEntry, attribute string) []string {
+	for name, values := range entry.Attributes {
+		if strings.EqualFold(name, attribute) {
+			return values
+		}
+	}
+	return nil
+}
+
+func searchResultEntry(msgID int64, entry *LDAPEntry, attributes []string) *ber.Packet {
+	res := ber.NewConstructed(ber.ClassApplication, ldapSearchResultEntry,
+		ber.NewString(ber.ClassUniversal, ber.TagOctetString, entry.DN))
+
+	attrs := ber.NewSequence()
+	for name, values := range entry.Attributes {
+		if len(attributes) > 0 && !containsFold(attributes, name) {
+			continue
+		}
+		vals := ber.NewConstructed(ber.ClassUniversal, ber.TagSet)
+		for _, value := range values {
+			vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TagOctetString, value))
+		}
+		attrs.AppendChild(ber.NewSequence(ber.NewString(ber.ClassUniversal, ber.TagOctetString, name), vals))
+	}
+	res.AppendChild(attrs)
+
+	return ldapMessage(msgID, res)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeLDAPResult(conn net.Conn, msgID int64, op int, code int64, message string) {
+	res := ber.NewConstructed(ber.ClassApplication, op,
+		ber.NewInteger(ber.ClassUniversal, ber.TagEnumerated, code),
+		ber.NewString(ber.ClassUniversal, ber.TagOctetString, ""),
+		ber.NewString(ber.ClassUniversal, ber.TagOctetString, message),
+	)
+	conn.Write(ldapMessage(msgID, res).Bytes())
+}
+
+func ldapMessage(msgID int64, op *ber.Packet) *ber.Packet {
+	return ber.NewSequence(ber.NewInteger(ber.ClassUniversal, ber.TagInteger, msgID), op)
+}
+
+// newSelfSignedCert returns a certificate for 127.0.0.1 and its PEM encoding
+func newSelfSignedCert() (tls.Certificate, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mock-ldap"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http/httptest"
@@ -10,9 +11,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ksankeerth/open-image-registry/auth/keys"
+	"github.com/ksankeerth/open-image-registry/auth/revocation"
 	"github.com/ksankeerth/open-image-registry/client/email"
 	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/constants"
 	"github.com/ksankeerth/open-image-registry/lib"
+	"github.com/ksankeerth/open-image-registry/middleware"
+	"github.com/ksankeerth/open-image-registry/registry"
+	"github.com/ksankeerth/open-image-registry/replication"
 	"github.com/ksankeerth/open-image-registry/resource/access"
 	"github.com/ksankeerth/open-image-registry/rest"
 	"github.com/ksankeerth/open-image-registry/storage"
@@ -25,12 +32,15 @@ import (
 
 var (
 	testServer      *httptest.Server
+	registryServer  *httptest.Server
 	testStore       store.Store
 	testConfig      *config.AppConfig
 	testBaseURL     string
 	testEmailClient *email.EmailClient
 	tempDir         string
 	jwtProvider     lib.JWTProvider
+	oidcProvider    *helpers.MockOIDCProvider
+	ldapServer      *helpers.MockLDAPServer
 )
 
 func TestMain(m *testing.M) {
@@ -75,6 +85,20 @@ func TestIntegrationV1(t *testing.T) {
 		v1.NewAuthTestSuite(seeder, testBaseURL),
 		v1.NewNamespaceTestSuite(seeder, testBaseURL),
 		v1.NewRepositorySuite(seeder, testBaseURL),
+		v1.NewHostedRegistryTestSuite(seeder, testBaseURL, registryServer.URL),
+		v1.NewUpstreamTestSuite(seeder, testBaseURL),
+		v1.NewGroupTestSuite(seeder, testBaseURL),
+		v1.NewReplicationTestSuite(seeder, testBaseURL),
+		v1.NewUpstreamSyncTestSuite(seeder, testBaseURL),
+		v1.NewUpstreamCacheTestSuite(seeder, testBaseURL),
+		v1.NewAccessTokenTestSuite(seeder, testBaseURL),
+		v1.NewMachineTestSuite(seeder, testBaseURL, registryServer.URL),
+		v1.NewOIDCTestSuite(seeder, testBaseURL, oidcProvider),
+		v1.NewLDAPTestSuite(seeder, testBaseURL, ldapServer),
+		v1.NewTwoFactorTestSuite(seeder, testBaseURL),
+		v1.NewPasswordResetTestSuite(seeder, testBaseURL),
+		v1.NewSigningKeyTestSuite(seeder, testBaseURL),
+		v1.NewRevocationTestSuite(seeder, testBaseURL),
 	}
 
 	for _, suite := range suites {
@@ -153,16 +177,122 @@ func setupTestEnvironment() error {
 	accessManager := access.NewManager(store)
 
 	authConfig := appConfig.Security.AuthToken
-	jwtAuth := lib.NewOAuthEC256JWTAuthenticator(authConfig.GetPrivateKey(), authConfig.GetPublicKey(), authConfig.Issuer,
+	jwtAuth, err := lib.NewOAuthJWTAuthenticator(authConfig.Algorithm, authConfig.GetPrivateKey(), authConfig.Issuer,
 		time.Duration(authConfig.Expiry)*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to create jwt authenticator: %w", err)
+	}
 
 	jwtProvider = jwtAuth
 
+	keyManager := keys.NewManager(store, jwtAuth, time.Duration(authConfig.RetiredKeyGrace)*time.Second)
+	if err := keyManager.Init(context.Background(), &authConfig); err != nil {
+		return fmt.Errorf("failed to initialize signing keys: %w", err)
+	}
+
+	revocations := revocation.NewCache(store, time.Duration(authConfig.RevocationReload)*time.Second)
+	if err := revocations.Load(context.Background()); err != nil {
+		return fmt.Errorf("failed to load revoked tokens: %w", err)
+	}
+
 	log.Println("├─ Creating HTTP server...")
-	appRouter := rest.AppRouter(&appConfig.WebApp, store, jwtAuth, accessManager, testEmailClient)
+	upstreamListeners := registry.NewUpstreamListenerController(store, appConfig.ImageRegistry.Routing.IsSinglePort())
+	hostedRegistry := registry.NewRegistryHandler(constants.HostedRegistryID, constants.HostedRegistryName, store)
+	groupListeners := registry.NewGroupListenerController(store, hostedRegistry, upstreamListeners,
+		appConfig.ImageRegistry.Routing.IsSinglePort())
+
+	registryAuthenticator := middleware.NewAuthenticator(store, jwtAuth, revocations)
+	upstreamListeners.SetAuthenticator(registryAuthenticator.AuthenticateRegistry)
+	groupListeners.SetAuthenticator(registryAuthenticator.AuthenticateRegistry)
+
+	// hosted registry, upstreams and groups are served on a single port as in single port mode
+	singlePortRouter := registry.NewSinglePortRouter(hostedRegistry.Routes(), upstreamListeners, groupListeners,
+		config.RegistryRoutingConfig{})
+	singlePortRouter.SetAuthenticator(registryAuthenticator.AuthenticateRegistry)
+	registryServer = httptest.NewServer(singlePortRouter)
+
+	replicator := replication.NewReplicator(store, hostedRegistry, config.GetReplicationConfig())
+	hostedRegistry.SetPushObserver(replicator)
+	replicator.Start(context.Background())
+
+	syncScheduler := registry.NewSyncScheduler(store, upstreamListeners, config.GetUpstreamSyncConfig())
+	syncScheduler.Start(context.Background())
+
+	revocations.Start(context.Background())
+
+	log.Println("├─ Starting mock OIDC provider...")
+	oidcProvider, err = helpers.NewMockOIDCProvider("open-image-registry", "oidc-client-secret")
+	if err != nil {
+		return fmt.Errorf("failed to start mock OIDC provider: %w", err)
+	}
+	appConfig.Security.OIDC = config.OIDCConfig{
+		Enabled:        true,
+		Issuer:         oidcProvider.Issuer(),
+		ClientID:       oidcProvider.ClientID,
+		ClientSecret:   oidcProvider.ClientSecret,
+		Scopes:         constants.DefaultOIDCScopes,
+		TimeoutSeconds: constants.DefaultOIDCTimeout,
+		UsernameClaim:  constants.DefaultOIDCUsernameClaim,
+		RoleClaim:      constants.DefaultOIDCGroupsClaim,
+		RoleMapping: map[string]string{
+			"registry-admins":     constants.RoleAdmin,
+			"registry-developers": constants.RoleDeveloper,
+		},
+		DefaultRole: constants.RoleGuest,
+		GroupsClaim: constants.DefaultOIDCGroupsClaim,
+		NamespaceMapping: map[string][]config.OIDCNamespaceAccess{
+			"team-sso": {{Namespace: "sso-team", AccessLevel: constants.AccessLevelDeveloper}},
+		},
+		PostLoginRedirect: "/",
+	}
+	log.Printf("├─ Mock OIDC provider ready at: %s", oidcProvider.Issuer())
+
+	log.Println("├─ Starting mock LDAP server...")
+	ldapServer, err = helpers.NewMockLDAPServer(false)
+	if err != nil {
+		return fmt.Errorf("failed to start mock LDAP server: %w", err)
+	}
+	ldapServer.AddEntry(&helpers.LDAPEntry{
+		DN:         "cn=registry,ou=services,dc=example,dc=com",
+		Password:   "ldap-service-secret",
+		Attributes: map[string][]string{"objectClass": {"organizationalRole"}, "cn": {"registry"}},
+	})
+	ldapCAFile := filepath.Join(filepath.Dir(appConfig.Storage.Path), "ldap-ca.pem")
+	if err := os.WriteFile(ldapCAFile, ldapServer.CACertPEM(), 0600); err != nil {
+		return fmt.Errorf("failed to write LDAP CA certificate: %w", err)
+	}
+	appConfig.Security.LDAP = config.LDAPConfig{
+		Enabled:              true,
+		URL:                  ldapServer.URL(),
+		StartTLS:             true,
+		CAFile:               ldapCAFile,
+		TimeoutSeconds:       constants.DefaultLDAPTimeout,
+		BindDN:               "cn=registry,ou=services,dc=example,dc=com",
+		BindPassword:         "ldap-service-secret",
+		BaseDN:               "ou=people,dc=example,dc=com",
+		UserFilter:           "(&(objectClass=inetOrgPerson)(uid=%s))",
+		UsernameAttribute:    constants.DefaultLDAPUsernameAttribute,
+		EmailAttribute:       constants.DefaultLDAPEmailAttribute,
+		DisplayNameAttribute: constants.DefaultLDAPDisplayNameAttribute,
+		IDAttribute:          "entryUUID",
+		GroupBaseDN:          "ou=groups,dc=example,dc=com",
+		GroupFilter:          "(&(objectClass=groupOfNames)(member=%s))",
+		GroupNameAttribute:   constants.DefaultLDAPGroupNameAttribute,
+		RoleMapping: map[string]string{
+			"registry-admins":     constants.RoleAdmin,
+			"registry-developers": constants.RoleDeveloper,
+		},
+		DefaultRole: constants.RoleGuest,
+	}
+	log.Printf("├─ Mock LDAP server ready at: %s", ldapServer.URL())
+
+	appRouter := rest.AppRouter(&appConfig.WebApp, store, jwtAuth, accessManager, testEmailClient, upstreamListeners,
+		groupListeners, replicator, syncScheduler, keyManager, revocations)
 
 	testServer = httptest.NewServer(appRouter)
 	testBaseURL = testServer.URL
+	// redirect url is known only after the server is started. It is read when a login starts.
+	appConfig.Security.OIDC.RedirectURL = testBaseURL + "/api/v1/auth/oidc/callback"
 
 	if err := helpers.WaitForServer(testBaseURL, 10*time.Second); err != nil {
 		return fmt.Errorf("server failed to start: %w", err)
@@ -180,6 +310,15 @@ func teardownTestEnvironment() error {
 			testServer.Close()
 		}
 	}
+	if registryServer != nil {
+		registryServer.Close()
+	}
+	if oidcProvider != nil {
+		oidcProvider.Close()
+	}
+	if ldapServer != nil {
+		ldapServer.Close()
+	}
 
 	if testConfig == nil {
 		return nil
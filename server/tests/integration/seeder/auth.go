@@ -0,0 +1,81 @@
+package seeder
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/lib"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// RevokeToken records the revocation of the token in the database only, like another instance of the registry
+func (s *TestDataSeeder) RevokeToken(t *testing.T, token, userID string, expiresAt time.Time) {
+	t.Helper()
+
+	err := s.store.Auth().RecordTokenRevocation(context.Background(), &models.RevokedToken{
+		SignatureHash: tokenSignatureHash(t, token),
+		UserID:        userID,
+		ExpiresAt:     expiresAt.Unix(),
+		IssuedAt:      time.Now().Unix(),
+	})
+	require.NoError(t, err)
+}
+
+// IsTokenRevocationStored returns whether the database has the revocation of the token
+func (s *TestDataSeeder) IsTokenRevocationStored(t *testing.T, token string) bool {
+	t.Helper()
+
+	revokedToken, err := s.store.Auth().GetRevokedToken(context.Background(), tokenSignatureHash(t, token))
+	require.NoError(t, err)
+	return revokedToken != nil
+}
+
+// PurgeExpiredRevocations deletes revocations of expired tokens like the periodic purge job
+func (s *TestDataSeeder) PurgeExpiredRevocations(t *testing.T) (count int64) {
+	t.Helper()
+
+	count, err := s.store.Auth().DeleteExpiredRevokedTokens(context.Background(), time.Now().Unix())
+	require.NoError(t, err)
+	return count
+}
+
+// RotateSigningKey rotates the signing key in the database only, like another instance of the registry. The returned
+// authenticator signs tokens with the new key.
+func (s *TestDataSeeder) RotateSigningKey(t *testing.T, algorithm, issuer string) *lib.OAuthJWTAuthenticator {
+	t.Helper()
+
+	privateKey, err := lib.GenerateKey(algorithm)
+	require.NoError(t, err)
+	kid, err := lib.KeyID(privateKey.Public())
+	require.NoError(t, err)
+	privateKeyPEM, err := lib.MarshalPrivateKey(privateKey)
+	require.NoError(t, err)
+	publicKeyPEM, err := lib.MarshalPublicKey(privateKey.Public())
+	require.NoError(t, err)
+
+	require.NoError(t, s.store.SigningKeys().RetireActive(context.Background()))
+	err = s.store.SigningKeys().Create(context.Background(), &models.SigningKey{
+		KeyID:      kid,
+		Algorithm:  algorithm,
+		PrivateKey: privateKeyPEM,
+		PublicKey:  publicKeyPEM,
+		Active:     true,
+	})
+	require.NoError(t, err)
+
+	authenticator, err := lib.NewOAuthJWTAuthenticator(algorithm, privateKey, issuer, 10*time.Minute)
+	require.NoError(t, err)
+	return authenticator
+}
+
+func tokenSignatureHash(t *testing.T, token string) string {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+	return utils.CalcuateDigest([]byte(parts[2]))
+}
@@ -61,3 +61,18 @@ func (s *TestDataSeeder) SetRepositoryDisabled(t *testing.T, id string) {
 	err := s.store.Repositories().SetState(context.Background(), id, "Disabled")
 	require.NoError(t, err)
 }
+
+// RepositoryIDOf returns ID of the repository in the registry. Empty string is returned if it doesn't exist.
+func (s *TestDataSeeder) RepositoryIDOf(t *testing.T, regID, namespace, repository string) string {
+	t.Helper()
+
+	nsID, err := s.store.Namespaces().GetID(context.Background(), regID, namespace)
+	require.NoError(t, err)
+	if nsID == "" {
+		return ""
+	}
+
+	repoID, err := s.store.Repositories().GetID(context.Background(), nsID, repository)
+	require.NoError(t, err)
+	return repoID
+}
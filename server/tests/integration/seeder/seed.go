@@ -33,4 +33,16 @@ func (s *TestDataSeeder) AdminToken(t *testing.T) string {
 	require.NoError(t, err)
 
 	return token
-}
\ No newline at end of file
+}
+
+func (s *TestDataSeeder) UserToken(t *testing.T, username, role string) string {
+	t.Helper()
+
+	token, err := s.jwtProvider.Sign(map[string]any{
+		constants.ClaimRole:    role,
+		constants.ClaimSubject: username,
+	})
+	require.NoError(t, err)
+
+	return token
+}
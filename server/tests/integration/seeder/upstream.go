@@ -0,0 +1,82 @@
+package seeder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestDataSeeder) CreateUpstream(t *testing.T, name string, port int, upstreamURL string) (id string) {
+	t.Helper()
+
+	body := map[string]any{
+		"name":         name,
+		"description":  "",
+		"vendor":       "docker_hub",
+		"port":         port,
+		"upstream_url": upstreamURL,
+		"auth_config": map[string]any{
+			"auth_type": "anonymous",
+		},
+	}
+
+	reqBody, _ := json.Marshal(body)
+
+	req, err := http.NewRequest(http.MethodPost, s.baseURL+testdata.EndpointUpstreams, bytes.NewReader(reqBody))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	helpers.SetAuthCookie(req, s.AdminToken(t))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var resBody map[string]any
+	err = json.NewDecoder(resp.Body).Decode(&resBody)
+	require.NoError(t, err)
+
+	return resBody["reg_id"].(string)
+}
+
+func (s *TestDataSeeder) SetUpstreamState(t *testing.T, id, state string) {
+	t.Helper()
+
+	err := s.store.Upstreams().ChangeRegistryState(context.Background(), id, state)
+	require.NoError(t, err)
+}
+
+// UpstreamCredentialsContain returns whether the persisted auth config of the upstream contains the value
+func (s *TestDataSeeder) UpstreamCredentialsContain(t *testing.T, id, value string) bool {
+	t.Helper()
+
+	authConfig, err := s.store.Upstreams().GetRegistryAuthConfig(context.Background(), id)
+	require.NoError(t, err)
+	require.NotNil(t, authConfig)
+
+	var cfg map[string]any
+	require.NoError(t, json.Unmarshal(authConfig.ConfigJSON, &cfg))
+	for _, v := range cfg {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateUpstreamNamespace creates a namespace in the cache of the upstream as the first pull of its images does
+func (s *TestDataSeeder) CreateUpstreamNamespace(t *testing.T, regID, name string) (id string) {
+	t.Helper()
+
+	id, err := s.store.Namespaces().Create(context.Background(), regID, name, "", "", false, "admin")
+	require.NoError(t, err)
+	return id
+}
@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
 	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
 	"github.com/ksankeerth/open-image-registry/tests/testdata"
 	"github.com/stretchr/testify/assert"
@@ -31,6 +33,9 @@ func NewAuthTestSuite(seeder *seeder.TestDataSeeder, baseURL string) *AuthTestSu
 
 func (a *AuthTestSuite) Run(t *testing.T) {
 	t.Run("LoginAndLogout", a.testAuthFlowSuccess)
+	t.Run("RefreshToken", a.testRefreshToken)
+	t.Run("Sessions", a.testSessions)
+	t.Run("RevokeUserSessions", a.testRevokeUserSessions)
 }
 
 func (a *AuthTestSuite) Name() string {
@@ -110,4 +115,268 @@ func (a *AuthTestSuite) testAuthFlowSuccess(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, resp3.StatusCode, "Should be unauthorized after logout")
 		resp3.Body.Close()
 	})
-}
\ No newline at end of file
+}
+
+type authSessionResponse struct {
+	Id        string `json:"id"`
+	UserAgent string `json:"user_agent"`
+	IPAddress string `json:"ip_address"`
+	GrantType string `json:"grant_type"`
+	Current   bool   `json:"current"`
+}
+
+// login returns the auth token and refresh token cookies of a new session
+func (a *AuthTestSuite) login(t *testing.T, username, password, userAgent string) (authCookie,
+	refreshCookie *http.Cookie) {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]string{"username": username, "password": password})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, a.testBaseURL+testdata.EndpointLogin, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	return findCookies(t, resp)
+}
+
+func findCookies(t *testing.T, resp *http.Response) (authCookie, refreshCookie *http.Cookie) {
+	t.Helper()
+
+	for _, c := range resp.Cookies() {
+		switch c.Name {
+		case constants.AuthTokenCookie:
+			authCookie = c
+		case constants.RefreshTokenCookie:
+			refreshCookie = c
+		}
+	}
+	require.NotNil(t, authCookie, "auth token cookie is not set")
+	require.NotNil(t, refreshCookie, "refresh token cookie is not set")
+	return authCookie, refreshCookie
+}
+
+func (a *AuthTestSuite) doRequest(t *testing.T, method, endpoint string, cookies ...*http.Cookie) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, a.testBaseURL+endpoint, nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func (a *AuthTestSuite) listSessions(t *testing.T, authCookie *http.Cookie) []authSessionResponse {
+	t.Helper()
+
+	resp := a.doRequest(t, http.MethodGet, testdata.EndpointSessions, authCookie)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var res struct {
+		Sessions []authSessionResponse `json:"sessions"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	return res.Sessions
+}
+
+func (a *AuthTestSuite) testRefreshToken(t *testing.T) {
+	username := "auth-refresh-user"
+	password := "SecurePass123!"
+	a.seeder.ProvisionUserWithPassword(t, username, "auth.refresh@t.com", "Developer", password)
+
+	authCookie, refreshCookie := a.login(t, username, password, "refresh-test")
+	assert.Equal(t, constants.RefreshTokenCookiePath, refreshCookie.Path)
+	assert.True(t, refreshCookie.HttpOnly)
+
+	t.Run("Without refresh token", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodPost, testdata.EndpointRefresh, authCookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	var newAuthCookie, newRefreshCookie *http.Cookie
+	t.Run("Refresh renews tokens", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodPost, testdata.EndpointRefresh, refreshCookie)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		newAuthCookie, newRefreshCookie = findCookies(t, resp)
+		assert.NotEqual(t, refreshCookie.Value, newRefreshCookie.Value)
+
+		var res map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.Equal(t, username, res["user"].(map[string]any)["username"])
+
+		resp = a.doRequest(t, http.MethodGet, testdata.EndpointCurrentUser, newAuthCookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	})
+
+	t.Run("Refresh token can be used only once", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodPost, testdata.EndpointRefresh, refreshCookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Session stays the same", func(t *testing.T) {
+		sessions := a.listSessions(t, newAuthCookie)
+		require.Len(t, sessions, 1)
+		assert.True(t, sessions[0].Current)
+	})
+
+	t.Run("Logout ends the session", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodPost, testdata.EndpointLogout, newAuthCookie)
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		resp = a.doRequest(t, http.MethodPost, testdata.EndpointRefresh, newRefreshCookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+
+		// auth token of the first refresh belongs to the same session
+		resp = a.doRequest(t, http.MethodGet, testdata.EndpointCurrentUser, authCookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+}
+
+func (a *AuthTestSuite) testSessions(t *testing.T) {
+	username := "auth-session-user"
+	password := "SecurePass123!"
+	a.seeder.ProvisionUserWithPassword(t, username, "auth.session@t.com", "Developer", password)
+
+	laptopAuth, _ := a.login(t, username, password, "laptop-browser")
+	phoneAuth, phoneRefresh := a.login(t, username, password, "phone-browser")
+
+	var phoneSessionID string
+	t.Run("List sessions", func(t *testing.T) {
+		sessions := a.listSessions(t, laptopAuth)
+		require.Len(t, sessions, 2)
+
+		for _, session := range sessions {
+			assert.Equal(t, "password", session.GrantType)
+			assert.NotEmpty(t, session.IPAddress)
+			switch session.UserAgent {
+			case "laptop-browser":
+				assert.True(t, session.Current)
+			case "phone-browser":
+				assert.False(t, session.Current)
+				phoneSessionID = session.Id
+			default:
+				assert.Fail(t, "unexpected session", session.UserAgent)
+			}
+		}
+		require.NotEmpty(t, phoneSessionID)
+	})
+
+	t.Run("User with sessions is listed once", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodGet, testdata.EndpointUsers+"?search="+username,
+			&http.Cookie{Name: constants.AuthTokenCookie, Value: a.seeder.AdminToken(t)})
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var res map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.EqualValues(t, 1, res["total"])
+		assert.Len(t, res["entities"], 1)
+	})
+
+	t.Run("Session of another user", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointSession, phoneSessionID),
+			&http.Cookie{Name: constants.AuthTokenCookie, Value: a.seeder.AdminToken(t)})
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Revoke session", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointSession, phoneSessionID), laptopAuth)
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		resp = a.doRequest(t, http.MethodGet, testdata.EndpointCurrentUser, phoneAuth)
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+
+		resp = a.doRequest(t, http.MethodPost, testdata.EndpointRefresh, phoneRefresh)
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+
+		assert.Len(t, a.listSessions(t, laptopAuth), 1)
+	})
+
+	t.Run("Unknown session", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointSession, "unknown"), laptopAuth)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+}
+
+func (a *AuthTestSuite) testRevokeUserSessions(t *testing.T) {
+	username := "auth-revoke-user"
+	password := "SecurePass123!"
+	userID := a.seeder.ProvisionUserWithPassword(t, username, "auth.revoke@t.com", "Developer", password)
+	adminCookie := &http.Cookie{Name: constants.AuthTokenCookie, Value: a.seeder.AdminToken(t)}
+
+	authCookie1, _ := a.login(t, username, password, "first-browser")
+	authCookie2, refreshCookie2 := a.login(t, username, password, "second-browser")
+
+	t.Run("Non admin can't revoke", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUserSessions, userID), authCookie1)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+
+	t.Run("Admin revokes all sessions", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUserSessions, userID), adminCookie)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var res map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.EqualValues(t, 2, res["revoked_sessions"])
+
+		for _, c := range []*http.Cookie{authCookie1, authCookie2} {
+			resp := a.doRequest(t, http.MethodGet, testdata.EndpointCurrentUser, c)
+			resp.Body.Close()
+			helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+		}
+
+		resp = a.doRequest(t, http.MethodPost, testdata.EndpointRefresh, refreshCookie2)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Locking revokes sessions", func(t *testing.T) {
+		authCookie, refreshCookie := a.login(t, username, password, "third-browser")
+
+		resp := a.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUserLock, userID), adminCookie)
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		resp = a.doRequest(t, http.MethodGet, testdata.EndpointCurrentUser, authCookie)
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+
+		resp = a.doRequest(t, http.MethodPost, testdata.EndpointRefresh, refreshCookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUserSessions, "unknown"), adminCookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+}
@@ -0,0 +1,457 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type UpstreamCacheTestSuite struct {
+	apiVersion  string
+	name        string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+	upstream    *fakeSyncUpstream
+	upstreamID  string
+	port        int
+	// digest and blobs of the amd64 image of the fake upstream
+	amd64Manifest string
+	amd64Blobs    []string
+}
+
+func NewUpstreamCacheTestSuite(seeder *seeder.TestDataSeeder, baseURL string) *UpstreamCacheTestSuite {
+	return &UpstreamCacheTestSuite{
+		apiVersion:  "v1",
+		name:        "Upstream Cache API",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+	}
+}
+
+func (s *UpstreamCacheTestSuite) Name() string {
+	return s.name
+}
+
+func (s *UpstreamCacheTestSuite) APIVersion() string {
+	return s.apiVersion
+}
+
+func (s *UpstreamCacheTestSuite) Run(t *testing.T) {
+	// fake upstream outlives subtests since it is closed on cleanup of the suite
+	s.upstream = newFakeSyncUpstream(t)
+
+	t.Run("Setup", s.setup)
+	t.Run("ListCachedRepositories", s.testListCachedRepositories)
+	t.Run("ListCacheEntries", s.testListCacheEntries)
+	t.Run("ListCachedBlobs", s.testListCachedBlobs)
+	t.Run("PurgeTag", s.testPurgeTag)
+	t.Run("PurgeRepository", s.testPurgeRepository)
+	t.Run("PurgeUpstreamCache", s.testPurgeUpstreamCache)
+	t.Run("NegativeCache", s.testNegativeCache)
+	t.Run("NonAdminAccess", s.testNonAdminAccess)
+}
+
+func (s *UpstreamCacheTestSuite) doRequest(t *testing.T, method, endpoint string, body any, token string) *http.Response {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(reqBody)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, s.testBaseURL+endpoint, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	helpers.SetAuthCookie(req, token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+// getJSON sends a GET request as admin and decodes the response.
+func (s *UpstreamCacheTestSuite) getJSON(t *testing.T, endpoint string) map[string]any {
+	t.Helper()
+
+	resp := s.doRequest(t, http.MethodGet, endpoint, nil, s.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body
+}
+
+// purge sends a DELETE request as admin and decodes the response.
+func (s *UpstreamCacheTestSuite) purge(t *testing.T, endpoint string) map[string]any {
+	t.Helper()
+
+	resp := s.doRequest(t, http.MethodDelete, endpoint, nil, s.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body
+}
+
+// pull pulls the tag through the upstream along with the amd64 image and its blobs.
+func (s *UpstreamCacheTestSuite) pull(t *testing.T, tag string) {
+	t.Helper()
+
+	paths := []string{"manifests/" + tag, "manifests/" + s.amd64Manifest}
+	for _, blob := range s.amd64Blobs {
+		paths = append(paths, "blobs/"+blob)
+	}
+
+	for _, path := range paths {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/team/app/%s", s.port, path))
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	}
+}
+
+// repositoryID returns ID of the cached repository `team/app`. Empty string is returned if it is not cached.
+func (s *UpstreamCacheTestSuite) repositoryID(t *testing.T) string {
+	t.Helper()
+
+	list := s.getJSON(t, fmt.Sprintf(testdata.EndpointUpstreamCachedRepos, s.upstreamID))
+	for _, entity := range list["entities"].([]any) {
+		repo := entity.(map[string]any)
+		if repo["namespace"] == "team" && repo["name"] == "app" {
+			return repo["id"].(string)
+		}
+	}
+	return ""
+}
+
+// entries returns cached tags and digests of the repository by identifier.
+func (s *UpstreamCacheTestSuite) entries(t *testing.T, repositoryID, query string) map[string]map[string]any {
+	t.Helper()
+
+	list := s.getJSON(t, fmt.Sprintf(testdata.EndpointUpstreamCacheEntries, s.upstreamID, repositoryID)+query)
+
+	entries := make(map[string]map[string]any)
+	for _, entity := range list["entities"].([]any) {
+		entry := entity.(map[string]any)
+		entries[entry["identifier"].(string)] = entry
+	}
+	return entries
+}
+
+func (s *UpstreamCacheTestSuite) setup(t *testing.T) {
+	for digest, manifest := range s.upstream.manifests {
+		if strings.Contains(string(manifest), digestOf([]byte("layer of amd64"))) {
+			s.amd64Manifest = digest
+		}
+	}
+	s.amd64Blobs = []string{
+		digestOf([]byte(`{"architecture":"amd64","os":"linux"}`)),
+		digestOf([]byte("layer of amd64")),
+	}
+
+	s.port = int(helpers.FindFreePort())
+
+	body := upstreamBody("cache-upstream", s.port)
+	body["upstream_url"] = s.upstream.server.URL
+	body["auth_config"].(map[string]any)["token_endpoint"] = s.upstream.server.URL + "/token"
+
+	resp := s.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, body, s.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var created map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	s.upstreamID = created["reg_id"].(string)
+
+	waitForListener(t, s.port)
+
+	s.pull(t, "1.0")
+	s.pull(t, "2.0")
+}
+
+func (s *UpstreamCacheTestSuite) testListCachedRepositories(t *testing.T) {
+	t.Run("List", func(t *testing.T) {
+		list := s.getJSON(t, fmt.Sprintf(testdata.EndpointUpstreamCachedRepos, s.upstreamID)+
+			"?search=app&sort_by=size&order=desc")
+		require.Equal(t, float64(1), list["total"])
+
+		repo := list["entities"].([]any)[0].(map[string]any)
+		assert.Equal(t, "team", repo["namespace"])
+		assert.Equal(t, "app", repo["name"])
+		assert.Equal(t, float64(2), repo["tags"])
+		assert.Equal(t, float64(1), repo["digests"])
+		assert.Equal(t, float64(2), repo["blobs"])
+		assert.Greater(t, repo["size"], float64(len("layer of amd64")))
+		assert.NotNil(t, repo["last_accessed_at"])
+	})
+
+	t.Run("Repositories of other upstreams are not listed", func(t *testing.T) {
+		otherID := s.seeder.CreateUpstream(t, "cache-other-upstream", int(helpers.FindFreePort()),
+			"https://registry-1.docker.io")
+
+		list := s.getJSON(t, fmt.Sprintf(testdata.EndpointUpstreamCachedRepos, otherID))
+		assert.Equal(t, float64(0), list["total"])
+
+		resp := s.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamCacheEntries, otherID,
+			s.repositoryID(t)), nil, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Non existent upstream", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamCachedRepos, "non-existent-id"),
+			nil, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Unsupported sort field", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamCachedRepos, s.upstreamID)+
+			"?sort_by=tags", nil, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+}
+
+func (s *UpstreamCacheTestSuite) testListCacheEntries(t *testing.T) {
+	repositoryID := s.repositoryID(t)
+	indexDigest := digestOf(s.upstream.index)
+
+	t.Run("List", func(t *testing.T) {
+		entries := s.entries(t, repositoryID, "")
+		require.Len(t, entries, 3)
+
+		tag := entries["1.0"]
+		require.NotNil(t, tag)
+		assert.Equal(t, "tag", tag["type"])
+		assert.Equal(t, indexDigest, tag["digest"])
+		assert.Equal(t, float64(len(s.upstream.index)), tag["size"])
+		assert.NotNil(t, tag["expires_at"])
+		assert.Equal(t, false, tag["expired"])
+		assert.NotNil(t, tag["last_accessed_at"])
+
+		digest := entries[s.amd64Manifest]
+		require.NotNil(t, digest)
+		assert.Equal(t, "digest", digest["type"])
+		assert.Equal(t, "application/vnd.oci.image.manifest.v1+json", digest["media_type"])
+		assert.Nil(t, digest["expires_at"])
+	})
+
+	t.Run("Filter by type", func(t *testing.T) {
+		entries := s.entries(t, repositoryID, "?type=tag")
+		assert.Len(t, entries, 2)
+		assert.Contains(t, entries, "1.0")
+		assert.Contains(t, entries, "2.0")
+	})
+
+	t.Run("Invalid type", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamCacheEntries, s.upstreamID,
+			repositoryID)+"?type=blob", nil, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Non existent repository", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamCacheEntries, s.upstreamID,
+			"non-existent-id"), nil, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+}
+
+func (s *UpstreamCacheTestSuite) testListCachedBlobs(t *testing.T) {
+	list := s.getJSON(t, fmt.Sprintf(testdata.EndpointUpstreamCachedBlobs, s.upstreamID, s.repositoryID(t))+
+		"?sort_by=size")
+	require.Equal(t, float64(2), list["total"])
+
+	blobs := list["entities"].([]any)
+	layer := blobs[0].(map[string]any)
+	config := blobs[1].(map[string]any)
+	assert.Equal(t, s.amd64Blobs[1], layer["digest"])
+	assert.Equal(t, float64(len("layer of amd64")), layer["size"])
+	assert.Equal(t, s.amd64Blobs[0], config["digest"])
+	assert.NotNil(t, config["last_accessed_at"])
+}
+
+func (s *UpstreamCacheTestSuite) testPurgeTag(t *testing.T) {
+	repositoryID := s.repositoryID(t)
+	freedBytes := float64(len(`{"architecture":"amd64","os":"linux"}`) + len("layer of amd64"))
+
+	t.Run("Manifest referred by another tag is kept", func(t *testing.T) {
+		res := s.purge(t, fmt.Sprintf(testdata.EndpointUpstreamCachedTag, s.upstreamID, repositoryID, "1.0"))
+		assert.Equal(t, float64(0), res["deleted_blobs"])
+
+		entries := s.entries(t, repositoryID, "")
+		assert.NotContains(t, entries, "1.0")
+		assert.Contains(t, entries, "2.0")
+		assert.Contains(t, entries, s.amd64Manifest)
+	})
+
+	t.Run("Purged tag is not found", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUpstreamCachedTag, s.upstreamID,
+			repositoryID, "1.0"), nil, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Last tag deletes manifests and blobs", func(t *testing.T) {
+		res := s.purge(t, fmt.Sprintf(testdata.EndpointUpstreamCachedTag, s.upstreamID, repositoryID, "2.0"))
+		assert.Equal(t, float64(2), res["deleted_blobs"])
+		assert.Equal(t, freedBytes, res["freed_bytes"])
+
+		assert.Empty(t, s.entries(t, repositoryID, ""))
+
+		list := s.getJSON(t, fmt.Sprintf(testdata.EndpointUpstreamCachedBlobs, s.upstreamID, repositoryID))
+		assert.Equal(t, float64(0), list["total"])
+	})
+
+	t.Run("Purged image is downloaded again", func(t *testing.T) {
+		layerPath := "/v2/team/app/blobs/" + s.amd64Blobs[1]
+		fetches := s.upstream.fetchCount(layerPath)
+
+		s.pull(t, "2.0")
+		assert.Equal(t, fetches+1, s.upstream.fetchCount(layerPath))
+	})
+}
+
+func (s *UpstreamCacheTestSuite) testPurgeRepository(t *testing.T) {
+	repositoryID := s.repositoryID(t)
+	require.NotEmpty(t, repositoryID)
+
+	res := s.purge(t, fmt.Sprintf(testdata.EndpointUpstreamCachedRepo, s.upstreamID, repositoryID))
+	assert.Equal(t, float64(2), res["deleted_blobs"])
+	assert.Empty(t, s.repositoryID(t))
+
+	t.Run("Purged repository is not found", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUpstreamCachedRepo, s.upstreamID,
+			repositoryID), nil, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Repository is cached again", func(t *testing.T) {
+		s.pull(t, "1.0")
+
+		newID := s.repositoryID(t)
+		require.NotEmpty(t, newID)
+		assert.NotEqual(t, repositoryID, newID)
+		assert.Len(t, s.entries(t, newID, ""), 2)
+	})
+}
+
+func (s *UpstreamCacheTestSuite) testPurgeUpstreamCache(t *testing.T) {
+	res := s.purge(t, fmt.Sprintf(testdata.EndpointUpstreamCache, s.upstreamID))
+	assert.Equal(t, float64(2), res["deleted_blobs"])
+
+	list := s.getJSON(t, fmt.Sprintf(testdata.EndpointUpstreamCachedRepos, s.upstreamID))
+	assert.Equal(t, float64(0), list["total"])
+
+	t.Run("Upstream is cached again", func(t *testing.T) {
+		s.pull(t, "latest")
+		assert.NotEmpty(t, s.repositoryID(t))
+	})
+
+	t.Run("Non existent upstream", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUpstreamCache, "non-existent-id"),
+			nil, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+}
+
+func (s *UpstreamCacheTestSuite) testNonAdminAccess(t *testing.T) {
+	s.seeder.ProvisionUser(t, "cache-developer", "cache-developer@t.com", constants.RoleDeveloper)
+	token := s.seeder.UserToken(t, "cache-developer", constants.RoleDeveloper)
+
+	resp := s.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamCachedRepos, s.upstreamID), nil, token)
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+
+	resp = s.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUpstreamCache, s.upstreamID), nil, token)
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+}
+
+func (s *UpstreamCacheTestSuite) testNegativeCache(t *testing.T) {
+	pullMissing := func(t *testing.T, method, repository string) {
+		t.Helper()
+
+		req, err := http.NewRequest(method, fmt.Sprintf("http://localhost:%d/v2/%s/manifests/latest", s.port,
+			repository), nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+		if method == http.MethodGet {
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(body), "MANIFEST_UNKNOWN")
+		}
+	}
+
+	flush := func(t *testing.T, query string) float64 {
+		t.Helper()
+		return s.purge(t, fmt.Sprintf(testdata.EndpointUpstreamNotFoundCache, s.upstreamID)+query)["flushed_entries"].(float64)
+	}
+
+	missingPath := "/v2/team/missing/manifests/latest"
+
+	t.Run("Not found result is cached", func(t *testing.T) {
+		pullMissing(t, http.MethodGet, "team/missing")
+		pullMissing(t, http.MethodGet, "team/missing")
+		pullMissing(t, http.MethodHead, "team/missing")
+
+		assert.Equal(t, 1, s.upstream.fetchCount(missingPath))
+	})
+
+	t.Run("Flush other repository", func(t *testing.T) {
+		assert.Zero(t, flush(t, "?repository=team/other"))
+
+		pullMissing(t, http.MethodGet, "team/missing")
+		assert.Equal(t, 1, s.upstream.fetchCount(missingPath))
+	})
+
+	t.Run("Flush repository", func(t *testing.T) {
+		assert.Equal(t, float64(1), flush(t, "?repository=team/missing"))
+
+		pullMissing(t, http.MethodGet, "team/missing")
+		assert.Equal(t, 2, s.upstream.fetchCount(missingPath))
+	})
+
+	t.Run("Flush all", func(t *testing.T) {
+		pullMissing(t, http.MethodGet, "team/gone")
+		assert.Equal(t, float64(2), flush(t, ""))
+
+		pullMissing(t, http.MethodGet, "team/missing")
+		assert.Equal(t, 3, s.upstream.fetchCount(missingPath))
+	})
+
+	t.Run("Non existent upstream", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUpstreamNotFoundCache, "non-existent-id"),
+			nil, s.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+}
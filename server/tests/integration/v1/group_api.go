@@ -0,0 +1,440 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type GroupTestSuite struct {
+	apiVersion  string
+	name        string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+}
+
+func NewGroupTestSuite(seeder *seeder.TestDataSeeder, baseURL string) *GroupTestSuite {
+	return &GroupTestSuite{
+		apiVersion:  "v1",
+		name:        "Group Registry API",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+	}
+}
+
+func (g *GroupTestSuite) Name() string {
+	return g.name
+}
+
+func (g *GroupTestSuite) APIVersion() string {
+	return g.apiVersion
+}
+
+func (g *GroupTestSuite) Run(t *testing.T) {
+	t.Run("CreateGroup_Validation", g.testCreateGroupValidation)
+	t.Run("CreateGroup_Conflicts", g.testCreateGroupConflicts)
+	t.Run("GetAndListGroups", g.testGetAndListGroups)
+	t.Run("UpdateMembers", g.testUpdateMembers)
+	t.Run("PullThroughGroup", g.testPullThroughGroup)
+	t.Run("PushThroughGroup", g.testPushThroughGroup)
+	t.Run("DeleteGroup", g.testDeleteGroup)
+	t.Run("NonAdminAccess", g.testNonAdminAccess)
+}
+
+func (g *GroupTestSuite) doRequest(t *testing.T, method, endpoint string, body any, token string) *http.Response {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(reqBody)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, g.testBaseURL+endpoint, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	helpers.SetAuthCookie(req, token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func groupBody(name string, port int, members ...string) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"description": "group for tests",
+		"port":        port,
+		"members":     members,
+	}
+}
+
+func (g *GroupTestSuite) createGroup(t *testing.T, body map[string]any) string {
+	t.Helper()
+
+	resp := g.doRequest(t, http.MethodPost, testdata.EndpointGroups, body, g.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var created map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	return created["group_id"].(string)
+}
+
+func (g *GroupTestSuite) getGroup(t *testing.T, id string) map[string]any {
+	t.Helper()
+
+	resp := g.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointGroupByID, id), nil, g.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var group map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&group))
+	return group
+}
+
+func memberIDs(group map[string]any) []string {
+	ids := make([]string, 0)
+	for _, m := range group["members"].([]any) {
+		ids = append(ids, m.(map[string]any)["id"].(string))
+	}
+	return ids
+}
+
+// waitForListener waits until the registry listener on the port accepts requests.
+func waitForListener(t *testing.T, port int) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/", port))
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func (g *GroupTestSuite) testCreateGroupValidation(t *testing.T) {
+	upstreamID := g.seeder.CreateUpstream(t, "group-validation-upstream", int(helpers.FindFreePort()),
+		"https://registry-1.docker.io")
+
+	withPushMember := func(body map[string]any, pushMemberID string) map[string]any {
+		body["push_member_id"] = pushMemberID
+		return body
+	}
+
+	tcs := []struct {
+		name       string
+		body       map[string]any
+		statusCode int
+	}{
+		{"Short name", groupBody("gr", 19001, constants.HostedRegistryID), http.StatusBadRequest},
+		{"Invalid port", groupBody("group-invalid", 80, constants.HostedRegistryID), http.StatusBadRequest},
+		{"Without members", groupBody("group-invalid", 19001), http.StatusBadRequest},
+		{"Duplicate members", groupBody("group-invalid", 19001, upstreamID, upstreamID), http.StatusBadRequest},
+		{"Unknown member", groupBody("group-invalid", 19001, "unknown-member"), http.StatusBadRequest},
+		{"Upstream as push member", withPushMember(groupBody("group-invalid", 19001, constants.HostedRegistryID,
+			upstreamID), upstreamID), http.StatusBadRequest},
+		{"Push member not in members", withPushMember(groupBody("group-invalid", 19001, upstreamID),
+			constants.HostedRegistryID), http.StatusBadRequest},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := g.doRequest(t, http.MethodPost, testdata.EndpointGroups, tc.body, g.seeder.AdminToken(t))
+			defer resp.Body.Close()
+
+			helpers.AssertStatusCode(t, resp, tc.statusCode)
+		})
+	}
+}
+
+func (g *GroupTestSuite) testCreateGroupConflicts(t *testing.T) {
+	upstreamPort := int(helpers.FindFreePort())
+	g.seeder.CreateUpstream(t, "group-conflict-upstream", upstreamPort, "https://registry-1.docker.io")
+
+	groupPort := int(helpers.FindFreePort())
+	g.createGroup(t, groupBody("group-conflict", groupPort, constants.HostedRegistryID))
+
+	tcs := []struct {
+		name     string
+		endpoint string
+		body     map[string]any
+	}{
+		{"Same name as group", testdata.EndpointGroups, groupBody("group-conflict", int(helpers.FindFreePort()),
+			constants.HostedRegistryID)},
+		{"Same name as upstream", testdata.EndpointGroups, groupBody("group-conflict-upstream",
+			int(helpers.FindFreePort()), constants.HostedRegistryID)},
+		{"Port used by upstream", testdata.EndpointGroups, groupBody("group-conflict-2", upstreamPort,
+			constants.HostedRegistryID)},
+		{"Port used by group", testdata.EndpointGroups, groupBody("group-conflict-2", groupPort,
+			constants.HostedRegistryID)},
+		{"Upstream with name of group", testdata.EndpointUpstreams, upstreamBody("group-conflict",
+			int(helpers.FindFreePort()))},
+		{"Upstream with port of group", testdata.EndpointUpstreams, upstreamBody("group-conflict-upstream-2",
+			groupPort)},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := g.doRequest(t, http.MethodPost, tc.endpoint, tc.body, g.seeder.AdminToken(t))
+			defer resp.Body.Close()
+
+			helpers.AssertStatusCode(t, resp, http.StatusConflict)
+		})
+	}
+}
+
+func (g *GroupTestSuite) testGetAndListGroups(t *testing.T) {
+	upstreamID := g.seeder.CreateUpstream(t, "group-get-upstream", int(helpers.FindFreePort()),
+		"https://registry-1.docker.io")
+
+	body := groupBody("group-get", int(helpers.FindFreePort()), constants.HostedRegistryID, upstreamID)
+	body["push_member_id"] = constants.HostedRegistryID
+	id := g.createGroup(t, body)
+
+	t.Run("Get group", func(t *testing.T) {
+		group := g.getGroup(t, id)
+
+		assert.Equal(t, "group-get", group["name"])
+		assert.Equal(t, constants.ResourceStateActive, group["status"])
+		assert.Equal(t, constants.HostedRegistryID, group["push_member_id"])
+
+		members := group["members"].([]any)
+		require.Len(t, members, 2)
+		hosted := members[0].(map[string]any)
+		assert.Equal(t, constants.HostedRegistryID, hosted["id"])
+		assert.Equal(t, constants.GroupMemberTypeHosted, hosted["type"])
+		upstream := members[1].(map[string]any)
+		assert.Equal(t, upstreamID, upstream["id"])
+		assert.Equal(t, "group-get-upstream", upstream["name"])
+		assert.Equal(t, constants.GroupMemberTypeUpstream, upstream["type"])
+	})
+
+	t.Run("Non existent group", func(t *testing.T) {
+		resp := g.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointGroupByID, "non-existent-id"), nil,
+			g.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("List groups", func(t *testing.T) {
+		resp := g.doRequest(t, http.MethodGet, testdata.EndpointGroups+"?search=group-get", nil, g.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var list map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		assert.Equal(t, float64(1), list["total"])
+		assert.Equal(t, id, list["entities"].([]any)[0].(map[string]any)["id"])
+	})
+
+	t.Run("Invalid sort field", func(t *testing.T) {
+		resp := g.doRequest(t, http.MethodGet, testdata.EndpointGroups+"?sort_by=members", nil, g.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+}
+
+func (g *GroupTestSuite) testUpdateMembers(t *testing.T) {
+	upstream1 := g.seeder.CreateUpstream(t, "group-members-upstream1", int(helpers.FindFreePort()),
+		"https://registry-1.docker.io")
+	upstream2 := g.seeder.CreateUpstream(t, "group-members-upstream2", int(helpers.FindFreePort()),
+		"https://quay.io")
+
+	body := groupBody("group-members", int(helpers.FindFreePort()), constants.HostedRegistryID, upstream1)
+	body["push_member_id"] = constants.HostedRegistryID
+	id := g.createGroup(t, body)
+
+	updateMembers := func(t *testing.T, members ...string) *http.Response {
+		return g.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointGroupMembers, id),
+			map[string]any{"members": members}, g.seeder.AdminToken(t))
+	}
+
+	t.Run("Change resolution order", func(t *testing.T) {
+		resp := updateMembers(t, upstream2, constants.HostedRegistryID, upstream1)
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		assert.Equal(t, []string{upstream2, constants.HostedRegistryID, upstream1}, memberIDs(g.getGroup(t, id)))
+	})
+
+	t.Run("Unknown member", func(t *testing.T) {
+		resp := updateMembers(t, constants.HostedRegistryID, "unknown-member")
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Removing push member", func(t *testing.T) {
+		resp := updateMembers(t, upstream1)
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Deleted upstream is removed from group", func(t *testing.T) {
+		resp := g.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUpstreamByID, upstream2), nil,
+			g.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		assert.Equal(t, []string{constants.HostedRegistryID, upstream1}, memberIDs(g.getGroup(t, id)))
+	})
+
+	t.Run("Non existent group", func(t *testing.T) {
+		resp := g.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointGroupMembers, "non-existent-id"),
+			map[string]any{"members": []string{upstream1}}, g.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+}
+
+func (g *GroupTestSuite) testPullThroughGroup(t *testing.T) {
+	manifest := `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json"}`
+
+	fakeUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token":"group-test-token","expires_in":300}`))
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/team/app/manifests/1.0":
+			w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+			w.Write([]byte(manifest))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer fakeUpstream.Close()
+
+	body := upstreamBody("group-pull-upstream", int(helpers.FindFreePort()))
+	body["upstream_url"] = fakeUpstream.URL
+	body["auth_config"].(map[string]any)["token_endpoint"] = fakeUpstream.URL + "/token"
+	body["cache_config"] = map[string]any{"enabled": false}
+
+	resp := g.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, body, g.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	upstreamID := created["reg_id"].(string)
+
+	port := int(helpers.FindFreePort())
+	g.createGroup(t, groupBody("group-pull", port, constants.HostedRegistryID, upstreamID))
+	waitForListener(t, port)
+
+	t.Run("Image of upstream member", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/team/app/manifests/1.0", port))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+		content, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, manifest, string(content))
+		assert.NotEmpty(t, resp.Header.Get("Docker-Content-Digest"))
+	})
+
+	t.Run("Image is not available in any member", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/team/unknown/manifests/1.0", port))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Push to read-only group", func(t *testing.T) {
+		resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v2/team/app/blobs/uploads/", port), "", nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusMethodNotAllowed)
+	})
+}
+
+func (g *GroupTestSuite) testPushThroughGroup(t *testing.T) {
+	port := int(helpers.FindFreePort())
+	body := groupBody("group-push", port, constants.HostedRegistryID)
+	body["push_member_id"] = constants.HostedRegistryID
+	g.createGroup(t, body)
+	waitForListener(t, port)
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v2/group-push-team/app/blobs/uploads/", port), "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusAccepted)
+
+	// upload continues through the group
+	location := resp.Header.Get("Location")
+	require.True(t, strings.HasPrefix(location, fmt.Sprintf("http://localhost:%d/v2/group-push-team/app/blobs/uploads/",
+		port)), location)
+
+	req, err := http.NewRequest(http.MethodPut, location+"?digest=sha256:"+strings.Repeat("a", 64),
+		bytes.NewReader([]byte("layer")))
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusCreated)
+}
+
+func (g *GroupTestSuite) testDeleteGroup(t *testing.T) {
+	port := int(helpers.FindFreePort())
+	id := g.createGroup(t, groupBody("group-delete", port, constants.HostedRegistryID))
+	waitForListener(t, port)
+
+	t.Run("Disabled group stops serving", func(t *testing.T) {
+		endpoint := fmt.Sprintf(testdata.EndpointGroupState, id) + "?state=" + constants.ResourceStateDisabled
+		resp := g.doRequest(t, http.MethodPatch, endpoint, nil, g.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		_, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/", port))
+		assert.Error(t, err)
+	})
+
+	t.Run("Delete group", func(t *testing.T) {
+		resp := g.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointGroupByID, id), nil, g.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		resp = g.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointGroupByID, id), nil, g.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Delete non existent group", func(t *testing.T) {
+		resp := g.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointGroupByID, id), nil, g.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+}
+
+func (g *GroupTestSuite) testNonAdminAccess(t *testing.T) {
+	g.seeder.ProvisionUser(t, "group-developer", "group-developer@t.com", constants.RoleDeveloper)
+	token := g.seeder.UserToken(t, "group-developer", constants.RoleDeveloper)
+
+	resp := g.doRequest(t, http.MethodGet, testdata.EndpointGroups, nil, token)
+	defer resp.Body.Close()
+
+	helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+}
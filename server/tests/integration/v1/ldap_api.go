@@ -0,0 +1,447 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/security"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type LDAPTestSuite struct {
+	name        string
+	apiVersion  string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+	server      *helpers.MockLDAPServer
+	// groupMembers keeps members of groups in the directory, since they are replaced as a whole
+	groupMembers map[string][]string
+}
+
+func NewLDAPTestSuite(seeder *seeder.TestDataSeeder, baseURL string, server *helpers.MockLDAPServer) *LDAPTestSuite {
+	return &LDAPTestSuite{
+		name:         "LDAPAPI",
+		apiVersion:   "v1",
+		seeder:       seeder,
+		testBaseURL:  baseURL,
+		server:       server,
+		groupMembers: make(map[string][]string),
+	}
+}
+
+func (l *LDAPTestSuite) Run(t *testing.T) {
+	for _, group := range []string{"registry-admins", "registry-developers", "unmapped-group"} {
+		l.server.AddEntry(&helpers.LDAPEntry{
+			DN: l.groupDN(group),
+			Attributes: map[string][]string{
+				"objectClass": {"groupOfNames"},
+				"cn":          {group},
+			},
+		})
+	}
+
+	t.Run("Provisioning", l.testProvisioning)
+	t.Run("InvalidCredentials", l.testInvalidCredentials)
+	t.Run("LocalAccounts", l.testLocalAccounts)
+	t.Run("RoleMapping", l.testRoleMapping)
+	t.Run("DirectoryUnavailable", l.testDirectoryUnavailable)
+	t.Run("TwoFactor", l.testTwoFactor)
+}
+
+func (l *LDAPTestSuite) Name() string {
+	return l.name
+}
+
+func (l *LDAPTestSuite) APIVersion() string {
+	return l.apiVersion
+}
+
+func (l *LDAPTestSuite) userDN(uid string) string {
+	return fmt.Sprintf("uid=%s,ou=people,dc=example,dc=com", uid)
+}
+
+func (l *LDAPTestSuite) groupDN(group string) string {
+	return fmt.Sprintf("cn=%s,ou=groups,dc=example,dc=com", group)
+}
+
+// addUser adds the user to the directory as a member of the groups
+func (l *LDAPTestSuite) addUser(uid, password, email, displayName string, groups ...string) {
+	l.server.AddEntry(&helpers.LDAPEntry{
+		DN:       l.userDN(uid),
+		Password: password,
+		Attributes: map[string][]string{
+			"objectClass": {"inetOrgPerson"},
+			"uid":         {uid},
+			"mail":        {email},
+			"cn":          {displayName},
+			"entryUUID":   {uuid.NewString()},
+		},
+	})
+	l.setGroups(uid, groups...)
+}
+
+// setGroups replaces groups of the user in the directory
+func (l *LDAPTestSuite) setGroups(uid string, groups ...string) {
+	for group, members := range l.groupMembers {
+		l.groupMembers[group] = removeValue(members, l.userDN(uid))
+	}
+	for _, group := range groups {
+		l.groupMembers[group] = append(l.groupMembers[group], l.userDN(uid))
+	}
+	for group, members := range l.groupMembers {
+		l.server.SetAttribute(l.groupDN(group), "member", members...)
+	}
+}
+
+func removeValue(values []string, value string) []string {
+	var res []string
+	for _, v := range values {
+		if v != value {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func (l *LDAPTestSuite) login(t *testing.T, username, password string) *http.Response {
+	t.Helper()
+
+	reqBody, err := json.Marshal(map[string]any{"username": username, "password": password})
+	require.NoError(t, err)
+
+	resp, err := http.Post(l.testBaseURL+testdata.EndpointLogin, testdata.ApplicationJson, bytes.NewReader(reqBody))
+	require.NoError(t, err)
+	return resp
+}
+
+func (l *LDAPTestSuite) getUser(t *testing.T, username string) map[string]any {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, l.testBaseURL+fmt.Sprintf(testdata.EndpointUserByID, username), nil)
+	require.NoError(t, err)
+	helpers.SetAuthCookie(req, l.seeder.AdminToken(t))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var user map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&user))
+	return user
+}
+
+func (l *LDAPTestSuite) testProvisioning(t *testing.T) {
+	l.addUser("ldap-developer", "DirectoryPass1!", "ldap.developer@t.com", "LDAP Developer", "registry-developers")
+
+	var userID string
+	t.Run("First login provisions the user", func(t *testing.T) {
+		resp := l.login(t, "ldap-developer", "DirectoryPass1!")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var res map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.Equal(t, constants.RoleDeveloper, res["user"].(map[string]any)["role"])
+
+		user := l.getUser(t, "ldap-developer")
+		userID = user["id"].(string)
+		assert.Equal(t, "ldap.developer@t.com", user["email"])
+		assert.Equal(t, "LDAP Developer", user["display_name"])
+		assert.Equal(t, constants.RoleDeveloper, user["role"])
+	})
+
+	t.Run("Profile is synced on next login", func(t *testing.T) {
+		dn := l.userDN("ldap-developer")
+		l.server.SetAttribute(dn, "mail", "ldap.developer.new@t.com")
+		l.server.SetAttribute(dn, "cn", "LDAP Developer Renamed")
+
+		resp := l.login(t, "ldap-developer", "DirectoryPass1!")
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		user := l.getUser(t, "ldap-developer")
+		assert.Equal(t, userID, user["id"])
+		assert.Equal(t, "ldap.developer.new@t.com", user["email"])
+		assert.Equal(t, "LDAP Developer Renamed", user["display_name"])
+	})
+
+	t.Run("Password changed in directory", func(t *testing.T) {
+		l.server.SetPassword(l.userDN("ldap-developer"), "DirectoryPass2!")
+
+		resp := l.login(t, "ldap-developer", "DirectoryPass1!")
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+
+		resp = l.login(t, "ldap-developer", "DirectoryPass2!")
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	})
+}
+
+func (l *LDAPTestSuite) testInvalidCredentials(t *testing.T) {
+	l.addUser("ldap-invalid", "DirectoryPass1!", "ldap.invalid@t.com", "LDAP Invalid", "registry-developers")
+
+	tcs := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"Wrong password", "ldap-invalid", "WrongPass1!"},
+		{"Empty password", "ldap-invalid", ""},
+		{"Unknown user", "ldap-unknown", "DirectoryPass1!"},
+		{"Filter injection", "*", "DirectoryPass1!"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := l.login(t, tc.username, tc.password)
+			defer resp.Body.Close()
+			helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+		})
+	}
+
+	t.Run("Failed attempts don't lock the account", func(t *testing.T) {
+		for range constants.MaxFailedLoginAttempts + 1 {
+			resp := l.login(t, "ldap-invalid", "WrongPass1!")
+			resp.Body.Close()
+			helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+		}
+
+		resp := l.login(t, "ldap-invalid", "DirectoryPass1!")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	})
+}
+
+func (l *LDAPTestSuite) testLocalAccounts(t *testing.T) {
+	l.seeder.ProvisionUserWithPassword(t, "ldap-local-user", "ldap.local.user@t.com", "Developer", "LocalPass123!")
+	l.addUser("ldap-local-user", "DirectoryPass1!", "ldap.local.directory@t.com", "Directory User",
+		"registry-admins")
+
+	t.Run("Local password works", func(t *testing.T) {
+		resp := l.login(t, "ldap-local-user", "LocalPass123!")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		user := l.getUser(t, "ldap-local-user")
+		assert.Equal(t, constants.RoleDeveloper, user["role"])
+		assert.Equal(t, "ldap.local.user@t.com", user["email"])
+	})
+
+	t.Run("Directory password doesn't work", func(t *testing.T) {
+		resp := l.login(t, "ldap-local-user", "DirectoryPass1!")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Directory user conflicting with local email", func(t *testing.T) {
+		l.addUser("ldap-email-conflict", "DirectoryPass1!", "ldap.local.user@t.com", "Email Conflict",
+			"registry-developers")
+
+		resp := l.login(t, "ldap-email-conflict", "DirectoryPass1!")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusConflict)
+	})
+}
+
+func (l *LDAPTestSuite) testRoleMapping(t *testing.T) {
+	maintainerID := l.seeder.ProvisionUser(t, "ldap-ns-maintainer", "ldap.ns.maintainer@t.com", "Maintainer")
+	nsID := l.seeder.CreateNamespace(t, "ldap-role-ns", "", "Team", false, maintainerID)
+
+	l.addUser("ldap-role-user", "DirectoryPass1!", "ldap.role.user@t.com", "LDAP Role User", "unmapped-group")
+
+	t.Run("Default role is assigned without mapped groups", func(t *testing.T) {
+		resp := l.login(t, "ldap-role-user", "DirectoryPass1!")
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, constants.RoleGuest, l.getUser(t, "ldap-role-user")["role"])
+	})
+
+	var userID string
+	t.Run("Highest mapped role wins", func(t *testing.T) {
+		l.setGroups("ldap-role-user", "registry-developers", "registry-admins")
+
+		resp := l.login(t, "ldap-role-user", "DirectoryPass1!")
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		user := l.getUser(t, "ldap-role-user")
+		userID = user["id"].(string)
+		assert.Equal(t, constants.RoleAdmin, user["role"])
+	})
+
+	t.Run("Access not allowed for the new role is revoked", func(t *testing.T) {
+		l.setGroups("ldap-role-user", "registry-developers")
+		resp := l.login(t, "ldap-role-user", "DirectoryPass1!")
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		l.seeder.GrantAccess(t, nsID, constants.ResourceTypeNamespace, userID, constants.AccessLevelDeveloper)
+
+		l.setGroups("ldap-role-user")
+		resp = l.login(t, "ldap-role-user", "DirectoryPass1!")
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		assert.Equal(t, constants.RoleGuest, l.getUser(t, "ldap-role-user")["role"])
+
+		req, err := http.NewRequest(http.MethodGet,
+			l.testBaseURL+fmt.Sprintf(testdata.EndpointNamespaceUsers, nsID), nil)
+		require.NoError(t, err)
+		helpers.SetAuthCookie(req, l.seeder.AdminToken(t))
+		nsResp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer nsResp.Body.Close()
+
+		var res struct {
+			Entities []struct {
+				UserId string `json:"user_id"`
+			} `json:"entities"`
+		}
+		require.NoError(t, json.NewDecoder(nsResp.Body).Decode(&res))
+		for _, entity := range res.Entities {
+			assert.NotEqual(t, userID, entity.UserId)
+		}
+	})
+
+	t.Run("Login is rejected without a role", func(t *testing.T) {
+		ldapConfig := config.GetLDAPConfig()
+		defaultRole := ldapConfig.DefaultRole
+		ldapConfig.DefaultRole = ""
+		defer func() { ldapConfig.DefaultRole = defaultRole }()
+
+		l.addUser("ldap-no-role", "DirectoryPass1!", "ldap.no.role@t.com", "LDAP No Role")
+
+		resp := l.login(t, "ldap-no-role", "DirectoryPass1!")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+}
+
+func (l *LDAPTestSuite) testDirectoryUnavailable(t *testing.T) {
+	l.addUser("ldap-outage", "DirectoryPass1!", "ldap.outage@t.com", "LDAP Outage", "registry-developers")
+	l.seeder.ProvisionUserWithPassword(t, "ldap-outage-local", "ldap.outage.local@t.com", "Developer",
+		"LocalPass123!")
+
+	ldapConfig := config.GetLDAPConfig()
+	ldapURL := ldapConfig.URL
+	// nothing listens on the discard port
+	ldapConfig.URL = "ldap://127.0.0.1:9"
+	defer func() { ldapConfig.URL = ldapURL }()
+
+	t.Run("Directory users can't log in", func(t *testing.T) {
+		resp := l.login(t, "ldap-outage", "DirectoryPass1!")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadGateway)
+	})
+
+	t.Run("Local accounts keep working", func(t *testing.T) {
+		resp := l.login(t, "ldap-outage-local", "LocalPass123!")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	})
+}
+
+// setRoleRequirement sets whether accounts of the role must use two-factor authentication
+func setRoleRequirement(t *testing.T, baseURL, adminToken, role string, required bool) {
+	t.Helper()
+
+	reqBody, err := json.Marshal(map[string]bool{"two_factor_required": required})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, baseURL+fmt.Sprintf(testdata.EndpointRoleByName, role),
+		bytes.NewReader(reqBody))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	helpers.SetAuthCookie(req, adminToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// enrollAndCompleteLogin enrolls the user during the login of the two-factor token and completes the login with the
+// first code of the new secret
+func enrollAndCompleteLogin(t *testing.T, client *http.Client, baseURL, token string) *http.Response {
+	t.Helper()
+
+	reqBody, err := json.Marshal(map[string]string{"token": token})
+	require.NoError(t, err)
+	resp, err := client.Post(baseURL+testdata.EndpointLoginTwoFactorEnroll, testdata.ApplicationJson,
+		bytes.NewReader(reqBody))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var enrollment twoFactorEnrollmentResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&enrollment))
+
+	code, err := security.TOTPCode(enrollment.Secret, security.TOTPStep(time.Now()))
+	require.NoError(t, err)
+
+	reqBody, err = json.Marshal(map[string]string{"token": token, "code": code})
+	require.NoError(t, err)
+	resp, err = client.Post(baseURL+testdata.EndpointLoginTwoFactor, testdata.ApplicationJson,
+		bytes.NewReader(reqBody))
+	require.NoError(t, err)
+	return resp
+}
+
+func (l *LDAPTestSuite) testTwoFactor(t *testing.T) {
+	l.addUser("ldap-2fa-user", "DirectoryPass1!", "ldap.2fa.user@t.com", "LDAP 2FA User", "registry-developers")
+
+	setRoleRequirement(t, l.testBaseURL, l.seeder.AdminToken(t), constants.RoleDeveloper, true)
+	defer setRoleRequirement(t, l.testBaseURL, l.seeder.AdminToken(t), constants.RoleDeveloper, false)
+
+	var token string
+	t.Run("Login requires second factor", func(t *testing.T) {
+		resp := l.login(t, "ldap-2fa-user", "DirectoryPass1!")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Cookies(), "session must not be started before the second factor")
+
+		var res twoFactorLoginResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		require.NotNil(t, res.TwoFactor)
+		assert.True(t, res.TwoFactor.EnrollmentRequired)
+		token = res.TwoFactor.Token
+
+		// the account is provisioned by the first factor
+		assert.Equal(t, constants.RoleDeveloper, l.getUser(t, "ldap-2fa-user")["role"])
+	})
+
+	t.Run("Second factor completes the login", func(t *testing.T) {
+		resp := enrollAndCompleteLogin(t, http.DefaultClient, l.testBaseURL, token)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		authCookie, refreshCookie := findCookies(t, resp)
+		assert.NotNil(t, authCookie)
+		assert.NotNil(t, refreshCookie)
+	})
+
+	t.Run("Enabled second factor is verified on next login", func(t *testing.T) {
+		resp := l.login(t, "ldap-2fa-user", "DirectoryPass1!")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Cookies())
+
+		var res twoFactorLoginResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		require.NotNil(t, res.TwoFactor)
+		assert.False(t, res.TwoFactor.EnrollmentRequired)
+	})
+}
@@ -0,0 +1,442 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type MachineTestSuite struct {
+	name        string
+	apiVersion  string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+	// registryURL serves all registries on a single port
+	registryURL string
+}
+
+func NewMachineTestSuite(seeder *seeder.TestDataSeeder, baseURL, registryURL string) *MachineTestSuite {
+	return &MachineTestSuite{
+		name:        "MachineAPI",
+		apiVersion:  "v1",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+		registryURL: registryURL,
+	}
+}
+
+func (m *MachineTestSuite) Run(t *testing.T) {
+	t.Run("CreateValidation", m.testCreateValidation)
+	t.Run("Lifecycle", m.testLifecycle)
+	t.Run("MaintainerScope", m.testMaintainerScope)
+	t.Run("RegistryAccess", m.testRegistryAccess)
+}
+
+func (m *MachineTestSuite) Name() string {
+	return m.name
+}
+
+func (m *MachineTestSuite) APIVersion() string {
+	return m.apiVersion
+}
+
+type machineResponse struct {
+	Id       string `json:"id"`
+	Username string `json:"username"`
+	Access   []struct {
+		ResourceType string `json:"resource_type"`
+		ResourceID   string `json:"resource_id"`
+		AccessLevel  string `json:"access_level"`
+	} `json:"access"`
+	Secret *struct {
+		Id          string `json:"id"`
+		TokenPrefix string `json:"token_prefix"`
+		Secret      string `json:"secret"`
+	} `json:"secret"`
+}
+
+// doRequest sends the request with the session cookie if cookieToken is set. Otherwise authorization is used as
+// `Authorization` header.
+func (m *MachineTestSuite) doRequest(t *testing.T, method, endpoint string, body any, cookieToken,
+	authorization string) *http.Response {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(reqBody)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, m.testBaseURL+endpoint, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	if cookieToken != "" {
+		helpers.SetAuthCookie(req, cookieToken)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func machineBody(username, resourceType, resourceID, accessLevel string) map[string]any {
+	return map[string]any{
+		"username":     username,
+		"display_name": "CI pipeline",
+		"access": []map[string]any{
+			{"resource_type": resourceType, "resource_id": resourceID, "access_level": accessLevel},
+		},
+	}
+}
+
+func (m *MachineTestSuite) createMachine(t *testing.T, sessionToken string, body map[string]any) *machineResponse {
+	t.Helper()
+
+	resp := m.doRequest(t, http.MethodPost, testdata.EndpointMachines, body, sessionToken, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var machine machineResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&machine))
+	return &machine
+}
+
+func (m *MachineTestSuite) testCreateValidation(t *testing.T) {
+	maintainerID := m.seeder.ProvisionUser(t, "machine-ns-maintainer", "machine.ns.maintainer@t.com", "Maintainer")
+	nsID := m.seeder.CreateNamespace(t, "machine-validation", "", "Team", false, maintainerID)
+	m.createMachine(t, m.seeder.AdminToken(t), machineBody("existing-machine", "Namespace", nsID, "Guest"))
+
+	tcs := []struct {
+		name       string
+		body       map[string]any
+		statusCode int
+	}{
+		{"Invalid username", machineBody("CI Machine", "Namespace", nsID, "Guest"), http.StatusBadRequest},
+		{"No access", map[string]any{"username": "ci-machine"}, http.StatusBadRequest},
+		{"Maintainer access", machineBody("ci-machine", "Namespace", nsID, "Maintainer"), http.StatusBadRequest},
+		{"Invalid resource type", machineBody("ci-machine", "Upstream", nsID, "Guest"), http.StatusBadRequest},
+		{"Duplicate resource", map[string]any{
+			"username": "ci-machine",
+			"access": []map[string]any{
+				{"resource_type": "Namespace", "resource_id": nsID, "access_level": "Guest"},
+				{"resource_type": "Namespace", "resource_id": nsID, "access_level": "Developer"},
+			},
+		}, http.StatusBadRequest},
+		{"Too long expiry", func() map[string]any {
+			body := machineBody("ci-machine", "Namespace", nsID, "Guest")
+			body["secret_expires_in_days"] = 366
+			return body
+		}(), http.StatusBadRequest},
+		{"Duplicate username", machineBody("existing-machine", "Namespace", nsID, "Guest"), http.StatusConflict},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := m.doRequest(t, http.MethodPost, testdata.EndpointMachines, tc.body, m.seeder.AdminToken(t), "")
+			defer resp.Body.Close()
+			helpers.AssertStatusCode(t, resp, tc.statusCode)
+		})
+	}
+
+	t.Run("Developer can't create", func(t *testing.T) {
+		m.seeder.ProvisionUser(t, "machine-developer", "machine.developer@t.com", "Developer")
+		resp := m.doRequest(t, http.MethodPost, testdata.EndpointMachines,
+			machineBody("ci-machine", "Namespace", nsID, "Guest"),
+			m.seeder.UserToken(t, "machine-developer", "Developer"), "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+}
+
+func (m *MachineTestSuite) testLifecycle(t *testing.T) {
+	maintainerID := m.seeder.ProvisionUser(t, "machine-ns-maintainer", "machine.ns.maintainer@t.com", "Maintainer")
+	nsID := m.seeder.CreateNamespace(t, "machine-lifecycle", "", "Team", false, maintainerID)
+	adminToken := m.seeder.AdminToken(t)
+
+	created := m.createMachine(t, adminToken, machineBody("lifecycle-machine", "Namespace", nsID, "Developer"))
+	require.NotNil(t, created.Secret)
+	require.True(t, strings.HasPrefix(created.Secret.Secret, "oir_pat_"))
+	require.Len(t, created.Access, 1)
+	assert.Equal(t, nsID, created.Access[0].ResourceID)
+	assert.Equal(t, "Developer", created.Access[0].AccessLevel)
+
+	t.Run("Password login is rejected", func(t *testing.T) {
+		for range 6 {
+			resp := m.doRequest(t, http.MethodPost, testdata.EndpointLogin,
+				map[string]any{"username": "lifecycle-machine", "password": "SecurePass123!"}, "", "")
+			resp.Body.Close()
+			helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+		}
+
+		resp := m.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUserByID, created.Id), nil,
+			adminToken, "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var user map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&user))
+		assert.Equal(t, false, user["locked"])
+		assert.EqualValues(t, 0, user["failed_attempts"])
+	})
+
+	t.Run("Secret can't access management APIs", func(t *testing.T) {
+		resp := m.doRequest(t, http.MethodGet, testdata.EndpointUsers, nil, "", "Bearer "+created.Secret.Secret)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+
+	t.Run("Role can't be changed", func(t *testing.T) {
+		resp := m.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUserChangeRole, created.Id),
+			map[string]any{"role": "Developer"}, adminToken, "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("Secret is not returned", func(t *testing.T) {
+		resp := m.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointMachineByID, created.Id), nil,
+			adminToken, "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var machine machineResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&machine))
+		require.NotNil(t, machine.Secret)
+		assert.Equal(t, created.Secret.Id, machine.Secret.Id)
+		assert.Empty(t, machine.Secret.Secret)
+	})
+
+	t.Run("Rotate secret", func(t *testing.T) {
+		resp := m.doRequest(t, http.MethodPost, fmt.Sprintf(testdata.EndpointMachineSecret, created.Id), nil,
+			adminToken, "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var rotated machineResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&rotated))
+		require.NotNil(t, rotated.Secret)
+		assert.NotEqual(t, created.Secret.Id, rotated.Secret.Id)
+		assert.True(t, strings.HasPrefix(rotated.Secret.Secret, "oir_pat_"))
+
+		resp = m.doRequest(t, http.MethodGet, testdata.EndpointUsers, nil, "", "Bearer "+created.Secret.Secret)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Delete machine", func(t *testing.T) {
+		resp := m.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointMachineByID, created.Id), nil,
+			adminToken, "")
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		resp = m.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointMachineByID, created.Id), nil,
+			adminToken, "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Regular user is not a machine", func(t *testing.T) {
+		userID := m.seeder.ProvisionUser(t, "machine-lifecycle-user", "machine.lifecycle@t.com", "Developer")
+		resp := m.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointMachineByID, userID), nil,
+			adminToken, "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+}
+
+func (m *MachineTestSuite) testMaintainerScope(t *testing.T) {
+	maintainerID := m.seeder.ProvisionUser(t, "machine-maintainer", "machine.maintainer@t.com", "Maintainer")
+	maintainerToken := m.seeder.UserToken(t, "machine-maintainer", "Maintainer")
+	otherMaintainerID := m.seeder.ProvisionUser(t, "machine-ns-maintainer", "machine.ns.maintainer@t.com",
+		"Maintainer")
+
+	ownNsID := m.seeder.CreateNamespace(t, "machine-own", "", "Team", false, maintainerID)
+	otherNsID := m.seeder.CreateNamespace(t, "machine-other", "", "Team", false, otherMaintainerID)
+	repoID := m.seeder.CreateRepository(t, "app", "", "admin", ownNsID, false)
+
+	own := m.createMachine(t, maintainerToken, machineBody("maintainer-machine", "Namespace", ownNsID, "Guest"))
+	m.createMachine(t, maintainerToken, machineBody("maintainer-repo-machine", "Repository", repoID, "Developer"))
+	other := m.createMachine(t, m.seeder.AdminToken(t),
+		machineBody("other-machine", "Namespace", otherNsID, "Guest"))
+
+	t.Run("Namespace of another maintainer", func(t *testing.T) {
+		resp := m.doRequest(t, http.MethodPost, testdata.EndpointMachines,
+			machineBody("maintainer-other-machine", "Namespace", otherNsID, "Guest"), maintainerToken, "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+
+	t.Run("List managed machines", func(t *testing.T) {
+		resp := m.doRequest(t, http.MethodGet, testdata.EndpointMachines, nil, maintainerToken, "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var res struct {
+			Machines []machineResponse `json:"machines"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+
+		usernames := make([]string, 0)
+		for _, machine := range res.Machines {
+			usernames = append(usernames, machine.Username)
+		}
+		assert.ElementsMatch(t, []string{"maintainer-machine", "maintainer-repo-machine"}, usernames)
+	})
+
+	t.Run("Get machine", func(t *testing.T) {
+		resp := m.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointMachineByID, own.Id), nil,
+			maintainerToken, "")
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		resp = m.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointMachineByID, other.Id), nil,
+			maintainerToken, "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+
+	t.Run("Delete machine of another namespace", func(t *testing.T) {
+		resp := m.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointMachineByID, other.Id), nil,
+			maintainerToken, "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+}
+
+func (m *MachineTestSuite) testRegistryAccess(t *testing.T) {
+	maintainerID := m.seeder.ProvisionUser(t, "machine-ns-maintainer", "machine.ns.maintainer@t.com", "Maintainer")
+	nsID := m.seeder.CreateNamespace(t, "machine-registry", "", "Team", false, maintainerID)
+	m.seeder.CreateNamespace(t, "machine-registry-other", "", "Team", false, maintainerID)
+	adminToken := m.seeder.AdminToken(t)
+
+	fakeUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token":"machine-registry-token","expires_in":300}`))
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer fakeUpstream.Close()
+
+	port := int(helpers.FindFreePort())
+	body := upstreamBody("machine-registry-upstream", port)
+	body["upstream_url"] = fakeUpstream.URL
+	body["auth_config"].(map[string]any)["token_endpoint"] = fakeUpstream.URL + "/token"
+
+	resp := m.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, body, adminToken, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var upstream struct {
+		RegID string `json:"reg_id"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&upstream))
+	waitForListener(t, port)
+
+	// namespace of the upstream cache has the same name as the hosted namespace
+	upstreamNsID := m.seeder.CreateUpstreamNamespace(t, upstream.RegID, "machine-registry")
+
+	guest := m.createMachine(t, adminToken, machineBody("registry-guest-machine", "Namespace", nsID, "Guest"))
+	developer := m.createMachine(t, adminToken,
+		machineBody("registry-developer-machine", "Namespace", nsID, "Developer"))
+	upstreamGuest := m.createMachine(t, adminToken,
+		machineBody("registry-upstream-machine", "Namespace", upstreamNsID, "Guest"))
+
+	tcs := []struct {
+		name       string
+		method     string
+		path       string
+		secret     string
+		statusCode int
+	}{
+		{"API version check", http.MethodGet, "/v2/", guest.Secret.Secret, http.StatusOK},
+		{"Guest pulls granted namespace", http.MethodGet, "/v2/machine-registry/app/manifests/latest",
+			upstreamGuest.Secret.Secret, http.StatusNotFound},
+		{"Guest pulls another namespace", http.MethodGet, "/v2/machine-registry-other/app/manifests/latest",
+			upstreamGuest.Secret.Secret, http.StatusForbidden},
+		{"Guest pulls unknown namespace", http.MethodGet, "/v2/unknown/app/manifests/latest",
+			upstreamGuest.Secret.Secret, http.StatusForbidden},
+		{"Guest of hosted namespace pulls upstream namespace", http.MethodGet,
+			"/v2/machine-registry/app/manifests/latest", guest.Secret.Secret, http.StatusForbidden},
+		{"Guest can't push", http.MethodPost, "/v2/machine-registry/app/blobs/uploads/",
+			upstreamGuest.Secret.Secret, http.StatusForbidden},
+		{"Developer of hosted namespace pushes upstream namespace", http.MethodPost,
+			"/v2/machine-registry/app/blobs/uploads/", developer.Secret.Secret, http.StatusForbidden},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			m.assertRegistryRequest(t, tc.method, fmt.Sprintf("http://localhost:%d%s", port, tc.path), tc.secret,
+				tc.statusCode)
+		})
+	}
+
+	t.Run("SinglePort", func(t *testing.T) {
+		tcs := []struct {
+			name       string
+			method     string
+			path       string
+			secret     string
+			statusCode int
+		}{
+			{"API version check", http.MethodGet, "/v2/", guest.Secret.Secret, http.StatusOK},
+			{"API version check of upstream", http.MethodGet, "/v2/machine-registry-upstream/",
+				upstreamGuest.Secret.Secret, http.StatusOK},
+			{"Guest pulls hosted namespace", http.MethodGet, "/v2/machine-registry/app/manifests/latest",
+				guest.Secret.Secret, http.StatusNotFound},
+			{"Guest pulls another hosted namespace", http.MethodGet,
+				"/v2/machine-registry-other/app/manifests/latest", guest.Secret.Secret, http.StatusForbidden},
+			{"Guest of upstream namespace pulls hosted namespace", http.MethodGet,
+				"/v2/machine-registry/app/manifests/latest", upstreamGuest.Secret.Secret, http.StatusForbidden},
+			{"Guest pulls upstream namespace", http.MethodGet,
+				"/v2/machine-registry-upstream/machine-registry/app/manifests/latest", upstreamGuest.Secret.Secret,
+				http.StatusNotFound},
+			{"Guest of hosted namespace pulls upstream namespace", http.MethodGet,
+				"/v2/machine-registry-upstream/machine-registry/app/manifests/latest", guest.Secret.Secret,
+				http.StatusForbidden},
+			{"Guest can't push hosted namespace", http.MethodPost, "/v2/machine-registry/app/blobs/uploads/",
+				guest.Secret.Secret, http.StatusForbidden},
+			{"Developer pushes hosted namespace", http.MethodPost, "/v2/machine-registry/app/blobs/uploads/",
+				developer.Secret.Secret, http.StatusAccepted},
+			{"Developer pushes another hosted namespace", http.MethodPost,
+				"/v2/machine-registry-other/app/blobs/uploads/", developer.Secret.Secret, http.StatusForbidden},
+		}
+
+		for _, tc := range tcs {
+			t.Run(tc.name, func(t *testing.T) {
+				m.assertRegistryRequest(t, tc.method, m.registryURL+tc.path, tc.secret, tc.statusCode)
+			})
+		}
+	})
+}
+
+func (m *MachineTestSuite) assertRegistryRequest(t *testing.T, method, url, secret string, statusCode int) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+secret)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, statusCode)
+}
@@ -0,0 +1,506 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type OIDCTestSuite struct {
+	name        string
+	apiVersion  string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+	provider    *helpers.MockOIDCProvider
+}
+
+func NewOIDCTestSuite(seeder *seeder.TestDataSeeder, baseURL string, provider *helpers.MockOIDCProvider) *OIDCTestSuite {
+	return &OIDCTestSuite{
+		name:        "OIDCAPI",
+		apiVersion:  "v1",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+		provider:    provider,
+	}
+}
+
+func (o *OIDCTestSuite) Run(t *testing.T) {
+	t.Run("LoginRedirect", o.testLoginRedirect)
+	t.Run("Provisioning", o.testProvisioning)
+	t.Run("RoleMapping", o.testRoleMapping)
+	t.Run("InvalidCallback", o.testInvalidCallback)
+	t.Run("AccountConflict", o.testAccountConflict)
+	t.Run("TwoFactor", o.testTwoFactor)
+}
+
+func (o *OIDCTestSuite) Name() string {
+	return o.name
+}
+
+func (o *OIDCTestSuite) APIVersion() string {
+	return o.apiVersion
+}
+
+// newBrowser returns a client which keeps cookies but doesn't follow redirects, so each step of the login can be
+// checked.
+func (o *OIDCTestSuite) newBrowser(t *testing.T) *http.Client {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// startLogin starts the login from the registry and returns the callback URL which the identity provider redirects
+// to after the user logs in.
+func (o *OIDCTestSuite) startLogin(t *testing.T, browser *http.Client) string {
+	t.Helper()
+
+	resp, err := browser.Get(o.testBaseURL + testdata.EndpointOIDCLogin)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	resp, err = browser.Get(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callbackURL := resp.Header.Get("Location")
+	require.True(t, strings.HasPrefix(callbackURL, o.testBaseURL+testdata.EndpointOIDCCallback))
+	return callbackURL
+}
+
+// login logs the user of the claims in and returns the response of the callback
+func (o *OIDCTestSuite) login(t *testing.T, browser *http.Client, claims map[string]any) *http.Response {
+	t.Helper()
+
+	o.provider.SetNextUser(claims)
+	callbackURL := o.startLogin(t, browser)
+
+	resp, err := browser.Get(callbackURL)
+	require.NoError(t, err)
+	return resp
+}
+
+func (o *OIDCTestSuite) getUser(t *testing.T, username string) map[string]any {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, o.testBaseURL+fmt.Sprintf(testdata.EndpointUserByID, username), nil)
+	require.NoError(t, err)
+	helpers.SetAuthCookie(req, o.seeder.AdminToken(t))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var user map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&user))
+	return user
+}
+
+// namespaceAccess returns the access level of the user to the namespace. It is empty if the user doesn't have access.
+func (o *OIDCTestSuite) namespaceAccess(t *testing.T, nsID, userID string) string {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, o.testBaseURL+fmt.Sprintf(testdata.EndpointNamespaceUsers, nsID), nil)
+	require.NoError(t, err)
+	helpers.SetAuthCookie(req, o.seeder.AdminToken(t))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var res struct {
+		Entities []struct {
+			UserId      string `json:"user_id"`
+			AccessLevel string `json:"access_level"`
+		} `json:"entities"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+
+	for _, entity := range res.Entities {
+		if entity.UserId == userID {
+			return entity.AccessLevel
+		}
+	}
+	return ""
+}
+
+func (o *OIDCTestSuite) testLoginRedirect(t *testing.T) {
+	browser := o.newBrowser(t)
+
+	resp, err := browser.Get(o.testBaseURL + testdata.EndpointOIDCLogin)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	authURL, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, o.provider.Issuer()+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+
+	query := authURL.Query()
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, o.provider.ClientID, query.Get("client_id"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.NotEmpty(t, query.Get("code_challenge"))
+	assert.NotEmpty(t, query.Get("nonce"))
+	assert.Contains(t, strings.Fields(query.Get("scope")), "openid")
+
+	var stateCookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == constants.OIDCStateCookie {
+			stateCookie = c
+		}
+	}
+	require.NotNil(t, stateCookie)
+	assert.Equal(t, query.Get("state"), stateCookie.Value)
+	assert.True(t, stateCookie.HttpOnly)
+}
+
+func (o *OIDCTestSuite) testProvisioning(t *testing.T) {
+	maintainerID := o.seeder.ProvisionUser(t, "sso-ns-maintainer", "sso.ns.maintainer@t.com", "Maintainer")
+	nsID := o.seeder.CreateNamespace(t, "sso-team", "", "Team", false, maintainerID)
+
+	claims := map[string]any{
+		"sub":                "sso-subject-1",
+		"preferred_username": "sso-developer",
+		"email":              "sso.developer@t.com",
+		"name":               "SSO Developer",
+		"groups":             []string{"registry-developers", "team-sso"},
+	}
+
+	browser := o.newBrowser(t)
+	var userID string
+
+	t.Run("First login provisions the user", func(t *testing.T) {
+		resp := o.login(t, browser, claims)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		assert.Equal(t, "/", resp.Header.Get("Location"))
+
+		cookies := map[string]string{}
+		for _, c := range resp.Cookies() {
+			cookies[c.Name] = c.Value
+		}
+		assert.NotEmpty(t, cookies[constants.AuthTokenCookie])
+		assert.NotEmpty(t, cookies[constants.RefreshTokenCookie])
+
+		user := o.getUser(t, "sso-developer")
+		userID = user["id"].(string)
+		assert.Equal(t, "sso.developer@t.com", user["email"])
+		assert.Equal(t, "SSO Developer", user["display_name"])
+		assert.Equal(t, constants.RoleDeveloper, user["role"])
+	})
+
+	t.Run("Session is created", func(t *testing.T) {
+		resp, err := browser.Get(o.testBaseURL + testdata.EndpointSessions)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var res struct {
+			Sessions []struct {
+				GrantType string `json:"grant_type"`
+			} `json:"sessions"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		require.Len(t, res.Sessions, 1)
+		assert.Equal(t, constants.GrantTypeAuthorizationCode, res.Sessions[0].GrantType)
+	})
+
+	t.Run("Mapped namespace access is granted", func(t *testing.T) {
+		assert.Equal(t, constants.AccessLevelDeveloper, o.namespaceAccess(t, nsID, userID))
+	})
+
+	t.Run("Profile is synced on next login", func(t *testing.T) {
+		updated := map[string]any{
+			"sub":                "sso-subject-1",
+			"preferred_username": "sso-developer",
+			"email":              "sso.developer.new@t.com",
+			"name":               "SSO Developer Renamed",
+			"groups":             []string{"registry-developers", "team-sso"},
+		}
+		resp := o.login(t, o.newBrowser(t), updated)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		user := o.getUser(t, "sso-developer")
+		assert.Equal(t, userID, user["id"])
+		assert.Equal(t, "sso.developer.new@t.com", user["email"])
+		assert.Equal(t, "SSO Developer Renamed", user["display_name"])
+	})
+
+	t.Run("Password login is rejected", func(t *testing.T) {
+		reqBody, err := json.Marshal(map[string]any{"username": "sso-developer", "password": "SecurePass123!"})
+		require.NoError(t, err)
+
+		resp, err := http.Post(o.testBaseURL+testdata.EndpointLogin, testdata.ApplicationJson,
+			bytes.NewReader(reqBody))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+}
+
+func (o *OIDCTestSuite) testRoleMapping(t *testing.T) {
+	maintainerID := o.seeder.ProvisionUser(t, "sso-role-maintainer", "sso.role.maintainer@t.com", "Maintainer")
+	nsID := o.seeder.CreateNamespace(t, "sso-role-ns", "", "Team", false, maintainerID)
+
+	claims := func(groups ...string) map[string]any {
+		return map[string]any{
+			"sub":                "sso-subject-2",
+			"preferred_username": "sso-role-user",
+			"email":              "sso.role.user@t.com",
+			"groups":             groups,
+		}
+	}
+
+	t.Run("Default role is assigned without mapped groups", func(t *testing.T) {
+		resp := o.login(t, o.newBrowser(t), claims("unmapped-group"))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		user := o.getUser(t, "sso-role-user")
+		assert.Equal(t, constants.RoleGuest, user["role"])
+		// display name falls back to username
+		assert.Equal(t, "sso-role-user", user["display_name"])
+	})
+
+	var userID string
+	t.Run("Highest mapped role wins", func(t *testing.T) {
+		resp := o.login(t, o.newBrowser(t), claims("registry-developers", "registry-admins"))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		user := o.getUser(t, "sso-role-user")
+		userID = user["id"].(string)
+		assert.Equal(t, constants.RoleAdmin, user["role"])
+	})
+
+	t.Run("Access not allowed for the new role is revoked", func(t *testing.T) {
+		resp := o.login(t, o.newBrowser(t), claims("registry-developers"))
+		resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		o.seeder.GrantAccess(t, nsID, constants.ResourceTypeNamespace, userID, constants.AccessLevelDeveloper)
+		require.Equal(t, constants.AccessLevelDeveloper, o.namespaceAccess(t, nsID, userID))
+
+		resp = o.login(t, o.newBrowser(t), claims("unmapped-group"))
+		resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		assert.Equal(t, constants.RoleGuest, o.getUser(t, "sso-role-user")["role"])
+		assert.Empty(t, o.namespaceAccess(t, nsID, userID))
+	})
+
+	t.Run("Login is rejected without a role", func(t *testing.T) {
+		oidcConfig := config.GetOIDCConfig()
+		defaultRole := oidcConfig.DefaultRole
+		oidcConfig.DefaultRole = ""
+		defer func() { oidcConfig.DefaultRole = defaultRole }()
+
+		resp := o.login(t, o.newBrowser(t), map[string]any{
+			"sub":                "sso-subject-3",
+			"preferred_username": "sso-no-role",
+			"email":              "sso.no.role@t.com",
+		})
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+}
+
+func (o *OIDCTestSuite) testInvalidCallback(t *testing.T) {
+	claims := map[string]any{
+		"sub":                "sso-subject-4",
+		"preferred_username": "sso-callback-user",
+		"email":              "sso.callback.user@t.com",
+	}
+
+	t.Run("State doesn't match", func(t *testing.T) {
+		o.provider.SetNextUser(claims)
+		browser := o.newBrowser(t)
+		callbackURL := o.startLogin(t, browser)
+
+		// state of another login
+		other := o.startLogin(t, o.newBrowser(t))
+		otherURL, err := url.Parse(other)
+		require.NoError(t, err)
+		tampered, err := url.Parse(callbackURL)
+		require.NoError(t, err)
+		query := tampered.Query()
+		query.Set("state", otherURL.Query().Get("state"))
+		tampered.RawQuery = query.Encode()
+
+		resp, err := browser.Get(tampered.String())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Callback from another browser", func(t *testing.T) {
+		o.provider.SetNextUser(claims)
+		callbackURL := o.startLogin(t, o.newBrowser(t))
+
+		resp, err := o.newBrowser(t).Get(callbackURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Login request can be used only once", func(t *testing.T) {
+		o.provider.SetNextUser(claims)
+		browser := o.newBrowser(t)
+		callbackURL := o.startLogin(t, browser)
+
+		u, err := url.Parse(callbackURL)
+		require.NoError(t, err)
+
+		resp, err := browser.Get(callbackURL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		// replay with the state cookie
+		req, err := http.NewRequest(http.MethodGet, callbackURL, nil)
+		require.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: constants.OIDCStateCookie, Value: u.Query().Get("state")})
+
+		resp, err = o.newBrowser(t).Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Identity provider returns error", func(t *testing.T) {
+		resp, err := o.newBrowser(t).Get(o.testBaseURL + testdata.EndpointOIDCCallback +
+			"?error=access_denied&state=unknown")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Missing email", func(t *testing.T) {
+		resp := o.login(t, o.newBrowser(t), map[string]any{
+			"sub":                "sso-subject-5",
+			"preferred_username": "sso-no-email",
+		})
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+}
+
+func (o *OIDCTestSuite) testAccountConflict(t *testing.T) {
+	o.seeder.ProvisionUserWithPassword(t, "sso-local-user", "sso.local.user@t.com", "Developer", "SecurePass123!")
+
+	tcs := []struct {
+		name   string
+		claims map[string]any
+	}{
+		{"Same username", map[string]any{
+			"sub":                "sso-subject-6",
+			"preferred_username": "sso-local-user",
+			"email":              "sso.other@t.com",
+		}},
+		{"Same email", map[string]any{
+			"sub":                "sso-subject-7",
+			"preferred_username": "sso-other-user",
+			"email":              "sso.local.user@t.com",
+		}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := o.login(t, o.newBrowser(t), tc.claims)
+			defer resp.Body.Close()
+			helpers.AssertStatusCode(t, resp, http.StatusConflict)
+		})
+	}
+
+	t.Run("Local account isn't linked", func(t *testing.T) {
+		reqBody, err := json.Marshal(map[string]any{"username": "sso-local-user", "password": "SecurePass123!"})
+		require.NoError(t, err)
+
+		resp, err := http.Post(o.testBaseURL+testdata.EndpointLogin, testdata.ApplicationJson,
+			bytes.NewReader(reqBody))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	})
+}
+
+func (o *OIDCTestSuite) testTwoFactor(t *testing.T) {
+	claims := map[string]any{
+		"sub":                "sso-subject-2fa",
+		"preferred_username": "sso-2fa-user",
+		"email":              "sso.2fa.user@t.com",
+		"groups":             []string{"registry-developers"},
+	}
+
+	setRoleRequirement(t, o.testBaseURL, o.seeder.AdminToken(t), constants.RoleDeveloper, true)
+	defer setRoleRequirement(t, o.testBaseURL, o.seeder.AdminToken(t), constants.RoleDeveloper, false)
+
+	browser := o.newBrowser(t)
+	var token string
+	t.Run("Callback redirects to the second factor", func(t *testing.T) {
+		resp := o.login(t, browser, claims)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		for _, c := range resp.Cookies() {
+			assert.NotEqual(t, constants.AuthTokenCookie, c.Name, "session must not be started before the second factor")
+			assert.NotEqual(t, constants.RefreshTokenCookie, c.Name)
+		}
+
+		location, err := url.Parse(resp.Header.Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "/", location.Path)
+
+		fragment, err := url.ParseQuery(location.Fragment)
+		require.NoError(t, err)
+		token = fragment.Get("two_factor_token")
+		require.NotEmpty(t, token)
+		assert.Equal(t, "true", fragment.Get("enrollment_required"))
+	})
+
+	t.Run("Second factor completes the login", func(t *testing.T) {
+		resp := enrollAndCompleteLogin(t, browser, o.testBaseURL, token)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		sessionsResp, err := browser.Get(o.testBaseURL + testdata.EndpointSessions)
+		require.NoError(t, err)
+		defer sessionsResp.Body.Close()
+		require.Equal(t, http.StatusOK, sessionsResp.StatusCode)
+
+		var res struct {
+			Sessions []struct {
+				GrantType string `json:"grant_type"`
+			} `json:"sessions"`
+		}
+		require.NoError(t, json.NewDecoder(sessionsResp.Body).Decode(&res))
+		require.Len(t, res.Sessions, 1)
+		assert.Equal(t, constants.GrantTypeAuthorizationCode, res.Sessions[0].GrantType)
+	})
+}
@@ -0,0 +1,312 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type PasswordResetTestSuite struct {
+	name        string
+	apiVersion  string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+}
+
+func NewPasswordResetTestSuite(seeder *seeder.TestDataSeeder, baseURL string) *PasswordResetTestSuite {
+	return &PasswordResetTestSuite{
+		name:        "PasswordResetAPI",
+		apiVersion:  "v1",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+	}
+}
+
+func (p *PasswordResetTestSuite) Run(t *testing.T) {
+	t.Run("ResetFlow", p.testResetFlow)
+	t.Run("UnknownAccount", p.testUnknownAccount)
+	t.Run("LockedAccounts", p.testLockedAccounts)
+	t.Run("AccountRateLimit", p.testAccountRateLimit)
+	t.Run("IPRateLimit", p.testIPRateLimit)
+}
+
+func (p *PasswordResetTestSuite) Name() string {
+	return p.name
+}
+
+func (p *PasswordResetTestSuite) APIVersion() string {
+	return p.apiVersion
+}
+
+type forgotPasswordResponse struct {
+	Message string `json:"message"`
+	Token   string `json:"token"`
+}
+
+// clientFrom returns a client which connects from the loopback address, so requests have it as the remote address
+func clientFrom(t *testing.T, clientIP string) *http.Client {
+	t.Helper()
+
+	localAddr := net.ParseIP(clientIP)
+	require.True(t, localAddr.IsLoopback(), "client IP must be a loopback address")
+
+	dialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: localAddr}}
+	return &http.Client{Transport: &http.Transport{DialContext: dialer.DialContext, DisableKeepAlives: true}}
+}
+
+// doRequest sends the request from the client IP. Each test uses its own IP, so they don't share rate limits.
+func (p *PasswordResetTestSuite) doRequest(t *testing.T, method, endpoint, clientIP string, body any,
+	cookie *http.Cookie) *http.Response {
+	t.Helper()
+
+	return p.doRequestWithHeaders(t, method, endpoint, clientIP, body, cookie, nil)
+}
+
+func (p *PasswordResetTestSuite) doRequestWithHeaders(t *testing.T, method, endpoint, clientIP string, body any,
+	cookie *http.Cookie, headers map[string]string) *http.Response {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(reqBody)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, p.testBaseURL+endpoint, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := clientFrom(t, clientIP).Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+// forgotPassword requests a password reset. Token is set in development mode if the link was sent.
+func (p *PasswordResetTestSuite) forgotPassword(t *testing.T, clientIP, username string) *forgotPasswordResponse {
+	t.Helper()
+
+	resp := p.doRequest(t, http.MethodPost, testdata.EndpointForgotPassword, clientIP,
+		map[string]string{"username": username}, nil)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	var res forgotPasswordResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	assert.NotEmpty(t, res.Message)
+	return &res
+}
+
+func (p *PasswordResetTestSuite) resetPassword(t *testing.T, clientIP, token, password string) *http.Response {
+	t.Helper()
+
+	return p.doRequest(t, http.MethodPost, testdata.EndpointResetPassword, clientIP,
+		map[string]string{"token": token, "password": password}, nil)
+}
+
+func (p *PasswordResetTestSuite) login(t *testing.T, username, password string) *http.Response {
+	t.Helper()
+
+	return p.doRequest(t, http.MethodPost, testdata.EndpointLogin, "127.0.10.1",
+		map[string]string{"username": username, "password": password}, nil)
+}
+
+func (p *PasswordResetTestSuite) testResetFlow(t *testing.T) {
+	// links are usually opened from another device, and the limit of an IP covers both endpoints
+	clientIP, resetIP := "127.0.11.1", "127.0.11.2"
+	p.seeder.ProvisionUserWithPassword(t, "reset-flow-user", "reset.flow.user@t.com", "Developer", "OldPassword123!")
+
+	loginResp := p.login(t, "reset-flow-user", "OldPassword123!")
+	loginResp.Body.Close()
+	require.Equal(t, http.StatusOK, loginResp.StatusCode)
+	authCookie, _ := findCookies(t, loginResp)
+
+	var token string
+	t.Run("Request sends link", func(t *testing.T) {
+		res := p.forgotPassword(t, clientIP, "reset-flow-user")
+		require.NotEmpty(t, res.Token)
+		assert.Contains(t, res.Token, constants.PasswordResetTokenPrefix)
+		token = res.Token
+	})
+
+	t.Run("Weak password", func(t *testing.T) {
+		resp := p.resetPassword(t, resetIP, token, "weak")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		resp := p.resetPassword(t, resetIP, constants.PasswordResetTokenPrefix+"unknown", "NewPassword123!")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("New request replaces the link", func(t *testing.T) {
+		res := p.forgotPassword(t, clientIP, "reset-flow-user")
+		require.NotEmpty(t, res.Token)
+
+		resp := p.resetPassword(t, resetIP, token, "NewPassword123!")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+
+		token = res.Token
+	})
+
+	t.Run("Reset password", func(t *testing.T) {
+		resp := p.resetPassword(t, resetIP, token, "NewPassword123!")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		oldResp := p.login(t, "reset-flow-user", "OldPassword123!")
+		defer oldResp.Body.Close()
+		helpers.AssertStatusCode(t, oldResp, http.StatusUnauthorized)
+
+		newResp := p.login(t, "reset-flow-user", "NewPassword123!")
+		defer newResp.Body.Close()
+		helpers.AssertStatusCode(t, newResp, http.StatusOK)
+	})
+
+	t.Run("Sessions are revoked", func(t *testing.T) {
+		resp := p.doRequest(t, http.MethodGet, testdata.EndpointCurrentUser, resetIP, nil, authCookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Link works once", func(t *testing.T) {
+		resp := p.resetPassword(t, resetIP, token, "OtherPassword123!")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+}
+
+func (p *PasswordResetTestSuite) testUnknownAccount(t *testing.T) {
+	clientIP := "127.0.12.1"
+
+	t.Run("Unknown account gets the same response", func(t *testing.T) {
+		res := p.forgotPassword(t, clientIP, "reset-unknown-user")
+		assert.Empty(t, res.Token)
+	})
+
+	t.Run("Missing username", func(t *testing.T) {
+		resp := p.doRequest(t, http.MethodPost, testdata.EndpointForgotPassword, clientIP,
+			map[string]string{}, nil)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+}
+
+func (p *PasswordResetTestSuite) testLockedAccounts(t *testing.T) {
+	clientIP := "127.0.13.1"
+
+	t.Run("Reset unlocks account locked by failed attempts", func(t *testing.T) {
+		p.seeder.ProvisionUserWithPassword(t, "reset-failed-user", "reset.failed.user@t.com", "Developer",
+			"OldPassword123!")
+
+		for range constants.MaxFailedLoginAttempts + 1 {
+			resp := p.login(t, "reset-failed-user", "WrongPassword123!")
+			resp.Body.Close()
+		}
+		lockedResp := p.login(t, "reset-failed-user", "OldPassword123!")
+		lockedResp.Body.Close()
+		require.Equal(t, http.StatusForbidden, lockedResp.StatusCode)
+
+		res := p.forgotPassword(t, clientIP, "reset-failed-user")
+		require.NotEmpty(t, res.Token)
+
+		resp := p.resetPassword(t, clientIP, res.Token, "NewPassword123!")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		loginResp := p.login(t, "reset-failed-user", "NewPassword123!")
+		defer loginResp.Body.Close()
+		helpers.AssertStatusCode(t, loginResp, http.StatusOK)
+	})
+
+	t.Run("Account locked by admin", func(t *testing.T) {
+		userID := p.seeder.ProvisionUserWithPassword(t, "reset-admin-locked-user", "reset.admin.locked.user@t.com",
+			"Developer", "OldPassword123!")
+
+		res := p.forgotPassword(t, clientIP, "reset-admin-locked-user")
+		require.NotEmpty(t, res.Token)
+
+		lockResp := p.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUserLock, userID), clientIP, nil,
+			&http.Cookie{Name: constants.AuthTokenCookie, Value: p.seeder.AdminToken(t)})
+		lockResp.Body.Close()
+		require.Equal(t, http.StatusOK, lockResp.StatusCode)
+
+		// links sent before the account was locked don't work
+		resp := p.resetPassword(t, clientIP, res.Token, "NewPassword123!")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+
+		res = p.forgotPassword(t, clientIP, "reset-admin-locked-user")
+		assert.Empty(t, res.Token)
+	})
+}
+
+func (p *PasswordResetTestSuite) testAccountRateLimit(t *testing.T) {
+	p.seeder.ProvisionUserWithPassword(t, "reset-limited-user", "reset.limited.user@t.com", "Developer",
+		"OldPassword123!")
+
+	// requests from different IPs count towards the limit of the account
+	for i := range 3 {
+		res := p.forgotPassword(t, fmt.Sprintf("127.0.14.%d", i+1), "reset-limited-user")
+		require.NotEmpty(t, res.Token)
+	}
+
+	// the response doesn't change, but no link is sent
+	res := p.forgotPassword(t, "127.0.14.10", "reset-limited-user")
+	assert.Empty(t, res.Token)
+}
+
+func (p *PasswordResetTestSuite) testIPRateLimit(t *testing.T) {
+	clientIP := "127.0.15.1"
+
+	for range 5 {
+		p.forgotPassword(t, clientIP, "reset-ip-limited-user")
+	}
+
+	t.Run("Request", func(t *testing.T) {
+		resp := p.doRequest(t, http.MethodPost, testdata.EndpointForgotPassword, clientIP,
+			map[string]string{"username": "reset-ip-limited-user"}, nil)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusTooManyRequests)
+		assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		resp := p.resetPassword(t, clientIP, constants.PasswordResetTokenPrefix+"unknown", "NewPassword123!")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusTooManyRequests)
+	})
+
+	t.Run("X-Forwarded-For doesn't bypass the limit", func(t *testing.T) {
+		resp := p.doRequestWithHeaders(t, http.MethodPost, testdata.EndpointForgotPassword, clientIP,
+			map[string]string{"username": "reset-ip-limited-user"}, nil,
+			map[string]string{"X-Forwarded-For": "203.0.113.10"})
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusTooManyRequests)
+	})
+
+	t.Run("Other IPs are not limited", func(t *testing.T) {
+		p.forgotPassword(t, "127.0.15.2", "reset-ip-limited-user")
+	})
+}
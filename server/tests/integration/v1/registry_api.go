@@ -0,0 +1,185 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type HostedRegistryTestSuite struct {
+	name        string
+	apiVersion  string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+	// registryURL serves the hosted registry
+	registryURL string
+}
+
+func NewHostedRegistryTestSuite(seeder *seeder.TestDataSeeder, baseURL, registryURL string) *HostedRegistryTestSuite {
+	return &HostedRegistryTestSuite{
+		name:        "HostedRegistry",
+		apiVersion:  "v1",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+		registryURL: registryURL,
+	}
+}
+
+func (h *HostedRegistryTestSuite) Run(t *testing.T) {
+	t.Run("BlobUpload", h.testBlobUpload)
+	t.Run("ManifestPush", h.testManifestPush)
+}
+
+func (h *HostedRegistryTestSuite) Name() string {
+	return h.name
+}
+
+func (h *HostedRegistryTestSuite) APIVersion() string {
+	return h.apiVersion
+}
+
+func digestOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// pushBlob uploads the blob in a single request and returns its digest.
+func (h *HostedRegistryTestSuite) pushBlob(t *testing.T, name string, content []byte) string {
+	t.Helper()
+
+	resp, err := http.Post(fmt.Sprintf("%s/v2/%s/blobs/uploads/", h.registryURL, name), "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusAccepted)
+
+	digest := digestOf(content)
+	req, err := http.NewRequest(http.MethodPut, resp.Header.Get("Location")+"?digest="+digest,
+		bytes.NewReader(content))
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusCreated)
+
+	return digest
+}
+
+// pushManifest pushes a manifest of an image with the config as the tag and returns the manifest digest.
+func (h *HostedRegistryTestSuite) pushManifest(t *testing.T, name, tag string, config []byte) string {
+	t.Helper()
+
+	layer := []byte("shared layer")
+	layerDigest := h.pushBlob(t, name, layer)
+	configDigest := h.pushBlob(t, name, config)
+
+	manifest, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config": map[string]any{
+			"mediaType": "application/vnd.oci.image.config.v1+json",
+			"digest":    configDigest,
+			"size":      len(config),
+		},
+		"layers": []map[string]any{{
+			"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+			"digest":    layerDigest,
+			"size":      len(layer),
+		}},
+	})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/v2/%s/manifests/%s", h.registryURL, name, tag),
+		bytes.NewReader(manifest))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusCreated)
+
+	return digestOf(manifest)
+}
+
+// manifestDigest pulls the manifest and returns its digest.
+func (h *HostedRegistryTestSuite) manifestDigest(t *testing.T, name, reference string) string {
+	t.Helper()
+
+	resp, err := http.Get(fmt.Sprintf("%s/v2/%s/manifests/%s", h.registryURL, name, reference))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+	content, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return digestOf(content)
+}
+
+func (h *HostedRegistryTestSuite) testBlobUpload(t *testing.T) {
+	content := []byte("monolithic upload")
+	digest := h.pushBlob(t, "hosted-push/blobs", content)
+
+	t.Run("Pull blob of monolithic upload", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/v2/hosted-push/blobs/blobs/%s", h.registryURL, digest))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		pulled, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, content, pulled)
+	})
+
+	t.Run("Upload same blob again", func(t *testing.T) {
+		assert.Equal(t, digest, h.pushBlob(t, "hosted-push/blobs", content))
+
+		req, err := http.NewRequest(http.MethodHead, fmt.Sprintf("%s/v2/hosted-push/blobs/blobs/%s", h.registryURL,
+			digest), nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	})
+}
+
+func (h *HostedRegistryTestSuite) testManifestPush(t *testing.T) {
+	first := h.pushManifest(t, "hosted-push/app", "1.0", []byte(`{"architecture":"amd64","os":"linux","v":"1"}`))
+
+	t.Run("Pull pushed tag", func(t *testing.T) {
+		assert.Equal(t, first, h.manifestDigest(t, "hosted-push/app", "1.0"))
+		assert.Equal(t, first, h.manifestDigest(t, "hosted-push/app", first))
+	})
+
+	t.Run("Push same manifest with another tag", func(t *testing.T) {
+		assert.Equal(t, first, h.pushManifest(t, "hosted-push/app", "latest",
+			[]byte(`{"architecture":"amd64","os":"linux","v":"1"}`)))
+
+		assert.Equal(t, first, h.manifestDigest(t, "hosted-push/app", "latest"))
+		assert.Equal(t, first, h.manifestDigest(t, "hosted-push/app", "1.0"))
+	})
+
+	t.Run("Push existing tag moves it", func(t *testing.T) {
+		second := h.pushManifest(t, "hosted-push/app", "latest", []byte(`{"architecture":"amd64","os":"linux","v":"2"}`))
+		require.NotEqual(t, first, second)
+
+		assert.Equal(t, second, h.manifestDigest(t, "hosted-push/app", "latest"))
+		assert.Equal(t, first, h.manifestDigest(t, "hosted-push/app", "1.0"))
+	})
+
+	t.Run("Push same manifest to another repository", func(t *testing.T) {
+		assert.Equal(t, first, h.pushManifest(t, "hosted-push/other", "1.0",
+			[]byte(`{"architecture":"amd64","os":"linux","v":"1"}`)))
+
+		assert.Equal(t, first, h.manifestDigest(t, "hosted-push/other", "1.0"))
+	})
+}
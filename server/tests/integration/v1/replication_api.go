@@ -0,0 +1,637 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ReplicationTestSuite struct {
+	apiVersion  string
+	name        string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+	// pushPort is the port of the group registry which pushes images to hosted registry.
+	pushPort int
+}
+
+func NewReplicationTestSuite(seeder *seeder.TestDataSeeder, baseURL string) *ReplicationTestSuite {
+	return &ReplicationTestSuite{
+		apiVersion:  "v1",
+		name:        "Replication API",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+	}
+}
+
+func (rs *ReplicationTestSuite) Name() string {
+	return rs.name
+}
+
+func (rs *ReplicationTestSuite) APIVersion() string {
+	return rs.apiVersion
+}
+
+func (rs *ReplicationTestSuite) Run(t *testing.T) {
+	t.Run("Setup", rs.setup)
+	t.Run("CreateRule_Validation", rs.testCreateRuleValidation)
+	t.Run("GetAndListRules", rs.testGetAndListRules)
+	t.Run("UpdateRule", rs.testUpdateRule)
+	t.Run("ReplicateOnPush", rs.testReplicateOnPush)
+	t.Run("RunRule", rs.testRunRule)
+	t.Run("FailedReplication", rs.testFailedReplication)
+	t.Run("DeleteRule", rs.testDeleteRule)
+	t.Run("NonAdminAccess", rs.testNonAdminAccess)
+}
+
+// fakeTargetRegistry is a remote registry which records the images replicated to it. It requires bearer tokens
+// which are issued for the configured credentials.
+type fakeTargetRegistry struct {
+	server   *httptest.Server
+	username string
+	password string
+	// fail makes all registry requests fail with 500.
+	fail bool
+
+	mu              sync.Mutex
+	blobs           map[string][]byte
+	manifests       map[string][]byte
+	uploadedDigests []string
+}
+
+const fakeTargetToken = "replication-test-token"
+
+func newFakeTargetRegistry(t *testing.T, fail bool) *fakeTargetRegistry {
+	f := &fakeTargetRegistry{
+		username:  "replicator",
+		password:  "replicator-secret",
+		fail:      fail,
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeTargetRegistry) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != f.username || password != f.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": fakeTargetToken})
+		return
+	}
+
+	if f.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+fakeTargetToken {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake-target"`,
+			f.server.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case strings.Contains(path, "/blobs/uploads/") && r.Method == http.MethodPost:
+		name, _, _ := strings.Cut(path, "/blobs/uploads/")
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%d", name, time.Now().UnixNano()))
+		w.WriteHeader(http.StatusAccepted)
+	case strings.Contains(path, "/blobs/uploads/") && r.Method == http.MethodPut:
+		digest := r.URL.Query().Get("digest")
+		content, _ := io.ReadAll(r.Body)
+		f.blobs[digest] = content
+		f.uploadedDigests = append(f.uploadedDigests, digest)
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/") && r.Method == http.MethodHead:
+		_, digest, _ := strings.Cut(path, "/blobs/")
+		if _, ok := f.blobs[digest]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case strings.Contains(path, "/manifests/") && r.Method == http.MethodHead:
+		content, ok := f.manifests[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digestOf(content))
+		w.WriteHeader(http.StatusOK)
+	case strings.Contains(path, "/manifests/") && r.Method == http.MethodPut:
+		content, _ := io.ReadAll(r.Body)
+		f.manifests[path] = content
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeTargetRegistry) hasManifest(name, reference string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.manifests[name+"/manifests/"+reference]
+	return ok
+}
+
+func (f *fakeTargetRegistry) uploadCount(digest string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := 0
+	for _, d := range f.uploadedDigests {
+		if d == digest {
+			count++
+		}
+	}
+	return count
+}
+
+func (rs *ReplicationTestSuite) doRequest(t *testing.T, method, endpoint string, body any, token string) *http.Response {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(reqBody)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, rs.testBaseURL+endpoint, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	helpers.SetAuthCookie(req, token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func ruleBody(name string, target *fakeTargetRegistry, namespaceFilter string) map[string]any {
+	return map[string]any{
+		"name":             name,
+		"description":      "rule for tests",
+		"target_url":       target.server.URL,
+		"username":         target.username,
+		"password":         target.password,
+		"namespace_filter": namespaceFilter,
+		"trigger":          constants.ReplicationTriggerPush,
+	}
+}
+
+func (rs *ReplicationTestSuite) createRule(t *testing.T, body map[string]any) string {
+	t.Helper()
+
+	resp := rs.doRequest(t, http.MethodPost, testdata.EndpointReplicationRules, body, rs.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var created map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	return created["rule_id"].(string)
+}
+
+func (rs *ReplicationTestSuite) getRule(t *testing.T, id string) map[string]any {
+	t.Helper()
+
+	resp := rs.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointReplicationRuleByID, id), nil,
+		rs.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var rule map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&rule))
+	return rule
+}
+
+func (rs *ReplicationTestSuite) getStatus(t *testing.T, id string) map[string]any {
+	t.Helper()
+
+	resp := rs.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointReplicationRuleStatus, id), nil,
+		rs.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var status map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	return status
+}
+
+// pushBlob uploads the blob to hosted registry and returns its digest.
+func (rs *ReplicationTestSuite) pushBlob(t *testing.T, name string, content []byte) string {
+	t.Helper()
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/v2/%s/blobs/uploads/", rs.pushPort, name), "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusAccepted)
+
+	digest := digestOf(content)
+	req, err := http.NewRequest(http.MethodPut, resp.Header.Get("Location")+"?digest="+digest,
+		bytes.NewReader(content))
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusCreated)
+
+	return digest
+}
+
+// pushImage pushes an image with the layer and a config which is unique to the tag. It returns the digest of
+// the layer.
+func (rs *ReplicationTestSuite) pushImage(t *testing.T, name, tag string, layer []byte) string {
+	t.Helper()
+
+	layerDigest := rs.pushBlob(t, name, layer)
+	config := []byte(fmt.Sprintf(`{"architecture":"amd64","os":"linux","tag":"%s"}`, tag))
+	configDigest := rs.pushBlob(t, name, config)
+
+	manifest, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config": map[string]any{
+			"mediaType": "application/vnd.oci.image.config.v1+json",
+			"digest":    configDigest,
+			"size":      len(config),
+		},
+		"layers": []map[string]any{{
+			"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+			"digest":    layerDigest,
+			"size":      len(layer),
+		}},
+	})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:%d/v2/%s/manifests/%s", rs.pushPort,
+		name, tag), bytes.NewReader(manifest))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusCreated)
+
+	return layerDigest
+}
+
+func (rs *ReplicationTestSuite) setup(t *testing.T) {
+	rs.pushPort = int(helpers.FindFreePort())
+
+	body := groupBody("replication-push", rs.pushPort, constants.HostedRegistryID)
+	body["push_member_id"] = constants.HostedRegistryID
+
+	resp := rs.doRequest(t, http.MethodPost, testdata.EndpointGroups, body, rs.seeder.AdminToken(t))
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	waitForListener(t, rs.pushPort)
+}
+
+func (rs *ReplicationTestSuite) testCreateRuleValidation(t *testing.T) {
+	target := newFakeTargetRegistry(t, false)
+
+	testCases := []struct {
+		name   string
+		modify func(body map[string]any)
+	}{
+		{"Missing name", func(body map[string]any) { body["name"] = "" }},
+		{"Invalid target url", func(body map[string]any) { body["target_url"] = "ftp://registry.example.com" }},
+		{"Password without username", func(body map[string]any) { body["username"] = "" }},
+		{"Invalid namespace filter", func(body map[string]any) { body["namespace_filter"] = "team-[" }},
+		{"Invalid tag filter", func(body map[string]any) { body["tag_filter"] = "v1,[" }},
+		{"Unsupported trigger", func(body map[string]any) { body["trigger"] = "manual" }},
+		{"Push rule with interval", func(body map[string]any) { body["schedule_interval_seconds"] = 120 }},
+		{"Scheduled rule with short interval", func(body map[string]any) {
+			body["trigger"] = constants.ReplicationTriggerScheduled
+			body["schedule_interval_seconds"] = 10
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := ruleBody("rep-validation", target, "")
+			tc.modify(body)
+
+			resp := rs.doRequest(t, http.MethodPost, testdata.EndpointReplicationRules, body, rs.seeder.AdminToken(t))
+			defer resp.Body.Close()
+
+			helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+		})
+	}
+
+	t.Run("Name conflict", func(t *testing.T) {
+		rs.createRule(t, ruleBody("rep-conflict", target, "rep-conflict-*"))
+
+		resp := rs.doRequest(t, http.MethodPost, testdata.EndpointReplicationRules,
+			ruleBody("rep-conflict", target, ""), rs.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusConflict)
+	})
+}
+
+func (rs *ReplicationTestSuite) testGetAndListRules(t *testing.T) {
+	target := newFakeTargetRegistry(t, false)
+	body := ruleBody("rep-get", target, "rep-get-*")
+	body["trigger"] = constants.ReplicationTriggerScheduled
+	body["schedule_interval_seconds"] = 3600
+	body["tag_filter"] = "v*"
+	id := rs.createRule(t, body)
+
+	t.Run("Get rule", func(t *testing.T) {
+		rule := rs.getRule(t, id)
+
+		assert.Equal(t, "rep-get", rule["name"])
+		assert.Equal(t, target.server.URL, rule["target_url"])
+		assert.Equal(t, target.username, rule["username"])
+		assert.Equal(t, constants.ReplicationTriggerScheduled, rule["trigger"])
+		assert.Equal(t, float64(3600), rule["schedule_interval_seconds"])
+		assert.Equal(t, "v*", rule["tag_filter"])
+		assert.Equal(t, true, rule["enabled"])
+		assert.NotContains(t, rule, "password")
+	})
+
+	t.Run("Get non existent rule", func(t *testing.T) {
+		resp := rs.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointReplicationRuleByID, "unknown"), nil,
+			rs.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("List rules filtered by trigger", func(t *testing.T) {
+		endpoint := testdata.EndpointReplicationRules + "?trigger=" + constants.ReplicationTriggerScheduled
+		resp := rs.doRequest(t, http.MethodGet, endpoint, nil, rs.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		var res map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+
+		names := make([]string, 0)
+		for _, e := range res["entities"].([]any) {
+			rule := e.(map[string]any)
+			assert.Equal(t, constants.ReplicationTriggerScheduled, rule["trigger"])
+			assert.NotContains(t, rule, "password")
+			names = append(names, rule["name"].(string))
+		}
+		assert.Contains(t, names, "rep-get")
+	})
+
+	t.Run("List rules with invalid sort field", func(t *testing.T) {
+		resp := rs.doRequest(t, http.MethodGet, testdata.EndpointReplicationRules+"?sort_by=password", nil,
+			rs.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+}
+
+func (rs *ReplicationTestSuite) testUpdateRule(t *testing.T) {
+	target := newFakeTargetRegistry(t, false)
+	id := rs.createRule(t, ruleBody("rep-update", target, "rep-update-*"))
+
+	t.Run("Mismatched ID", func(t *testing.T) {
+		body := ruleBody("rep-update", target, "")
+		body["rule_id"] = "another-id"
+
+		resp := rs.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointReplicationRuleByID, id), body,
+			rs.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Update non existent rule", func(t *testing.T) {
+		body := ruleBody("rep-update-missing", target, "")
+		body["rule_id"] = "unknown"
+
+		resp := rs.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointReplicationRuleByID, "unknown"), body,
+			rs.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Update rule keeps password", func(t *testing.T) {
+		body := ruleBody("rep-update-renamed", target, "rep-updated-*")
+		body["rule_id"] = id
+		body["password"] = ""
+		body["enabled"] = false
+
+		resp := rs.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointReplicationRuleByID, id), body,
+			rs.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		rule := rs.getRule(t, id)
+		assert.Equal(t, "rep-update-renamed", rule["name"])
+		assert.Equal(t, "rep-updated-*", rule["namespace_filter"])
+		assert.Equal(t, false, rule["enabled"])
+
+		// disabled rule is enabled again without password. Images are replicated with the existing password.
+		body["enabled"] = true
+		resp = rs.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointReplicationRuleByID, id), body,
+			rs.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		rs.pushImage(t, "rep-updated-team/app", "v1", []byte("rep-update-layer"))
+		assert.Eventually(t, func() bool {
+			return target.hasManifest("rep-updated-team/app", "v1")
+		}, 10*time.Second, 200*time.Millisecond)
+	})
+}
+
+func (rs *ReplicationTestSuite) testReplicateOnPush(t *testing.T) {
+	target := newFakeTargetRegistry(t, false)
+	body := ruleBody("rep-push", target, "rep-push-*")
+	body["tag_filter"] = "v*"
+	id := rs.createRule(t, body)
+
+	layer := []byte("rep-push-layer")
+
+	t.Run("Pushed image is replicated", func(t *testing.T) {
+		// tag does not match the filter of the rule
+		rs.pushImage(t, "rep-push-team/app", "dev", layer)
+		layerDigest := rs.pushImage(t, "rep-push-team/app", "v1", layer)
+
+		require.Eventually(t, func() bool {
+			return rs.getStatus(t, id)["succeeded"] == float64(1)
+		}, 10*time.Second, 200*time.Millisecond)
+
+		assert.True(t, target.hasManifest("rep-push-team/app", "v1"))
+		assert.False(t, target.hasManifest("rep-push-team/app", "dev"))
+		assert.Equal(t, 1, target.uploadCount(layerDigest))
+
+		status := rs.getStatus(t, id)
+		assert.Equal(t, float64(0), status["pending"])
+		assert.Equal(t, float64(0), status["failed"])
+		assert.Equal(t, float64(0), status["lag_seconds"])
+		assert.NotNil(t, status["last_success_at"])
+	})
+
+	t.Run("Existing blobs are not transferred", func(t *testing.T) {
+		layerDigest := rs.pushImage(t, "rep-push-team/app", "v2", layer)
+
+		require.Eventually(t, func() bool {
+			return target.hasManifest("rep-push-team/app", "v2")
+		}, 10*time.Second, 200*time.Millisecond)
+
+		assert.Equal(t, 1, target.uploadCount(layerDigest))
+	})
+
+	t.Run("Image of other namespace is not replicated", func(t *testing.T) {
+		rs.pushImage(t, "rep-other-team/app", "v1", layer)
+
+		require.Eventually(t, func() bool {
+			return rs.getStatus(t, id)["succeeded"] == float64(2)
+		}, 10*time.Second, 200*time.Millisecond)
+		assert.False(t, target.hasManifest("rep-other-team/app", "v1"))
+	})
+}
+
+func (rs *ReplicationTestSuite) testRunRule(t *testing.T) {
+	rs.pushImage(t, "rep-run-team/app", "v1", []byte("rep-run-layer"))
+	rs.pushImage(t, "rep-run-team/app", "v2", []byte("rep-run-layer"))
+
+	target := newFakeTargetRegistry(t, false)
+	id := rs.createRule(t, ruleBody("rep-run", target, "rep-run-*"))
+
+	t.Run("Run rule replicates existing images", func(t *testing.T) {
+		resp := rs.doRequest(t, http.MethodPost, fmt.Sprintf(testdata.EndpointReplicationRuleRun, id), nil,
+			rs.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusAccepted)
+
+		var res map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.Equal(t, float64(2), res["queued_images"])
+
+		require.Eventually(t, func() bool {
+			return target.hasManifest("rep-run-team/app", "v1") && target.hasManifest("rep-run-team/app", "v2")
+		}, 10*time.Second, 200*time.Millisecond)
+	})
+
+	t.Run("Run non existent rule", func(t *testing.T) {
+		resp := rs.doRequest(t, http.MethodPost, fmt.Sprintf(testdata.EndpointReplicationRuleRun, "unknown"), nil,
+			rs.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Run disabled rule", func(t *testing.T) {
+		body := ruleBody("rep-run-disabled", target, "rep-run-*")
+		body["enabled"] = false
+		disabledID := rs.createRule(t, body)
+
+		resp := rs.doRequest(t, http.MethodPost, fmt.Sprintf(testdata.EndpointReplicationRuleRun, disabledID), nil,
+			rs.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+}
+
+func (rs *ReplicationTestSuite) testFailedReplication(t *testing.T) {
+	target := newFakeTargetRegistry(t, true)
+	id := rs.createRule(t, ruleBody("rep-fail", target, "rep-fail-*"))
+
+	rs.pushImage(t, "rep-fail-team/app", "v1", []byte("rep-fail-layer"))
+
+	// attempts are retried with backoff until max attempts(3) of test configuration
+	require.Eventually(t, func() bool {
+		return rs.getStatus(t, id)["failed"] == float64(1)
+	}, 20*time.Second, 200*time.Millisecond)
+
+	status := rs.getStatus(t, id)
+	assert.Equal(t, float64(0), status["pending"])
+	assert.Contains(t, status["last_error"], "unexpected status 500")
+	assert.NotNil(t, status["last_failure_at"])
+
+	failures := status["recent_failures"].([]any)
+	require.Len(t, failures, 1)
+	failure := failures[0].(map[string]any)
+	assert.Equal(t, "rep-fail-team", failure["namespace"])
+	assert.Equal(t, "app", failure["repository"])
+	assert.Equal(t, "v1", failure["tag"])
+	assert.Equal(t, float64(3), failure["attempts"])
+
+	t.Run("List statuses", func(t *testing.T) {
+		resp := rs.doRequest(t, http.MethodGet, testdata.EndpointReplicationStatus+"?limit=100", nil,
+			rs.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		var res map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+
+		found := false
+		for _, e := range res["entities"].([]any) {
+			s := e.(map[string]any)
+			if s["rule_id"] == id {
+				found = true
+				assert.Equal(t, float64(1), s["failed"])
+			}
+		}
+		assert.True(t, found)
+	})
+}
+
+func (rs *ReplicationTestSuite) testDeleteRule(t *testing.T) {
+	target := newFakeTargetRegistry(t, false)
+	id := rs.createRule(t, ruleBody("rep-delete", target, "rep-delete-*"))
+
+	resp := rs.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointReplicationRuleByID, id), nil,
+		rs.seeder.AdminToken(t))
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+	resp = rs.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointReplicationRuleStatus, id), nil,
+		rs.seeder.AdminToken(t))
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+
+	resp = rs.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointReplicationRuleByID, id), nil,
+		rs.seeder.AdminToken(t))
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+}
+
+func (rs *ReplicationTestSuite) testNonAdminAccess(t *testing.T) {
+	rs.seeder.ProvisionUser(t, "replication-developer", "replication-developer@t.com", constants.RoleDeveloper)
+	token := rs.seeder.UserToken(t, "replication-developer", constants.RoleDeveloper)
+
+	resp := rs.doRequest(t, http.MethodGet, testdata.EndpointReplicationRules, nil, token)
+	defer resp.Body.Close()
+
+	helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+}
@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RevocationTestSuite tests revocation of auth tokens. Tokens of the seeder don't belong to a login session, so they
+// are rejected only by revocation.
+type RevocationTestSuite struct {
+	name        string
+	apiVersion  string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+}
+
+func NewRevocationTestSuite(seeder *seeder.TestDataSeeder, baseURL string) *RevocationTestSuite {
+	return &RevocationTestSuite{
+		name:        "RevocationAPI",
+		apiVersion:  "v1",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+	}
+}
+
+func (s *RevocationTestSuite) Run(t *testing.T) {
+	username := "revocation-user"
+	userID := s.seeder.ProvisionUser(t, username, "revocation.user@t.com", "Developer")
+
+	t.Run("Logout", func(t *testing.T) { s.testLogout(t, username) })
+	t.Run("RevokedByAnotherInstance", func(t *testing.T) { s.testRevokedByAnotherInstance(t, username, userID) })
+	t.Run("SessionTokenRevokedByAnotherInstance", s.testSessionTokenRevokedByAnotherInstance)
+	t.Run("Purge", func(t *testing.T) { s.testPurge(t, username, userID) })
+}
+
+func (s *RevocationTestSuite) Name() string {
+	return s.name
+}
+
+func (s *RevocationTestSuite) APIVersion() string {
+	return s.apiVersion
+}
+
+func (s *RevocationTestSuite) doRequest(t *testing.T, method, endpoint, token string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, s.testBaseURL+endpoint, nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	req.AddCookie(&http.Cookie{Name: constants.AuthTokenCookie, Value: token})
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func (s *RevocationTestSuite) statusCode(t *testing.T, token string) int {
+	t.Helper()
+
+	resp := s.doRequest(t, http.MethodGet, testdata.EndpointCurrentUser, token)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func (s *RevocationTestSuite) testLogout(t *testing.T, username string) {
+	token := s.seeder.UserToken(t, username, "Developer")
+	require.Equal(t, http.StatusOK, s.statusCode(t, token))
+
+	resp := s.doRequest(t, http.MethodPost, testdata.EndpointLogout, token)
+	defer resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+	assert.True(t, s.seeder.IsTokenRevocationStored(t, token))
+	// the revocation applies without waiting for a reload
+	assert.Equal(t, http.StatusUnauthorized, s.statusCode(t, token))
+
+	otherToken := s.seeder.UserToken(t, username, "Developer")
+	assert.Equal(t, http.StatusOK, s.statusCode(t, otherToken))
+}
+
+func (s *RevocationTestSuite) testRevokedByAnotherInstance(t *testing.T, username, userID string) {
+	token := s.seeder.UserToken(t, username, "Developer")
+	require.Equal(t, http.StatusOK, s.statusCode(t, token))
+
+	s.seeder.RevokeToken(t, token, userID, time.Now().Add(time.Hour))
+
+	// revoked tokens are reloaded every second in tests
+	assert.Eventually(t, func() bool {
+		return s.statusCode(t, token) == http.StatusUnauthorized
+	}, 5*time.Second, 200*time.Millisecond)
+}
+
+func (s *RevocationTestSuite) testSessionTokenRevokedByAnotherInstance(t *testing.T) {
+	userID := s.seeder.ProvisionUserWithPassword(t, "revocation-session-user", "revocation.session.user@t.com",
+		"Developer", "Password123!")
+
+	body, err := json.Marshal(map[string]string{"username": "revocation-session-user", "password": "Password123!"})
+	require.NoError(t, err)
+	resp, err := http.Post(s.testBaseURL+testdata.EndpointLogin, testdata.ApplicationJson, bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	authCookie, _ := findCookies(t, resp)
+
+	token := authCookie.Value
+	require.Equal(t, http.StatusOK, s.statusCode(t, token))
+
+	s.seeder.RevokeToken(t, token, userID, time.Now().Add(time.Hour))
+
+	// tokens of login sessions are checked in the database, so they are rejected without waiting for a reload
+	assert.Equal(t, http.StatusUnauthorized, s.statusCode(t, token))
+}
+
+func (s *RevocationTestSuite) testPurge(t *testing.T, username, userID string) {
+	expiredToken := s.seeder.UserToken(t, username, "Developer")
+	s.seeder.RevokeToken(t, expiredToken, userID, time.Now().Add(-time.Minute))
+	activeToken := s.seeder.UserToken(t, username, "Developer")
+	s.seeder.RevokeToken(t, activeToken, userID, time.Now().Add(time.Hour))
+
+	assert.GreaterOrEqual(t, s.seeder.PurgeExpiredRevocations(t), int64(1))
+	assert.False(t, s.seeder.IsTokenRevocationStored(t, expiredToken))
+	assert.True(t, s.seeder.IsTokenRevocationStored(t, activeToken))
+}
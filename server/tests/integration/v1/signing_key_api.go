@@ -0,0 +1,316 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type SigningKeyTestSuite struct {
+	name        string
+	apiVersion  string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+}
+
+func NewSigningKeyTestSuite(seeder *seeder.TestDataSeeder, baseURL string) *SigningKeyTestSuite {
+	return &SigningKeyTestSuite{
+		name:        "SigningKeyAPI",
+		apiVersion:  "v1",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+	}
+}
+
+func (s *SigningKeyTestSuite) Run(t *testing.T) {
+	s.seeder.ProvisionUserWithPassword(t, "signing-key-user", "signing.key.user@t.com", "Developer",
+		"Password123!")
+
+	t.Run("JWKS", s.testJWKS)
+	t.Run("Authorization", s.testAuthorization)
+	t.Run("Rotation", s.testRotation)
+	t.Run("RotatedByAnotherInstance", s.testRotatedByAnotherInstance)
+}
+
+func (s *SigningKeyTestSuite) Name() string {
+	return s.name
+}
+
+func (s *SigningKeyTestSuite) APIVersion() string {
+	return s.apiVersion
+}
+
+func (s *SigningKeyTestSuite) doRequest(t *testing.T, method, endpoint string, cookie *http.Cookie) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, s.testBaseURL+endpoint, nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func (s *SigningKeyTestSuite) adminCookie(t *testing.T) *http.Cookie {
+	t.Helper()
+
+	return &http.Cookie{Name: constants.AuthTokenCookie, Value: s.seeder.AdminToken(t)}
+}
+
+func (s *SigningKeyTestSuite) userCookie(t *testing.T) *http.Cookie {
+	t.Helper()
+
+	reqBody, err := json.Marshal(map[string]string{"username": "signing-key-user", "password": "Password123!"})
+	require.NoError(t, err)
+
+	resp, err := http.Post(s.testBaseURL+testdata.EndpointLogin, testdata.ApplicationJson, bytes.NewReader(reqBody))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	authCookie, _ := findCookies(t, resp)
+	require.NotNil(t, authCookie)
+	return authCookie
+}
+
+func (s *SigningKeyTestSuite) jwks(t *testing.T) *mgmt.JWKSet {
+	t.Helper()
+
+	resp := s.doRequest(t, http.MethodGet, testdata.EndpointJWKS, nil)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var jwks mgmt.JWKSet
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jwks))
+	return &jwks
+}
+
+func (s *SigningKeyTestSuite) listKeys(t *testing.T) []*mgmt.SigningKeyDTO {
+	t.Helper()
+
+	resp := s.doRequest(t, http.MethodGet, testdata.EndpointSigningKeys, s.adminCookie(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var keys []*mgmt.SigningKeyDTO
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&keys))
+	return keys
+}
+
+func (s *SigningKeyTestSuite) activeKey(t *testing.T) *mgmt.SigningKeyDTO {
+	t.Helper()
+
+	var active *mgmt.SigningKeyDTO
+	for _, key := range s.listKeys(t) {
+		if key.Status == constants.SigningKeyStatusActive {
+			require.Nil(t, active, "only one key can be active")
+			active = key
+		}
+	}
+	require.NotNil(t, active)
+	return active
+}
+
+// tokenKeyID returns the kid header of the token
+func tokenKeyID(t *testing.T, token string) string {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+
+	var header map[string]string
+	require.NoError(t, json.Unmarshal(headerBytes, &header))
+	return header["kid"]
+}
+
+// verifyWithJWKS verifies the signature of the token like another service would, with the public key of the JWKS
+func verifyWithJWKS(t *testing.T, jwks *mgmt.JWKSet, token string) bool {
+	t.Helper()
+
+	kid := tokenKeyID(t, token)
+	for _, jwk := range jwks.Keys {
+		if jwk.KeyID != kid {
+			continue
+		}
+		require.Equal(t, "EC", jwk.KeyType)
+		require.Equal(t, "P-256", jwk.Curve)
+		require.Equal(t, "ES256", jwk.Algorithm)
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		require.NoError(t, err)
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		require.NoError(t, err)
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x),
+			Y: new(big.Int).SetBytes(y)}
+
+		parts := strings.Split(token, ".")
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+		require.Len(t, sig, 64)
+
+		hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		return ecdsa.Verify(publicKey, hashed[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+	}
+	return false
+}
+
+func (s *SigningKeyTestSuite) testJWKS(t *testing.T) {
+	jwks := s.jwks(t)
+	require.NotEmpty(t, jwks.Keys)
+
+	t.Run("Active key is first", func(t *testing.T) {
+		assert.Equal(t, s.activeKey(t).KeyID, jwks.Keys[0].KeyID)
+	})
+
+	t.Run("Tokens verify with JWKS", func(t *testing.T) {
+		authCookie := s.userCookie(t)
+		assert.Equal(t, jwks.Keys[0].KeyID, tokenKeyID(t, authCookie.Value))
+		assert.True(t, verifyWithJWKS(t, jwks, authCookie.Value))
+	})
+}
+
+func (s *SigningKeyTestSuite) testAuthorization(t *testing.T) {
+	userCookie := s.userCookie(t)
+
+	t.Run("List requires admin", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodGet, testdata.EndpointSigningKeys, userCookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+
+	t.Run("Rotate requires admin", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, testdata.EndpointRotateSigningKey, userCookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, testdata.EndpointRotateSigningKey, nil)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+}
+
+func (s *SigningKeyTestSuite) testRotation(t *testing.T) {
+	previousKey := s.activeKey(t)
+	// logins before rotation continue until their tokens expire
+	previousCookie := s.userCookie(t)
+
+	var rotatedKey mgmt.SigningKeyDTO
+	t.Run("Rotate", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, testdata.EndpointRotateSigningKey, s.adminCookie(t))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&rotatedKey))
+
+		assert.Equal(t, constants.SigningKeyStatusActive, rotatedKey.Status)
+		assert.Equal(t, "ES256", rotatedKey.Algorithm)
+		assert.NotEqual(t, previousKey.KeyID, rotatedKey.KeyID)
+		assert.Nil(t, rotatedKey.ExpiresAt)
+	})
+
+	t.Run("Previous key is retired", func(t *testing.T) {
+		keys := s.listKeys(t)
+		require.GreaterOrEqual(t, len(keys), 2)
+		assert.Equal(t, rotatedKey.KeyID, keys[0].KeyID)
+
+		var retired *mgmt.SigningKeyDTO
+		for _, key := range keys {
+			if key.KeyID == previousKey.KeyID {
+				retired = key
+			}
+		}
+		require.NotNil(t, retired)
+		assert.Equal(t, constants.SigningKeyStatusRetired, retired.Status)
+		require.NotNil(t, retired.RetiredAt)
+		require.NotNil(t, retired.ExpiresAt)
+		assert.True(t, retired.ExpiresAt.After(*retired.RetiredAt))
+	})
+
+	t.Run("Tokens of retired key still work", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodGet, testdata.EndpointCurrentUser, previousCookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	})
+
+	t.Run("New tokens use rotated key", func(t *testing.T) {
+		authCookie := s.userCookie(t)
+		assert.Equal(t, rotatedKey.KeyID, tokenKeyID(t, authCookie.Value))
+
+		jwks := s.jwks(t)
+		assert.Equal(t, rotatedKey.KeyID, jwks.Keys[0].KeyID)
+		assert.True(t, verifyWithJWKS(t, jwks, authCookie.Value))
+		assert.True(t, verifyWithJWKS(t, jwks, previousCookie.Value))
+	})
+
+	t.Run("Activate previous key", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointActivateSigningKey, previousKey.KeyID),
+			s.adminCookie(t))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		assert.Equal(t, previousKey.KeyID, s.activeKey(t).KeyID)
+		assert.Equal(t, previousKey.KeyID, tokenKeyID(t, s.userCookie(t).Value))
+	})
+
+	t.Run("Activate unknown key", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointActivateSigningKey, "unknown"),
+			s.adminCookie(t))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+}
+
+func (s *SigningKeyTestSuite) testRotatedByAnotherInstance(t *testing.T) {
+	authenticator := s.seeder.RotateSigningKey(t, "ES256", "open-image-registry")
+	token, err := authenticator.Sign(map[string]any{
+		constants.ClaimRole:    "Developer",
+		constants.ClaimSubject: "signing-key-user",
+	})
+	require.NoError(t, err)
+
+	// keys are reloaded for the unknown kid instead of waiting for the periodic reload
+	resp := s.doRequest(t, http.MethodGet, testdata.EndpointCurrentUser, &http.Cookie{Name: constants.AuthTokenCookie,
+		Value: token})
+	defer resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusOK)
+	assert.Equal(t, tokenKeyID(t, token), s.activeKey(t).KeyID)
+
+	t.Run("Unknown key is rejected", func(t *testing.T) {
+		unknown := s.seeder.RotateSigningKey(t, "ES256", "open-image-registry")
+		token, err := unknown.Sign(map[string]any{
+			constants.ClaimRole:    "Developer",
+			constants.ClaimSubject: "signing-key-user",
+		})
+		require.NoError(t, err)
+
+		// keys were reloaded for the previous request, so they aren't reloaded again yet
+		resp := s.doRequest(t, http.MethodGet, testdata.EndpointCurrentUser, &http.Cookie{
+			Name: constants.AuthTokenCookie, Value: token})
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,496 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type UpstreamSyncTestSuite struct {
+	apiVersion  string
+	name        string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+	upstream    *fakeSyncUpstream
+	upstreamID  string
+	port        int
+}
+
+func NewUpstreamSyncTestSuite(seeder *seeder.TestDataSeeder, baseURL string) *UpstreamSyncTestSuite {
+	return &UpstreamSyncTestSuite{
+		apiVersion:  "v1",
+		name:        "Upstream Sync Job API",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+	}
+}
+
+func (s *UpstreamSyncTestSuite) Name() string {
+	return s.name
+}
+
+func (s *UpstreamSyncTestSuite) APIVersion() string {
+	return s.apiVersion
+}
+
+func (s *UpstreamSyncTestSuite) Run(t *testing.T) {
+	// fake upstream outlives subtests since it is closed on cleanup of the suite
+	s.upstream = newFakeSyncUpstream(t)
+
+	t.Run("Setup", s.setup)
+	t.Run("CreateSyncJob_Validation", s.testCreateSyncJobValidation)
+	t.Run("GetAndListSyncJobs", s.testGetAndListSyncJobs)
+	t.Run("UpdateSyncJob", s.testUpdateSyncJob)
+	t.Run("RunSyncJob", s.testRunSyncJob)
+	t.Run("DeleteSyncJob", s.testDeleteSyncJob)
+	t.Run("NonAdminAccess", s.testNonAdminAccess)
+}
+
+// fakeSyncUpstream serves a multi-arch image `team/app` under several tags. Tags are listed in two pages.
+type fakeSyncUpstream struct {
+	server    *httptest.Server
+	index     []byte
+	manifests map[string][]byte
+	blobs     map[string][]byte
+
+	mu      sync.Mutex
+	fetched []string
+}
+
+func newFakeSyncUpstream(t *testing.T) *fakeSyncUpstream {
+	f := &fakeSyncUpstream{
+		manifests: make(map[string][]byte),
+		blobs:     make(map[string][]byte),
+	}
+
+	var descriptors []string
+	for _, arch := range []string{"amd64", "arm64"} {
+		config := []byte(fmt.Sprintf(`{"architecture":"%s","os":"linux"}`, arch))
+		layer := []byte("layer of " + arch)
+		f.blobs[digestOf(config)] = config
+		f.blobs[digestOf(layer)] = layer
+
+		manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+			`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"%s","size":%d},`+
+			`"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":"%s","size":%d}]}`,
+			digestOf(config), len(config), digestOf(layer), len(layer)))
+		f.manifests[digestOf(manifest)] = manifest
+
+		descriptors = append(descriptors, fmt.Sprintf(`{"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+			`"digest":"%s","size":%d,"platform":{"architecture":"%s","os":"linux"}}`,
+			digestOf(manifest), len(manifest), arch))
+	}
+
+	f.index = []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[` +
+		strings.Join(descriptors, ",") + `]}`)
+
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeSyncUpstream) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.fetched = append(f.fetched, r.URL.Path)
+	f.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/token":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"token":"sync-test-token","expires_in":300}`))
+	case r.URL.Path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+	case r.URL.Path == "/v2/team/app/tags/list":
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/team/app/tags/list?n=2&last=1.1>; rel="next"`)
+			w.Write([]byte(`{"name":"team/app","tags":["1.0","1.1"]}`))
+			return
+		}
+		w.Write([]byte(`{"name":"team/app","tags":["2.0","latest"]}`))
+	case strings.HasPrefix(r.URL.Path, "/v2/team/app/manifests/"):
+		reference := strings.TrimPrefix(r.URL.Path, "/v2/team/app/manifests/")
+		if manifest, ok := f.manifests[reference]; ok {
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Write(manifest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+		w.Write(f.index)
+	case strings.HasPrefix(r.URL.Path, "/v2/team/app/blobs/"):
+		blob, ok := f.blobs[strings.TrimPrefix(r.URL.Path, "/v2/team/app/blobs/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(blob)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// fetchCount returns how many times the path was requested.
+func (f *fakeSyncUpstream) fetchCount(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := 0
+	for _, p := range f.fetched {
+		if p == path {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *UpstreamSyncTestSuite) doRequest(t *testing.T, method, endpoint string, body any, token string) *http.Response {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(reqBody)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, s.testBaseURL+endpoint, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	helpers.SetAuthCookie(req, token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func syncJobBody(name string, tagPatterns []string, platforms []string) map[string]any {
+	return map[string]any{
+		"name":         name,
+		"repositories": []string{"team/app"},
+		"tag_patterns": tagPatterns,
+		"platforms":    platforms,
+	}
+}
+
+func (s *UpstreamSyncTestSuite) createSyncJob(t *testing.T, body map[string]any) string {
+	t.Helper()
+
+	resp := s.doRequest(t, http.MethodPost, fmt.Sprintf(testdata.EndpointUpstreamSyncJobs, s.upstreamID), body,
+		s.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var created map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	return created["job_id"].(string)
+}
+
+func (s *UpstreamSyncTestSuite) getSyncJob(t *testing.T, jobID string) map[string]any {
+	t.Helper()
+
+	resp := s.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamSyncJobByID, s.upstreamID, jobID), nil,
+		s.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var job map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+	return job
+}
+
+func (s *UpstreamSyncTestSuite) setup(t *testing.T) {
+	s.port = int(helpers.FindFreePort())
+
+	body := upstreamBody("sync-upstream", s.port)
+	body["upstream_url"] = s.upstream.server.URL
+	body["auth_config"].(map[string]any)["token_endpoint"] = s.upstream.server.URL + "/token"
+
+	resp := s.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, body, s.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var created map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	s.upstreamID = created["reg_id"].(string)
+
+	waitForListener(t, s.port)
+}
+
+func (s *UpstreamSyncTestSuite) testCreateSyncJobValidation(t *testing.T) {
+	endpoint := fmt.Sprintf(testdata.EndpointUpstreamSyncJobs, s.upstreamID)
+
+	tests := []struct {
+		name   string
+		modify func(body map[string]any)
+	}{
+		{"Invalid name", func(body map[string]any) { body["name"] = "a" }},
+		{"No repositories", func(body map[string]any) { body["repositories"] = []string{} }},
+		{"Invalid repository", func(body map[string]any) { body["repositories"] = []string{"team/App!"} }},
+		{"No tag patterns", func(body map[string]any) { body["tag_patterns"] = []string{} }},
+		{"Invalid tag pattern", func(body map[string]any) { body["tag_patterns"] = []string{"[1-"} }},
+		{"Invalid platform", func(body map[string]any) { body["platforms"] = []string{"linux"} }},
+		{"Too short interval", func(body map[string]any) { body["schedule_interval_seconds"] = 10 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := syncJobBody("sync-validation", []string{"*"}, nil)
+			tt.modify(body)
+
+			resp := s.doRequest(t, http.MethodPost, endpoint, body, s.seeder.AdminToken(t))
+			resp.Body.Close()
+			helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+		})
+	}
+
+	t.Run("Non existent upstream", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, fmt.Sprintf(testdata.EndpointUpstreamSyncJobs, "non-existent-id"),
+			syncJobBody("sync-missing-upstream", []string{"*"}, nil), s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Duplicate name", func(t *testing.T) {
+		s.createSyncJob(t, syncJobBody("sync-duplicate", []string{"*"}, nil))
+
+		resp := s.doRequest(t, http.MethodPost, endpoint, syncJobBody("sync-duplicate", []string{"*"}, nil),
+			s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusConflict)
+	})
+}
+
+func (s *UpstreamSyncTestSuite) testGetAndListSyncJobs(t *testing.T) {
+	body := syncJobBody("sync-get", []string{"1.*", "latest"}, []string{"linux/amd64"})
+	body["schedule_interval_seconds"] = 3600
+	jobID := s.createSyncJob(t, body)
+
+	t.Run("Get", func(t *testing.T) {
+		job := s.getSyncJob(t, jobID)
+		assert.Equal(t, "sync-get", job["name"])
+		assert.Equal(t, s.upstreamID, job["upstream_id"])
+		assert.Equal(t, []any{"team/app"}, job["repositories"])
+		assert.Equal(t, []any{"1.*", "latest"}, job["tag_patterns"])
+		assert.Equal(t, []any{"linux/amd64"}, job["platforms"])
+		assert.Equal(t, float64(3600), job["schedule_interval_seconds"])
+		assert.Equal(t, true, job["enabled"])
+		assert.Equal(t, "", job["last_run_status"])
+	})
+
+	t.Run("List", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamSyncJobs, s.upstreamID)+
+			"?search=sync-get", nil, s.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var list map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		assert.Equal(t, float64(1), list["total"])
+	})
+
+	t.Run("List with unsupported filter", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamSyncJobs, s.upstreamID)+
+			"?sort_by=repositories", nil, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Job of another upstream", func(t *testing.T) {
+		otherID := s.seeder.CreateUpstream(t, "sync-other-upstream", int(helpers.FindFreePort()),
+			"https://registry-1.docker.io")
+
+		resp := s.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamSyncJobByID, otherID, jobID), nil,
+			s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+}
+
+func (s *UpstreamSyncTestSuite) testUpdateSyncJob(t *testing.T) {
+	jobID := s.createSyncJob(t, syncJobBody("sync-update", []string{"*"}, nil))
+	endpoint := fmt.Sprintf(testdata.EndpointUpstreamSyncJobByID, s.upstreamID, jobID)
+
+	t.Run("Update", func(t *testing.T) {
+		body := syncJobBody("sync-updated", []string{"2.*"}, []string{"linux/arm64"})
+		body["job_id"] = jobID
+		body["enabled"] = false
+
+		resp := s.doRequest(t, http.MethodPut, endpoint, body, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		job := s.getSyncJob(t, jobID)
+		assert.Equal(t, "sync-updated", job["name"])
+		assert.Equal(t, []any{"2.*"}, job["tag_patterns"])
+		assert.Equal(t, false, job["enabled"])
+	})
+
+	t.Run("Run disabled job", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, fmt.Sprintf(testdata.EndpointUpstreamSyncJobRun, s.upstreamID, jobID),
+			nil, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Mismatched ID", func(t *testing.T) {
+		body := syncJobBody("sync-updated", []string{"*"}, nil)
+		body["job_id"] = "another-id"
+
+		resp := s.doRequest(t, http.MethodPut, endpoint, body, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Name conflict", func(t *testing.T) {
+		body := syncJobBody("sync-duplicate", []string{"*"}, nil)
+		body["job_id"] = jobID
+
+		resp := s.doRequest(t, http.MethodPut, endpoint, body, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusConflict)
+	})
+}
+
+func (s *UpstreamSyncTestSuite) testRunSyncJob(t *testing.T) {
+	jobID := s.createSyncJob(t, syncJobBody("sync-run", []string{"1.*"}, []string{"linux/amd64"}))
+
+	resp := s.doRequest(t, http.MethodPost, fmt.Sprintf(testdata.EndpointUpstreamSyncJobRun, s.upstreamID, jobID), nil,
+		s.seeder.AdminToken(t))
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusAccepted)
+
+	var job map[string]any
+	require.Eventually(t, func() bool {
+		job = s.getSyncJob(t, jobID)
+		return job["last_run_status"] != "Running"
+	}, 10*time.Second, 100*time.Millisecond)
+
+	require.Equal(t, "Succeeded", job["last_run_status"], job["last_run_message"])
+	assert.Equal(t, float64(2), job["last_synced_images"])
+	assert.NotNil(t, job["last_run_at"])
+
+	var amd64Manifest, arm64Manifest string
+	for digest, manifest := range s.upstream.manifests {
+		if strings.Contains(string(manifest), digestOf([]byte("layer of amd64"))) {
+			amd64Manifest = digest
+		} else {
+			arm64Manifest = digest
+		}
+	}
+
+	t.Run("Only matching tags are fetched", func(t *testing.T) {
+		assert.Equal(t, 1, s.upstream.fetchCount("/v2/team/app/manifests/1.0"))
+		assert.Equal(t, 1, s.upstream.fetchCount("/v2/team/app/manifests/1.1"))
+		assert.Zero(t, s.upstream.fetchCount("/v2/team/app/manifests/2.0"))
+		assert.Zero(t, s.upstream.fetchCount("/v2/team/app/manifests/latest"))
+	})
+
+	t.Run("Only matching platforms are fetched", func(t *testing.T) {
+		assert.Zero(t, s.upstream.fetchCount("/v2/team/app/manifests/"+arm64Manifest))
+		assert.Zero(t, s.upstream.fetchCount("/v2/team/app/blobs/"+digestOf([]byte("layer of arm64"))))
+		// blobs are downloaded once even though both tags refer them
+		assert.Equal(t, 1, s.upstream.fetchCount("/v2/team/app/blobs/"+digestOf([]byte("layer of amd64"))))
+	})
+
+	t.Run("Pull is served from cache", func(t *testing.T) {
+		manifestFetches := s.upstream.fetchCount("/v2/team/app/manifests/" + amd64Manifest)
+
+		for _, reference := range []string{"1.0", amd64Manifest} {
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/team/app/manifests/%s", s.port, reference))
+			require.NoError(t, err)
+			content, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			require.NoError(t, err)
+
+			helpers.AssertStatusCode(t, resp, http.StatusOK)
+			if reference == "1.0" {
+				assert.Equal(t, string(s.upstream.index), string(content))
+			} else {
+				assert.Equal(t, string(s.upstream.manifests[amd64Manifest]), string(content))
+			}
+		}
+
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/team/app/blobs/%s", s.port,
+			digestOf([]byte("layer of amd64"))))
+		require.NoError(t, err)
+		content, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+		assert.Equal(t, "layer of amd64", string(content))
+
+		assert.Equal(t, 1, s.upstream.fetchCount("/v2/team/app/manifests/1.0"))
+		assert.Equal(t, manifestFetches, s.upstream.fetchCount("/v2/team/app/manifests/"+amd64Manifest))
+		assert.Equal(t, 1, s.upstream.fetchCount("/v2/team/app/blobs/"+digestOf([]byte("layer of amd64"))))
+	})
+
+	t.Run("Run again", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, fmt.Sprintf(testdata.EndpointUpstreamSyncJobRun, s.upstreamID, jobID),
+			nil, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusAccepted)
+
+		require.Eventually(t, func() bool {
+			job = s.getSyncJob(t, jobID)
+			return job["last_run_status"] != "Running"
+		}, 10*time.Second, 100*time.Millisecond)
+
+		require.Equal(t, "Succeeded", job["last_run_status"], job["last_run_message"])
+		// cached blobs are not downloaded again
+		assert.Equal(t, 1, s.upstream.fetchCount("/v2/team/app/blobs/"+digestOf([]byte("layer of amd64"))))
+	})
+
+	t.Run("Non existent job", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, fmt.Sprintf(testdata.EndpointUpstreamSyncJobRun, s.upstreamID,
+			"non-existent-id"), nil, s.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+}
+
+func (s *UpstreamSyncTestSuite) testDeleteSyncJob(t *testing.T) {
+	jobID := s.createSyncJob(t, syncJobBody("sync-delete", []string{"*"}, nil))
+	endpoint := fmt.Sprintf(testdata.EndpointUpstreamSyncJobByID, s.upstreamID, jobID)
+
+	resp := s.doRequest(t, http.MethodDelete, endpoint, nil, s.seeder.AdminToken(t))
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+	resp = s.doRequest(t, http.MethodGet, endpoint, nil, s.seeder.AdminToken(t))
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+
+	resp = s.doRequest(t, http.MethodDelete, endpoint, nil, s.seeder.AdminToken(t))
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+}
+
+func (s *UpstreamSyncTestSuite) testNonAdminAccess(t *testing.T) {
+	s.seeder.ProvisionUser(t, "sync-developer", "sync-developer@t.com", constants.RoleDeveloper)
+	token := s.seeder.UserToken(t, "sync-developer", constants.RoleDeveloper)
+
+	resp := s.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamSyncJobs, s.upstreamID), nil, token)
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+
+	resp = s.doRequest(t, http.MethodPost, fmt.Sprintf(testdata.EndpointUpstreamSyncJobs, s.upstreamID),
+		syncJobBody("sync-forbidden", []string{"*"}, nil), token)
+	resp.Body.Close()
+	helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+}
@@ -0,0 +1,371 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type AccessTokenTestSuite struct {
+	name        string
+	apiVersion  string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+}
+
+func NewAccessTokenTestSuite(seeder *seeder.TestDataSeeder, baseURL string) *AccessTokenTestSuite {
+	return &AccessTokenTestSuite{
+		name:        "AccessTokenAPI",
+		apiVersion:  "v1",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+	}
+}
+
+func (a *AccessTokenTestSuite) Run(t *testing.T) {
+	t.Run("CreateValidation", a.testCreateValidation)
+	t.Run("Lifecycle", a.testLifecycle)
+	t.Run("ManagementScopes", a.testManagementScopes)
+	t.Run("RegistryAuthentication", a.testRegistryAuthentication)
+}
+
+func (a *AccessTokenTestSuite) Name() string {
+	return a.name
+}
+
+func (a *AccessTokenTestSuite) APIVersion() string {
+	return a.apiVersion
+}
+
+type accessTokenResponse struct {
+	Id          string     `json:"id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	Token       string     `json:"token"`
+}
+
+// doRequest sends the request with the session cookie if cookieToken is set. Otherwise authorization is used as
+// `Authorization` header.
+func (a *AccessTokenTestSuite) doRequest(t *testing.T, method, endpoint string, body any, cookieToken,
+	authorization string) *http.Response {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(reqBody)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, a.testBaseURL+endpoint, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	if cookieToken != "" {
+		helpers.SetAuthCookie(req, cookieToken)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func (a *AccessTokenTestSuite) createToken(t *testing.T, sessionToken, name string, scopes []string,
+	expiresInDays int) *accessTokenResponse {
+	t.Helper()
+
+	resp := a.doRequest(t, http.MethodPost, testdata.EndpointAccessTokens, map[string]any{
+		"name":            name,
+		"scopes":          scopes,
+		"expires_in_days": expiresInDays,
+	}, sessionToken, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var token accessTokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&token))
+	return &token
+}
+
+func basicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+func (a *AccessTokenTestSuite) testCreateValidation(t *testing.T) {
+	username := "token-validation-user"
+	a.seeder.ProvisionUserWithPassword(t, username, "token.validation@t.com", "Developer", "SecurePass123!")
+	sessionToken := a.seeder.UserToken(t, username, "Developer")
+
+	a.createToken(t, sessionToken, "existing-token", []string{"pull"}, 0)
+
+	tcs := []struct {
+		name       string
+		body       map[string]any
+		statusCode int
+	}{
+		{"Short name", map[string]any{"name": "ci", "scopes": []string{"pull"}}, http.StatusBadRequest},
+		{"No scopes", map[string]any{"name": "ci-token", "scopes": []string{}}, http.StatusBadRequest},
+		{"Unknown scope", map[string]any{"name": "ci-token", "scopes": []string{"delete"}}, http.StatusBadRequest},
+		{"Duplicate scope", map[string]any{"name": "ci-token", "scopes": []string{"pull", "pull"}},
+			http.StatusBadRequest},
+		{"Negative expiry", map[string]any{"name": "ci-token", "scopes": []string{"pull"}, "expires_in_days": -1},
+			http.StatusBadRequest},
+		{"Too long expiry", map[string]any{"name": "ci-token", "scopes": []string{"pull"}, "expires_in_days": 366},
+			http.StatusBadRequest},
+		{"Duplicate name", map[string]any{"name": "existing-token", "scopes": []string{"pull"}},
+			http.StatusConflict},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := a.doRequest(t, http.MethodPost, testdata.EndpointAccessTokens, tc.body, sessionToken, "")
+			defer resp.Body.Close()
+			helpers.AssertStatusCode(t, resp, tc.statusCode)
+		})
+	}
+
+	t.Run("Without authentication", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodPost, testdata.EndpointAccessTokens,
+			map[string]any{"name": "ci-token", "scopes": []string{"pull"}}, "", "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+}
+
+func (a *AccessTokenTestSuite) testLifecycle(t *testing.T) {
+	username := "token-lifecycle-user"
+	a.seeder.ProvisionUserWithPassword(t, username, "token.lifecycle@t.com", "Developer", "SecurePass123!")
+	sessionToken := a.seeder.UserToken(t, username, "Developer")
+
+	created := a.createToken(t, sessionToken, "lifecycle-token", []string{"pull", "management:read"}, 30)
+	require.True(t, strings.HasPrefix(created.Token, "oir_pat_"))
+	assert.True(t, strings.HasPrefix(created.Token, created.TokenPrefix))
+	assert.ElementsMatch(t, []string{"pull", "management:read"}, created.Scopes)
+	require.NotNil(t, created.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), *created.ExpiresAt, time.Hour)
+	assert.Nil(t, created.LastUsedAt)
+
+	listTokens := func(t *testing.T) []accessTokenResponse {
+		t.Helper()
+
+		resp := a.doRequest(t, http.MethodGet, testdata.EndpointAccessTokens, nil, sessionToken, "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var res struct {
+			Tokens []accessTokenResponse `json:"tokens"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		return res.Tokens
+	}
+
+	t.Run("Token is not listed", func(t *testing.T) {
+		tokens := listTokens(t)
+		require.Len(t, tokens, 1)
+		assert.Equal(t, created.Id, tokens[0].Id)
+		assert.Empty(t, tokens[0].Token)
+	})
+
+	t.Run("Bearer token authenticates", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodGet, testdata.EndpointAccessTokens, nil, "", "Bearer "+created.Token)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	})
+
+	t.Run("Basic credentials authenticate", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodGet, testdata.EndpointAccessTokens, nil, "",
+			basicAuth(username, created.Token))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	})
+
+	t.Run("Basic credentials of another user", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodGet, testdata.EndpointAccessTokens, nil, "",
+			basicAuth("admin", created.Token))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Last used time is recorded", func(t *testing.T) {
+		tokens := listTokens(t)
+		require.Len(t, tokens, 1)
+		assert.NotNil(t, tokens[0].LastUsedAt)
+	})
+
+	t.Run("Token can't create tokens", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodPost, testdata.EndpointAccessTokens,
+			map[string]any{"name": "nested-token", "scopes": []string{"push"}}, "", "Bearer "+created.Token)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+
+	t.Run("Revoke token of another user", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointAccessToken, created.Id), nil,
+			a.seeder.AdminToken(t), "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Revoked token is rejected", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointAccessToken, created.Id), nil,
+			sessionToken, "")
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		resp = a.doRequest(t, http.MethodGet, testdata.EndpointAccessTokens, nil, "", "Bearer "+created.Token)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+
+		tokens := listTokens(t)
+		require.Len(t, tokens, 1)
+		assert.NotNil(t, tokens[0].RevokedAt)
+	})
+
+	t.Run("Name of revoked token can be reused", func(t *testing.T) {
+		recreated := a.createToken(t, sessionToken, "lifecycle-token", []string{"pull"}, 0)
+		assert.Nil(t, recreated.ExpiresAt)
+		assert.Len(t, listTokens(t), 2)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodGet, testdata.EndpointAccessTokens, nil, "",
+			"Bearer oir_pat_unknown")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+}
+
+func (a *AccessTokenTestSuite) testManagementScopes(t *testing.T) {
+	username := "token-scope-user"
+	a.seeder.ProvisionUserWithPassword(t, username, "token.scope@t.com", "Admin", "SecurePass123!")
+	sessionToken := a.seeder.UserToken(t, username, "Admin")
+
+	pullToken := a.createToken(t, sessionToken, "pull-token", []string{"pull"}, 0)
+	readToken := a.createToken(t, sessionToken, "read-token", []string{"management:read"}, 0)
+	writeToken := a.createToken(t, sessionToken, "write-token", []string{"management:write"}, 0)
+
+	validateBody := map[string]any{"username": "token-scope-new-user"}
+
+	tcs := []struct {
+		name       string
+		method     string
+		endpoint   string
+		body       any
+		token      string
+		statusCode int
+	}{
+		{"Pull scope can't read", http.MethodGet, testdata.EndpointUsers, nil, pullToken.Token,
+			http.StatusForbidden},
+		{"Read scope can read", http.MethodGet, testdata.EndpointUsers, nil, readToken.Token, http.StatusOK},
+		{"Read scope can't write", http.MethodPost, testdata.EndpointValidateUser, validateBody, readToken.Token,
+			http.StatusForbidden},
+		{"Write scope can read", http.MethodGet, testdata.EndpointUsers, nil, writeToken.Token, http.StatusOK},
+		{"Write scope can write", http.MethodPost, testdata.EndpointValidateUser, validateBody, writeToken.Token,
+			http.StatusOK},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := a.doRequest(t, tc.method, tc.endpoint, tc.body, "", "Bearer "+tc.token)
+			defer resp.Body.Close()
+			helpers.AssertStatusCode(t, resp, tc.statusCode)
+		})
+	}
+
+	t.Run("Logout with a token", func(t *testing.T) {
+		resp := a.doRequest(t, http.MethodPost, testdata.EndpointLogout, nil, "", "Bearer "+writeToken.Token)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+}
+
+func (a *AccessTokenTestSuite) testRegistryAuthentication(t *testing.T) {
+	username := "token-registry-user"
+	a.seeder.ProvisionUserWithPassword(t, username, "token.registry@t.com", "Developer", "SecurePass123!")
+	sessionToken := a.seeder.UserToken(t, username, "Developer")
+
+	pullToken := a.createToken(t, sessionToken, "registry-pull", []string{"pull"}, 0)
+	pushToken := a.createToken(t, sessionToken, "registry-push", []string{"push"}, 0)
+	mgmtToken := a.createToken(t, sessionToken, "registry-mgmt", []string{"management:read"}, 0)
+
+	fakeUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token":"registry-auth-token","expires_in":300}`))
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer fakeUpstream.Close()
+
+	port := int(helpers.FindFreePort())
+	body := upstreamBody("token-registry-upstream", port)
+	body["upstream_url"] = fakeUpstream.URL
+	body["auth_config"].(map[string]any)["token_endpoint"] = fakeUpstream.URL + "/token"
+
+	resp := a.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, body, a.seeder.AdminToken(t), "")
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	waitForListener(t, port)
+
+	registryURL := fmt.Sprintf("http://localhost:%d/v2/", port)
+
+	tcs := []struct {
+		name          string
+		method        string
+		authorization string
+		statusCode    int
+	}{
+		{"Anonymous pull", http.MethodGet, "", http.StatusOK},
+		{"Pull with bearer token", http.MethodGet, "Bearer " + pullToken.Token, http.StatusOK},
+		{"Pull with basic credentials", http.MethodGet, basicAuth(username, pullToken.Token), http.StatusOK},
+		{"Push scope allows pull", http.MethodGet, "Bearer " + pushToken.Token, http.StatusOK},
+		{"Management scope can't pull", http.MethodGet, "Bearer " + mgmtToken.Token, http.StatusForbidden},
+		{"Pull scope can't push", http.MethodPost, "Bearer " + pullToken.Token, http.StatusForbidden},
+		{"Password is not accepted", http.MethodGet, basicAuth(username, "SecurePass123!"),
+			http.StatusUnauthorized},
+		{"Unknown token", http.MethodGet, "Bearer oir_pat_unknown", http.StatusUnauthorized},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, registryURL, nil)
+			require.NoError(t, err)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			helpers.AssertStatusCode(t, resp, tc.statusCode)
+
+			if tc.statusCode == http.StatusUnauthorized {
+				assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Basic")
+			}
+		})
+	}
+}
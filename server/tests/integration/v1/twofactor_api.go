@@ -0,0 +1,601 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/security"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type TwoFactorTestSuite struct {
+	name        string
+	apiVersion  string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+}
+
+func NewTwoFactorTestSuite(seeder *seeder.TestDataSeeder, baseURL string) *TwoFactorTestSuite {
+	return &TwoFactorTestSuite{
+		name:        "TwoFactorAPI",
+		apiVersion:  "v1",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+	}
+}
+
+func (s *TwoFactorTestSuite) Run(t *testing.T) {
+	t.Run("Enrollment", s.testEnrollment)
+	t.Run("Login", s.testLogin)
+	t.Run("RecoveryCodes", s.testRecoveryCodes)
+	t.Run("Lockout", s.testLockout)
+	t.Run("RoleRequirement", s.testRoleRequirement)
+	t.Run("AdminReset", s.testAdminReset)
+	t.Run("AccessTokens", s.testAccessTokens)
+}
+
+func (s *TwoFactorTestSuite) Name() string {
+	return s.name
+}
+
+func (s *TwoFactorTestSuite) APIVersion() string {
+	return s.apiVersion
+}
+
+type twoFactorLoginResponse struct {
+	TwoFactor *struct {
+		Token              string `json:"token"`
+		EnrollmentRequired bool   `json:"enrollment_required"`
+		ExpiresIn          int    `json:"expires_in"`
+	} `json:"two_factor"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type twoFactorEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// doRequest sends the request with the auth token cookie if it is set
+func (s *TwoFactorTestSuite) doRequest(t *testing.T, method, endpoint string, body any,
+	cookie *http.Cookie) *http.Response {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(reqBody)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, s.testBaseURL+endpoint, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func (s *TwoFactorTestSuite) adminCookie(t *testing.T) *http.Cookie {
+	t.Helper()
+
+	return &http.Cookie{Name: constants.AuthTokenCookie, Value: s.seeder.AdminToken(t)}
+}
+
+// login logs in with the password and returns the two-factor challenge. The challenge is nil if the session was
+// started.
+func (s *TwoFactorTestSuite) login(t *testing.T, username, password string) (*twoFactorLoginResponse,
+	*http.Response) {
+	t.Helper()
+
+	resp := s.doRequest(t, http.MethodPost, testdata.EndpointLogin,
+		map[string]string{"username": username, "password": password}, nil)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var res twoFactorLoginResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	return &res, resp
+}
+
+// sessionCookie logs in a user without two-factor authentication and returns the auth token cookie
+func (s *TwoFactorTestSuite) sessionCookie(t *testing.T, username, password string) *http.Cookie {
+	t.Helper()
+
+	res, resp := s.login(t, username, password)
+	require.Nil(t, res.TwoFactor)
+
+	authCookie, _ := findCookies(t, resp)
+	return authCookie
+}
+
+func (s *TwoFactorTestSuite) completeLogin(t *testing.T, token, code, recoveryCode string) *http.Response {
+	t.Helper()
+
+	return s.doRequest(t, http.MethodPost, testdata.EndpointLoginTwoFactor, map[string]string{
+		"token":         token,
+		"code":          code,
+		"recovery_code": recoveryCode,
+	}, nil)
+}
+
+// enable enrolls the user and confirms the enrollment with the code of the current time step. It returns the
+// secret, the step used to confirm and the recovery codes.
+func (s *TwoFactorTestSuite) enable(t *testing.T, cookie *http.Cookie) (secret string, step int64,
+	recoveryCodes []string) {
+	t.Helper()
+
+	resp := s.doRequest(t, http.MethodPost, testdata.EndpointTwoFactor, nil, cookie)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var enrollment twoFactorEnrollmentResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&enrollment))
+
+	step = security.TOTPStep(time.Now())
+	confirmResp := s.doRequest(t, http.MethodPost, testdata.EndpointTwoFactorConfirm,
+		map[string]string{"code": s.code(t, enrollment.Secret, step)}, cookie)
+	defer confirmResp.Body.Close()
+	require.Equal(t, http.StatusOK, confirmResp.StatusCode)
+
+	var codes struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	require.NoError(t, json.NewDecoder(confirmResp.Body).Decode(&codes))
+	require.Len(t, codes.RecoveryCodes, constants.TwoFactorRecoveryCodes)
+
+	return enrollment.Secret, step, codes.RecoveryCodes
+}
+
+// code returns the TOTP code of the time step
+func (s *TwoFactorTestSuite) code(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	code, err := security.TOTPCode(secret, step)
+	require.NoError(t, err)
+	return code
+}
+
+func (s *TwoFactorTestSuite) status(t *testing.T, cookie *http.Cookie) map[string]any {
+	t.Helper()
+
+	resp := s.doRequest(t, http.MethodGet, testdata.EndpointTwoFactor, nil, cookie)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var status map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	return status
+}
+
+func (s *TwoFactorTestSuite) setRoleRequirement(t *testing.T, role string, required bool) {
+	t.Helper()
+
+	resp := s.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointRoleByName, role),
+		map[string]bool{"two_factor_required": required}, s.adminCookie(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func (s *TwoFactorTestSuite) testEnrollment(t *testing.T) {
+	s.seeder.ProvisionUserWithPassword(t, "2fa-enroll-user", "2fa.enroll.user@t.com", "Developer", "TwoFactor123!")
+	cookie := s.sessionCookie(t, "2fa-enroll-user", "TwoFactor123!")
+
+	var enrollment twoFactorEnrollmentResponse
+	t.Run("Enroll returns secret", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, testdata.EndpointTwoFactor, nil, cookie)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&enrollment))
+		assert.NotEmpty(t, enrollment.Secret)
+		assert.Contains(t, enrollment.ProvisioningURI, "otpauth://totp/")
+		assert.Contains(t, enrollment.ProvisioningURI, "secret="+enrollment.Secret)
+
+		status := s.status(t, cookie)
+		assert.Equal(t, false, status["enabled"])
+	})
+
+	t.Run("Pending enrollment doesn't change login", func(t *testing.T) {
+		res, _ := s.login(t, "2fa-enroll-user", "TwoFactor123!")
+		assert.Nil(t, res.TwoFactor)
+	})
+
+	t.Run("Confirm with invalid code", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, testdata.EndpointTwoFactorConfirm,
+			map[string]string{"code": "000000"}, cookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Confirm without code", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, testdata.EndpointTwoFactorConfirm, map[string]string{}, cookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Confirm with valid code", func(t *testing.T) {
+		code := s.code(t, enrollment.Secret, security.TOTPStep(time.Now()))
+		resp := s.doRequest(t, http.MethodPost, testdata.EndpointTwoFactorConfirm,
+			map[string]string{"code": code}, cookie)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var codes map[string][]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&codes))
+		assert.Len(t, codes["recovery_codes"], constants.TwoFactorRecoveryCodes)
+
+		status := s.status(t, cookie)
+		assert.Equal(t, true, status["enabled"])
+		assert.Equal(t, false, status["required"])
+		assert.Equal(t, float64(constants.TwoFactorRecoveryCodes), status["recovery_codes_remaining"])
+	})
+
+	t.Run("Enroll again", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, testdata.EndpointTwoFactor, nil, cookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusConflict)
+	})
+}
+
+func (s *TwoFactorTestSuite) testLogin(t *testing.T) {
+	s.seeder.ProvisionUserWithPassword(t, "2fa-login-user", "2fa.login.user@t.com", "Developer", "TwoFactor123!")
+	secret, step, _ := s.enable(t, s.sessionCookie(t, "2fa-login-user", "TwoFactor123!"))
+
+	t.Run("Password alone doesn't start a session", func(t *testing.T) {
+		res, resp := s.login(t, "2fa-login-user", "TwoFactor123!")
+		require.NotNil(t, res.TwoFactor)
+		assert.NotEmpty(t, res.TwoFactor.Token)
+		assert.False(t, res.TwoFactor.EnrollmentRequired)
+		assert.Equal(t, constants.TwoFactorChallengeExpiry, res.TwoFactor.ExpiresIn)
+		assert.Empty(t, resp.Cookies())
+	})
+
+	t.Run("Wrong password", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, testdata.EndpointLogin,
+			map[string]string{"username": "2fa-login-user", "password": "WrongPass123!"}, nil)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Missing code", func(t *testing.T) {
+		res, _ := s.login(t, "2fa-login-user", "TwoFactor123!")
+
+		resp := s.completeLogin(t, res.TwoFactor.Token, "", "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		resp := s.completeLogin(t, constants.TwoFactorTokenPrefix+"unknown", s.code(t, secret, step+1), "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Used code is rejected", func(t *testing.T) {
+		res, _ := s.login(t, "2fa-login-user", "TwoFactor123!")
+
+		resp := s.completeLogin(t, res.TwoFactor.Token, s.code(t, secret, step), "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Valid code starts a session", func(t *testing.T) {
+		res, _ := s.login(t, "2fa-login-user", "TwoFactor123!")
+
+		resp := s.completeLogin(t, res.TwoFactor.Token, s.code(t, secret, step+1), "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		authCookie, _ := findCookies(t, resp)
+		meResp := s.doRequest(t, http.MethodGet, testdata.EndpointCurrentUser, nil, authCookie)
+		defer meResp.Body.Close()
+		helpers.AssertStatusCode(t, meResp, http.StatusOK)
+
+		// the token can't be used twice
+		retryResp := s.completeLogin(t, res.TwoFactor.Token, s.code(t, secret, step+1), "")
+		defer retryResp.Body.Close()
+		helpers.AssertStatusCode(t, retryResp, http.StatusUnauthorized)
+	})
+}
+
+func (s *TwoFactorTestSuite) testRecoveryCodes(t *testing.T) {
+	s.seeder.ProvisionUserWithPassword(t, "2fa-recovery-user", "2fa.recovery.user@t.com", "Developer",
+		"TwoFactor123!")
+	_, _, recoveryCodes := s.enable(t, s.sessionCookie(t, "2fa-recovery-user", "TwoFactor123!"))
+
+	var cookie *http.Cookie
+	t.Run("Recovery code starts a session", func(t *testing.T) {
+		res, _ := s.login(t, "2fa-recovery-user", "TwoFactor123!")
+
+		resp := s.completeLogin(t, res.TwoFactor.Token, "", recoveryCodes[0])
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		cookie, _ = findCookies(t, resp)
+		status := s.status(t, cookie)
+		assert.Equal(t, float64(constants.TwoFactorRecoveryCodes-1), status["recovery_codes_remaining"])
+	})
+
+	t.Run("Recovery code works once", func(t *testing.T) {
+		res, _ := s.login(t, "2fa-recovery-user", "TwoFactor123!")
+
+		resp := s.completeLogin(t, res.TwoFactor.Token, "", recoveryCodes[0])
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+	})
+
+	t.Run("Regenerate replaces recovery codes", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPost, testdata.EndpointTwoFactorRecoveryCodes,
+			map[string]string{"recovery_code": recoveryCodes[1]}, cookie)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var codes map[string][]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&codes))
+		require.Len(t, codes["recovery_codes"], constants.TwoFactorRecoveryCodes)
+
+		res, _ := s.login(t, "2fa-recovery-user", "TwoFactor123!")
+		oldResp := s.completeLogin(t, res.TwoFactor.Token, "", recoveryCodes[2])
+		defer oldResp.Body.Close()
+		helpers.AssertStatusCode(t, oldResp, http.StatusUnauthorized)
+
+		recoveryCodes = codes["recovery_codes"]
+	})
+
+	t.Run("Disable with invalid code", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodDelete, testdata.EndpointTwoFactor,
+			map[string]string{"code": "000000"}, cookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodDelete, testdata.EndpointTwoFactor,
+			map[string]string{"recovery_code": recoveryCodes[0]}, cookie)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		res, _ := s.login(t, "2fa-recovery-user", "TwoFactor123!")
+		assert.Nil(t, res.TwoFactor)
+	})
+}
+
+func (s *TwoFactorTestSuite) testLockout(t *testing.T) {
+	userID := s.seeder.ProvisionUserWithPassword(t, "2fa-lockout-user", "2fa.lockout.user@t.com", "Developer",
+		"TwoFactor123!")
+	secret, step, _ := s.enable(t, s.sessionCookie(t, "2fa-lockout-user", "TwoFactor123!"))
+
+	res, _ := s.login(t, "2fa-lockout-user", "TwoFactor123!")
+
+	t.Run("Wrong codes lock the account", func(t *testing.T) {
+		for range constants.MaxFailedLoginAttempts {
+			resp := s.completeLogin(t, res.TwoFactor.Token, "000000", "")
+			resp.Body.Close()
+			helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+		}
+
+		resp := s.completeLogin(t, res.TwoFactor.Token, "000000", "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+
+	t.Run("Valid code after lockout", func(t *testing.T) {
+		resp := s.completeLogin(t, res.TwoFactor.Token, s.code(t, secret, step+1), "")
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+
+		loginResp := s.doRequest(t, http.MethodPost, testdata.EndpointLogin,
+			map[string]string{"username": "2fa-lockout-user", "password": "TwoFactor123!"}, nil)
+		defer loginResp.Body.Close()
+		helpers.AssertStatusCode(t, loginResp, http.StatusForbidden)
+	})
+
+	unlockResp := s.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUserUnlock, userID), nil, s.adminCookie(t))
+	unlockResp.Body.Close()
+	helpers.AssertStatusCode(t, unlockResp, http.StatusOK)
+}
+
+func (s *TwoFactorTestSuite) testRoleRequirement(t *testing.T) {
+	s.seeder.ProvisionUserWithPassword(t, "2fa-required-user", "2fa.required.user@t.com", constants.RoleGuest,
+		"TwoFactor123!")
+
+	t.Run("List roles", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodGet, testdata.EndpointRoles, nil, s.adminCookie(t))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var res struct {
+			Roles []struct {
+				Name              string `json:"name"`
+				TwoFactorRequired bool   `json:"two_factor_required"`
+			} `json:"roles"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+
+		var names []string
+		for _, role := range res.Roles {
+			names = append(names, role.Name)
+			assert.False(t, role.TwoFactorRequired)
+		}
+		assert.ElementsMatch(t, []string{constants.RoleAdmin, constants.RoleMaintainer, constants.RoleDeveloper,
+			constants.RoleGuest}, names)
+	})
+
+	t.Run("Update invalid role", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointRoleByName, "Unknown"),
+			map[string]bool{"two_factor_required": true}, s.adminCookie(t))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	t.Run("Only admins can update roles", func(t *testing.T) {
+		cookie := s.sessionCookie(t, "2fa-required-user", "TwoFactor123!")
+		resp := s.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointRoleByName, constants.RoleGuest),
+			map[string]bool{"two_factor_required": false}, cookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+
+	s.setRoleRequirement(t, constants.RoleGuest, true)
+	defer s.setRoleRequirement(t, constants.RoleGuest, false)
+
+	t.Run("Login requires enrollment", func(t *testing.T) {
+		res, resp := s.login(t, "2fa-required-user", "TwoFactor123!")
+		require.NotNil(t, res.TwoFactor)
+		assert.True(t, res.TwoFactor.EnrollmentRequired)
+		assert.Empty(t, resp.Cookies())
+
+		codeResp := s.completeLogin(t, res.TwoFactor.Token, "123456", "")
+		defer codeResp.Body.Close()
+		helpers.AssertStatusCode(t, codeResp, http.StatusBadRequest)
+	})
+
+	var cookie *http.Cookie
+	var secret string
+	var step int64
+	t.Run("Enroll during login", func(t *testing.T) {
+		res, _ := s.login(t, "2fa-required-user", "TwoFactor123!")
+
+		resp := s.doRequest(t, http.MethodPost, testdata.EndpointLoginTwoFactorEnroll,
+			map[string]string{"token": res.TwoFactor.Token}, nil)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var enrollment twoFactorEnrollmentResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&enrollment))
+		require.NotEmpty(t, enrollment.Secret)
+
+		secret, step = enrollment.Secret, security.TOTPStep(time.Now())
+		codeResp := s.completeLogin(t, res.TwoFactor.Token, s.code(t, secret, step), "")
+		defer codeResp.Body.Close()
+		require.Equal(t, http.StatusOK, codeResp.StatusCode)
+
+		var loginRes twoFactorLoginResponse
+		require.NoError(t, json.NewDecoder(codeResp.Body).Decode(&loginRes))
+		assert.Len(t, loginRes.RecoveryCodes, constants.TwoFactorRecoveryCodes)
+
+		cookie, _ = findCookies(t, codeResp)
+		status := s.status(t, cookie)
+		assert.Equal(t, true, status["enabled"])
+		assert.Equal(t, true, status["required"])
+	})
+
+	t.Run("Enroll during login after enabled", func(t *testing.T) {
+		res, _ := s.login(t, "2fa-required-user", "TwoFactor123!")
+		assert.False(t, res.TwoFactor.EnrollmentRequired)
+
+		resp := s.doRequest(t, http.MethodPost, testdata.EndpointLoginTwoFactorEnroll,
+			map[string]string{"token": res.TwoFactor.Token}, nil)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusConflict)
+	})
+
+	t.Run("Disable is refused", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodDelete, testdata.EndpointTwoFactor,
+			map[string]string{"code": s.code(t, secret, step+1)}, cookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusConflict)
+	})
+}
+
+func (s *TwoFactorTestSuite) testAdminReset(t *testing.T) {
+	userID := s.seeder.ProvisionUserWithPassword(t, "2fa-reset-user", "2fa.reset.user@t.com", "Developer",
+		"TwoFactor123!")
+	s.enable(t, s.sessionCookie(t, "2fa-reset-user", "TwoFactor123!"))
+
+	t.Run("Only admins can reset", func(t *testing.T) {
+		cookie := &http.Cookie{
+			Name:  constants.AuthTokenCookie,
+			Value: s.seeder.UserToken(t, "2fa-reset-user", constants.RoleDeveloper),
+		}
+		resp := s.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUserTwoFactor, userID), nil, cookie)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		res, _ := s.login(t, "2fa-reset-user", "TwoFactor123!")
+		require.NotNil(t, res.TwoFactor)
+
+		resp := s.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUserTwoFactor, userID), nil, s.adminCookie(t))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// pending logins are discarded
+		codeResp := s.completeLogin(t, res.TwoFactor.Token, "123456", "")
+		defer codeResp.Body.Close()
+		helpers.AssertStatusCode(t, codeResp, http.StatusUnauthorized)
+
+		res, _ = s.login(t, "2fa-reset-user", "TwoFactor123!")
+		assert.Nil(t, res.TwoFactor)
+	})
+
+	t.Run("Reset without enrollment", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUserTwoFactor, userID), nil, s.adminCookie(t))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Reset unknown user", func(t *testing.T) {
+		resp := s.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUserTwoFactor, "unknown-user-id"),
+			nil, s.adminCookie(t))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+}
+
+func (s *TwoFactorTestSuite) testAccessTokens(t *testing.T) {
+	s.seeder.ProvisionUserWithPassword(t, "2fa-token-user", "2fa.token.user@t.com", "Developer", "TwoFactor123!")
+	cookie := s.sessionCookie(t, "2fa-token-user", "TwoFactor123!")
+
+	resp := s.doRequest(t, http.MethodPost, testdata.EndpointAccessTokens, map[string]any{
+		"name":            "2fa-token",
+		"scopes":          []string{constants.ScopeManagementWrite},
+		"expires_in_days": 1,
+	}, cookie)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var token accessTokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&token))
+
+	t.Run("Enroll with access token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, s.testBaseURL+testdata.EndpointTwoFactor, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+
+	t.Run("Accounts with two-factor authentication keep using access tokens", func(t *testing.T) {
+		s.enable(t, cookie)
+
+		req, err := http.NewRequest(http.MethodGet, s.testBaseURL+testdata.EndpointCurrentUser, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	})
+}
@@ -0,0 +1,1572 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/tests/integration/helpers"
+	"github.com/ksankeerth/open-image-registry/tests/integration/seeder"
+	"github.com/ksankeerth/open-image-registry/tests/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type UpstreamTestSuite struct {
+	apiVersion  string
+	name        string
+	seeder      *seeder.TestDataSeeder
+	testBaseURL string
+}
+
+func NewUpstreamTestSuite(seeder *seeder.TestDataSeeder, baseURL string) *UpstreamTestSuite {
+	return &UpstreamTestSuite{
+		apiVersion:  "v1",
+		name:        "Upstream API",
+		seeder:      seeder,
+		testBaseURL: baseURL,
+	}
+}
+
+func (u *UpstreamTestSuite) Name() string {
+	return u.name
+}
+
+func (u *UpstreamTestSuite) APIVersion() string {
+	return u.apiVersion
+}
+
+func (u *UpstreamTestSuite) Run(t *testing.T) {
+	t.Run("CreateUpstream_Validation", u.testCreateUpstreamValidation)
+	t.Run("CreateUpstream_Conflicts", u.testCreateUpstreamConflicts)
+	t.Run("GetUpstream", u.testGetUpstream)
+	t.Run("ListUpstreams", u.testListUpstreams)
+	t.Run("UpdateUpstream", u.testUpdateUpstream)
+	t.Run("UpdateUpstreamConfigs", u.testUpdateUpstreamConfigs)
+	t.Run("MaskedCredentialsRoundTrip", u.testMaskedCredentialsRoundTrip)
+	t.Run("StateChange", u.testUpstreamStateChange)
+	t.Run("DeleteUpstream", u.testDeleteUpstream)
+	t.Run("NonAdminAccess", u.testNonAdminAccess)
+	t.Run("ListenerLifecycle", u.testListenerLifecycle)
+	t.Run("CachingOfUpstreamImages", u.testCachingOfUpstreamImages)
+	t.Run("HealthCheck", u.testHealthCheck)
+	t.Run("ProxyAndTLS", u.testProxyAndTLS)
+	t.Run("CacheTTLRules", u.testCacheTTLRules)
+	t.Run("RateLimits", u.testRateLimits)
+	t.Run("UpstreamErrors", u.testUpstreamErrors)
+}
+
+func (u *UpstreamTestSuite) doRequest(t *testing.T, method, endpoint string, body any, token string) *http.Response {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(reqBody)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, u.testBaseURL+endpoint, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", testdata.ApplicationJson)
+	helpers.SetAuthCookie(req, token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func upstreamBody(name string, port int) map[string]any {
+	return map[string]any{
+		"name":         name,
+		"description":  "upstream for tests",
+		"vendor":       "docker_hub",
+		"port":         port,
+		"upstream_url": "https://registry-1.docker.io",
+		"auth_config": map[string]any{
+			"auth_type": "basic",
+			"credentials_json": map[string]any{
+				"username": "puller",
+				"password": "secret-password",
+			},
+			"token_endpoint": "https://auth.docker.io/token",
+		},
+		"access_config": map[string]any{
+			"connection_timeout": 5,
+			"read_timeout":       20,
+		},
+		"cache_config": map[string]any{
+			"enabled":     true,
+			"ttl_seconds": 600,
+		},
+		"storage_config": map[string]any{
+			"storage_limit":     500,
+			"cleanup_threshold": 70,
+		},
+	}
+}
+
+func (u *UpstreamTestSuite) testCreateUpstreamValidation(t *testing.T) {
+	withChange := func(change func(body map[string]any)) map[string]any {
+		body := upstreamBody("upstream-validation", 18001)
+		change(body)
+		return body
+	}
+
+	tcs := []struct {
+		name       string
+		body       any
+		statusCode int
+	}{
+		{
+			name:       "Non JSON body",
+			body:       "invalid body",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid name",
+			body:       withChange(func(b map[string]any) { b["name"] = "docker hub" }),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Too short name",
+			body:       withChange(func(b map[string]any) { b["name"] = "dh" }),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Privileged port",
+			body:       withChange(func(b map[string]any) { b["port"] = 80 }),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid url",
+			body:       withChange(func(b map[string]any) { b["upstream_url"] = "ftp://registry-1.docker.io" }),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Unsupported vendor",
+			body:       withChange(func(b map[string]any) { b["vendor"] = "unknown" }),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name: "Unsupported auth type",
+			body: withChange(func(b map[string]any) {
+				b["auth_config"] = map[string]any{"auth_type": "kerberos"}
+			}),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name: "Basic auth without password",
+			body: withChange(func(b map[string]any) {
+				b["auth_config"] = map[string]any{
+					"auth_type":        "basic",
+					"credentials_json": map[string]any{"username": "puller"},
+				}
+			}),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name: "ECR auth without keys",
+			body: withChange(func(b map[string]any) {
+				b["auth_config"] = map[string]any{
+					"auth_type":        "aws_ecr",
+					"credentials_json": map[string]any{"region": "us-east-1"},
+				}
+			}),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name: "Read timeout out of range",
+			body: withChange(func(b map[string]any) {
+				b["access_config"] = map[string]any{"read_timeout": 601}
+			}),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name: "Cache ttl out of range",
+			body: withChange(func(b map[string]any) {
+				b["cache_config"] = map[string]any{"ttl_seconds": 10}
+			}),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name: "Negative cache ttl out of range",
+			body: withChange(func(b map[string]any) {
+				b["cache_config"] = map[string]any{"negative_ttl_seconds": 3601}
+			}),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name: "Cleanup threshold out of range",
+			body: withChange(func(b map[string]any) {
+				b["storage_config"] = map[string]any{"cleanup_threshold": 99}
+			}),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Valid upstream",
+			body:       withChange(func(b map[string]any) {}),
+			statusCode: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := u.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, tc.body, u.seeder.AdminToken(t))
+			defer resp.Body.Close()
+
+			helpers.AssertStatusCode(t, resp, tc.statusCode)
+		})
+	}
+}
+
+func (u *UpstreamTestSuite) testCreateUpstreamConflicts(t *testing.T) {
+	u.seeder.CreateUpstream(t, "upstream-conflict", 18002, "https://registry-1.docker.io")
+
+	t.Run("Same name", func(t *testing.T) {
+		resp := u.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, upstreamBody("upstream-conflict", 18003),
+			u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusConflict)
+	})
+
+	t.Run("Same port", func(t *testing.T) {
+		resp := u.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, upstreamBody("upstream-conflict-2", 18002),
+			u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusConflict)
+	})
+}
+
+func (u *UpstreamTestSuite) testGetUpstream(t *testing.T) {
+	resp := u.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, upstreamBody("upstream-get", 18004), u.seeder.AdminToken(t))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	resp.Body.Close()
+	id := created["reg_id"].(string)
+
+	t.Run("Non existent upstream", func(t *testing.T) {
+		resp := u.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamByID, "non-existent-id"), nil,
+			u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Upstream with configs", func(t *testing.T) {
+		resp := u.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamByID, id), nil, u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		var res struct {
+			Id         string `json:"id"`
+			Name       string `json:"name"`
+			Port       int    `json:"port"`
+			Status     string `json:"status"`
+			AuthConfig struct {
+				AuthType       string         `json:"auth_type"`
+				CredentialJson map[string]any `json:"credentials_json"`
+				TokenEndpoint  string         `json:"token_endpoint"`
+			} `json:"auth_config"`
+			AccessConfig struct {
+				ConnectionTimeout int `json:"connection_timeout"`
+				ReadTimeout       int `json:"read_timeout"`
+				MaxConnections    int `json:"max_connections"`
+			} `json:"access_config"`
+			CacheConfig struct {
+				Enabled    bool `json:"enabled"`
+				TtlSeconds int  `json:"ttl_seconds"`
+			} `json:"cache_config"`
+			StorageConfig struct {
+				StorageLimit     float32 `json:"storage_limit"`
+				CleanupThreshold float32 `json:"cleanup_threshold"`
+			} `json:"storage_config"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+
+		assert.Equal(t, id, res.Id)
+		assert.Equal(t, "upstream-get", res.Name)
+		assert.Equal(t, 18004, res.Port)
+		assert.Equal(t, constants.ResourceStateActive, res.Status)
+
+		assert.Equal(t, "basic", res.AuthConfig.AuthType)
+		assert.Equal(t, "https://auth.docker.io/token", res.AuthConfig.TokenEndpoint)
+		assert.Equal(t, "puller", res.AuthConfig.CredentialJson["username"])
+		assert.NotEqual(t, "secret-password", res.AuthConfig.CredentialJson["credential"], "secrets must be masked")
+
+		assert.Equal(t, 5, res.AccessConfig.ConnectionTimeout)
+		assert.Equal(t, 20, res.AccessConfig.ReadTimeout)
+		assert.Equal(t, 100, res.AccessConfig.MaxConnections, "default value must be used")
+
+		assert.True(t, res.CacheConfig.Enabled)
+		assert.Equal(t, 600, res.CacheConfig.TtlSeconds)
+		assert.Equal(t, float32(500), res.StorageConfig.StorageLimit)
+		assert.Equal(t, float32(70), res.StorageConfig.CleanupThreshold)
+	})
+}
+
+func (u *UpstreamTestSuite) testListUpstreams(t *testing.T) {
+	u.seeder.CreateUpstream(t, "upstream-list-1", 18005, "https://registry-1.docker.io")
+	id2 := u.seeder.CreateUpstream(t, "upstream-list-2", 18006, "https://registry-1.docker.io")
+	u.seeder.SetUpstreamState(t, id2, constants.ResourceStateDeprecated)
+
+	tcs := []struct {
+		name       string
+		query      url.Values
+		statusCode int
+		expected   []string
+	}{
+		{
+			name:       "Search by name",
+			query:      url.Values{"search": {"upstream-list"}, "sort_by": {"name"}},
+			statusCode: http.StatusOK,
+			expected:   []string{"upstream-list-1", "upstream-list-2"},
+		},
+		{
+			name:       "Filter by state",
+			query:      url.Values{"search": {"upstream-list"}, "state": {constants.ResourceStateDeprecated}},
+			statusCode: http.StatusOK,
+			expected:   []string{"upstream-list-2"},
+		},
+		{
+			name:       "Not allowed sort field",
+			query:      url.Values{"sort_by": {"upstream_url"}},
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := u.doRequest(t, http.MethodGet, testdata.EndpointUpstreams+"?"+tc.query.Encode(), nil,
+				u.seeder.AdminToken(t))
+			defer resp.Body.Close()
+
+			helpers.AssertStatusCode(t, resp, tc.statusCode)
+			if tc.statusCode != http.StatusOK {
+				return
+			}
+
+			var res struct {
+				Total    int `json:"total"`
+				Entities []struct {
+					Name string `json:"name"`
+				} `json:"entities"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+
+			names := make([]string, 0, len(res.Entities))
+			for _, e := range res.Entities {
+				names = append(names, e.Name)
+			}
+			assert.Equal(t, len(tc.expected), res.Total)
+			assert.ElementsMatch(t, tc.expected, names)
+		})
+	}
+}
+
+func (u *UpstreamTestSuite) testUpdateUpstream(t *testing.T) {
+	id := u.seeder.CreateUpstream(t, "upstream-update", 18007, "https://registry-1.docker.io")
+	u.seeder.CreateUpstream(t, "upstream-update-other", 18008, "https://registry-1.docker.io")
+
+	withID := func(regID string, body map[string]any) map[string]any {
+		body["reg_id"] = regID
+		return body
+	}
+
+	tcs := []struct {
+		name       string
+		id         string
+		body       map[string]any
+		statusCode int
+	}{
+		{
+			name:       "Update non-existent upstream",
+			id:         "non-existent-id",
+			body:       withID("non-existent-id", upstreamBody("upstream-update-x", 18009)),
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "ID mismatch",
+			id:         id,
+			body:       withID("another-id", upstreamBody("upstream-update", 18007)),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Port used by another upstream",
+			id:         id,
+			body:       withID(id, upstreamBody("upstream-update", 18008)),
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "Name used by another upstream",
+			id:         id,
+			body:       withID(id, upstreamBody("upstream-update-other", 18007)),
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "Valid update",
+			id:         id,
+			body:       withID(id, upstreamBody("upstream-updated", 18010)),
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := u.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUpstreamByID, tc.id), tc.body,
+				u.seeder.AdminToken(t))
+			defer resp.Body.Close()
+
+			helpers.AssertStatusCode(t, resp, tc.statusCode)
+		})
+	}
+
+	resp := u.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamByID, id), nil, u.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	var res struct {
+		Name string `json:"name"`
+		Port int    `json:"port"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	assert.Equal(t, "upstream-updated", res.Name)
+	assert.Equal(t, 18010, res.Port)
+}
+
+func (u *UpstreamTestSuite) testUpdateUpstreamConfigs(t *testing.T) {
+	id := u.seeder.CreateUpstream(t, "upstream-configs", 18011, "https://registry-1.docker.io")
+
+	tcs := []struct {
+		name       string
+		endpoint   string
+		id         string
+		body       any
+		statusCode int
+	}{
+		{
+			name:     "Auth config",
+			endpoint: testdata.EndpointUpstreamAuthConfig,
+			id:       id,
+			body: map[string]any{
+				"auth_type":        "basic",
+				"credentials_json": map[string]any{"username": "puller", "password": "pass"},
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "Invalid auth config",
+			endpoint:   testdata.EndpointUpstreamAuthConfig,
+			id:         id,
+			body:       map[string]any{"auth_type": "basic"},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Auth config of non-existent upstream",
+			endpoint:   testdata.EndpointUpstreamAuthConfig,
+			id:         "non-existent-id",
+			body:       map[string]any{"auth_type": "anonymous"},
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:     "Cache config",
+			endpoint: testdata.EndpointUpstreamCacheConfig,
+			id:       id,
+			body: map[string]any{"enabled": true, "ttl_seconds": 120, "negative_ttl_seconds": 0, "ttl_rules": []map[string]any{
+				{"tag_pattern": "latest", "ttl_seconds": 0},
+				{"tag_pattern": "1.*", "ttl_seconds": 86400},
+			}},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:     "Cache config without negative ttl",
+			endpoint: testdata.EndpointUpstreamCacheConfig,
+			id:       id,
+			body: map[string]any{"enabled": true, "ttl_seconds": 120, "ttl_rules": []map[string]any{
+				{"tag_pattern": "latest", "ttl_seconds": 0},
+				{"tag_pattern": "1.*", "ttl_seconds": 86400},
+			}},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:     "Invalid ttl rule",
+			endpoint: testdata.EndpointUpstreamCacheConfig,
+			id:       id,
+			body: map[string]any{"enabled": true, "ttl_seconds": 120, "ttl_rules": []map[string]any{
+				{"tag_pattern": "[1-", "ttl_seconds": 60},
+			}},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Storage config",
+			endpoint:   testdata.EndpointUpstreamStorageConfig,
+			id:         id,
+			body:       map[string]any{"storage_limit": 2048, "cleanup_threshold": 90},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "Network config",
+			endpoint:   testdata.EndpointUpstreamNetworkConfig,
+			id:         id,
+			body:       map[string]any{"connection_timeout": 15, "max_retries": 5},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "Invalid network config",
+			endpoint:   testdata.EndpointUpstreamNetworkConfig,
+			id:         id,
+			body:       map[string]any{"max_connections": 5000},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Network config of non-existent upstream",
+			endpoint:   testdata.EndpointUpstreamNetworkConfig,
+			id:         "non-existent-id",
+			body:       map[string]any{"connection_timeout": 15},
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := u.doRequest(t, http.MethodPut, fmt.Sprintf(tc.endpoint, tc.id), tc.body, u.seeder.AdminToken(t))
+			defer resp.Body.Close()
+
+			helpers.AssertStatusCode(t, resp, tc.statusCode)
+		})
+	}
+
+	resp := u.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamByID, id), nil, u.seeder.AdminToken(t))
+	defer resp.Body.Close()
+
+	var res struct {
+		AccessConfig struct {
+			ConnectionTimeout int `json:"connection_timeout"`
+			MaxRetries        int `json:"max_retries"`
+		} `json:"access_config"`
+		CacheConfig struct {
+			Enabled            bool `json:"enabled"`
+			TtlSeconds         int  `json:"ttl_seconds"`
+			NegativeTtlSeconds *int `json:"negative_ttl_seconds"`
+			TtlRules           []struct {
+				TagPattern string `json:"tag_pattern"`
+				TtlSeconds int    `json:"ttl_seconds"`
+			} `json:"ttl_rules"`
+		} `json:"cache_config"`
+		StorageConfig struct {
+			StorageLimit     float32 `json:"storage_limit"`
+			CleanupThreshold float32 `json:"cleanup_threshold"`
+		} `json:"storage_config"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+
+	assert.Equal(t, 15, res.AccessConfig.ConnectionTimeout)
+	assert.Equal(t, 5, res.AccessConfig.MaxRetries)
+	assert.True(t, res.CacheConfig.Enabled)
+	assert.Equal(t, 120, res.CacheConfig.TtlSeconds)
+	require.NotNil(t, res.CacheConfig.NegativeTtlSeconds)
+	assert.Equal(t, 0, *res.CacheConfig.NegativeTtlSeconds)
+	require.Len(t, res.CacheConfig.TtlRules, 2)
+	assert.Equal(t, "latest", res.CacheConfig.TtlRules[0].TagPattern)
+	assert.Equal(t, 0, res.CacheConfig.TtlRules[0].TtlSeconds)
+	assert.Equal(t, "1.*", res.CacheConfig.TtlRules[1].TagPattern)
+	assert.Equal(t, float32(2048), res.StorageConfig.StorageLimit)
+	assert.Equal(t, float32(90), res.StorageConfig.CleanupThreshold)
+
+	t.Run("Upstream update without negative ttl", func(t *testing.T) {
+		body := upstreamBody("upstream-configs", 18011)
+		body["reg_id"] = id
+		resp := u.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUpstreamByID, id), body,
+			u.seeder.AdminToken(t))
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = u.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamByID, id), nil, u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		var res struct {
+			CacheConfig struct {
+				TtlSeconds         int  `json:"ttl_seconds"`
+				NegativeTtlSeconds *int `json:"negative_ttl_seconds"`
+			} `json:"cache_config"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.Equal(t, 600, res.CacheConfig.TtlSeconds)
+		require.NotNil(t, res.CacheConfig.NegativeTtlSeconds)
+		assert.Equal(t, 0, *res.CacheConfig.NegativeTtlSeconds, "negative ttl must be kept")
+	})
+}
+
+// testMaskedCredentialsRoundTrip updates upstreams with cloud credentials as they are returned by GET, with masked
+// secrets.
+func (u *UpstreamTestSuite) testMaskedCredentialsRoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	body := upstreamBody("upstream-gcp", 18015)
+	body["vendor"] = "gcr"
+	body["upstream_url"] = "https://gcr.io"
+	body["auth_config"] = map[string]any{
+		"auth_type": "gcp_service_account",
+		"credentials_json": map[string]any{
+			"client_email": "puller@project.iam.gserviceaccount.com",
+			"private_key":  privateKey,
+		},
+	}
+	resp := u.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, body, u.seeder.AdminToken(t))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	resp.Body.Close()
+	id := created["reg_id"].(string)
+
+	getUpstream := func(t *testing.T) map[string]any {
+		resp := u.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamByID, id), nil, u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var res map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		return res
+	}
+
+	t.Run("Auth config", func(t *testing.T) {
+		authConfig := getUpstream(t)["auth_config"]
+		require.NotContains(t, fmt.Sprint(authConfig), "PRIVATE KEY", "secrets must be masked")
+
+		resp := u.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUpstreamAuthConfig, id), authConfig,
+			u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	})
+
+	t.Run("Upstream", func(t *testing.T) {
+		upstream := getUpstream(t)
+		body := upstreamBody("upstream-gcp", 18015)
+		body["reg_id"] = id
+		body["vendor"] = "gcr"
+		body["upstream_url"] = "https://gcr.io"
+		body["auth_config"] = upstream["auth_config"]
+
+		resp := u.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUpstreamByID, id), body,
+			u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+	})
+
+	assertMaskedRejected := func(t *testing.T, resp *http.Response, field string) {
+		t.Helper()
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+
+		var res map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.Contains(t, res["error_message"], field)
+	}
+
+	t.Run("Masked key with another upstream URL", func(t *testing.T) {
+		upstream := getUpstream(t)
+		body := upstreamBody("upstream-gcp", 18015)
+		body["reg_id"] = id
+		body["vendor"] = "gcr"
+		body["upstream_url"] = "https://eu.gcr.io"
+		body["auth_config"] = upstream["auth_config"]
+
+		resp := u.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUpstreamByID, id), body,
+			u.seeder.AdminToken(t))
+		assertMaskedRejected(t, resp, "upstream_url")
+	})
+
+	t.Run("Masked key with another token endpoint", func(t *testing.T) {
+		authConfig := getUpstream(t)["auth_config"].(map[string]any)
+		authConfig["token_endpoint"] = "https://token.attacker.test/token"
+
+		resp := u.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUpstreamAuthConfig, id), authConfig,
+			u.seeder.AdminToken(t))
+		assertMaskedRejected(t, resp, "token_endpoint")
+	})
+
+	t.Run("Masked key of another auth type", func(t *testing.T) {
+		resp := u.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUpstreamAuthConfig, id), map[string]any{
+			"auth_type": "azure_service_principal",
+			"credentials_json": map[string]any{
+				"tenant_id": "tenant", "client_id": "client", "client_secret": "********",
+				"private_key": "********",
+			},
+		}, u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+	})
+
+	// the persisted key is kept, so the upstream can still be authenticated
+	assert.True(t, u.seeder.UpstreamCredentialsContain(t, id, privateKey))
+}
+
+func (u *UpstreamTestSuite) testUpstreamStateChange(t *testing.T) {
+	id := u.seeder.CreateUpstream(t, "upstream-state", 18012, "https://registry-1.docker.io")
+
+	tcs := []struct {
+		name       string
+		id         string
+		state      string
+		statusCode int
+	}{
+		{name: "Missing state", id: id, state: "", statusCode: http.StatusBadRequest},
+		{name: "Invalid state", id: id, state: "Paused", statusCode: http.StatusBadRequest},
+		{name: "Non existent upstream", id: "non-existent-id", state: constants.ResourceStateDisabled, statusCode: http.StatusNotFound},
+		{name: "Disable upstream", id: id, state: constants.ResourceStateDisabled, statusCode: http.StatusOK},
+		{name: "Activate upstream", id: id, state: constants.ResourceStateActive, statusCode: http.StatusOK},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			endpoint := fmt.Sprintf(testdata.EndpointUpstreamState, tc.id) + "?state=" + url.QueryEscape(tc.state)
+			resp := u.doRequest(t, http.MethodPatch, endpoint, nil, u.seeder.AdminToken(t))
+			defer resp.Body.Close()
+
+			helpers.AssertStatusCode(t, resp, tc.statusCode)
+		})
+	}
+}
+
+func (u *UpstreamTestSuite) testDeleteUpstream(t *testing.T) {
+	id := u.seeder.CreateUpstream(t, "upstream-delete", 18013, "https://registry-1.docker.io")
+
+	tcs := []struct {
+		name       string
+		id         string
+		statusCode int
+	}{
+		{name: "Delete upstream", id: id, statusCode: http.StatusOK},
+		{name: "Delete already deleted upstream", id: id, statusCode: http.StatusNotFound},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := u.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUpstreamByID, tc.id), nil,
+				u.seeder.AdminToken(t))
+			defer resp.Body.Close()
+
+			helpers.AssertStatusCode(t, resp, tc.statusCode)
+		})
+	}
+}
+
+func (u *UpstreamTestSuite) testNonAdminAccess(t *testing.T) {
+	u.seeder.ProvisionUser(t, "upstream-maintainer", "upstream-maintainer@t.com", constants.RoleMaintainer)
+	token := u.seeder.UserToken(t, "upstream-maintainer", constants.RoleMaintainer)
+
+	t.Run("List upstreams", func(t *testing.T) {
+		resp := u.doRequest(t, http.MethodGet, testdata.EndpointUpstreams, nil, token)
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+
+	t.Run("Create upstream", func(t *testing.T) {
+		resp := u.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, upstreamBody("upstream-non-admin", 18014), token)
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusForbidden)
+	})
+}
+
+func (u *UpstreamTestSuite) testListenerLifecycle(t *testing.T) {
+	port := int(helpers.FindFreePort())
+	id := u.seeder.CreateUpstream(t, "upstream-listener", port, "https://registry-1.docker.io")
+
+	listening := func(port int) bool {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/", port))
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}
+
+	require.Eventually(t, func() bool { return listening(port) }, 5*time.Second, 50*time.Millisecond,
+		"listener must be started when upstream is created")
+
+	t.Run("Listener moves to new port", func(t *testing.T) {
+		newPort := int(helpers.FindFreePort())
+		body := upstreamBody("upstream-listener", newPort)
+		body["reg_id"] = id
+		body["auth_config"] = map[string]any{"auth_type": "anonymous"}
+
+		resp := u.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUpstreamByID, id), body, u.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		assert.Eventually(t, func() bool { return listening(newPort) }, 5*time.Second, 50*time.Millisecond)
+		assert.False(t, listening(port), "old port must be closed")
+		port = newPort
+	})
+
+	t.Run("Config change keeps listener running", func(t *testing.T) {
+		resp := u.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUpstreamCacheConfig, id),
+			map[string]any{"enabled": true, "ttl_seconds": 300}, u.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		assert.True(t, listening(port))
+	})
+
+	t.Run("Disabling upstream stops listener", func(t *testing.T) {
+		endpoint := fmt.Sprintf(testdata.EndpointUpstreamState, id) + "?state=" + constants.ResourceStateDisabled
+		resp := u.doRequest(t, http.MethodPatch, endpoint, nil, u.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		assert.False(t, listening(port))
+	})
+
+	t.Run("Enabling upstream starts listener", func(t *testing.T) {
+		endpoint := fmt.Sprintf(testdata.EndpointUpstreamState, id) + "?state=" + constants.ResourceStateActive
+		resp := u.doRequest(t, http.MethodPatch, endpoint, nil, u.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		assert.Eventually(t, func() bool { return listening(port) }, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("Deleting upstream stops listener", func(t *testing.T) {
+		resp := u.doRequest(t, http.MethodDelete, fmt.Sprintf(testdata.EndpointUpstreamByID, id), nil, u.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		assert.False(t, listening(port))
+	})
+}
+
+func (u *UpstreamTestSuite) testCachingOfUpstreamImages(t *testing.T) {
+	layer := []byte("cached layer")
+	layerDigest := digestOf(layer)
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json",`+
+		`"layers":[{"mediaType":"application/vnd.docker.image.rootfs.diff.tar.gzip","digest":%q,"size":%d}]}`,
+		layerDigest, len(layer))
+
+	var mu sync.Mutex
+	fetches := map[string]int{}
+	fakeUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			fetches[r.URL.Path]++
+			mu.Unlock()
+		}
+
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token":"cache-test-token","expires_in":300}`))
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/cache/app/manifests/1.0", "/v2/cache/app/manifests/2.0":
+			w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+			w.Header().Set("Docker-Content-Digest", digestOf([]byte(manifest)))
+			w.Write([]byte(manifest))
+		case "/v2/cache/app/blobs/" + layerDigest:
+			w.Header().Set("Docker-Content-Digest", layerDigest)
+			w.Write(layer)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer fakeUpstream.Close()
+
+	fetchCount := func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return fetches[path]
+	}
+
+	port := int(helpers.FindFreePort())
+	body := upstreamBody("upstream-cache", port)
+	body["upstream_url"] = fakeUpstream.URL
+	body["auth_config"].(map[string]any)["token_endpoint"] = fakeUpstream.URL + "/token"
+
+	resp := u.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, body, u.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	upstreamID := created["reg_id"].(string)
+
+	waitForListener(t, port)
+
+	pull := func(t *testing.T, path string) []byte {
+		t.Helper()
+
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/cache/app/%s", port, path))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		content, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return content
+	}
+
+	t.Run("Namespace and repository are created in the upstream", func(t *testing.T) {
+		assert.Equal(t, manifest, string(pull(t, "manifests/1.0")))
+		assert.NotEmpty(t, u.seeder.RepositoryIDOf(t, upstreamID, "cache", "app"))
+	})
+
+	t.Run("Tags share the cached manifest", func(t *testing.T) {
+		assert.Equal(t, manifest, string(pull(t, "manifests/2.0")))
+		fetched := fetchCount("/v2/cache/app/manifests/1.0") + fetchCount("/v2/cache/app/manifests/2.0")
+
+		assert.Equal(t, manifest, string(pull(t, "manifests/1.0")))
+		assert.Equal(t, manifest, string(pull(t, "manifests/2.0")))
+		assert.Equal(t, fetched, fetchCount("/v2/cache/app/manifests/1.0")+fetchCount("/v2/cache/app/manifests/2.0"),
+			"cached tags must not be fetched again")
+	})
+
+	t.Run("Cached blobs are served from cache", func(t *testing.T) {
+		assert.Equal(t, layer, pull(t, "blobs/"+layerDigest))
+		fetched := fetchCount("/v2/cache/app/blobs/" + layerDigest)
+
+		assert.Equal(t, layer, pull(t, "blobs/"+layerDigest))
+		assert.Equal(t, fetched, fetchCount("/v2/cache/app/blobs/"+layerDigest))
+	})
+}
+
+func (u *UpstreamTestSuite) testHealthCheck(t *testing.T) {
+	fakeUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fakeUpstream.Close()
+
+	port := int(helpers.FindFreePort())
+	id := u.seeder.CreateUpstream(t, "upstream-health", port, fakeUpstream.URL)
+
+	getHealth := func(t *testing.T) map[string]any {
+		resp := u.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamHealth, id), nil, u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var health map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&health))
+		return health
+	}
+
+	t.Run("Non existent upstream", func(t *testing.T) {
+		resp := u.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamHealth, "non-existent-id"), nil,
+			u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("Healthy upstream", func(t *testing.T) {
+		require.Eventually(t, func() bool {
+			return getHealth(t)["state"] == constants.UpstreamHealthHealthy
+		}, 5*time.Second, 100*time.Millisecond)
+
+		health := getHealth(t)
+		assert.Equal(t, constants.CircuitStateClosed, health["circuit_state"])
+		assert.NotNil(t, health["last_checked_at"])
+
+		resp := u.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamByID, id), nil, u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		var upstream map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&upstream))
+		assert.Equal(t, constants.UpstreamHealthHealthy, upstream["health"].(map[string]any)["state"])
+	})
+
+	t.Run("Unreachable upstream opens the circuit", func(t *testing.T) {
+		fakeUpstream.Close()
+
+		require.Eventually(t, func() bool {
+			return getHealth(t)["circuit_state"] == constants.CircuitStateOpen
+		}, 10*time.Second, 100*time.Millisecond)
+
+		health := getHealth(t)
+		assert.Equal(t, constants.UpstreamHealthUnhealthy, health["state"])
+		assert.NotEmpty(t, health["last_error"])
+	})
+
+	t.Run("Requests fail fast while circuit is open", func(t *testing.T) {
+		start := time.Now()
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/library/alpine/manifests/latest", port))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusServiceUnavailable)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("Disabled upstream is not monitored", func(t *testing.T) {
+		endpoint := fmt.Sprintf(testdata.EndpointUpstreamState, id) + "?state=" + constants.ResourceStateDisabled
+		resp := u.doRequest(t, http.MethodPatch, endpoint, nil, u.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		assert.Equal(t, constants.UpstreamHealthUnknown, getHealth(t)["state"])
+	})
+}
+
+func (u *UpstreamTestSuite) createUpstreamWithAccessConfig(t *testing.T, name, upstreamURL string,
+	accessConfig map[string]any) string {
+	t.Helper()
+
+	body := upstreamBody(name, int(helpers.FindFreePort()))
+	body["upstream_url"] = upstreamURL
+	body["access_config"] = accessConfig
+
+	resp := u.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, body, u.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var created map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	return created["reg_id"].(string)
+}
+
+func (u *UpstreamTestSuite) upstreamHealthState(t *testing.T, id string) string {
+	resp := u.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamHealth, id), nil, u.seeder.AdminToken(t))
+	defer resp.Body.Close()
+
+	var health map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&health))
+	return health["state"].(string)
+}
+
+func (u *UpstreamTestSuite) testProxyAndTLS(t *testing.T) {
+	t.Run("Invalid network configs", func(t *testing.T) {
+		tcs := []struct {
+			name         string
+			accessConfig map[string]any
+		}{
+			{"Proxy without url", map[string]any{"proxy_enabled": true}},
+			{"Unsupported proxy scheme", map[string]any{"proxy_enabled": true, "proxy_url": "ftp://proxy:21"}},
+			{"Proxy password without username", map[string]any{"proxy_enabled": true, "proxy_url": "http://proxy:3128",
+				"proxy_password": "secret"}},
+			{"Invalid CA bundle", map[string]any{"tls_ca_bundle": "not a certificate"}},
+			{"Client certificate without key", map[string]any{"tls_client_cert": "-----BEGIN CERTIFICATE-----"}},
+		}
+
+		for _, tc := range tcs {
+			t.Run(tc.name, func(t *testing.T) {
+				body := upstreamBody("upstream-invalid-network", int(helpers.FindFreePort()))
+				body["access_config"] = tc.accessConfig
+
+				resp := u.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, body, u.seeder.AdminToken(t))
+				defer resp.Body.Close()
+
+				helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+			})
+		}
+	})
+
+	t.Run("Upstream is reached through authenticated proxy", func(t *testing.T) {
+		var proxied atomic.Int32
+		expectedAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("egress:proxy-secret"))
+
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Proxy-Authorization") != expectedAuth {
+				w.WriteHeader(http.StatusProxyAuthRequired)
+				return
+			}
+			if r.URL.Host != "upstream.internal.test" {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			proxied.Add(1)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer proxy.Close()
+
+		id := u.createUpstreamWithAccessConfig(t, "upstream-proxy", "http://upstream.internal.test", map[string]any{
+			"proxy_enabled":  true,
+			"proxy_url":      proxy.URL,
+			"proxy_username": "egress",
+			"proxy_password": "proxy-secret",
+		})
+
+		require.Eventually(t, func() bool {
+			return u.upstreamHealthState(t, id) == constants.UpstreamHealthHealthy
+		}, 5*time.Second, 100*time.Millisecond)
+		assert.Positive(t, proxied.Load())
+
+		resp := u.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamByID, id), nil, u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		var upstream map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&upstream))
+		accessConfig := upstream["access_config"].(map[string]any)
+		assert.Equal(t, true, accessConfig["proxy_enabled"])
+		assert.Equal(t, "egress", accessConfig["proxy_username"])
+		assert.Equal(t, "********", accessConfig["proxy_password"])
+
+		// masked password is replaced with the persisted one
+		before := proxied.Load()
+		resp = u.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUpstreamNetworkConfig, id), accessConfig,
+			u.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		assert.Eventually(t, func() bool { return proxied.Load() > before }, 5*time.Second, 100*time.Millisecond)
+		assert.Equal(t, constants.UpstreamHealthHealthy, u.upstreamHealthState(t, id))
+
+		// masked password is not sent to another proxy
+		accessConfig["proxy_url"] = "http://proxy.attacker.test:3128"
+		resp = u.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUpstreamNetworkConfig, id), accessConfig,
+			u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusBadRequest)
+
+		var res map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		assert.Contains(t, res["error_message"], "proxy_url")
+	})
+
+	t.Run("Upstream with private CA", func(t *testing.T) {
+		tlsUpstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer tlsUpstream.Close()
+
+		id := u.createUpstreamWithAccessConfig(t, "upstream-private-ca", tlsUpstream.URL, map[string]any{})
+
+		require.Eventually(t, func() bool {
+			return u.upstreamHealthState(t, id) == constants.UpstreamHealthUnhealthy
+		}, 5*time.Second, 100*time.Millisecond, "certificate signed by unknown authority must not be trusted")
+
+		caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsUpstream.Certificate().Raw})
+		resp := u.doRequest(t, http.MethodPut, fmt.Sprintf(testdata.EndpointUpstreamNetworkConfig, id),
+			map[string]any{"tls_ca_bundle": string(caBundle)}, u.seeder.AdminToken(t))
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		assert.Eventually(t, func() bool {
+			return u.upstreamHealthState(t, id) == constants.UpstreamHealthHealthy
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("Insecure skip verify", func(t *testing.T) {
+		tlsUpstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer tlsUpstream.Close()
+
+		id := u.createUpstreamWithAccessConfig(t, "upstream-insecure", tlsUpstream.URL, map[string]any{
+			"tls_insecure_skip_verify": true,
+		})
+
+		assert.Eventually(t, func() bool {
+			return u.upstreamHealthState(t, id) == constants.UpstreamHealthHealthy
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+}
+
+func (u *UpstreamTestSuite) testCacheTTLRules(t *testing.T) {
+	var mu sync.Mutex
+	manifests := map[string]string{
+		"latest": `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[]}`,
+		"1.0":    `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"v":"1.0"}}`,
+	}
+	requests := make(map[string]int)
+
+	fakeUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/token":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token":"ttl-test-token","expires_in":300}`))
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case strings.HasPrefix(r.URL.Path, "/v2/team/app/manifests/"):
+			reference := strings.TrimPrefix(r.URL.Path, "/v2/team/app/manifests/")
+			requests[r.Method+" "+reference]++
+
+			manifest, ok := manifests[reference]
+			for _, m := range manifests {
+				if digestOf([]byte(m)) == reference {
+					manifest, ok = m, true
+				}
+			}
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", digestOf([]byte(manifest)))
+			if r.Method == http.MethodHead {
+				return
+			}
+			w.Write([]byte(manifest))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer fakeUpstream.Close()
+
+	requestCount := func(method, reference string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests[method+" "+reference]
+	}
+
+	port := int(helpers.FindFreePort())
+	body := upstreamBody("upstream-ttl-rules", port)
+	body["upstream_url"] = fakeUpstream.URL
+	body["auth_config"].(map[string]any)["token_endpoint"] = fakeUpstream.URL + "/token"
+	body["cache_config"] = map[string]any{
+		"enabled":     true,
+		"ttl_seconds": 600,
+		"ttl_rules":   []map[string]any{{"tag_pattern": "latest", "ttl_seconds": 0}},
+	}
+
+	resp := u.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, body, u.seeder.AdminToken(t))
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	waitForListener(t, port)
+
+	pull := func(t *testing.T, reference string) string {
+		t.Helper()
+
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/team/app/manifests/%s", port, reference))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+		content, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	t.Run("Expired tag is revalidated with HEAD", func(t *testing.T) {
+		pull(t, "latest")
+		assert.Equal(t, manifests["latest"], pull(t, "latest"))
+
+		assert.Equal(t, 1, requestCount(http.MethodGet, "latest"))
+		assert.Equal(t, 1, requestCount(http.MethodHead, "latest"))
+	})
+
+	t.Run("Changed tag is downloaded again", func(t *testing.T) {
+		changed := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"v":"2"}}`
+		mu.Lock()
+		manifests["latest"] = changed
+		mu.Unlock()
+
+		assert.Equal(t, changed, pull(t, "latest"))
+		assert.Equal(t, 2, requestCount(http.MethodGet, "latest"))
+		assert.Equal(t, 2, requestCount(http.MethodHead, "latest"))
+	})
+
+	t.Run("Tag without rule uses TTL of upstream", func(t *testing.T) {
+		pull(t, "1.0")
+		assert.Equal(t, manifests["1.0"], pull(t, "1.0"))
+
+		assert.Equal(t, 1, requestCount(http.MethodGet, "1.0"))
+		assert.Zero(t, requestCount(http.MethodHead, "1.0"))
+	})
+
+	t.Run("Digest is cached indefinitely", func(t *testing.T) {
+		digest := digestOf([]byte(manifests["1.0"]))
+
+		pull(t, digest)
+		assert.Equal(t, manifests["1.0"], pull(t, digest))
+
+		assert.Equal(t, 1, requestCount(http.MethodGet, digest))
+		assert.Zero(t, requestCount(http.MethodHead, digest))
+	})
+}
+
+func (u *UpstreamTestSuite) testRateLimits(t *testing.T) {
+	var mu sync.Mutex
+	manifests := map[string]string{
+		"latest": `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[]}`,
+		"1.0":    `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"v":"1.0"}}`,
+	}
+	requests := make(map[string]int)
+	remaining := 50
+	limited := false
+
+	fakeUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/token":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token":"rate-limit-test-token","expires_in":300}`))
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case strings.HasPrefix(r.URL.Path, "/v2/team/app/manifests/"):
+			reference := strings.TrimPrefix(r.URL.Path, "/v2/team/app/manifests/")
+			requests[r.Method+" "+reference]++
+
+			w.Header().Set("RateLimit-Limit", "100;w=21600")
+			w.Header().Set("RateLimit-Remaining", fmt.Sprintf("%d;w=21600", remaining))
+			if limited && r.Method == http.MethodGet {
+				w.Header().Set("Retry-After", "120")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+
+			manifest, ok := manifests[reference]
+			for _, m := range manifests {
+				if digestOf([]byte(m)) == reference {
+					manifest, ok = m, true
+				}
+			}
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", digestOf([]byte(manifest)))
+			if r.Method == http.MethodHead {
+				return
+			}
+			w.Write([]byte(manifest))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer fakeUpstream.Close()
+
+	requestCount := func(method, reference string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests[method+" "+reference]
+	}
+
+	port := int(helpers.FindFreePort())
+	body := upstreamBody("upstream-rate-limits", port)
+	body["upstream_url"] = fakeUpstream.URL
+	body["auth_config"].(map[string]any)["token_endpoint"] = fakeUpstream.URL + "/token"
+	body["cache_config"] = map[string]any{
+		"enabled":     true,
+		"ttl_seconds": 600,
+		"ttl_rules":   []map[string]any{{"tag_pattern": "latest", "ttl_seconds": 0}},
+	}
+
+	resp := u.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, body, u.seeder.AdminToken(t))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var created map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	id := created["reg_id"].(string)
+	waitForListener(t, port)
+
+	pull := func(t *testing.T, reference string) *http.Response {
+		t.Helper()
+
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/team/app/manifests/%s", port, reference))
+		require.NoError(t, err)
+		return resp
+	}
+
+	pullContent := func(t *testing.T, reference string) string {
+		t.Helper()
+
+		resp := pull(t, reference)
+		defer resp.Body.Close()
+
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+		content, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	rateLimit := func(t *testing.T) map[string]any {
+		t.Helper()
+
+		resp := u.doRequest(t, http.MethodGet, fmt.Sprintf(testdata.EndpointUpstreamHealth, id), nil,
+			u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var health map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&health))
+		require.Contains(t, health, "rate_limit")
+		return health["rate_limit"].(map[string]any)
+	}
+
+	t.Run("Quota is reported in health and metrics", func(t *testing.T) {
+		pullContent(t, "latest")
+
+		quota := rateLimit(t)
+		assert.EqualValues(t, 100, quota["limit"])
+		assert.EqualValues(t, 50, quota["remaining"])
+		assert.EqualValues(t, 21600, quota["window_seconds"])
+		assert.Equal(t, false, quota["low"])
+		assert.Equal(t, false, quota["limited"])
+
+		resp, err := http.Get(u.testBaseURL + testdata.EndpointMetrics)
+		require.NoError(t, err)
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusUnauthorized)
+
+		resp = u.doRequest(t, http.MethodGet, testdata.EndpointMetrics, nil, u.seeder.AdminToken(t))
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusOK)
+
+		metrics, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(metrics), fmt.Sprintf(`oir_upstream_rate_limit_remaining{upstream_id="%s"} 50`, id))
+		assert.Contains(t, string(metrics), fmt.Sprintf(`oir_upstream_rate_limit_limit{upstream_id="%s"} 100`, id))
+	})
+
+	t.Run("Changed tag is served from cached digest when quota is low", func(t *testing.T) {
+		digest := digestOf([]byte(manifests["1.0"]))
+		pullContent(t, digest)
+
+		mu.Lock()
+		remaining = 5
+		mu.Unlock()
+
+		// revalidation records the low quota
+		pullContent(t, "latest")
+		assert.Equal(t, true, rateLimit(t)["low"])
+
+		mu.Lock()
+		manifests["latest"] = manifests["1.0"]
+		mu.Unlock()
+
+		assert.Equal(t, manifests["1.0"], pullContent(t, "latest"))
+		assert.Equal(t, 1, requestCount(http.MethodGet, "latest"))
+		assert.Equal(t, 2, requestCount(http.MethodHead, "latest"))
+	})
+
+	t.Run("Uncached tag is resolved with HEAD when quota is low", func(t *testing.T) {
+		mu.Lock()
+		manifests["stable"] = manifests["1.0"]
+		mu.Unlock()
+
+		assert.Equal(t, manifests["1.0"], pullContent(t, "stable"))
+		assert.Zero(t, requestCount(http.MethodGet, "stable"))
+		assert.Equal(t, 1, requestCount(http.MethodHead, "stable"))
+	})
+
+	t.Run("Rate limited upstream is not retried until Retry-After", func(t *testing.T) {
+		mu.Lock()
+		remaining = 0
+		limited = true
+		manifests["2.0"] = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"v":"2.0"}}`
+		mu.Unlock()
+
+		resp := pull(t, "2.0")
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusTooManyRequests)
+		assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+		resp = pull(t, "2.0")
+		resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, http.StatusTooManyRequests)
+		assert.Equal(t, 1, requestCount(http.MethodGet, "2.0"))
+
+		quota := rateLimit(t)
+		assert.Equal(t, true, quota["limited"])
+		assert.EqualValues(t, 0, quota["remaining"])
+		assert.NotNil(t, quota["retry_at"])
+
+		// expired manifests are served without revalidation
+		heads := requestCount(http.MethodHead, "latest")
+		assert.Equal(t, manifests["1.0"], pullContent(t, "latest"))
+		assert.Equal(t, heads, requestCount(http.MethodHead, "latest"))
+	})
+}
+
+func (u *UpstreamTestSuite) testUpstreamErrors(t *testing.T) {
+	manifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[]}`
+
+	fakeUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if strings.Contains(r.URL.Query().Get("scope"), "team/private") {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token":"errors-test-token","expires_in":300}`))
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/v2/team/app/manifests/latest":
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Write([]byte(manifest))
+		case strings.HasPrefix(r.URL.Path, "/v2/team/teapot/"):
+			w.WriteHeader(http.StatusTeapot)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer fakeUpstream.Close()
+
+	port := int(helpers.FindFreePort())
+	body := upstreamBody("upstream-errors", port)
+	body["upstream_url"] = fakeUpstream.URL
+	body["auth_config"].(map[string]any)["token_endpoint"] = fakeUpstream.URL + "/token"
+
+	resp := u.doRequest(t, http.MethodPost, testdata.EndpointUpstreams, body, u.seeder.AdminToken(t))
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	waitForListener(t, port)
+
+	pullError := func(t *testing.T, path string, statusCode int) (code, message string) {
+		t.Helper()
+
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v2/%s", port, path))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		helpers.AssertStatusCode(t, resp, statusCode)
+
+		var errResp struct {
+			Errors []struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+		require.Len(t, errResp.Errors, 1)
+		return errResp.Errors[0].Code, errResp.Errors[0].Message
+	}
+
+	t.Run("Missing blob", func(t *testing.T) {
+		code, _ := pullError(t, "team/app/blobs/sha256:"+strings.Repeat("0", 64), http.StatusNotFound)
+		assert.Equal(t, "BLOB_UNKNOWN", code)
+	})
+
+	t.Run("Missing manifest", func(t *testing.T) {
+		code, _ := pullError(t, "team/app/manifests/missing", http.StatusNotFound)
+		assert.Equal(t, "MANIFEST_UNKNOWN", code)
+	})
+
+	t.Run("Credentials rejected by upstream", func(t *testing.T) {
+		code, message := pullError(t, "team/private/manifests/latest", http.StatusBadGateway)
+		assert.Equal(t, "UNAVAILABLE", code)
+		assert.Contains(t, message, "credentials")
+	})
+
+	t.Run("Unexpected response of upstream", func(t *testing.T) {
+		code, message := pullError(t, "team/teapot/manifests/latest", http.StatusBadGateway)
+		assert.Equal(t, "UNAVAILABLE", code)
+		assert.Contains(t, message, "invalid response")
+	})
+}
@@ -100,12 +100,19 @@ const (
 // Endpoints
 const (
 	// Authentication
-	EndpointLogin  = "/api/v1/auth/login"
-	EndpointLogout = "/api/v1/auth/logout"
+	EndpointLogin        = "/api/v1/auth/login"
+	EndpointLogout       = "/api/v1/auth/logout"
+	EndpointRefresh      = "/api/v1/auth/refresh"
+	EndpointOIDCLogin    = "/api/v1/auth/oidc/login"
+	EndpointOIDCCallback = "/api/v1/auth/oidc/callback"
 
 	// User Management (Base)
-	EndpointUsers       = "/api/v1/users"
-	EndpointCurrentUser = "/api/v1/users/me"
+	EndpointUsers        = "/api/v1/users"
+	EndpointCurrentUser  = "/api/v1/users/me"
+	EndpointAccessTokens = "/api/v1/users/me/tokens"
+	EndpointAccessToken  = "/api/v1/users/me/tokens/%s"
+	EndpointSessions     = "/api/v1/users/me/sessions"
+	EndpointSession      = "/api/v1/users/me/sessions/%s"
 
 	// User Management ID Specific
 	EndpointUserByID        = "/api/v1/users/%s"
@@ -115,17 +122,46 @@ const (
 	EndpointUserLock        = "/api/v1/users/%s/lock"
 	EndpointUserUnlock      = "/api/v1/users/%s/unlock"
 	EndpointUserChangeRole  = "/api/v1/users/%s/role"
+	EndpointUserSessions    = "/api/v1/users/%s/sessions"
+
+	// Two-Factor Authentication
+	EndpointLoginTwoFactor         = "/api/v1/auth/login/2fa"
+	EndpointLoginTwoFactorEnroll   = "/api/v1/auth/login/2fa/enroll"
+	EndpointTwoFactor              = "/api/v1/users/me/2fa"
+	EndpointTwoFactorConfirm       = "/api/v1/users/me/2fa/confirm"
+	EndpointTwoFactorRecoveryCodes = "/api/v1/users/me/2fa/recovery-codes"
+	EndpointUserTwoFactor          = "/api/v1/users/%s/2fa"
+	EndpointRoles                  = "/api/v1/users/roles"
+	EndpointRoleByName             = "/api/v1/users/roles/%s"
 
 	// Account Setup/Validation
 	EndpointValidateUser         = "/api/v1/users/validate"
 	EndpointAccountSetupInfo     = "/api/v1/onboarding/%s"
 	EndpointAccountSetupComplete = "/api/v1/onboarding/%s/complete"
 
+	// Password Reset
+	EndpointForgotPassword = "/api/v1/forgot-password"
+	EndpointResetPassword  = "/api/v1/forgot-password/reset"
+
+	// Signing Keys
+	EndpointSigningKeys        = "/api/v1/auth/keys"
+	EndpointRotateSigningKey   = "/api/v1/auth/keys/rotate"
+	EndpointActivateSigningKey = "/api/v1/auth/keys/%s/activate"
+	EndpointJWKS               = "/.well-known/jwks.json"
+
+	// Machine account endpoints
+	EndpointMachines      = "/api/v1/machines"
+	EndpointMachineByID   = "/api/v1/machines/%s"
+	EndpointMachineSecret = "/api/v1/machines/%s/secret"
+
 	// Resource Management
-	EndpointResourceBase = "/api/v1/resource"
-	EndpointNamespaces   = "/api/v1/resource/namespaces"
-	EndpointRepositories = "/api/v1/resource/repositories"
-	EndpointUpstreams    = "/api/v1/resource/upstreams"
+	EndpointResourceBase      = "/api/v1/resource"
+	EndpointNamespaces        = "/api/v1/resource/namespaces"
+	EndpointRepositories      = "/api/v1/resource/repositories"
+	EndpointUpstreams         = "/api/v1/resource/upstreams"
+	EndpointGroups            = "/api/v1/resource/groups"
+	EndpointReplicationRules  = "/api/v1/resource/replications/rules"
+	EndpointReplicationStatus = "/api/v1/resource/replications/status"
 
 	// Namespace ID Specific
 	EndpointNamespaceByID       = "/api/v1/resource/namespaces/%s"
@@ -141,5 +177,36 @@ const (
 	EndpointRepositoryUsers      = "/api/v1/resource/repositories/%s/users"
 	EndpointRepositoryUserRevoke = "/api/v1/resource/repositories/%s/users/%s"
 
+	// Upstream ID Specific
+	EndpointUpstreamByID          = "/api/v1/resource/upstreams/%s"
+	EndpointUpstreamState         = "/api/v1/resource/upstreams/%s/state"
+	EndpointUpstreamAuthConfig    = "/api/v1/resource/upstreams/%s/auth-config"
+	EndpointUpstreamCacheConfig   = "/api/v1/resource/upstreams/%s/cache-config"
+	EndpointUpstreamNetworkConfig = "/api/v1/resource/upstreams/%s/network-config"
+	EndpointUpstreamStorageConfig = "/api/v1/resource/upstreams/%s/storage-config"
+	EndpointUpstreamUsers         = "/api/v1/resource/upstreams/%s/users"
+	EndpointUpstreamHealth        = "/api/v1/resource/upstreams/%s/health"
+	EndpointUpstreamSyncJobs      = "/api/v1/resource/upstreams/%s/sync-jobs"
+	EndpointUpstreamSyncJobByID   = "/api/v1/resource/upstreams/%s/sync-jobs/%s" // UpstreamID, JobID
+	EndpointUpstreamSyncJobRun    = "/api/v1/resource/upstreams/%s/sync-jobs/%s/run"
+	EndpointUpstreamNotFoundCache = "/api/v1/resource/upstreams/%s/cache/not-found"
+	EndpointUpstreamCache         = "/api/v1/resource/upstreams/%s/cache"
+	EndpointUpstreamCachedRepos   = "/api/v1/resource/upstreams/%s/cache/repositories"
+	EndpointUpstreamCachedRepo    = "/api/v1/resource/upstreams/%s/cache/repositories/%s"         // UpstreamID, RepositoryID
+	EndpointUpstreamCacheEntries  = "/api/v1/resource/upstreams/%s/cache/repositories/%s/entries" // UpstreamID, RepositoryID
+	EndpointUpstreamCachedBlobs   = "/api/v1/resource/upstreams/%s/cache/repositories/%s/blobs"   // UpstreamID, RepositoryID
+	EndpointUpstreamCachedTag     = "/api/v1/resource/upstreams/%s/cache/repositories/%s/tags/%s" // UpstreamID, RepositoryID, Tag
+
+	// Group registry ID Specific
+	EndpointGroupByID    = "/api/v1/resource/groups/%s"
+	EndpointGroupState   = "/api/v1/resource/groups/%s/state"
+	EndpointGroupMembers = "/api/v1/resource/groups/%s/members"
+
+	// Replication rule ID Specific
+	EndpointReplicationRuleByID   = "/api/v1/resource/replications/rules/%s"
+	EndpointReplicationRuleRun    = "/api/v1/resource/replications/rules/%s/run"
+	EndpointReplicationRuleStatus = "/api/v1/resource/replications/rules/%s/status"
+
 	EndpointHealthCheck = "/api/v1/health"
-)
\ No newline at end of file
+	EndpointMetrics     = "/api/v1/metrics"
+)
@@ -1,5 +1,7 @@
 package mgmt
 
+import "time"
+
 type UserProfileInfo struct {
 	UserId   string `json:"user_id"`
 	Username string `json:"username"`
@@ -8,6 +10,10 @@ type UserProfileInfo struct {
 
 type AuthLoginResponse struct {
 	User UserProfileInfo `json:"user"`
+	// TwoFactor is set instead of the session cookies if the login needs a second factor
+	TwoFactor *TwoFactorChallengeDTO `json:"two_factor,omitempty"`
+	// RecoveryCodes are returned only once, when two-factor authentication is enrolled during login
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 	// TODO: later, We may send additional information such as resources the user has access 
 }
 
@@ -15,4 +21,53 @@ type AuthLoginRequest struct {
 	Username string   `json:"username"`
 	Password string   `json:"password"`
 	Scopes   []string `json:"scopes"`
-}
\ No newline at end of file
+}
+
+type TwoFactorChallengeDTO struct {
+	// Token identifies the login in POST /api/v1/auth/login/2fa
+	Token string `json:"token"`
+	// EnrollmentRequired is true if the role requires two-factor authentication but the user hasn't enrolled yet
+	EnrollmentRequired bool `json:"enrollment_required"`
+	ExpiresIn          int  `json:"expires_in"`
+}
+
+// TwoFactorLoginRequest completes a login with either a TOTP code or a recovery code
+type TwoFactorLoginRequest struct {
+	Token        string `json:"token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TwoFactorLoginEnrollRequest struct {
+	Token string `json:"token"`
+}
+
+// SigningKeyDTO is a key which signs or verifies auth tokens. Private keys are never returned.
+type SigningKeyDTO struct {
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"algorithm"`
+	// Status is either active or retired
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+	// ExpiresAt is when a retired key stops verifying tokens
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Curve     string `json:"crv,omitempty"` // EC and OKP keys
+	X         string `json:"x,omitempty"`   // EC and OKP keys
+	Y         string `json:"y,omitempty"`   // EC keys
+	N         string `json:"n,omitempty"`   // RSA keys
+	E         string `json:"e,omitempty"`   // RSA keys
+}
+
+// JWKSet is the response of /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
@@ -0,0 +1,55 @@
+package mgmt
+
+import "time"
+
+type CreateGroupRegistryRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Port        int    `json:"port"`
+	Status      string `json:"status,omitempty"`
+	// Members are IDs of hosted registry and upstream registries. Images are resolved through members
+	// in the given order.
+	Members []string `json:"members"`
+	// PushMemberID is the member which receives pushes sent to the group. Only hosted registry is allowed.
+	// The group is read-only if it is empty.
+	PushMemberID string `json:"push_member_id,omitempty"`
+}
+
+type CreateGroupRegistryResponse struct {
+	GroupId   string `json:"group_id"`
+	GroupName string `json:"group_name"`
+}
+
+type UpdateGroupRegistryRequest struct {
+	GroupId string `json:"group_id"`
+	CreateGroupRegistryRequest
+}
+
+type UpdateGroupMembersRequest struct {
+	Members []string `json:"members"`
+}
+
+type GroupRegistryMemberDTO struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Type is `Hosted` or `Upstream`.
+	Type     string `json:"type"`
+	State    string `json:"state,omitempty"`
+	Position int    `json:"position"`
+}
+
+type GroupRegistrySummaryDTO struct {
+	Id           string     `json:"id"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	Port         int        `json:"port"`
+	Status       string     `json:"status"`
+	PushMemberID string     `json:"push_member_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    *time.Time `json:"updated_at"`
+}
+
+type GroupRegistryResponse struct {
+	GroupRegistrySummaryDTO
+	Members []*GroupRegistryMemberDTO `json:"members"`
+}
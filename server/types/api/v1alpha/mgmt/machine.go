@@ -0,0 +1,46 @@
+package mgmt
+
+import "time"
+
+type MachineAccessDTO struct {
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+	AccessLevel  string `json:"access_level"`
+}
+
+type CreateMachineAccountRequest struct {
+	Username    string              `json:"username"`
+	DisplayName string              `json:"display_name"`
+	Access      []*MachineAccessDTO `json:"access"`
+	// secret never expires if this is 0
+	SecretExpiresInDays int `json:"secret_expires_in_days"`
+}
+
+type RotateMachineSecretRequest struct {
+	// secret never expires if this is 0
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+type MachineSecretDTO struct {
+	Id          string     `json:"id"`
+	TokenPrefix string     `json:"token_prefix"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	// Secret is only returned when the secret is generated
+	Secret string `json:"secret,omitempty"`
+}
+
+type MachineAccountDTO struct {
+	Id          string                   `json:"id"`
+	Username    string                   `json:"username"`
+	DisplayName string                   `json:"display_name"`
+	Locked      bool                     `json:"locked"`
+	CreatedAt   time.Time                `json:"created_at"`
+	Access      []*ResourceAccessViewDTO `json:"access"`
+	Secret      *MachineSecretDTO        `json:"secret"`
+}
+
+type ListMachineAccountsResponse struct {
+	Machines []*MachineAccountDTO `json:"machines"`
+}
@@ -0,0 +1,79 @@
+package mgmt
+
+import "time"
+
+type CreateReplicationRuleRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// TargetURL is the base URL of the remote OCI registry. e.g. https://dr-registry.example.com
+	TargetURL string `json:"target_url"`
+	Username  string `json:"username,omitempty"`
+	// Password is not returned in responses. It is kept unchanged on updates if it is empty.
+	Password string `json:"password,omitempty"`
+	// Filters are comma separated glob patterns. e.g. `team-*,platform`. Empty filter selects all.
+	NamespaceFilter  string `json:"namespace_filter,omitempty"`
+	RepositoryFilter string `json:"repository_filter,omitempty"`
+	TagFilter        string `json:"tag_filter,omitempty"`
+	// Trigger is `push` or `scheduled`.
+	Trigger                 string `json:"trigger"`
+	ScheduleIntervalSeconds int    `json:"schedule_interval_seconds,omitempty"`
+	// Enabled defaults to true.
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+type CreateReplicationRuleResponse struct {
+	RuleId   string `json:"rule_id"`
+	RuleName string `json:"rule_name"`
+}
+
+type UpdateReplicationRuleRequest struct {
+	RuleId string `json:"rule_id"`
+	CreateReplicationRuleRequest
+}
+
+type ReplicationRuleDTO struct {
+	Id                      string     `json:"id"`
+	Name                    string     `json:"name"`
+	Description             string     `json:"description"`
+	TargetURL               string     `json:"target_url"`
+	Username                string     `json:"username"`
+	NamespaceFilter         string     `json:"namespace_filter"`
+	RepositoryFilter        string     `json:"repository_filter"`
+	TagFilter               string     `json:"tag_filter"`
+	Trigger                 string     `json:"trigger"`
+	ScheduleIntervalSeconds int        `json:"schedule_interval_seconds"`
+	Enabled                 bool       `json:"enabled"`
+	LastScheduledAt         *time.Time `json:"last_scheduled_at"`
+	CreatedAt               time.Time  `json:"created_at"`
+	UpdatedAt               *time.Time `json:"updated_at"`
+}
+
+type RunReplicationRuleResponse struct {
+	QueuedImages int `json:"queued_images"`
+}
+
+type ReplicationFailureDTO struct {
+	Namespace  string     `json:"namespace"`
+	Repository string     `json:"repository"`
+	Tag        string     `json:"tag"`
+	Attempts   int        `json:"attempts"`
+	LastError  string     `json:"last_error"`
+	FailedAt   *time.Time `json:"failed_at"`
+}
+
+type ReplicationRuleStatusDTO struct {
+	RuleId   string `json:"rule_id"`
+	RuleName string `json:"rule_name"`
+	Pending  int    `json:"pending"`
+	Running  int    `json:"running"`
+	// Succeeded and Failed count tasks which are completed.
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// LagSeconds is the age of the oldest image which is waiting to be replicated. It is 0 when the
+	// target is in sync.
+	LagSeconds     int64                    `json:"lag_seconds"`
+	LastSuccessAt  *time.Time               `json:"last_success_at"`
+	LastFailureAt  *time.Time               `json:"last_failure_at"`
+	LastError      string                   `json:"last_error"`
+	RecentFailures []*ReplicationFailureDTO `json:"recent_failures"`
+}
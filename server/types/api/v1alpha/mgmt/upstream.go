@@ -3,25 +3,39 @@ package mgmt
 import "time"
 
 type UpstreamAuthConfigDTO struct {
-	// AuthType defines authentication methods. Possible values: `anonymous`, `basic`, `bearer`
+	// AuthType defines authentication methods. Possible values: `anonymous`, `basic`, `bearer`,
+	// `aws_ecr`, `gcp_service_account`, `azure_service_principal`
 	AuthType string `json:"auth_type"`
 	// CredentialJson contains required data for the defined `AuthType`. eg:
 	// 1. { grant_type:'client-credentials', basic_auth_header: ''}
 	// 2. { username: 'admin', password: 'admin }
 	// 3. {api_key: 'value' }
+	// 4. { region: 'us-east-1', access_key_id: '', secret_access_key: '' } for `aws_ecr`
+	// 5. JSON key file of the service account for `gcp_service_account`
+	// 6. { tenant_id: '', client_id: '', client_secret: '' } for `azure_service_principal`
+	// Secrets are masked in responses.
 	CredentialJson map[string]interface{} `json:"credentials_json,omitempty"`
 	// If `AuthType` is `oauth2`, the `TokenEndpoint` defines url to get the token.
 	TokenEndpoint string `json:"token_endpoint"`
 }
 
 type UpstreamAccessConfigDTO struct {
-	ProxyEnabled               bool   `json:"proxy_enabled"`
-	ProxyUrl                   string `json:"proxy_url,omitempty"`
-	ConnectionTimeoutInSeconds int    `json:"connection_timeout"`
-	ReadTimeoutInSeconds       int    `json:"read_timeout"`
-	MaxConnections             int    `json:"max_connections"`
-	MaxRetries                 int    `json:"max_retries"`
-	RetryDelayInSeconds        int    `json:"retry_delay"`
+	ProxyEnabled               bool    `json:"proxy_enabled"`
+	ProxyUrl                   string  `json:"proxy_url,omitempty"`
+	ConnectionTimeoutInSeconds int     `json:"connection_timeout"`
+	ReadTimeoutInSeconds       int     `json:"read_timeout"`
+	WriteTimeoutInSeconds      int     `json:"write_timeout"`
+	MaxConnections             int     `json:"max_connections"`
+	MaxIdleConnections         int     `json:"max_idle_connections"`
+	MaxRetries                 int     `json:"max_retries"`
+	RetryDelayInSeconds        int     `json:"retry_delay"`
+	RetryBackoffMultiplier     float32 `json:"retry_backoff_multiplier"`
+	ProxyUsername              string  `json:"proxy_username,omitempty"`
+	ProxyPassword              string  `json:"proxy_password,omitempty"`
+	TLSCABundle                string  `json:"tls_ca_bundle,omitempty"`
+	TLSClientCert              string  `json:"tls_client_cert,omitempty"`
+	TLSClientKey               string  `json:"tls_client_key,omitempty"`
+	TLSInsecureSkipVerify      bool    `json:"tls_insecure_skip_verify"`
 }
 
 type UpstreamStorageConfigDTO struct {
@@ -34,10 +48,23 @@ type UpstreamCacheConfigDTO struct {
 	Enabled      bool `json:"enabled"`
 	TtlInSeconds int  `json:"ttl_seconds"`
 	OfflineMode  bool `json:"offline_mode"`
+	// TtlRules override TtlInSeconds for tags which match their patterns. First matching rule is applied.
+	// Manifests pulled by digest never expire.
+	TtlRules []UpstreamCacheTTLRuleDTO `json:"ttl_rules"`
+	// NegativeTtlInSeconds is how long manifests which upstream reported as not found are answered with
+	// MANIFEST_UNKNOWN without asking upstream again. Defaults to 60 seconds. 0 disables negative caching.
+	NegativeTtlInSeconds *int `json:"negative_ttl_seconds"`
+}
+
+type UpstreamCacheTTLRuleDTO struct {
+	TagPattern   string `json:"tag_pattern"`
+	TtlInSeconds int    `json:"ttl_seconds"`
 }
 
 type CreateUpstreamRegistryRequest struct {
 	Name        string `json:"name"`
+	Description string `json:"description"`
+	Vendor      string `json:"vendor"`
 	Port        int    `json:"port"`
 	Status      string `json:"status,omitempty"`
 	UpstreamUrl string `json:"upstream_url"`
@@ -59,12 +86,17 @@ type UpdateUpstreamRegistryRequest struct {
 }
 
 type UpstreamRegistrySummaryDTO struct {
-	Id                string `json:"id"`
-	Name              string `json:"name"`
-	Port              int    `json:"port"`
-	Status            string `json:"status,omitempty"`
-	UpstreamUrl       string `json:"upstream_url"`
-	CachedImagesCount int    `json:"cached_images_count"`
+	Id                string     `json:"id"`
+	Name              string     `json:"name"`
+	Description       string     `json:"description"`
+	Vendor            string     `json:"vendor"`
+	Port              int        `json:"port"`
+	Status            string     `json:"status,omitempty"`
+	UpstreamUrl       string     `json:"upstream_url"`
+	CachedImagesCount int        `json:"cached_images_count"`
+	HealthState       string     `json:"health_state"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         *time.Time `json:"updated_at"`
 }
 
 type ListUpstreamsResponse struct {
@@ -94,13 +126,15 @@ type UpstreamStorageConfigResponse struct {
 
 type UpstreamCacheConfigResponse struct {
 	UpstreamCacheConfigDTO
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
 }
 
 type UpstreamRegistryResponse struct {
 	Id            string                        `json:"id"`
 	Name          string                        `json:"name"`
+	Description   string                        `json:"description"`
+	Vendor        string                        `json:"vendor"`
 	Port          int                           `json:"port"`
 	Status        string                        `json:"status,omitempty"`
 	UpstreamUrl   string                        `json:"upstream_url"`
@@ -110,4 +144,114 @@ type UpstreamRegistryResponse struct {
 	AccessConfig  UpstreamAccessConfigResponse  `json:"access_config"`
 	StorageConfig UpstreamStorageConfigResponse `json:"storage_config"`
 	CacheConfig   UpstreamCacheConfigResponse   `json:"cache_config"`
-}
\ No newline at end of file
+	Health        UpstreamHealthDTO             `json:"health"`
+}
+
+type UpstreamHealthDTO struct {
+	State               string     `json:"state"`
+	CircuitState        string     `json:"circuit_state,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LatencyInMs         int64      `json:"latency_ms"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastCheckedAt       *time.Time `json:"last_checked_at"`
+	LastHealthyAt       *time.Time `json:"last_healthy_at"`
+	// RateLimit is omitted until the upstream reports its quota or rejects requests with 429.
+	RateLimit *UpstreamRateLimitDTO `json:"rate_limit,omitempty"`
+}
+
+type UpstreamRateLimitDTO struct {
+	Limit           int   `json:"limit"`
+	Remaining       int   `json:"remaining"`
+	WindowInSeconds int64 `json:"window_seconds"`
+	// Low is true when remaining quota is at or below the threshold. Cached manifests are preferred then.
+	Low bool `json:"low"`
+	// Limited is true while requests are held back until RetryAt due to 429 responses.
+	Limited   bool       `json:"limited"`
+	RetryAt   *time.Time `json:"retry_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+type CreateSyncJobRequest struct {
+	Name string `json:"name"`
+	// Repositories are names like `library/alpine`. Names without a namespace belong to `library`.
+	Repositories []string `json:"repositories"`
+	// TagPatterns are glob patterns which are matched against tags of the upstream. e.g. `3.*`, `latest`
+	TagPatterns []string `json:"tag_patterns"`
+	// Platforms select manifests of multi-arch images. e.g. `linux/amd64`, `linux/arm/v7`. Empty selects all.
+	Platforms []string `json:"platforms,omitempty"`
+	// ScheduleIntervalSeconds runs the job periodically. 0 means the job is run on demand only.
+	ScheduleIntervalSeconds int `json:"schedule_interval_seconds,omitempty"`
+	// Enabled defaults to true.
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+type CreateSyncJobResponse struct {
+	JobId   string `json:"job_id"`
+	JobName string `json:"job_name"`
+}
+
+type UpdateSyncJobRequest struct {
+	JobId string `json:"job_id"`
+	CreateSyncJobRequest
+}
+
+type SyncJobDTO struct {
+	Id                      string     `json:"id"`
+	UpstreamId              string     `json:"upstream_id"`
+	Name                    string     `json:"name"`
+	Repositories            []string   `json:"repositories"`
+	TagPatterns             []string   `json:"tag_patterns"`
+	Platforms               []string   `json:"platforms"`
+	ScheduleIntervalSeconds int        `json:"schedule_interval_seconds"`
+	Enabled                 bool       `json:"enabled"`
+	LastRunAt               *time.Time `json:"last_run_at"`
+	LastRunStatus           string     `json:"last_run_status"`
+	LastRunMessage          string     `json:"last_run_message"`
+	LastSyncedImages        int        `json:"last_synced_images"`
+	CreatedAt               time.Time  `json:"created_at"`
+	UpdatedAt               *time.Time `json:"updated_at"`
+}
+
+// CachedRepositoryDTO is a repository of the upstream cache. Size includes manifests and blobs in bytes.
+type FlushNotFoundCacheResponse struct {
+	FlushedEntries int `json:"flushed_entries"`
+}
+
+type CachedRepositoryDTO struct {
+	Id             string     `json:"id"`
+	Namespace      string     `json:"namespace"`
+	Name           string     `json:"name"`
+	Tags           int        `json:"tags"`
+	Digests        int        `json:"digests"`
+	Blobs          int        `json:"blobs"`
+	Size           int64      `json:"size"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// CacheEntryDTO is a tag or digest of the upstream cache. ExpiresAt is nil for digests since they never
+// expire.
+type CacheEntryDTO struct {
+	Identifier     string     `json:"identifier"`
+	Type           string     `json:"type"`
+	Digest         string     `json:"digest"`
+	MediaType      string     `json:"media_type"`
+	Size           int64      `json:"size"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	Expired        bool       `json:"expired"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type CachedBlobDTO struct {
+	Digest         string     `json:"digest"`
+	Size           int64      `json:"size"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// PurgeCacheResponse reports blobs which were removed from storage. FreedBytes is in bytes.
+type PurgeCacheResponse struct {
+	DeletedBlobs int   `json:"deleted_blobs"`
+	FreedBytes   int64 `json:"freed_bytes"`
+}
@@ -52,6 +52,21 @@ type ChangePasswordResponse struct {
 	Message string `json:"message"`
 }
 
+type ForgotPasswordRequest struct {
+	Username string `json:"username"`
+}
+
+// ForgotPasswordResponse is the same whether the account exists or not. Token is set only in development mode.
+type ForgotPasswordResponse struct {
+	Message string `json:"message"`
+	Token   string `json:"token,omitempty"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
 type UpdateUserEmailRequest struct {
 	UserId string `json:"user_id"`
 	Email  string `json:"email"`
@@ -100,4 +115,88 @@ type GetUserResponse struct {
 	CreatedAt      time.Time  `json:"created_at"`
 	UpdatedAt      *time.Time `json:"updated_at"`
 	LockedAt       *time.Time `json:"locked_at"`
-}
\ No newline at end of file
+}
+type CreateAccessTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// token never expires if this is 0
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+type AccessTokenDTO struct {
+	Id          string     `json:"id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CreateAccessTokenResponse is the only response which contains the token. Only its hash is persisted.
+type CreateAccessTokenResponse struct {
+	AccessTokenDTO
+	Token string `json:"token"`
+}
+
+type ListAccessTokensResponse struct {
+	Tokens []*AccessTokenDTO `json:"tokens"`
+}
+
+type AuthSessionDTO struct {
+	Id             string    `json:"id"`
+	UserAgent      string    `json:"user_agent"`
+	IPAddress      string    `json:"ip_address"`
+	GrantType      string    `json:"grant_type"`
+	IssuedAt       time.Time `json:"issued_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	LastAccessedAt time.Time `json:"last_accessed_at"`
+	// Current is true for the session of the request
+	Current bool `json:"current"`
+}
+
+type ListAuthSessionsResponse struct {
+	Sessions []*AuthSessionDTO `json:"sessions"`
+}
+
+type RevokeUserSessionsResponse struct {
+	RevokedSessions int64 `json:"revoked_sessions"`
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled bool `json:"enabled"`
+	// Required is true if the role of the user requires two-factor authentication
+	Required               bool `json:"required"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorEnrollmentResponse has the secret of a pending enrollment. ProvisioningURI is encoded in a QR code for
+// authenticator apps.
+type TwoFactorEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TwoFactorCodeRequest has either a TOTP code or a recovery code
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TwoFactorRecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type RoleDTO struct {
+	Name              string `json:"name"`
+	TwoFactorRequired bool   `json:"two_factor_required"`
+}
+
+type ListRolesResponse struct {
+	Roles []*RoleDTO `json:"roles"`
+}
+
+type UpdateRoleRequest struct {
+	TwoFactorRequired bool `json:"two_factor_required"`
+}
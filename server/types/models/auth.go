@@ -1,8 +1,81 @@
 package models
 
+import "time"
+
 type RevokedToken struct {
 	SignatureHash string
 	ExpiresAt     int64
 	IssuedAt      int64
 	UserID        string
-}
\ No newline at end of file
+}
+
+// PersonalAccessToken is a token created by a user for scripts and docker clients. Scopes are comma separated.
+type PersonalAccessToken struct {
+	ID          string
+	UserID      string
+	Name        string
+	TokenPrefix string
+	TokenHash   string
+	Scopes      string
+	ExpiresAt   *time.Time
+	LastUsedAt  *time.Time
+	RevokedAt   *time.Time
+	CreatedAt   time.Time
+}
+
+// AuthSession is a login session. Auth tokens of the session are renewed with its refresh token until it expires.
+type AuthSession struct {
+	ID               string
+	UserID           string
+	ScopeHash        string
+	RefreshTokenHash string
+	IssuedAt         time.Time
+	ExpiresAt        time.Time
+	LastAccessedAt   time.Time
+	UserAgent        string
+	IPAddress        string
+	GrantType        string
+}
+
+// AuthorizationRequest is an OIDC login waiting for the callback of the identity provider
+type AuthorizationRequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+// TwoFactorEnrollment is the TOTP secret of a user. It is used for login only after it is enabled.
+type TwoFactorEnrollment struct {
+	UserID       string
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64
+	CreatedAt    time.Time
+	EnabledAt    *time.Time
+}
+
+// TwoFactorChallenge is a login waiting for the second factor. ScopeHash is passed to the session.
+type TwoFactorChallenge struct {
+	TokenHash string
+	UserID    string
+	ScopeHash string
+	// GrantType is the grant type of the session which is started after the second factor is verified
+	GrantType string
+}
+
+// RoleTwoFactorPolicy tells whether accounts of the role must use two-factor authentication
+type RoleTwoFactorPolicy struct {
+	Role              string
+	TwoFactorRequired bool
+}
+
+// SigningKey is a key pair of auth tokens. Keys are PEM encoded. RetiredAt is set when another key was activated.
+type SigningKey struct {
+	KeyID      string
+	Algorithm  string
+	PrivateKey string
+	PublicKey  string
+	Active     bool
+	CreatedAt  time.Time
+	RetiredAt  *time.Time
+}
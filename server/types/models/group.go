@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type GroupRegistry struct {
+	ID           string
+	Name         string
+	Description  string
+	State        string
+	Port         uint
+	PushMemberID string
+	CreatedAt    time.Time
+	UpdatedAt    *time.Time
+}
+
+// GroupRegistryMemberView is a member of group registry. Name and State are empty for hosted registry.
+type GroupRegistryMemberView struct {
+	MemberID string
+	Name     string
+	State    string
+	Position int
+}
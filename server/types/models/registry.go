@@ -29,14 +29,15 @@ type RepositoryModel struct {
 }
 
 type ImageBlobMetaModel struct {
-	NamespaceID  string
-	RegistryID   string
-	RepositoryID string
-	Digest       string
-	Size         int
-	Location     string
-	CreatedAt    time.Time
-	UpdatedAt    *time.Time
+	NamespaceID    string
+	RegistryID     string
+	RepositoryID   string
+	Digest         string
+	Size           int
+	Location       string
+	LastAccessedAt *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      *time.Time
 }
 
 type ImageBlobUploadSessionModel struct {
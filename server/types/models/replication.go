@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+type ReplicationRule struct {
+	ID                      string
+	Name                    string
+	Description             string
+	TargetURL               string
+	Username                string
+	Password                string
+	NamespaceFilter         string
+	RepositoryFilter        string
+	TagFilter               string
+	Trigger                 string
+	ScheduleIntervalSeconds int
+	Enabled                 bool
+	LastScheduledAt         *time.Time
+	CreatedAt               time.Time
+	UpdatedAt               *time.Time
+}
+
+type ReplicationTask struct {
+	ID            string
+	RuleID        string
+	Namespace     string
+	Repository    string
+	Tag           string
+	Status        string
+	Attempts      int
+	LastError     string
+	NextAttemptAt *time.Time
+	CompletedAt   *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     *time.Time
+}
+
+// ReplicationRuleStats summarizes tasks of a replication rule.
+type ReplicationRuleStats struct {
+	Pending   int
+	Running   int
+	Succeeded int
+	Failed    int
+	// OldestPendingAt is the creation time of the oldest task which is not replicated yet.
+	OldestPendingAt *time.Time
+	LastSuccessAt   *time.Time
+	LastFailureAt   *time.Time
+	LastError       string
+}
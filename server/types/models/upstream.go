@@ -23,13 +23,22 @@ type UpstreamRegistryAuthConfig struct {
 }
 
 type UpstreamRegistryCacheStoreConfig struct {
-	RegistryID       string
-	CacheEnabled     bool
-	TTLSeconds       int
-	StorageLimit     float32
-	CleanupThreshold float32
-	CreatedAt        time.Time
-	UpdatedAt        *time.Time
+	RegistryID   string
+	CacheEnabled bool
+	TTLSeconds   int
+	TTLRules     []CacheTTLRule
+	// NegativeTTLSeconds is how long manifests which upstream reported as not found are not looked up again.
+	NegativeTTLSeconds int
+	StorageLimit       float32
+	CleanupThreshold   float32
+	CreatedAt          time.Time
+	UpdatedAt          *time.Time
+}
+
+// CacheTTLRule overrides TTL of cached tags which match TagPattern. Rules are evaluated in order.
+type CacheTTLRule struct {
+	TagPattern string `json:"tag_pattern"`
+	TTLSeconds int    `json:"ttl_seconds"`
 }
 
 type UpstreamRegistryNetworkConfig struct {
@@ -42,6 +51,33 @@ type UpstreamRegistryNetworkConfig struct {
 	MaxRetries             int
 	RetryDelay             int
 	RetryBackOffMultiplier float32
+	ProxyEnabled           bool
+	ProxyURL               string
+	ProxyUsername          string
+	ProxyPassword          string
+	TLSCABundle            string
+	TLSClientCert          string
+	TLSClientKey           string
+	TLSInsecureSkipVerify  bool
 	CreatedAt              time.Time
 	UpdatedAt              *time.Time
 }
+
+// UpstreamSyncJob pre-fetches images of an upstream into its cache. Repositories, TagPatterns and
+// Platforms are comma separated.
+type UpstreamSyncJob struct {
+	ID                      string
+	RegistryID              string
+	Name                    string
+	Repositories            string
+	TagPatterns             string
+	Platforms               string
+	ScheduleIntervalSeconds int
+	Enabled                 bool
+	LastRunAt               *time.Time
+	LastRunStatus           string
+	LastRunMessage          string
+	LastSyncedImages        int
+	CreatedAt               time.Time
+	UpdatedAt               *time.Time
+}
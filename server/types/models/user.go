@@ -14,6 +14,7 @@ type UserAccount struct {
 	UpdatedAt      *time.Time
 	LockedAt       *time.Time
 	LastAccessedAt *time.Time
+	AccountType    string
 }
 
 type AccountRecovery struct {
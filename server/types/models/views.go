@@ -40,6 +40,19 @@ type UpstreamAddressView struct {
 	UpstreamUrl string
 }
 
+type UpstreamRegistryView struct {
+	ID                string
+	Name              string
+	Description       string
+	Vendor            string
+	State             string
+	Port              int
+	UpstreamURL       string
+	CachedImagesCount int
+	CreatedAt         time.Time
+	UpdatedAt         *time.Time
+}
+
 type NamespaceView struct {
 	RegistryID  string
 	ID          string
@@ -68,3 +81,39 @@ type RepositoryView struct {
 	CreatedAt   time.Time
 	UpdatedAt   *time.Time
 }
+
+// ImageTagView is a tag along with names of its namespace and repository.
+type ImageTagView struct {
+	Namespace  string
+	Repository string
+	Tag        string
+}
+
+// CachedRepositoryView is a repository of an upstream along with the usage of its cache. Size includes
+// manifests and blobs.
+type CachedRepositoryView struct {
+	RegistryID     string
+	ID             string
+	Namespace      string
+	Name           string
+	TagsCount      int
+	DigestsCount   int
+	BlobsCount     int
+	Size           int64
+	LastAccessedAt *time.Time
+	CreatedAt      time.Time
+}
+
+// CacheEntryView is a cached tag or digest along with the manifest it refers.
+type CacheEntryView struct {
+	RegistryID     string
+	RepositoryID   string
+	Identifier     string
+	Type           string
+	Digest         string
+	MediaType      string
+	Size           int64
+	ExpiresAt      time.Time
+	LastAccessedAt *time.Time
+	CreatedAt      time.Time
+}
@@ -1,6 +1,8 @@
 package user
 
 import (
+	"strings"
+
 	"github.com/google/uuid"
 	"github.com/ksankeerth/open-image-registry/constants"
 	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
@@ -141,4 +143,49 @@ func (ua *UserAdapter) makeGetUserResponse(m *models.UserAccount, role string) *
 	}
 
 	return res
-}
\ No newline at end of file
+}
+
+func (ua *UserAdapter) toAccessTokenDTO(m *models.PersonalAccessToken) *mgmt.AccessTokenDTO {
+	if m == nil {
+		return nil
+	}
+
+	return &mgmt.AccessTokenDTO{
+		Id:          m.ID,
+		Name:        m.Name,
+		TokenPrefix: m.TokenPrefix,
+		Scopes:      strings.Split(m.Scopes, ","),
+		ExpiresAt:   m.ExpiresAt,
+		LastUsedAt:  m.LastUsedAt,
+		RevokedAt:   m.RevokedAt,
+		CreatedAt:   m.CreatedAt,
+	}
+}
+
+func (ua *UserAdapter) toAuthSessionDTO(m *models.AuthSession, currentSessionID string) *mgmt.AuthSessionDTO {
+	if m == nil {
+		return nil
+	}
+
+	return &mgmt.AuthSessionDTO{
+		Id:             m.ID,
+		UserAgent:      m.UserAgent,
+		IPAddress:      m.IPAddress,
+		GrantType:      m.GrantType,
+		IssuedAt:       m.IssuedAt,
+		ExpiresAt:      m.ExpiresAt,
+		LastAccessedAt: m.LastAccessedAt,
+		Current:        m.ID == currentSessionID,
+	}
+}
+
+func (ua *UserAdapter) toRoleDTO(m *models.RoleTwoFactorPolicy) *mgmt.RoleDTO {
+	if m == nil {
+		return nil
+	}
+
+	return &mgmt.RoleDTO{
+		Name:              m.Role,
+		TwoFactorRequired: m.TwoFactorRequired,
+	}
+}
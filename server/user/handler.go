@@ -1,17 +1,26 @@
 package user
 
 import (
+	"context"
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ksankeerth/open-image-registry/client/email"
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/constants"
 	"github.com/ksankeerth/open-image-registry/errors/httperrors"
 	"github.com/ksankeerth/open-image-registry/lib"
 	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/middleware"
 	"github.com/ksankeerth/open-image-registry/resource/access"
+	"github.com/ksankeerth/open-image-registry/security"
 	"github.com/ksankeerth/open-image-registry/store"
 	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/utils"
 )
 
 type UserAPIHandler struct {
@@ -21,12 +30,17 @@ type UserAPIHandler struct {
 
 // NewUserAPIHandler creates a new user API handler
 func NewUserAPIHandler(store store.Store, emailClient *email.EmailClient) *UserAPIHandler {
+	resetConfig := config.GetPasswordResetConfig()
+	resetWindow := time.Duration(resetConfig.RateLimitWindowSeconds) * time.Second
+
 	return &UserAPIHandler{
 		svc: &userService{
-			store:         store,
-			adapter:       &UserAdapter{},
-			ec:            emailClient,
-			accessManager: access.NewManager(store),
+			store:               store,
+			adapter:             &UserAdapter{},
+			ec:                  emailClient,
+			accessManager:       access.NewManager(store),
+			resetAccountLimiter: security.NewRateLimiter(resetConfig.MaxRequestsPerAccount, resetWindow),
+			resetIPLimiter:      security.NewRateLimiter(resetConfig.MaxRequestsPerIP, resetWindow),
 		},
 	}
 }
@@ -423,6 +437,523 @@ func (h *UserAPIHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// CreateAccessToken handles POST /api/v1/users/me/tokens
+func (h *UserAPIHandler) CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	// A token must not be able to create tokens with wider scopes than its own
+	if r.Context().Value(constants.ContextAuthMethod) == constants.AuthMethodAccessToken {
+		httperrors.NotAllowed(w, 403, "Personal access tokens can't be created with a personal access token")
+		return
+	}
+
+	var req mgmt.CreateAccessTokenRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when reading json request body: %s", r.RequestURI)
+		httperrors.BadRequest(w, 400, "Invalid payload")
+		return
+	}
+
+	valid, errMsg := ValidateCreateAccessToken(&req)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	username := r.Context().Value(constants.ContextUsername).(string)
+
+	res, err := h.svc.createAccessToken(r.Context(), username, &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if res.userNotFound {
+		httperrors.NotFound(w, 404, "User not found")
+		return
+	}
+
+	if res.conflict {
+		httperrors.AlreadyExist(w, 409, "An active token with the same name already exists")
+		return
+	}
+
+	response := mgmt.CreateAccessTokenResponse{
+		AccessTokenDTO: *h.adapter.toAccessTokenDTO(res.token),
+		Token:          res.secret,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+// ListAccessTokens handles GET /api/v1/users/me/tokens
+func (h *UserAPIHandler) ListAccessTokens(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value(constants.ContextUsername).(string)
+
+	tokens, found, err := h.svc.listAccessTokens(r.Context(), username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !found {
+		httperrors.NotFound(w, 404, "User not found")
+		return
+	}
+
+	response := mgmt.ListAccessTokensResponse{
+		Tokens: make([]*mgmt.AccessTokenDTO, len(tokens)),
+	}
+	for i, token := range tokens {
+		response.Tokens[i] = h.adapter.toAccessTokenDTO(token)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+// RevokeAccessToken handles DELETE /api/v1/users/me/tokens/{tokenId}
+func (h *UserAPIHandler) RevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value(constants.ContextUsername).(string)
+	tokenID := chi.URLParam(r, "tokenId")
+
+	found, err := h.svc.revokeAccessToken(r.Context(), username, tokenID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !found {
+		httperrors.NotFound(w, 404, "Token not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ListSessions handles GET /api/v1/users/me/sessions
+func (h *UserAPIHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value(constants.ContextUsername).(string)
+	currentSessionID, _ := r.Context().Value(constants.ContextSessionID).(string)
+
+	sessions, found, err := h.svc.listSessions(r.Context(), username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !found {
+		httperrors.NotFound(w, 404, "User not found")
+		return
+	}
+
+	response := mgmt.ListAuthSessionsResponse{
+		Sessions: make([]*mgmt.AuthSessionDTO, len(sessions)),
+	}
+	for i, session := range sessions {
+		response.Sessions[i] = h.adapter.toAuthSessionDTO(session, currentSessionID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+// RevokeSession handles DELETE /api/v1/users/me/sessions/{sessionId}
+func (h *UserAPIHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value(constants.ContextUsername).(string)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	found, err := h.svc.revokeSession(r.Context(), username, sessionID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !found {
+		httperrors.NotFound(w, 404, "Session not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RevokeUserSessions handles DELETE /api/v1/users/{id}/sessions
+func (h *UserAPIHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "id")
+
+	count, found, err := h.svc.revokeUserSessions(r.Context(), userId)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !found {
+		httperrors.NotFound(w, 404, "User not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(mgmt.RevokeUserSessionsResponse{RevokedSessions: count})
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+// GetTwoFactorStatus handles GET /api/v1/users/me/2fa
+func (h *UserAPIHandler) GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value(constants.ContextUsername).(string)
+
+	res, err := h.svc.getTwoFactorStatus(r.Context(), username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if res.userNotFound {
+		httperrors.NotFound(w, 404, "User not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res.status)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+// EnrollTwoFactor handles POST /api/v1/users/me/2fa. The enrollment is pending until a code is confirmed.
+func (h *UserAPIHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if !h.allowTwoFactorChanges(w, r) {
+		return
+	}
+
+	username := r.Context().Value(constants.ContextUsername).(string)
+
+	res, err := h.svc.enrollTwoFactor(r.Context(), username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if h.writeTwoFactorError(w, res) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(res.enrollment)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+// ConfirmTwoFactor handles POST /api/v1/users/me/2fa/confirm
+func (h *UserAPIHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	h.handleTwoFactorCode(w, r, h.svc.confirmTwoFactor)
+}
+
+// DisableTwoFactor handles DELETE /api/v1/users/me/2fa
+func (h *UserAPIHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	h.handleTwoFactorCode(w, r, h.svc.disableTwoFactor)
+}
+
+// RegenerateRecoveryCodes handles POST /api/v1/users/me/2fa/recovery-codes
+func (h *UserAPIHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	h.handleTwoFactorCode(w, r, h.svc.regenerateRecoveryCodes)
+}
+
+// handleTwoFactorCode runs two-factor operations which are verified with a code. New recovery codes are returned
+// if the operation generated them.
+func (h *UserAPIHandler) handleTwoFactorCode(w http.ResponseWriter, r *http.Request,
+	op func(context.Context, string, *mgmt.TwoFactorCodeRequest) (*twoFactorResult, error)) {
+	if !h.allowTwoFactorChanges(w, r) {
+		return
+	}
+
+	var req mgmt.TwoFactorCodeRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when reading json request body: %s", r.RequestURI)
+		httperrors.BadRequest(w, 400, "Invalid payload")
+		return
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		httperrors.BadRequest(w, 400, "Verification code is required")
+		return
+	}
+
+	username := r.Context().Value(constants.ContextUsername).(string)
+
+	res, err := op(r.Context(), username, &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if h.writeTwoFactorError(w, res) {
+		return
+	}
+
+	if res.recoveryCodes == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(mgmt.TwoFactorRecoveryCodesResponse{RecoveryCodes: res.recoveryCodes})
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+// allowTwoFactorChanges rejects requests of personal access tokens. Only the user can change two-factor settings.
+func (h *UserAPIHandler) allowTwoFactorChanges(w http.ResponseWriter, r *http.Request) bool {
+	if r.Context().Value(constants.ContextAuthMethod) == constants.AuthMethodAccessToken {
+		httperrors.NotAllowed(w, 403, "Two-factor authentication can't be changed with a personal access token")
+		return false
+	}
+	return true
+}
+
+// writeTwoFactorError writes the error response if the two-factor operation failed
+func (h *UserAPIHandler) writeTwoFactorError(w http.ResponseWriter, res *twoFactorResult) bool {
+	switch {
+	case res.userNotFound:
+		httperrors.NotFound(w, 404, "User not found")
+	case res.notAllowed:
+		httperrors.BadRequest(w, 400, "Two-factor authentication is available only for local accounts")
+	case res.alreadyEnabled:
+		httperrors.AlreadyExist(w, 409, "Two-factor authentication is already enabled")
+	case res.notEnrolled:
+		httperrors.NotFound(w, 404, "Two-factor authentication is not enrolled")
+	case res.locked:
+		httperrors.NotAllowed(w, 403, "User account has been locked! Contact system administrator.")
+	case res.invalidCode:
+		httperrors.BadRequest(w, 400, "Invalid verification code")
+	case res.required:
+		httperrors.AlreadyExist(w, 409, "Two-factor authentication is required for your role")
+	default:
+		return false
+	}
+	return true
+}
+
+// ResetTwoFactor handles DELETE /api/v1/users/{id}/2fa. The user can enroll again, e.g. after losing the device.
+func (h *UserAPIHandler) ResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "id")
+
+	found, enrolled, err := h.svc.resetTwoFactor(r.Context(), userId)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !found {
+		httperrors.NotFound(w, 404, "User not found")
+		return
+	}
+	if !enrolled {
+		httperrors.NotFound(w, 404, "Two-factor authentication is not enrolled")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ListRoles handles GET /api/v1/users/roles
+func (h *UserAPIHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.svc.listRoles(r.Context())
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	response := mgmt.ListRolesResponse{
+		Roles: make([]*mgmt.RoleDTO, 0, len(roles)),
+	}
+	for _, role := range roles {
+		// machine accounts don't log in
+		if isValidRole(role.Role) {
+			response.Roles = append(response.Roles, h.adapter.toRoleDTO(role))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+// UpdateRole handles PUT /api/v1/users/roles/{role}
+func (h *UserAPIHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	role := chi.URLParam(r, "role")
+	if !isValidRole(role) {
+		httperrors.BadRequest(w, 400, "Invalid role")
+		return
+	}
+
+	var req mgmt.UpdateRoleRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when reading json request body: %s", r.RequestURI)
+		httperrors.BadRequest(w, 400, "Invalid payload")
+		return
+	}
+
+	found, err := h.svc.updateRole(r.Context(), role, &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if !found {
+		httperrors.NotFound(w, 404, "Role not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ForgotPassword handles POST /api/v1/forgot-password. The response is the same whether the account exists or not.
+func (h *UserAPIHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if !h.allowPasswordReset(w, r) {
+		return
+	}
+
+	var req mgmt.ForgotPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when reading json request body: %s", r.RequestURI)
+		httperrors.BadRequest(w, 400, "Invalid payload")
+		return
+	}
+
+	if req.Username == "" {
+		httperrors.BadRequest(w, 400, "Username is required")
+		return
+	}
+
+	token, err := h.svc.requestPasswordReset(r.Context(), req.Username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	err = json.NewEncoder(w).Encode(mgmt.ForgotPasswordResponse{
+		Message: "If the account exists, a password reset link has been sent to its email",
+		Token:   token,
+	})
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occured when writing response: %s", r.RequestURI)
+	}
+}
+
+// ResetPassword handles POST /api/v1/forgot-password/reset
+func (h *UserAPIHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if !h.allowPasswordReset(w, r) {
+		return
+	}
+
+	var req mgmt.ResetPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when reading json request body: %s", r.RequestURI)
+		httperrors.BadRequest(w, 400, "Invalid payload")
+		return
+	}
+
+	if req.Token == "" {
+		httperrors.BadRequest(w, 400, "Token is required")
+		return
+	}
+
+	valid, errMsg := utils.ValidatePassword(req.Password)
+	if !valid {
+		httperrors.BadRequest(w, 400, errMsg)
+		return
+	}
+
+	res, err := h.svc.completePasswordReset(r.Context(), &req)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Request aborted due to errors: %s", r.RequestURI)
+		httperrors.InternalError(w, 500, "Request aborted due to errors")
+		return
+	}
+
+	if res.invalidLink {
+		httperrors.BadRequest(w, 400, "This password reset link is invalid or has expired")
+		return
+	}
+	if res.locked {
+		httperrors.NotAllowed(w, 403, "User account has been locked! Contact system administrator.")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// allowPasswordReset rejects password reset requests if email notifications are disabled or the client IP reached
+// the limit of requests. The address of the connection is used, since X-Forwarded-For is set by clients.
+func (h *UserAPIHandler) allowPasswordReset(w http.ResponseWriter, r *http.Request) bool {
+	if h.svc.ec == nil {
+		httperrors.SendError(w, http.StatusServiceUnavailable,
+			"Password reset is not available. Contact system administrator.")
+		return false
+	}
+
+	allowed, retryAfter := h.svc.resetIPLimiter.Allow(utils.RemoteIP(r))
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		httperrors.SendError(w, http.StatusTooManyRequests, "Too many requests. Please try again later.")
+		return false
+	}
+	return true
+}
+
+func (h *UserAPIHandler) ForgotPasswordRoutes() chi.Router {
+	router := chi.NewRouter()
+
+	router.Route("/", func(r chi.Router) {
+		r.Post("/", h.ForgotPassword)
+		r.Post("/reset", h.ResetPassword)
+	})
+
+	return router
+}
+
 func (h *UserAPIHandler) OnboardingRoutes() chi.Router {
 	router := chi.NewRouter()
 
@@ -439,6 +970,18 @@ func (h *UserAPIHandler) Routes() chi.Router {
 	router.Route("/", func(r chi.Router) {
 		r.Get("/me", h.GetCurrentUser)
 		r.Put("/me", h.UpdateCurrentUser)
+		r.Post("/me/tokens", h.CreateAccessToken)
+		r.Get("/me/tokens", h.ListAccessTokens)
+		r.Delete("/me/tokens/{tokenId}", h.RevokeAccessToken)
+		r.Get("/me/sessions", h.ListSessions)
+		r.Delete("/me/sessions/{sessionId}", h.RevokeSession)
+		r.Get("/me/2fa", h.GetTwoFactorStatus)
+		r.Post("/me/2fa", h.EnrollTwoFactor)
+		r.Delete("/me/2fa", h.DisableTwoFactor)
+		r.Post("/me/2fa/confirm", h.ConfirmTwoFactor)
+		r.Post("/me/2fa/recovery-codes", h.RegenerateRecoveryCodes)
+		r.With(middleware.RequireRole(constants.RoleAdmin)).Get("/roles", h.ListRoles)
+		r.With(middleware.RequireRole(constants.RoleAdmin)).Put("/roles/{role}", h.UpdateRole)
 		r.Post("/validate", h.ValidateUser)
 
 		r.Put("/{id}/email", h.UpdateUserEmail)
@@ -447,6 +990,8 @@ func (h *UserAPIHandler) Routes() chi.Router {
 		r.Put("/{id}", h.UpdateUser)
 		r.Put("/{id}/lock", h.LockUser)
 		r.Put("/{id}/unlock", h.UnlockUser)
+		r.With(middleware.RequireRole(constants.RoleAdmin)).Delete("/{id}/sessions", h.RevokeUserSessions)
+		r.With(middleware.RequireRole(constants.RoleAdmin)).Delete("/{id}/2fa", h.ResetTwoFactor)
 
 		r.Delete("/{id}", h.DeleteUser)
 		r.Get("/{id}", h.GetUser)
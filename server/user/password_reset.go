@@ -0,0 +1,211 @@
+package user
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/ksankeerth/open-image-registry/config"
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/security"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+type passwordResetResult struct {
+	// invalidLink is set if the token doesn't exist, expired or was already used
+	invalidLink bool
+	locked      bool
+}
+
+// requestPasswordReset sends a password reset link to the email of the account. Nothing is sent if the account
+// doesn't exist, can't reset its password or reached the limit of requests, and the caller can't tell these cases
+// apart. token is returned only in development mode.
+func (svc *userService) requestPasswordReset(reqCtx context.Context, username string) (token string, err error) {
+	userAccount, err := svc.passwordResetAccount(reqCtx, username)
+	if err != nil || userAccount == nil {
+		return "", err
+	}
+
+	if allowed, _ := svc.resetAccountLimiter.Allow(userAccount.Id); !allowed {
+		log.Logger().Warn().Msgf("Password reset requests of user(%s) reached the limit", username)
+		return "", nil
+	}
+
+	token, err = security.GenerateAccessToken(constants.PasswordResetTokenPrefix)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when generating password reset token")
+		return "", err
+	}
+
+	err = svc.savePasswordReset(reqCtx, userAccount.Id, utils.CalcuateDigest([]byte(token)))
+	if err != nil {
+		return "", err
+	}
+
+	resetLink := config.GetPasswordResetConfig().ResetURL + "#token=" + url.QueryEscape(token)
+
+	// the email is sent in background, so the response time doesn't tell whether the account exists
+	go func() {
+		err := svc.ec.SendPasswordResetEmail(userAccount.Username, userAccount.Email, resetLink)
+		if err != nil {
+			log.Logger().Error().Err(err).Msgf("Error occurred when sending password reset mail to %s",
+				userAccount.Email)
+		}
+	}()
+
+	if !config.GetDevelopmentConfig().Enable {
+		token = ""
+	}
+	return token, nil
+}
+
+// passwordResetAccount returns the account if it can reset its password. Only local accounts which completed
+// account setup can reset their passwords. Accounts locked by failed login attempts are unlocked by the reset, but
+// accounts locked by admins are not.
+func (svc *userService) passwordResetAccount(ctx context.Context, username string) (*models.UserAccount, error) {
+	userAccount, err := svc.store.Users().GetByUsername(ctx, username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", username)
+		return nil, err
+	}
+	if userAccount == nil || userAccount.AccountType == constants.AccountTypeMachine {
+		return nil, nil
+	}
+	if userAccount.Locked && userAccount.LockedReason != constants.ReasonLockedFailedLoginAttempts {
+		return nil, nil
+	}
+
+	identityProvider, err := svc.store.Users().GetIdentityProvider(ctx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving identity provider of user(%s)", username)
+		return nil, err
+	}
+	if identityProvider != "" {
+		return nil, nil
+	}
+
+	return userAccount, nil
+}
+
+// savePasswordReset replaces a previous password reset of the user, so only the latest link works
+func (svc *userService) savePasswordReset(reqCtx context.Context, userID, tokenHash string) (err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	err = svc.store.AccountRecovery().DeleteByUserID(ctx, userID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when removing password recovery of user: %s", userID)
+		return err
+	}
+
+	err = svc.store.AccountRecovery().Create(ctx, userID, tokenHash, constants.ReasonPasswordRecoveryForgotPassowrd)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when creating password recovery of user: %s", userID)
+		return err
+	}
+
+	return nil
+}
+
+// completePasswordReset sets the new password if the link is valid. The link works once. Sessions of the user are
+// revoked, and failed login attempts are cleared.
+func (svc *userService) completePasswordReset(reqCtx context.Context, req *mgmt.ResetPasswordRequest) (
+	res *passwordResetResult, err error) {
+	res = &passwordResetResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	pwRecovery, err := svc.store.AccountRecovery().GetUnexpired(ctx, utils.CalcuateDigest([]byte(req.Token)),
+		constants.ReasonPasswordRecoveryForgotPassowrd, config.GetPasswordResetConfig().ExpirySeconds)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when retriving password recovery")
+		return nil, err
+	}
+	if pwRecovery == nil {
+		res.invalidLink = true
+		return res, nil
+	}
+
+	userAccount, err := svc.store.Users().Get(ctx, pwRecovery.UserID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database",
+			pwRecovery.UserID)
+		return nil, err
+	}
+	if userAccount == nil {
+		res.invalidLink = true
+		return res, nil
+	}
+
+	// the account may be locked by an admin after the link was sent
+	if userAccount.Locked && userAccount.LockedReason != constants.ReasonLockedFailedLoginAttempts {
+		res.locked = true
+		return res, nil
+	}
+
+	salt, err := security.GenerateSalt(16)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when generating salt")
+		return nil, err
+	}
+	passwordHash := security.GeneratePasswordHash(req.Password, salt)
+
+	err = svc.store.Users().UpdatePasswordAndSalt(ctx, userAccount.Id, passwordHash, salt)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when setting new password for user: %s", userAccount.Id)
+		return nil, err
+	}
+
+	err = svc.store.Users().UnlockAccount(ctx, userAccount.Username, true)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when unlocking user account: %s", userAccount.Username)
+		return nil, err
+	}
+
+	err = svc.store.AccountRecovery().DeleteByUserID(ctx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when removing password recovery of user: %s",
+			userAccount.Id)
+		return nil, err
+	}
+
+	// whoever knew the old password must not continue with existing logins
+	_, err = svc.store.Auth().DeleteUserSessions(ctx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when revoking sessions of user account: %s",
+			userAccount.Id)
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -3,6 +3,7 @@ package user
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/google/uuid"
@@ -17,6 +18,7 @@ import (
 	"github.com/ksankeerth/open-image-registry/log"
 	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
 	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
 )
 
 type userService struct {
@@ -25,6 +27,9 @@ type userService struct {
 	ec            *email.EmailClient
 	adapter       *UserAdapter
 	userIdNameMap sync.Map // for now, we'll sync.Map, later we have to use map with Mutex
+	// password reset requests are limited per account and per client IP
+	resetAccountLimiter *security.RateLimiter
+	resetIPLimiter      *security.RateLimiter
 }
 
 func (svc *userService) getUserList(cond *store.ListQueryConditions) (users []*models.UserAccountView, total int, err error) {
@@ -284,6 +289,13 @@ func (svc *userService) lockUserAccount(userId string) (*lockResult, error) {
 		return res, err
 	}
 
+	// locked users must not continue with their existing logins
+	_, err = svc.store.Auth().DeleteUserSessions(ctx, user.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when revoking sessions of user account: %s", userId)
+		return res, err
+	}
+
 	res.success = true
 
 	return res, nil
@@ -504,6 +516,10 @@ func (svc *userService) changeRole(reqCtx context.Context, newRole, userId strin
 		return "Not allowed to change role of Admins", nil
 	}
 
+	if role == constants.RoleMachine {
+		return "Not allowed to change role of machine accounts", nil
+	}
+
 	if !isRolePromotion(role, newRole) {
 		var overPriveleges int
 		if role == constants.RoleMaintainer {
@@ -603,4 +619,239 @@ func (svc *userService) getUser(reqCtx context.Context, identifier string) (user
 	}
 
 	return
-}
\ No newline at end of file
+}
+
+type createAccessTokenResult struct {
+	userNotFound bool
+	conflict     bool
+	token        *models.PersonalAccessToken
+	// secret is the plain token. It is returned to the user only once.
+	secret string
+}
+
+func (svc *userService) createAccessToken(reqCtx context.Context, username string,
+	req *mgmt.CreateAccessTokenRequest) (res *createAccessTokenResult, err error) {
+	res = &createAccessTokenResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	user, err := svc.store.Users().GetByUsername(ctx, username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", username)
+		return nil, err
+	}
+	if user == nil {
+		res.userNotFound = true
+		return res, nil
+	}
+
+	existing, err := svc.store.AccessTokens().GetActiveTokenByName(ctx, user.Id, req.Name)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when checking access tokens of user(%s)", username)
+		return nil, err
+	}
+	if existing != nil {
+		res.conflict = true
+		return res, nil
+	}
+
+	secret, err := security.GenerateAccessToken(constants.AccessTokenPrefix)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when generating access token")
+		return nil, err
+	}
+
+	m := &models.PersonalAccessToken{
+		UserID:      user.Id,
+		Name:        req.Name,
+		TokenPrefix: secret[:constants.AccessTokenDisplayLength],
+		TokenHash:   utils.CalcuateDigest([]byte(secret)),
+		Scopes:      strings.Join(req.Scopes, ","),
+	}
+
+	tokenID, err := svc.store.AccessTokens().CreateToken(ctx, m, req.ExpiresInDays)
+	if err != nil {
+		if yes, _ := dberrors.IsUniqueConstraint(err); yes {
+			err = nil
+			res.conflict = true
+			return res, nil
+		}
+		log.Logger().Error().Err(err).Msgf("Error occurred when creating access token for user(%s)", username)
+		return nil, err
+	}
+
+	res.token, err = svc.store.AccessTokens().GetToken(ctx, user.Id, tokenID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving access token(%s)", tokenID)
+		return nil, err
+	}
+	res.secret = secret
+
+	return res, nil
+}
+
+func (svc *userService) listAccessTokens(reqCtx context.Context, username string) (
+	tokens []*models.PersonalAccessToken, userFound bool, err error) {
+	user, err := svc.store.Users().GetByUsername(reqCtx, username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", username)
+		return nil, false, err
+	}
+	if user == nil {
+		return nil, false, nil
+	}
+
+	tokens, err = svc.store.AccessTokens().ListTokens(reqCtx, user.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving access tokens of user(%s)", username)
+		return nil, false, err
+	}
+
+	return tokens, true, nil
+}
+
+// revokeAccessToken revokes the token if it belongs to the user. Revoking a revoked token is not an error.
+func (svc *userService) revokeAccessToken(reqCtx context.Context, username, tokenID string) (found bool, err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return false, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	user, err := svc.store.Users().GetByUsername(ctx, username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", username)
+		return false, err
+	}
+	if user == nil {
+		return false, nil
+	}
+
+	token, err := svc.store.AccessTokens().GetToken(ctx, user.Id, tokenID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving access token(%s)", tokenID)
+		return false, err
+	}
+	if token == nil {
+		return false, nil
+	}
+
+	err = svc.store.AccessTokens().RevokeToken(ctx, tokenID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when revoking access token(%s)", tokenID)
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (svc *userService) listSessions(reqCtx context.Context, username string) (sessions []*models.AuthSession,
+	userFound bool, err error) {
+	user, err := svc.store.Users().GetByUsername(reqCtx, username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", username)
+		return nil, false, err
+	}
+	if user == nil {
+		return nil, false, nil
+	}
+
+	sessions, err = svc.store.Auth().ListSessions(reqCtx, user.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving sessions of user(%s)", username)
+		return nil, false, err
+	}
+
+	return sessions, true, nil
+}
+
+// revokeSession deletes the session if it belongs to the user. Auth tokens of the session are rejected from the next
+// request.
+func (svc *userService) revokeSession(reqCtx context.Context, username, sessionID string) (found bool, err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return false, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	user, err := svc.store.Users().GetByUsername(ctx, username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", username)
+		return false, err
+	}
+	if user == nil {
+		return false, nil
+	}
+
+	session, err := svc.store.Auth().GetSession(ctx, sessionID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving session(%s)", sessionID)
+		return false, err
+	}
+	if session == nil || session.UserID != user.Id {
+		return false, nil
+	}
+
+	err = svc.store.Auth().DeleteSession(ctx, sessionID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when deleting session(%s)", sessionID)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// revokeUserSessions deletes all sessions of the user
+func (svc *userService) revokeUserSessions(reqCtx context.Context, userID string) (count int64, userFound bool,
+	err error) {
+	user, err := svc.store.Users().Get(reqCtx, userID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", userID)
+		return 0, false, err
+	}
+	if user == nil {
+		return 0, false, nil
+	}
+
+	count, err = svc.store.Auth().DeleteUserSessions(reqCtx, user.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when deleting sessions of user(%s)", userID)
+		return 0, false, err
+	}
+
+	return count, true, nil
+}
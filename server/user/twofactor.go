@@ -0,0 +1,463 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/ksankeerth/open-image-registry/constants"
+	"github.com/ksankeerth/open-image-registry/log"
+	"github.com/ksankeerth/open-image-registry/security"
+	"github.com/ksankeerth/open-image-registry/store"
+	"github.com/ksankeerth/open-image-registry/types/api/v1alpha/mgmt"
+	"github.com/ksankeerth/open-image-registry/types/models"
+	"github.com/ksankeerth/open-image-registry/utils"
+)
+
+// StartTwoFactorEnrollment replaces the enrollment of the user with a pending enrollment of a new secret. It is used
+// for login only after the user confirms a code of the secret.
+func StartTwoFactorEnrollment(ctx context.Context, s store.Store, userAccount *models.UserAccount) (
+	*mgmt.TwoFactorEnrollmentResponse, error) {
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when generating totp secret")
+		return nil, err
+	}
+
+	err = s.TwoFactor().SaveEnrollment(ctx, userAccount.Id, secret)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when saving two-factor enrollment of user(%s)",
+			userAccount.Username)
+		return nil, err
+	}
+
+	return &mgmt.TwoFactorEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: security.TOTPProvisioningURI(constants.TwoFactorIssuer, userAccount.Username, secret),
+	}, nil
+}
+
+// VerifySecondFactor verifies the TOTP code, or the recovery code if it is given. Codes can be used only once.
+// Recovery codes are accepted only after the enrollment is enabled.
+func VerifySecondFactor(ctx context.Context, s store.Store, enrollment *models.TwoFactorEnrollment, code,
+	recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		if !enrollment.Enabled {
+			return false, nil
+		}
+		codeHash := utils.CalcuateDigest([]byte(security.NormalizeRecoveryCode(recoveryCode)))
+		return s.TwoFactor().UseRecoveryCode(ctx, enrollment.UserID, codeHash)
+	}
+
+	step, valid := security.ValidateTOTP(enrollment.Secret, code, time.Now())
+	if !valid {
+		return false, nil
+	}
+	return s.TwoFactor().RecordUsedStep(ctx, enrollment.UserID, step)
+}
+
+// RecordFailedSecondFactor counts a wrong code towards the lockout of the account like a wrong password
+func RecordFailedSecondFactor(ctx context.Context, s store.Store, userAccount *models.UserAccount) (locked bool,
+	err error) {
+	err = s.Users().RecordFailedAttempt(ctx, userAccount.Username)
+	if err != nil {
+		return false, err
+	}
+
+	if (userAccount.FailedAttempts + 1) > constants.MaxFailedLoginAttempts {
+		err = s.Users().LockAccount(ctx, userAccount.Username, constants.ReasonLockedFailedLoginAttempts)
+		if err != nil {
+			return false, err
+		}
+		log.Logger().Warn().Msgf("User account(%s) was locked due to failed two-factor attempts",
+			userAccount.Username)
+		return true, nil
+	}
+	return false, nil
+}
+
+// EnableTwoFactor enables the pending enrollment of the user and returns new recovery codes
+func EnableTwoFactor(ctx context.Context, s store.Store, userID string) (recoveryCodes []string, err error) {
+	err = s.TwoFactor().EnableEnrollment(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return replaceRecoveryCodes(ctx, s, userID)
+}
+
+// replaceRecoveryCodes generates new recovery codes of the user. Only their hashes are persisted.
+func replaceRecoveryCodes(ctx context.Context, s store.Store, userID string) ([]string, error) {
+	codes, err := security.GenerateRecoveryCodes(constants.TwoFactorRecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.CalcuateDigest([]byte(security.NormalizeRecoveryCode(code)))
+	}
+
+	err = s.TwoFactor().ReplaceRecoveryCodes(ctx, userID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+type twoFactorResult struct {
+	userNotFound bool
+	// notAllowed is set for machine accounts. They don't log in interactively.
+	notAllowed     bool
+	alreadyEnabled bool
+	notEnrolled    bool
+	invalidCode    bool
+	locked         bool
+	// required is set if the role of the user requires two-factor authentication
+	required      bool
+	status        *mgmt.TwoFactorStatusResponse
+	enrollment    *mgmt.TwoFactorEnrollmentResponse
+	recoveryCodes []string
+}
+
+// twoFactorAccount returns the account of the user if it can use two-factor authentication. Otherwise, the reason
+// is set in res.
+func (svc *userService) twoFactorAccount(ctx context.Context, res *twoFactorResult, username string) (
+	*models.UserAccount, error) {
+	userAccount, err := svc.store.Users().GetByUsername(ctx, username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", username)
+		return nil, err
+	}
+	if userAccount == nil {
+		res.userNotFound = true
+		return nil, nil
+	}
+	// accounts of identity providers can use two-factor authentication too, since it is verified after any login
+	if userAccount.AccountType == constants.AccountTypeMachine {
+		res.notAllowed = true
+		return nil, nil
+	}
+
+	return userAccount, nil
+}
+
+func (svc *userService) getTwoFactorStatus(reqCtx context.Context, username string) (res *twoFactorResult,
+	err error) {
+	res = &twoFactorResult{}
+
+	userAccount, err := svc.store.Users().GetByUsername(reqCtx, username)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", username)
+		return nil, err
+	}
+	if userAccount == nil {
+		res.userNotFound = true
+		return res, nil
+	}
+
+	enrollment, err := svc.store.TwoFactor().GetEnrollment(reqCtx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving two-factor enrollment of user(%s)", username)
+		return nil, err
+	}
+
+	role, err := svc.store.Users().GetRole(reqCtx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve role of user(%s)", username)
+		return nil, err
+	}
+
+	required, err := svc.store.TwoFactor().IsRequiredForRole(reqCtx, role)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Failed to retrieve two-factor policy of role(%s)", role)
+		return nil, err
+	}
+
+	res.status = &mgmt.TwoFactorStatusResponse{
+		Enabled:  enrollment != nil && enrollment.Enabled,
+		Required: required,
+	}
+	if res.status.Enabled {
+		res.status.RecoveryCodesRemaining, err = svc.store.TwoFactor().CountUnusedRecoveryCodes(reqCtx,
+			userAccount.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func (svc *userService) enrollTwoFactor(reqCtx context.Context, username string) (res *twoFactorResult,
+	err error) {
+	res = &twoFactorResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	userAccount, err := svc.twoFactorAccount(ctx, res, username)
+	if err != nil || userAccount == nil {
+		return res, err
+	}
+
+	enrollment, err := svc.store.TwoFactor().GetEnrollment(ctx, userAccount.Id)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment != nil && enrollment.Enabled {
+		res.alreadyEnabled = true
+		return res, nil
+	}
+
+	res.enrollment, err = StartTwoFactorEnrollment(ctx, svc.store, userAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// confirmTwoFactor enables the pending enrollment if the code is generated from its secret
+func (svc *userService) confirmTwoFactor(reqCtx context.Context, username string, req *mgmt.TwoFactorCodeRequest) (
+	res *twoFactorResult, err error) {
+	res = &twoFactorResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	userAccount, err := svc.twoFactorAccount(ctx, res, username)
+	if err != nil || userAccount == nil {
+		return res, err
+	}
+
+	enrollment, err := svc.store.TwoFactor().GetEnrollment(ctx, userAccount.Id)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment == nil {
+		res.notEnrolled = true
+		return res, nil
+	}
+	if enrollment.Enabled {
+		res.alreadyEnabled = true
+		return res, nil
+	}
+
+	verified, err := VerifySecondFactor(ctx, svc.store, enrollment, req.Code, "")
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		res.invalidCode = true
+		return res, nil
+	}
+
+	res.recoveryCodes, err = EnableTwoFactor(ctx, svc.store, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when enabling two-factor authentication of user(%s)",
+			username)
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// disableTwoFactor deletes the enrollment of the user. A code is required, so that a stolen session can't disable
+// two-factor authentication. Wrong codes count towards the lockout.
+func (svc *userService) disableTwoFactor(reqCtx context.Context, username string, req *mgmt.TwoFactorCodeRequest) (
+	res *twoFactorResult, err error) {
+	res = &twoFactorResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	userAccount, enrollment, err := svc.verifyEnabledTwoFactor(ctx, res, username, req)
+	if err != nil || enrollment == nil {
+		return res, err
+	}
+
+	role, err := svc.store.Users().GetRole(ctx, userAccount.Id)
+	if err != nil {
+		return nil, err
+	}
+	res.required, err = svc.store.TwoFactor().IsRequiredForRole(ctx, role)
+	if err != nil || res.required {
+		return res, err
+	}
+
+	_, err = svc.store.TwoFactor().DeleteEnrollment(ctx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when deleting two-factor enrollment of user(%s)", username)
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// regenerateRecoveryCodes replaces recovery codes of the user. Same as disabling, a code is required.
+func (svc *userService) regenerateRecoveryCodes(reqCtx context.Context, username string,
+	req *mgmt.TwoFactorCodeRequest) (res *twoFactorResult, err error) {
+	res = &twoFactorResult{}
+
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	userAccount, enrollment, err := svc.verifyEnabledTwoFactor(ctx, res, username, req)
+	if err != nil || enrollment == nil {
+		return res, err
+	}
+
+	res.recoveryCodes, err = replaceRecoveryCodes(ctx, svc.store, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when generating recovery codes of user(%s)", username)
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// verifyEnabledTwoFactor returns the enrollment of the user if it is enabled and the code is valid. Otherwise, the
+// reason is set in res.
+func (svc *userService) verifyEnabledTwoFactor(ctx context.Context, res *twoFactorResult, username string,
+	req *mgmt.TwoFactorCodeRequest) (*models.UserAccount, *models.TwoFactorEnrollment, error) {
+	userAccount, err := svc.twoFactorAccount(ctx, res, username)
+	if err != nil || userAccount == nil {
+		return nil, nil, err
+	}
+
+	enrollment, err := svc.store.TwoFactor().GetEnrollment(ctx, userAccount.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if enrollment == nil || !enrollment.Enabled {
+		res.notEnrolled = true
+		return nil, nil, nil
+	}
+
+	verified, err := VerifySecondFactor(ctx, svc.store, enrollment, req.Code, req.RecoveryCode)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !verified {
+		res.invalidCode = true
+		res.locked, err = RecordFailedSecondFactor(ctx, svc.store, userAccount)
+		return nil, nil, err
+	}
+
+	return userAccount, enrollment, nil
+}
+
+// resetTwoFactor deletes the enrollment of the user, so that the user can enroll again. Pending logins of the user
+// are discarded.
+func (svc *userService) resetTwoFactor(reqCtx context.Context, userID string) (userFound, enrolled bool,
+	err error) {
+	tx, err := svc.store.Begin(reqCtx)
+	if err != nil {
+		log.Logger().Error().Err(err).Msg("Error occurred when starting transaction")
+		return false, false, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			tx.Commit()
+		}
+	}()
+
+	ctx := store.WithTxContext(reqCtx, tx)
+
+	userAccount, err := svc.store.Users().Get(ctx, userID)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when retriving user account(%s) from database", userID)
+		return false, false, err
+	}
+	if userAccount == nil {
+		return false, false, nil
+	}
+
+	enrolled, err = svc.store.TwoFactor().DeleteEnrollment(ctx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when deleting two-factor enrollment of user(%s)", userID)
+		return false, false, err
+	}
+
+	err = svc.store.TwoFactor().DeleteUserChallenges(ctx, userAccount.Id)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when deleting pending logins of user(%s)", userID)
+		return false, false, err
+	}
+
+	if enrolled {
+		log.Logger().Info().Msgf("Two-factor enrollment of user(%s) was reset", userAccount.Username)
+	}
+	return true, enrolled, nil
+}
+
+func (svc *userService) listRoles(ctx context.Context) ([]*models.RoleTwoFactorPolicy, error) {
+	return svc.store.TwoFactor().ListRolePolicies(ctx)
+}
+
+func (svc *userService) updateRole(ctx context.Context, role string, req *mgmt.UpdateRoleRequest) (found bool,
+	err error) {
+	found, err = svc.store.TwoFactor().SetRequiredForRole(ctx, role, req.TwoFactorRequired)
+	if err != nil {
+		log.Logger().Error().Err(err).Msgf("Error occurred when updating two-factor policy of role(%s)", role)
+		return false, err
+	}
+	if found {
+		log.Logger().Info().Msgf("Two-factor authentication required for role(%s): %t", role, req.TwoFactorRequired)
+	}
+	return found, nil
+}
@@ -101,4 +101,29 @@ func isValidRole(role string) bool {
 	}
 
 	return true
-}
\ No newline at end of file
+}
+
+func ValidateCreateAccessToken(req *mgmt.CreateAccessTokenRequest) (bool, string) {
+	if len(req.Name) < 3 || len(req.Name) > 100 {
+		return false, "Token name must be between 3 and 100 characters"
+	}
+
+	if len(req.Scopes) == 0 {
+		return false, "At least one scope is required"
+	}
+
+	for i, scope := range req.Scopes {
+		if !slices.Contains(constants.AccessTokenScopes, scope) {
+			return false, fmt.Sprintf("Invalid scope: %s", scope)
+		}
+		if slices.Contains(req.Scopes[:i], scope) {
+			return false, fmt.Sprintf("Duplicate scope: %s", scope)
+		}
+	}
+
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > constants.MaxAccessTokenExpiryDays {
+		return false, fmt.Sprintf("expires_in_days must be between 0 and %d", constants.MaxAccessTokenExpiryDays)
+	}
+
+	return true, ""
+}
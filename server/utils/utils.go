@@ -4,6 +4,8 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"net"
+	"net/http"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -177,4 +179,29 @@ func isAllowedSymbol(ch rune) bool {
 		}
 	}
 	return false
-}
\ No newline at end of file
+}
+
+// ClientIP returns the client address of the request. X-Forwarded-For is preferred since the server is usually
+// behind a load balancer. Clients can set X-Forwarded-For, so use RemoteIP for rate limits.
+func ClientIP(r *http.Request) string {
+	xForwardedFor := r.Header.Get("X-Forwarded-For") // client_ip, lb1_ip, ........
+	clientIp := strings.TrimSpace(strings.Split(xForwardedFor, ",")[0])
+	if clientIp != "" {
+		return clientIp
+	}
+
+	clientIp, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return clientIp
+}
+
+// RemoteIP returns the address of the connection of the request, which clients can't change
+func RemoteIP(r *http.Request) string {
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return remoteIP
+}
@@ -3,6 +3,8 @@ package utils
 import (
 	"crypto/sha256"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
 	"testing"
 
@@ -174,3 +176,15 @@ func TestCombineAndCalculateSHA256Digest(t *testing.T) {
 		assert.Equal(t, tt.want, CombineAndCalculateSHA256Digest(tt.inputs...))
 	}
 }
+
+func TestRemoteIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "10.0.0.5:51234"
+	req.Header.Set("X-Forwarded-For", "192.168.1.1, 10.0.0.1")
+
+	assert.Equal(t, "10.0.0.5", RemoteIP(req))
+	assert.Equal(t, "192.168.1.1", ClientIP(req))
+
+	req.RemoteAddr = "[::1]:51234"
+	assert.Equal(t, "::1", RemoteIP(req))
+}